@@ -0,0 +1,81 @@
+package scimgateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// ApprovalsMiddleware serves the changes held for approval by plugins
+// configured with approval: GET /{plugin}/Approvals/{id} returns the change,
+// whose URL is the Location of the 202 Accepted response that held it, GET
+// /{plugin}/Approvals lists the plugin's changes, oldest first, optionally
+// filtered by the resourceId and status query parameters, and POST
+// /{plugin}/Approvals/{id}/approve and /reject decide a pending change,
+// applying it to the plugin if approved. Requests routed to a base entity
+// see its changes only. It is placed behind the plugin's authentication,
+// and authorization grants decisions with the admin operation. Other
+// requests, and requests to plugins without approval, are passed to next.
+func ApprovalsMiddleware(manager *plugin.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			rest, isApproval := strings.CutPrefix(endpoint, "Approvals/")
+			id, decision, _ := strings.Cut(rest, "/")
+			isApproval = isApproval && id != "" && !strings.Contains(decision, "/")
+			switch {
+			case endpoint == "Approvals" && r.Method == http.MethodGet:
+			case isApproval && decision == "" && r.Method == http.MethodGet:
+			case isApproval && (decision == "approve" || decision == "reject") && r.Method == http.MethodPost:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+			queue, ok := manager.GetApprovalQueue(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if endpoint == "Approvals" {
+				listOperations(w, r, queue, plugin.OperationPending, plugin.OperationApplied, plugin.OperationFailed, plugin.OperationRejected)
+				return
+			}
+
+			op, err := queue.Get(r.Context(), id)
+			if err == nil && !visible(r, op) {
+				err = plugin.ErrOperationNotFound
+			}
+			if err == nil {
+				switch decision {
+				case "approve":
+					op, err = queue.Approve(r.Context(), id)
+				case "reject":
+					op, err = queue.Reject(r.Context(), id)
+				}
+			}
+			switch {
+			case errors.Is(err, plugin.ErrOperationNotFound):
+				scim.NewHandler("").WriteError(w, http.StatusNotFound, fmt.Sprintf("Operation %s not found", id), "")
+			case errors.Is(err, plugin.ErrOperationDecided):
+				scim.NewHandler("").WriteError(w, http.StatusConflict, fmt.Sprintf("Operation %s was already decided", id), "")
+			case err != nil:
+				scim.NewHandler("").WriteError(w, http.StatusServiceUnavailable, fmt.Sprintf("Cannot %s operation %s: %v", approvalAction(decision), id, err), "")
+			default:
+				writeAdminJSON(w, operationStatus(op))
+			}
+		})
+	}
+}
+
+// approvalAction names what a request to a held change does, for errors
+func approvalAction(decision string) string {
+	if decision == "" {
+		return "read"
+	}
+	return decision
+}
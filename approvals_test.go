@@ -0,0 +1,82 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestApprovals(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Approval = &config.ApprovalConfig{Attributes: map[string][]string{"Users": {"title"}}}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	var user scim.User
+	if err := json.Unmarshal(do("POST", "/test/Users", `{"userName": "alice", "title": "Engineer"}`, "token").Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	w := do("PATCH", "/test/Users/"+user.ID, `{"schemas": ["`+scim.SchemaPatchOp+`"], "Operations": [{"op": "replace", "path": "title", "value": "Manager"}]}`, "token")
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(location, cfg.Gateway.BaseURL+"/test/Approvals/") {
+		t.Fatalf("patch status = %d, Location = %q, body: %s", w.Code, location, w.Body.String())
+	}
+	path := strings.TrimPrefix(location, cfg.Gateway.BaseURL)
+
+	// The approval endpoints require the plugin's credentials
+	if w := do("POST", path+"/approve", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+	if w := do("POST", "/test/Approvals/unknown/approve", "", "token"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown operation status = %d, want 404", w.Code)
+	}
+
+	var op plugin.Operation
+	if err := json.Unmarshal(do("GET", path, "", "token").Body.Bytes(), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != plugin.OperationPending || op.Approval == nil || op.Approval.Attributes[0] != "title" || op.Body != nil {
+		t.Errorf("operation = %+v, want a pending change of title", op)
+	}
+
+	w = do("POST", path+"/approve", "", "token")
+	if err := json.Unmarshal(w.Body.Bytes(), &op); err != nil || w.Code != http.StatusOK || op.Status != plugin.OperationApplied {
+		t.Fatalf("approve status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(do("GET", "/test/Users/"+user.ID, "", "token").Body.Bytes(), &user); err != nil || user.Title != "Manager" {
+		t.Errorf("title = %q after approval, want Manager", user.Title)
+	}
+	if w := do("POST", path+"/reject", "", "token"); w.Code != http.StatusConflict {
+		t.Errorf("reject of an applied change status = %d, want 409", w.Code)
+	}
+
+	var list scim.ListResponse[*plugin.Operation]
+	if err := json.Unmarshal(do("GET", "/test/Approvals?status=applied", "", "token").Body.Bytes(), &list); err != nil || list.TotalResults != 1 {
+		t.Errorf("list = %+v, %v, want the applied change", list, err)
+	}
+	if w := do("GET", "/test/Approvals?status=done", "", "token"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid status filter: status = %d, want 400", w.Code)
+	}
+}
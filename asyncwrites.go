@@ -0,0 +1,114 @@
+package scimgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// AsyncWritesMiddleware serves the status of the operations queued by
+// plugins configured with asyncWrites: GET /{plugin}/Operations/{id} returns
+// the operation, whose URL is the Location of the 202 Accepted response that
+// queued it, and GET /{plugin}/Operations lists the plugin's operations,
+// oldest first, optionally filtered by the resourceId and status query
+// parameters. Requests routed to a base entity see its operations only. It
+// is placed behind the plugin's authentication. Other requests, and requests
+// to plugins without asynchronous writes, are passed to next.
+func AsyncWritesMiddleware(manager *plugin.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			id, isOperation := strings.CutPrefix(endpoint, "Operations/")
+			isOperation = isOperation && id != "" && !strings.Contains(id, "/")
+			if (endpoint != "Operations" && !isOperation) || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			queue, ok := manager.GetAsyncQueue(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if endpoint == "Operations" {
+				listOperations(w, r, queue, plugin.OperationPending, plugin.OperationApplied, plugin.OperationFailed)
+				return
+			}
+
+			op, err := queue.Get(r.Context(), id)
+			if err == nil && !visible(r, op) {
+				err = plugin.ErrOperationNotFound
+			}
+			switch {
+			case errors.Is(err, plugin.ErrOperationNotFound):
+				scim.NewHandler("").WriteError(w, http.StatusNotFound, fmt.Sprintf("Operation %s not found", id), "")
+			case err != nil:
+				scim.NewHandler("").WriteError(w, http.StatusServiceUnavailable, fmt.Sprintf("Cannot read operation %s: %v", id, err), "")
+			default:
+				writeAdminJSON(w, operationStatus(op))
+			}
+		})
+	}
+}
+
+// operationQueue lists the operations of a plugin: a *plugin.AsyncQueue or
+// a *plugin.ApprovalQueue
+type operationQueue interface {
+	List(ctx context.Context) ([]*plugin.Operation, error)
+}
+
+// listOperations writes the operations of a queue matching the resourceId
+// and status query parameters as a list response. statuses are the states
+// the queue's operations can be in.
+func listOperations(w http.ResponseWriter, r *http.Request, queue operationQueue, statuses ...string) {
+	resourceID := r.URL.Query().Get("resourceId")
+	status := r.URL.Query().Get("status")
+	if status != "" && !slices.Contains(statuses, status) {
+		scim.NewHandler("").WriteError(w, http.StatusBadRequest,
+			fmt.Sprintf("Invalid status '%s', must be one of %s", status, strings.Join(statuses, ", ")), scim.ScimTypeInvalidValue)
+		return
+	}
+
+	ops, err := queue.List(r.Context())
+	if err != nil {
+		scim.NewHandler("").WriteError(w, http.StatusServiceUnavailable, fmt.Sprintf("Cannot list operations: %v", err), "")
+		return
+	}
+	ops = slices.DeleteFunc(ops, func(op *plugin.Operation) bool {
+		return !visible(r, op) || (resourceID != "" && op.ResourceID != resourceID) || (status != "" && op.Status != status)
+	})
+	if ops == nil {
+		ops = []*plugin.Operation{}
+	}
+	for i, op := range ops {
+		ops[i] = operationStatus(op)
+	}
+
+	writeAdminJSON(w, scim.ListResponse[*plugin.Operation]{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: len(ops),
+		StartIndex:   1,
+		ItemsPerPage: len(ops),
+		Resources:    ops,
+	})
+}
+
+// visible reports whether op can be read by a request: requests routed to a
+// base entity only see its operations
+func visible(r *http.Request, op *plugin.Operation) bool {
+	entity := scim.BaseEntityFromContext(r.Context())
+	return entity == "" || op.BaseEntity == entity
+}
+
+// operationStatus returns op without its body, which may hold secrets such
+// as the password of a user
+func operationStatus(op *plugin.Operation) *plugin.Operation {
+	op.Body = nil
+	return op
+}
@@ -0,0 +1,169 @@
+package scimgateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestAsyncWrites(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].AsyncWrites = &config.AsyncWritesConfig{}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice", "password": "s3cret"}`, "token")
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(location, cfg.Gateway.BaseURL+"/test/Operations/") {
+		t.Fatalf("create status = %d, Location = %q, body: %s", w.Code, location, w.Body.String())
+	}
+	path := strings.TrimPrefix(location, cfg.Gateway.BaseURL)
+
+	// The status endpoint requires the plugin's credentials
+	if w := do("GET", path, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+	if w := do("GET", "/test/Operations/unknown", "", "token"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown operation status = %d, want 404", w.Code)
+	}
+
+	var op plugin.Operation
+	if err := json.Unmarshal(do("GET", path, "", "token").Body.Bytes(), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != plugin.OperationPending || op.Method != "POST" || op.ResourceType != "Users" || op.Body != nil {
+		t.Errorf("operation = %+v, want a pending POST Users", op)
+	}
+
+	// The worker applies the operation
+	queue, _ := gw.PluginManager().GetAsyncQueue("test")
+	p, _ := gw.PluginManager().Get("test")
+	if done, err := queue.Process(context.Background(), plugin.NewAdapter(p)); err != nil || done != 1 {
+		t.Fatalf("Process() = %d, %v, want 1 operation done", done, err)
+	}
+
+	if err := json.Unmarshal(do("GET", path, "", "token").Body.Bytes(), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.Status != plugin.OperationApplied || op.ResourceID == "" {
+		t.Errorf("operation = %+v, want applied with the created user's ID", op)
+	}
+	var user scim.User
+	if err := json.Unmarshal(do("GET", "/test/Users/"+op.ResourceID, "", "token").Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.UserName != "alice" {
+		t.Errorf("created user = %+v, want alice", user)
+	}
+
+	// Other writes are queued too
+	w = do("PATCH", "/test/Users/"+op.ResourceID, `{"schemas": ["`+scim.SchemaPatchOp+`"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`, "token")
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") == "" {
+		t.Errorf("patch status = %d, body: %s", w.Code, w.Body.String())
+	}
+	w = do("DELETE", "/test/Users/"+op.ResourceID, "", "token")
+	if w.Code != http.StatusAccepted || w.Header().Get("Location") == "" {
+		t.Errorf("delete status = %d, body: %s", w.Code, w.Body.String())
+	}
+	do("POST", "/test/Users", `{"userName": "bob"}`, "token")
+
+	// Operations are listed oldest first and filtered by resource and status
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"POST", "PATCH", "DELETE", "POST"}},
+		{"?resourceId=" + op.ResourceID, []string{"POST", "PATCH", "DELETE"}},
+		{"?resourceId=" + op.ResourceID + "&status=pending", []string{"PATCH", "DELETE"}},
+		{"?status=applied", []string{"POST"}},
+		{"?status=failed", []string{}},
+	}
+	for _, tt := range tests {
+		w := do("GET", "/test/Operations"+tt.query, "", "token")
+		var list scim.ListResponse[*plugin.Operation]
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("list %q: %v, body: %s", tt.query, err, w.Body.String())
+		}
+		methods := []string{}
+		for _, op := range list.Resources {
+			methods = append(methods, op.Method)
+		}
+		if list.TotalResults != len(tt.want) || !slices.Equal(methods, tt.want) {
+			t.Errorf("list %q = %v, want %v", tt.query, methods, tt.want)
+		}
+	}
+
+	if w := do("GET", "/test/Operations?status=done", "", "token"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid status filter: status = %d, want 400", w.Code)
+	}
+}
+
+func TestAsyncWritesBaseEntity(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].AsyncWrites = &config.AsyncWritesConfig{}
+	cfg.Plugins[0].BaseEntities = []config.BaseEntityConfig{{Name: "acme"}, {Name: "globex"}}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/acme/Users", `{"userName": "alice"}`)
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(location, "http://localhost:8080/test/acme/Operations/") {
+		t.Fatalf("create status = %d, Location = %q", w.Code, location)
+	}
+	id := strings.TrimPrefix(location, "http://localhost:8080/test/acme/Operations/")
+
+	var op plugin.Operation
+	if err := json.Unmarshal(do("GET", "/test/acme/Operations/"+id, "").Body.Bytes(), &op); err != nil {
+		t.Fatal(err)
+	}
+	if op.BaseEntity != "acme" {
+		t.Errorf("operation base entity = %q, want acme", op.BaseEntity)
+	}
+
+	// Other base entities do not see the operation; the plugin does
+	if w := do("GET", "/test/globex/Operations/"+id, ""); w.Code != http.StatusNotFound {
+		t.Errorf("operation of another base entity: status = %d, want 404", w.Code)
+	}
+	var list scim.ListResponse[*plugin.Operation]
+	if err := json.Unmarshal(do("GET", "/test/globex/Operations", "").Body.Bytes(), &list); err != nil || list.TotalResults != 0 {
+		t.Errorf("operations of another base entity = %+v, %v", list, err)
+	}
+	if w := do("GET", "/test/Operations/"+id, ""); w.Code != http.StatusOK {
+		t.Errorf("operation through the plugin: status = %d, want 200", w.Code)
+	}
+}
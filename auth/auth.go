@@ -1,11 +1,16 @@
 package auth
 
 import (
-	"crypto/subtle"
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scim"
 )
 
 // AuthType represents the type of authentication
@@ -15,6 +20,9 @@ const (
 	AuthTypeNone   AuthType = "none"
 	AuthTypeBasic  AuthType = "basic"
 	AuthTypeBearer AuthType = "bearer"
+	AuthTypeOAuth2 AuthType = "oauth2"
+	AuthTypeJWT    AuthType = "jwt"
+	AuthTypeMTLS   AuthType = "mtls"
 )
 
 // Authenticator defines the interface for authentication
@@ -22,10 +30,144 @@ type Authenticator interface {
 	Authenticate(r *http.Request) error
 }
 
+// Identifier is an optional interface for authenticators that can name the
+// client of a request, e.g. by username or certificate identity. Identity
+// authenticates the request like Authenticate and returns that name.
+type Identifier interface {
+	Identity(r *http.Request) (string, error)
+}
+
+// AuthResult describes the client an authenticator accepted a request from
+type AuthResult struct {
+	// Subject names the client, e.g. the Basic auth username, the "sub"
+	// claim of an access token, the certificate identity of mTLS or the
+	// label of a static bearer token. Unlabeled shared secrets leave it
+	// empty.
+	Subject string
+
+	// Scopes are the scopes granted to the client, e.g. of an access token
+	Scopes []string
+
+	// Claims are further attributes of the client, such as the claims of
+	// an access token. They must not be modified.
+	Claims map[string]any
+}
+
+// PrincipalAuthenticator is an optional interface for authenticators that
+// can describe the client of a request beyond a name (see Identifier).
+// AuthenticatePrincipal authenticates the request like Authenticate and
+// returns the client it was accepted from. The gateway passes the result to
+// plugins, see scim.PrincipalFromContext.
+type PrincipalAuthenticator interface {
+	AuthenticatePrincipal(r *http.Request) (AuthResult, error)
+}
+
+// Principal authenticates r with authenticator and describes its client,
+// with AuthenticatePrincipal or Identity when the authenticator implements
+// them. Other authenticators give an empty AuthResult.
+func Principal(authenticator Authenticator, r *http.Request) (AuthResult, error) {
+	switch a := authenticator.(type) {
+	case PrincipalAuthenticator:
+		return a.AuthenticatePrincipal(r)
+	case Identifier:
+		subject, err := a.Identity(r)
+		return AuthResult{Subject: subject}, err
+	default:
+		return AuthResult{}, authenticator.Authenticate(r)
+	}
+}
+
+// resultKey is the context key of the AuthResult of a request
+type resultKey struct{}
+
+// ResultFromContext returns the AuthResult of a request that passed
+// Middleware
+func ResultFromContext(ctx context.Context) (AuthResult, bool) {
+	result, ok := ctx.Value(resultKey{}).(AuthResult)
+	return result, ok
+}
+
+// Credential is a secret accepted by a BasicAuthenticator or a
+// BearerAuthenticator besides its primary one, so secrets can be rotated
+// without downtime and several clients can have their own
+type Credential struct {
+	// Label names the credential, e.g. the identity provider tenant using
+	// it. It is the subject of clients authenticated with a bearer token.
+	Label string
+
+	// Username is the Basic auth username; bearer tokens have none
+	Username string
+
+	// Secret is the Basic auth password or the bearer token
+	Secret string
+
+	// SecretProvider, if set, supplies the secret at request time instead
+	// of Secret
+	SecretProvider SecretProvider
+
+	// ExpiresAt is when the credential stops being accepted. Zero never
+	// expires.
+	ExpiresAt time.Time
+}
+
+// match returns the first of credentials valid at now matching username and
+// secret. All credentials are compared in constant time, fetching the
+// secrets of those with a SecretProvider. Credentials whose secret cannot be
+// fetched don't match; the error is returned if none matches.
+func match(ctx context.Context, credentials []Credential, now time.Time, username, secret string) (Credential, error) {
+	var matched Credential
+	var fetchErr error
+	found := false
+	for _, c := range credentials {
+		want := c.Secret
+		if c.SecretProvider != nil {
+			var err error
+			if want, err = c.SecretProvider.Secret(ctx); err != nil {
+				fetchErr = fmt.Errorf("fetching secret: %w", err)
+				continue
+			}
+		}
+		usernameMatch := secretsEqual(username, c.Username)
+		secretMatch := want != "" && secretsEqual(secret, want)
+		expired := !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+		if usernameMatch && secretMatch && !expired && !found {
+			matched, found = c, true
+		}
+	}
+	if !found {
+		if fetchErr != nil {
+			return Credential{}, fetchErr
+		}
+		return Credential{}, errNoMatch
+	}
+	return matched, nil
+}
+
+// errNoMatch is returned by match if no credential matches
+var errNoMatch = errors.New("no matching credential")
+
+// now returns the time of c, or of the system clock if c is nil
+func now(c clock.Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
 // BasicAuthenticator implements HTTP Basic authentication
 type BasicAuthenticator struct {
 	Username string
 	Password string
+
+	// PasswordProvider, if set, supplies the password at request time
+	// instead of Password
+	PasswordProvider SecretProvider
+
+	// Credentials are further username and password pairs accepted
+	Credentials []Credential
+
+	// Clock is the time credentials expire at. Nil uses the system clock.
+	Clock clock.Clock
 }
 
 // NewBasicAuthenticator creates a new basic authenticator
@@ -38,41 +180,60 @@ func NewBasicAuthenticator(username, password string) *BasicAuthenticator {
 
 // Authenticate validates basic authentication credentials
 func (ba *BasicAuthenticator) Authenticate(r *http.Request) error {
+	_, err := ba.Identity(r)
+	return err
+}
+
+// Identity implements Identifier, naming the client by its username
+func (ba *BasicAuthenticator) Identity(r *http.Request) (string, error) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
-		return fmt.Errorf("missing authorization header")
+		return "", fmt.Errorf("missing authorization header")
 	}
 
 	if !strings.HasPrefix(auth, "Basic ") {
-		return fmt.Errorf("invalid authorization type")
+		return "", fmt.Errorf("invalid authorization type")
 	}
 
 	payload, err := base64.StdEncoding.DecodeString(auth[6:])
 	if err != nil {
-		return fmt.Errorf("invalid base64 encoding")
+		return "", fmt.Errorf("invalid base64 encoding")
 	}
 
 	parts := strings.SplitN(string(payload), ":", 2)
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid authorization format")
+		return "", fmt.Errorf("invalid authorization format")
 	}
 
 	username, password := parts[0], parts[1]
 
-	// Use constant-time comparison to prevent timing attacks
-	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(ba.Username)) == 1
-	passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(ba.Password)) == 1
-
-	if !usernameMatch || !passwordMatch {
-		return fmt.Errorf("invalid credentials")
+	credentials := ba.Credentials
+	if ba.Password != "" || ba.PasswordProvider != nil {
+		credentials = append([]Credential{{Username: ba.Username, Secret: ba.Password, SecretProvider: ba.PasswordProvider}}, credentials...)
+	}
+	if _, err := match(r.Context(), credentials, now(ba.Clock), username, password); err != nil {
+		if errors.Is(err, errNoMatch) {
+			return "", fmt.Errorf("invalid credentials")
+		}
+		return "", err
 	}
 
-	return nil
+	return username, nil
 }
 
 // BearerAuthenticator implements Bearer token authentication
 type BearerAuthenticator struct {
 	Token string
+
+	// TokenProvider, if set, supplies the token at request time instead of
+	// Token
+	TokenProvider SecretProvider
+
+	// Tokens are further tokens accepted, naming their clients by label
+	Tokens []Credential
+
+	// Clock is the time tokens expire at. Nil uses the system clock.
+	Clock clock.Clock
 }
 
 // NewBearerAuthenticator creates a new bearer token authenticator
@@ -84,23 +245,37 @@ func NewBearerAuthenticator(token string) *BearerAuthenticator {
 
 // Authenticate validates bearer token
 func (ba *BearerAuthenticator) Authenticate(r *http.Request) error {
+	_, err := ba.AuthenticatePrincipal(r)
+	return err
+}
+
+// AuthenticatePrincipal implements PrincipalAuthenticator, naming the client
+// by the label of its token
+func (ba *BearerAuthenticator) AuthenticatePrincipal(r *http.Request) (AuthResult, error) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" {
-		return fmt.Errorf("missing authorization header")
+		return AuthResult{}, fmt.Errorf("missing authorization header")
 	}
 
 	if !strings.HasPrefix(auth, "Bearer ") {
-		return fmt.Errorf("invalid authorization type")
+		return AuthResult{}, fmt.Errorf("invalid authorization type")
 	}
 
 	token := auth[7:]
 
-	// Use constant-time comparison
-	if subtle.ConstantTimeCompare([]byte(token), []byte(ba.Token)) != 1 {
-		return fmt.Errorf("invalid token")
+	tokens := ba.Tokens
+	if ba.Token != "" || ba.TokenProvider != nil {
+		tokens = append([]Credential{{Secret: ba.Token, SecretProvider: ba.TokenProvider}}, tokens...)
+	}
+	matched, err := match(r.Context(), tokens, now(ba.Clock), "", token)
+	if err != nil {
+		if errors.Is(err, errNoMatch) {
+			return AuthResult{}, fmt.Errorf("invalid token")
+		}
+		return AuthResult{}, err
 	}
 
-	return nil
+	return AuthResult{Subject: matched.Label}, nil
 }
 
 // MultiAuthenticator supports multiple authentication methods
@@ -117,27 +292,35 @@ func NewMultiAuthenticator(authenticators ...Authenticator) *MultiAuthenticator
 
 // Authenticate tries each authenticator until one succeeds
 func (ma *MultiAuthenticator) Authenticate(r *http.Request) error {
+	_, err := ma.AuthenticatePrincipal(r)
+	return err
+}
+
+// AuthenticatePrincipal implements PrincipalAuthenticator, describing the
+// client as the first authenticator accepting the request does
+func (ma *MultiAuthenticator) AuthenticatePrincipal(r *http.Request) (AuthResult, error) {
 	if len(ma.Authenticators) == 0 {
-		return nil // No authentication required
+		return AuthResult{}, nil // No authentication required
 	}
 
 	var lastErr error
 	for _, auth := range ma.Authenticators {
-		if err := auth.Authenticate(r); err == nil {
-			return nil // Authentication successful
+		if result, err := Principal(auth, r); err == nil {
+			return result, nil // Authentication successful
 		} else {
 			lastErr = err
 		}
 	}
 
 	if lastErr != nil {
-		return lastErr
+		return AuthResult{}, lastErr
 	}
 
-	return fmt.Errorf("authentication failed")
+	return AuthResult{}, fmt.Errorf("authentication failed")
 }
 
-// Middleware creates an authentication middleware
+// Middleware creates an authentication middleware. Requests passing it
+// carry the AuthResult of their client, see ResultFromContext.
 func Middleware(authenticator Authenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -146,15 +329,14 @@ func Middleware(authenticator Authenticator) func(http.Handler) http.Handler {
 				return
 			}
 
-			if err := authenticator.Authenticate(r); err != nil {
+			result, err := Principal(authenticator, r)
+			if err != nil {
 				w.Header().Set("WWW-Authenticate", `Basic realm="SCIM Gateway"`)
-				w.Header().Set("Content-Type", "application/scim+json")
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"401","detail":"Unauthorized"}`))
+				scim.NewHandler("").WriteError(w, http.StatusUnauthorized, "Unauthorized", "")
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), resultKey{}, result)))
 		})
 	}
 }
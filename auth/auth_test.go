@@ -2,9 +2,15 @@ package auth
 
 import (
 	"encoding/base64"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scim"
 )
 
 func TestBasicAuthenticator(t *testing.T) {
@@ -107,6 +113,69 @@ func TestBearerAuthenticator(t *testing.T) {
 	}
 }
 
+func TestCredentialRotation(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+	basic := &BasicAuthenticator{
+		Username: "okta",
+		Password: "old",
+		Credentials: []Credential{
+			{Label: "next", Username: "okta", Secret: "new"},
+			{Label: "tenant-b", Username: "entra", Secret: "b-secret", ExpiresAt: now.Add(time.Hour)},
+		},
+		Clock: fake,
+	}
+	bearer := &BearerAuthenticator{
+		Tokens: []Credential{
+			{Label: "tenant-a", Secret: "a-token"},
+			{Label: "tenant-b", Secret: "b-token", ExpiresAt: now.Add(time.Hour)},
+		},
+		Clock: fake,
+	}
+	basicHeader := func(username, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		header        string
+		expired       bool
+		want          AuthResult
+		wantErr       bool
+	}{
+		{name: "basic primary", authenticator: basic, header: basicHeader("okta", "old"), want: AuthResult{Subject: "okta"}},
+		{name: "basic rotated", authenticator: basic, header: basicHeader("okta", "new"), want: AuthResult{Subject: "okta"}},
+		{name: "basic other tenant", authenticator: basic, header: basicHeader("entra", "b-secret"), want: AuthResult{Subject: "entra"}},
+		{name: "basic secret of another user", authenticator: basic, header: basicHeader("entra", "new"), wantErr: true},
+		{name: "basic expired", authenticator: basic, header: basicHeader("entra", "b-secret"), expired: true, wantErr: true},
+		{name: "basic unexpired after expiry", authenticator: basic, header: basicHeader("okta", "new"), expired: true, want: AuthResult{Subject: "okta"}},
+		{name: "bearer labeled", authenticator: bearer, header: "Bearer a-token", want: AuthResult{Subject: "tenant-a"}},
+		{name: "bearer other tenant", authenticator: bearer, header: "Bearer b-token", want: AuthResult{Subject: "tenant-b"}},
+		{name: "bearer expired", authenticator: bearer, header: "Bearer b-token", expired: true, wantErr: true},
+		{name: "bearer without primary token", authenticator: bearer, header: "Bearer ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.Set(now)
+			if tt.expired {
+				fake.Set(now.Add(time.Hour))
+			}
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", tt.header)
+
+			got, err := Principal(tt.authenticator, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Principal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Principal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestMultiAuthenticator(t *testing.T) {
 	basic := NewBasicAuthenticator("admin", "secret")
 	bearer := NewBearerAuthenticator("my-token")
@@ -201,6 +270,83 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 }
 
+// textEncoder writes responses as Go syntax under its own media type
+type textEncoder struct{}
+
+func (textEncoder) ContentType() string { return "text/plain" }
+
+func (textEncoder) Encode(v any) ([]byte, error) { return fmt.Appendf(nil, "%+v", v), nil }
+
+func TestMiddlewareEncoder(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	middleware := scim.EncoderMiddleware(textEncoder{})(Middleware(NewBasicAuthenticator("admin", "secret"))(handler))
+
+	w := httptest.NewRecorder()
+	middleware.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("response = %d %s, want 401 written by the request's encoder", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+// scopedAuthenticator accepts the token "t" as a client with scopes
+type scopedAuthenticator struct{}
+
+func (scopedAuthenticator) Authenticate(r *http.Request) error {
+	_, err := scopedAuthenticator{}.AuthenticatePrincipal(r)
+	return err
+}
+
+func (scopedAuthenticator) AuthenticatePrincipal(r *http.Request) (AuthResult, error) {
+	if r.Header.Get("Authorization") != "Bearer t" {
+		return AuthResult{}, fmt.Errorf("invalid token")
+	}
+	return AuthResult{Subject: "okta", Scopes: []string{"scim.read"}, Claims: map[string]any{"tenant": "acme"}}, nil
+}
+
+func TestPrincipal(t *testing.T) {
+	scoped := AuthResult{Subject: "okta", Scopes: []string{"scim.read"}, Claims: map[string]any{"tenant": "acme"}}
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		header        string
+		want          AuthResult
+		wantErr       bool
+	}{
+		{name: "principal authenticator", authenticator: scopedAuthenticator{}, header: "Bearer t", want: scoped},
+		{name: "principal authenticator rejecting", authenticator: scopedAuthenticator{}, header: "Bearer x", wantErr: true},
+		{name: "identifier", authenticator: NewBasicAuthenticator("admin", "secret"), header: "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret")), want: AuthResult{Subject: "admin"}},
+		{name: "plain authenticator", authenticator: NewBearerAuthenticator("t"), header: "Bearer t", want: AuthResult{}},
+		{name: "multi authenticator", authenticator: NewMultiAuthenticator(NewBearerAuthenticator("other"), scopedAuthenticator{}), header: "Bearer t", want: scoped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", tt.header)
+
+			got, err := Principal(tt.authenticator, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Principal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Principal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var result AuthResult
+	var ok bool
+	handler := Middleware(scopedAuthenticator{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, ok = ResultFromContext(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer t")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !ok || !reflect.DeepEqual(result, scoped) {
+		t.Errorf("ResultFromContext() = %+v, %v; want %+v", result, ok, scoped)
+	}
+}
+
 func TestNoAuth(t *testing.T) {
 	noAuth := &NoAuth{}
 	req := httptest.NewRequest("GET", "/", nil)
@@ -0,0 +1,323 @@
+// Package jwt implements an authenticator for bearer tokens that are signed
+// JWTs, issued by an identity provider or a token service of the client.
+//
+// Tokens are verified with keys from a JWKS endpoint or with static PEM
+// public keys, with the signature algorithms allowed, and their expiry,
+// issuer and audience are checked. The client is described by configurable
+// claims, e.g. the "azp" claim as its subject and Keycloak's
+// "realm_access.roles" as its scopes.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/internal/jose"
+)
+
+const (
+	// DefaultCacheTTL is how long keys of a JWKS endpoint are cached
+	DefaultCacheTTL = 5 * time.Minute
+
+	// DefaultClockSkew is the leeway applied to exp and nbf checks
+	DefaultClockSkew = time.Minute
+
+	// DefaultSubjectClaim is the claim naming the client
+	DefaultSubjectClaim = "sub"
+)
+
+// Algorithms are the signature algorithms supported, and allowed when none
+// are configured. Symmetric (HS*) and unsigned ("none") tokens are never
+// accepted.
+var Algorithms = slices.Clone(jose.DefaultAlgorithms)
+
+// Config configures a JWT authenticator. Exactly one of JWKSURL,
+// PublicKeyPEM and PublicKeyFile must be set.
+type Config struct {
+	// JWKSURL verifies tokens with the keys of this endpoint, selected by
+	// the "kid" header of the token
+	JWKSURL string
+
+	// PublicKeyPEM verifies tokens with static keys: PEM blocks of PKIX
+	// ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY") public keys, or of
+	// certificates. A token signed by any of them is accepted.
+	PublicKeyPEM []byte
+
+	// PublicKeyFile is a file holding PublicKeyPEM
+	PublicKeyFile string
+
+	// Algorithms restricts the signature algorithms accepted, e.g. RS256.
+	// Nil allows all Algorithms.
+	Algorithms []string
+
+	// Issuer and Audience are checked when set
+	Issuer   string
+	Audience string
+
+	// SubjectClaim is the claim naming the client, e.g. "azp" or
+	// "client_id". Empty uses DefaultSubjectClaim.
+	SubjectClaim string
+
+	// ScopesClaim is the claim holding the scopes of the client, as a list
+	// or a space-separated string, e.g. "roles". Empty uses the "scope" or
+	// "scp" claim. Nested claims are named by their path, e.g.
+	// "realm_access.roles".
+	ScopesClaim string
+
+	// ClockSkew is the leeway applied to the exp and nbf claims.
+	// Zero uses DefaultClockSkew.
+	ClockSkew time.Duration
+
+	// CacheTTL is how long keys of JWKSURL are cached.
+	// Zero uses DefaultCacheTTL.
+	CacheTTL time.Duration
+
+	// HTTPClient is used to fetch the keys of JWKSURL.
+	// Nil uses a client with a 10 second timeout.
+	HTTPClient *http.Client
+
+	// Clock is the time tokens are validated at. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// Authenticator validates bearer tokens that are signed JWTs
+type Authenticator struct {
+	cfg  Config
+	keys []jose.KeyProvider // tried in order until one verifies the token
+}
+
+// New creates a JWT authenticator
+func New(cfg Config) (*Authenticator, error) {
+	sources := 0
+	for _, set := range []bool{cfg.JWKSURL != "", len(cfg.PublicKeyPEM) > 0, cfg.PublicKeyFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("jwt: exactly one of JWKS URL, public key and public key file must be set")
+	}
+	for _, alg := range cfg.Algorithms {
+		if !slices.Contains(Algorithms, alg) {
+			return nil, fmt.Errorf("jwt: unsupported signing algorithm %q", alg)
+		}
+	}
+	if cfg.SubjectClaim == "" {
+		cfg.SubjectClaim = DefaultSubjectClaim
+	}
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = DefaultClockSkew
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.System
+	}
+
+	a := &Authenticator{cfg: cfg}
+	if cfg.JWKSURL != "" {
+		client := cfg.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+		a.keys = []jose.KeyProvider{jose.NewRemoteKeySet(cfg.JWKSURL, client, cfg.CacheTTL)}
+		return a, nil
+	}
+
+	data := cfg.PublicKeyPEM
+	if cfg.PublicKeyFile != "" {
+		var err error
+		if data, err = os.ReadFile(cfg.PublicKeyFile); err != nil {
+			return nil, fmt.Errorf("jwt: failed to read public key: %w", err)
+		}
+	}
+	keys, err := ParsePublicKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: %w", err)
+	}
+	for _, key := range keys {
+		a.keys = append(a.keys, staticKey{key})
+	}
+	return a, nil
+}
+
+// ParsePublicKeys parses the PEM public keys and certificates of data
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", strings.ToLower(block.Type), err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM public key found")
+	}
+	return keys, nil
+}
+
+// staticKey is a KeyProvider returning a key whatever the key ID
+type staticKey struct {
+	key crypto.PublicKey
+}
+
+func (s staticKey) Key(context.Context, string) (crypto.PublicKey, error) {
+	return s.key, nil
+}
+
+// Authenticate validates the bearer token of the request
+func (a *Authenticator) Authenticate(r *http.Request) error {
+	_, err := a.AuthenticatePrincipal(r)
+	return err
+}
+
+// AuthenticatePrincipal implements auth.PrincipalAuthenticator: it validates
+// the bearer token of the request and describes the client with the
+// configured subject and scopes claims
+func (a *Authenticator) AuthenticatePrincipal(r *http.Request) (auth.AuthResult, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return auth.AuthResult{}, fmt.Errorf("missing authorization header")
+	}
+
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return auth.AuthResult{}, fmt.Errorf("invalid authorization type")
+	}
+
+	token := strings.TrimSpace(authHeader[7:])
+	if token == "" {
+		return auth.AuthResult{}, fmt.Errorf("missing token")
+	}
+
+	claims, err := a.verify(r.Context(), token)
+	if err != nil {
+		return auth.AuthResult{}, err
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return auth.AuthResult{}, err
+	}
+
+	subject, _ := claim(claims, a.cfg.SubjectClaim).(string)
+	return auth.AuthResult{
+		Subject: subject,
+		Scopes:  a.scopes(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// verify checks the signature of token with the keys and returns its
+// claims
+func (a *Authenticator) verify(ctx context.Context, token string) (jose.Claims, error) {
+	var err error
+	for _, keys := range a.keys {
+		var claims jose.Claims
+		if claims, err = jose.Verify(ctx, token, keys, a.cfg.Algorithms); err == nil {
+			return claims, nil
+		}
+	}
+	return nil, err
+}
+
+// checkClaims validates the time, issuer and audience claims
+func (a *Authenticator) checkClaims(claims jose.Claims) error {
+	now := a.cfg.Clock.Now()
+	exp := claims.Time("exp")
+	if exp.IsZero() {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(exp.Add(a.cfg.ClockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf := claims.Time("nbf"); !nbf.IsZero() && now.Add(a.cfg.ClockSkew).Before(nbf) {
+		return fmt.Errorf("token not yet valid")
+	}
+
+	if a.cfg.Issuer != "" && claims.String("iss") != a.cfg.Issuer {
+		return fmt.Errorf("invalid token issuer")
+	}
+	if a.cfg.Audience != "" && !slices.Contains(claims.Strings("aud"), a.cfg.Audience) {
+		return fmt.Errorf("invalid token audience")
+	}
+	return nil
+}
+
+// scopes returns the scopes of the configured claim, or of the "scope" or
+// "scp" claim
+func (a *Authenticator) scopes(claims jose.Claims) []string {
+	if a.cfg.ScopesClaim != "" {
+		return claimStrings(claim(claims, a.cfg.ScopesClaim))
+	}
+	if scopes := claimStrings(claims["scope"]); len(scopes) > 0 {
+		return scopes
+	}
+	return claimStrings(claims["scp"])
+}
+
+// claim returns the claim named path, or the claim nested at its
+// dot-separated path, e.g. "realm_access.roles". Claims whose names contain
+// dots, such as URLs, are found by their name.
+func claim(claims map[string]any, path string) any {
+	if value, ok := claims[path]; ok {
+		return value
+	}
+	name, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil
+	}
+	nested, _ := claims[name].(map[string]any)
+	if nested == nil {
+		return nil
+	}
+	return claim(nested, rest)
+}
+
+// claimStrings returns the values of a claim holding a list of strings or a
+// space-separated string
+func claimStrings(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
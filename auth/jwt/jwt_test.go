@@ -0,0 +1,228 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/clock"
+)
+
+var now = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func encodeSegment(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "key-1"}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(map[string]string{"alg": "ES256", "typ": "JWT"}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func publicKeyPEM(t *testing.T, key crypto.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func principal(a *Authenticator, token string) (auth.AuthResult, error) {
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return a.AuthenticatePrincipal(req)
+}
+
+func TestNew(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keyFile := filepath.Join(t.TempDir(), "public-key.pem")
+	if err := os.WriteFile(keyFile, publicKeyPEM(t, &rsaKey.PublicKey), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "JWKS", cfg: Config{JWKSURL: "https://idp.example.com/jwks"}},
+		{name: "PEM", cfg: Config{PublicKeyPEM: publicKeyPEM(t, &rsaKey.PublicKey)}},
+		{name: "PEM file", cfg: Config{PublicKeyFile: keyFile}},
+		{name: "no keys", cfg: Config{}, wantErr: true},
+		{name: "JWKS and PEM", cfg: Config{JWKSURL: "https://idp.example.com/jwks", PublicKeyFile: keyFile}, wantErr: true},
+		{name: "missing file", cfg: Config{PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: true},
+		{name: "no PEM block", cfg: Config{PublicKeyPEM: []byte("not a key")}, wantErr: true},
+		{name: "symmetric algorithm", cfg: Config{JWKSURL: "https://idp.example.com/jwks", Algorithms: []string{"HS256"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticatePrincipal(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	keys := append(publicKeyPEM(t, &rsaKey.PublicKey), publicKeyPEM(t, &ecKey.PublicKey)...)
+
+	a, err := New(Config{
+		PublicKeyPEM: keys,
+		Issuer:       "https://idp.example.com",
+		Audience:     "scim",
+		SubjectClaim: "azp",
+		ScopesClaim:  "realm_access.roles",
+		Clock:        clock.NewFake(now),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsOnly, err := New(Config{PublicKeyPEM: keys, Algorithms: []string{"RS256"}, Clock: clock.NewFake(now)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := func(overrides map[string]any) map[string]any {
+		claims := map[string]any{
+			"iss":          "https://idp.example.com",
+			"aud":          []string{"scim", "other"},
+			"sub":          "service-account-okta",
+			"azp":          "okta",
+			"exp":          now.Add(time.Hour).Unix(),
+			"realm_access": map[string]any{"roles": []string{"scim.read", "scim.write"}},
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name          string
+		authenticator *Authenticator
+		token         string
+		want          auth.AuthResult
+		wantErr       bool
+	}{
+		{name: "RSA key", authenticator: a, token: signRS256(t, rsaKey, valid(nil)),
+			want: auth.AuthResult{Subject: "okta", Scopes: []string{"scim.read", "scim.write"}}},
+		{name: "second key", authenticator: a, token: signES256(t, ecKey, valid(nil)),
+			want: auth.AuthResult{Subject: "okta", Scopes: []string{"scim.read", "scim.write"}}},
+		{name: "unknown key", authenticator: a, token: signRS256(t, otherKey, valid(nil)), wantErr: true},
+		{name: "algorithm not allowed", authenticator: rsOnly, token: signES256(t, ecKey, valid(nil)), wantErr: true},
+		{name: "default scope claim", authenticator: rsOnly, token: signRS256(t, rsaKey, valid(map[string]any{"scope": "scim.read"})),
+			want: auth.AuthResult{Subject: "service-account-okta", Scopes: []string{"scim.read"}}},
+		{name: "wrong issuer", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"iss": "https://evil.example.com"})), wantErr: true},
+		{name: "wrong audience", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"aud": "other"})), wantErr: true},
+		{name: "expired past skew", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})), wantErr: true},
+		{name: "expired within skew", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})),
+			want: auth.AuthResult{Subject: "okta", Scopes: []string{"scim.read", "scim.write"}}},
+		{name: "no expiry", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"exp": nil})), wantErr: true},
+		{name: "not yet valid", authenticator: a, token: signRS256(t, rsaKey, valid(map[string]any{"nbf": now.Add(time.Hour).Unix()})), wantErr: true},
+		{name: "malformed", authenticator: a, token: "not.a.jwt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := principal(tt.authenticator, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AuthenticatePrincipal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Subject != tt.want.Subject || !reflect.DeepEqual(got.Scopes, tt.want.Scopes) || got.Claims["iss"] == nil {
+				t.Errorf("AuthenticatePrincipal() = %+v, want %+v with the token's claims", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{ // nolint:errcheck
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+			}},
+		})
+	}))
+	defer server.Close()
+
+	a, err := New(Config{JWKSURL: server.URL, Clock: clock.NewFake(now)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := principal(a, signRS256(t, key, map[string]any{"sub": "okta", "exp": now.Add(time.Hour).Unix()}))
+	if err != nil || got.Subject != "okta" {
+		t.Errorf("AuthenticatePrincipal() = %+v, %v, want subject okta", got, err)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	claims := map[string]any{
+		"https://example.com/roles": []any{"admin"},
+		"realm_access":              map[string]any{"roles": []any{"scim.read"}},
+	}
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"https://example.com/roles", []string{"admin"}},
+		{"realm_access.roles", []string{"scim.read"}},
+		{"realm_access.missing", nil},
+		{"missing.roles", nil},
+	}
+	for _, tt := range tests {
+		if got := claimStrings(claim(claims, tt.path)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("claim(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package mtls implements an authenticator for TLS client certificates.
+//
+// Certificates presented during the TLS handshake are verified against a
+// per-plugin CA bundle, and the certificate's subject common name and subject
+// alternative names are mapped to the identities allowed to use the plugin.
+// The gateway's TLS server requests client certificates without verifying them,
+// so each plugin can trust its own CAs.
+package mtls
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+)
+
+// Config configures an mTLS authenticator
+type Config struct {
+	// CAFile is a PEM bundle of the CAs client certificates must chain to
+	CAFile string
+
+	// CAs is used instead of CAFile when set
+	CAs *x509.CertPool
+
+	// AllowedIdentities restricts access to certificates with one of these
+	// identities (see Identities). Empty allows any certificate issued by the CAs.
+	AllowedIdentities []string
+}
+
+// Authenticator validates TLS client certificates
+type Authenticator struct {
+	roots   *x509.CertPool
+	allowed []string
+	now     func() time.Time
+}
+
+// New creates an mTLS authenticator
+func New(cfg Config) (*Authenticator, error) {
+	roots := cfg.CAs
+	if roots == nil {
+		if cfg.CAFile == "" {
+			return nil, errors.New("mtls: a CA bundle is required")
+		}
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mtls: failed to read CA bundle: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mtls: no certificates found in %s", cfg.CAFile)
+		}
+	}
+
+	return &Authenticator{
+		roots:   roots,
+		allowed: cfg.AllowedIdentities,
+		now:     time.Now,
+	}, nil
+}
+
+// Authenticate verifies the client certificate of the request's TLS connection
+func (a *Authenticator) Authenticate(r *http.Request) error {
+	_, err := a.Identity(r)
+	return err
+}
+
+// Identity verifies the client certificate of the request's TLS connection and
+// returns the identity it was authorized as: the first allowed identity of the
+// certificate, or its first identity when any certificate is allowed.
+func (a *Authenticator) Identity(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", errors.New("client certificate required")
+	}
+
+	leaf := r.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.roots,
+		Intermediates: intermediates,
+		CurrentTime:   a.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid client certificate: %w", err)
+	}
+
+	identities := Identities(leaf)
+	if len(a.allowed) == 0 {
+		if len(identities) == 0 {
+			return "", nil
+		}
+		return identities[0], nil
+	}
+	for _, identity := range identities {
+		if slices.Contains(a.allowed, identity) {
+			return identity, nil
+		}
+	}
+	return "", fmt.Errorf("client certificate identity not allowed: %v", identities)
+}
+
+// Identities returns the identities of a certificate: its subject common name
+// followed by its DNS, email and URI subject alternative names
+func Identities(cert *x509.Certificate) []string {
+	var identities []string
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	identities = append(identities, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	return identities
+}
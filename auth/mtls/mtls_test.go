@@ -0,0 +1,259 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	cert, key := issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue creates a certificate from template, signed by parent or self-signed
+func issue(t *testing.T, template *x509.Certificate, parent *testCA) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func (ca *testCA) client(t *testing.T, commonName string, sans ...string) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, san := range sans {
+		if u, err := url.Parse(san); err == nil && u.Scheme != "" {
+			template.URIs = append(template.URIs, u)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+	cert, _ := issue(t, template, ca)
+	return cert
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+func TestAuthenticate(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	other := newTestCA(t, "Other CA")
+
+	intermediate, intermediateKey := issue(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Intermediate CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, ca)
+	intermediateCA := &testCA{cert: intermediate, key: intermediateKey}
+
+	serverOnly, _ := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	expired, _ := issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "okta"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		NotBefore:   time.Now().Add(-2 * time.Hour),
+		NotAfter:    time.Now().Add(-time.Hour),
+	}, ca)
+
+	tests := []struct {
+		name         string
+		allowed      []string
+		chain        []*x509.Certificate
+		wantIdentity string
+		wantErr      string
+	}{
+		{
+			name:    "no certificate",
+			wantErr: "client certificate required",
+		},
+		{
+			name:         "any certificate from the CA",
+			chain:        []*x509.Certificate{ca.client(t, "okta")},
+			wantIdentity: "okta",
+		},
+		{
+			name:         "allowed common name",
+			allowed:      []string{"azure", "okta"},
+			chain:        []*x509.Certificate{ca.client(t, "okta")},
+			wantIdentity: "okta",
+		},
+		{
+			name:         "allowed DNS SAN",
+			allowed:      []string{"scim.idp.example.com"},
+			chain:        []*x509.Certificate{ca.client(t, "okta", "scim.idp.example.com")},
+			wantIdentity: "scim.idp.example.com",
+		},
+		{
+			name:         "allowed URI SAN",
+			allowed:      []string{"spiffe://example.com/idp"},
+			chain:        []*x509.Certificate{ca.client(t, "", "spiffe://example.com/idp")},
+			wantIdentity: "spiffe://example.com/idp",
+		},
+		{
+			name:    "identity not allowed",
+			allowed: []string{"azure"},
+			chain:   []*x509.Certificate{ca.client(t, "okta", "okta.example.com")},
+			wantErr: "identity not allowed",
+		},
+		{
+			name:    "untrusted CA",
+			chain:   []*x509.Certificate{other.client(t, "okta")},
+			wantErr: "invalid client certificate",
+		},
+		{
+			name:         "chain through intermediate",
+			chain:        []*x509.Certificate{intermediateCA.client(t, "okta"), intermediate},
+			wantIdentity: "okta",
+		},
+		{
+			name:    "missing intermediate",
+			chain:   []*x509.Certificate{intermediateCA.client(t, "okta")},
+			wantErr: "invalid client certificate",
+		},
+		{
+			name:    "server certificate",
+			chain:   []*x509.Certificate{serverOnly},
+			wantErr: "invalid client certificate",
+		},
+		{
+			name:    "expired certificate",
+			chain:   []*x509.Certificate{expired},
+			wantErr: "invalid client certificate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(Config{CAs: ca.pool(), AllowedIdentities: tt.allowed})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			r := httptest.NewRequest("GET", "https://gateway/okta/Users", nil)
+			r.TLS = &tls.ConnectionState{PeerCertificates: tt.chain}
+
+			identity, err := a.Identity(r)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Identity() error = %v, want %q", err, tt.wantErr)
+				}
+				if a.Authenticate(r) == nil {
+					t.Error("Authenticate() should fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Identity() error = %v", err)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("Identity() = %q, want %q", identity, tt.wantIdentity)
+			}
+			if err := a.Authenticate(r); err != nil {
+				t.Errorf("Authenticate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+
+	bundle := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Config{CAFile: bundle})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	r := httptest.NewRequest("GET", "https://gateway/okta/Users", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.client(t, "okta")}}
+	if err := a.Authenticate(r); err != nil {
+		t.Errorf("Authenticate() with CA from file error = %v", err)
+	}
+
+	for name, cfg := range map[string]Config{
+		"no CA":        {},
+		"missing file": {CAFile: filepath.Join(dir, "missing.pem")},
+		"no PEM":       {CAFile: empty},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New() with %s should fail", name)
+		}
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "okta"},
+		DNSNames:       []string{"okta.example.com"},
+		EmailAddresses: []string{"scim@example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/idp"}},
+	}
+
+	got := Identities(cert)
+	want := []string{"okta", "okta.example.com", "scim@example.com", "spiffe://example.com/idp"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Identities() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,309 @@
+// Package oauth2 implements an authenticator for OAuth2 access tokens issued by an
+// authorization server, typically through the client credentials grant used by
+// identity providers that push to SCIM endpoints.
+//
+// Tokens are validated either locally as signed JWTs against the server's JWKS
+// endpoint, or remotely with token introspection (RFC 7662). In both modes the
+// issuer, audience and required scopes are checked when configured.
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/internal/jose"
+	"github.com/marcelom97/scimgateway/version"
+)
+
+const (
+	// DefaultCacheTTL is how long JWKS keys and introspection results are cached
+	DefaultCacheTTL = 5 * time.Minute
+
+	// DefaultClockSkew is the leeway applied to exp and nbf checks
+	DefaultClockSkew = time.Minute
+
+	// maxCachedTokens bounds the introspection cache before expired entries are swept
+	maxCachedTokens = 10000
+)
+
+// Config configures an OAuth2 authenticator. Exactly one of JWKSURL and
+// IntrospectionURL must be set.
+type Config struct {
+	// JWKSURL validates tokens as signed JWTs with keys from this endpoint
+	JWKSURL string
+
+	// IntrospectionURL validates tokens with RFC 7662 token introspection,
+	// authenticating to the endpoint with ClientID and ClientSecret
+	IntrospectionURL string
+	ClientID         string
+	ClientSecret     string
+
+	// Issuer, Audience and RequiredScopes are checked when set
+	Issuer         string
+	Audience       string
+	RequiredScopes []string
+
+	// CacheTTL is how long keys and introspection results are cached.
+	// Zero uses DefaultCacheTTL.
+	CacheTTL time.Duration
+
+	// HTTPClient is used to call the authorization server.
+	// Nil uses a client with a 10 second timeout.
+	HTTPClient *http.Client
+
+	// ClockSkew is the leeway applied to the exp and nbf claims, allowing for
+	// clocks of the gateway and the authorization server that differ.
+	// Zero uses DefaultClockSkew.
+	ClockSkew time.Duration
+
+	// Clock is the time tokens are validated at. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// Authenticator validates OAuth2 bearer access tokens
+type Authenticator struct {
+	cfg    Config
+	client *http.Client
+	keys   *jose.RemoteKeySet
+
+	cache   map[[sha256.Size]byte]cachedResult
+	cacheMu sync.Mutex
+}
+
+// cachedResult is an introspection result for a token
+type cachedResult struct {
+	claims  jose.Claims
+	err     error
+	expires time.Time
+}
+
+// New creates an OAuth2 authenticator
+func New(cfg Config) (*Authenticator, error) {
+	if (cfg.JWKSURL == "") == (cfg.IntrospectionURL == "") {
+		return nil, errors.New("exactly one of JWKS URL and introspection URL must be set")
+	}
+	if cfg.IntrospectionURL != "" && (cfg.ClientID == "" || cfg.ClientSecret == "") {
+		return nil, errors.New("client ID and secret are required for token introspection")
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = DefaultClockSkew
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.System
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	a := &Authenticator{
+		cfg:    cfg,
+		client: client,
+		cache:  make(map[[sha256.Size]byte]cachedResult),
+	}
+	if cfg.JWKSURL != "" {
+		a.keys = jose.NewRemoteKeySet(cfg.JWKSURL, client, cfg.CacheTTL)
+	}
+	return a, nil
+}
+
+// Authenticate validates the bearer token of the request
+func (a *Authenticator) Authenticate(r *http.Request) error {
+	_, err := a.AuthenticatePrincipal(r)
+	return err
+}
+
+// AuthenticatePrincipal implements auth.PrincipalAuthenticator: it validates
+// the bearer token of the request and returns its "sub" claim, granted
+// scopes and claims. Introspection responses are used as the claims.
+func (a *Authenticator) AuthenticatePrincipal(r *http.Request) (auth.AuthResult, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return auth.AuthResult{}, fmt.Errorf("missing authorization header")
+	}
+
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return auth.AuthResult{}, fmt.Errorf("invalid authorization type")
+	}
+
+	token := strings.TrimSpace(authHeader[7:])
+	if token == "" {
+		return auth.AuthResult{}, fmt.Errorf("missing token")
+	}
+
+	var claims jose.Claims
+	var err error
+	if a.keys != nil {
+		claims, err = a.verifyJWT(r.Context(), token)
+	} else {
+		claims, err = a.introspect(r.Context(), token)
+	}
+	if err != nil {
+		return auth.AuthResult{}, err
+	}
+	return auth.AuthResult{
+		Subject: claims.String("sub"),
+		Scopes:  tokenScopes(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// verifyJWT validates a signed JWT access token and returns its claims
+func (a *Authenticator) verifyJWT(ctx context.Context, token string) (jose.Claims, error) {
+	claims, err := jose.Verify(ctx, token, a.keys, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	exp := claims.Time("exp")
+	if exp.IsZero() {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims validates the time, issuer, audience and scope claims
+func (a *Authenticator) checkClaims(claims jose.Claims) error {
+	now := a.cfg.Clock.Now()
+	if exp := claims.Time("exp"); !exp.IsZero() && now.After(exp.Add(a.cfg.ClockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf := claims.Time("nbf"); !nbf.IsZero() && now.Add(a.cfg.ClockSkew).Before(nbf) {
+		return fmt.Errorf("token not yet valid")
+	}
+
+	if a.cfg.Issuer != "" && claims.String("iss") != a.cfg.Issuer {
+		return fmt.Errorf("invalid token issuer")
+	}
+	if a.cfg.Audience != "" && !slices.Contains(claims.Strings("aud"), a.cfg.Audience) {
+		return fmt.Errorf("invalid token audience")
+	}
+
+	if len(a.cfg.RequiredScopes) > 0 {
+		scopes := tokenScopes(claims)
+		for _, scope := range a.cfg.RequiredScopes {
+			if !slices.Contains(scopes, scope) {
+				return fmt.Errorf("token is missing required scope %q", scope)
+			}
+		}
+	}
+	return nil
+}
+
+// tokenScopes returns the granted scopes from the space-delimited "scope" claim
+// (RFC 8693) or the "scp" claim used by some providers
+func tokenScopes(claims jose.Claims) []string {
+	if scope := claims.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	if scp := claims.String("scp"); scp != "" {
+		return strings.Fields(scp)
+	}
+	return claims.Strings("scp")
+}
+
+// introspect validates a token with the introspection endpoint, caching
+// results, and returns the claims of the response
+func (a *Authenticator) introspect(ctx context.Context, token string) (jose.Claims, error) {
+	key := sha256.Sum256([]byte(token))
+
+	a.cacheMu.Lock()
+	cached, ok := a.cache[key]
+	a.cacheMu.Unlock()
+	if ok && a.cfg.Clock.Now().Before(cached.expires) {
+		return cached.claims, cached.err
+	}
+
+	claims, err := a.callIntrospection(ctx, token)
+	if err != nil {
+		// Endpoint failures are not cached, so the next request retries
+		return nil, err
+	}
+
+	var result error
+	if active, _ := claims["active"].(bool); !active {
+		result = fmt.Errorf("token is not active")
+	} else {
+		result = a.checkClaims(claims)
+	}
+
+	expires := a.cfg.Clock.Now().Add(a.cfg.CacheTTL)
+	if exp := claims.Time("exp"); result == nil && !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	if result != nil {
+		claims = nil
+	}
+	a.store(key, cachedResult{claims: claims, err: result, expires: expires})
+
+	return claims, result
+}
+
+// callIntrospection posts the token to the introspection endpoint
+func (a *Authenticator) callIntrospection(ctx context.Context, token string) (jose.Claims, error) {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create introspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token introspection failed: %w", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token introspection failed: unexpected status %d", resp.StatusCode)
+	}
+
+	var claims jose.Claims
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("invalid introspection response: %w", err)
+	}
+	return claims, nil
+}
+
+// store caches an introspection result, sweeping expired entries when the cache is full
+func (a *Authenticator) store(key [sha256.Size]byte, result cachedResult) {
+	a.cacheMu.Lock()
+	defer a.cacheMu.Unlock()
+
+	if len(a.cache) >= maxCachedTokens {
+		now := a.cfg.Clock.Now()
+		for k, v := range a.cache {
+			if now.After(v.expires) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= maxCachedTokens {
+			clear(a.cache)
+		}
+	}
+	a.cache[key] = result
+}
@@ -0,0 +1,340 @@
+package oauth2
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+)
+
+// testIssuer serves a JWKS endpoint and signs tokens with its key
+type testIssuer struct {
+	key     *rsa.PrivateKey
+	server  *httptest.Server
+	fetches atomic.Int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{ // nolint:errcheck
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+			}},
+		})
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "key-1"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func authenticate(a *Authenticator, header string) error {
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	return a.Authenticate(req)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "JWKS", cfg: Config{JWKSURL: "https://idp.example.com/jwks"}},
+		{name: "introspection", cfg: Config{IntrospectionURL: "https://idp.example.com/introspect", ClientID: "id", ClientSecret: "secret"}},
+		{name: "no endpoint", cfg: Config{}, wantErr: true},
+		{name: "both endpoints", cfg: Config{JWKSURL: "https://a", IntrospectionURL: "https://b", ClientID: "id", ClientSecret: "secret"}, wantErr: true},
+		{name: "introspection without credentials", cfg: Config{IntrospectionURL: "https://idp.example.com/introspect"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorJWKS(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a, err := New(Config{
+		JWKSURL:        issuer.server.URL,
+		Issuer:         "https://idp.example.com",
+		Audience:       "scim-gateway",
+		RequiredScopes: []string{"scim.read", "scim.write"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   "https://idp.example.com",
+			"aud":   []string{"scim-gateway", "other"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"scope": "scim.read scim.write",
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		claims := valid()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr string
+	}{
+		{name: "valid token", header: "Bearer " + issuer.sign(t, valid())},
+		{name: "no scope claim", header: "Bearer " + issuer.sign(t, with("scope", nil)), wantErr: "missing required scope"},
+		{name: "expired", header: "Bearer " + issuer.sign(t, with("exp", time.Now().Add(-time.Hour).Unix())), wantErr: "token expired"},
+		{name: "within clock skew", header: "Bearer " + issuer.sign(t, with("exp", time.Now().Add(-10*time.Second).Unix()))},
+		{name: "not yet valid", header: "Bearer " + issuer.sign(t, with("nbf", time.Now().Add(time.Hour).Unix())), wantErr: "not yet valid"},
+		{name: "no expiry", header: "Bearer " + issuer.sign(t, with("exp", nil)), wantErr: "no expiry"},
+		{name: "wrong issuer", header: "Bearer " + issuer.sign(t, with("iss", "https://evil.example.com")), wantErr: "invalid token issuer"},
+		{name: "wrong audience", header: "Bearer " + issuer.sign(t, with("aud", "other")), wantErr: "invalid token audience"},
+		{name: "missing scope", header: "Bearer " + issuer.sign(t, with("scope", "scim.read")), wantErr: `missing required scope "scim.write"`},
+		{name: "tampered", header: "Bearer " + issuer.sign(t, valid()) + "x", wantErr: "signature"},
+		{name: "missing header", header: "", wantErr: "missing authorization header"},
+		{name: "basic auth", header: "Basic dXNlcjpwYXNz", wantErr: "invalid authorization type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authenticate(a, tt.header)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Authenticate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Authenticate() error = %v, want to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("scp claim", func(t *testing.T) {
+		claims := with("scope", nil)
+		claims["scp"] = []string{"scim.read", "scim.write"}
+		if err := authenticate(a, "Bearer "+issuer.sign(t, claims)); err != nil {
+			t.Errorf("Authenticate() error = %v", err)
+		}
+	})
+
+	if n := issuer.fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 (keys should be cached)", n)
+	}
+}
+
+func TestAuthenticatePrincipal(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a, err := New(Config{JWKSURL: issuer.server.URL})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer "+issuer.sign(t, map[string]any{
+		"sub":    "okta-client",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"scope":  "scim.read scim.write",
+		"tenant": "acme",
+	}))
+	result, err := a.AuthenticatePrincipal(req)
+	if err != nil {
+		t.Fatalf("AuthenticatePrincipal() error = %v", err)
+	}
+	if result.Subject != "okta-client" || !reflect.DeepEqual(result.Scopes, []string{"scim.read", "scim.write"}) || result.Claims["tenant"] != "acme" {
+		t.Errorf("AuthenticatePrincipal() = %+v", result)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+issuer.sign(t, map[string]any{"sub": "okta-client"}))
+	if result, err := a.AuthenticatePrincipal(req); err == nil || result.Subject != "" {
+		t.Errorf("AuthenticatePrincipal() of an invalid token = %+v, %v; want an error", result, err)
+	}
+}
+
+func TestAuthenticatorClock(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := clock.NewFake(now)
+	a, err := New(Config{JWKSURL: issuer.server.URL, ClockSkew: 5 * time.Minute, Clock: c})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	token := "Bearer " + issuer.sign(t, map[string]any{
+		"exp": now.Add(time.Hour).Unix(),
+		"nbf": now.Add(3 * time.Minute).Unix(),
+	})
+
+	// nbf is within the configured skew
+	if err := authenticate(a, token); err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+
+	c.Advance(time.Hour + 4*time.Minute)
+	if err := authenticate(a, token); err != nil {
+		t.Errorf("Authenticate() within the skew after exp error = %v", err)
+	}
+
+	c.Advance(2 * time.Minute)
+	if err := authenticate(a, token); err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("Authenticate() past the skew error = %v, want token expired", err)
+	}
+}
+
+func TestAuthenticatorIntrospection(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "gateway" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("token_type_hint") != "access_token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		response := map[string]any{"active": false}
+		switch r.PostForm.Get("token") {
+		case "good":
+			response = map[string]any{
+				"active": true,
+				"sub":    "okta",
+				"iss":    "https://idp.example.com",
+				"aud":    "scim-gateway",
+				"scope":  "scim.write",
+				"exp":    time.Now().Add(time.Hour).Unix(),
+			}
+		case "wrong-scope":
+			response = map[string]any{"active": true, "iss": "https://idp.example.com", "aud": "scim-gateway", "scope": "openid"}
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(response) // nolint:errcheck
+	}))
+	defer server.Close()
+
+	a, err := New(Config{
+		IntrospectionURL: server.URL,
+		ClientID:         "gateway",
+		ClientSecret:     "s3cret",
+		Issuer:           "https://idp.example.com",
+		Audience:         "scim-gateway",
+		RequiredScopes:   []string{"scim.write"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := authenticate(a, "Bearer good"); err != nil {
+		t.Errorf("active token: Authenticate() error = %v", err)
+	}
+	if err := authenticate(a, "Bearer revoked"); err == nil || !strings.Contains(err.Error(), "not active") {
+		t.Errorf("inactive token: Authenticate() error = %v, want not active", err)
+	}
+	if err := authenticate(a, "Bearer wrong-scope"); err == nil || !strings.Contains(err.Error(), "missing required scope") {
+		t.Errorf("wrong scope: Authenticate() error = %v, want missing required scope", err)
+	}
+
+	// Results are cached, so repeating the requests does not call the endpoint
+	before := calls.Load()
+	for range 3 {
+		authenticate(a, "Bearer good")    // nolint:errcheck
+		authenticate(a, "Bearer revoked") // nolint:errcheck
+	}
+	if n := calls.Load(); n != before {
+		t.Errorf("introspection endpoint called %d more times, want cached results", n-before)
+	}
+
+	// Cached results describe the client too
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	req.Header.Set("Authorization", "Bearer good")
+	if result, err := a.AuthenticatePrincipal(req); err != nil || result.Subject != "okta" || !reflect.DeepEqual(result.Scopes, []string{"scim.write"}) {
+		t.Errorf("AuthenticatePrincipal() = %+v, %v; want okta with scim.write", result, err)
+	}
+
+	// Endpoint failures are not cached
+	for range 2 {
+		if err := authenticate(a, "Bearer error"); err == nil || !strings.Contains(err.Error(), "introspection failed") {
+			t.Errorf("endpoint failure: Authenticate() error = %v", err)
+		}
+	}
+	if n := calls.Load(); n != before+2 {
+		t.Errorf("introspection endpoint called %d times for failing token, want 2", n-before)
+	}
+}
+
+func TestIntrospectionCacheExpiry(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"active": true}) // nolint:errcheck
+	}))
+	defer server.Close()
+
+	a, err := New(Config{IntrospectionURL: server.URL, ClientID: "id", ClientSecret: "secret", CacheTTL: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	authenticate(a, "Bearer token") // nolint:errcheck
+	authenticate(a, "Bearer token") // nolint:errcheck
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("introspection calls = %d, want 1", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	authenticate(a, "Bearer token") // nolint:errcheck
+	if n := calls.Load(); n != 2 {
+		t.Errorf("introspection calls after TTL = %d, want 2", n)
+	}
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretProvider supplies a secret, such as a bearer token or a Basic auth
+// password, at request time, so it needn't be embedded in the configuration
+// and can be rotated without a restart. Providers fetching secrets remotely,
+// e.g. from Vault or a KMS, should cache them: Secret is called for every
+// request authenticated against the secret.
+type SecretProvider interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+// SecretFunc adapts a function to a SecretProvider
+type SecretFunc func(ctx context.Context) (string, error)
+
+// Secret calls f
+func (f SecretFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// EnvSecret returns a SecretProvider reading the secret from the environment
+// variable name on every request
+func EnvSecret(name string) SecretProvider {
+	return SecretFunc(func(context.Context) (string, error) {
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return secret, nil
+	})
+}
+
+// FileSecret returns a SecretProvider reading the secret from the file at
+// path, without surrounding whitespace. The file is read again when its
+// modification time changes, so secrets mounted from e.g. Kubernetes
+// secrets are rotated in place.
+func FileSecret(path string) SecretProvider {
+	return &fileSecret{path: path}
+}
+
+// fileSecret is the SecretProvider of FileSecret
+type fileSecret struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	secret  string
+}
+
+// Secret returns the secret of the file, reading it if it changed
+func (f *fileSecret) Secret(context.Context) (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.secret != "" && info.ModTime().Equal(f.modTime) {
+		return f.secret, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", err
+	}
+	f.secret, f.modTime = strings.TrimSpace(string(data)), info.ModTime()
+	return f.secret, nil
+}
+
+// secretsEqual compares a and b in constant time. Their SHA-256 digests are
+// compared, so the time taken does not reveal their lengths either.
+func secretsEqual(a, b string) bool {
+	da, db := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(da[:], db[:]) == 1
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSecretProviders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SCIM_TEST_PASSWORD", "env-secret")
+
+	vaultErr := errors.New("vault sealed")
+	bearer := &BearerAuthenticator{
+		TokenProvider: FileSecret(path),
+		Tokens: []Credential{
+			{Label: "vault", SecretProvider: SecretFunc(func(context.Context) (string, error) { return "vault-token", nil })},
+			{Label: "sealed", SecretProvider: SecretFunc(func(context.Context) (string, error) { return "", vaultErr })},
+		},
+	}
+	basic := &BasicAuthenticator{Username: "okta", PasswordProvider: EnvSecret("SCIM_TEST_PASSWORD")}
+	unset := &BasicAuthenticator{Username: "okta", PasswordProvider: EnvSecret("SCIM_TEST_UNSET")}
+	basicHeader := func(username, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		header        string
+		wantErr       bool
+	}{
+		{name: "file token", authenticator: bearer, header: "Bearer file-token"},
+		{name: "provider token", authenticator: bearer, header: "Bearer vault-token"},
+		{name: "wrong token", authenticator: bearer, header: "Bearer other", wantErr: true},
+		{name: "env password", authenticator: basic, header: basicHeader("okta", "env-secret")},
+		{name: "wrong password", authenticator: basic, header: basicHeader("okta", "env"), wantErr: true},
+		{name: "empty secret never matches", authenticator: unset, header: basicHeader("okta", ""), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", tt.header)
+			if err := tt.authenticator.Authenticate(req); (err != nil) != tt.wantErr {
+				t.Errorf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("fetch error reported when nothing matches", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer other")
+		if err := bearer.Authenticate(req); !errors.Is(err, vaultErr) {
+			t.Errorf("Authenticate() error = %v, want %v", err, vaultErr)
+		}
+	})
+
+	t.Run("rotated file", func(t *testing.T) {
+		// Ensure the modification time changes on filesystems with a coarse one
+		if err := os.WriteFile(path, []byte("rotated-token"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer rotated-token")
+		if err := bearer.Authenticate(req); err != nil {
+			t.Errorf("Authenticate() with the rotated token error = %v", err)
+		}
+	})
+}
+
+func TestSecretsEqual(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"secret", "secret", true},
+		{"secret", "secreT", false},
+		{"secret", "secret-longer", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		if got := secretsEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("secretsEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package clock provides the time source of the gateway.
+//
+// Code that reads the current time takes a Clock, or reads it from the request
+// context with Now, so tests can use a Fake and deployments can replace the
+// system clock in one place:
+//
+//	gw.SetClock(clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+//
+// Plugins stamp resource metadata with the clock of the request:
+//
+//	now := clock.Now(ctx)
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the Clock of the operating system
+var System Clock = systemClock{}
+
+// systemClock reads time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a Clock that only moves when told to. It is safe for concurrent use.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake returns a Fake clock set to now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the time the clock is set to
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set sets the clock to now
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// contextKey is the context key of the request's clock
+type contextKey struct{}
+
+// WithContext returns a copy of ctx carrying c
+func WithContext(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the clock carried by ctx, or System if there is none
+func FromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(contextKey{}).(Clock); ok && c != nil {
+		return c
+	}
+	return System
+}
+
+// Now returns the current time of the clock carried by ctx
+func Now(ctx context.Context) time.Time {
+	return FromContext(ctx).Now()
+}
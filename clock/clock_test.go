@@ -0,0 +1,41 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v, want %v", got, start)
+	}
+	c.Advance(time.Hour)
+	if got := c.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now() after Advance = %v, want %v", got, start.Add(time.Hour))
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set = %v, want %v", got, start)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != System {
+		t.Error("FromContext() without a clock should return System")
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := WithContext(context.Background(), NewFake(start))
+	if got := Now(ctx); !got.Equal(start) {
+		t.Errorf("Now() = %v, want the clock of the context", got)
+	}
+
+	before := time.Now()
+	if got := Now(context.Background()); got.Before(before) {
+		t.Errorf("Now() without a clock = %v, want the system time", got)
+	}
+}
@@ -1,20 +1,41 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
 	"fmt"
+	"maps"
+	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/auth/jwt"
+	"github.com/marcelom97/scimgateway/cron"
 )
 
 // ValidationError represents a configuration validation error
 type ValidationError struct {
 	Field   string
 	Message string
+
+	// File and Line locate the error in the source file when the
+	// configuration was loaded with LoadFile. Line is 0 otherwise.
+	File string
+	Line int
 }
 
 func (e *ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: config validation error [%s]: %s", e.File, e.Line, e.Field, e.Message)
+	}
+	if e.File != "" {
+		return fmt.Sprintf("%s: config validation error [%s]: %s", e.File, e.Field, e.Message)
+	}
 	return fmt.Sprintf("config validation error [%s]: %s", e.Field, e.Message)
 }
 
@@ -39,14 +60,29 @@ func (e ValidationErrors) Error() string {
 
 // Config represents the gateway configuration
 type Config struct {
-	Gateway GatewayConfig
-	Plugins []PluginConfig
+	// Version is the layout version of configuration files, see
+	// CurrentVersion. Zero is accepted for configurations built in code.
+	Version int `yaml:"version"`
+
+	Gateway GatewayConfig  `yaml:"gateway"`
+	Plugins []PluginConfig `yaml:"plugins"`
+
+	// Deprecations are the keys of the file the configuration was loaded
+	// from that LoadFile migrated from an older layout
+	Deprecations []Deprecation `yaml:"-"`
 }
 
 // Validate validates the entire configuration
 func (c *Config) Validate() error {
 	var errors ValidationErrors
 
+	if c.Version < 0 || c.Version > CurrentVersion {
+		errors = append(errors, ValidationError{
+			Field:   "version",
+			Message: fmt.Sprintf("version %d is not supported: this release reads versions up to %d", c.Version, CurrentVersion),
+		})
+	}
+
 	// Validate gateway config
 	if err := c.Gateway.Validate(); err != nil {
 		if verrs, ok := err.(ValidationErrors); ok {
@@ -88,6 +124,10 @@ func (c *Config) Validate() error {
 		}
 		pluginNames[plugin.Name] = true
 
+		if plugin.BaseURL != "" {
+			errors = append(errors, validateBaseURL(fmt.Sprintf("plugins[%d].baseURL", i), plugin.BaseURL)...)
+		}
+
 		// Validate plugin auth if present
 		if plugin.Auth != nil {
 			if err := plugin.Auth.Validate(fmt.Sprintf("plugins[%d].auth", i)); err != nil {
@@ -98,6 +138,201 @@ func (c *Config) Validate() error {
 				}
 			}
 		}
+
+		for j, attr := range plugin.DefaultExcludedAttributes {
+			if strings.TrimSpace(attr) == "" {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("plugins[%d].defaultExcludedAttributes[%d]", i, j),
+					Message: "attribute name cannot be empty",
+				})
+			}
+		}
+
+		switch plugin.SortCollation {
+		case "", SortCollationCaseInsensitive:
+		default:
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("plugins[%d].sortCollation", i),
+				Message: fmt.Sprintf("sortCollation must be empty or %s", SortCollationCaseInsensitive),
+			})
+		}
+
+		errors = append(errors, validateOperations(fmt.Sprintf("plugins[%d].operations", i), plugin.Operations)...)
+		errors = append(errors, validateBaseEntities(fmt.Sprintf("plugins[%d].baseEntities", i), plugin.BaseEntities)...)
+
+		if plugin.Authorization != nil {
+			field := fmt.Sprintf("plugins[%d].authorization", i)
+			if err := plugin.Authorization.Validate(field); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+			if !pluginAuthenticates(plugin) {
+				errors = append(errors, ValidationError{
+					Field:   field,
+					Message: "authorization requires auth on the plugin or on all of its base entities",
+				})
+			}
+		}
+
+		if plugin.Bulk != nil {
+			if err := plugin.Bulk.Validate(fmt.Sprintf("plugins[%d].bulk", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		// Validate connection pool settings if present
+		if plugin.Pool != nil {
+			if err := plugin.Pool.Validate(fmt.Sprintf("plugins[%d].pool", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Failover != nil {
+			if err := plugin.Failover.Validate(fmt.Sprintf("plugins[%d].failover", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Warmup != nil {
+			if err := plugin.Warmup.Validate(fmt.Sprintf("plugins[%d].warmup", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Keepalive != nil {
+			if err := plugin.Keepalive.Validate(fmt.Sprintf("plugins[%d].keepalive", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Quota != nil {
+			if err := plugin.Quota.Validate(fmt.Sprintf("plugins[%d].quota", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Cache != nil {
+			if err := plugin.Cache.Validate(fmt.Sprintf("plugins[%d].cache", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.ClientCache != nil {
+			if err := plugin.ClientCache.Validate(fmt.Sprintf("plugins[%d].clientCache", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Audit != nil {
+			if err := plugin.Audit.Validate(fmt.Sprintf("plugins[%d].audit", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Recording != nil {
+			if err := plugin.Recording.Validate(fmt.Sprintf("plugins[%d].recording", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.CircuitBreaker != nil {
+			if err := plugin.CircuitBreaker.Validate(fmt.Sprintf("plugins[%d].circuitBreaker", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.ErrorBudget != nil {
+			field := fmt.Sprintf("plugins[%d].errorBudget", i)
+			if err := plugin.ErrorBudget.Validate(field); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+			if plugin.ErrorBudget.TripBreaker && plugin.CircuitBreaker == nil {
+				errors = append(errors, ValidationError{
+					Field:   field + ".tripBreaker",
+					Message: "tripBreaker requires circuitBreaker on the plugin",
+				})
+			}
+		}
+
+		if plugin.WriteWindow != nil {
+			if err := plugin.WriteWindow.Validate(fmt.Sprintf("plugins[%d].writeWindow", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.AsyncWrites != nil {
+			if err := plugin.AsyncWrites.Validate(fmt.Sprintf("plugins[%d].asyncWrites", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Approval != nil {
+			if err := plugin.Approval.Validate(fmt.Sprintf("plugins[%d].approval", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.HTTPClient != nil {
+			if err := plugin.HTTPClient.Validate(fmt.Sprintf("plugins[%d].httpClient", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		if plugin.Normalize != nil {
+			if err := plugin.Normalize.Validate(fmt.Sprintf("plugins[%d].normalize", i)); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+
+		// Root endpoints would hide a plugin named like one of them
+		if c.Gateway.RootEndpoints != nil && slices.Contains(RootEndpoints, plugin.Name) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("plugins[%d].name", i),
+				Message: fmt.Sprintf("plugin name %s is a root endpoint of gateway.rootEndpoints", plugin.Name),
+			})
+		}
+	}
+
+	if root := c.Gateway.RootEndpoints; root != nil && root.Plugin != "" && !pluginNames[root.Plugin] {
+		errors = append(errors, ValidationError{
+			Field:   "gateway.rootEndpoints.plugin",
+			Message: fmt.Sprintf("plugin '%s' is not configured", root.Plugin),
+		})
 	}
 
 	if len(errors) > 0 {
@@ -108,11 +343,68 @@ func (c *Config) Validate() error {
 
 // GatewayConfig represents gateway-specific configuration
 type GatewayConfig struct {
-	BaseURL string
-	Port    int
-	TLS     *TLS
+	BaseURL string `yaml:"baseURL"`
+	Port    int    `yaml:"port"`
+	TLS     *TLS   `yaml:"tls"`
+
+	// ClockSkew is the leeway applied to token expiry and not-before checks of
+	// every plugin, e.g. 2m. Zero uses the authenticator's default of a minute.
+	ClockSkew time.Duration `yaml:"clockSkew"`
+
+	// PropagateHeaders are request headers passed on to plugins in addition
+	// to the W3C traceparent and tracestate, e.g. X-B3-TraceId. HTTP-based
+	// plugins attach them to their backend requests.
+	PropagateHeaders []string `yaml:"propagateHeaders"`
+
+	// HTTPClient configures the outbound HTTP clients of the gateway, such as
+	// those calling OAuth2 authorization servers, for plugins without their
+	// own httpClient settings. Nil uses the defaults.
+	HTTPClient *HTTPClientConfig `yaml:"httpClient"`
+
+	// Normalize canonicalizes the attribute values of users and groups
+	// before they are validated and passed to plugins without their own
+	// normalize settings. Nil passes them as sent.
+	Normalize *NormalizeConfig `yaml:"normalize"`
+
+	// FilterTrace exposes how the filter of each list request was applied:
+	// passed to the plugin, translated to SQL, partly dropped or evaluated in
+	// memory. "header" returns it in the X-Filter-Trace response header,
+	// "log" adds it to a debug log entry. Empty disables tracing.
+	FilterTrace string `yaml:"filterTrace"`
+
+	// RootEndpoints serves /Users, /Groups and /ServiceProviderConfig
+	// without a plugin path prefix. Nil serves them only under /{plugin}.
+	RootEndpoints *RootEndpointsConfig `yaml:"rootEndpoints"`
+
+	// PaginationLinks adds a Link header with the URLs of the first,
+	// previous and next page to GET list responses (RFC 8288), for clients
+	// following them to page through lists
+	PaginationLinks bool `yaml:"paginationLinks"`
+
+	// CanonicalJSON writes SCIM responses with the members of objects sorted
+	// by name, so identical resources are written identically by every
+	// replica and release, e.g. for tools diffing responses as text.
+	// Encoders set with Gateway.SetEncoder take precedence.
+	CanonicalJSON bool `yaml:"canonicalJSON"`
+
+	// StrictContentType rejects request bodies that are not
+	// application/scim+json or application/json with 415 and requests not
+	// accepting either with 406, and writes SCIM responses with charset
+	// utf-8, as conformance suites expect. See
+	// scim.StrictContentTypeMiddleware.
+	StrictContentType bool `yaml:"strictContentType"`
+
+	// Jobs overrides the settings of the gateway's maintenance jobs by job
+	// name, e.g. "hr/purge-deleted" or "hr/warm". See package scheduler.
+	Jobs map[string]JobConfig `yaml:"jobs"`
 }
 
+// Filter trace modes
+const (
+	FilterTraceHeader = "header" // decisions are returned in X-Filter-Trace
+	FilterTraceLog    = "log"    // decisions are logged at debug level
+)
+
 // Validate validates the gateway configuration
 func (g *GatewayConfig) Validate() error {
 	var errors ValidationErrors
@@ -124,29 +416,7 @@ func (g *GatewayConfig) Validate() error {
 			Message: "baseURL cannot be empty",
 		})
 	} else {
-		// Parse and validate URL format
-		parsedURL, err := url.Parse(g.BaseURL)
-		if err != nil {
-			errors = append(errors, ValidationError{
-				Field:   "gateway.baseURL",
-				Message: fmt.Sprintf("invalid URL format: %v", err),
-			})
-		} else {
-			// Check scheme
-			if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-				errors = append(errors, ValidationError{
-					Field:   "gateway.baseURL",
-					Message: fmt.Sprintf("invalid URL scheme '%s': must be http or https", parsedURL.Scheme),
-				})
-			}
-			// Check host
-			if parsedURL.Host == "" {
-				errors = append(errors, ValidationError{
-					Field:   "gateway.baseURL",
-					Message: "URL must include a host (e.g., http://localhost:8080)",
-				})
-			}
-		}
+		errors = append(errors, validateBaseURL("gateway.baseURL", g.BaseURL)...)
 	}
 
 	// Validate Port (0 means unspecified - valid for embedded mode using Handler())
@@ -157,6 +427,63 @@ func (g *GatewayConfig) Validate() error {
 		})
 	}
 
+	if g.ClockSkew < 0 {
+		errors = append(errors, ValidationError{
+			Field:   "gateway.clockSkew",
+			Message: fmt.Sprintf("clockSkew %s cannot be negative", g.ClockSkew),
+		})
+	}
+
+	for i, name := range g.PropagateHeaders {
+		if !isHeaderName(name) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("gateway.propagateHeaders[%d]", i),
+				Message: fmt.Sprintf("'%s' is not a valid header name", name),
+			})
+		}
+	}
+
+	switch g.FilterTrace {
+	case "", FilterTraceHeader, FilterTraceLog:
+	default:
+		errors = append(errors, ValidationError{
+			Field:   "gateway.filterTrace",
+			Message: fmt.Sprintf("filterTrace %q must be %s or %s", g.FilterTrace, FilterTraceHeader, FilterTraceLog),
+		})
+	}
+
+	if g.RootEndpoints != nil {
+		if err := g.RootEndpoints.Validate("gateway.rootEndpoints"); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	}
+
+	for name, job := range g.Jobs {
+		if err := job.Validate("gateway.jobs." + name); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	}
+
+	if g.HTTPClient != nil {
+		if err := g.HTTPClient.Validate("gateway.httpClient"); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	}
+
+	if g.Normalize != nil {
+		if err := g.Normalize.Validate("gateway.normalize"); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	}
+
 	// Validate TLS configuration
 	if g.TLS != nil && g.TLS.Enabled {
 		if g.TLS.CertFile == "" {
@@ -179,88 +506,1400 @@ func (g *GatewayConfig) Validate() error {
 	return nil
 }
 
-// TLS represents TLS configuration
-type TLS struct {
-	Enabled  bool
-	CertFile string
-	KeyFile  string
-}
-
-// PluginConfig represents plugin-specific configuration
-type PluginConfig struct {
-	Name   string
-	Auth   *AuthConfig
-	Config map[string]any
-}
-
-// AuthConfig represents authentication configuration with type-safe config
-type AuthConfig struct {
-	Type   string // basic, bearer, custom, none
-	Basic  *BasicAuth
-	Bearer *BearerAuth
-	Custom *CustomAuth
+// isHeaderName reports whether name is a valid HTTP header name (RFC 9110 token)
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+	return true
 }
 
-// Validate validates the authentication configuration
-func (a *AuthConfig) Validate(fieldPrefix string) error {
+// validateBaseURL validates the format of a base URL
+func validateBaseURL(field, baseURL string) ValidationErrors {
 	var errors ValidationErrors
 
-	// Validate Type
-	validTypes := map[string]bool{
-		"basic":  true,
-		"bearer": true,
-		"custom": true,
-		"none":   true,
-		"":       true, // empty is treated as none
+	// Parse and validate URL format
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return append(errors, ValidationError{
+			Field:   field,
+			Message: fmt.Sprintf("invalid URL format: %v", err.(*url.Error).Err),
+		})
 	}
 
-	if !validTypes[strings.ToLower(a.Type)] {
+	// Check scheme
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
 		errors = append(errors, ValidationError{
-			Field:   fmt.Sprintf("%s.type", fieldPrefix),
-			Message: fmt.Sprintf("invalid auth type '%s': must be 'basic', 'bearer', 'custom', or 'none'", a.Type),
+			Field:   field,
+			Message: fmt.Sprintf("invalid URL scheme '%s': must be http or https", parsedURL.Scheme),
+		})
+	}
+	// Check host
+	if parsedURL.Host == "" {
+		errors = append(errors, ValidationError{
+			Field:   field,
+			Message: "URL must include a host (e.g., http://localhost:8080)",
 		})
 	}
+	return errors
+}
 
-	// Validate type-specific configuration
-	authType := strings.ToLower(a.Type)
-	switch authType {
-	case "basic":
-		if a.Basic == nil {
+// TLS represents TLS configuration
+type TLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+}
+
+// operations are the operations a plugin's operations setting may allow
+var operations = []string{"read", "create", "replace", "patch", "delete"}
+
+// grantableOperations are the operations authorization may grant: those on
+// resources and admin, for the admin endpoints such as resource history
+var grantableOperations = append(slices.Clone(operations), "admin")
+
+// validateOperations validates the operations allowed per resource type.
+// Resource types match endpoints case-insensitively, so they must differ in
+// more than case.
+func validateOperations(field string, allowed map[string][]string) ValidationErrors {
+	var errors ValidationErrors
+	endpoints := slices.Sorted(maps.Keys(allowed))
+	for i, endpoint := range endpoints {
+		ops := allowed[endpoint]
+		if strings.TrimSpace(endpoint) == "" {
 			errors = append(errors, ValidationError{
-				Field:   fmt.Sprintf("%s.basic", fieldPrefix),
-				Message: "basic auth configuration is required when type is 'basic'",
+				Field:   field,
+				Message: "resource type cannot be empty",
 			})
-		} else {
-			if a.Basic.Username == "" {
-				errors = append(errors, ValidationError{
-					Field:   fmt.Sprintf("%s.basic.username", fieldPrefix),
-					Message: "username cannot be empty for basic auth",
-				})
-			}
-			if a.Basic.Password == "" {
-				errors = append(errors, ValidationError{
-					Field:   fmt.Sprintf("%s.basic.password", fieldPrefix),
-					Message: "password cannot be empty for basic auth",
-				})
-			}
+			continue
 		}
-	case "bearer":
-		if a.Bearer == nil {
+		if i > 0 && strings.EqualFold(endpoints[i-1], endpoint) {
 			errors = append(errors, ValidationError{
-				Field:   fmt.Sprintf("%s.bearer", fieldPrefix),
-				Message: "bearer auth configuration is required when type is 'bearer'",
+				Field:   fmt.Sprintf("%s.%s", field, endpoint),
+				Message: fmt.Sprintf("resource type %s is listed as %s too", endpoint, endpoints[i-1]),
 			})
-		} else {
-			if a.Bearer.Token == "" {
+		}
+		for j, op := range ops {
+			if !slices.Contains(operations, op) {
 				errors = append(errors, ValidationError{
-					Field:   fmt.Sprintf("%s.bearer.token", fieldPrefix),
-					Message: "token cannot be empty for bearer auth",
+					Field:   fmt.Sprintf("%s.%s[%d]", field, endpoint, j),
+					Message: fmt.Sprintf("unknown operation %q, must be one of %s", op, strings.Join(operations, ", ")),
 				})
 			}
 		}
-	case "custom":
-		if a.Custom == nil || a.Custom.Authenticator == nil {
-			errors = append(errors, ValidationError{
+	}
+	return errors
+}
+
+// reservedBaseEntities are the endpoints of a plugin, which cannot be used as
+// base entity names
+var reservedBaseEntities = []string{
+	"Users", "Groups", "Me", "Bulk", "Schemas", "ResourceTypes", "ServiceProviderConfig",
+	".search", "health", "WriteQueue", "Operations", "Approvals",
+}
+
+// validateBaseEntities validates the base entities of a plugin
+func validateBaseEntities(field string, entities []BaseEntityConfig) ValidationErrors {
+	var errors ValidationErrors
+	names := make(map[string]bool)
+	for i, entity := range entities {
+		prefix := fmt.Sprintf("%s[%d]", field, i)
+		switch {
+		case strings.TrimSpace(entity.Name) == "":
+			errors = append(errors, ValidationError{
+				Field:   prefix + ".name",
+				Message: "base entity name is required",
+			})
+		case strings.ContainsAny(entity.Name, "/?#"):
+			errors = append(errors, ValidationError{
+				Field:   prefix + ".name",
+				Message: fmt.Sprintf("base entity name %q cannot contain '/', '?' or '#'", entity.Name),
+			})
+		case slices.Contains(reservedBaseEntities, entity.Name):
+			errors = append(errors, ValidationError{
+				Field:   prefix + ".name",
+				Message: fmt.Sprintf("base entity name %q is a plugin endpoint", entity.Name),
+			})
+		case names[entity.Name]:
+			errors = append(errors, ValidationError{
+				Field:   prefix + ".name",
+				Message: fmt.Sprintf("duplicate base entity name: %s", entity.Name),
+			})
+		}
+		names[entity.Name] = true
+
+		if entity.Auth != nil {
+			if err := entity.Auth.Validate(prefix + ".auth"); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				} else if verr, ok := err.(*ValidationError); ok {
+					errors = append(errors, *verr)
+				}
+			}
+		}
+		if entity.Quota != nil {
+			if err := entity.Quota.Validate(prefix + ".quota"); err != nil {
+				if verrs, ok := err.(ValidationErrors); ok {
+					errors = append(errors, verrs...)
+				}
+			}
+		}
+	}
+	return errors
+}
+
+// PluginConfig represents plugin-specific configuration
+type PluginConfig struct {
+	Name   string         `yaml:"name"`
+	Auth   *AuthConfig    `yaml:"auth"`
+	Config map[string]any `yaml:"config"`
+
+	// BaseURL overrides gateway.baseURL for the plugin's resources, e.g. when
+	// the plugin is exposed under its own public hostname. Location headers,
+	// meta.location and member $ref URLs are built from it.
+	BaseURL string `yaml:"baseURL"`
+
+	// BaseEntities lists the base entities (tenants, OUs, ...) the plugin
+	// serves under /{plugin}/{baseEntity}/..., so one plugin instance can
+	// serve several of them. Plugins scope requests by
+	// scim.BaseEntityFromContext.
+	BaseEntities []BaseEntityConfig `yaml:"baseEntities"`
+
+	// MembershipSync enables gateway-managed group membership fan-out:
+	// group member changes are mirrored into each user's groups attribute
+	// and deleted users are removed from their groups. See scim.MembershipSync.
+	MembershipSync bool `yaml:"membershipSync"`
+
+	// DefaultExcludedAttributes are left out of GET and search responses when
+	// the client specifies neither attributes nor excludedAttributes, e.g.
+	// [groups, members]. See scim.DefaultExcludedAttributesProvider.
+	DefaultExcludedAttributes []string `yaml:"defaultExcludedAttributes"`
+
+	// SortCollation is how sorted strings are ordered: empty in code point
+	// order, or by the database's collation for plugins sorting natively;
+	// "caseInsensitive" ignoring case, with Unicode case folding. See
+	// scim.CollationProvider.
+	SortCollation string `yaml:"sortCollation"`
+
+	// AccentInsensitive makes filters ignore accents as well as case, so
+	// "Jose" matches "José". Leave it off where values must match exactly.
+	// See scim.AccentInsensitiveProvider.
+	AccentInsensitive bool `yaml:"accentInsensitive"`
+
+	// Operations restricts the operations clients may perform per resource
+	// type endpoint, e.g. {Users: [read, create, replace, patch], Groups:
+	// [read]} allows user writes but no deletes and group reads only. The
+	// operations are read, create, replace, patch and delete. Resource types
+	// are matched case-insensitively; those not listed are unrestricted. See
+	// scim.OperationsProvider.
+	Operations map[string][]string `yaml:"operations"`
+
+	// Authorization restricts the operations of each authenticated client
+	// to those granted to its scopes or roles, e.g. scim.read to read only.
+	// Nil lets authenticated clients perform every operation.
+	Authorization *AuthorizationConfig `yaml:"authorization"`
+
+	// Bulk limits the size of bulk requests to the plugin. The limits are
+	// advertised in ServiceProviderConfig. Nil uses the plugin's limits or
+	// the defaults of scim.DefaultBulkMaxOperations and
+	// scim.DefaultBulkMaxPayloadSize. See scim.BulkLimitsProvider.
+	Bulk *BulkConfig `yaml:"bulk"`
+
+	// Pool tunes the connection pool of database-backed plugins
+	// (plugins implementing plugin.DBProvider). Nil keeps the plugin's defaults.
+	Pool *PoolConfig `yaml:"pool"`
+
+	// Failover tunes the health checks of a plugin registered with a standby
+	// (see Gateway.RegisterFailover). Nil uses the defaults.
+	Failover *FailoverConfig `yaml:"failover"`
+
+	// Warmup lists all users and groups of the plugin when the gateway
+	// starts, priming its caches. Nil disables warmup.
+	Warmup *WarmupConfig `yaml:"warmup"`
+
+	// Keepalive pings the persistent connections of the plugin's backend
+	// periodically, as job "<plugin>/keepalive", reconnecting when they went
+	// stale (plugins implementing plugin.Pinger or plugin.DBProvider). Nil
+	// disables keepalive pings.
+	Keepalive *KeepaliveConfig `yaml:"keepalive"`
+
+	// Quota limits the number of users and groups clients may create in the
+	// plugin's backend, counted per base entity for requests to one. Creates
+	// beyond it fail with 403. Nil allows any number.
+	Quota *QuotaConfig `yaml:"quota"`
+
+	// Cache serves repeated reads of the plugin's users and groups, such as
+	// those of the precondition checks of writes, from memory until a TTL
+	// expires, and invalidates them on writes. Nil reads from the plugin
+	// every time.
+	Cache *CacheConfig `yaml:"cache"`
+
+	// ClientCache lets clients cache successful GET responses of the plugin
+	// privately for a max age, with Cache-Control. Nil answers every request
+	// with Cache-Control: no-store. See scim.ClientCacheProvider.
+	ClientCache *ClientCacheConfig `yaml:"clientCache"`
+
+	// Audit records the writes to the plugin's users and groups, with the
+	// client that made them and the attributes they changed, and serves
+	// them as the history of each resource at /{plugin}/Users/{id}/_history
+	// and /{plugin}/Groups/{id}/_history. Nil records nothing.
+	Audit *AuditConfig `yaml:"audit"`
+
+	// Recording captures the requests to the plugin and their responses,
+	// without credentials and passwords, for troubleshooting, and serves
+	// them at /{plugin}/_recordings to be fetched or replayed. Nil records
+	// nothing.
+	Recording *RecordingConfig `yaml:"recording"`
+
+	// CircuitBreaker stops calling the user or the group backend of the
+	// plugin while it keeps failing, answering 503 for that resource type
+	// only. Nil disables circuit breaking.
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuitBreaker"`
+
+	// ErrorBudget tracks the share of successful calls to the plugin's
+	// backend over a rolling window against an objective, exposing the
+	// error budget left as metrics and at /{plugin}/_errorBudget. Nil tracks
+	// nothing.
+	ErrorBudget *ErrorBudgetConfig `yaml:"errorBudget"`
+
+	// WriteWindow restricts writes to the plugin's backend to a schedule,
+	// rejecting or queueing writes outside of it. Nil allows writes at any
+	// time.
+	WriteWindow *WriteWindowConfig `yaml:"writeWindow"`
+
+	// AsyncWrites acknowledges writes with 202 Accepted and a status URL,
+	// queues them in the gateway's operation store and applies them in the
+	// background with retries. Nil applies writes while the client waits.
+	AsyncWrites *AsyncWritesConfig `yaml:"asyncWrites"`
+
+	// Approval holds replaces and PATCHes changing the listed attributes
+	// until they are approved at /{plugin}/Approvals/{id}/approve,
+	// acknowledging them with 202 Accepted. Nil applies all changes right
+	// away.
+	Approval *ApprovalConfig `yaml:"approval"`
+
+	// HTTPClient configures the outbound HTTP client of the plugin's
+	// authenticator and of HTTP-based plugins such as restproxy: proxy,
+	// trusted CAs, client certificate, timeout and connection pooling.
+	// Nil uses gateway.httpClient.
+	HTTPClient *HTTPClientConfig `yaml:"httpClient"`
+
+	// Normalize canonicalizes the attribute values of users and groups sent
+	// to the plugin, replacing gateway.normalize entirely. Nil uses
+	// gateway.normalize.
+	Normalize *NormalizeConfig `yaml:"normalize"`
+}
+
+// SortCollationCaseInsensitive sorts strings ignoring case, as
+// scim.CollationCaseInsensitive
+const SortCollationCaseInsensitive = "caseInsensitive"
+
+// BaseEntityConfig represents a base entity (tenant, OU, ...) a plugin
+// serves under /{plugin}/{name}/...
+type BaseEntityConfig struct {
+	Name string `yaml:"name"`
+
+	// Auth authenticates the requests to the base entity. Nil uses the
+	// plugin's auth.
+	Auth *AuthConfig `yaml:"auth"`
+
+	// Config holds settings of the base entity, which plugins read with
+	// scimcontext.TenantConfig
+	Config map[string]any `yaml:"config"`
+
+	// Quota limits the users and groups of the base entity. Nil uses the
+	// plugin's quota.
+	Quota *QuotaConfig `yaml:"quota"`
+}
+
+// AuthorizationConfig maps the scopes or roles of authenticated clients to
+// the operations they may perform on the plugin's resources
+type AuthorizationConfig struct {
+	// Claim names the claim holding the client's roles, e.g. "roles", as a
+	// list or a space-separated string. Empty uses the client's scopes.
+	Claim string `yaml:"claim"`
+
+	// Grants maps each scope or role to the operations it allows, e.g.
+	// {scim.read: [read], scim.write: [read, create, replace, patch,
+	// delete]}. Clients may perform the operations of all their scopes or
+	// roles, and none without a granted one.
+	Grants map[string][]string `yaml:"grants"`
+}
+
+// Validate validates the authorization configuration
+func (c *AuthorizationConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if len(c.Grants) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.grants", fieldPrefix),
+			Message: "at least one grant is required",
+		})
+	}
+	for grant, ops := range c.Grants {
+		if strings.TrimSpace(grant) == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.grants", fieldPrefix),
+				Message: "scope or role cannot be empty",
+			})
+			continue
+		}
+		for j, op := range ops {
+			if !slices.Contains(grantableOperations, op) {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("%s.grants.%s[%d]", fieldPrefix, grant, j),
+					Message: fmt.Sprintf("unknown operation %q, must be one of %s", op, strings.Join(grantableOperations, ", ")),
+				})
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// pluginAuthenticates reports whether every request to plugin is
+// authenticated, by the plugin's auth or by the auth of its base entities
+func pluginAuthenticates(plugin PluginConfig) bool {
+	if plugin.Auth != nil {
+		return true
+	}
+	if len(plugin.BaseEntities) == 0 {
+		return false
+	}
+	for _, entity := range plugin.BaseEntities {
+		if entity.Auth == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// BulkConfig represents the limits of bulk requests to a plugin. Zero
+// values keep the plugin's limits or the defaults.
+type BulkConfig struct {
+	// MaxOperations is the maximum number of operations of a request,
+	// e.g. 1000
+	MaxOperations int `yaml:"maxOperations"`
+
+	// MaxPayloadSize is the maximum size of a request body in bytes, e.g.
+	// 1048576
+	MaxPayloadSize int `yaml:"maxPayloadSize"`
+}
+
+// Validate validates the bulk configuration
+func (c *BulkConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.MaxOperations < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxOperations", fieldPrefix),
+			Message: fmt.Sprintf("maxOperations %d cannot be negative", c.MaxOperations),
+		})
+	}
+	if c.MaxPayloadSize < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxPayloadSize", fieldPrefix),
+			Message: fmt.Sprintf("maxPayloadSize %d cannot be negative", c.MaxPayloadSize),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// QuotaConfig represents the maximum numbers of users and groups of a
+// plugin or base entity, e.g. the seats of a licensed backend. Zero values
+// are unlimited.
+type QuotaConfig struct {
+	MaxUsers  int `yaml:"maxUsers"`
+	MaxGroups int `yaml:"maxGroups"`
+
+	// WarnPercent is the share of a quota, in percent, from which creates
+	// are answered with an X-Quota-Warning header, e.g. 80. Zero disables
+	// the warnings.
+	WarnPercent int `yaml:"warnPercent"`
+}
+
+// Validate validates the quota configuration
+func (c *QuotaConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.MaxUsers < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxUsers", fieldPrefix),
+			Message: fmt.Sprintf("maxUsers %d cannot be negative", c.MaxUsers),
+		})
+	}
+	if c.MaxGroups < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxGroups", fieldPrefix),
+			Message: fmt.Sprintf("maxGroups %d cannot be negative", c.MaxGroups),
+		})
+	}
+	if c.WarnPercent < 0 || c.WarnPercent > 100 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.warnPercent", fieldPrefix),
+			Message: fmt.Sprintf("warnPercent %d must be between 0 and 100", c.WarnPercent),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// CacheConfig represents the settings of the cache of a plugin's users and
+// groups. Zero values use the defaults of plugin.NewCache and
+// plugin.NewMemoryCacheStore.
+type CacheConfig struct {
+	// TTL is how long users, groups and lists are cached, e.g. 30s
+	TTL time.Duration `yaml:"ttl"`
+
+	// MaxEntries bounds the number of entries kept in memory, e.g. 50000
+	MaxEntries int `yaml:"maxEntries"`
+}
+
+// Validate validates the cache configuration
+func (c *CacheConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.TTL < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.ttl", fieldPrefix),
+			Message: fmt.Sprintf("ttl %s cannot be negative", c.TTL),
+		})
+	}
+	if c.MaxEntries < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxEntries", fieldPrefix),
+			Message: fmt.Sprintf("maxEntries %d cannot be negative", c.MaxEntries),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// ClientCacheConfig represents how long clients may cache the responses of
+// a plugin. Zero max ages keep the responses from being cached.
+type ClientCacheConfig struct {
+	// DiscoveryMaxAge applies to ServiceProviderConfig, ResourceTypes and
+	// Schemas, e.g. 1h
+	DiscoveryMaxAge time.Duration `yaml:"discoveryMaxAge"`
+
+	// ResourceMaxAge applies to GETs of resources and their lists, e.g. 30s.
+	// Clients revalidate cached resources with their ETag once it expires.
+	ResourceMaxAge time.Duration `yaml:"resourceMaxAge"`
+}
+
+// Validate validates the client cache configuration
+func (c *ClientCacheConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.DiscoveryMaxAge < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.discoveryMaxAge", fieldPrefix),
+			Message: fmt.Sprintf("discoveryMaxAge %s cannot be negative", c.DiscoveryMaxAge),
+		})
+	}
+	if c.ResourceMaxAge < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.resourceMaxAge", fieldPrefix),
+			Message: fmt.Sprintf("resourceMaxAge %s cannot be negative", c.ResourceMaxAge),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// AuditConfig represents the settings of the audit log of a plugin's users
+// and groups
+type AuditConfig struct {
+	// MaxEvents bounds the number of events kept in memory, e.g. 50000.
+	// Zero uses plugin.DefaultAuditMaxEvents. The oldest events are dropped
+	// first.
+	MaxEvents int `yaml:"maxEvents"`
+}
+
+// Validate validates the audit configuration
+func (c *AuditConfig) Validate(fieldPrefix string) error {
+	if c.MaxEvents < 0 {
+		return ValidationErrors{{
+			Field:   fmt.Sprintf("%s.maxEvents", fieldPrefix),
+			Message: fmt.Sprintf("maxEvents %d cannot be negative", c.MaxEvents),
+		}}
+	}
+	return nil
+}
+
+// RecordingConfig represents the settings of the recorder of a plugin's
+// requests
+type RecordingConfig struct {
+	// MaxExchanges bounds the number of exchanges kept in memory, e.g. 500.
+	// Zero uses plugin.DefaultRecordingMaxExchanges. The oldest exchanges
+	// are dropped first.
+	MaxExchanges int `yaml:"maxExchanges"`
+
+	// MaxBodySize bounds the bytes recorded of each request and response
+	// body, e.g. 65536. Zero uses plugin.DefaultRecordingMaxBodySize.
+	// Exchanges with a truncated request body cannot be replayed.
+	MaxBodySize int `yaml:"maxBodySize"`
+
+	// File also appends the exchanges to a JSON Lines file, e.g.
+	// /var/log/scimgateway/hr-recording.jsonl. Empty keeps them in memory
+	// only.
+	File string `yaml:"file"`
+}
+
+// Validate validates the recording configuration
+func (c *RecordingConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+	if c.MaxExchanges < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxExchanges", fieldPrefix),
+			Message: fmt.Sprintf("maxExchanges %d cannot be negative", c.MaxExchanges),
+		})
+	}
+	if c.MaxBodySize < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxBodySize", fieldPrefix),
+			Message: fmt.Sprintf("maxBodySize %d cannot be negative", c.MaxBodySize),
+		})
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// CircuitBreakerConfig represents the settings of the per resource type
+// circuit breakers of a plugin. Zero values use the defaults of
+// plugin.BreakerOptions.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests that
+	// open the circuit, e.g. 5
+	FailureThreshold int `yaml:"failureThreshold"`
+
+	// OpenDuration is how long requests are rejected before a trial request
+	// is let through, e.g. 30s
+	OpenDuration time.Duration `yaml:"openDuration"`
+}
+
+// Validate validates the circuit breaker configuration
+func (c *CircuitBreakerConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.FailureThreshold < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.failureThreshold", fieldPrefix),
+			Message: fmt.Sprintf("failureThreshold %d cannot be negative", c.FailureThreshold),
+		})
+	}
+	if c.OpenDuration < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.openDuration", fieldPrefix),
+			Message: fmt.Sprintf("openDuration %s cannot be negative", c.OpenDuration),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// ErrorBudgetConfig represents the service level objective the calls to a
+// plugin's backend are tracked against. Zero values use the defaults of
+// plugin.ErrorBudgetOptions.
+type ErrorBudgetConfig struct {
+	// Objective is the percentage of calls that should succeed, e.g. 99.5
+	Objective float64 `yaml:"objective"`
+
+	// Window is the rolling period calls are counted over, e.g. 1h
+	Window time.Duration `yaml:"window"`
+
+	// MinRequests is the number of calls in the window below which the
+	// budget is not considered exhausted, e.g. 100
+	MinRequests int `yaml:"minRequests"`
+
+	// TripBreaker opens the circuit breaker of a resource type when one of
+	// its calls fails while the budget is exhausted. It requires
+	// circuitBreaker.
+	TripBreaker bool `yaml:"tripBreaker"`
+}
+
+// Validate validates the error budget configuration
+func (c *ErrorBudgetConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.Objective < 0 || c.Objective >= 100 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.objective", fieldPrefix),
+			Message: fmt.Sprintf("objective %g must be a percentage of at least 0 and below 100", c.Objective),
+		})
+	}
+	if c.Window < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.window", fieldPrefix),
+			Message: fmt.Sprintf("window %s cannot be negative", c.Window),
+		})
+	}
+	if c.MinRequests < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.minRequests", fieldPrefix),
+			Message: fmt.Sprintf("minRequests %d cannot be negative", c.MinRequests),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// AsyncWritesConfig represents the settings of the asynchronous write mode
+// of a plugin. Zero values use the defaults of plugin.AsyncOptions.
+type AsyncWritesConfig struct {
+	// MaxAttempts is the number of times a write failing with a backend
+	// error is tried before it fails, e.g. 5
+	MaxAttempts int `yaml:"maxAttempts"`
+
+	// RetryDelay is the delay before the first retry, doubled for every
+	// further retry, e.g. 30s
+	RetryDelay time.Duration `yaml:"retryDelay"`
+
+	// Retention is how long applied and failed operations are kept for
+	// status requests, e.g. 24h
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Validate validates the asynchronous write configuration
+func (c *AsyncWritesConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if c.MaxAttempts < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxAttempts", fieldPrefix),
+			Message: fmt.Sprintf("maxAttempts %d cannot be negative", c.MaxAttempts),
+		})
+	}
+	if c.RetryDelay < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.retryDelay", fieldPrefix),
+			Message: fmt.Sprintf("retryDelay %s cannot be negative", c.RetryDelay),
+		})
+	}
+	if c.Retention < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.retention", fieldPrefix),
+			Message: fmt.Sprintf("retention %s cannot be negative", c.Retention),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// ApprovalConfig represents the attribute changes of a plugin's users and
+// groups that wait for an external approval
+type ApprovalConfig struct {
+	// Attributes maps the resource type endpoints, Users and Groups, to the
+	// attributes whose changes need approval, e.g. {Users:
+	// [title, urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager]}.
+	// Extension attributes are named with their schema URN.
+	Attributes map[string][]string `yaml:"attributes"`
+
+	// Retention is how long approved, rejected and failed changes are kept
+	// for status requests, e.g. 168h. Defaults to 24h.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Validate validates the approval configuration
+func (c *ApprovalConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if len(c.Attributes) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.attributes", fieldPrefix),
+			Message: "at least one attribute is required",
+		})
+	}
+	for endpoint, attributes := range c.Attributes {
+		if endpoint != "Users" && endpoint != "Groups" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.attributes.%s", fieldPrefix, endpoint),
+				Message: fmt.Sprintf("unknown resource type %q, must be Users or Groups", endpoint),
+			})
+		}
+		for j, attribute := range attributes {
+			if strings.TrimSpace(attribute) == "" {
+				errors = append(errors, ValidationError{
+					Field:   fmt.Sprintf("%s.attributes.%s[%d]", fieldPrefix, endpoint, j),
+					Message: "attribute cannot be empty",
+				})
+			}
+		}
+	}
+	if c.Retention < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.retention", fieldPrefix),
+			Message: fmt.Sprintf("retention %s cannot be negative", c.Retention),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// RootEndpoints are the first path segments served by gateway.rootEndpoints
+var RootEndpoints = []string{"Users", "Groups", "ServiceProviderConfig"}
+
+// RootEndpointsConfig represents the endpoints served without a plugin path
+// prefix, for identity providers that cannot be configured with one
+type RootEndpointsConfig struct {
+	// Plugin serves the root endpoints as if the requests were sent to
+	// /{plugin}/..., with the plugin's authentication
+	Plugin string `yaml:"plugin"`
+
+	// Aggregate answers GET /Users and /Groups with the resources of every
+	// plugin the client is authenticated for, GET /Users/{id} and
+	// /Groups/{id} from the first plugin having the resource, and
+	// /ServiceProviderConfig with the features all plugins support. Other
+	// requests are sent to Plugin, or fail with 501 without one.
+	Aggregate bool `yaml:"aggregate"`
+}
+
+// Validate validates the root endpoints configuration. Whether Plugin is
+// configured is checked by Config.Validate.
+func (c *RootEndpointsConfig) Validate(fieldPrefix string) error {
+	if c.Plugin == "" && !c.Aggregate {
+		return ValidationErrors{{
+			Field:   fmt.Sprintf("%s.plugin", fieldPrefix),
+			Message: "plugin is required unless aggregate is enabled",
+		}}
+	}
+	return nil
+}
+
+// JobConfig overrides the settings of a maintenance job. Zero values keep
+// the job's own settings.
+type JobConfig struct {
+	// Enabled turns the job on or off. Nil keeps the job's default.
+	Enabled *bool `yaml:"enabled"`
+
+	// Interval between runs of the job, e.g. 30m
+	Interval time.Duration `yaml:"interval"`
+
+	// Jitter is the largest fraction of the interval randomly added to or
+	// removed from each wait, e.g. 0.2. Negative runs at exact intervals.
+	Jitter float64 `yaml:"jitter"`
+}
+
+// Validate validates the job configuration
+func (j *JobConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+	if j.Interval < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.interval", fieldPrefix),
+			Message: fmt.Sprintf("interval %s cannot be negative", j.Interval),
+		})
+	}
+	if j.Jitter > 1 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.jitter", fieldPrefix),
+			Message: fmt.Sprintf("jitter %g cannot be greater than 1", j.Jitter),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// Write window modes
+const (
+	WriteWindowReject = "reject" // writes outside the windows fail with 503
+	WriteWindowQueue  = "queue"  // writes outside the windows are queued
+)
+
+// WriteWindowConfig represents the times writes to a plugin's backend are
+// allowed at, for backends that must not change during business hours
+type WriteWindowConfig struct {
+	// Schedule lists cron expressions of the minutes writes are allowed in,
+	// e.g. "* 22-23,0-5 * * 1-5" for weeknights and "* * * * 0,6" for
+	// weekends. See package cron.
+	Schedule []string `yaml:"schedule"`
+
+	// TimeZone is the IANA time zone the schedule is in, e.g. Europe/Berlin.
+	// Empty uses UTC.
+	TimeZone string `yaml:"timeZone"`
+
+	// Mode is what happens to writes outside the windows: "reject"
+	// (default) fails them with 503, "queue" accepts them with 202 and
+	// applies them in order when the next window opens or they are flushed.
+	Mode string `yaml:"mode"`
+}
+
+// Validate validates the write window configuration
+func (c *WriteWindowConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if len(c.Schedule) == 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.schedule", fieldPrefix),
+			Message: "schedule must list at least one cron expression",
+		})
+	}
+	for i, expr := range c.Schedule {
+		if _, err := cron.Parse(expr); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.schedule[%d]", fieldPrefix, i),
+				Message: err.Error(),
+			})
+		}
+	}
+	if _, err := time.LoadLocation(c.TimeZone); err != nil {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.timeZone", fieldPrefix),
+			Message: fmt.Sprintf("unknown time zone %q", c.TimeZone),
+		})
+	}
+	switch c.Mode {
+	case "", WriteWindowReject, WriteWindowQueue:
+	default:
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.mode", fieldPrefix),
+			Message: fmt.Sprintf("mode %q must be %s or %s", c.Mode, WriteWindowReject, WriteWindowQueue),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// WarmupConfig represents the settings of cache priming on startup.
+// Zero values use the defaults of plugin.WarmOptions.
+type WarmupConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// PageSize is the number of resources requested per page, e.g. 500
+	PageSize int `yaml:"pageSize"`
+
+	// Interval is the minimum time between two page requests, e.g. 200ms
+	Interval time.Duration `yaml:"interval"`
+
+	// RefreshInterval warms the plugin again periodically, e.g. 1h, as job
+	// "<plugin>/warm", to keep its caches fresh. Zero warms it only on start.
+	RefreshInterval time.Duration `yaml:"refreshInterval"`
+}
+
+// Validate validates the warmup configuration
+func (w *WarmupConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if w.PageSize < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.pageSize", fieldPrefix),
+			Message: fmt.Sprintf("pageSize %d cannot be negative", w.PageSize),
+		})
+	}
+	if w.Interval < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.interval", fieldPrefix),
+			Message: fmt.Sprintf("interval %s cannot be negative", w.Interval),
+		})
+	}
+	if w.RefreshInterval < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.refreshInterval", fieldPrefix),
+			Message: fmt.Sprintf("refreshInterval %s cannot be negative", w.RefreshInterval),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// KeepaliveConfig represents the settings of keepalive pings. Zero values
+// use the defaults of plugin.DefaultKeepaliveInterval and
+// plugin.KeepaliveOptions.
+type KeepaliveConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Interval between pings, e.g. 5m. Keep it below the idle timeout of
+	// firewalls and of the backend.
+	Interval time.Duration `yaml:"interval"`
+
+	// Jitter is the largest fraction of the interval randomly added to or
+	// removed from each wait, e.g. 0.2, so the replicas of a gateway do not
+	// ping at the same moment. Zero uses scheduler.DefaultJitter.
+	Jitter float64 `yaml:"jitter"`
+
+	// Timeout bounds each ping and reconnect, e.g. 10s
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Validate validates the keepalive configuration
+func (k *KeepaliveConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if k.Interval < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.interval", fieldPrefix),
+			Message: fmt.Sprintf("interval %s cannot be negative", k.Interval),
+		})
+	}
+	if k.Jitter > 1 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.jitter", fieldPrefix),
+			Message: fmt.Sprintf("jitter %g cannot be greater than 1", k.Jitter),
+		})
+	}
+	if k.Timeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.timeout", fieldPrefix),
+			Message: fmt.Sprintf("timeout %s cannot be negative", k.Timeout),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// FailoverConfig represents the health check settings of a failover pair.
+// Zero values use the defaults of plugin.FailoverOptions.
+type FailoverConfig struct {
+	// Interval between health checks of the primary, e.g. 10s
+	Interval time.Duration `yaml:"interval"`
+
+	// FailureThreshold is the number of consecutive failed checks after which
+	// requests fail over to the standby, and of successful checks after which
+	// they fail back to the primary
+	FailureThreshold int `yaml:"failureThreshold"`
+
+	// Timeout bounds a single health check. Zero uses the interval.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Validate validates the failover configuration
+func (f *FailoverConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if f.Interval < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.interval", fieldPrefix),
+			Message: fmt.Sprintf("interval %s cannot be negative", f.Interval),
+		})
+	}
+	if f.FailureThreshold < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.failureThreshold", fieldPrefix),
+			Message: fmt.Sprintf("failureThreshold %d cannot be negative", f.FailureThreshold),
+		})
+	}
+	if f.Timeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.timeout", fieldPrefix),
+			Message: fmt.Sprintf("timeout %s cannot be negative", f.Timeout),
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// PoolConfig represents database connection pool settings.
+// Zero values leave the corresponding setting unchanged.
+type PoolConfig struct {
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"connMaxIdleTime"`
+}
+
+// Validate validates the pool configuration
+func (p *PoolConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if p.MaxOpenConns < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxOpenConns", fieldPrefix),
+			Message: fmt.Sprintf("maxOpenConns %d cannot be negative", p.MaxOpenConns),
+		})
+	}
+	if p.MaxIdleConns < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxIdleConns", fieldPrefix),
+			Message: fmt.Sprintf("maxIdleConns %d cannot be negative", p.MaxIdleConns),
+		})
+	}
+	if p.MaxOpenConns > 0 && p.MaxIdleConns > p.MaxOpenConns {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxIdleConns", fieldPrefix),
+			Message: fmt.Sprintf("maxIdleConns %d cannot exceed maxOpenConns %d", p.MaxIdleConns, p.MaxOpenConns),
+		})
+	}
+	if p.ConnMaxLifetime < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.connMaxLifetime", fieldPrefix),
+			Message: "connMaxLifetime cannot be negative",
+		})
+	}
+	if p.ConnMaxIdleTime < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.connMaxIdleTime", fieldPrefix),
+			Message: "connMaxIdleTime cannot be negative",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// Apply applies the non-zero pool settings to db
+func (p *PoolConfig) Apply(db *sql.DB) {
+	if p.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(p.MaxOpenConns)
+	}
+	if p.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(p.MaxIdleConns)
+	}
+	if p.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(p.ConnMaxLifetime)
+	}
+	if p.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(p.ConnMaxIdleTime)
+	}
+}
+
+// DefaultHTTPTimeout is the timeout of outbound HTTP clients whose
+// HTTPClientConfig leaves it unset
+const DefaultHTTPTimeout = 10 * time.Second
+
+// HTTPClientConfig represents the settings of an outbound HTTP client, used
+// to call authorization servers and the backends of HTTP-based plugins.
+// Zero values keep the defaults of http.DefaultTransport.
+type HTTPClientConfig struct {
+	// Timeout limits the time of a request including reading the response
+	// body, e.g. 30s. Zero uses DefaultHTTPTimeout.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// ProxyURL routes requests through an http, https or socks5 proxy.
+	// Empty uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string `yaml:"proxyURL"`
+
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system pool, e.g. for backends with an internal CA
+	CAFile string `yaml:"caFile"`
+
+	// CertFile and KeyFile are a client certificate presented to servers
+	// requiring mutual TLS. Both or neither must be set.
+	CertFile string `yaml:"certFile"`
+	KeyFile  string `yaml:"keyFile"`
+
+	// MaxIdleConnsPerHost is the number of keep-alive connections kept per
+	// backend host, e.g. 20 for a busy backend
+	MaxIdleConnsPerHost int `yaml:"maxIdleConnsPerHost"`
+
+	// IdleConnTimeout closes keep-alive connections idle for longer, e.g. 90s
+	IdleConnTimeout time.Duration `yaml:"idleConnTimeout"`
+}
+
+// Validate validates the HTTP client configuration
+func (h *HTTPClientConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if h.Timeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.timeout", fieldPrefix),
+			Message: fmt.Sprintf("timeout %s cannot be negative", h.Timeout),
+		})
+	}
+	if h.ProxyURL != "" {
+		u, err := url.Parse(h.ProxyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || u.Host == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.proxyURL", fieldPrefix),
+				Message: fmt.Sprintf("proxyURL '%s' must be an http, https or socks5 URL", redactString(h.ProxyURL)),
+			})
+		}
+	}
+	if (h.CertFile == "") != (h.KeyFile == "") {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.certFile", fieldPrefix),
+			Message: "certFile and keyFile must be set together",
+		})
+	}
+	if h.MaxIdleConnsPerHost < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.maxIdleConnsPerHost", fieldPrefix),
+			Message: fmt.Sprintf("maxIdleConnsPerHost %d cannot be negative", h.MaxIdleConnsPerHost),
+		})
+	}
+	if h.IdleConnTimeout < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.idleConnTimeout", fieldPrefix),
+			Message: "idleConnTimeout cannot be negative",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// NewClient returns an HTTP client with the settings of h. Every call
+// returns a client with its own connection pool, so components sharing a
+// backend should share the client. A nil h returns a client with the
+// defaults.
+func (h *HTTPClientConfig) NewClient() (*http.Client, error) {
+	if h == nil {
+		h = &HTTPClientConfig{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if h.ProxyURL != "" {
+		proxy, err := url.Parse(h.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if h.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = h.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, h.MaxIdleConnsPerHost)
+	}
+	if h.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = h.IdleConnTimeout
+	}
+
+	if h.CAFile != "" || h.CertFile != "" {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if h.CAFile != "" {
+			pem, err := os.ReadFile(h.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in CA file %s", h.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if h.CertFile != "" {
+			cert, err := tls.LoadX509KeyPair(h.CertFile, h.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHTTPTimeout
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// PhoneFormatE164 formats phone numbers as E.164, e.g. +4930123456
+const PhoneFormatE164 = "e164"
+
+// NormalizeConfig represents the rules canonicalizing the attribute values
+// of users and groups (see scim.Normalization)
+type NormalizeConfig struct {
+	// TrimWhitespace removes leading and trailing whitespace from string
+	// values, except passwords
+	TrimWhitespace bool `yaml:"trimWhitespace"`
+
+	// LowercaseUserName lowercases userName
+	LowercaseUserName bool `yaml:"lowercaseUserName"`
+
+	// PhoneNumbers formats phoneNumbers: "e164", or empty to pass them as sent
+	PhoneNumbers string `yaml:"phoneNumbers"`
+
+	// DefaultCountryCode is the calling code of phone numbers sent without
+	// one, e.g. "49". Empty rejects such numbers.
+	DefaultCountryCode string `yaml:"defaultCountryCode"`
+
+	// ValidateEmails rejects emails whose value is not an email address
+	ValidateEmails bool `yaml:"validateEmails"`
+}
+
+// Validate validates the normalization configuration
+func (n *NormalizeConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	if n.PhoneNumbers != "" && n.PhoneNumbers != PhoneFormatE164 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.phoneNumbers", fieldPrefix),
+			Message: fmt.Sprintf("unsupported phoneNumbers format '%s': must be %s", n.PhoneNumbers, PhoneFormatE164),
+		})
+	}
+	if n.DefaultCountryCode != "" {
+		if !isCountryCode(n.DefaultCountryCode) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.defaultCountryCode", fieldPrefix),
+				Message: fmt.Sprintf("defaultCountryCode '%s' must be 1 to 3 digits without a leading 0", n.DefaultCountryCode),
+			})
+		} else if n.PhoneNumbers != PhoneFormatE164 {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.defaultCountryCode", fieldPrefix),
+				Message: fmt.Sprintf("defaultCountryCode requires phoneNumbers %s", PhoneFormatE164),
+			})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// isCountryCode reports whether code is an E.164 country calling code
+func isCountryCode(code string) bool {
+	if len(code) == 0 || len(code) > 3 || code[0] == '0' {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AuthConfig represents authentication configuration with type-safe config
+type AuthConfig struct {
+	Type   string      `yaml:"type"` // basic, bearer, oauth2, jwt, mtls, custom, none
+	Basic  *BasicAuth  `yaml:"basic"`
+	Bearer *BearerAuth `yaml:"bearer"`
+	OAuth2 *OAuth2Auth `yaml:"oauth2"`
+	JWT    *JWTAuth    `yaml:"jwt"`
+	MTLS   *MTLSAuth   `yaml:"mtls"`
+	Custom *CustomAuth `yaml:"-"` // custom authenticators can only be set programmatically
+}
+
+// Validate validates the authentication configuration
+func (a *AuthConfig) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	// Validate Type
+	validTypes := map[string]bool{
+		"basic":  true,
+		"bearer": true,
+		"oauth2": true,
+		"jwt":    true,
+		"mtls":   true,
+		"custom": true,
+		"none":   true,
+		"":       true, // empty is treated as none
+	}
+
+	if !validTypes[strings.ToLower(a.Type)] {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.type", fieldPrefix),
+			Message: fmt.Sprintf("invalid auth type '%s': must be 'basic', 'bearer', 'oauth2', 'jwt', 'mtls', 'custom', or 'none'", a.Type),
+		})
+	}
+
+	// Validate type-specific configuration
+	authType := strings.ToLower(a.Type)
+	switch authType {
+	case "basic":
+		if a.Basic == nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.basic", fieldPrefix),
+				Message: "basic auth configuration is required when type is 'basic'",
+			})
+		} else {
+			errors = append(errors, a.Basic.validate(fmt.Sprintf("%s.basic", fieldPrefix))...)
+		}
+	case "bearer":
+		if a.Bearer == nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.bearer", fieldPrefix),
+				Message: "bearer auth configuration is required when type is 'bearer'",
+			})
+		} else {
+			errors = append(errors, a.Bearer.validate(fmt.Sprintf("%s.bearer", fieldPrefix))...)
+		}
+	case "oauth2":
+		if a.OAuth2 == nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.oauth2", fieldPrefix),
+				Message: "oauth2 auth configuration is required when type is 'oauth2'",
+			})
+		} else if err := a.OAuth2.Validate(fmt.Sprintf("%s.oauth2", fieldPrefix)); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	case "jwt":
+		if a.JWT == nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.jwt", fieldPrefix),
+				Message: "jwt auth configuration is required when type is 'jwt'",
+			})
+		} else if err := a.JWT.Validate(fmt.Sprintf("%s.jwt", fieldPrefix)); err != nil {
+			if verrs, ok := err.(ValidationErrors); ok {
+				errors = append(errors, verrs...)
+			}
+		}
+	case "mtls":
+		if a.MTLS == nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.mtls", fieldPrefix),
+				Message: "mtls auth configuration is required when type is 'mtls'",
+			})
+		} else if a.MTLS.CAFile == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.mtls.caFile", fieldPrefix),
+				Message: "caFile cannot be empty for mtls auth",
+			})
+		}
+	case "custom":
+		if a.Custom == nil || a.Custom.Authenticator == nil {
+			errors = append(errors, ValidationError{
 				Field:   fmt.Sprintf("%s.custom", fieldPrefix),
 				Message: "custom auth configuration is required when type is 'custom'",
 			})
@@ -275,13 +1914,330 @@ func (a *AuthConfig) Validate(fieldPrefix string) error {
 
 // BasicAuth represents basic authentication configuration
 type BasicAuth struct {
-	Username string
-	Password string
+	Username string `yaml:"username"`
+	Password string `yaml:"password" redact:"true"`
+
+	// PasswordFile reads the password from a file at request time instead,
+	// e.g. a mounted Kubernetes secret, so it is rotated without a reload
+	PasswordFile string `yaml:"passwordFile"`
+
+	// PasswordProvider fetches the password at request time instead, e.g.
+	// from Vault or a KMS. It can only be set programmatically.
+	PasswordProvider auth.SecretProvider `yaml:"-"`
+
+	// Credentials are further username and password pairs accepted, e.g.
+	// one per identity provider tenant, or the next password while
+	// clients are moved to it. Username and Password may be left empty
+	// when they are set.
+	Credentials []BasicCredential `yaml:"credentials"`
+}
+
+// BasicCredential is a username and password accepted by basic auth
+type BasicCredential struct {
+	Label    string `yaml:"label"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" redact:"true"`
+
+	// PasswordFile and PasswordProvider supply the password at request
+	// time instead, as for BasicAuth
+	PasswordFile     string              `yaml:"passwordFile"`
+	PasswordProvider auth.SecretProvider `yaml:"-"`
+
+	// ExpiresAt is when the credential stops being accepted, e.g.
+	// 2025-07-01T00:00:00Z. Zero never expires.
+	ExpiresAt time.Time `yaml:"expiresAt"`
+}
+
+// validate validates the basic auth configuration
+func (b *BasicAuth) validate(fieldPrefix string) ValidationErrors {
+	var errors ValidationErrors
+	hasPassword := b.Password != "" || b.PasswordFile != "" || b.PasswordProvider != nil
+	if len(b.Credentials) == 0 || b.Username != "" || hasPassword {
+		if b.Username == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.username", fieldPrefix),
+				Message: "username cannot be empty for basic auth",
+			})
+		}
+		errors = append(errors, validateSecret(fieldPrefix, "basic auth", "password", b.Password, b.PasswordFile, b.PasswordProvider)...)
+	}
+	for i, c := range b.Credentials {
+		prefix := fmt.Sprintf("%s.credentials[%d]", fieldPrefix, i)
+		if c.Username == "" {
+			errors = append(errors, ValidationError{
+				Field:   prefix + ".username",
+				Message: "username cannot be empty for basic auth",
+			})
+		}
+		errors = append(errors, validateSecret(prefix, "basic auth", "password", c.Password, c.PasswordFile, c.PasswordProvider)...)
+	}
+	return errors
+}
+
+// validateSecret validates that exactly one of a literal secret, a file
+// and a provider supplies the secret named name, e.g. "password", of the
+// auth method method
+func validateSecret(fieldPrefix, method, name, literal, file string, provider auth.SecretProvider) ValidationErrors {
+	sources := 0
+	for _, set := range []bool{literal != "", file != "", provider != nil} {
+		if set {
+			sources++
+		}
+	}
+	switch sources {
+	case 0:
+		return ValidationErrors{{
+			Field:   fmt.Sprintf("%s.%s", fieldPrefix, name),
+			Message: fmt.Sprintf("%s cannot be empty for %s", name, method),
+		}}
+	case 1:
+		return nil
+	}
+	return ValidationErrors{{
+		Field:   fmt.Sprintf("%s.%s", fieldPrefix, name),
+		Message: fmt.Sprintf("only one of %s, %sFile and %sProvider can be set", name, name, strings.ToUpper(name[:1])+name[1:]),
+	}}
 }
 
 // BearerAuth represents bearer token authentication configuration
 type BearerAuth struct {
-	Token string
+	Token string `yaml:"token" redact:"true"`
+
+	// TokenFile reads the token from a file at request time instead, e.g.
+	// a mounted Kubernetes secret, so it is rotated without a reload
+	TokenFile string `yaml:"tokenFile"`
+
+	// TokenProvider fetches the token at request time instead, e.g. from
+	// Vault or a KMS. It can only be set programmatically.
+	TokenProvider auth.SecretProvider `yaml:"-"`
+
+	// Tokens are further tokens accepted, e.g. one per identity provider
+	// tenant, or the next token while clients are moved to it. Token may be
+	// left empty when they are set.
+	Tokens []BearerToken `yaml:"tokens"`
+}
+
+// BearerToken is a token accepted by bearer auth
+type BearerToken struct {
+	// Label names the client using the token. Plugins see it as the
+	// subject of the request, see scim.PrincipalFromContext.
+	Label string `yaml:"label"`
+	Token string `yaml:"token" redact:"true"`
+
+	// TokenFile and TokenProvider supply the token at request time
+	// instead, as for BearerAuth
+	TokenFile     string              `yaml:"tokenFile"`
+	TokenProvider auth.SecretProvider `yaml:"-"`
+
+	// ExpiresAt is when the token stops being accepted, e.g.
+	// 2025-07-01T00:00:00Z. Zero never expires.
+	ExpiresAt time.Time `yaml:"expiresAt"`
+}
+
+// validate validates the bearer auth configuration
+func (b *BearerAuth) validate(fieldPrefix string) ValidationErrors {
+	var errors ValidationErrors
+	if len(b.Tokens) == 0 || b.Token != "" || b.TokenFile != "" || b.TokenProvider != nil {
+		errors = append(errors, validateSecret(fieldPrefix, "bearer auth", "token", b.Token, b.TokenFile, b.TokenProvider)...)
+	}
+	for i, t := range b.Tokens {
+		prefix := fmt.Sprintf("%s.tokens[%d]", fieldPrefix, i)
+		errors = append(errors, validateSecret(prefix, "bearer auth", "token", t.Token, t.TokenFile, t.TokenProvider)...)
+	}
+	return errors
+}
+
+// OAuth2Auth represents OAuth2 access token authentication configuration.
+// Tokens are validated as signed JWTs against JWKSURL, or with token
+// introspection at IntrospectionURL; exactly one of them must be set.
+type OAuth2Auth struct {
+	JWKSURL          string        `yaml:"jwksURL"`
+	IntrospectionURL string        `yaml:"introspectionURL"`
+	ClientID         string        `yaml:"clientID"`                   // required for introspection
+	ClientSecret     string        `yaml:"clientSecret" redact:"true"` // required for introspection
+	Issuer           string        `yaml:"issuer"`
+	Audience         string        `yaml:"audience"`
+	RequiredScopes   []string      `yaml:"requiredScopes"`
+	CacheTTL         time.Duration `yaml:"cacheTTL"` // key and introspection cache lifetime (default 5m)
+}
+
+// Validate validates the OAuth2 configuration
+func (o *OAuth2Auth) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	switch {
+	case o.JWKSURL == "" && o.IntrospectionURL == "":
+		errors = append(errors, ValidationError{
+			Field:   fieldPrefix,
+			Message: "one of jwksURL or introspectionURL is required for oauth2 auth",
+		})
+	case o.JWKSURL != "" && o.IntrospectionURL != "":
+		errors = append(errors, ValidationError{
+			Field:   fieldPrefix,
+			Message: "jwksURL and introspectionURL cannot both be set",
+		})
+	}
+
+	endpoints := []struct{ field, value string }{
+		{"jwksURL", o.JWKSURL},
+		{"introspectionURL", o.IntrospectionURL},
+	}
+	for _, endpoint := range endpoints {
+		field, value := endpoint.field, endpoint.value
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.%s", fieldPrefix, field),
+				Message: fmt.Sprintf("invalid URL '%s': must be an http or https URL", redactString(value)),
+			})
+		}
+	}
+
+	if o.IntrospectionURL != "" {
+		if o.ClientID == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.clientID", fieldPrefix),
+				Message: "clientID cannot be empty for token introspection",
+			})
+		}
+		if o.ClientSecret == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.clientSecret", fieldPrefix),
+				Message: "clientSecret cannot be empty for token introspection",
+			})
+		}
+	}
+
+	if o.CacheTTL < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.cacheTTL", fieldPrefix),
+			Message: "cacheTTL cannot be negative",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// JWTAuth represents authentication with bearer tokens that are signed JWTs,
+// verified with the keys of JWKSURL or with static PEM public keys; exactly
+// one of JWKSURL, PublicKey and PublicKeyFile must be set. See package
+// auth/jwt.
+type JWTAuth struct {
+	JWKSURL       string `yaml:"jwksURL"`
+	PublicKey     string `yaml:"publicKey"`     // PEM public keys or certificates
+	PublicKeyFile string `yaml:"publicKeyFile"` // file holding PublicKey
+
+	// Algorithms restricts the signature algorithms accepted, e.g. [RS256].
+	// Empty allows all RS*, PS* and ES* algorithms.
+	Algorithms []string `yaml:"algorithms"`
+
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// ClockSkew is the leeway of the exp and nbf checks, e.g. 30s. Zero uses
+	// gateway.clockSkew.
+	ClockSkew time.Duration `yaml:"clockSkew"`
+
+	// SubjectClaim names the claim identifying the client, e.g. azp
+	// (default sub), and ScopesClaim the claim holding its scopes, e.g.
+	// realm_access.roles (default scope or scp). Nested claims are named by
+	// their dot-separated path.
+	SubjectClaim string `yaml:"subjectClaim"`
+	ScopesClaim  string `yaml:"scopesClaim"`
+
+	CacheTTL time.Duration `yaml:"cacheTTL"` // JWKS cache lifetime (default 5m)
+}
+
+// Validate validates the JWT configuration
+func (j *JWTAuth) Validate(fieldPrefix string) error {
+	var errors ValidationErrors
+
+	sources := 0
+	for _, set := range []bool{j.JWKSURL != "", j.PublicKey != "", j.PublicKeyFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		errors = append(errors, ValidationError{
+			Field:   fieldPrefix,
+			Message: "exactly one of jwksURL, publicKey or publicKeyFile is required for jwt auth",
+		})
+	}
+
+	if j.JWKSURL != "" {
+		if u, err := url.Parse(j.JWKSURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.jwksURL", fieldPrefix),
+				Message: fmt.Sprintf("invalid URL '%s': must be an http or https URL", redactString(j.JWKSURL)),
+			})
+		}
+	}
+	if j.PublicKey != "" {
+		if _, err := jwt.ParsePublicKeys([]byte(j.PublicKey)); err != nil {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.publicKey", fieldPrefix),
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for i, alg := range j.Algorithms {
+		if !slices.Contains(jwt.Algorithms, alg) {
+			errors = append(errors, ValidationError{
+				Field:   fmt.Sprintf("%s.algorithms[%d]", fieldPrefix, i),
+				Message: fmt.Sprintf("unsupported algorithm %q, must be one of %s", alg, strings.Join(jwt.Algorithms, ", ")),
+			})
+		}
+	}
+
+	if j.ClockSkew < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.clockSkew", fieldPrefix),
+			Message: "clockSkew cannot be negative",
+		})
+	}
+	if j.CacheTTL < 0 {
+		errors = append(errors, ValidationError{
+			Field:   fmt.Sprintf("%s.cacheTTL", fieldPrefix),
+			Message: "cacheTTL cannot be negative",
+		})
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// MTLSAuth represents TLS client certificate authentication configuration.
+// Client certificates must chain to a CA in CAFile. AllowedIdentities lists the
+// certificate subject common names or DNS, email or URI subject alternative names
+// allowed to use the plugin; empty allows any certificate issued by the CAs.
+type MTLSAuth struct {
+	CAFile            string   `yaml:"caFile"`
+	AllowedIdentities []string `yaml:"allowedIdentities"`
+}
+
+// UsesMTLS reports whether any plugin or base entity authenticates with
+// client certificates
+func (c *Config) UsesMTLS() bool {
+	for _, plugin := range c.Plugins {
+		if plugin.Auth != nil && strings.EqualFold(plugin.Auth.Type, "mtls") {
+			return true
+		}
+		for _, entity := range plugin.BaseEntities {
+			if entity.Auth != nil && strings.EqualFold(entity.Auth.Type, "mtls") {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // CustomAuth represents custom authentication configuration
@@ -1,9 +1,20 @@
 package config
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/pem"
+	"errors"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/auth"
 )
 
 func TestConfigValidate(t *testing.T) {
@@ -204,6 +215,61 @@ func TestConfigValidate(t *testing.T) {
 			wantErr:     true,
 			errContains: []string{"gateway.baseURL", "gateway.port", "plugins"},
 		},
+		{
+			name: "empty default excluded attribute",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "http://localhost",
+					Port:    8080,
+				},
+				Plugins: []PluginConfig{
+					{Name: "test", DefaultExcludedAttributes: []string{"groups", " "}},
+				},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].defaultExcludedAttributes[1]", "cannot be empty"},
+		},
+		{
+			name: "unknown plugin operation",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "http://localhost",
+					Port:    8080,
+				},
+				Plugins: []PluginConfig{
+					{Name: "test", Operations: map[string][]string{"Users": {"read", "update"}}},
+				},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].operations.Users[1]", `unknown operation "update"`},
+		},
+		{
+			name: "plugin operations listing a resource type twice",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "http://localhost",
+					Port:    8080,
+				},
+				Plugins: []PluginConfig{
+					{Name: "test", Operations: map[string][]string{"Users": {"read"}, "users": {"read", "delete"}}},
+				},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].operations.users", "listed as Users too"},
+		},
+		{
+			name: "valid plugin operations",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "http://localhost",
+					Port:    8080,
+				},
+				Plugins: []PluginConfig{
+					{Name: "test", Operations: map[string][]string{"Users": {"read", "create", "replace", "patch"}, "Groups": {}}},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "valid config with https",
 			config: &Config{
@@ -217,6 +283,171 @@ func TestConfigValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "plugin baseURL override",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "https://api.example.com",
+				},
+				Plugins: []PluginConfig{
+					{Name: "acme", BaseURL: "https://scim.acme.example"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid plugin baseURL",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL: "https://api.example.com",
+				},
+				Plugins: []PluginConfig{
+					{Name: "acme", BaseURL: "scim.acme.example"},
+				},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].baseURL", "must be http or https"},
+		},
+		{
+			name: "unknown sort collation",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{Name: "acme", SortCollation: "tr"}},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].sortCollation"},
+		},
+		{
+			name: "bearer tokens without a primary token",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name: "acme",
+					Auth: &AuthConfig{Type: "bearer", Bearer: &BearerAuth{Tokens: []BearerToken{{Label: "tenant-a", Token: "secret"}}}},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "basic credential without password",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name: "acme",
+					Auth: &AuthConfig{Type: "basic", Basic: &BasicAuth{Credentials: []BasicCredential{{Username: "okta"}}}},
+				}},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].auth.basic.credentials[0].password"},
+		},
+		{
+			name: "invalid job settings",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com", Jobs: map[string]JobConfig{
+					"hr/purge-deleted": {Interval: -time.Minute, Jitter: 2},
+				}},
+				Plugins: []PluginConfig{{Name: "hr", Warmup: &WarmupConfig{Enabled: true, RefreshInterval: -time.Hour}}},
+			},
+			wantErr:     true,
+			errContains: []string{"gateway.jobs.hr/purge-deleted.interval", "gateway.jobs.hr/purge-deleted.jitter", "plugins[0].warmup.refreshInterval"},
+		},
+		{
+			name: "secrets from a file and a provider",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name: "acme",
+					Auth: &AuthConfig{Type: "bearer", Bearer: &BearerAuth{
+						TokenFile: "/run/secrets/scim-token",
+						Tokens:    []BearerToken{{Label: "vault", TokenProvider: auth.EnvSecret("SCIM_TOKEN")}},
+					}},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "password and password file",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name: "acme",
+					Auth: &AuthConfig{Type: "basic", Basic: &BasicAuth{Username: "okta", Password: "secret", PasswordFile: "/run/secrets/password"}},
+				}},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].auth.basic.password", "only one of password, passwordFile and PasswordProvider"},
+		},
+		{
+			name: "authorization with auth",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name:          "acme",
+					Auth:          &AuthConfig{Type: "bearer", Bearer: &BearerAuth{Token: "secret"}},
+					Authorization: &AuthorizationConfig{Grants: map[string][]string{"scim.read": {"read"}}},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "authorization without auth or with unknown operation",
+			config: &Config{
+				Gateway: GatewayConfig{BaseURL: "https://api.example.com"},
+				Plugins: []PluginConfig{{
+					Name:          "acme",
+					Authorization: &AuthorizationConfig{Grants: map[string][]string{"scim.read": {"list"}}},
+				}},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].authorization.grants.scim.read[0]", "authorization requires auth"},
+		},
+		{
+			name: "root endpoints served by a plugin",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL:       "https://api.example.com",
+					RootEndpoints: &RootEndpointsConfig{Plugin: "acme"},
+				},
+				Plugins: []PluginConfig{{Name: "acme"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "root endpoints without a plugin or aggregation",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL:       "https://api.example.com",
+					RootEndpoints: &RootEndpointsConfig{},
+				},
+				Plugins: []PluginConfig{{Name: "acme"}},
+			},
+			wantErr:     true,
+			errContains: []string{"gateway.rootEndpoints.plugin", "required unless aggregate"},
+		},
+		{
+			name: "root endpoints of an unknown plugin",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL:       "https://api.example.com",
+					RootEndpoints: &RootEndpointsConfig{Plugin: "crm", Aggregate: true},
+				},
+				Plugins: []PluginConfig{{Name: "acme"}},
+			},
+			wantErr:     true,
+			errContains: []string{"gateway.rootEndpoints.plugin", "'crm' is not configured"},
+		},
+		{
+			name: "plugin named like a root endpoint",
+			config: &Config{
+				Gateway: GatewayConfig{
+					BaseURL:       "https://api.example.com",
+					RootEndpoints: &RootEndpointsConfig{Aggregate: true},
+				},
+				Plugins: []PluginConfig{{Name: "Users"}},
+			},
+			wantErr:     true,
+			errContains: []string{"plugins[0].name", "root endpoint"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -308,6 +539,49 @@ func TestGatewayConfigValidate(t *testing.T) {
 			wantErr:     true,
 			errContains: "must include a host",
 		},
+		{
+			name: "negative clock skew",
+			config: GatewayConfig{
+				BaseURL:   "http://localhost",
+				ClockSkew: -time.Minute,
+			},
+			wantErr:     true,
+			errContains: "clockSkew",
+		},
+		{
+			name: "propagated headers",
+			config: GatewayConfig{
+				BaseURL:          "http://localhost",
+				PropagateHeaders: []string{"X-B3-TraceId", "x-correlation-id"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid propagated header",
+			config: GatewayConfig{
+				BaseURL:          "http://localhost",
+				PropagateHeaders: []string{"X-Trace Id"},
+			},
+			wantErr:     true,
+			errContains: "gateway.propagateHeaders[0]",
+		},
+		{
+			name: "filter trace in a header",
+			config: GatewayConfig{
+				BaseURL:     "http://localhost",
+				FilterTrace: FilterTraceHeader,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown filter trace mode",
+			config: GatewayConfig{
+				BaseURL:     "http://localhost",
+				FilterTrace: "stdout",
+			},
+			wantErr:     true,
+			errContains: "gateway.filterTrace",
+		},
 	}
 
 	for _, tt := range tests {
@@ -413,12 +687,204 @@ func TestAuthConfigValidate(t *testing.T) {
 			errContains: "custom auth configuration is required",
 		},
 		{
-			name: "invalid auth type",
+			name: "valid oauth2 auth with JWKS",
+			config: AuthConfig{
+				Type: "oauth2",
+				OAuth2: &OAuth2Auth{
+					JWKSURL:        "https://idp.example.com/.well-known/jwks.json",
+					Issuer:         "https://idp.example.com",
+					RequiredScopes: []string{"scim.write"},
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     false,
+		},
+		{
+			name: "valid oauth2 auth with introspection",
+			config: AuthConfig{
+				Type: "oauth2",
+				OAuth2: &OAuth2Auth{
+					IntrospectionURL: "https://idp.example.com/oauth2/introspect",
+					ClientID:         "gateway",
+					ClientSecret:     "secret",
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     false,
+		},
+		{
+			name: "oauth2 auth with nil config",
 			config: AuthConfig{
 				Type: "oauth2",
 			},
 			fieldPrefix: "gateway.auth",
 			wantErr:     true,
+			errContains: "oauth2 auth configuration is required",
+		},
+		{
+			name: "oauth2 auth without endpoint",
+			config: AuthConfig{
+				Type:   "oauth2",
+				OAuth2: &OAuth2Auth{Issuer: "https://idp.example.com"},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "one of jwksURL or introspectionURL is required",
+		},
+		{
+			name: "oauth2 auth with both endpoints",
+			config: AuthConfig{
+				Type: "oauth2",
+				OAuth2: &OAuth2Auth{
+					JWKSURL:          "https://idp.example.com/jwks",
+					IntrospectionURL: "https://idp.example.com/introspect",
+					ClientID:         "gateway",
+					ClientSecret:     "secret",
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "cannot both be set",
+		},
+		{
+			name: "oauth2 auth with invalid JWKS URL",
+			config: AuthConfig{
+				Type:   "oauth2",
+				OAuth2: &OAuth2Auth{JWKSURL: "ftp://idp.example.com/jwks"},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "gateway.auth.oauth2.jwksURL",
+		},
+		{
+			name: "oauth2 introspection without client credentials",
+			config: AuthConfig{
+				Type:   "oauth2",
+				OAuth2: &OAuth2Auth{IntrospectionURL: "https://idp.example.com/introspect"},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "clientID cannot be empty",
+		},
+		{
+			name: "valid jwt auth with JWKS",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT: &JWTAuth{
+					JWKSURL:    "https://idp.example.com/.well-known/jwks.json",
+					Algorithms: []string{"RS256"},
+					Audience:   "scim",
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     false,
+		},
+		{
+			name: "jwt auth with nil config",
+			config: AuthConfig{
+				Type: "jwt",
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "jwt auth configuration is required",
+		},
+		{
+			name: "jwt auth without keys",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT:  &JWTAuth{Issuer: "https://idp.example.com"},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "exactly one of jwksURL, publicKey or publicKeyFile",
+		},
+		{
+			name: "jwt auth with JWKS and key file",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT: &JWTAuth{
+					JWKSURL:       "https://idp.example.com/jwks",
+					PublicKeyFile: "/etc/scim/idp.pem",
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "exactly one of jwksURL, publicKey or publicKeyFile",
+		},
+		{
+			name: "jwt auth with invalid public key",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT:  &JWTAuth{PublicKey: "not a key"},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "gateway.auth.jwt.publicKey",
+		},
+		{
+			name: "jwt auth with symmetric algorithm",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT: &JWTAuth{
+					JWKSURL:    "https://idp.example.com/jwks",
+					Algorithms: []string{"HS256"},
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "unsupported algorithm",
+		},
+		{
+			name: "jwt auth with negative clock skew",
+			config: AuthConfig{
+				Type: "jwt",
+				JWT: &JWTAuth{
+					JWKSURL:   "https://idp.example.com/jwks",
+					ClockSkew: -time.Second,
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "gateway.auth.jwt.clockSkew",
+		},
+		{
+			name: "valid mtls auth",
+			config: AuthConfig{
+				Type: "mtls",
+				MTLS: &MTLSAuth{
+					CAFile:            "/etc/scim/clients-ca.pem",
+					AllowedIdentities: []string{"okta.example.com"},
+				},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     false,
+		},
+		{
+			name: "mtls auth with nil config",
+			config: AuthConfig{
+				Type: "mtls",
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "mtls auth configuration is required",
+		},
+		{
+			name: "mtls auth without CA bundle",
+			config: AuthConfig{
+				Type: "mtls",
+				MTLS: &MTLSAuth{AllowedIdentities: []string{"okta"}},
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
+			errContains: "caFile cannot be empty",
+		},
+		{
+			name: "invalid auth type",
+			config: AuthConfig{
+				Type: "kerberos",
+			},
+			fieldPrefix: "gateway.auth",
+			wantErr:     true,
 			errContains: "invalid auth type",
 		},
 	}
@@ -440,6 +906,549 @@ func TestAuthConfigValidate(t *testing.T) {
 	}
 }
 
+func TestPoolConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      PoolConfig
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "zero values keep defaults",
+			config:  PoolConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "valid settings",
+			config:  PoolConfig{MaxOpenConns: 25, MaxIdleConns: 10, ConnMaxLifetime: 5 * time.Minute},
+			wantErr: false,
+		},
+		{
+			name:        "negative maxOpenConns",
+			config:      PoolConfig{MaxOpenConns: -1},
+			wantErr:     true,
+			errContains: "plugins[0].pool.maxOpenConns",
+		},
+		{
+			name:        "maxIdleConns exceeds maxOpenConns",
+			config:      PoolConfig{MaxOpenConns: 5, MaxIdleConns: 10},
+			wantErr:     true,
+			errContains: "cannot exceed maxOpenConns",
+		},
+		{
+			name:        "negative connMaxLifetime",
+			config:      PoolConfig{ConnMaxLifetime: -time.Second},
+			wantErr:     true,
+			errContains: "plugins[0].pool.connMaxLifetime",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate("plugins[0].pool")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PoolConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("PoolConfig.Validate() error = %v, should contain %q", err, tt.errContains)
+			}
+		})
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "db", Pool: &PoolConfig{MaxIdleConns: -1}}},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "plugins[0].pool.maxIdleConns") {
+		t.Errorf("Config.Validate() error = %v, want pool validation error", err)
+	}
+}
+
+func TestFailoverConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      FailoverConfig
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:    "zero values keep defaults",
+			config:  FailoverConfig{},
+			wantErr: false,
+		},
+		{
+			name:    "valid settings",
+			config:  FailoverConfig{Interval: 5 * time.Second, FailureThreshold: 2, Timeout: time.Second},
+			wantErr: false,
+		},
+		{
+			name:        "negative interval",
+			config:      FailoverConfig{Interval: -time.Second},
+			wantErr:     true,
+			errContains: "plugins[0].failover.interval",
+		},
+		{
+			name:        "negative failureThreshold",
+			config:      FailoverConfig{FailureThreshold: -1},
+			wantErr:     true,
+			errContains: "plugins[0].failover.failureThreshold",
+		},
+		{
+			name:        "negative timeout",
+			config:      FailoverConfig{Timeout: -time.Second},
+			wantErr:     true,
+			errContains: "plugins[0].failover.timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate("plugins[0].failover")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FailoverConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil && !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("FailoverConfig.Validate() error = %v, should contain %q", err, tt.errContains)
+			}
+		})
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "db", Failover: &FailoverConfig{FailureThreshold: -1}}},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "plugins[0].failover.failureThreshold") {
+		t.Errorf("Config.Validate() error = %v, want failover validation error", err)
+	}
+}
+
+func TestWarmupConfigValidate(t *testing.T) {
+	valid := WarmupConfig{Enabled: true, PageSize: 500, Interval: 200 * time.Millisecond}
+	if err := valid.Validate("plugins[0].warmup"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "db", Warmup: &WarmupConfig{Enabled: true, PageSize: -1, Interval: -time.Second}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].warmup.pageSize", "plugins[0].warmup.interval"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestCircuitBreakerConfigValidate(t *testing.T) {
+	valid := CircuitBreakerConfig{FailureThreshold: 5, OpenDuration: 30 * time.Second}
+	if err := valid.Validate("plugins[0].circuitBreaker"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "db", CircuitBreaker: &CircuitBreakerConfig{FailureThreshold: -1, OpenDuration: -time.Second}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].circuitBreaker.failureThreshold", "plugins[0].circuitBreaker.openDuration"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestErrorBudgetConfigValidate(t *testing.T) {
+	valid := ErrorBudgetConfig{Objective: 99.5, Window: time.Hour, MinRequests: 100}
+	if err := valid.Validate("plugins[0].errorBudget"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{
+			{Name: "db", ErrorBudget: &ErrorBudgetConfig{Objective: 100, Window: -time.Hour, MinRequests: -1}},
+			{Name: "ldap", ErrorBudget: &ErrorBudgetConfig{TripBreaker: true}},
+		},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].errorBudget.objective",
+		"plugins[0].errorBudget.window",
+		"plugins[0].errorBudget.minRequests",
+		"plugins[1].errorBudget.tripBreaker",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestBulkConfigValidate(t *testing.T) {
+	valid := BulkConfig{MaxOperations: 100, MaxPayloadSize: 65536}
+	if err := valid.Validate("plugins[0].bulk"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "db", Bulk: &BulkConfig{MaxOperations: -1, MaxPayloadSize: -1}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].bulk.maxOperations", "plugins[0].bulk.maxPayloadSize"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestCacheConfigValidate(t *testing.T) {
+	valid := CacheConfig{TTL: 30 * time.Second, MaxEntries: 50000}
+	if err := valid.Validate("plugins[0].cache"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "ldap", Cache: &CacheConfig{TTL: -time.Second, MaxEntries: -1}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].cache.ttl", "plugins[0].cache.maxEntries"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestClientCacheConfigValidate(t *testing.T) {
+	valid := ClientCacheConfig{DiscoveryMaxAge: time.Hour, ResourceMaxAge: 30 * time.Second}
+	if err := valid.Validate("plugins[0].clientCache"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{Name: "ldap", ClientCache: &ClientCacheConfig{DiscoveryMaxAge: -time.Second, ResourceMaxAge: -time.Second}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].clientCache.discoveryMaxAge", "plugins[0].clientCache.resourceMaxAge"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestAuditConfigValidate(t *testing.T) {
+	valid := AuditConfig{MaxEvents: 50000}
+	if err := valid.Validate("plugins[0].audit"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{
+			Name:          "hr",
+			Audit:         &AuditConfig{MaxEvents: -1},
+			Authorization: &AuthorizationConfig{Grants: map[string][]string{"auditor": {"admin"}}},
+		}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "plugins[0].audit.maxEvents") {
+		t.Errorf("Config.Validate() error = %v, want plugins[0].audit.maxEvents error", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "authorization.grants") {
+		t.Errorf("Config.Validate() error = %v, want the admin operation granted", err)
+	}
+}
+
+func TestRecordingConfigValidate(t *testing.T) {
+	valid := RecordingConfig{MaxExchanges: 500, MaxBodySize: 65536, File: "/var/log/hr.jsonl"}
+	if err := valid.Validate("plugins[0].recording"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{
+			Name:      "hr",
+			Recording: &RecordingConfig{MaxExchanges: -1, MaxBodySize: -1},
+		}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{"plugins[0].recording.maxExchanges", "plugins[0].recording.maxBodySize"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestApprovalConfigValidate(t *testing.T) {
+	valid := ApprovalConfig{Attributes: map[string][]string{"Users": {"title"}}, Retention: time.Hour}
+	if err := valid.Validate("plugins[0].approval"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{
+			{Name: "hr", Approval: &ApprovalConfig{Attributes: map[string][]string{"Devices": {"name"}, "Users": {" "}}, Retention: -time.Hour}},
+			{Name: "ldap", Approval: &ApprovalConfig{}},
+		},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].approval.attributes.Devices",
+		"plugins[0].approval.attributes.Users[0]",
+		"plugins[0].approval.retention",
+		"plugins[1].approval.attributes",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestQuotaConfigValidate(t *testing.T) {
+	valid := QuotaConfig{MaxUsers: 500, WarnPercent: 80}
+	if err := valid.Validate("plugins[0].quota"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{
+			Name:         "hr",
+			Quota:        &QuotaConfig{MaxUsers: -1, MaxGroups: -1, WarnPercent: 120},
+			BaseEntities: []BaseEntityConfig{{Name: "acme", Quota: &QuotaConfig{MaxUsers: -5}}},
+		}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].quota.maxUsers",
+		"plugins[0].quota.maxGroups",
+		"plugins[0].quota.warnPercent",
+		"plugins[0].baseEntities[0].quota.maxUsers",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestWriteWindowConfigValidate(t *testing.T) {
+	valid := WriteWindowConfig{Schedule: []string{"* 22-23,0-5 * * 1-5", "* * * * 0,6"}, TimeZone: "UTC", Mode: WriteWindowQueue}
+	if err := valid.Validate("plugins[0].writeWindow"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{
+			{Name: "hr", WriteWindow: &WriteWindowConfig{Schedule: []string{"* 25 * * *"}, TimeZone: "Mars/Olympus", Mode: "defer"}},
+			{Name: "crm", WriteWindow: &WriteWindowConfig{}},
+		},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].writeWindow.schedule[0]",
+		"plugins[0].writeWindow.timeZone",
+		"plugins[0].writeWindow.mode",
+		"plugins[1].writeWindow.schedule",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestBaseEntitiesValidate(t *testing.T) {
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{{
+			Name: "hr",
+			BaseEntities: []BaseEntityConfig{
+				{Name: "acme", Auth: &AuthConfig{Type: "bearer", Bearer: &BearerAuth{Token: "secret"}}},
+				{Name: ""},
+				{Name: "acme"},
+				{Name: "Users"},
+				{Name: "a/b"},
+				{Name: "globex", Auth: &AuthConfig{Type: "kerberos"}},
+			},
+		}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].baseEntities[1].name",
+		"plugins[0].baseEntities[2].name",
+		"plugins[0].baseEntities[3].name",
+		"plugins[0].baseEntities[4].name",
+		"plugins[0].baseEntities[5].auth",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "baseEntities[0]") {
+		t.Errorf("Config.Validate() error = %v, want no error for a valid base entity", err)
+	}
+
+	cfg.Plugins[0].BaseEntities = []BaseEntityConfig{{Name: "acme", Auth: &AuthConfig{Type: "mtls"}}}
+	if !cfg.UsesMTLS() {
+		t.Error("UsesMTLS() = false for a base entity authenticating with mtls")
+	}
+}
+
+func TestAsyncWritesConfigValidate(t *testing.T) {
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost"},
+		Plugins: []PluginConfig{
+			{Name: "hr", AsyncWrites: &AsyncWritesConfig{MaxAttempts: -1, RetryDelay: -time.Second, Retention: -time.Hour}},
+			{Name: "crm", AsyncWrites: &AsyncWritesConfig{}},
+		},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"plugins[0].asyncWrites.maxAttempts",
+		"plugins[0].asyncWrites.retryDelay",
+		"plugins[0].asyncWrites.retention",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+	if err != nil && strings.Contains(err.Error(), "plugins[1]") {
+		t.Errorf("Config.Validate() error = %v, want no error for the default settings", err)
+	}
+}
+
+func TestHTTPClientConfigValidate(t *testing.T) {
+	valid := HTTPClientConfig{Timeout: 30 * time.Second, ProxyURL: "http://proxy.internal:3128", MaxIdleConnsPerHost: 20}
+	if err := valid.Validate("gateway.httpClient"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost", HTTPClient: &HTTPClientConfig{ProxyURL: "proxy.internal:3128"}},
+		Plugins: []PluginConfig{{Name: "hr", HTTPClient: &HTTPClientConfig{Timeout: -time.Second, CertFile: "client.pem", MaxIdleConnsPerHost: -1}}},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"gateway.httpClient.proxyURL",
+		"plugins[0].httpClient.timeout",
+		"plugins[0].httpClient.certFile",
+		"plugins[0].httpClient.maxIdleConnsPerHost",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestNormalizeConfigValidate(t *testing.T) {
+	valid := NormalizeConfig{TrimWhitespace: true, PhoneNumbers: PhoneFormatE164, DefaultCountryCode: "49"}
+	if err := valid.Validate("gateway.normalize"); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	cfg := &Config{
+		Gateway: GatewayConfig{BaseURL: "http://localhost", Normalize: &NormalizeConfig{PhoneNumbers: "national"}},
+		Plugins: []PluginConfig{
+			{Name: "hr", Normalize: &NormalizeConfig{PhoneNumbers: PhoneFormatE164, DefaultCountryCode: "049"}},
+			{Name: "crm", Normalize: &NormalizeConfig{DefaultCountryCode: "1"}},
+		},
+	}
+	err := cfg.Validate()
+	for _, field := range []string{
+		"gateway.normalize.phoneNumbers",
+		"plugins[0].normalize.defaultCountryCode",
+		"plugins[1].normalize.defaultCountryCode",
+	} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Config.Validate() error = %v, want %s error", err, field)
+		}
+	}
+}
+
+func TestHTTPClientConfigNewClient(t *testing.T) {
+	client, err := (*HTTPClientConfig)(nil).NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if client.Timeout != DefaultHTTPTimeout {
+		t.Errorf("Timeout = %s, want %s", client.Timeout, DefaultHTTPTimeout)
+	}
+
+	// The server's self-signed certificate is trusted through caFile
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("default client trusted a self-signed certificate")
+	}
+	client, err = (&HTTPClientConfig{CAFile: caFile, MaxIdleConnsPerHost: 20}).NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() with caFile error = %v", err)
+	}
+	resp.Body.Close() // nolint:errcheck
+	if got := client.Transport.(*http.Transport).MaxIdleConnsPerHost; got != 20 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 20", got)
+	}
+
+	proxied, err := (&HTTPClientConfig{ProxyURL: "http://proxy.internal:3128"}).NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	proxy, _ := proxied.Transport.(*http.Transport).Proxy(httptest.NewRequest("GET", "https://hr.example.com", nil))
+	if proxy == nil || proxy.Host != "proxy.internal:3128" {
+		t.Errorf("Proxy = %v, want proxy.internal:3128", proxy)
+	}
+
+	if _, err := (&HTTPClientConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).NewClient(); err == nil {
+		t.Error("NewClient() with a missing CA file should fail")
+	}
+}
+
+// nopConnector lets tests create a *sql.DB without a real driver
+type nopConnector struct{}
+
+func (nopConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestPoolConfigApply(t *testing.T) {
+	db := sql.OpenDB(nopConnector{})
+	defer db.Close() // nolint:errcheck
+
+	db.SetMaxOpenConns(10)
+	(&PoolConfig{MaxOpenConns: 50, MaxIdleConns: 5}).Apply(db)
+	if got := db.Stats().MaxOpenConnections; got != 50 {
+		t.Errorf("MaxOpenConnections = %d, want 50", got)
+	}
+
+	// Zero values leave existing settings untouched
+	(&PoolConfig{}).Apply(db)
+	if got := db.Stats().MaxOpenConnections; got != 50 {
+		t.Errorf("MaxOpenConnections = %d after empty Apply, want 50", got)
+	}
+}
+
 func TestDefaultConfigIsValid(t *testing.T) {
 	cfg := DefaultConfig()
 
@@ -454,3 +1463,18 @@ type mockAuthenticator struct{}
 func (m *mockAuthenticator) Authenticate(r *http.Request) error {
 	return nil
 }
+
+func TestConfig_UsesMTLS(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.UsesMTLS() {
+		t.Error("UsesMTLS() = true for default config")
+	}
+
+	cfg.Plugins = append(cfg.Plugins, PluginConfig{
+		Name: "okta",
+		Auth: &AuthConfig{Type: "mtls", MTLS: &MTLSAuth{CAFile: "ca.pem"}},
+	})
+	if !cfg.UsesMTLS() {
+		t.Error("UsesMTLS() = false with an mtls plugin")
+	}
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envVarPattern matches ${VAR_NAME} references in configuration values
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// LoadFile reads a gateway configuration from a YAML or JSON file.
+//
+// JSON documents are valid YAML, so both formats share the same keys:
+//
+//	gateway:
+//	  baseURL: https://scim.example.com
+//	  port: 8443
+//	plugins:
+//	  - name: ldap
+//	    auth:
+//	      type: bearer
+//	      bearer:
+//	        token: ${LDAP_SCIM_TOKEN}
+//	    config:
+//	      url: ldap://ldap.example.com
+//
+// References of the form ${VAR} in values are replaced with the environment
+// variable VAR; referencing an unset variable is an error. Unknown keys and
+// validation failures are reported as ValidationErrors carrying the file and
+// line of the offending key. Custom authenticators cannot be configured from
+// a file and must be set on the returned Config programmatically.
+//
+// Files of older layouts, as told by their version key (see CurrentVersion),
+// are migrated to the current one; the keys migrated are listed in
+// Config.Deprecations, which the gateway logs as warnings.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// An empty file has no content node; validation reports what is missing
+	root := &doc
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+
+	cfg := &Config{}
+
+	var errs ValidationErrors
+	errs = append(errs, expandEnv(root, "")...)
+	deprecations, verrs := migrateLayout(root)
+	errs = append(errs, verrs...)
+	if len(verrs) == 0 {
+		errs = append(errs, checkKnownFields(root, reflect.TypeOf(cfg).Elem(), "")...)
+	}
+	if len(errs) > 0 {
+		return nil, locate(errs, path, root)
+	}
+
+	if err := root.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Version = CurrentVersion
+	for _, d := range deprecations {
+		d.File = path
+		cfg.Deprecations = append(cfg.Deprecations, d)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, locate(err, path, root)
+	}
+	return cfg, nil
+}
+
+// expandEnv replaces ${VAR} references in scalar values in place
+func expandEnv(node *yaml.Node, field string) ValidationErrors {
+	var errs ValidationErrors
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if !envVarPattern.MatchString(node.Value) {
+			return nil
+		}
+		node.Value = envVarPattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			name := envVarPattern.FindStringSubmatch(ref)[1]
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				errs = append(errs, ValidationError{
+					Field:   field,
+					Message: fmt.Sprintf("environment variable %s is not set", name),
+					Line:    node.Line,
+				})
+			}
+			return value
+		})
+		// Re-resolve the type of plain scalars so ${PORT} can decode into an int
+		if node.Style == 0 {
+			node.Tag = ""
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, expandEnv(node.Content[i+1], joinField(field, node.Content[i].Value))...)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, expandEnv(item, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	}
+
+	return errs
+}
+
+// checkKnownFields reports mapping keys that do not correspond to a field of t
+func checkKnownFields(node *yaml.Node, t reflect.Type, field string) ValidationErrors {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var errs ValidationErrors
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := make(map[string]reflect.Type)
+		for i := range t.NumField() {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			keyField := joinField(field, key.Value)
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, ValidationError{
+					Field:   keyField,
+					Message: fmt.Sprintf("unknown field '%s'", key.Value),
+					Line:    key.Line,
+				})
+				continue
+			}
+			errs = append(errs, checkKnownFields(node.Content[i+1], fieldType, keyField)...)
+		}
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			errs = append(errs, checkKnownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", field, i))...)
+		}
+	}
+
+	return errs
+}
+
+// locate annotates validation errors with the file and line of their field.
+// Fields missing from the file are reported at their closest existing parent.
+func locate(err error, path string, root *yaml.Node) error {
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	located := make(ValidationErrors, len(verrs))
+	for i, verr := range verrs {
+		verr.File = path
+		if verr.Line == 0 {
+			verr.Line = lineOf(root, verr.Field)
+		}
+		located[i] = verr
+	}
+	return located
+}
+
+// lineOf returns the line of the deepest node along a field path such as
+// "plugins[0].auth.basic.username"
+func lineOf(node *yaml.Node, field string) int {
+	line := node.Line
+	if field == "" {
+		return line
+	}
+
+	for _, segment := range strings.Split(field, ".") {
+		key, indexes := splitIndexes(segment)
+
+		if node.Kind != yaml.MappingNode {
+			return line
+		}
+		var found bool
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				line = node.Content[i].Line
+				node = node.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return line
+		}
+
+		for _, index := range indexes {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return line
+			}
+			node = node.Content[index]
+			line = node.Line
+		}
+	}
+
+	return line
+}
+
+// splitIndexes splits a field segment like "plugins[0]" into its key and indexes
+func splitIndexes(segment string) (string, []int) {
+	key, rest, _ := strings.Cut(segment, "[")
+	var indexes []int
+	for rest != "" {
+		var index string
+		index, rest, _ = strings.Cut(rest, "]")
+		if n, err := strconv.Atoi(index); err == nil {
+			indexes = append(indexes, n)
+		}
+		rest = strings.TrimPrefix(rest, "[")
+	}
+	return key, indexes
+}
+
+// joinField appends a key to a dotted field path
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
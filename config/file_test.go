@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFileYAML(t *testing.T) {
+	t.Setenv("SCIM_TOKEN", "s3cr3t")
+	t.Setenv("SCIM_PORT", "8443")
+
+	path := writeConfigFile(t, "config.yaml", `
+gateway:
+  baseURL: https://scim.example.com
+  port: ${SCIM_PORT}
+  tls:
+    enabled: true
+    certFile: /etc/scim/tls.crt
+    keyFile: /etc/scim/tls.key
+plugins:
+  - name: ldap
+    membershipSync: true
+    auth:
+      type: bearer
+      bearer:
+        token: ${SCIM_TOKEN}
+        tokens:
+          - label: tenant-b
+            token: next-token
+            expiresAt: 2030-01-01T00:00:00Z
+    config:
+      url: ldap://ldap.example.com
+      poolSize: 5
+  - name: memory
+    pool:
+      maxOpenConns: 50
+      maxIdleConns: 10
+      connMaxLifetime: 5m
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	if cfg.Gateway.BaseURL != "https://scim.example.com" || cfg.Gateway.Port != 8443 {
+		t.Errorf("Gateway = %+v", cfg.Gateway)
+	}
+	if cfg.Gateway.TLS == nil || !cfg.Gateway.TLS.Enabled || cfg.Gateway.TLS.CertFile != "/etc/scim/tls.crt" {
+		t.Errorf("Gateway.TLS = %+v", cfg.Gateway.TLS)
+	}
+	if len(cfg.Plugins) != 2 {
+		t.Fatalf("len(Plugins) = %d, want 2", len(cfg.Plugins))
+	}
+
+	ldap := cfg.Plugins[0]
+	if ldap.Name != "ldap" || !ldap.MembershipSync {
+		t.Errorf("Plugins[0] = %+v", ldap)
+	}
+	if ldap.Auth == nil || ldap.Auth.Bearer == nil || ldap.Auth.Bearer.Token != "s3cr3t" {
+		t.Errorf("Plugins[0].Auth = %+v", ldap.Auth)
+	}
+	if tokens := ldap.Auth.Bearer.Tokens; len(tokens) != 1 || tokens[0].Label != "tenant-b" || !tokens[0].ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Plugins[0].Auth.Bearer.Tokens = %+v", ldap.Auth.Bearer.Tokens)
+	}
+	if ldap.Config["url"] != "ldap://ldap.example.com" || ldap.Config["poolSize"] != 5 {
+		t.Errorf("Plugins[0].Config = %v", ldap.Config)
+	}
+	if cfg.Plugins[1].Name != "memory" {
+		t.Errorf("Plugins[1].Name = %q, want memory", cfg.Plugins[1].Name)
+	}
+	pool := cfg.Plugins[1].Pool
+	if pool == nil || pool.MaxOpenConns != 50 || pool.MaxIdleConns != 10 || pool.ConnMaxLifetime != 5*time.Minute {
+		t.Errorf("Plugins[1].Pool = %+v", pool)
+	}
+}
+
+func TestLoadFileJSON(t *testing.T) {
+	t.Setenv("SCIM_PASSWORD", "p@ss: #word")
+
+	path := writeConfigFile(t, "config.json", `{
+	"gateway": {"baseURL": "http://localhost:8080", "port": 8080},
+	"plugins": [
+		{
+			"name": "db",
+			"auth": {"type": "basic", "basic": {"username": "admin", "password": "${SCIM_PASSWORD}"}}
+		}
+	]
+}`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	if cfg.Gateway.Port != 8080 {
+		t.Errorf("Gateway.Port = %d, want 8080", cfg.Gateway.Port)
+	}
+	// Expanded values are not reparsed as YAML
+	if got := cfg.Plugins[0].Auth.Basic.Password; got != "p@ss: #word" {
+		t.Errorf("Basic.Password = %q, want %q", got, "p@ss: #word")
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantField   string
+		wantLine    int
+		errContains string
+	}{
+		{
+			name: "validation error",
+			content: `gateway:
+  baseURL: ftp://localhost
+plugins:
+  - name: test
+`,
+			wantField:   "gateway.baseURL",
+			wantLine:    2,
+			errContains: "invalid URL scheme",
+		},
+		{
+			name: "missing field reported at parent",
+			content: `gateway:
+  baseURL: http://localhost
+plugins:
+  - name: test
+    auth:
+      type: basic
+      basic:
+        username: admin
+`,
+			wantField:   "plugins[0].auth.basic.password",
+			wantLine:    7,
+			errContains: "password cannot be empty",
+		},
+		{
+			name: "duplicate plugin name",
+			content: `gateway:
+  baseURL: http://localhost
+plugins:
+  - name: test
+  - name: test
+`,
+			wantField:   "plugins[1].name",
+			wantLine:    5,
+			errContains: "duplicate plugin name",
+		},
+		{
+			name: "unknown field",
+			content: `gateway:
+  baseURL: http://localhost
+  prot: 8080
+plugins:
+  - name: test
+`,
+			wantField:   "gateway.prot",
+			wantLine:    3,
+			errContains: "unknown field 'prot'",
+		},
+		{
+			name: "unset environment variable",
+			content: `gateway:
+  baseURL: http://localhost
+plugins:
+  - name: test
+    auth:
+      type: bearer
+      bearer:
+        token: ${SCIM_LOAD_FILE_UNSET}
+`,
+			wantField:   "plugins[0].auth.bearer.token",
+			wantLine:    8,
+			errContains: "SCIM_LOAD_FILE_UNSET is not set",
+		},
+		{
+			name:        "empty file",
+			content:     "",
+			wantField:   "gateway.baseURL",
+			errContains: "cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", tt.content)
+
+			_, err := LoadFile(path)
+			if err == nil {
+				t.Fatal("LoadFile() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("LoadFile() error = %v, want to contain %q", err, tt.errContains)
+			}
+
+			var verrs ValidationErrors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("LoadFile() error type = %T, want ValidationErrors", err)
+			}
+
+			var found bool
+			for _, verr := range verrs {
+				if verr.Field != tt.wantField {
+					continue
+				}
+				found = true
+				if verr.File != path || verr.Line != tt.wantLine {
+					t.Errorf("%s located at %s:%d, want %s:%d", verr.Field, verr.File, verr.Line, path, tt.wantLine)
+				}
+			}
+			if !found {
+				t.Errorf("LoadFile() errors = %v, want error for field %s", verrs, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestLoadFileSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{"malformed YAML", "gateway: [unclosed\n", "line 1"},
+		{"type mismatch", "gateway:\n  port: abc\nplugins:\n  - name: test\n", "line 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", tt.content)
+
+			_, err := LoadFile(path)
+			if err == nil {
+				t.Fatal("LoadFile() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("LoadFile() error = %v, want file path and %q", err, tt.errContains)
+			}
+		})
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadFile() missing file error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestValidationErrorLocation(t *testing.T) {
+	err := &ValidationError{Field: "gateway.port", Message: "out of range", File: "gateway.yaml", Line: 3}
+	want := "gateway.yaml:3: config validation error [gateway.port]: out of range"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
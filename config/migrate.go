@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the version of the configuration file layout of this
+// release. LoadFile upgrades files of older versions to it; files without a
+// version are version 0.
+const CurrentVersion = 1
+
+// Deprecation reports a key of a configuration file written in an older
+// layout, which LoadFile migrated to the current one. The file keeps
+// loading, but should be updated, e.g. before a release stops migrating the
+// layout.
+type Deprecation struct {
+	File string
+	Line int
+
+	// Field is the path of the deprecated key, e.g. "gateway.baseurl"
+	Field string
+
+	// Message tells where the setting moved, e.g. "renamed to gateway.baseURL"
+	Message string
+}
+
+// String formats the deprecation with its location, like ValidationError
+func (d Deprecation) String() string {
+	return fmt.Sprintf("%s:%d: %s is deprecated: %s", d.File, d.Line, d.Field, d.Message)
+}
+
+// migration upgrades a configuration document from version to version+1 in
+// place, and returns the deprecated keys it migrated
+type migration struct {
+	version int
+	migrate func(root *yaml.Node) []Deprecation
+}
+
+// migrations upgrade the layouts of older versions, in order. A change to
+// the layout adds a migration and increments CurrentVersion.
+var migrations = []migration{
+	{version: 0, migrate: migrateUnversioned},
+}
+
+// migrateLayout upgrades the document root, whose layout is that of its
+// version key, to CurrentVersion
+func migrateLayout(root *yaml.Node) ([]Deprecation, ValidationErrors) {
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	version := 0
+	if node := mappingValue(root, "version"); node != nil {
+		if err := node.Decode(&version); err != nil {
+			return nil, ValidationErrors{{Field: "version", Message: "version must be an integer", Line: node.Line}}
+		}
+		if version < 0 || version > CurrentVersion {
+			return nil, ValidationErrors{{
+				Field:   "version",
+				Message: fmt.Sprintf("version %d is not supported: this release reads versions up to %d", version, CurrentVersion),
+				Line:    node.Line,
+			}}
+		}
+	}
+
+	var deprecations []Deprecation
+	for _, m := range migrations {
+		if m.version >= version {
+			deprecations = append(deprecations, m.migrate(root)...)
+		}
+	}
+	return deprecations, nil
+}
+
+// migrateUnversioned upgrades files written before the layout was versioned:
+// the lower-case keys of configurations decoded without YAML tags
+func migrateUnversioned(root *yaml.Node) []Deprecation {
+	var deprecations []Deprecation
+
+	if gateway := mappingValue(root, "gateway"); gateway != nil {
+		deprecations = append(deprecations, renameKey(gateway, "gateway", "baseurl", "baseURL")...)
+		if tls := mappingValue(gateway, "tls"); tls != nil {
+			deprecations = append(deprecations, renameKey(tls, "gateway.tls", "certfile", "certFile")...)
+			deprecations = append(deprecations, renameKey(tls, "gateway.tls", "keyfile", "keyFile")...)
+		}
+	}
+	return deprecations
+}
+
+// renameKey renames the key from of the mapping node at field to to, unless
+// to is set as well, which is then reported as an unknown field
+func renameKey(node *yaml.Node, field, from, to string) []Deprecation {
+	i := mappingIndex(node, from)
+	if i < 0 || mappingIndex(node, to) >= 0 {
+		return nil
+	}
+	key := node.Content[i]
+	key.Value = to
+	return []Deprecation{{
+		Line:    key.Line,
+		Field:   joinField(field, from),
+		Message: "renamed to " + joinField(field, to),
+	}}
+}
+
+// mappingIndex returns the index of key in the content of a mapping node,
+// or -1 if node is not a mapping or has no such key
+func mappingIndex(node *yaml.Node, key string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if i := mappingIndex(node, key); i >= 0 {
+		return node.Content[i+1]
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadFileMigratesUnversioned(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+gateway:
+  baseurl: https://scim.example.com
+  port: 8443
+  tls:
+    enabled: false
+    certfile: /etc/scim/tls.crt
+    keyfile: /etc/scim/tls.key
+plugins:
+  - name: ldap
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if cfg.Version != CurrentVersion {
+		t.Errorf("Version = %d, want %d", cfg.Version, CurrentVersion)
+	}
+	if cfg.Gateway.BaseURL != "https://scim.example.com" || cfg.Gateway.TLS.CertFile != "/etc/scim/tls.crt" || cfg.Gateway.TLS.KeyFile != "/etc/scim/tls.key" {
+		t.Errorf("Gateway = %+v, TLS = %+v", cfg.Gateway, cfg.Gateway.TLS)
+	}
+
+	var got []string
+	for _, d := range cfg.Deprecations {
+		got = append(got, d.String())
+	}
+	want := []string{
+		path + ":3: gateway.baseurl is deprecated: renamed to gateway.baseURL",
+		path + ":7: gateway.tls.certfile is deprecated: renamed to gateway.tls.certFile",
+		path + ":8: gateway.tls.keyfile is deprecated: renamed to gateway.tls.keyFile",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Deprecations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadFileVersioned(t *testing.T) {
+	// The current layout loads without deprecations
+	path := writeConfigFile(t, "current.yaml", `
+version: 1
+gateway:
+  baseURL: https://scim.example.com
+  port: 8443
+plugins:
+  - name: ldap
+`)
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if cfg.Version != 1 || len(cfg.Deprecations) != 0 {
+		t.Errorf("Version = %d, Deprecations = %v, want 1 and none", cfg.Version, cfg.Deprecations)
+	}
+
+	// Versioned files are not migrated
+	path = writeConfigFile(t, "old-keys.yaml", `
+version: 1
+gateway:
+  baseurl: https://scim.example.com
+  port: 8443
+plugins:
+  - name: ldap
+`)
+	if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), "unknown field 'baseurl'") {
+		t.Errorf("LoadFile() old keys in version 1 error = %v, want unknown field", err)
+	}
+
+	// Newer layouts are rejected
+	path = writeConfigFile(t, "newer.yaml", `
+version: 7
+gateway:
+  baseURL: https://scim.example.com
+  port: 8443
+plugins:
+  - name: ldap
+`)
+	_, err = LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), path+":2:") || !strings.Contains(err.Error(), "version 7 is not supported") {
+		t.Errorf("LoadFile() newer version error = %v, want version 7 rejected at line 2", err)
+	}
+}
+
+func TestConfigValidateVersion(t *testing.T) {
+	cfg := &Config{
+		Version: CurrentVersion + 1,
+		Gateway: GatewayConfig{BaseURL: "http://localhost", Port: 8080},
+		Plugins: []PluginConfig{{Name: "hr"}},
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "[version]") {
+		t.Errorf("Validate() error = %v, want version error", err)
+	}
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the values of credentials in redacted configurations
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of plugin config map keys holding credentials
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "authorization", "credential", "privatekey", "private_key"}
+
+// authKeys are the keys of an auth settings map that are not credentials,
+// e.g. restproxy's auth.type and auth.header; its other keys are redacted
+var authKeys = map[string]bool{"type": true, "header": true, "username": true}
+
+// dsnPassword matches the password of a user:password@ DSN prefix, the
+// format of MySQL DSNs
+var dsnPassword = regexp.MustCompile(`^([^:@/]+):([^@]*)@`)
+
+// Redact returns a copy of v with credentials replaced by Redacted, for
+// writing configurations to logs, error messages and HTTP responses. It
+// redacts
+//   - struct fields tagged `redact:"true"`, such as BasicAuth.Password
+//   - values of map keys naming a credential, such as the token of a plugin's
+//     config map
+//   - passwords of URLs and DSNs, keeping the rest of the string readable
+//
+// v itself is left unchanged. Interface values that are not maps or plain
+// values, such as a custom authenticator, are copied as is.
+func Redact[T any](v T) T {
+	value := reflect.ValueOf(&v).Elem()
+	redacted := reflect.New(value.Type()).Elem()
+	redacted.Set(redactValue(value, ""))
+	return redacted.Interface().(T)
+}
+
+// Redact returns a copy of c with credentials replaced by Redacted
+func (c *Config) Redact() *Config {
+	return Redact(c)
+}
+
+// LogValue implements slog.LogValuer, logging the redacted configuration
+// with the keys of configuration files
+func (c *Config) LogValue() slog.Value {
+	return slog.AnyValue(settings(c.Redact()))
+}
+
+// String formats the redacted configuration as YAML
+func (c *Config) String() string {
+	return yamlString(c.Redact())
+}
+
+// Redact returns a copy of p with credentials replaced by Redacted
+func (p *PluginConfig) Redact() *PluginConfig {
+	return Redact(p)
+}
+
+// LogValue implements slog.LogValuer, logging the redacted configuration
+// with the keys of configuration files
+func (p *PluginConfig) LogValue() slog.Value {
+	return slog.AnyValue(settings(p.Redact()))
+}
+
+// String formats the redacted configuration as YAML
+func (p *PluginConfig) String() string {
+	return yamlString(p.Redact())
+}
+
+// settings converts a configuration to the settings maps of its YAML
+// encoding, so logs show the keys of configuration files
+func settings(v any) any {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("!(%v)", err)
+	}
+	var out map[string]any
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return fmt.Sprintf("!(%v)", err)
+	}
+	return out
+}
+
+// yamlString encodes a configuration as YAML
+func yamlString(v any) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("!(%v)", err)
+	}
+	return string(data)
+}
+
+// redactValue returns a redacted deep copy of v. key is the map key v is
+// stored under, empty outside of maps.
+func redactValue(v reflect.Value, key string) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(redactValue(v.Elem(), key))
+		return copied
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		inner := v.Elem()
+		switch inner.Kind() {
+		case reflect.Map, reflect.Slice, reflect.String:
+			copied := reflect.New(v.Type()).Elem()
+			copied.Set(redactValue(inner, key))
+			return copied
+		}
+		return v
+
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get("redact") == "true" {
+				if field.Type.Kind() == reflect.String && v.Field(i).Len() > 0 {
+					copied.Field(i).SetString(Redacted)
+				}
+				continue
+			}
+			copied.Field(i).Set(redactValue(v.Field(i), ""))
+		}
+		return copied
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			copied.Index(i).Set(redactValue(v.Index(i), key))
+		}
+		return copied
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			value := iter.Value()
+			if sensitiveKey(name) || (strings.EqualFold(key, "auth") && !authKeys[strings.ToLower(name)]) {
+				value = redactedValue(value)
+			} else {
+				value = redactValue(value, name)
+			}
+			copied.SetMapIndex(iter.Key(), value)
+		}
+		return copied
+
+	case reflect.String:
+		copied := reflect.New(v.Type()).Elem()
+		copied.SetString(redactString(v.String()))
+		return copied
+	}
+	return v
+}
+
+// redactedValue returns Redacted in place of the map value v, keeping nested
+// settings maps so their structure stays visible
+func redactedValue(v reflect.Value) reflect.Value {
+	inner := v
+	if inner.Kind() == reflect.Interface && !inner.IsNil() {
+		inner = inner.Elem()
+	}
+	switch inner.Kind() {
+	case reflect.Map:
+		copied := reflect.MakeMapWithSize(inner.Type(), inner.Len())
+		iter := inner.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), redactedValue(iter.Value()))
+		}
+		return copied
+	case reflect.String:
+		if inner.Len() == 0 {
+			return v
+		}
+	}
+	if reflect.TypeOf(Redacted).AssignableTo(v.Type()) {
+		return reflect.ValueOf(Redacted)
+	}
+	return reflect.Zero(v.Type())
+}
+
+// sensitiveKey reports whether a config map key names a credential
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString redacts the password of a URL or a user:password@ DSN
+func redactString(s string) string {
+	u, err := url.Parse(s)
+	if err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	// user:password@tcp(host)/db parses as an opaque URL with scheme "user"
+	if err != nil || u.Opaque != "" {
+		return dsnPassword.ReplaceAllString(s, "${1}:xxxxx@")
+	}
+	return s
+}
@@ -0,0 +1,191 @@
+// Package cron parses cron expressions, the schedules of the gateway's
+// time-based settings such as the write windows of plugins.
+//
+// An expression has five fields separated by spaces, matched against the
+// minute, hour, day of month, month and day of week of a time:
+//
+//	┌───────────── minute (0-59)
+//	│ ┌─────────── hour (0-23)
+//	│ │ ┌───────── day of month (1-31)
+//	│ │ │ ┌─────── month (1-12)
+//	│ │ │ │ ┌───── day of week (0-6, Sunday is 0 or 7)
+//	│ │ │ │ │
+//	* 22-23,0-5 * * 1-5
+//
+// Fields are lists of values (5), ranges (1-5) and wildcards (*), each with
+// an optional step (*/15, 0-30/10). Like in Vixie cron, a time matches when
+// either the day of month or the day of week matches if both are restricted.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field describes one field of an expression
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Schedule is a parsed cron expression
+type Schedule struct {
+	expr    string
+	minutes uint64
+	hours   uint64
+	days    uint64
+	months  uint64
+	weekday uint64
+
+	// whether the day of month and day of week fields are restricted, i.e.
+	// do not start with a wildcard
+	daysRestricted    bool
+	weekdayRestricted bool
+}
+
+// Parse parses a cron expression
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expr, len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &Schedule{
+		expr:              expr,
+		minutes:           sets[0],
+		hours:             sets[1],
+		days:              sets[2],
+		months:            sets[3],
+		weekday:           sets[4],
+		daysRestricted:    !strings.HasPrefix(parts[2], "*"),
+		weekdayRestricted: !strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma separated list of values, ranges and wildcards
+// into the set of values it matches
+func parseField(s string, f field) (uint64, error) {
+	var set uint64
+	for item := range strings.SplitSeq(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+		case strings.Contains(rng, "-"):
+			from, to, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(from, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(to, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rng, f); err != nil {
+				return 0, err
+			}
+			if !hasStep {
+				hi = lo
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a single value of field f
+func parseValue(s string, f field) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, must be %d-%d", s, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Matches reports whether the minute of t matches the schedule
+func (s *Schedule) Matches(t time.Time) bool {
+	return s.minutes&(1<<t.Minute()) != 0 &&
+		s.hours&(1<<t.Hour()) != 0 &&
+		s.months&(1<<int(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+// matchesDay reports whether the day of t matches the day of month and day
+// of week fields
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<t.Day()) != 0
+	weekday := s.weekday&(1<<int(t.Weekday())) != 0
+	if s.daysRestricted && s.weekdayRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Next returns the start of the first minute after t matching the schedule,
+// in the location of t, or the zero time if none does within five years,
+// e.g. for February 30
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hours&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minutes&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
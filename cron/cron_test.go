@@ -0,0 +1,93 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "must have 5 fields"},
+		{"60 * * * *", `invalid value "60" in minute field`},
+		{"* 5-2 * * *", `invalid range "5-2" in hour field`},
+		{"*/0 * * * *", `invalid step "0"`},
+		{"* * 0 * *", "day of month field, must be 1-31"},
+		{"* * * * mon", `invalid value "mon" in day of week field`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	// Wednesday, 15 January 2025
+	wednesday := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 15, hour, minute, 30, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"* * * * *", wednesday(12, 0), true},
+		{"* 22-23,0-5 * * *", wednesday(23, 59), true},
+		{"* 22-23,0-5 * * *", wednesday(6, 0), false},
+		{"*/15 * * * *", wednesday(9, 45), true},
+		{"*/15 * * * *", wednesday(9, 46), false},
+		{"0-30/10 * * * *", wednesday(9, 20), true},
+		{"* * * * 1-5", wednesday(12, 0), true},
+		{"* * * * 0,6", wednesday(12, 0), false},
+		{"* * * * 7", time.Date(2025, 1, 19, 12, 0, 0, 0, time.UTC), true}, // Sunday
+		{"* * * 2 *", wednesday(12, 0), false},
+		// Restricted day of month and day of week match either
+		{"* * 1 * 3", wednesday(12, 0), true},
+		{"* * 1 * 4", wednesday(12, 0), false},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Matches(tt.t); got != tt.want {
+			t.Errorf("%q.Matches(%v) = %v, want %v", tt.expr, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 9, 0, 30, 0, time.UTC), time.Date(2025, 1, 15, 9, 1, 0, 0, time.UTC)},
+		{"* 22-23 * * *", time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC), time.Date(2025, 1, 15, 22, 0, 0, 0, time.UTC)},
+		// Friday evening to Monday morning
+		{"0 6 * * 1-5", time.Date(2025, 1, 17, 18, 0, 0, 0, time.UTC), time.Date(2025, 1, 20, 6, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+		{"0 22 * * *", time.Date(2025, 6, 1, 12, 0, 0, 0, berlin), time.Date(2025, 6, 1, 22, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package scimgateway
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/plugin"
+)
+
+// errorBudgetStatus is the body of GET /{plugin}/_errorBudget
+type errorBudgetStatus struct {
+	plugin.ErrorBudgetStatus
+	Window string `json:"window"`
+}
+
+// ErrorBudgetMiddleware serves the error budget of plugins configured with
+// errorBudget: GET /{plugin}/_errorBudget reports the objective, the calls
+// and failures counted over the window, the success rate, the share of the
+// budget left and whether it is exhausted. It is placed behind the plugin's
+// authentication, and authorization grants it with the admin operation.
+// Other requests, and requests to plugins without an error budget, are
+// passed to next.
+func ErrorBudgetMiddleware(manager *plugin.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if endpoint != "_errorBudget" || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			budget, ok := manager.GetErrorBudget(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := budget.Status()
+			writeAdminJSON(w, errorBudgetStatus{ErrorBudgetStatus: status, Window: status.Window.String()})
+		})
+	}
+}
+
+// registerErrorBudgets registers the scimgateway_error_budget_success_rate,
+// scimgateway_error_budget_remaining and scimgateway_error_budget_exhausted
+// gauges of every plugin with an error budget. The budgets are looked up at
+// scrape time, so gauges follow budgets recreated on reload.
+func (g *Gateway) registerErrorBudgets() {
+	for _, name := range g.pluginManager.List() {
+		if _, ok := g.pluginManager.GetErrorBudget(name); !ok {
+			continue
+		}
+		status := func() (plugin.ErrorBudgetStatus, bool) {
+			budget, ok := g.pluginManager.GetErrorBudget(name)
+			if !ok {
+				return plugin.ErrorBudgetStatus{}, false
+			}
+			return budget.Status(), true
+		}
+		labels := metrics.Labels{"plugin": name}
+
+		g.metrics.GaugeFunc("scimgateway_error_budget_success_rate",
+			"Percentage of the calls to a plugin's backend that succeeded over the error budget window.",
+			labels,
+			func() float64 {
+				s, ok := status()
+				if !ok {
+					return 100
+				}
+				return s.SuccessRate
+			},
+		)
+		g.metrics.GaugeFunc("scimgateway_error_budget_remaining",
+			"Share of the error budget of a plugin left over its window: 1 unused, 0 or less used up.",
+			labels,
+			func() float64 {
+				s, ok := status()
+				if !ok {
+					return 1
+				}
+				return s.Remaining
+			},
+		)
+		g.metrics.GaugeFunc("scimgateway_error_budget_exhausted",
+			"Whether the error budget of a plugin is used up (1) or not (0).",
+			labels,
+			func() float64 {
+				if s, ok := status(); ok && s.Exhausted {
+					return 1
+				}
+				return 0
+			},
+		)
+	}
+}
@@ -0,0 +1,69 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/metrics"
+)
+
+func TestErrorBudget(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].ErrorBudget = &config.ErrorBudgetConfig{Objective: 90, MinRequests: 2}
+	gw := New(cfg)
+	gw.RegisterPlugin(groupsDownPlugin{testutil.NewMemoryPlugin("test")})
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	get("/test/Users")
+	get("/test/Users")
+	get("/test/Groups")
+	get("/test/Users")
+
+	w := get("/test/_errorBudget")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var status struct {
+		Objective   float64 `json:"objective"`
+		Window      string  `json:"window"`
+		Requests    int     `json:"requests"`
+		Failures    int     `json:"failures"`
+		SuccessRate float64 `json:"successRate"`
+		Remaining   float64 `json:"remaining"`
+		Exhausted   bool    `json:"exhausted"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Objective != 90 || status.Window != "1h0m0s" || status.Requests != 4 || status.Failures != 1 || status.SuccessRate != 75 || !status.Exhausted {
+		t.Errorf("error budget = %+v, want 1 failure in 4 calls exhausting a 90%% objective", status)
+	}
+
+	labels := metrics.Labels{"plugin": "test"}
+	successRate, _ := gw.Metrics().Value("scimgateway_error_budget_success_rate", labels)
+	exhausted, _ := gw.Metrics().Value("scimgateway_error_budget_exhausted", labels)
+	if successRate != 75 || exhausted != 1 {
+		t.Errorf("error budget metrics = success rate %v, exhausted %v; want 75, 1", successRate, exhausted)
+	}
+
+	// Plugins without an error budget do not serve it
+	gw.PluginManager().UpdateConfig("test", &config.PluginConfig{Name: "test", Auth: cfg.Plugins[0].Auth})
+	if w := get("/test/_errorBudget"); w.Code != http.StatusNotFound {
+		t.Errorf("status without errorBudget = %d, want 404", w.Code)
+	}
+}
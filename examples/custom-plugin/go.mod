@@ -1,9 +1,13 @@
 module github.com/marcelom97/scimgateway/examples/custom-plugin
 
-go 1.25
+go 1.25.0
 
 replace github.com/marcelom97/scimgateway => ../..
 
 require github.com/marcelom97/scimgateway v0.0.0-00010101000000-000000000000
 
-require github.com/google/uuid v1.6.0 // indirect
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -23,7 +23,7 @@ func (p *CustomPlugin) Name() string {
 
 func (p *CustomPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
 	// Return hardcoded users for demonstration
-	// The adapter layer will handle filtering, pagination, and attribute selection
+	// The gateway's plugin.Adapter handles filtering, sorting, pagination and attribute selection
 	users := []*scim.User{
 		{
 			ID:       "1",
@@ -60,7 +60,7 @@ func (p *CustomPlugin) DeleteUser(ctx context.Context, id string) error {
 
 func (p *CustomPlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
 	// Return empty slice for demonstration
-	// The adapter layer will handle filtering, pagination, and attribute selection
+	// The gateway's plugin.Adapter handles filtering, sorting, pagination and attribute selection
 	return []*scim.Group{}, nil
 }
 
@@ -9,3 +9,8 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/marcelom97/scimgateway v0.0.0-00010101000000-000000000000
 )
+
+require (
+	golang.org/x/text v0.40.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -7,4 +7,9 @@ require (
 	github.com/marcelom97/scimgateway v0.2.3
 )
 
+require (
+	golang.org/x/text v0.40.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
 replace github.com/marcelom97/scimgateway => ../..
@@ -0,0 +1,52 @@
+package main
+
+import "slices"
+
+// index stores resources by ID and keeps them in creation order, so listings
+// are deterministic: paging through a listing with startIndex neither skips
+// nor repeats resources when others are created meanwhile, since new
+// resources are appended after every page already read. Deleting resources
+// still shifts the following pages, as with any offset-based pagination.
+type index[T any] struct {
+	byID  map[string]T
+	order []string // IDs in creation order
+}
+
+// newIndex creates an empty index
+func newIndex[T any]() *index[T] {
+	return &index[T]{byID: make(map[string]T)}
+}
+
+// get returns the resource with the given ID
+func (i *index[T]) get(id string) (T, bool) {
+	resource, ok := i.byID[id]
+	return resource, ok
+}
+
+// put stores a resource. A new ID is appended to the order; a resource
+// replacing an existing one keeps its position.
+func (i *index[T]) put(id string, resource T) {
+	if _, ok := i.byID[id]; !ok {
+		i.order = append(i.order, id)
+	}
+	i.byID[id] = resource
+}
+
+// remove deletes the resource with the given ID and reports whether it existed
+func (i *index[T]) remove(id string) bool {
+	if _, ok := i.byID[id]; !ok {
+		return false
+	}
+	delete(i.byID, id)
+	i.order = slices.DeleteFunc(i.order, func(existing string) bool { return existing == id })
+	return true
+}
+
+// list returns the resources in creation order
+func (i *index[T]) list() []T {
+	resources := make([]T, 0, len(i.order))
+	for _, id := range i.order {
+		resources = append(resources, i.byID[id])
+	}
+	return resources
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"log/slog"
 	"os"
 
 	"github.com/marcelom97/scimgateway"
 	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scimgen"
 )
 
 func main() {
+	seedUsers := flag.Int("seed", 0, "number of random users to start with, plus a group per 10 users")
+	flag.Parse()
+
 	// Create configuration programmatically with per-plugin authentication
 	cfg := &config.Config{
 		Gateway: config.GatewayConfig{
@@ -41,7 +47,15 @@ func main() {
 	gw.SetLogger(logger)
 	log.Printf("Structured logging enabled")
 
-	gw.RegisterPlugin(New("memory"))
+	p := New("memory")
+	if *seedUsers > 0 {
+		if err := seed(p, *seedUsers); err != nil {
+			log.Fatalf("Failed to seed users: %v", err)
+		}
+		log.Printf("Seeded %d users and %d groups", *seedUsers, *seedUsers/10)
+	}
+
+	gw.RegisterPlugin(p)
 	log.Printf("Registered plugin: memory with basic auth")
 
 	// Initialize gateway
@@ -61,3 +75,23 @@ func main() {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+// seed fills the plugin with n random users with enterprise attributes and
+// a group of up to 25 of them per 10 users
+func seed(p *Plugin, n int) error {
+	ctx := context.Background()
+	gen := scimgen.New(scimgen.Options{Seed: 1, Enterprise: true, MaxMembers: 25})
+
+	users := gen.Users(n)
+	for _, user := range users {
+		if _, err := p.CreateUser(ctx, user); err != nil {
+			return err
+		}
+	}
+	for _, group := range gen.Groups(n/10, users) {
+		if _, err := p.CreateGroup(ctx, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,17 +4,17 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"time"
 
 	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/scim"
 )
 
 // Plugin implements an in-memory SCIM plugin
 type Plugin struct {
 	name   string
-	users  map[string]*scim.User
-	groups map[string]*scim.Group
+	users  *index[*scim.User]
+	groups *index[*scim.Group]
 	mu     sync.RWMutex
 }
 
@@ -22,8 +22,8 @@ type Plugin struct {
 func New(name string) *Plugin {
 	return &Plugin{
 		name:   name,
-		users:  make(map[string]*scim.User),
-		groups: make(map[string]*scim.Group),
+		users:  newIndex[*scim.User](),
+		groups: newIndex[*scim.Group](),
 	}
 }
 
@@ -32,19 +32,20 @@ func (p *Plugin) Name() string {
 	return p.name
 }
 
-// GetUsers retrieves all users
-// Note: The adapter layer handles filtering, pagination, and attribute selection
+// GetUsers retrieves all users in creation order
+// Note: The adapter layer handles filtering, pagination, and attribute selection.
+// Its sort is stable, so pages stay deterministic when sorting by attributes
+// that several users share.
 func (p *Plugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	// Return all users - adapter will apply SCIM operations
-	allUsers := make([]*scim.User, 0, len(p.users))
-	for _, user := range p.users {
-		allUsers = append(allUsers, user)
+	users := p.users.list()
+	for i, user := range users {
+		users[i] = cloneUser(user)
 	}
-
-	return allUsers, nil
+	return users, nil
 }
 
 // CreateUser creates a new user
@@ -52,6 +53,13 @@ func (p *Plugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, e
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	// userName is unique
+	for _, existing := range p.users.byID {
+		if existing.UserName == user.UserName {
+			return nil, scim.ErrConflict("User", "userName")
+		}
+	}
+
 	// Generate ID if not provided
 	if user.ID == "" {
 		user.ID = uuid.New().String()
@@ -63,7 +71,7 @@ func (p *Plugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, e
 	}
 
 	// Set meta
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta = &scim.Meta{
 		ResourceType: "User",
 		Created:      &now,
@@ -72,9 +80,9 @@ func (p *Plugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, e
 	}
 
 	// Store user
-	p.users[user.ID] = user
+	p.users.put(user.ID, user)
 
-	return user, nil
+	return cloneUser(user), nil
 }
 
 // GetUser retrieves a specific user by ID
@@ -82,7 +90,7 @@ func (p *Plugin) GetUser(ctx context.Context, id string, attributes []string) (*
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	user, ok := p.users[id]
+	user, ok := p.users.get(id)
 	if !ok {
 		return nil, fmt.Errorf("user not found")
 	}
@@ -90,7 +98,7 @@ func (p *Plugin) GetUser(ctx context.Context, id string, attributes []string) (*
 	// Note: Attribute selection is handled by the server layer
 	// The attributes parameter is provided for plugins that need to optimize queries
 	// For in-memory plugin, we just return the full user
-	return user, nil
+	return cloneUser(user), nil
 }
 
 // ModifyUser updates a user's attributes
@@ -98,7 +106,7 @@ func (p *Plugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp)
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	user, ok := p.users[id]
+	user, ok := p.users.get(id)
 	if !ok {
 		return fmt.Errorf("user not found")
 	}
@@ -111,7 +119,7 @@ func (p *Plugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp)
 	}
 
 	// Update user metadata
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta.LastModified = &now
 	user.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
 
@@ -123,27 +131,20 @@ func (p *Plugin) DeleteUser(ctx context.Context, id string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if _, ok := p.users[id]; !ok {
+	if !p.users.remove(id) {
 		return fmt.Errorf("user not found")
 	}
-
-	delete(p.users, id)
 	return nil
 }
 
-// GetGroups retrieves all groups
+// GetGroups retrieves all groups in creation order
 // Note: The adapter layer handles filtering, pagination, and attribute selection
 func (p *Plugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	// Return all groups - adapter will apply SCIM operations
-	allGroups := make([]*scim.Group, 0, len(p.groups))
-	for _, group := range p.groups {
-		allGroups = append(allGroups, group)
-	}
-
-	return allGroups, nil
+	return p.groups.list(), nil
 }
 
 // CreateGroup creates a new group
@@ -162,7 +163,7 @@ func (p *Plugin) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Grou
 	}
 
 	// Set meta
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta = &scim.Meta{
 		ResourceType: "Group",
 		Created:      &now,
@@ -171,7 +172,7 @@ func (p *Plugin) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Grou
 	}
 
 	// Store group
-	p.groups[group.ID] = group
+	p.groups.put(group.ID, group)
 
 	return group, nil
 }
@@ -181,7 +182,7 @@ func (p *Plugin) GetGroup(ctx context.Context, id string, attributes []string) (
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	group, ok := p.groups[id]
+	group, ok := p.groups.get(id)
 	if !ok {
 		return nil, fmt.Errorf("group not found")
 	}
@@ -197,7 +198,7 @@ func (p *Plugin) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	group, ok := p.groups[id]
+	group, ok := p.groups.get(id)
 	if !ok {
 		return fmt.Errorf("group not found")
 	}
@@ -210,7 +211,7 @@ func (p *Plugin) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp
 	}
 
 	// Update group metadata
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta.LastModified = &now
 	group.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
 
@@ -222,10 +223,15 @@ func (p *Plugin) DeleteGroup(ctx context.Context, id string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if _, ok := p.groups[id]; !ok {
+	if !p.groups.remove(id) {
 		return fmt.Errorf("group not found")
 	}
-
-	delete(p.groups, id)
 	return nil
 }
+
+// cloneUser returns a copy of a stored user for the gateway, which rewrites
+// users for responses, e.g. removing the password
+func cloneUser(user *scim.User) *scim.User {
+	copied := *user
+	return &copied
+}
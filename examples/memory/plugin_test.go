@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"testing"
 
 	"github.com/marcelom97/scimgateway/plugin"
@@ -164,3 +166,47 @@ func TestGetUserWithAttributes(t *testing.T) {
 	t.Logf("GetUser with attributes=userName: ID=%s, UserName=%s, Active=%v",
 		result.ID, result.UserName, result.Active)
 }
+
+func TestPaginationWithConcurrentCreates(t *testing.T) {
+	memPlugin := New("test")
+	adapter := plugin.NewAdapter(memPlugin)
+	ctx := context.Background()
+
+	create := func(userName, displayName string) {
+		t.Helper()
+		if _, err := memPlugin.CreateUser(ctx, &scim.User{UserName: userName, DisplayName: displayName}); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+	page := func(startIndex int, sortBy string) []string {
+		t.Helper()
+		resp, err := adapter.GetUsers(ctx, scim.QueryParams{StartIndex: startIndex, Count: 2, SortBy: sortBy})
+		if err != nil {
+			t.Fatalf("Failed to get users: %v", err)
+		}
+		var names []string
+		for _, user := range resp.Resources {
+			names = append(names, user.UserName)
+		}
+		return names
+	}
+	for i := range 6 {
+		create(fmt.Sprintf("user%d", i), fmt.Sprintf("team%d", i%2))
+	}
+
+	// Users sharing the sort value keep their order across listings
+	sorted := append(page(1, "displayName"), page(3, "displayName")...)
+	if want := []string{"user0", "user2", "user4", "user1"}; !slices.Equal(sorted, want) {
+		t.Errorf("sorted pages = %v, want %v", sorted, want)
+	}
+
+	// Users created between pages come after the pages already read
+	seen := page(1, "")
+	create("late", "team0")
+	seen = append(seen, page(3, "")...)
+	seen = append(seen, page(5, "")...)
+	seen = append(seen, page(7, "")...)
+	if want := []string{"user0", "user1", "user2", "user3", "user4", "user5", "late"}; !slices.Equal(seen, want) {
+		t.Errorf("pages = %v, want %v", seen, want)
+	}
+}
@@ -1,12 +1,24 @@
 module github.com/marcelom97/scimgateway/examples/postgres
 
-go 1.25
+go 1.25.0
 
-replace github.com/marcelom97/scimgateway => ../..
+replace (
+	github.com/marcelom97/scimgateway => ../..
+	github.com/marcelom97/scimgateway/plugins/postgres => ../../plugins/postgres
+)
 
 require (
-	github.com/google/uuid v1.6.0
-	github.com/jmoiron/sqlx v1.4.0
-	github.com/lib/pq v1.10.9
 	github.com/marcelom97/scimgateway v0.0.0-00010101000000-000000000000
+	github.com/marcelom97/scimgateway/plugins/postgres v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/jmoiron/sqlx v1.4.0 // indirect
+	github.com/kr/pretty v0.3.1 // indirect
+	github.com/lib/pq v1.10.9 // indirect
+	github.com/rogpeppe/go-internal v1.14.1 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,663 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scheduler"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/test"
+)
+
+// TestSQLiteCompliance runs the SCIM compliance suite against a file-backed
+// SQLite database. Run with: go test -tags integration ./...
+func TestSQLiteCompliance(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	test.RunComplianceSuite(t, test.NewComplianceHandler(t, p))
+}
+
+// TestSQLiteBaseEntityIsolation verifies that rows are scoped by the request's
+// base entity, so tenants sharing a database cannot see each other's resources
+func TestSQLiteBaseEntityIsolation(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	acme := scim.WithBaseEntity(context.Background(), "acme")
+	globex := scim.WithBaseEntity(context.Background(), "globex")
+
+	user, err := p.CreateUser(acme, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser(acme) error = %v", err)
+	}
+
+	// The same userName may exist once per base entity
+	if _, err := p.CreateUser(globex, &scim.User{UserName: "john"}); err != nil {
+		t.Fatalf("CreateUser(globex) error = %v", err)
+	}
+	if _, err := p.CreateUser(acme, &scim.User{UserName: "john"}); err == nil {
+		t.Error("CreateUser(acme) with duplicate userName should fail")
+	}
+
+	users, err := p.GetUsers(globex, scim.QueryParams{})
+	if err != nil {
+		t.Fatalf("GetUsers(globex) error = %v", err)
+	}
+	if len(users) != 1 || users[0].ID == user.ID {
+		t.Errorf("GetUsers(globex) returned %d users, want only globex's user", len(users))
+	}
+
+	if _, err := p.GetUser(globex, user.ID, nil); err == nil {
+		t.Error("GetUser(globex) should not find acme's user")
+	}
+	if err := p.DeleteUser(globex, user.ID); err == nil {
+		t.Error("DeleteUser(globex) should not delete acme's user")
+	}
+	if _, err := p.GetUser(acme, user.ID, nil); err != nil {
+		t.Errorf("GetUser(acme) error = %v", err)
+	}
+
+	// Requests without a base entity only see unscoped rows
+	users, err = p.GetUsers(context.Background(), scim.QueryParams{})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("GetUsers() without base entity returned %d users, want 0", len(users))
+	}
+}
+
+// TestSQLiteBaseEntityMigration verifies that databases created before the
+// base_entity column existed are upgraded in place
+func TestSQLiteBaseEntityMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scim.db")
+
+	db, err := sqlx.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		data TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO users VALUES ('1', 'legacy', '{"id":"1","userName":"legacy"}', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close() // nolint:errcheck
+
+	p, err := NewSQLitePlugin("test", path)
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	// Existing rows become unscoped rows
+	user, err := p.GetUser(context.Background(), "1", nil)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if user.UserName != "legacy" {
+		t.Errorf("userName = %q, want legacy", user.UserName)
+	}
+}
+
+// TestSQLiteStreamUsers verifies that users are streamed from the cursor in the
+// requested order and that the stream stops when the consumer fails
+func TestSQLiteStreamUsers(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	for _, name := range []string{"carol", "alice", "bob"} {
+		user := &scim.User{UserName: name, Name: &scim.Name{FamilyName: strings.ToUpper(name)}}
+		if _, err := p.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		params scim.QueryParams
+		want   []string
+	}{
+		{"insertion order", scim.QueryParams{}, []string{"carol", "alice", "bob"}},
+		{"column ascending", scim.QueryParams{SortBy: "userName"}, []string{"alice", "bob", "carol"}},
+		{"column descending", scim.QueryParams{SortBy: "username", SortOrder: "descending"}, []string{"carol", "bob", "alice"}},
+		{"json attribute", scim.QueryParams{SortBy: "name.familyName", SortOrder: "descending"}, []string{"carol", "bob", "alice"}},
+		{"qualified attribute", scim.QueryParams{SortBy: scim.SchemaUser + ":userName"}, []string{"alice", "bob", "carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := p.StreamUsers(ctx, tt.params, func(user *scim.User) error {
+				got = append(got, user.UserName)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("StreamUsers() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("StreamUsers() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("client went away")
+	var yielded int
+	err = p.StreamUsers(ctx, scim.QueryParams{}, func(*scim.User) error {
+		yielded++
+		return stop
+	})
+	if !errors.Is(err, stop) || yielded != 1 {
+		t.Errorf("StreamUsers() = %v after %d users, want the yield error after 1", err, yielded)
+	}
+}
+
+// TestSQLiteSoftDelete verifies that soft-deleted rows are hidden from reads
+// and removed by the purge job
+func TestSQLiteSoftDelete(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"),
+		WithDeletion(DeletionConfig{Mode: DeleteSoft}))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	countRows := func(table string) int {
+		var n int
+		if err := p.db.Get(&n, "SELECT COUNT(*) FROM "+table); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	group, err := p.CreateGroup(ctx, &scim.Group{DisplayName: "Admins"})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	if err := p.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if err := p.DeleteGroup(ctx, group.ID); err != nil {
+		t.Fatalf("DeleteGroup() error = %v", err)
+	}
+
+	// Deleted rows are kept but behave as if they were gone
+	if countRows("users") != 1 || countRows("groups") != 1 {
+		t.Fatal("soft delete should keep the rows")
+	}
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() should not return a deleted user")
+	}
+	if users, _ := p.GetUsers(ctx, scim.QueryParams{}); len(users) != 0 {
+		t.Errorf("GetUsers() returned %d users, want none", len(users))
+	}
+	if err := p.ModifyUser(ctx, user.ID, &scim.PatchOp{}); err == nil {
+		t.Error("ModifyUser() should not modify a deleted user")
+	}
+	if err := p.DeleteUser(ctx, user.ID); err == nil {
+		t.Error("DeleteUser() should not delete a user twice")
+	}
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john"}); err != nil {
+		t.Errorf("CreateUser() should reuse a deleted userName: %v", err)
+	}
+
+	// Rows deleted before the cutoff are purged; live rows are kept
+	purged, err := p.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted() error = %v", err)
+	}
+	if purged != 2 || countRows("users") != 1 || countRows("groups") != 0 {
+		t.Errorf("PurgeDeleted() = %d, want the 2 deleted rows removed", purged)
+	}
+}
+
+// TestSQLiteSoftDeletePurgeJob verifies that the purge job, run by a
+// scheduler, removes rows once the retention period passed
+func TestSQLiteSoftDeletePurgeJob(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"), WithDeletion(DeletionConfig{
+		Mode:          DeleteSoft,
+		Retention:     time.Millisecond,
+		PurgeInterval: 10 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	jobs := scheduler.New()
+	for _, job := range p.Jobs() {
+		if err := jobs.Add(job); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	runCtx, stop := context.WithCancel(context.Background())
+	t.Cleanup(stop)
+	go jobs.Run(runCtx) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := p.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var n int
+		err := p.db.Get(&n, "SELECT COUNT(*) FROM users")
+		if err != nil && !strings.Contains(err.Error(), "SQLITE_BUSY") {
+			t.Fatal(err)
+		}
+		if err == nil && n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("purge job did not remove the deleted user")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestSQLiteSoftDeletePurgeJobClock verifies that the purge job computes
+// its cutoff with the scheduler's clock, as deletions are timestamped with
+// the gateway's
+func TestSQLiteSoftDeletePurgeJobClock(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"), WithDeletion(DeletionConfig{
+		Mode:      DeleteSoft,
+		Retention: time.Hour,
+	}))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	clk := clock.NewFake(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	jobs := scheduler.New()
+	jobs.SetClock(clk)
+	for _, job := range p.Jobs() {
+		if err := jobs.Add(job); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	ctx := clock.WithContext(context.Background(), clk)
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := p.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	purge := func(advance time.Duration, wantUsers int) {
+		t.Helper()
+		clk.Advance(advance)
+		if err := jobs.Trigger(context.Background(), "purge-deleted"); err != nil {
+			t.Fatalf("Trigger() error = %v", err)
+		}
+		var n int
+		if err := p.db.Get(&n, "SELECT COUNT(*) FROM users"); err != nil {
+			t.Fatal(err)
+		}
+		if n != wantUsers {
+			t.Errorf("users = %d, want %d", n, wantUsers)
+		}
+	}
+	purge(30*time.Minute, 1)
+	purge(time.Hour, 0)
+}
+
+func TestSQLiteHardDelete(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	user, err := p.CreateUser(context.Background(), &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := p.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	var n int
+	if err := p.db.Get(&n, "SELECT COUNT(*) FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("hard delete left %d rows", n)
+	}
+}
+
+func TestDeletionConfigFromSettings(t *testing.T) {
+	cfg, err := DeletionConfigFromSettings(map[string]any{"deleteMode": "soft", "deleteRetention": "720h"})
+	if err != nil {
+		t.Fatalf("DeletionConfigFromSettings() error = %v", err)
+	}
+	if cfg.Mode != DeleteSoft || cfg.Retention != 720*time.Hour {
+		t.Errorf("DeletionConfigFromSettings() = %+v", cfg)
+	}
+
+	if cfg, err := DeletionConfigFromSettings(nil); err != nil || cfg.Mode != DeleteHard {
+		t.Errorf("DeletionConfigFromSettings(nil) = %+v, %v, want hard deletion", cfg, err)
+	}
+
+	for _, settings := range []map[string]any{
+		{"deleteMode": "archive"},
+		{"deleteRetention": "30 days"},
+		{"deleteRetention": "-1h"},
+	} {
+		if _, err := DeletionConfigFromSettings(settings); err == nil {
+			t.Errorf("DeletionConfigFromSettings(%v) should fail", settings)
+		}
+	}
+}
+
+// TestSQLiteMigrations verifies that the schema migrations run once on startup
+// and that a dry run reports them without touching the database
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "scim.db")
+
+	pending, err := PendingMigrations(ctx, path)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != 4 || pending[0].Version != 1 {
+		t.Errorf("PendingMigrations() = %v, want all 4 migrations on a new database", pending)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("dry run should not create the database")
+	}
+
+	for range 2 {
+		p, err := NewSQLitePlugin("test", path)
+		if err != nil {
+			t.Fatalf("NewSQLitePlugin() error = %v", err)
+		}
+		var versions int
+		if err := p.db.Get(&versions, "SELECT COUNT(*) FROM schema_migrations"); err != nil {
+			t.Fatal(err)
+		}
+		if versions != 4 {
+			t.Errorf("schema_migrations has %d versions, want 4", versions)
+		}
+		p.Close() // nolint:errcheck
+	}
+
+	pending, err = PendingMigrations(ctx, path)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("PendingMigrations() = %v, %v, want none after startup", pending, err)
+	}
+}
+
+// TestSQLiteMigrationsUpgrade verifies that a database whose schema the
+// plugin created in code before it had migrations is upgraded in place
+func TestSQLiteMigrationsUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scim.db")
+	db, err := sqlx.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := []string{
+		`CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT NOT NULL, data TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL,
+			base_entity TEXT NOT NULL DEFAULT '', deleted_at TIMESTAMP)`,
+		`CREATE TABLE groups (id TEXT PRIMARY KEY, display_name TEXT NOT NULL, data TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL,
+			base_entity TEXT NOT NULL DEFAULT '', deleted_at TIMESTAMP)`,
+		`INSERT INTO users (id, username, data, created_at, updated_at)
+			VALUES ('1', 'john', '{"id":"1","userName":"john"}', '2025-01-01 00:00:00', '2025-01-01 00:00:00')`,
+	}
+	for _, statement := range legacy {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close() // nolint:errcheck
+
+	p, err := NewSQLitePlugin("test", path)
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() on a database created before migrations error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	var versions int
+	if err := p.db.Get(&versions, "SELECT COUNT(*) FROM schema_migrations"); err != nil || versions != 4 {
+		t.Errorf("schema_migrations has %d versions, %v, want 4", versions, err)
+	}
+	user, err := p.GetUser(context.Background(), "1", nil)
+	if err != nil || user.UserName != "john" {
+		t.Errorf("GetUser() = %+v, %v, want the existing user", user, err)
+	}
+	if _, err := p.CreateUser(context.Background(), &scim.User{UserName: "jane"}); err != nil {
+		t.Errorf("CreateUser() after the upgrade error = %v", err)
+	}
+}
+
+// TestSQLiteReadReplica verifies that reads go to the replica, except in a base
+// entity written to within the pin window. The replica is a separate database
+// that never receives the writes, so a read from it misses new users.
+func TestSQLiteReadReplica(t *testing.T) {
+	dir := t.TempDir()
+	replicaPath := filepath.Join(dir, "replica.db")
+	r, err := NewSQLitePlugin("replica", replicaPath)
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	r.Close() // nolint:errcheck
+
+	p, err := NewSQLitePlugin("test", filepath.Join(dir, "scim.db"), WithReadReplica(ReplicaConfig{
+		ReadDSN:   "file:" + replicaPath + "?mode=ro",
+		PinWindow: time.Minute,
+	}))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	now := time.Now()
+	p.pins.now = func() time.Time { return now }
+
+	tenantA := scim.WithBaseEntity(context.Background(), "tenant-a")
+	tenantB := scim.WithBaseEntity(context.Background(), "tenant-b")
+
+	user, err := p.CreateUser(tenantA, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// Read-after-write in the same base entity goes to the primary
+	if _, err := p.GetUser(tenantA, user.ID, nil); err != nil {
+		t.Errorf("GetUser() after write error = %v, want the primary to serve it", err)
+	}
+	users, err := p.GetUsers(tenantA, scim.QueryParams{})
+	if err != nil || len(users) != 1 {
+		t.Errorf("GetUsers() after write = %v, %v, want 1 user from the primary", users, err)
+	}
+
+	// Other base entities keep reading from the replica
+	if p.reader(tenantB) != p.replica {
+		t.Error("a write in tenant-a should not pin tenant-b")
+	}
+
+	// Updates read the current row from the primary even when not pinned
+	now = now.Add(time.Minute)
+	patch := &scim.PatchOp{Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "John"}}}
+	if err := p.ModifyUser(tenantA, user.ID, patch); err != nil {
+		t.Fatalf("ModifyUser() error = %v", err)
+	}
+
+	// Once the pin expires, reads go to the stale replica
+	now = now.Add(time.Minute)
+	var scimErr *scim.SCIMError
+	if _, err := p.GetUser(tenantA, user.ID, nil); !errors.As(err, &scimErr) || scimErr.Status != 404 {
+		t.Errorf("GetUser() after pin window error = %v, want not found on the replica", err)
+	}
+}
+
+func TestReplicaConfigFromSettings(t *testing.T) {
+	cfg, err := ReplicaConfigFromSettings(map[string]any{"readDSN": "file:replica.db?mode=ro", "readPinWindow": "10s"})
+	if err != nil {
+		t.Fatalf("ReplicaConfigFromSettings() error = %v", err)
+	}
+	if cfg.ReadDSN != "file:replica.db?mode=ro" || cfg.PinWindow != 10*time.Second {
+		t.Errorf("ReplicaConfigFromSettings() = %+v", cfg)
+	}
+
+	if cfg, err := ReplicaConfigFromSettings(nil); err != nil || cfg.ReadDSN != "" {
+		t.Errorf("ReplicaConfigFromSettings(nil) = %+v, %v, want no replica", cfg, err)
+	}
+
+	for _, window := range []string{"soon", "-1s"} {
+		if _, err := ReplicaConfigFromSettings(map[string]any{"readPinWindow": window}); err == nil {
+			t.Errorf("ReplicaConfigFromSettings() with readPinWindow %q should fail", window)
+		}
+	}
+}
+
+// TestSQLiteOptimisticLocking verifies that writes carrying an expected version
+// only apply to rows still at that version
+func TestSQLiteOptimisticLocking(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if user.Meta.Version != `W/"1"` {
+		t.Errorf("created version = %s, want W/\"1\"", user.Meta.Version)
+	}
+
+	patch := &scim.PatchOp{Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "John"}}}
+	if err := p.ModifyUser(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`}), user.ID, patch); err != nil {
+		t.Fatalf("ModifyUser() at the current version error = %v", err)
+	}
+	got, err := p.GetUser(ctx, user.ID, nil)
+	if err != nil || got.Meta.Version != `W/"2"` {
+		t.Fatalf("GetUser() = %v, %v, want version 2", got, err)
+	}
+
+	isPreconditionFailed := func(err error) bool {
+		var scimErr *scim.SCIMError
+		return errors.As(err, &scimErr) && scimErr.Status == 412
+	}
+
+	// A write checked against version 1 lost the race with the update above
+	stale := scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`})
+	if err := p.ModifyUser(stale, user.ID, patch); !isPreconditionFailed(err) {
+		t.Errorf("ModifyUser() at a stale version error = %v, want 412", err)
+	}
+	if err := p.DeleteUser(stale, user.ID); !isPreconditionFailed(err) {
+		t.Errorf("DeleteUser() at a stale version error = %v, want 412", err)
+	}
+
+	// Unconditional writes still apply
+	if err := p.ModifyUser(ctx, user.ID, patch); err != nil {
+		t.Fatalf("ModifyUser() error = %v", err)
+	}
+	if err := p.DeleteUser(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"3"`}), user.ID); err != nil {
+		t.Errorf("DeleteUser() at the current version error = %v", err)
+	}
+}
+
+func TestSQLiteBulkRollback(t *testing.T) {
+	p, err := NewSQLitePlugin("test", filepath.Join(t.TempDir(), "scim.db"))
+	if err != nil {
+		t.Fatalf("NewSQLitePlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	handler := test.NewComplianceHandler(t, p)
+
+	// The group references the user, and the duplicate userName fails after
+	// both were written
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{"method": "POST", "path": "/Users", "bulkId": "u1", "data": {"userName": "alice"}},
+			{"method": "POST", "path": "/Groups", "data": {"displayName": "Admins", "members": [{"value": "bulkId:u1"}]}},
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice"}}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/test/Bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var resp scim.BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v, body: %s", err, w.Body.String())
+	}
+	var statuses []string
+	for _, op := range resp.Operations {
+		statuses = append(statuses, op.Status)
+	}
+	if want := []string{"424", "424", "409"}; !slices.Equal(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+
+	ctx := context.Background()
+	if users, _ := p.GetUsers(ctx, scim.QueryParams{}); len(users) != 0 {
+		t.Errorf("users = %d after rollback, want 0", len(users))
+	}
+	if groups, _ := p.GetGroups(ctx, scim.QueryParams{}); len(groups) != 0 {
+		t.Errorf("groups = %d after rollback, want 0", len(groups))
+	}
+
+	// Without an error the same operations commit
+	body = strings.Replace(body, `"data": {"userName": "alice"}}
+		]`, `"data": {"userName": "bob"}}
+		]`, 1)
+	req = httptest.NewRequest("POST", "/test/Bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if users, _ := p.GetUsers(ctx, scim.QueryParams{}); len(users) != 2 {
+		t.Errorf("users = %d after commit, want 2", len(users))
+	}
+}
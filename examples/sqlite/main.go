@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"log/slog"
 	"os"
@@ -10,6 +12,9 @@ import (
 )
 
 func main() {
+	dryRun := flag.Bool("migrate-dry-run", false, "print pending schema migrations and exit")
+	flag.Parse()
+
 	// Create configuration programmatically
 	cfg := &config.Config{
 		Gateway: config.GatewayConfig{
@@ -25,6 +30,12 @@ func main() {
 						Token: "my-secret-token",
 					},
 				},
+				// Plugin-specific settings: keep deleted users and groups for
+				// 30 days before purging them
+				Config: map[string]any{
+					"deleteMode":      "soft",
+					"deleteRetention": "720h",
+				},
 			},
 		},
 	}
@@ -42,7 +53,27 @@ func main() {
 	// Create SQLite plugin
 	// Database file will be created in current directory
 	dbPath := "./scim.db"
-	sqlitePlugin, err := NewSQLitePlugin("sqlite", dbPath)
+	if *dryRun {
+		pending, err := PendingMigrations(context.Background(), dbPath)
+		if err != nil {
+			log.Fatalf("Failed to check migrations: %v", err)
+		}
+		for _, m := range pending {
+			log.Printf("Pending migration %04d: %s", m.Version, m.Name)
+		}
+		log.Printf("%d pending migrations", len(pending))
+		return
+	}
+
+	deletion, err := DeletionConfigFromSettings(cfg.Plugins[0].Config)
+	if err != nil {
+		log.Fatalf("Invalid plugin config: %v", err)
+	}
+	replica, err := ReplicaConfigFromSettings(cfg.Plugins[0].Config)
+	if err != nil {
+		log.Fatalf("Invalid plugin config: %v", err)
+	}
+	sqlitePlugin, err := NewSQLitePlugin("sqlite", dbPath, WithDeletion(deletion), WithReadReplica(replica))
 	if err != nil {
 		log.Fatalf("Failed to create SQLite plugin: %v", err)
 	}
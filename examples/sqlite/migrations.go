@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/migrate"
+)
+
+// migrationFiles holds the schema migrations, applied in version order on startup
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// columnMigrations add the columns the plugin created in code before it had
+// migrations. Databases created then have no migrations recorded but may
+// have the columns, and SQLite has no ADD COLUMN IF NOT EXISTS, so each
+// column is added only if missing.
+var columnMigrations = []migrate.Migration{
+	// Row-level tenancy: rows belong to the base entity of the request that created them
+	addColumn(2, "base_entity", "base_entity", "TEXT NOT NULL DEFAULT ''",
+		`DROP INDEX IF EXISTS idx_users_username`,
+		`CREATE INDEX IF NOT EXISTS idx_users_base_entity_username ON users(base_entity, username)`,
+		`DROP INDEX IF EXISTS idx_groups_display_name`,
+		`CREATE INDEX IF NOT EXISTS idx_groups_base_entity_display_name ON groups(base_entity, display_name)`,
+	),
+	// Soft delete: deleted rows are marked and hidden from reads until purged
+	addColumn(3, "soft_delete", "deleted_at", "TIMESTAMP",
+		`CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users(deleted_at) WHERE deleted_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_groups_deleted_at ON groups(deleted_at) WHERE deleted_at IS NOT NULL`,
+	),
+}
+
+// addColumn returns the migration adding column with definition to the users
+// and groups tables where it is missing, then running statements
+func addColumn(version int, name, column, definition string, statements ...string) migrate.Migration {
+	return migrate.Migration{Version: version, Name: name, Func: func(ctx context.Context, tx *sql.Tx) error {
+		for _, table := range []string{"users", "groups"} {
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count); err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+				return err
+			}
+		}
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+// applyMigrations applies the pending schema migrations, or only returns them
+// when dryRun is set
+func applyMigrations(ctx context.Context, db *sql.DB, dryRun bool) ([]migrate.Migration, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations = append(migrations, columnMigrations...)
+	slices.SortFunc(migrations, func(a, b migrate.Migration) int { return a.Version - b.Version })
+	return migrate.Apply(ctx, db, migrations, migrate.Options{
+		Placeholder: migrate.Question,
+		DryRun:      dryRun,
+	})
+}
+
+// PendingMigrations returns the schema migrations NewSQLitePlugin would apply
+// to the database at dbPath, without changing it. The database is opened read-only.
+func PendingMigrations(ctx context.Context, dbPath string) ([]migrate.Migration, error) {
+	db, err := sqlx.Open("sqlite", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	return applyMigrations(ctx, db.DB, true)
+}
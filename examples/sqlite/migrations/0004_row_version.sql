@@ -0,0 +1,3 @@
+-- Row version: incremented by every update for compare-and-swap writes
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE groups ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
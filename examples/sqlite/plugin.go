@@ -10,14 +10,25 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/scim"
 	_ "modernc.org/sqlite"
 )
 
-// SQLitePlugin implements a SQLite-backed SCIM plugin
+// SQLitePlugin implements a SQLite-backed SCIM plugin.
+//
+// Rows are scoped by a base_entity column holding scim.BaseEntityFromContext of
+// the request, so several tenants can share one database file. With
+// WithReadReplica, reads go to a replica except shortly after a write in the
+// same base entity.
 type SQLitePlugin struct {
-	name string
-	db   *sqlx.DB
+	name     string
+	db       *sqlx.DB
+	deletion DeletionConfig
+
+	replicaConfig ReplicaConfig
+	replica       *sqlx.DB   // read replica, nil when reads go to the primary
+	pins          *writePins // sessions whose reads are pinned to the primary
 }
 
 // UserData wraps scim.User and implements sql.Scanner and driver.Valuer
@@ -108,71 +119,54 @@ func (g GroupData) Value() (driver.Value, error) {
 
 // userRow represents a user row in the database
 type userRow struct {
-	ID        string    `db:"id"`
-	Username  string    `db:"username"`
-	Data      UserData  `db:"data"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID         string    `db:"id"`
+	BaseEntity string    `db:"base_entity"`
+	Username   string    `db:"username"`
+	Data       UserData  `db:"data"`
+	Version    int64     `db:"version"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
 }
 
 // groupRow represents a group row in the database
 type groupRow struct {
 	ID          string    `db:"id"`
+	BaseEntity  string    `db:"base_entity"`
 	DisplayName string    `db:"display_name"`
 	Data        GroupData `db:"data"`
+	Version     int64     `db:"version"`
 	CreatedAt   time.Time `db:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at"`
 }
 
 // NewSQLitePlugin creates a new SQLite plugin
-func NewSQLitePlugin(name string, dbPath string) (*SQLitePlugin, error) {
+func NewSQLitePlugin(name string, dbPath string, opts ...Option) (*SQLitePlugin, error) {
 	db, err := sqlx.Open("sqlite", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	plugin := &SQLitePlugin{
-		name: name,
-		db:   db,
+		name:     name,
+		db:       db,
+		deletion: DeletionConfig{Mode: DeleteHard},
+	}
+	for _, opt := range opts {
+		opt(plugin)
 	}
 
-	// Initialize database schema
-	if err := plugin.initSchema(); err != nil {
+	// Create or upgrade the database schema; a replica receives it through replication
+	if _, err := applyMigrations(context.Background(), db.DB, false); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to initialize schema: %w", err)
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
-	return plugin, nil
-}
-
-// initSchema creates the database tables if they don't exist
-func (p *SQLitePlugin) initSchema() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT NOT NULL,
-			data TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			updated_at TIMESTAMP NOT NULL
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
-		`CREATE TABLE IF NOT EXISTS groups (
-			id TEXT PRIMARY KEY,
-			display_name TEXT NOT NULL,
-			data TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			updated_at TIMESTAMP NOT NULL
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_groups_display_name ON groups(display_name)`,
-	}
-
-	for _, query := range queries {
-		if _, err := p.db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute schema query: %w", err)
-		}
+	if err := plugin.openReplica(); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return nil
+	return plugin, nil
 }
 
 // Name returns the plugin name
@@ -180,25 +174,29 @@ func (p *SQLitePlugin) Name() string {
 	return p.name
 }
 
-// Close closes the database connection
+// DB returns the underlying connection pool so the gateway can apply
+// pool settings and expose connection metrics (plugin.DBProvider)
+func (p *SQLitePlugin) DB() *sql.DB {
+	return p.db.DB
+}
+
+// Close closes the database connections
 func (p *SQLitePlugin) Close() error {
+	if p.replica != nil {
+		p.replica.Close() // nolint:errcheck
+	}
 	return p.db.Close()
 }
 
-// GetUsers retrieves all users
+// GetUsers retrieves users in the requested sort order
 func (p *SQLitePlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
-	var rows []userRow
-	query := `SELECT id, username, data, created_at, updated_at FROM users`
-
-	if err := p.db.SelectContext(ctx, &rows, query); err != nil {
-		return nil, fmt.Errorf("failed to query users: %w", err)
-	}
-
-	users := make([]*scim.User, 0, len(rows))
-	for _, row := range rows {
-		if row.Data.User != nil {
-			users = append(users, row.Data.User)
-		}
+	users := []*scim.User{}
+	err := p.StreamUsers(ctx, params, func(user *scim.User) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return users, nil
@@ -216,9 +214,11 @@ func (p *SQLitePlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.U
 		user.Schemas = []string{scim.SchemaUser}
 	}
 
+	baseEntity := scim.BaseEntityFromContext(ctx)
+
 	var exists bool
-	// Check for existing username
-	err := p.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", user.UserName)
+	// Check for existing username within the base entity
+	err := p.writer(ctx).GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE username = ? AND base_entity = ? AND deleted_at IS NULL)", user.UserName, baseEntity)
 	if err != nil {
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to check existing username: %v", err))
 	}
@@ -230,54 +230,63 @@ func (p *SQLitePlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.U
 	}
 
 	// Set meta
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta = &scim.Meta{
 		ResourceType: "User",
 		Created:      &now,
 		LastModified: &now,
-		Version:      fmt.Sprintf("W/\"%s\"", user.ID),
+		Version:      rowVersion(1),
 	}
 
 	// Insert user into database
-	query := `INSERT INTO users (id, username, data, created_at, updated_at) VALUES (:id, :username, :data, :created_at, :updated_at)`
+	query := `INSERT INTO users (id, base_entity, username, data, version, created_at, updated_at) VALUES (:id, :base_entity, :username, :data, 1, :created_at, :updated_at)`
 
 	row := userRow{
-		ID:        user.ID,
-		Username:  user.UserName,
-		Data:      UserData{User: user},
-		CreatedAt: now,
-		UpdatedAt: now,
+		ID:         user.ID,
+		BaseEntity: baseEntity,
+		Username:   user.UserName,
+		Data:       UserData{User: user},
+		CreatedAt:  now,
+		UpdatedAt:  now,
 	}
 
-	if _, err := p.db.NamedExecContext(ctx, query, row); err != nil {
+	if _, err := p.writer(ctx).NamedExecContext(ctx, query, row); err != nil {
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to insert user: %v", err))
 	}
+	p.markWrite(ctx)
 
 	return user, nil
 }
 
 // GetUser retrieves a specific user by ID
 func (p *SQLitePlugin) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return p.getUser(ctx, p.reader(ctx), id)
+}
+
+// getUser reads a user from db
+func (p *SQLitePlugin) getUser(ctx context.Context, db dbtx, id string) (*scim.User, error) {
 	var row userRow
-	query := `SELECT id, username, data, created_at, updated_at FROM users WHERE id = ?`
+	query := `SELECT id, base_entity, username, data, version, created_at, updated_at FROM users WHERE id = ? AND base_entity = ? AND deleted_at IS NULL`
 
-	if err := p.db.GetContext(ctx, &row, query, id); err != nil {
+	if err := db.GetContext(ctx, &row, query, id, scim.BaseEntityFromContext(ctx)); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, scim.ErrNotFound("User", id)
 		}
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to get user: %v", err))
 	}
 
+	setMetaVersion(row.Data.User.Meta, row.Version)
 	return row.Data.User, nil
 }
 
 // ModifyUser updates a user's attributes
 func (p *SQLitePlugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
-	// Get existing user (returns ErrNotFound if not exists)
-	user, err := p.GetUser(ctx, id, nil)
+	// Get existing user from the primary (returns ErrNotFound if not exists)
+	user, err := p.getUser(ctx, p.writer(ctx), id)
 	if err != nil {
 		return err
 	}
+	version, _ := parseRowVersion(user.Meta.Version)
 
 	// Apply patch operations
 	patcher := scim.NewPatchProcessor()
@@ -286,60 +295,63 @@ func (p *SQLitePlugin) ModifyUser(ctx context.Context, id string, patch *scim.Pa
 	}
 
 	// Update metadata
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta.LastModified = &now
-	user.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
+	user.Meta.Version = rowVersion(version + 1)
 
-	// Update user in database
-	query := `UPDATE users SET username = :username, data = :data, updated_at = :updated_at WHERE id = :id`
+	// Update user in database; conditional writes only apply to the version read above
+	query := `UPDATE users SET username = :username, data = :data, version = version + 1, updated_at = :updated_at WHERE id = :id AND base_entity = :base_entity AND deleted_at IS NULL`
+	if expected, ok := expectedVersion(ctx); ok {
+		if expected != version {
+			return noRowsError(ctx, "User", id)
+		}
+		query += ` AND version = :version`
+	}
 
 	row := userRow{
-		ID:        user.ID,
-		Username:  user.UserName,
-		Data:      UserData{User: user},
-		UpdatedAt: now,
+		ID:         user.ID,
+		BaseEntity: scim.BaseEntityFromContext(ctx),
+		Username:   user.UserName,
+		Data:       UserData{User: user},
+		Version:    version,
+		UpdatedAt:  now,
 	}
 
-	if _, err := p.db.NamedExecContext(ctx, query, row); err != nil {
+	result, err := p.writer(ctx).NamedExecContext(ctx, query, row)
+	if err != nil {
 		return scim.ErrInternalServer(fmt.Sprintf("failed to update user: %v", err))
 	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return noRowsError(ctx, "User", id)
+	}
+	p.markWrite(ctx)
 
 	return nil
 }
 
 // DeleteUser deletes a user
 func (p *SQLitePlugin) DeleteUser(ctx context.Context, id string) error {
-	result, err := p.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	rows, err := p.deleteRow(ctx, "users", id)
 	if err != nil {
 		return scim.ErrInternalServer(fmt.Sprintf("failed to delete user: %v", err))
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return scim.ErrInternalServer(fmt.Sprintf("failed to get rows affected: %v", err))
-	}
-
 	if rows == 0 {
-		return scim.ErrNotFound("User", id)
+		return noRowsError(ctx, "User", id)
 	}
 
 	return nil
 }
 
-// GetGroups retrieves all groups
+// GetGroups retrieves groups in the requested sort order
 func (p *SQLitePlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
-	var rows []groupRow
-	query := `SELECT id, display_name, data, created_at, updated_at FROM groups`
-
-	if err := p.db.SelectContext(ctx, &rows, query); err != nil {
-		return nil, fmt.Errorf("failed to query groups: %w", err)
-	}
-
-	groups := make([]*scim.Group, 0, len(rows))
-	for _, row := range rows {
-		if row.Data.Group != nil {
-			groups = append(groups, row.Data.Group)
-		}
+	groups := []*scim.Group{}
+	err := p.StreamGroups(ctx, params, func(group *scim.Group) error {
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return groups, nil
@@ -357,9 +369,11 @@ func (p *SQLitePlugin) CreateGroup(ctx context.Context, group *scim.Group) (*sci
 		group.Schemas = []string{scim.SchemaGroup}
 	}
 
+	baseEntity := scim.BaseEntityFromContext(ctx)
+
 	var exists bool
-	// Check for existing displayName
-	err := p.db.GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM groups WHERE display_name = ?)", group.DisplayName)
+	// Check for existing displayName within the base entity
+	err := p.writer(ctx).GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM groups WHERE display_name = ? AND base_entity = ? AND deleted_at IS NULL)", group.DisplayName, baseEntity)
 	if err != nil {
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to check existing displayName: %v", err))
 	}
@@ -371,54 +385,63 @@ func (p *SQLitePlugin) CreateGroup(ctx context.Context, group *scim.Group) (*sci
 	}
 
 	// Set meta
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta = &scim.Meta{
 		ResourceType: "Group",
 		Created:      &now,
 		LastModified: &now,
-		Version:      fmt.Sprintf("W/\"%s\"", group.ID),
+		Version:      rowVersion(1),
 	}
 
 	// Insert group into database
-	query := `INSERT INTO groups (id, display_name, data, created_at, updated_at) VALUES (:id, :display_name, :data, :created_at, :updated_at)`
+	query := `INSERT INTO groups (id, base_entity, display_name, data, version, created_at, updated_at) VALUES (:id, :base_entity, :display_name, :data, 1, :created_at, :updated_at)`
 
 	row := groupRow{
 		ID:          group.ID,
+		BaseEntity:  baseEntity,
 		DisplayName: group.DisplayName,
 		Data:        GroupData{Group: group},
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
 
-	if _, err := p.db.NamedExecContext(ctx, query, row); err != nil {
+	if _, err := p.writer(ctx).NamedExecContext(ctx, query, row); err != nil {
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to insert group: %v", err))
 	}
+	p.markWrite(ctx)
 
 	return group, nil
 }
 
 // GetGroup retrieves a specific group by ID
 func (p *SQLitePlugin) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return p.getGroup(ctx, p.reader(ctx), id)
+}
+
+// getGroup reads a group from db
+func (p *SQLitePlugin) getGroup(ctx context.Context, db dbtx, id string) (*scim.Group, error) {
 	var row groupRow
-	query := `SELECT id, display_name, data, created_at, updated_at FROM groups WHERE id = ?`
+	query := `SELECT id, base_entity, display_name, data, version, created_at, updated_at FROM groups WHERE id = ? AND base_entity = ? AND deleted_at IS NULL`
 
-	if err := p.db.GetContext(ctx, &row, query, id); err != nil {
+	if err := db.GetContext(ctx, &row, query, id, scim.BaseEntityFromContext(ctx)); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, scim.ErrNotFound("Group", id)
 		}
 		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to get group: %v", err))
 	}
 
+	setMetaVersion(row.Data.Group.Meta, row.Version)
 	return row.Data.Group, nil
 }
 
 // ModifyGroup updates a group's attributes
 func (p *SQLitePlugin) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
-	// Get existing group (returns ErrNotFound if not exists)
-	group, err := p.GetGroup(ctx, id, nil)
+	// Get existing group from the primary (returns ErrNotFound if not exists)
+	group, err := p.getGroup(ctx, p.writer(ctx), id)
 	if err != nil {
 		return err
 	}
+	version, _ := parseRowVersion(group.Meta.Version)
 
 	// Apply patch operations
 	patcher := scim.NewPatchProcessor()
@@ -427,41 +450,49 @@ func (p *SQLitePlugin) ModifyGroup(ctx context.Context, id string, patch *scim.P
 	}
 
 	// Update metadata
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta.LastModified = &now
-	group.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
+	group.Meta.Version = rowVersion(version + 1)
 
-	// Update group in database
-	query := `UPDATE groups SET display_name = :display_name, data = :data, updated_at = :updated_at WHERE id = :id`
+	// Update group in database; conditional writes only apply to the version read above
+	query := `UPDATE groups SET display_name = :display_name, data = :data, version = version + 1, updated_at = :updated_at WHERE id = :id AND base_entity = :base_entity AND deleted_at IS NULL`
+	if expected, ok := expectedVersion(ctx); ok {
+		if expected != version {
+			return noRowsError(ctx, "Group", id)
+		}
+		query += ` AND version = :version`
+	}
 
 	row := groupRow{
 		ID:          group.ID,
+		BaseEntity:  scim.BaseEntityFromContext(ctx),
 		DisplayName: group.DisplayName,
 		Data:        GroupData{Group: group},
+		Version:     version,
 		UpdatedAt:   now,
 	}
 
-	if _, err := p.db.NamedExecContext(ctx, query, row); err != nil {
+	result, err := p.writer(ctx).NamedExecContext(ctx, query, row)
+	if err != nil {
 		return scim.ErrInternalServer(fmt.Sprintf("failed to update group: %v", err))
 	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return noRowsError(ctx, "Group", id)
+	}
+	p.markWrite(ctx)
 
 	return nil
 }
 
 // DeleteGroup deletes a group
 func (p *SQLitePlugin) DeleteGroup(ctx context.Context, id string) error {
-	result, err := p.db.ExecContext(ctx, "DELETE FROM groups WHERE id = ?", id)
+	rows, err := p.deleteRow(ctx, "groups", id)
 	if err != nil {
 		return scim.ErrInternalServer(fmt.Sprintf("failed to delete group: %v", err))
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return scim.ErrInternalServer(fmt.Sprintf("failed to get rows affected: %v", err))
-	}
-
 	if rows == 0 {
-		return scim.ErrNotFound("Group", id)
+		return noRowsError(ctx, "Group", id)
 	}
 
 	return nil
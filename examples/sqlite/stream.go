@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// userSortColumns and groupSortColumns map sortBy attributes (lowercased) to
+// indexed columns; other attributes are sorted by their JSON value
+var (
+	userSortColumns  = map[string]string{"id": "id", "username": "username"}
+	groupSortColumns = map[string]string{"id": "id", "displayname": "display_name"}
+)
+
+// streamRows runs query and calls fn for each row as it is read from the
+// cursor, so large result sets are never loaded into memory at once. Errors
+// returned by fn are passed through unchanged.
+func streamRows[T any](ctx context.Context, db sqlx.QueryerContext, query string, args []any, fn func(*T) error) error {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck
+
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+	return nil
+}
+
+// orderByClause builds the ORDER BY clause for sortBy. SQLite's default BINARY
+// collation sorts NULLs first and strings bytewise, matching the gateway's
+// in-memory sort.
+func orderByClause(sortBy, sortOrder string, columns map[string]string) (string, []any) {
+	if sortBy == "" {
+		return "ORDER BY created_at ASC, id ASC", nil
+	}
+
+	direction := "ASC"
+	if strings.EqualFold(sortOrder, "descending") {
+		direction = "DESC"
+	}
+
+	if column, ok := columns[strings.ToLower(sortBy)]; ok {
+		return fmt.Sprintf("ORDER BY %s %s", column, direction), nil
+	}
+	return fmt.Sprintf("ORDER BY json_extract(data, ?) %s", direction), []any{jsonPath(sortBy)}
+}
+
+// jsonPath converts a SCIM attribute path to a SQLite JSON path. Extension
+// attributes are stored under their schema URN, which must be quoted.
+func jsonPath(attrPath string) string {
+	urn, path := scim.SplitSchemaURN(attrPath)
+	if urn == scim.SchemaUser || urn == scim.SchemaGroup {
+		urn = ""
+	}
+
+	var b strings.Builder
+	b.WriteString("$")
+	if urn != "" {
+		b.WriteString(`."` + urn + `"`)
+	}
+	if path != "" {
+		b.WriteString("." + path)
+	}
+	return b.String()
+}
+
+// StreamUsers implements scim.UserStreamer. Rows are read in sort order from a
+// cursor; the gateway filters and paginates the stream.
+func (p *SQLitePlugin) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	order, orderArgs := orderByClause(params.SortBy, params.SortOrder, userSortColumns)
+	query := `SELECT id, base_entity, username, data, version, created_at, updated_at FROM users WHERE base_entity = ? AND deleted_at IS NULL ` + order
+	args := append([]any{scim.BaseEntityFromContext(ctx)}, orderArgs...)
+
+	return streamRows(ctx, p.reader(ctx), query, args, func(row *userRow) error {
+		if row.Data.User == nil {
+			return nil
+		}
+		setMetaVersion(row.Data.User.Meta, row.Version)
+		return yield(row.Data.User)
+	})
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (p *SQLitePlugin) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	order, orderArgs := orderByClause(params.SortBy, params.SortOrder, groupSortColumns)
+	query := `SELECT id, base_entity, display_name, data, version, created_at, updated_at FROM groups WHERE base_entity = ? AND deleted_at IS NULL ` + order
+	args := append([]any{scim.BaseEntityFromContext(ctx)}, orderArgs...)
+
+	return streamRows(ctx, p.reader(ctx), query, args, func(row *groupRow) error {
+		if row.Data.Group == nil {
+			return nil
+		}
+		setMetaVersion(row.Data.Group.Meta, row.Version)
+		return yield(row.Data.Group)
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dbtx is implemented by both *sqlx.DB and *sqlx.Tx, so queries run the same
+// way inside and outside a transaction
+type dbtx interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	NamedExecContext(ctx context.Context, query string, arg any) (sql.Result, error)
+}
+
+// txKey is the context key for the transaction started by Begin
+type txKey struct{}
+
+// txFromContext returns the transaction started by Begin, or nil
+func txFromContext(ctx context.Context) *sqlx.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sqlx.Tx)
+	return tx
+}
+
+// Begin starts a transaction that writes and reads with the returned context
+// run in. It implements scim.TransactionalPlugin, so a bulk request with
+// failOnErrors either applies all of its operations or none of them.
+func (p *SQLitePlugin) Begin(ctx context.Context) (context.Context, error) {
+	if txFromContext(ctx) != nil {
+		return nil, errors.New("transaction already started")
+	}
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return context.WithValue(ctx, txKey{}, tx), nil
+}
+
+// Commit commits the transaction started by Begin
+func (p *SQLitePlugin) Commit(ctx context.Context) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction in context")
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the writes of the transaction started by Begin
+func (p *SQLitePlugin) Rollback(ctx context.Context) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction in context")
+	}
+	if err := tx.Rollback(); err != nil {
+		return fmt.Errorf("failed to roll back transaction: %w", err)
+	}
+	return nil
+}
+
+// writer returns the transaction started by Begin, or the primary database
+func (p *SQLitePlugin) writer(ctx context.Context) dbtx {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return p.db
+}
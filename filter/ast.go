@@ -0,0 +1,203 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Operator is a comparison or logical operator of a filter
+type Operator string
+
+// Comparison operators of attribute expressions
+const (
+	Eq Operator = "eq" // equal
+	Ne Operator = "ne" // not equal
+	Co Operator = "co" // contains
+	Sw Operator = "sw" // starts with
+	Ew Operator = "ew" // ends with
+	Pr Operator = "pr" // present, has no value
+	Gt Operator = "gt" // greater than
+	Ge Operator = "ge" // greater than or equal
+	Lt Operator = "lt" // less than
+	Le Operator = "le" // less than or equal
+)
+
+// Logical operators of logical expressions
+const (
+	And Operator = "and"
+	Or  Operator = "or"
+)
+
+// comparisonOperators are the operators of attribute expressions
+var comparisonOperators = []Operator{Eq, Ne, Co, Sw, Ew, Pr, Gt, Ge, Lt, Le}
+
+// Expr is a node of a parsed filter: an *AttributeExpr, *LogicalExpr,
+// *NotExpr or *GroupExpr. String returns the node as a filter.
+type Expr interface {
+	String() string
+	expr()
+}
+
+// AttributeExpr compares the values of an attribute, e.g. userName eq "bjensen"
+type AttributeExpr struct {
+	Path     Path
+	Operator Operator
+
+	// Value is a string, bool, int64, float64 or nil, and nil for Pr
+	Value any
+}
+
+// LogicalExpr combines two expressions with And or Or
+type LogicalExpr struct {
+	Operator Operator
+	Left     Expr
+	Right    Expr
+}
+
+// NotExpr negates an expression
+type NotExpr struct {
+	Expr Expr
+}
+
+// GroupExpr is an expression in parentheses
+type GroupExpr struct {
+	Expr Expr
+}
+
+func (*AttributeExpr) expr() {}
+func (*LogicalExpr) expr()   {}
+func (*NotExpr) expr()       {}
+func (*GroupExpr) expr()     {}
+
+// String implements Expr
+func (e *AttributeExpr) String() string {
+	if e.Operator == Pr {
+		return e.Path.String() + " pr"
+	}
+	return e.Path.String() + " " + string(e.Operator) + " " + formatValue(e.Value)
+}
+
+// String implements Expr
+func (e *LogicalExpr) String() string {
+	return e.Left.String() + " " + string(e.Operator) + " " + e.Right.String()
+}
+
+// String implements Expr
+func (e *NotExpr) String() string {
+	return "not " + e.Expr.String()
+}
+
+// String implements Expr
+func (e *GroupExpr) String() string {
+	return "(" + e.Expr.String() + ")"
+}
+
+// formatValue formats a comparison value as a filter literal. Strings are
+// quoted as is, as the grammar has no escapes.
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return `"` + v + `"`
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Path is the attribute of an attribute expression, e.g.
+// urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value
+// or emails[type eq "work"].value
+type Path struct {
+	// URN is the schema URN prefix of the attribute, if any
+	URN string
+
+	// Attribute is the name of the attribute
+	Attribute string
+
+	// Filter selects the values of a multi-valued attribute, e.g.
+	// type eq "work" in emails[type eq "work"]. Its paths are relative to
+	// the attribute.
+	Filter Expr
+
+	// SubAttribute is the name of the sub-attribute of a complex attribute,
+	// if any
+	SubAttribute string
+}
+
+// ParsePath parses an attribute path
+func ParsePath(path string) (Path, error) {
+	var p Path
+
+	// The URN ends at the last colon before any value filter
+	prefix, _, _ := strings.Cut(path, "[")
+	if i := strings.LastIndex(prefix, ":"); i != -1 {
+		p.URN, path = path[:i], path[i+1:]
+	}
+
+	if open := strings.Index(path, "["); open != -1 {
+		closing := strings.LastIndex(path, "]")
+		if closing < open {
+			return Path{}, fmt.Errorf("unterminated value filter in %q", path)
+		}
+		filter, err := Parse(path[open+1 : closing])
+		if err != nil {
+			return Path{}, fmt.Errorf("invalid value filter in %q: %w", path, err)
+		}
+		if filter == nil {
+			return Path{}, fmt.Errorf("empty value filter in %q", path)
+		}
+		rest := path[closing+1:]
+		if rest != "" && !strings.HasPrefix(rest, ".") {
+			return Path{}, fmt.Errorf("unexpected %q after value filter in %q", rest, path)
+		}
+		p.Attribute, p.Filter, p.SubAttribute = path[:open], filter, strings.TrimPrefix(rest, ".")
+	} else {
+		p.Attribute, p.SubAttribute, _ = strings.Cut(path, ".")
+	}
+
+	if p.Attribute == "" {
+		return Path{}, fmt.Errorf("missing attribute name in %q", path)
+	}
+	return p, nil
+}
+
+// String returns the path as written in a filter
+func (p Path) String() string {
+	var b strings.Builder
+	if p.URN != "" {
+		b.WriteString(p.URN + ":")
+	}
+	b.WriteString(p.Attribute)
+	if p.Filter != nil {
+		b.WriteString("[" + p.Filter.String() + "]")
+	}
+	if p.SubAttribute != "" {
+		b.WriteString("." + p.SubAttribute)
+	}
+	return b.String()
+}
+
+// Walk traverses expr depth-first, calling fn for each node before its
+// children: the operands of logical, not and group expressions, and the
+// value filters of attribute paths. Children of a node are skipped if fn
+// returns false for it.
+func Walk(expr Expr, fn func(Expr) bool) {
+	if expr == nil || !fn(expr) {
+		return
+	}
+	switch e := expr.(type) {
+	case *AttributeExpr:
+		Walk(e.Path.Filter, fn)
+	case *LogicalExpr:
+		Walk(e.Left, fn)
+		Walk(e.Right, fn)
+	case *NotExpr:
+		Walk(e.Expr, fn)
+	case *GroupExpr:
+		Walk(e.Expr, fn)
+	}
+}
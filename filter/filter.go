@@ -0,0 +1,303 @@
+// Package filter parses SCIM filter expressions (RFC 7644 section 3.4.2.2)
+// into a typed syntax tree, so plugins can translate filters to the query
+// language of their backend, such as SQL, LDAP or MongoDB:
+//
+//	expr, err := filter.Parse(`userName sw "j" and not (emails[type eq "work"] pr)`)
+//	if err != nil {
+//		return err
+//	}
+//	filter.Walk(expr, func(e filter.Expr) bool {
+//		if attr, ok := e.(*filter.AttributeExpr); ok {
+//			fmt.Println(attr.Path, attr.Operator, attr.Value)
+//		}
+//		return true
+//	})
+//
+// The gateway evaluates the same trees in memory, so filters a plugin
+// cannot translate may be left to the gateway.
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses a filter. Keywords and operators are case-insensitive, and
+// and binds tighter than or. An empty filter matches every resource and
+// parses to nil.
+func Parse(filter string) (Expr, error) {
+	p := &parser{input: strings.TrimSpace(filter)}
+	if p.input == "" {
+		return nil, nil
+	}
+	expr, err := p.parseLogicalOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+	return expr, nil
+}
+
+// parser parses a filter by recursive descent
+type parser struct {
+	input string
+	pos   int
+}
+
+// parseLogicalOr parses OR expressions
+func (p *parser) parseLogicalOr() (Expr, error) {
+	left, err := p.parseLogicalAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipWhitespace()
+		if !p.matchKeyword("or") {
+			break
+		}
+		p.pos += 2
+		p.skipWhitespace()
+
+		right, err := p.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &LogicalExpr{Operator: Or, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseLogicalAnd parses AND expressions
+func (p *parser) parseLogicalAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipWhitespace()
+		if !p.matchKeyword("and") {
+			break
+		}
+		p.pos += 3
+		p.skipWhitespace()
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &LogicalExpr{Operator: And, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+// parseNot parses NOT expressions
+func (p *parser) parseNot() (Expr, error) {
+	p.skipWhitespace()
+	if p.matchKeyword("not") {
+		p.pos += 3
+		p.skipWhitespace()
+
+		expr, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Expr: expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses primary expressions (attribute expressions or grouped expressions)
+func (p *parser) parsePrimary() (Expr, error) {
+	p.skipWhitespace()
+
+	// Check for grouped expression
+	if p.peek() == '(' {
+		p.pos++
+		expr, err := p.parseLogicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		p.pos++
+		return &GroupExpr{Expr: expr}, nil
+	}
+
+	return p.parseAttributeExpression()
+}
+
+// parseAttributeExpression parses an attribute comparison
+func (p *parser) parseAttributeExpression() (Expr, error) {
+	p.skipWhitespace()
+
+	start := p.pos
+	rawPath := p.parseAttributePath()
+	if rawPath == "" {
+		return nil, fmt.Errorf("expected attribute path at position %d", p.pos)
+	}
+	path, err := ParsePath(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attribute path at position %d: %w", start, err)
+	}
+
+	p.skipWhitespace()
+
+	op := p.parseOperator()
+	if op == "" {
+		return nil, fmt.Errorf("expected operator at position %d", p.pos)
+	}
+
+	p.skipWhitespace()
+
+	var value any
+	// pr (present) operator doesn't need a value
+	if op != Pr {
+		value, err = p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &AttributeExpr{Path: path, Operator: op, Value: value}, nil
+}
+
+// parseAttributePath parses an attribute path, including the value filter
+// of a multi-valued attribute such as emails[type eq "work"].value
+func (p *parser) parseAttributePath() string {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.input) {
+		ch := p.input[p.pos]
+		// ':' allows schema URN prefixes (e.g. urn:...:enterprise:2.0:User:department)
+		if !isAlphaNumeric(ch) && ch != '.' && ch != ':' && ch != '[' && ch != ']' && ch != '"' && ch != ' ' {
+			break
+		}
+		switch ch {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		// Spaces end the path outside of value filters
+		if ch == ' ' && depth == 0 {
+			break
+		}
+		p.pos++
+	}
+	return strings.TrimSpace(p.input[start:p.pos])
+}
+
+// parseOperator parses a comparison operator
+func (p *parser) parseOperator() Operator {
+	p.skipWhitespace()
+	for _, op := range comparisonOperators {
+		if p.matchKeyword(string(op)) {
+			p.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+// parseValue parses a value (string, number, boolean, null)
+func (p *parser) parseValue() (any, error) {
+	p.skipWhitespace()
+
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("expected value at position %d", p.pos)
+	}
+
+	// String value
+	if p.peek() == '"' {
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] != '"' {
+			p.pos++
+		}
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("unterminated string at position %d", start)
+		}
+		value := p.input[start:p.pos]
+		p.pos++ // Skip closing quote
+		return value, nil
+	}
+
+	// Boolean or null
+	if p.matchKeyword("true") {
+		p.pos += 4
+		return true, nil
+	}
+	if p.matchKeyword("false") {
+		p.pos += 5
+		return false, nil
+	}
+	if p.matchKeyword("null") {
+		p.pos += 4
+		return nil, nil
+	}
+
+	// Number
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '-') {
+		p.pos++
+	}
+	if p.pos > start {
+		numStr := p.input[start:p.pos]
+		if strings.Contains(numStr, ".") {
+			return strconv.ParseFloat(numStr, 64)
+		}
+		return strconv.ParseInt(numStr, 10, 64)
+	}
+
+	return nil, fmt.Errorf("invalid value at position %d", p.pos)
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *parser) skipWhitespace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// matchKeyword reports whether the input continues with keyword as a whole
+// word, ignoring case
+func (p *parser) matchKeyword(keyword string) bool {
+	if p.pos+len(keyword) > len(p.input) {
+		return false
+	}
+	if !strings.EqualFold(p.input[p.pos:p.pos+len(keyword)], keyword) {
+		return false
+	}
+	// Check that keyword is not part of a larger word
+	if p.pos+len(keyword) < len(p.input) && isAlphaNumeric(p.input[p.pos+len(keyword)]) {
+		return false
+	}
+	return true
+}
+
+func isAlphaNumeric(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
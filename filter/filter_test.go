@@ -0,0 +1,137 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   Expr
+	}{
+		{`userName eq "bjensen"`, &AttributeExpr{Path: Path{Attribute: "userName"}, Operator: Eq, Value: "bjensen"}},
+		{`title PR`, &AttributeExpr{Path: Path{Attribute: "title"}, Operator: Pr}},
+		{`meta.lastModified gt 42`, &AttributeExpr{Path: Path{Attribute: "meta", SubAttribute: "lastModified"}, Operator: Gt, Value: int64(42)}},
+		{`active eq true`, &AttributeExpr{Path: Path{Attribute: "active"}, Operator: Eq, Value: true}},
+		{
+			`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value eq "26118915"`,
+			&AttributeExpr{
+				Path: Path{
+					URN:          "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+					Attribute:    "manager",
+					SubAttribute: "value",
+				},
+				Operator: Eq,
+				Value:    "26118915",
+			},
+		},
+		{
+			`emails[type eq "work"].value co "@example.com"`,
+			&AttributeExpr{
+				Path: Path{
+					Attribute:    "emails",
+					Filter:       &AttributeExpr{Path: Path{Attribute: "type"}, Operator: Eq, Value: "work"},
+					SubAttribute: "value",
+				},
+				Operator: Co,
+				Value:    "@example.com",
+			},
+		},
+		{
+			`a eq 1 or b eq 2 and not (c pr)`,
+			&LogicalExpr{
+				Operator: Or,
+				Left:     &AttributeExpr{Path: Path{Attribute: "a"}, Operator: Eq, Value: int64(1)},
+				Right: &LogicalExpr{
+					Operator: And,
+					Left:     &AttributeExpr{Path: Path{Attribute: "b"}, Operator: Eq, Value: int64(2)},
+					Right:    &NotExpr{Expr: &GroupExpr{Expr: &AttributeExpr{Path: Path{Attribute: "c"}, Operator: Pr}}},
+				},
+			},
+		},
+		{"  ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter  string
+		wantErr string
+	}{
+		{`userName`, "expected operator"},
+		{`userName eq`, "expected value"},
+		{`userName eq "bjensen`, "unterminated string"},
+		{`(userName pr`, "expected ')'"},
+		{`userName pr )`, "unexpected"},
+		{`emails[type eq].value pr`, "invalid value filter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := Parse(tt.filter)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, filter := range []string{
+		`userName eq "bjensen"`,
+		`emails[type eq "work" and primary eq true].value pr`,
+		`not (meta.version ne 1.5) or name.familyName sw "J"`,
+		`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq null`,
+	} {
+		expr, err := Parse(filter)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", filter, err)
+		}
+		if got := expr.String(); got != filter {
+			t.Errorf("String() = %q, want %q", got, filter)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	expr, err := Parse(`userName sw "j" and not (emails[type eq "work"] pr)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attributes []string
+	Walk(expr, func(e Expr) bool {
+		if attr, ok := e.(*AttributeExpr); ok {
+			attributes = append(attributes, attr.Path.Attribute)
+		}
+		return true
+	})
+	if want := []string{"userName", "emails", "type"}; !reflect.DeepEqual(attributes, want) {
+		t.Errorf("Walk() visited %v, want %v", attributes, want)
+	}
+
+	// Returning false skips the children of a node
+	visited := 0
+	Walk(expr, func(e Expr) bool {
+		visited++
+		_, isNot := e.(*NotExpr)
+		return !isNot
+	})
+	if visited != 3 {
+		t.Errorf("Walk() visited %d nodes, want the and, its attribute and not", visited)
+	}
+}
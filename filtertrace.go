@@ -0,0 +1,66 @@
+package scimgateway
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// FilterTraceMiddleware records how the filter of each request is applied
+// (see scimcontext.TraceFilter) and exposes the decisions according to mode:
+// config.FilterTraceHeader sets them, joined by "; ", as the X-Filter-Trace
+// response header, config.FilterTraceLog logs them at debug level. Requests
+// without decisions are left as they are.
+func FilterTraceMiddleware(mode string, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace := &scimcontext.FilterTrace{}
+			r = r.WithContext(scimcontext.WithFilterTracer(r.Context(), trace))
+
+			if mode == config.FilterTraceHeader {
+				w = &filterTraceWriter{ResponseWriter: w, trace: trace}
+			}
+			next.ServeHTTP(w, r)
+
+			if decisions := trace.Decisions(); mode == config.FilterTraceLog && len(decisions) > 0 {
+				logger.DebugContext(r.Context(), "filter trace",
+					"path", r.URL.Path,
+					"filter", r.URL.Query().Get("filter"),
+					"decisions", decisions,
+				)
+			}
+		})
+	}
+}
+
+// filterTraceWriter sets the X-Filter-Trace header when the response header
+// is written, after the handler took its filter decisions
+type filterTraceWriter struct {
+	http.ResponseWriter
+	trace   *scimcontext.FilterTrace
+	written bool
+}
+
+func (fw *filterTraceWriter) WriteHeader(code int) {
+	if !fw.written {
+		fw.written = true
+		if trace := fw.trace.String(); trace != "" {
+			fw.Header().Set("X-Filter-Trace", trace)
+		}
+	}
+	fw.ResponseWriter.WriteHeader(code)
+}
+
+func (fw *filterTraceWriter) Write(b []byte) (int, error) {
+	if !fw.written {
+		fw.WriteHeader(http.StatusOK)
+	}
+	return fw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (fw *filterTraceWriter) Unwrap() http.ResponseWriter {
+	return fw.ResponseWriter
+}
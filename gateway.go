@@ -1,14 +1,25 @@
 package scimgateway
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/metrics"
 	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scheduler"
 	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+	"github.com/marcelom97/scimgateway/version"
 )
 
 // discardLogger returns a no-op logger that discards all output
@@ -23,15 +34,39 @@ type Gateway struct {
 	server        *scim.Server
 	handler       http.Handler
 	logger        *slog.Logger
+	metrics       *metrics.Registry
+	schemas       *scim.SchemaRegistry
+	clock         clock.Clock
+	messages      *scim.MessageCatalog
+	passwords     scim.PasswordHasher
+	encoder       scim.Encoder
+	scheduler     *scheduler.Scheduler
+
+	active      atomic.Pointer[http.Handler] // current middleware chain, swapped by Reload
+	inFlight    atomic.Int64                 // requests being served, reported by Shutdown
+	certs       *certificateStore            // serving certificate when TLS is enabled
+	httpServer  *http.Server                 // server started by Start
+	stopWorkers context.CancelFunc           // stops the background workers started by Start
+	jobs        []string                     // names of the jobs scheduled by scheduleJobs
+	mu          sync.RWMutex                 // protects config, server, httpServer, stopWorkers and jobs
+	reloadMu    sync.Mutex                   // serializes Reload calls
 }
 
 // New creates a new Gateway instance
 func New(cfg *config.Config) *Gateway {
-	return &Gateway{
+	g := &Gateway{
 		config:        cfg,
 		pluginManager: plugin.NewManager(),
 		logger:        discardLogger(), // Default to no-op logger
+		metrics:       metrics.NewRegistry(),
+		schemas:       scim.NewSchemaRegistry(),
+		clock:         clock.System,
+		scheduler:     scheduler.New(),
 	}
+	g.pluginManager.OnQuotaExceeded(g.logQuotaExceeded)
+	g.pluginManager.OnQuotaWarning(g.logQuotaWarning)
+	g.pluginManager.OnApprovalRequested(g.logApprovalRequested)
+	return g
 }
 
 // NewWithDefaults creates a new Gateway with default valid configuration
@@ -42,32 +77,214 @@ func NewWithDefaults() *Gateway {
 // RegisterPlugin registers a plugin with the gateway
 // The plugin config is automatically looked up from the gateway config by plugin name
 func (g *Gateway) RegisterPlugin(p plugin.Plugin) {
-	// Find the plugin config by name
-	var pluginCfg *config.PluginConfig
-	for i := range g.config.Plugins {
-		if g.config.Plugins[i].Name == p.Name() {
-			pluginCfg = &g.config.Plugins[i]
-			break
+	g.pluginManager.Register(p, findPluginConfig(g.Config(), p.Name()))
+}
+
+// RegisterFailover registers a primary and a standby plugin under the route
+// name of the primary. Requests fail over to the standby when the primary's
+// health check (see plugin.HealthChecker) fails for failover.failureThreshold
+// consecutive intervals of the primary's plugin config, and fail back once it
+// recovered. Switches are logged and counted in
+// scimgateway_plugin_failovers_total.
+//
+// Health checks run on the gateway's scheduler as job "<name>/health-check"
+// (see Scheduler).
+func (g *Gateway) RegisterFailover(primary, standby plugin.Plugin) *plugin.Failover {
+	cfg := findPluginConfig(g.Config(), primary.Name())
+
+	opts := plugin.FailoverOptions{Clock: g.clock}
+	if cfg != nil && cfg.Failover != nil {
+		opts.Interval = cfg.Failover.Interval
+		opts.FailureThreshold = cfg.Failover.FailureThreshold
+		opts.Timeout = cfg.Failover.Timeout
+	}
+
+	pair := plugin.NewFailover(primary.Name(), primary, standby, opts)
+	pair.OnSwitch(g.logFailover)
+	g.pluginManager.Register(pair, cfg)
+	return pair
+}
+
+// logFailover logs and counts a switch of a failover pair
+func (g *Gateway) logFailover(event plugin.FailoverEvent) {
+	if event.To == plugin.FailoverStandby {
+		g.logger.Warn("plugin failed over to standby", "plugin", event.Name, "error", event.Err)
+	} else {
+		g.logger.Info("plugin failed back to primary", "plugin", event.Name)
+	}
+	g.metrics.Counter("scimgateway_plugin_failovers_total",
+		"Total number of switches between the primary and standby plugin of a route.",
+		metrics.Labels{"plugin": event.Name, "to": string(event.To)},
+	).Inc()
+}
+
+// logDeprecations warns of the keys of the configuration file that were
+// migrated from an older layout, so they are updated before a release stops
+// migrating them
+func (g *Gateway) logDeprecations(cfg *config.Config) {
+	for _, d := range cfg.Deprecations {
+		g.logger.Warn("deprecated configuration key",
+			"file", d.File,
+			"line", d.Line,
+			"field", d.Field,
+			"migration", d.Message,
+		)
+	}
+}
+
+// logQuotaExceeded records a create rejected by a plugin's quota in the
+// audit log and counts it in scimgateway_quota_rejections_total
+func (g *Gateway) logQuotaExceeded(event plugin.QuotaEvent) {
+	g.logger.Warn("create rejected by quota",
+		"audit", true,
+		"plugin", event.Plugin,
+		"baseEntity", event.BaseEntity,
+		"resourceType", event.ResourceType,
+		"limit", event.Limit,
+		"count", event.Count,
+		"subject", event.Subject,
+	)
+	g.metrics.Counter("scimgateway_quota_rejections_total",
+		"Total number of creates rejected because a plugin reached its quota.",
+		metrics.Labels{"plugin": event.Plugin, "resource": event.ResourceType},
+	).Inc()
+}
+
+// logQuotaWarning logs a create bringing a plugin near its quota and counts
+// it in scimgateway_quota_warnings_total
+func (g *Gateway) logQuotaWarning(event plugin.QuotaEvent) {
+	g.logger.Warn("quota nearly reached",
+		"plugin", event.Plugin,
+		"baseEntity", event.BaseEntity,
+		"resourceType", event.ResourceType,
+		"limit", event.Limit,
+		"count", event.Count,
+		"subject", event.Subject,
+	)
+	g.metrics.Counter("scimgateway_quota_warnings_total",
+		"Total number of creates that brought a plugin within the warning share of its quota.",
+		metrics.Labels{"plugin": event.Plugin, "resource": event.ResourceType},
+	).Inc()
+}
+
+// logApprovalRequested records a change held for approval in the audit log
+func (g *Gateway) logApprovalRequested(op *plugin.Operation) {
+	g.logger.Info("change held for approval",
+		"audit", true,
+		"plugin", op.Plugin,
+		"baseEntity", op.BaseEntity,
+		"resourceType", op.ResourceType,
+		"resourceId", op.ResourceID,
+		"operation", op.ID,
+		"attributes", op.Approval.Attributes,
+		"subject", op.Approval.RequestedBy,
+	)
+}
+
+// RegisterSchemaExtension registers a custom extension schema for Users or
+// Groups. Registered extensions are listed by the Schemas and ResourceTypes
+// endpoints of every plugin, their attributes are validated on create, replace
+// and patch, and plugins receive their data in User.Extensions and
+// Group.Extensions. Register extensions before calling Initialize.
+func (g *Gateway) RegisterSchemaExtension(ext scim.SchemaExtension) error {
+	return g.schemas.Register(ext)
+}
+
+// SetAttributeMutability overrides the mutability of a User or Group
+// attribute, e.g. making userName immutable. PATCH and PUT requests modifying
+// readOnly attributes, or immutable ones that already have a value, fail with
+// scimType "mutability". id, meta and User groups are readOnly by default.
+func (g *Gateway) SetAttributeMutability(resourceType, attribute, mutability string) error {
+	return g.schemas.SetMutability(resourceType, attribute, mutability)
+}
+
+// findPluginConfig returns the config for the named plugin, or nil if there is none
+func findPluginConfig(cfg *config.Config, name string) *config.PluginConfig {
+	for i := range cfg.Plugins {
+		if cfg.Plugins[i].Name == name {
+			return &cfg.Plugins[i]
 		}
 	}
-	g.pluginManager.Register(p, pluginCfg)
+	return nil
 }
 
 // SetLogger sets the optional logger for the gateway.
 // Pass nil to disable logging (default behavior).
 // The logger will be used to log critical errors and warnings only.
+// Entries logged while serving a request carry its request_id.
 func (g *Gateway) SetLogger(logger *slog.Logger) {
 	if logger == nil {
 		g.logger = discardLogger()
 	} else {
-		g.logger = logger
+		g.logger = slog.New(scimcontext.NewLogHandler(logger.Handler()))
 	}
 }
 
+// SetClock sets the time source of the gateway. Plugins read it from the
+// request context with clock.Now, e.g. for meta timestamps, and token-based
+// authenticators validate tokens at its time, allowing for the
+// gateway.clockSkew of the configuration. Pass nil to use the system clock
+// (default behavior). Call SetClock before Initialize.
+func (g *Gateway) SetClock(c clock.Clock) {
+	if c == nil {
+		c = clock.System
+	}
+	g.clock = c
+	g.scheduler.SetClock(c)
+}
+
+// SetOperationStore sets the store of the writes queued by plugins
+// configured with asyncWrites. Pass a durable store such as
+// plugin.NewFileOperationStore to keep queued writes across restarts; nil
+// keeps them in memory (default behavior).
+func (g *Gateway) SetOperationStore(store plugin.OperationStore) {
+	g.pluginManager.SetOperationStore(store)
+}
+
+// SetCacheStore sets the store of the caches of plugins configured with
+// cache, e.g. one shared by several gateway instances; nil keeps each
+// plugin's cache in memory (default behavior).
+func (g *Gateway) SetCacheStore(store plugin.CacheStore) {
+	g.pluginManager.SetCacheStore(store)
+}
+
+// SetAuditStore sets the store of the audit logs of plugins configured with
+// audit, e.g. a database keeping the history of resources across restarts;
+// nil keeps each plugin's events in memory (default behavior).
+func (g *Gateway) SetAuditStore(store plugin.AuditStore) {
+	g.pluginManager.SetAuditStore(store)
+}
+
+// SetPasswordHasher sets the hasher applied to user passwords before they are
+// passed to plugins, on create, replace and PATCH requests setting the
+// password. Pass nil to pass passwords as sent (default behavior). Passwords
+// are never returned in responses.
+func (g *Gateway) SetPasswordHasher(hasher scim.PasswordHasher) {
+	g.passwords = hasher
+}
+
+// SetMessageCatalog sets the catalog that translates the detail of SCIM
+// error responses into the language of the client's Accept-Language header.
+// Pass nil to write details in English (default behavior).
+func (g *Gateway) SetMessageCatalog(catalog *scim.MessageCatalog) {
+	g.messages = catalog
+}
+
+// SetEncoder sets the encoder of the SCIM responses of the gateway, such as
+// scim.CompactJSONEncoder. Pass nil to write JSON (default behavior), or
+// canonical JSON if gateway.canonicalJSON is set. To
+// serve another encoding besides JSON, e.g. to internal clients, wrap the
+// Handler with scim.EncoderMiddleware on a separate listener instead.
+func (g *Gateway) SetEncoder(enc scim.Encoder) {
+	g.encoder = enc
+}
+
 // Initialize initializes the gateway (must be called before Start)
 func (g *Gateway) Initialize() error {
+	cfg := g.Config()
+
 	// Validate configuration first
-	if err := g.config.Validate(); err != nil {
+	if err := cfg.Validate(); err != nil {
 		g.logger.Error("configuration validation failed", "error", err)
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -79,36 +296,273 @@ func (g *Gateway) Initialize() error {
 		return err
 	}
 
+	build := version.Get()
 	g.logger.Info("initializing SCIM gateway",
-		"base_url", g.config.Gateway.BaseURL,
-		"port", g.config.Gateway.Port,
-		"tls_enabled", g.config.Gateway.TLS != nil && g.config.Gateway.TLS.Enabled,
+		"version", build.Version,
+		"commit", build.Commit,
+		"go_version", build.GoVersion,
+		"base_url", cfg.Gateway.BaseURL,
+		"port", cfg.Gateway.Port,
+		"tls_enabled", cfg.Gateway.TLS != nil && cfg.Gateway.TLS.Enabled,
+	)
+	g.logger.Debug("effective configuration", "config", cfg)
+	g.logDeprecations(cfg)
+
+	// Let plugins set up their backends (see plugin.Initializer)
+	if err := g.pluginManager.Init(context.Background()); err != nil {
+		g.logger.Error("plugin initialization failed", "error", err)
+		return err
+	}
+
+	// Tune and instrument database-backed plugins
+	g.setupDBPlugins()
+
+	g.scheduleJobs()
+
+	g.install(cfg)
+	g.handler = http.HandlerFunc(g.serveActive)
+
+	pluginNames := g.pluginManager.List()
+	g.logger.Info("gateway initialized successfully",
+		"plugins", pluginNames,
+		"plugin_count", len(pluginNames),
 	)
 
+	return nil
+}
+
+// install builds the SCIM server and middleware chain for cfg and makes it the
+// active handler. Requests already in flight finish on the previous chain.
+func (g *Gateway) install(cfg *config.Config) {
 	// Create adapted manager
 	adaptedManager := plugin.NewAdaptedManager(g.pluginManager)
 
 	// Create SCIM server with logger
-	g.server = scim.NewServerWithLogger(g.config.Gateway.BaseURL, adaptedManager, g.logger)
+	server := scim.NewServerWithLogger(cfg.Gateway.BaseURL, adaptedManager, g.logger)
+	server.SetSchemaRegistry(g.schemas)
+	server.SetMetrics(g.metrics)
+	server.SetMessageCatalog(g.messages)
+	server.SetPasswordHasher(g.passwords)
+	server.SetPropagatedHeaders(cfg.Gateway.PropagateHeaders)
+	server.SetPaginationLinks(cfg.Gateway.PaginationLinks)
+
+	// Validate tokens at the gateway clock with the configured skew
+	g.pluginManager.SetClock(g.clock, cfg.Gateway.ClockSkew)
+
+	// Call authorization servers with the configured outbound client settings
+	g.pluginManager.SetHTTPClientConfig(cfg.Gateway.HTTPClient)
+	g.pluginManager.SetNormalizeConfig(cfg.Gateway.Normalize)
+
+	// Log the failures plugin wrappers handle, such as membership fan-out
+	g.pluginManager.SetLogger(g.logger)
+
+	// Expose the circuit state of plugins configured with circuitBreaker
+	g.registerBreakerMetrics()
+
+	// Expose the error budget of plugins configured with errorBudget
+	g.registerErrorBudgets()
+
+	// Log and expose the queues of plugins configured with writeWindow
+	g.registerWriteWindows()
 
 	// Setup handler with middleware chain
-	var handler http.Handler = g.server
+	var handler http.Handler = server
+
+	// Serve the write queue admin endpoints
+	handler = WriteWindowMiddleware(g.pluginManager)(handler)
+
+	// Serve the error budget of plugins
+	handler = ErrorBudgetMiddleware(g.pluginManager)(handler)
+
+	// Serve the status of asynchronous writes
+	handler = AsyncWritesMiddleware(g.pluginManager)(handler)
+
+	// Serve and decide the changes held for approval
+	handler = ApprovalsMiddleware(g.pluginManager)(handler)
+
+	// Serve the history of resources from the audit logs
+	handler = HistoryMiddleware(g.pluginManager)(handler)
+
+	// Record the exchanges of plugins configured with recording, and serve
+	// and replay them
+	handler = RecordingMiddleware(g.pluginManager, g.logger)(handler)
+
+	// Make the gateway clock available to plugins
+	handler = ClockMiddleware(g.clock)(handler)
+
+	// Warn clients of creates nearing a quota
+	handler = QuotaWarningMiddleware()(handler)
+
+	// Expose how list filters are applied
+	if cfg.Gateway.FilterTrace != "" {
+		handler = FilterTraceMiddleware(cfg.Gateway.FilterTrace, g.logger)(handler)
+	}
 
 	// Add request logging middleware
 	handler = LoggingMiddleware(g.logger)(handler)
 
+	// Restrict the operations of authenticated clients to those granted to
+	// their scopes or roles
+	handler = plugin.AuthorizationMiddleware(g.pluginManager)(handler)
+
 	// Add per-plugin authentication middleware
 	handler = plugin.PerPluginAuthMiddleware(g.pluginManager)(handler)
 
-	g.handler = handler
+	// Route /{plugin}/{baseEntity}/... to the plugin, scoped to the base entity
+	handler = plugin.BaseEntityMiddleware(g.pluginManager)(handler)
 
-	pluginNames := g.pluginManager.List()
-	g.logger.Info("gateway initialized successfully",
-		"plugins", pluginNames,
-		"plugin_count", len(pluginNames),
-	)
+	// Serve /Users, /Groups and /ServiceProviderConfig without a plugin prefix
+	if cfg.Gateway.RootEndpoints != nil {
+		plugins := make([]string, 0, len(cfg.Plugins))
+		for _, p := range cfg.Plugins {
+			plugins = append(plugins, p.Name)
+		}
+		handler = RootEndpointsMiddleware(cfg.Gateway.RootEndpoints, plugins)(handler)
+	}
 
-	return nil
+	// Negotiate the media types of requests to plugins and their responses
+	if cfg.Gateway.StrictContentType {
+		handler = scim.StrictContentTypeMiddleware()(handler)
+	}
+
+	// Serve health probes without authentication
+	handler = HealthMiddleware(g.pluginManager, g.logger)(handler)
+
+	// Report the build without authentication
+	handler = VersionMiddleware()(handler)
+
+	// Encode responses with the configured encoder
+	encoder := g.encoder
+	if encoder == nil && cfg.Gateway.CanonicalJSON {
+		encoder = scim.CanonicalJSONEncoder{}
+	}
+	if encoder != nil {
+		handler = scim.EncoderMiddleware(encoder)(handler)
+	}
+
+	// Correlate the logs, errors and plugin calls of each request
+	handler = RequestIDMiddleware()(handler)
+
+	g.mu.Lock()
+	g.server = server
+	g.mu.Unlock()
+	g.active.Store(&handler)
+}
+
+// registerBreakerMetrics registers a scimgateway_circuit_breaker_open gauge
+// per resource type of every plugin with circuit breakers. The breakers are
+// looked up at scrape time, so gauges follow breakers recreated on reload.
+func (g *Gateway) registerBreakerMetrics() {
+	for _, name := range g.pluginManager.List() {
+		if _, ok := g.pluginManager.GetBreakers(name); !ok {
+			continue
+		}
+		for _, resource := range []string{"Users", "Groups"} {
+			g.metrics.GaugeFunc("scimgateway_circuit_breaker_open",
+				"Whether the circuit breaker of a plugin's resource type rejects requests (1) or not (0).",
+				metrics.Labels{"plugin": name, "resource": resource},
+				func() float64 {
+					breakers, ok := g.pluginManager.GetBreakers(name)
+					if !ok {
+						return 0
+					}
+					breaker := breakers.Users
+					if resource == "Groups" {
+						breaker = breakers.Groups
+					}
+					if breaker.State() == plugin.CircuitOpen {
+						return 1
+					}
+					return 0
+				},
+			)
+		}
+	}
+}
+
+// serveActive dispatches a request to the currently active handler chain
+func (g *Gateway) serveActive(w http.ResponseWriter, r *http.Request) {
+	g.inFlight.Add(1)
+	defer g.inFlight.Add(-1)
+	(*g.active.Load()).ServeHTTP(w, r)
+}
+
+// setupDBPlugins applies pool settings to plugins implementing plugin.DBProvider
+// and registers their connection pool metrics
+func (g *Gateway) setupDBPlugins() {
+	for _, name := range g.pluginManager.List() {
+		p, _ := g.pluginManager.Get(name)
+		provider, ok := p.(plugin.DBProvider)
+		if !ok {
+			continue
+		}
+		db := provider.DB()
+		if db == nil {
+			continue
+		}
+
+		if cfg, ok := g.pluginManager.GetConfig(name); ok && cfg.Pool != nil {
+			cfg.Pool.Apply(db)
+			g.logger.Info("applied connection pool settings",
+				"plugin", name,
+				"max_open_conns", cfg.Pool.MaxOpenConns,
+				"max_idle_conns", cfg.Pool.MaxIdleConns,
+				"conn_max_lifetime", cfg.Pool.ConnMaxLifetime,
+				"conn_max_idle_time", cfg.Pool.ConnMaxIdleTime,
+			)
+		}
+
+		metrics.RegisterDBStats(g.metrics, name, db)
+	}
+}
+
+// Warm primes the caches of plugins whose config enables warmup by listing
+// all their users and groups at the configured rate (see plugin.Warm).
+// Plugins are warmed one after another; failures are logged and returned
+// together. Start warms plugins in the background while it serves requests;
+// embedded gateways call Warm themselves after Initialize:
+//
+//	go gw.Warm(ctx)
+func (g *Gateway) Warm(ctx context.Context) error {
+	names := g.pluginManager.List()
+	slices.Sort(names)
+
+	var errs []error
+	for _, name := range names {
+		cfg, ok := g.pluginManager.GetConfig(name)
+		if !ok || cfg.Warmup == nil || !cfg.Warmup.Enabled {
+			continue
+		}
+		p, ok := g.pluginManager.Get(name)
+		if !ok {
+			continue
+		}
+
+		cache, _ := g.pluginManager.GetCache(name)
+		start := time.Now()
+		result, err := plugin.Warm(ctx, p, plugin.WarmOptions{
+			PageSize: cfg.Warmup.PageSize,
+			Interval: cfg.Warmup.Interval,
+			Cache:    cache,
+		})
+		if err != nil {
+			g.logger.Error("failed to warm plugin",
+				"plugin", name,
+				"users", result.Users,
+				"groups", result.Groups,
+				"error", err,
+			)
+			errs = append(errs, fmt.Errorf("plugin %s: %w", name, err))
+			continue
+		}
+		g.logger.Info("warmed plugin",
+			"plugin", name,
+			"users", result.Users,
+			"groups", result.Groups,
+			"duration", time.Since(start),
+		)
+	}
+	return errors.Join(errs...)
 }
 
 // Handler returns the HTTP handler for the gateway.
@@ -129,42 +583,93 @@ func (g *Gateway) Start() error {
 		}
 	}
 
-	if g.config.Gateway.Port == 0 {
+	cfg := g.Config()
+	if cfg.Gateway.Port == 0 {
 		return fmt.Errorf("port is required for standalone mode - use Handler() for embedded mode")
 	}
 
-	addr := fmt.Sprintf(":%d", g.config.Gateway.Port)
+	addr := fmt.Sprintf(":%d", cfg.Gateway.Port)
+
+	// Prime plugin caches while the server starts; failures are logged by Warm
+	go g.Warm(context.Background()) // nolint:errcheck
+
+	// Apply queued writes when write windows open and asynchronous writes,
+	// and run maintenance jobs in the background, until Shutdown
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	g.mu.Lock()
+	g.stopWorkers = stopWorkers
+	g.mu.Unlock()
+	go g.pluginManager.RunWriteWindows(workersCtx)
+	go g.pluginManager.RunAsyncWrites(workersCtx)
+	go g.scheduler.Run(workersCtx) // nolint:errcheck
+
+	if cfg.Gateway.TLS != nil && cfg.Gateway.TLS.Enabled {
+		// Serve the certificate through a store so Reload can rotate it
+		certs, err := loadCertificateStore(cfg.Gateway.TLS)
+		if err != nil {
+			g.logger.Error("failed to load TLS certificate", "error", err)
+			return err
+		}
+		g.mu.Lock()
+		g.certs = certs
+		g.mu.Unlock()
 
-	if g.config.Gateway.TLS != nil && g.config.Gateway.TLS.Enabled {
 		g.logger.Info("starting SCIM gateway with TLS",
 			"addr", addr,
-			"cert_file", g.config.Gateway.TLS.CertFile,
-		)
-		err := http.ListenAndServeTLS(
-			addr,
-			g.config.Gateway.TLS.CertFile,
-			g.config.Gateway.TLS.KeyFile,
-			g.handler,
+			"cert_file", cfg.Gateway.TLS.CertFile,
+			"client_certificates", cfg.UsesMTLS(),
 		)
-		if err != nil {
-			g.logger.Error("gateway server stopped", "error", err)
+		server := &http.Server{
+			Addr:      addr,
+			Handler:   g.handler,
+			TLSConfig: g.serverTLSConfig(certs),
 		}
-		return err
+		return g.serve(server, func() error { return server.ListenAndServeTLS("", "") })
 	}
 
 	g.logger.Info("starting SCIM gateway", "addr", addr)
-	err := http.ListenAndServe(addr, g.handler)
-	if err != nil {
-		g.logger.Error("gateway server stopped", "error", err)
+	server := &http.Server{Addr: addr, Handler: g.handler}
+	return g.serve(server, server.ListenAndServe)
+}
+
+// serve runs listen for server until it fails or Shutdown stops it
+func (g *Gateway) serve(server *http.Server, listen func() error) error {
+	g.mu.Lock()
+	g.httpServer = server
+	g.mu.Unlock()
+
+	err := listen()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
 	}
+	g.logger.Error("gateway server stopped", "error", err)
+	return err
+}
+
+// Shutdown gracefully stops the server started by Start, waiting for
+// in-flight requests until ctx is done, and then closes the plugins
+// implementing plugin.Closer. Embedded gateways call it when their own
+// server stopped, to release plugin resources. It logs a ShutdownReport.
+func (g *Gateway) Shutdown(ctx context.Context) error {
+	_, err := g.ShutdownWithReport(ctx)
 	return err
 }
 
 // Config returns the gateway configuration
 func (g *Gateway) Config() *config.Config {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 	return g.config
 }
 
+// Metrics returns the gateway metrics registry.
+// It implements http.Handler and can be mounted to expose metrics for scraping:
+//
+//	mux.Handle("/metrics", gw.Metrics())
+func (g *Gateway) Metrics() *metrics.Registry {
+	return g.metrics
+}
+
 // PluginManager returns the plugin manager
 func (g *Gateway) PluginManager() *plugin.Manager {
 	return g.pluginManager
@@ -3,14 +3,23 @@ package scimgateway
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/config"
 	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/metrics"
 	"github.com/marcelom97/scimgateway/scim"
 )
 
@@ -61,6 +70,23 @@ func (p *mockPlugin) DeleteGroup(ctx context.Context, id string) error {
 	return nil
 }
 
+// dbPlugin is a mockPlugin backed by a database/sql pool
+type dbPlugin struct {
+	mockPlugin
+	db *sql.DB
+}
+
+func (p *dbPlugin) DB() *sql.DB { return p.db }
+
+// nopConnector lets tests create a *sql.DB without a real driver
+type nopConnector struct{}
+
+func (nopConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (nopConnector) Driver() driver.Driver { return nil }
+
 func TestNew(t *testing.T) {
 	cfg := &config.Config{
 		Gateway: config.GatewayConfig{
@@ -120,6 +146,307 @@ func TestRegisterPlugin(t *testing.T) {
 	}
 }
 
+func TestRegisterSchemaExtension(t *testing.T) {
+	urn := "urn:example:scim:schemas:extension:acme:1.0:User"
+	gw := New(bearerConfig("token"))
+	err := gw.RegisterSchemaExtension(scim.SchemaExtension{
+		ResourceType: scim.ResourceTypeUser,
+		Schema: &scim.SchemaDefinition{
+			ID:         urn,
+			Attributes: []scim.AttributeDefinition{{Name: "costCenter", Type: "string"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterSchemaExtension() error = %v", err)
+	}
+	if err := gw.RegisterSchemaExtension(scim.SchemaExtension{ResourceType: scim.ResourceTypeUser}); err == nil {
+		t.Error("RegisterSchemaExtension() with an invalid extension should fail")
+	}
+
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/test/Schemas", ""); !strings.Contains(w.Body.String(), urn) {
+		t.Errorf("Schemas does not list the extension: %s", w.Body.String())
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice", "`+urn+`": {"costCenter": "CC-1"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var created scim.User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	w = do("GET", "/test/Users/"+created.ID, "")
+	var user scim.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Extensions[urn]["costCenter"] != "CC-1" || !slices.Contains(user.Schemas, urn) {
+		t.Errorf("stored user = %s, want the extension data and URN", w.Body.String())
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	gw.SetClock(clock.NewFake(now))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	req := httptest.NewRequest("POST", "/test/Users", strings.NewReader(`{"userName": "alice"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	var created scim.User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Meta == nil || created.Meta.Created == nil || !created.Meta.Created.Equal(now) {
+		t.Errorf("meta = %+v, want created at the gateway clock", created.Meta)
+	}
+}
+
+func TestSetMessageCatalog(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	catalog := scim.NewMessageCatalog()
+	catalog.Add("de", map[string]string{"Plugin '%s' not found": "Plugin '%s' nicht gefunden"})
+	gw.SetMessageCatalog(catalog)
+	gw.RegisterPlugin(&mockPlugin{name: "test"})
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	req := httptest.NewRequest("GET", "/unknown/Users", nil)
+	req.Header.Set("Accept-Language", "de")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "Plugin 'unknown' nicht gefunden") {
+		t.Errorf("body = %s, want the translated detail", w.Body.String())
+	}
+}
+
+func TestWarm(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Warmup = &config.WarmupConfig{Enabled: true, Interval: time.Millisecond}
+	cfg.Plugins = append(cfg.Plugins, config.PluginConfig{Name: "cold"})
+	gw := New(cfg)
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	p := testutil.NewMemoryPlugin("test")
+	for _, name := range []string{"alice", "bob"} {
+		if _, err := p.CreateUser(context.Background(), &scim.User{UserName: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gw.RegisterPlugin(p)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("cold"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	if err := gw.Warm(context.Background()); err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "msg=\"warmed plugin\" plugin=test users=2 groups=0") {
+		t.Errorf("log = %s, want the warmed plugin", logs.String())
+	}
+	if strings.Contains(logs.String(), "plugin=cold users") {
+		t.Errorf("log = %s, want plugins without warmup skipped", logs.String())
+	}
+}
+
+// groupsDownPlugin is a memory plugin whose group store is unreachable
+type groupsDownPlugin struct {
+	*testutil.MemoryPlugin
+}
+
+func (p groupsDownPlugin) GetGroups(context.Context, scim.QueryParams) ([]*scim.Group, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestCircuitBreakerPerResourceType(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1}
+	gw := New(cfg)
+	gw.RegisterPlugin(groupsDownPlugin{testutil.NewMemoryPlugin("test")})
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	get := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get("/test/Groups"); code != http.StatusInternalServerError {
+		t.Errorf("first groups status = %d, want 500", code)
+	}
+	if code := get("/test/Groups"); code != http.StatusServiceUnavailable {
+		t.Errorf("groups status = %d, want 503 once the circuit is open", code)
+	}
+	if code := get("/test/Users"); code != http.StatusOK {
+		t.Errorf("users status = %d, want 200", code)
+	}
+
+	groupsOpen, _ := gw.Metrics().Value("scimgateway_circuit_breaker_open", metrics.Labels{"plugin": "test", "resource": "Groups"})
+	usersOpen, _ := gw.Metrics().Value("scimgateway_circuit_breaker_open", metrics.Labels{"plugin": "test", "resource": "Users"})
+	if groupsOpen != 1 || usersOpen != 0 {
+		t.Errorf("circuit_breaker_open = groups %v, users %v; want 1, 0", groupsOpen, usersOpen)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Quota = &config.QuotaConfig{MaxUsers: 1}
+	gw := New(cfg)
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	create := func(userName string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/test/Users", strings.NewReader(`{"userName": "`+userName+`"}`))
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := create("alice"); w.Code != http.StatusCreated {
+		t.Fatalf("first create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	w := create("bob")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "quota of plugin 'test' is reached") {
+		t.Errorf("create beyond quota = %d %s, want 403 with the quota", w.Code, w.Body.String())
+	}
+	if !strings.Contains(logs.String(), "create rejected by quota") {
+		t.Errorf("log = %s, want the rejection audited", logs.String())
+	}
+	if v, _ := gw.Metrics().Value("scimgateway_quota_rejections_total", metrics.Labels{"plugin": "test", "resource": "Users"}); v != 1 {
+		t.Errorf("quota rejections = %v, want 1", v)
+	}
+}
+
+func TestQuotaWarning(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Quota = &config.QuotaConfig{MaxUsers: 2, WarnPercent: 50}
+	gw := New(cfg)
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	req := httptest.NewRequest("POST", "/test/Users", strings.NewReader(`{"userName": "alice"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if got, want := w.Header().Get("X-Quota-Warning"), "50% of the Users quota of plugin 'test' is used: 1 of 2 Users exist"; got != want {
+		t.Errorf("X-Quota-Warning = %q, want %q", got, want)
+	}
+	if !strings.Contains(logs.String(), "quota nearly reached") {
+		t.Errorf("log = %s, want the warning logged", logs.String())
+	}
+	if v, _ := gw.Metrics().Value("scimgateway_quota_warnings_total", metrics.Labels{"plugin": "test", "resource": "Users"}); v != 1 {
+		t.Errorf("quota warnings = %v, want 1", v)
+	}
+}
+
+// unhealthyPlugin is a memory plugin whose health check fails
+type unhealthyPlugin struct {
+	*testutil.MemoryPlugin
+}
+
+func (p unhealthyPlugin) HealthCheck(context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestRegisterFailover(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Failover = &config.FailoverConfig{FailureThreshold: 1}
+	gw := New(cfg)
+
+	standby := testutil.NewMemoryPlugin("test")
+	if _, err := standby.CreateUser(context.Background(), &scim.User{UserName: "standby-user"}); err != nil {
+		t.Fatal(err)
+	}
+	pair := gw.RegisterFailover(unhealthyPlugin{testutil.NewMemoryPlugin("test")}, standby)
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	listUsers := func() int {
+		req := httptest.NewRequest("GET", "/test/Users", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list status = %d, body: %s", w.Code, w.Body.String())
+		}
+		var resp scim.ListResponse[*scim.User]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.TotalResults
+	}
+
+	if got := listUsers(); got != 0 {
+		t.Errorf("TotalResults = %d, want the primary's users", got)
+	}
+
+	pair.Check(context.Background())
+
+	if got := listUsers(); got != 1 {
+		t.Errorf("TotalResults = %d after failover, want the standby's users", got)
+	}
+	if v, _ := gw.Metrics().Value("scimgateway_plugin_failovers_total", metrics.Labels{"plugin": "test", "to": "standby"}); v != 1 {
+		t.Errorf("failovers = %v, want 1", v)
+	}
+}
+
 func TestInitialize(t *testing.T) {
 	cfg := &config.Config{
 		Gateway: config.GatewayConfig{
@@ -149,6 +476,44 @@ func TestInitialize(t *testing.T) {
 	}
 }
 
+func TestInitializeDBPlugins(t *testing.T) {
+	cfg := &config.Config{
+		Gateway: config.GatewayConfig{
+			BaseURL: "http://localhost:8080",
+		},
+		Plugins: []config.PluginConfig{
+			{Name: "db", Pool: &config.PoolConfig{MaxOpenConns: 25, MaxIdleConns: 5}},
+			{Name: "memory"},
+		},
+	}
+
+	db := sql.OpenDB(nopConnector{})
+	defer db.Close() // nolint:errcheck
+
+	gw := New(cfg)
+	gw.RegisterPlugin(&dbPlugin{mockPlugin: mockPlugin{name: "db"}, db: db})
+	gw.RegisterPlugin(&mockPlugin{name: "memory"})
+
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	if got := db.Stats().MaxOpenConnections; got != 25 {
+		t.Errorf("MaxOpenConnections = %d, want 25", got)
+	}
+
+	w := httptest.NewRecorder()
+	gw.Metrics().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	if !strings.Contains(body, `scimgateway_db_max_open_connections{plugin="db"} 25`) {
+		t.Errorf("metrics should expose pool stats for db plugin:\n%s", body)
+	}
+	if strings.Contains(body, `plugin="memory"`) {
+		t.Errorf("metrics should not expose pool stats for non-DB plugins:\n%s", body)
+	}
+}
+
 func TestHandler(t *testing.T) {
 	cfg := &config.Config{
 		Gateway: config.GatewayConfig{
@@ -894,6 +1259,97 @@ func TestRequestLoggingIntegration(t *testing.T) {
 	}
 }
 
+func TestRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := bearerConfig("token")
+	gw := New(cfg)
+	gw.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(path, requestID, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if requestID != "" {
+			req.Header.Set("X-Request-Id", requestID)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// The client's ID is honored and returned in errors and logs
+	w := do("/test/Users/missing", "req-42", "token")
+	var scimErr scim.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("X-Request-Id") != "req-42" || scimErr.RequestID != "req-42" {
+		t.Errorf("X-Request-Id = %q, error requestId = %q, want req-42", w.Header().Get("X-Request-Id"), scimErr.RequestID)
+	}
+	if !strings.Contains(buf.String(), `"request_id":"req-42"`) {
+		t.Errorf("log does not carry the request ID: %s", buf.String())
+	}
+
+	// Unauthenticated requests get one too
+	w = do("/test/Users", "req-43", "")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"requestId":"req-43"`) {
+		t.Errorf("unauthenticated status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Missing and unusable IDs are replaced by a generated one
+	for _, requestID := range []string{"", "has spaces", strings.Repeat("x", 129)} {
+		got := do("/test/Users", requestID, "token").Header().Get("X-Request-Id")
+		if got == "" || got == requestID {
+			t.Errorf("X-Request-Id for %q = %q, want a generated ID", requestID, got)
+		}
+	}
+}
+
+func TestBaseEntityRouting(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].BaseEntities = []config.BaseEntityConfig{
+		{Name: "acme", Auth: &config.AuthConfig{Type: "bearer", Bearer: &config.BearerAuth{Token: "acme-token"}}},
+	}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("GET", "/test/acme/Users", "", "token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("base entity with the plugin's token: status = %d, want 401", w.Code)
+	}
+
+	w := do("POST", "/test/acme/Users", `{"userName": "alice"}`, "acme-token")
+	var user scim.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("create: %v, body: %s", err, w.Body.String())
+	}
+	want := "http://localhost:8080/test/acme/Users/" + user.ID
+	if w.Code != http.StatusCreated || w.Header().Get("Location") != want || user.Meta == nil || user.Meta.Location != want {
+		t.Errorf("create status = %d, Location = %q, meta = %+v; want location %s", w.Code, w.Header().Get("Location"), user.Meta, want)
+	}
+
+	if w := do("GET", "/test/acme/Users/"+user.ID, "", "acme-token"); w.Code != http.StatusOK {
+		t.Errorf("get status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
 // ============================================================================
 // Per-Plugin Authentication Tests
 // ============================================================================
@@ -1557,3 +2013,105 @@ func TestResponseWriterMultipleWriteHeaders(t *testing.T) {
 		t.Errorf("Should not contain status 500, got: %s", logOutput)
 	}
 }
+
+func TestCanonicalJSON(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Gateway.CanonicalJSON = true
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	req := httptest.NewRequest("GET", "/test/ServiceProviderConfig", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if !strings.HasPrefix(w.Body.String(), `{"authenticationSchemes":`) {
+		t.Errorf("body = %s, want members sorted by name", w.Body.String())
+	}
+}
+
+func TestStrictContentType(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Gateway.StrictContentType = true
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	serve := func(method, path, contentType, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("POST", "/test/Users", "text/plain", "*/*", `{"userName": "alice"}`); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain body status = %d, want 415", w.Code)
+	}
+	w := serve("POST", "/test/Users", "application/scim+json", "application/scim+json", `{"userName": "alice"}`)
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Type") != "application/scim+json; charset=utf-8" {
+		t.Errorf("create status = %d, Content-Type = %q, want 201 application/scim+json; charset=utf-8", w.Code, w.Header().Get("Content-Type"))
+	}
+	// Health probes are not SCIM requests
+	if w := serve("GET", "/healthz", "", "text/plain", ""); w.Code != http.StatusOK {
+		t.Errorf("health status = %d, want 200", w.Code)
+	}
+}
+
+func TestFilterTrace(t *testing.T) {
+	serve := func(mode string, logger *slog.Logger, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		cfg := bearerConfig("token")
+		cfg.Gateway.FilterTrace = mode
+		gw := New(cfg)
+		gw.SetLogger(logger)
+		gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+		if err := gw.Initialize(); err != nil {
+			t.Fatalf("Initialize() error = %v", err)
+		}
+		handler, _ := gw.Handler()
+
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, body: %s", path, w.Code, w.Body.String())
+		}
+		return w
+	}
+	filtered := "/test/Users?filter=" + url.QueryEscape(`userName eq "alice"`)
+
+	if got := serve(config.FilterTraceHeader, slog.Default(), filtered).Header().Get("X-Filter-Trace"); got != "evaluated in memory" {
+		t.Errorf("X-Filter-Trace = %q, want evaluated in memory", got)
+	}
+	if got := serve(config.FilterTraceHeader, slog.Default(), "/test/Users").Header().Get("X-Filter-Trace"); got != "" {
+		t.Errorf("X-Filter-Trace without a filter = %q, want none", got)
+	}
+	if got := serve("", slog.Default(), filtered).Header().Get("X-Filter-Trace"); got != "" {
+		t.Errorf("X-Filter-Trace when disabled = %q, want none", got)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	w := serve(config.FilterTraceLog, logger, filtered)
+	if w.Header().Get("X-Filter-Trace") != "" {
+		t.Errorf("X-Filter-Trace in log mode = %q, want none", w.Header().Get("X-Filter-Trace"))
+	}
+	if !strings.Contains(buf.String(), `"msg":"filter trace"`) || !strings.Contains(buf.String(), `"decisions":["evaluated in memory"]`) {
+		t.Errorf("log does not carry the filter trace: %s", buf.String())
+	}
+}
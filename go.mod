@@ -1,5 +1,9 @@
 module github.com/marcelom97/scimgateway
 
-go 1.25
+go 1.25.0
 
 require github.com/google/uuid v1.6.0
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require golang.org/x/text v0.40.0
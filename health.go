@@ -0,0 +1,90 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/plugin"
+)
+
+// Health statuses reported by the health endpoints
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusUnhealthy = "unhealthy"
+)
+
+// gatewayHealth is the body of the /healthz endpoint
+type gatewayHealth struct {
+	Status  string            `json:"status"`
+	Plugins map[string]string `json:"plugins"`
+}
+
+// pluginHealth is the body of the /{plugin}/health endpoint
+type pluginHealth struct {
+	Status string `json:"status"`
+}
+
+// HealthMiddleware serves GET /healthz, reporting the health of every
+// registered plugin, and GET /{plugin}/health, reporting the health of one
+// plugin (see plugin.HealthChecker). Both answer 200 when healthy and 503
+// otherwise. They are meant for load balancer and orchestrator probes, so
+// they are served before authentication; check errors are logged instead of
+// returned. Other requests are passed to next.
+func HealthMiddleware(manager *plugin.Manager, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.URL.Path == "/healthz" {
+				health := gatewayHealth{Status: healthStatusHealthy, Plugins: map[string]string{}}
+				for name, err := range manager.CheckHealth(r.Context()) {
+					health.Plugins[name] = healthStatus(err)
+					if err != nil {
+						health.Status = healthStatusUnhealthy
+						logger.WarnContext(r.Context(), "plugin health check failed", "plugin", name, "error", err)
+					}
+				}
+				writeHealth(w, health.Status, health)
+				return
+			}
+
+			name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/health")
+			if !ok || name == "" || strings.Contains(name, "/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if _, registered := manager.Get(name); !registered {
+				next.ServeHTTP(w, r)
+				return
+			}
+			err := manager.HealthCheck(r.Context(), name)
+			if err != nil {
+				logger.WarnContext(r.Context(), "plugin health check failed", "plugin", name, "error", err)
+			}
+			writeHealth(w, healthStatus(err), pluginHealth{Status: healthStatus(err)})
+		})
+	}
+}
+
+// healthStatus returns the status reported for a health check result
+func healthStatus(err error) string {
+	if err != nil {
+		return healthStatusUnhealthy
+	}
+	return healthStatusHealthy
+}
+
+// writeHealth writes a health response, 503 unless status is healthy
+func writeHealth(w http.ResponseWriter, status string, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status != healthStatusHealthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body) // nolint:errcheck
+}
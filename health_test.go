@@ -0,0 +1,109 @@
+package scimgateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/internal/testutil"
+)
+
+// lifecyclePlugin is a memory plugin recording its lifecycle hooks
+type lifecyclePlugin struct {
+	*testutil.MemoryPlugin
+	initErr   error
+	healthErr error
+	inits     int
+	closes    int
+}
+
+func (p *lifecyclePlugin) Init(context.Context) error {
+	p.inits++
+	return p.initErr
+}
+
+func (p *lifecyclePlugin) HealthCheck(context.Context) error {
+	return p.healthErr
+}
+
+func (p *lifecyclePlugin) Close() error {
+	p.closes++
+	return nil
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	cfg := bearerConfig("token")
+	gw := New(cfg)
+	p := &lifecyclePlugin{MemoryPlugin: testutil.NewMemoryPlugin("test")}
+	gw.RegisterPlugin(p)
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	if p.inits != 1 {
+		t.Errorf("Init called %d times, want 1", p.inits)
+	}
+	handler, _ := gw.Handler()
+
+	get := func(path string) (int, map[string]any) {
+		// Probes are served without credentials
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body) // nolint:errcheck
+		return w.Code, body
+	}
+
+	if code, body := get("/healthz"); code != http.StatusOK || body["status"] != "healthy" {
+		t.Errorf("/healthz = %d %v, want 200 healthy", code, body)
+	}
+	if code, body := get("/test/health"); code != http.StatusOK || body["status"] != "healthy" {
+		t.Errorf("/test/health = %d %v, want 200 healthy", code, body)
+	}
+
+	p.healthErr = errors.New("connection refused")
+	code, body := get("/healthz")
+	if code != http.StatusServiceUnavailable || body["status"] != "unhealthy" {
+		t.Errorf("/healthz = %d %v, want 503 unhealthy", code, body)
+	}
+	if plugins, _ := body["plugins"].(map[string]any); plugins["test"] != "unhealthy" {
+		t.Errorf("/healthz plugins = %v, want test unhealthy", body["plugins"])
+	}
+	if code, _ := get("/test/health"); code != http.StatusServiceUnavailable {
+		t.Errorf("/test/health status = %d, want 503", code)
+	}
+
+	// Unknown plugins and other routes are left to the SCIM server
+	if code, _ := get("/unknown/health"); code != http.StatusNotFound {
+		t.Errorf("/unknown/health status = %d, want 404", code)
+	}
+	if code, _ := get("/test/Users"); code != http.StatusUnauthorized {
+		t.Errorf("/test/Users status = %d, want 401", code)
+	}
+}
+
+func TestInitializeFailsOnPluginInit(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	gw.RegisterPlugin(&lifecyclePlugin{MemoryPlugin: testutil.NewMemoryPlugin("test"), initErr: errors.New("schema missing")})
+	if err := gw.Initialize(); err == nil {
+		t.Error("Initialize() succeeded, want the plugin init error")
+	}
+}
+
+func TestShutdownClosesPlugins(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	p := &lifecyclePlugin{MemoryPlugin: testutil.NewMemoryPlugin("test")}
+	gw.RegisterPlugin(p)
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	if err := gw.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if p.closes != 1 {
+		t.Errorf("Close called %d times, want 1", p.closes)
+	}
+}
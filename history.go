@@ -0,0 +1,55 @@
+package scimgateway
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// HistoryMiddleware serves the change timeline of the users and groups of
+// plugins configured with audit: GET /{plugin}/Users/{id}/_history and
+// GET /{plugin}/Groups/{id}/_history list the writes recorded for the
+// resource, oldest first, with who made them and the attributes they
+// changed. Requests routed to a base entity see its writes only. It is
+// placed behind the plugin's authentication, and authorization grants it
+// with the admin operation. Other requests, and requests to plugins without
+// audit, are passed to next.
+func HistoryMiddleware(manager *plugin.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			resource, isHistory := strings.CutSuffix(endpoint, "/_history")
+			resourceType, id, _ := strings.Cut(resource, "/")
+			isHistory = isHistory && (resourceType == "Users" || resourceType == "Groups") && id != "" && !strings.Contains(id, "/")
+			if !isHistory || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+			log, ok := manager.GetAuditLog(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			events, err := log.History(r.Context(), resourceType, id)
+			if err != nil {
+				scim.NewHandler("").WriteError(w, http.StatusServiceUnavailable, fmt.Sprintf("Cannot read the history of %s %s: %v", resourceType, id, err), "")
+				return
+			}
+			if events == nil {
+				events = []*plugin.AuditEvent{}
+			}
+
+			writeAdminJSON(w, scim.ListResponse[*plugin.AuditEvent]{
+				Schemas:      []string{scim.SchemaListResponse},
+				TotalResults: len(events),
+				StartIndex:   1,
+				ItemsPerPage: len(events),
+				Resources:    events,
+			})
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestHistory(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Audit = &config.AuditConfig{}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	var user scim.User
+	if err := json.Unmarshal(do("POST", "/test/Users", `{"userName": "alice"}`, "token").Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	do("PATCH", "/test/Users/"+user.ID, `{"schemas": ["`+scim.SchemaPatchOp+`"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`, "token")
+	path := "/test/Users/" + user.ID + "/_history"
+
+	// The history requires the plugin's credentials
+	if w := do("GET", path, "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+
+	w := do("GET", path, "", "token")
+	var list scim.ListResponse[*plugin.AuditEvent]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("history: %v, body: %s", err, w.Body.String())
+	}
+	operations := []string{}
+	for _, event := range list.Resources {
+		operations = append(operations, event.Operation)
+	}
+	if w.Code != http.StatusOK || !slices.Equal(operations, []string{scim.OperationCreate, scim.OperationPatch}) {
+		t.Fatalf("history status = %d, operations = %v, want create and patch", w.Code, operations)
+	}
+	if changes := list.Resources[1].Changes; len(changes) != 1 || changes[0].Attribute != "active" || changes[0].New != false {
+		t.Errorf("patch changes = %+v, want active set to false", changes)
+	}
+
+	// Resources without writes have an empty history
+	w = do("GET", "/test/Groups/unknown/_history", "", "token")
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK || list.TotalResults != 0 || list.Resources == nil {
+		t.Errorf("empty history status = %d, body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHistoryWithoutAudit(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	req := httptest.NewRequest("GET", "/test/Users/1/_history", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for a plugin without audit", w.Code)
+	}
+}
@@ -0,0 +1,173 @@
+// Package jose implements the subset of JOSE (RFC 7515/7517/7519) needed to verify
+// signed access tokens: JWS signature verification for RSA and ECDSA algorithms and
+// JWKS key sets fetched from a remote endpoint with caching.
+package jose
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultAlgorithms are the signature algorithms accepted when none are configured.
+// Symmetric (HS*) and unsigned ("none") tokens are never accepted.
+var DefaultAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// KeyProvider resolves the public key for a token's key ID
+type KeyProvider interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// Claims are the decoded claims of a verified token
+type Claims map[string]any
+
+// header is the JOSE header of a JWS
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify checks the signature of a compact JWS token with a key from keys and
+// returns its claims. Only algorithms in allowed are accepted; nil allows
+// DefaultAlgorithms. Registered claims (exp, iss, aud, ...) are not validated.
+func Verify(ctx context.Context, token string, keys KeyProvider, allowed []string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var hdr header
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	if allowed == nil {
+		allowed = DefaultAlgorithms
+	}
+	if !slices.Contains(allowed, hdr.Algorithm) || !slices.Contains(DefaultAlgorithms, hdr.Algorithm) {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", hdr.Algorithm)
+	}
+
+	key, err := keys.Key(ctx, hdr.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("invalid token signature encoding")
+	}
+	if err := verifySignature(hdr.Algorithm, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	return claims, nil
+}
+
+// verifySignature verifies signature over signed with key using alg
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(rsaKey, hash, digest, signature)
+		} else {
+			err = rsa.VerifyPSS(rsaKey, hash, digest, signature, nil)
+		}
+		if err != nil {
+			return errors.New("invalid token signature")
+		}
+	case "ES":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+		}
+		// JWS encodes ECDSA signatures as fixed-size R || S
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// String returns a string claim, or "" if absent
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim that may be a single string or an array of strings,
+// such as "aud"
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Time returns a NumericDate claim such as "exp", or the zero time if absent
+func (c Claims) Time(name string) time.Time {
+	switch v := c[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,234 @@
+package jose
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// staticKeys is a KeyProvider with fixed keys
+type staticKeys map[string]crypto.PublicKey
+
+func (s staticKeys) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok := s[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := staticKeys{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}
+	claims := map[string]any{"sub": "client-1"}
+
+	unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claims) + "."
+	hmac := encodeSegment(t, map[string]string{"alg": "HS256", "kid": "rsa"}) + "." + encodeSegment(t, claims) + ".c2ln"
+
+	tests := []struct {
+		name    string
+		token   string
+		allowed []string
+		wantErr string
+	}{
+		{name: "RS256", token: signRS256(t, rsaKey, "rsa", claims)},
+		{name: "ES256", token: signES256(t, ecKey, "ec", claims)},
+		{name: "wrong key", token: signRS256(t, otherKey, "rsa", claims), wantErr: "invalid token signature"},
+		{name: "key type mismatch", token: signRS256(t, rsaKey, "ec", claims), wantErr: "does not match algorithm"},
+		{name: "unknown key", token: signRS256(t, rsaKey, "missing", claims), wantErr: "unknown key"},
+		{name: "alg none", token: unsigned, wantErr: "unsupported signing algorithm"},
+		{name: "symmetric alg", token: hmac, wantErr: "unsupported signing algorithm"},
+		{name: "alg not allowed", token: signES256(t, ecKey, "ec", claims), allowed: []string{"RS256"}, wantErr: "unsupported signing algorithm"},
+		{name: "malformed", token: "not-a-token", wantErr: "malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(context.Background(), tt.token, keys, tt.allowed)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify() error = %v, want to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if got.String("sub") != "client-1" {
+				t.Errorf("sub = %q, want client-1", got.String("sub"))
+			}
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	var claims Claims
+	if err := json.Unmarshal([]byte(`{"aud":["a","b"],"iss":"idp","exp":1700000000,"single":"x"}`), &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := claims.Strings("aud"); len(got) != 2 || got[1] != "b" {
+		t.Errorf("Strings(aud) = %v", got)
+	}
+	if got := claims.Strings("single"); len(got) != 1 || got[0] != "x" {
+		t.Errorf("Strings(single) = %v", got)
+	}
+	if got := claims.String("iss"); got != "idp" {
+		t.Errorf("String(iss) = %q", got)
+	}
+	if got := claims.Time("exp"); !got.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Time(exp) = %v", got)
+	}
+	if got := claims.Time("nbf"); !got.IsZero() {
+		t.Errorf("Time(nbf) = %v, want zero", got)
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString([]byte{1, 0, 1}),
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	var keys atomic.Value
+	keys.Store([]map[string]string{rsaJWK("k1", &key1.PublicKey)})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys.Load()}) // nolint:errcheck
+	}))
+	defer server.Close()
+
+	set := NewRemoteKeySet(server.URL, server.Client(), time.Hour)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := Verify(ctx, signRS256(t, key1, "k1", nil), set, nil); err != nil {
+			t.Fatalf("Verify() error = %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 (keys should be cached)", n)
+	}
+
+	// A rotated key is not fetched again within the refresh interval
+	keys.Store([]map[string]string{rsaJWK("k1", &key1.PublicKey), rsaJWK("k2", &key2.PublicKey)})
+	if _, err := set.Key(ctx, "k2"); err == nil {
+		t.Error("Key() for unknown key within refresh interval should fail")
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+
+	// Once the refresh interval has passed, an unknown key ID triggers a refetch
+	set.fetchedAt = time.Now().Add(-2 * minRefreshInterval)
+	if _, err := Verify(ctx, signRS256(t, key2, "k2", nil), set, nil); err != nil {
+		t.Fatalf("Verify() with rotated key error = %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", n)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := fmt.Sprintf(`{"keys":[
+		{"kty":"EC","kid":"ec","crv":"P-256","x":%q,"y":%q},
+		{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"},
+		{"kty":"oct","kid":"secret","k":"c2VjcmV0"}
+	]}`,
+		base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+		base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()))
+
+	keys, err := ParseKeySet(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ParseKeySet() error = %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("ParseKeySet() returned %d keys, want 1 (encryption and symmetric keys skipped)", len(keys))
+	}
+	if pub, ok := keys["ec"].(*ecdsa.PublicKey); !ok || !pub.Equal(&ecKey.PublicKey) {
+		t.Error("EC key not parsed correctly")
+	}
+
+	if _, err := ParseKeySet(strings.NewReader("not json")); err == nil {
+		t.Error("ParseKeySet() with invalid JSON should fail")
+	}
+}
@@ -0,0 +1,196 @@
+package jose
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/version"
+)
+
+// minRefreshInterval limits how often an unknown key ID triggers a JWKS refetch
+const minRefreshInterval = 30 * time.Second
+
+// RemoteKeySet is a KeyProvider backed by a JWKS endpoint. Keys are cached for the
+// configured TTL and refetched early when a token references an unknown key ID,
+// so signing key rotation at the issuer is picked up without a restart.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+	ttl    time.Duration
+
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	mu        sync.Mutex
+}
+
+// NewRemoteKeySet creates a key set for a JWKS URL.
+// A nil client uses http.DefaultClient; a zero ttl caches keys for one hour.
+func NewRemoteKeySet(url string, client *http.Client, ttl time.Duration) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &RemoteKeySet{url: url, client: client, ttl: ttl}
+}
+
+// Key implements KeyProvider
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := time.Since(s.fetchedAt)
+	if s.keys == nil || age > s.ttl {
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+	} else if _, ok := s.lookup(kid); !ok && age > minRefreshInterval {
+		// Unknown key ID: the issuer may have rotated its signing keys
+		if err := s.refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok := s.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("signing key %q not found in JWKS", kid)
+	}
+	return key, nil
+}
+
+// lookup finds a key by ID. Tokens without a key ID match a key set with a single key.
+func (s *RemoteKeySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh fetches the key set. Callers must hold s.mu.
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	keys, err := ParseKeySet(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	return nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) with the RSA and EC public key members
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// ParseKeySet decodes a JWKS document into public keys indexed by key ID.
+// Keys that are not signature keys or use unsupported types are skipped.
+func ParseKeySet(r io.Reader) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.KeyID] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -6,9 +6,9 @@ import (
 	"context"
 	"fmt"
 	"sync"
-	"time"
 
 	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/scim"
 )
 
@@ -56,6 +56,12 @@ func (p *MemoryPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.U
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	for _, existing := range p.users {
+		if existing.UserName == user.UserName {
+			return nil, scim.ErrConflict("User", "userName")
+		}
+	}
+
 	if user.ID == "" {
 		user.ID = uuid.New().String()
 	}
@@ -63,7 +69,7 @@ func (p *MemoryPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.U
 		user.Schemas = []string{scim.SchemaUser}
 	}
 
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta = &scim.Meta{
 		ResourceType: "User",
 		Created:      &now,
@@ -103,7 +109,7 @@ func (p *MemoryPlugin) ModifyUser(ctx context.Context, id string, patch *scim.Pa
 		return err
 	}
 
-	now := time.Now()
+	now := clock.Now(ctx)
 	user.Meta.LastModified = &now
 	user.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
 
@@ -147,7 +153,7 @@ func (p *MemoryPlugin) CreateGroup(ctx context.Context, group *scim.Group) (*sci
 		group.Schemas = []string{scim.SchemaGroup}
 	}
 
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta = &scim.Meta{
 		ResourceType: "Group",
 		Created:      &now,
@@ -187,7 +193,7 @@ func (p *MemoryPlugin) ModifyGroup(ctx context.Context, id string, patch *scim.P
 		return err
 	}
 
-	now := time.Now()
+	now := clock.Now(ctx)
 	group.Meta.LastModified = &now
 	group.Meta.Version = fmt.Sprintf("W/\"%s-%d\"", id, now.Unix())
 
@@ -0,0 +1,150 @@
+package scimgateway
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scheduler"
+)
+
+// Scheduler returns the scheduler running the gateway's maintenance jobs:
+// the jobs of plugins implementing plugin.JobProvider, such as purging
+// soft-deleted rows and failover health checks, the cache refresh of
+// plugins configured with warmup.refreshInterval and the pings of plugins
+// configured with keepalive, named <plugin>/<job>.
+// Their settings can be overridden with gateway.jobs. Start runs it;
+// embedded gateways run it in a goroutine after Initialize:
+//
+//	go gw.Scheduler().Run(ctx)
+//
+// Applications may add jobs of their own.
+func (g *Gateway) Scheduler() *scheduler.Scheduler {
+	return g.scheduler
+}
+
+// scheduleJobs schedules the jobs of the registered plugins with the
+// settings of the current configuration, and unschedules those of plugins
+// no longer providing them
+func (g *Gateway) scheduleJobs() {
+	cfg := g.Config()
+
+	jobs := g.pluginManager.Jobs()
+	names := g.pluginManager.List()
+	slices.Sort(names)
+	for _, name := range names {
+		pluginCfg, ok := g.pluginManager.GetConfig(name)
+		if !ok || pluginCfg.Warmup == nil || !pluginCfg.Warmup.Enabled || pluginCfg.Warmup.RefreshInterval <= 0 {
+			continue
+		}
+		jobs = append(jobs, scheduler.Job{
+			Name:     name + "/warm",
+			Interval: pluginCfg.Warmup.RefreshInterval,
+			Run: func(ctx context.Context) error {
+				p, ok := g.pluginManager.Get(name)
+				if !ok {
+					return nil
+				}
+				cache, _ := g.pluginManager.GetCache(name)
+				_, err := plugin.Warm(ctx, p, plugin.WarmOptions{
+					PageSize: pluginCfg.Warmup.PageSize,
+					Interval: pluginCfg.Warmup.Interval,
+					Cache:    cache,
+				})
+				return err
+			},
+		})
+	}
+
+	for _, name := range names {
+		pluginCfg, ok := g.pluginManager.GetConfig(name)
+		if !ok || pluginCfg.Keepalive == nil || !pluginCfg.Keepalive.Enabled {
+			continue
+		}
+		if p, ok := g.pluginManager.Get(name); !ok || !plugin.HasKeepalive(p) {
+			g.logger.Warn("keepalive is enabled for a plugin without connections to ping", "plugin", name)
+			continue
+		}
+		interval := pluginCfg.Keepalive.Interval
+		if interval <= 0 {
+			interval = plugin.DefaultKeepaliveInterval
+		}
+		jobs = append(jobs, scheduler.Job{
+			Name:     name + "/keepalive",
+			Interval: interval,
+			Jitter:   pluginCfg.Keepalive.Jitter,
+			Run: func(ctx context.Context) error {
+				p, ok := g.pluginManager.Get(name)
+				if !ok {
+					return nil
+				}
+				reconnected, err := plugin.Keepalive(ctx, p, plugin.KeepaliveOptions{Timeout: pluginCfg.Keepalive.Timeout})
+				if reconnected {
+					g.logger.Info("plugin reconnected after a failed keepalive ping", "plugin", name)
+				}
+				return err
+			},
+		})
+	}
+
+	scheduled := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		if override, ok := cfg.Gateway.Jobs[job.Name]; ok {
+			if override.Enabled != nil {
+				job.Disabled = !*override.Enabled
+			}
+			if override.Interval > 0 {
+				job.Interval = override.Interval
+			}
+			if override.Jitter != 0 {
+				job.Jitter = override.Jitter
+			}
+		}
+		job.Run = g.instrumentJob(job.Name, job.Run)
+		if err := g.scheduler.Add(job); err != nil {
+			g.logger.Error("failed to schedule job", "job", job.Name, "error", err)
+			continue
+		}
+		scheduled = append(scheduled, job.Name)
+	}
+
+	for name := range cfg.Gateway.Jobs {
+		if !slices.Contains(scheduled, name) {
+			g.logger.Warn("gateway.jobs names an unknown job", "job", name)
+		}
+	}
+
+	g.mu.Lock()
+	previous := g.jobs
+	g.jobs = scheduled
+	g.mu.Unlock()
+	for _, name := range previous {
+		if !slices.Contains(scheduled, name) {
+			g.scheduler.Remove(name)
+		}
+	}
+}
+
+// instrumentJob wraps the run function of the job name to log its failures
+// and count its runs in scimgateway_job_runs_total
+func (g *Gateway) instrumentJob(name string, run func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		start := time.Now()
+		err := run(ctx)
+
+		result := "success"
+		if err != nil {
+			result = "error"
+			g.logger.Error("maintenance job failed", "job", name, "error", err)
+		} else {
+			g.logger.Debug("maintenance job completed", "job", name, "duration", time.Since(start))
+		}
+		g.metrics.Counter("scimgateway_job_runs_total",
+			"Total number of runs of maintenance jobs by result.",
+			metrics.Labels{"job": name, "result": result},
+		).Inc()
+		return err
+	}
+}
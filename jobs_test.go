@@ -0,0 +1,102 @@
+package scimgateway
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/plugin"
+)
+
+func TestScheduleJobs(t *testing.T) {
+	disabled := false
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Failover = &config.FailoverConfig{FailureThreshold: 1}
+	cfg.Plugins[0].Warmup = &config.WarmupConfig{Enabled: true, RefreshInterval: time.Hour}
+	cfg.Gateway.Jobs = map[string]config.JobConfig{
+		"test/warm":  {Enabled: &disabled},
+		"test/purge": {Interval: time.Minute},
+	}
+	gw := New(cfg)
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	pair := gw.RegisterFailover(unhealthyPlugin{testutil.NewMemoryPlugin("test")}, testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	jobs := gw.Scheduler().Jobs()
+	if len(jobs) != 2 || jobs[0].Name != "test/health-check" || jobs[1].Name != "test/warm" {
+		t.Fatalf("Jobs() = %+v, want test/health-check and test/warm", jobs)
+	}
+	if !jobs[0].Enabled || jobs[0].Interval != plugin.DefaultFailoverInterval {
+		t.Errorf("health check = %+v, want enabled every %v", jobs[0], plugin.DefaultFailoverInterval)
+	}
+	if jobs[1].Enabled || jobs[1].Interval != time.Hour {
+		t.Errorf("warm = %+v, want disabled by gateway.jobs every hour", jobs[1])
+	}
+	if !strings.Contains(logs.String(), "job=test/purge") {
+		t.Errorf("log = %s, want the unknown job test/purge reported", logs.String())
+	}
+
+	if err := gw.Scheduler().Trigger(context.Background(), "test/health-check"); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if pair.Active() != plugin.FailoverStandby {
+		t.Error("health check did not fail over to the standby")
+	}
+	if v, _ := gw.Metrics().Value("scimgateway_job_runs_total", metrics.Labels{"job": "test/health-check", "result": "success"}); v != 1 {
+		t.Errorf("job runs = %v, want 1", v)
+	}
+
+	// Jobs of settings removed on reload are unscheduled
+	reloaded := bearerConfig("token")
+	reloaded.Plugins[0].Failover = cfg.Plugins[0].Failover
+	if err := gw.Reload(reloaded); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if jobs := gw.Scheduler().Jobs(); len(jobs) != 1 || jobs[0].Name != "test/health-check" || jobs[0].Runs != 1 {
+		t.Errorf("Jobs() after reload = %+v, want the health check keeping its status", jobs)
+	}
+}
+
+// pingedPlugin is a memory plugin counting keepalive pings
+type pingedPlugin struct {
+	*testutil.MemoryPlugin
+	pings *int
+}
+
+func (p pingedPlugin) Ping(context.Context) error {
+	*p.pings++
+	return nil
+}
+
+func TestScheduleKeepalive(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Keepalive = &config.KeepaliveConfig{Enabled: true, Jitter: 0.2}
+	gw := New(cfg)
+
+	var pings int
+	gw.RegisterPlugin(pingedPlugin{testutil.NewMemoryPlugin("test"), &pings})
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	jobs := gw.Scheduler().Jobs()
+	if len(jobs) != 1 || jobs[0].Name != "test/keepalive" || jobs[0].Interval != plugin.DefaultKeepaliveInterval {
+		t.Fatalf("Jobs() = %+v, want test/keepalive every %v", jobs, plugin.DefaultKeepaliveInterval)
+	}
+	if err := gw.Scheduler().Trigger(context.Background(), "test/keepalive"); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if pings != 1 {
+		t.Errorf("pings = %d, want 1", pings)
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"database/sql"
+)
+
+// StatsProvider is implemented by *sql.DB and types embedding it, such as *sqlx.DB
+type StatsProvider interface {
+	Stats() sql.DBStats
+}
+
+// RegisterDBStats registers connection pool metrics for a plugin's database,
+// labelled with plugin=<name>. Values are read from db.Stats() at scrape time.
+func RegisterDBStats(r *Registry, plugin string, db StatsProvider) {
+	labels := Labels{"plugin": plugin}
+
+	stats := []struct {
+		name  string
+		help  string
+		kind  Kind
+		value func(sql.DBStats) float64
+	}{
+		{"scimgateway_db_max_open_connections", "Maximum number of open connections to the database.", KindGauge,
+			func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+		{"scimgateway_db_open_connections", "Number of established connections, both in use and idle.", KindGauge,
+			func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+		{"scimgateway_db_in_use_connections", "Number of connections currently in use.", KindGauge,
+			func(s sql.DBStats) float64 { return float64(s.InUse) }},
+		{"scimgateway_db_idle_connections", "Number of idle connections.", KindGauge,
+			func(s sql.DBStats) float64 { return float64(s.Idle) }},
+		{"scimgateway_db_wait_count_total", "Total number of connections waited for.", KindCounter,
+			func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+		{"scimgateway_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", KindCounter,
+			func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+		{"scimgateway_db_max_idle_closed_total", "Total number of connections closed due to MaxIdleConns.", KindCounter,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+		{"scimgateway_db_max_idle_time_closed_total", "Total number of connections closed due to ConnMaxIdleTime.", KindCounter,
+			func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+		{"scimgateway_db_max_lifetime_closed_total", "Total number of connections closed due to ConnMaxLifetime.", KindCounter,
+			func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+	}
+
+	for _, stat := range stats {
+		read := func() float64 { return stat.value(db.Stats()) }
+		if stat.kind == KindCounter {
+			r.CounterFunc(stat.name, stat.help, labels, read)
+		} else {
+			r.GaugeFunc(stat.name, stat.help, labels, read)
+		}
+	}
+}
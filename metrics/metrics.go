@@ -0,0 +1,209 @@
+// Package metrics provides a minimal, dependency-free metrics registry for the gateway.
+//
+// Metrics are exposed in the Prometheus text exposition format by serving the
+// Registry as an http.Handler:
+//
+//	mux.Handle("/metrics", gw.Metrics())
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Labels are the label names and values that identify a metric series
+type Labels map[string]string
+
+// Kind is the type of a metric family
+type Kind string
+
+const (
+	// KindCounter is a monotonically increasing value
+	KindCounter Kind = "counter"
+	// KindGauge is a value that can go up and down
+	KindGauge Kind = "gauge"
+)
+
+// Counter is a monotonically increasing counter. It is safe for concurrent use.
+type Counter struct {
+	value atomic.Uint64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increments the counter by n
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() uint64 {
+	return c.value.Load()
+}
+
+// family groups the series sharing a metric name
+type family struct {
+	help   string
+	kind   Kind
+	series map[string]*series // keyed by rendered label set
+}
+
+// series is a single labelled metric value
+type series struct {
+	value   func() float64
+	counter *Counter // set for series created by Registry.Counter
+}
+
+// Registry holds metric families and renders them for scraping.
+// It is safe for concurrent use.
+type Registry struct {
+	families map[string]*family
+	mu       sync.RWMutex
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Counter returns the counter registered under name and labels, creating it if needed
+func (r *Registry) Counter(name, help string, labels Labels) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.family(name, help, KindCounter)
+	key := renderLabels(labels)
+	if s, ok := f.series[key]; ok && s.counter != nil {
+		return s.counter
+	}
+
+	c := &Counter{}
+	f.series[key] = &series{value: func() float64 { return float64(c.Value()) }, counter: c}
+	return c
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at scrape time.
+// Registering the same name and labels again replaces the previous function.
+func (r *Registry) GaugeFunc(name, help string, labels Labels, fn func() float64) {
+	r.registerFunc(name, help, KindGauge, labels, fn)
+}
+
+// CounterFunc registers a counter whose value is read from fn at scrape time,
+// for monotonic values maintained elsewhere (e.g. sql.DBStats.WaitCount).
+// Registering the same name and labels again replaces the previous function.
+func (r *Registry) CounterFunc(name, help string, labels Labels, fn func() float64) {
+	r.registerFunc(name, help, KindCounter, labels, fn)
+}
+
+// Unregister removes the series registered under name and labels
+func (r *Registry) Unregister(name string, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		delete(f.series, renderLabels(labels))
+		if len(f.series) == 0 {
+			delete(r.families, name)
+		}
+	}
+}
+
+// Value returns the current value of the series registered under name and labels
+func (r *Registry) Value(name string, labels Labels) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	f, ok := r.families[name]
+	if !ok {
+		return 0, false
+	}
+	s, ok := f.series[renderLabels(labels)]
+	if !ok {
+		return 0, false
+	}
+	return s.value(), true
+}
+
+// WriteTo renders all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(r.families)) {
+		f := r.families[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n", name, escapeHelp(f.help))
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", name, f.kind)
+		for _, key := range slices.Sorted(maps.Keys(f.series)) {
+			fmt.Fprintf(&sb, "%s%s %s\n", name, key, formatValue(f.series[key].value()))
+		}
+	}
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w) // nolint:errcheck
+}
+
+func (r *Registry) registerFunc(name, help string, kind Kind, labels Labels, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := r.family(name, help, kind)
+	f.series[renderLabels(labels)] = &series{value: fn}
+}
+
+// family returns the family for name, creating it if needed. Callers must hold r.mu.
+func (r *Registry) family(name, help string, kind Kind) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{help: help, kind: kind, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	return f
+}
+
+// renderLabels renders labels as {a="1",b="2"} with sorted names
+func renderLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, strconv.Quote(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	help = strings.ReplaceAll(help, `\`, `\\`)
+	return strings.ReplaceAll(help, "\n", `\n`)
+}
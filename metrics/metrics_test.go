@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.Counter("scimgateway_requests_total", "Total requests.", Labels{"plugin": "ldap", "method": "GET"})
+	requests.Inc()
+	requests.Add(2)
+	r.Counter("scimgateway_requests_total", "Total requests.", Labels{"method": "POST", "plugin": "ldap"}).Inc()
+	r.GaugeFunc("scimgateway_up", "Whether the gateway is up.", nil, func() float64 { return 1 })
+
+	var sb strings.Builder
+	if _, err := r.WriteTo(&sb); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	want := `# HELP scimgateway_requests_total Total requests.
+# TYPE scimgateway_requests_total counter
+scimgateway_requests_total{method="GET",plugin="ldap"} 3
+scimgateway_requests_total{method="POST",plugin="ldap"} 1
+# HELP scimgateway_up Whether the gateway is up.
+# TYPE scimgateway_up gauge
+scimgateway_up 1
+`
+	if sb.String() != want {
+		t.Errorf("WriteTo() =\n%s\nwant:\n%s", sb.String(), want)
+	}
+}
+
+func TestRegistryCounterReuse(t *testing.T) {
+	r := NewRegistry()
+	labels := Labels{"plugin": "test"}
+
+	first := r.Counter("scimgateway_errors_total", "Errors.", labels)
+	second := r.Counter("scimgateway_errors_total", "Errors.", Labels{"plugin": "test"})
+	if first != second {
+		t.Fatal("Counter() should return the existing counter for the same name and labels")
+	}
+
+	first.Inc()
+	if v, ok := r.Value("scimgateway_errors_total", labels); !ok || v != 1 {
+		t.Errorf("Value() = %v, %v, want 1, true", v, ok)
+	}
+
+	r.Unregister("scimgateway_errors_total", labels)
+	if _, ok := r.Value("scimgateway_errors_total", labels); ok {
+		t.Error("Value() after Unregister should not find the series")
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.GaugeFunc("scimgateway_up", "Whether the gateway is up.", nil, func() float64 { return 1 })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if !strings.Contains(w.Body.String(), "scimgateway_up 1") {
+		t.Errorf("body = %q, want scimgateway_up 1", w.Body.String())
+	}
+}
+
+// fakeStats returns fixed pool statistics
+type fakeStats struct {
+	stats sql.DBStats
+}
+
+func (f *fakeStats) Stats() sql.DBStats {
+	return f.stats
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	r := NewRegistry()
+	db := &fakeStats{stats: sql.DBStats{
+		MaxOpenConnections: 20,
+		OpenConnections:    7,
+		InUse:              5,
+		Idle:               2,
+		WaitCount:          42,
+		WaitDuration:       1500 * time.Millisecond,
+	}}
+	RegisterDBStats(r, "postgres", db)
+
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"scimgateway_db_max_open_connections", 20},
+		{"scimgateway_db_open_connections", 7},
+		{"scimgateway_db_in_use_connections", 5},
+		{"scimgateway_db_idle_connections", 2},
+		{"scimgateway_db_wait_count_total", 42},
+		{"scimgateway_db_wait_duration_seconds_total", 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Value(tt.name, Labels{"plugin": "postgres"})
+			if !ok || got != tt.want {
+				t.Errorf("Value(%s) = %v, %v, want %v", tt.name, got, ok, tt.want)
+			}
+		})
+	}
+
+	// Values are read at scrape time
+	db.stats.InUse = 9
+	if got, _ := r.Value("scimgateway_db_in_use_connections", Labels{"plugin": "postgres"}); got != 9 {
+		t.Errorf("in-use connections = %v after update, want 9", got)
+	}
+
+	var sb strings.Builder
+	r.WriteTo(&sb) // nolint:errcheck
+	if !strings.Contains(sb.String(), "# TYPE scimgateway_db_wait_count_total counter") {
+		t.Errorf("wait count should be exposed as a counter:\n%s", sb.String())
+	}
+}
@@ -4,8 +4,15 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
+// maxRequestIDLength is the length of the longest X-Request-Id honored
+const maxRequestIDLength = 128
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -28,6 +35,11 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return rw.ResponseWriter.Write(b)
 }
 
+// Unwrap returns the underlying response writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // LoggingMiddleware logs HTTP requests with method, path, status, duration, and client IP
 func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -67,3 +79,47 @@ func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// ClockMiddleware adds c to the request context, so plugins read the time
+// with clock.Now(ctx)
+func ClockMiddleware(c clock.Clock) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(clock.WithContext(r.Context(), c)))
+		})
+	}
+}
+
+// RequestIDMiddleware gives every request a correlation ID: the client's
+// X-Request-Id header if it is a printable ASCII string of up to 128
+// characters, or a generated UUID. The ID is set as the request's
+// X-Request-Id header and context value (see scimcontext.RequestID), which
+// plugins and loggers wrapped with scimcontext.NewLogHandler read, and as
+// the X-Request-Id header of the response.
+func RequestIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get("X-Request-Id")
+			if !validRequestID(id) {
+				id = uuid.New().String()
+				r = r.Clone(r.Context())
+				r.Header.Set("X-Request-Id", id)
+			}
+			w.Header().Set("X-Request-Id", id)
+			next.ServeHTTP(w, r.WithContext(scimcontext.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// validRequestID reports whether a client's X-Request-Id can be honored
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := range len(id) {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+// Package migrate applies versioned SQL schema migrations for database-backed
+// plugins, so table creation and later schema changes run automatically on
+// startup.
+//
+// Migrations are SQL files named <version>_<name>.sql, usually embedded in the
+// plugin:
+//
+//	//go:embed migrations/*.sql
+//	var migrationFiles embed.FS
+//
+//	migrations, err := migrate.Load(migrationFiles, "migrations")
+//	applied, err := migrate.Apply(ctx, db, migrations, migrate.Options{})
+//
+// Applied versions are recorded in a version table, so each migration runs
+// once. Every migration runs in its own transaction together with its version
+// row. A file may hold several statements if the driver executes them in one
+// call (SQLite and PostgreSQL do; MySQL needs multiStatements=true).
+//
+// Changes SQL cannot make conditionally, such as adding a column that
+// databases created before migrations may already have on SQLite, are Go
+// migrations with a Func, added to the loaded ones in version order.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTable is the name of the version table
+const DefaultTable = "schema_migrations"
+
+// Migration is a single schema change
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+
+	// Func applies the migration in its transaction instead of SQL if set,
+	// e.g. to inspect the schema first
+	Func func(ctx context.Context, tx *sql.Tx) error
+}
+
+// Placeholder is the bind parameter style of a database driver
+type Placeholder int
+
+const (
+	// Question uses ? parameters (SQLite, MySQL)
+	Question Placeholder = iota
+
+	// Dollar uses $1, $2, ... parameters (PostgreSQL)
+	Dollar
+)
+
+// Options configures Apply
+type Options struct {
+	// Table is the version table. Empty uses DefaultTable.
+	Table string
+
+	// Placeholder is the driver's bind parameter style
+	Placeholder Placeholder
+
+	// Lock is run at the start of every migration transaction to serialize
+	// gateways migrating the same database concurrently, e.g.
+	// "LOCK TABLE schema_migrations IN EXCLUSIVE MODE" on PostgreSQL. SQLite
+	// serializes writers itself and needs none.
+	Lock string
+
+	// DryRun reports the pending migrations without applying them or creating
+	// the version table
+	DryRun bool
+
+	// Logger logs each migration applied or pending. Nil disables logging.
+	Logger *slog.Logger
+}
+
+// Load reads the migrations in dir of fsys. File names must start with a
+// positive version number followed by an underscore, e.g. 0001_create_users.sql;
+// other files are ignored. Migrations are returned in version order.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		file := entry.Name()
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration file name %q: want <version>_<name>.sql", file)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, file)
+		}
+		seen[version] = file
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", file, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(data)})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// Apply applies the migrations not yet recorded in the version table, in
+// version order, and returns them. With Options.DryRun it only returns them.
+//
+// Apply fails without changing anything if the database has a version newer
+// than the last migration, i.e. it was migrated by a newer plugin release.
+func Apply(ctx context.Context, db *sql.DB, migrations []Migration, opts Options) ([]Migration, error) {
+	table := opts.Table
+	if table == "" {
+		table = DefaultTable
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	if !opts.DryRun {
+		_, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`, table))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create version table: %w", err)
+		}
+	}
+
+	applied, err := appliedVersions(ctx, db, table)
+	if err != nil {
+		// A dry run against a fresh database has no version table yet
+		if !opts.DryRun {
+			return nil, err
+		}
+		applied = nil
+	}
+
+	if len(applied) > 0 && len(migrations) > 0 {
+		if newest := slices.Max(applied); newest > migrations[len(migrations)-1].Version {
+			return nil, fmt.Errorf("database schema version %d is newer than the latest known migration %d",
+				newest, migrations[len(migrations)-1].Version)
+		}
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if !slices.Contains(applied, m.Version) {
+			pending = append(pending, m)
+		}
+	}
+
+	if opts.DryRun {
+		for _, m := range pending {
+			logger.Info("pending schema migration", "version", m.Version, "name", m.Name)
+		}
+		return pending, nil
+	}
+
+	var done []Migration
+	for _, m := range pending {
+		ok, err := applyOne(ctx, db, table, m, opts)
+		if err != nil {
+			return done, err
+		}
+		if ok {
+			logger.Info("applied schema migration", "version", m.Version, "name", m.Name)
+			done = append(done, m)
+		}
+	}
+	return done, nil
+}
+
+// applyOne applies m and records it in one transaction. It reports false if
+// another runner applied m first.
+func applyOne(ctx context.Context, db *sql.DB, table string, m Migration, opts Options) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback() // nolint:errcheck
+
+	if opts.Lock != "" {
+		if _, err := tx.ExecContext(ctx, opts.Lock); err != nil {
+			return false, fmt.Errorf("failed to lock version table: %w", err)
+		}
+	}
+
+	// Re-check under the lock in case a concurrent runner got here first
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE version = %s", table, placeholder(opts.Placeholder, 1))
+	if err := tx.QueryRowContext(ctx, query, m.Version).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	if m.Func != nil {
+		err = m.Func(ctx, tx)
+	} else {
+		_, err = tx.ExecContext(ctx, m.SQL)
+	}
+	if err != nil {
+		return false, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)", table,
+		placeholder(opts.Placeholder, 1), placeholder(opts.Placeholder, 2), placeholder(opts.Placeholder, 3))
+	if _, err := tx.ExecContext(ctx, insert, m.Version, m.Name, time.Now().UTC()); err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	return true, nil
+}
+
+// appliedVersions returns the versions recorded in the version table
+func appliedVersions(ctx context.Context, db *sql.DB, table string) ([]int, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+// placeholder returns the n-th bind parameter in style p
+func placeholder(p Placeholder, n int) string {
+	if p == Dollar {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
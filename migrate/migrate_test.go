@@ -0,0 +1,304 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeDB is an in-memory database/sql driver that understands just the
+// statements Apply issues. Migration SQL containing "FAIL" fails.
+type fakeDB struct {
+	mu       sync.Mutex
+	table    bool  // version table exists
+	versions []int // committed versions
+	executed []string
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return &fakeConn{db: d}, nil }
+
+type fakeConn struct {
+	db      *fakeDB
+	pending []int // versions inserted in the open transaction
+	inTx    bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { c.inTx = true; return c, nil }
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.versions = append(c.db.versions, c.pending...)
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.pending, c.inTx = nil, false
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "CREATE TABLE IF NOT EXISTS schema_migrations"):
+		db.table = true
+	case strings.HasPrefix(s.query, "INSERT INTO schema_migrations"):
+		s.conn.pending = append(s.conn.pending, int(args[0].(int64)))
+	case strings.Contains(s.query, "FAIL"):
+		return nil, errors.New("syntax error")
+	default:
+		db.executed = append(db.executed, s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.conn.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.table {
+		return nil, errors.New("no such table: schema_migrations")
+	}
+	if strings.HasPrefix(s.query, "SELECT COUNT(*)") {
+		var count int64
+		for _, v := range db.versions {
+			if int64(v) == args[0].(int64) {
+				count++
+			}
+		}
+		return &fakeRows{values: []int64{count}}, nil
+	}
+
+	rows := &fakeRows{}
+	for _, v := range db.versions {
+		rows.values = append(rows.values, int64(v))
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values []int64
+}
+
+func (r *fakeRows) Columns() []string { return []string{"version"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+var registerOnce sync.Once
+var fakeDBs sync.Map
+
+// openFake returns a database/sql handle on a fresh fakeDB
+func openFake(t *testing.T) (*sql.DB, *fakeDB) {
+	t.Helper()
+	registerOnce.Do(func() { sql.Register("migratefake", fakeDriver{}) })
+
+	fake := &fakeDB{}
+	fakeDBs.Store(t.Name(), fake)
+	db, err := sql.Open("migratefake", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() }) // nolint:errcheck
+	return db, fake
+}
+
+// fakeDriver dispatches connections to the fakeDB registered under the DSN
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fake, _ := fakeDBs.Load(name)
+	return fake.(*fakeDB).Open(name)
+}
+
+func testMigrations() []Migration {
+	return []Migration{
+		{Version: 1, Name: "create_users", SQL: "CREATE TABLE users (id TEXT)"},
+		{Version: 2, Name: "add_base_entity", SQL: "ALTER TABLE users ADD COLUMN base_entity TEXT"},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_base_entity.sql": {Data: []byte("ALTER TABLE users ADD COLUMN base_entity TEXT")},
+		"migrations/0001_create_users.sql":    {Data: []byte("CREATE TABLE users (id TEXT)")},
+		"migrations/README.md":                {Data: []byte("not a migration")},
+	}
+
+	migrations, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Load() returned %d migrations, want 2", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_users" || migrations[0].SQL != "CREATE TABLE users (id TEXT)" {
+		t.Errorf("migrations[0] = %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Name != "add_base_entity" {
+		t.Errorf("migrations[1] = %+v", migrations[1])
+	}
+
+	invalid := map[string]fstest.MapFS{
+		"no version":        {"m/create_users.sql": {}},
+		"zero version":      {"m/0000_init.sql": {}},
+		"duplicate version": {"m/0001_a.sql": {}, "m/1_b.sql": {}},
+		"missing directory": {},
+	}
+	for name, fsys := range invalid {
+		if _, err := Load(fsys, "m"); err == nil {
+			t.Errorf("Load() with %s should fail", name)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	applied, err := Apply(ctx, db, testMigrations()[:1], Options{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 1 {
+		t.Errorf("Apply() = %v, want migration 1", applied)
+	}
+
+	// Only the new migration runs on the next start
+	applied, err = Apply(ctx, db, testMigrations(), Options{})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("Apply() = %v, want migration 2", applied)
+	}
+	if len(fake.executed) != 2 {
+		t.Errorf("executed %d migrations, want 2: %v", len(fake.executed), fake.executed)
+	}
+
+	applied, err = Apply(ctx, db, testMigrations(), Options{})
+	if err != nil || len(applied) != 0 {
+		t.Errorf("Apply() on an up-to-date database = %v, %v, want nothing applied", applied, err)
+	}
+}
+
+func TestApplyDryRun(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	pending, err := Apply(ctx, db, testMigrations(), Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(pending) != 2 {
+		t.Errorf("Apply() dry run = %v, want both migrations pending", pending)
+	}
+	if fake.table || len(fake.executed) != 0 {
+		t.Error("dry run should not change the database")
+	}
+
+	if _, err := Apply(ctx, db, testMigrations()[:1], Options{}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = Apply(ctx, db, testMigrations(), Options{DryRun: true})
+	if err != nil || len(pending) != 1 || pending[0].Version != 2 {
+		t.Errorf("Apply() dry run = %v, %v, want migration 2 pending", pending, err)
+	}
+}
+
+func TestApplyFailure(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	migrations := append(testMigrations(), Migration{Version: 3, Name: "broken", SQL: "FAIL"})
+	applied, err := Apply(ctx, db, migrations, Options{})
+	if err == nil || !strings.Contains(err.Error(), "migration 3 (broken) failed") {
+		t.Fatalf("Apply() error = %v, want migration 3 failure", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Apply() = %v, want the migrations before the failure", applied)
+	}
+	if len(fake.versions) != 2 {
+		t.Errorf("recorded versions = %v, want the failed migration rolled back", fake.versions)
+	}
+}
+
+func TestApplyFunc(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	var ran int
+	migrations := append(testMigrations(), Migration{Version: 3, Name: "go", Func: func(ctx context.Context, tx *sql.Tx) error {
+		ran++
+		_, err := tx.ExecContext(ctx, "UPDATE users SET base_entity = ''")
+		return err
+	}})
+	if _, err := Apply(ctx, db, migrations, Options{}); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if ran != 1 || len(fake.versions) != 3 || fake.executed[2] != "UPDATE users SET base_entity = ''" {
+		t.Errorf("Func ran %d times, executed %v, versions %v", ran, fake.executed, fake.versions)
+	}
+
+	migrations[2].Func = func(context.Context, *sql.Tx) error { return errors.New("column exists") }
+	migrations = append(migrations, Migration{Version: 4, Name: "broken", Func: migrations[2].Func})
+	if _, err := Apply(ctx, db, migrations, Options{}); err == nil || !strings.Contains(err.Error(), "migration 4 (broken) failed") {
+		t.Errorf("Apply() error = %v, want migration 4 failure", err)
+	}
+}
+
+func TestApplyNewerDatabase(t *testing.T) {
+	db, fake := openFake(t)
+	ctx := context.Background()
+
+	if _, err := Apply(ctx, db, testMigrations(), Options{}); err != nil {
+		t.Fatal(err)
+	}
+	executed := len(fake.executed)
+
+	_, err := Apply(ctx, db, testMigrations()[:1], Options{})
+	if err == nil || !strings.Contains(err.Error(), "newer than the latest known migration") {
+		t.Errorf("Apply() error = %v, want newer schema error", err)
+	}
+	if len(fake.executed) != executed {
+		t.Error("Apply() should not run migrations against a newer schema")
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	if got := placeholder(Question, 2); got != "?" {
+		t.Errorf("placeholder(Question, 2) = %q", got)
+	}
+	if got := placeholder(Dollar, 2); got != "$2" {
+		t.Errorf("placeholder(Dollar, 2) = %q", got)
+	}
+}
@@ -2,13 +2,42 @@ package plugin
 
 import (
 	"context"
+	"errors"
+	"strings"
 
 	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
 // Adapter adapts the plugin interface to the scim.PluginGetter interface
 type Adapter struct {
 	plugin Plugin
+
+	// defaultExcluded overrides the plugin's default excluded attributes
+	defaultExcluded []string
+
+	// baseURL overrides the public base URL of the plugin's resources
+	baseURL string
+
+	// operations overrides the operations the plugin allows per resource type
+	operations map[string][]string
+
+	// sortCollation overrides the plugin's collation of sorted strings
+	sortCollation string
+
+	// accentInsensitive makes the plugin's filters ignore accents
+	accentInsensitive bool
+
+	// clientCache overrides how long clients may cache the plugin's responses
+	clientCache *scim.ClientCache
+
+	// maxBulkOperations and maxBulkPayloadSize override the plugin's limits
+	// of bulk requests when positive
+	maxBulkOperations  int
+	maxBulkPayloadSize int
+
+	// normalization overrides the plugin's normalization rules
+	normalization *scim.Normalization
 }
 
 // NewAdapter creates a new plugin adapter
@@ -16,17 +45,148 @@ func NewAdapter(plugin Plugin) *Adapter {
 	return &Adapter{plugin: plugin}
 }
 
+// Unwrap returns the underlying plugin so the server can discover optional
+// capabilities it implements (e.g. scim.ResourceTypeProvider)
+func (a *Adapter) Unwrap() any {
+	return a.plugin
+}
+
+// DefaultExcludedAttributes implements scim.DefaultExcludedAttributesProvider.
+// The plugin's defaultExcludedAttributes setting takes precedence over the
+// plugin's own defaults.
+func (a *Adapter) DefaultExcludedAttributes() []string {
+	if len(a.defaultExcluded) > 0 {
+		return a.defaultExcluded
+	}
+	if provider, ok := a.plugin.(scim.DefaultExcludedAttributesProvider); ok {
+		return provider.DefaultExcludedAttributes()
+	}
+	return nil
+}
+
+// SortCollation implements scim.CollationProvider. The plugin's
+// sortCollation setting takes precedence over the plugin's own collation.
+func (a *Adapter) SortCollation() string {
+	if a.sortCollation != "" {
+		return a.sortCollation
+	}
+	if provider, ok := a.plugin.(scim.CollationProvider); ok {
+		return provider.SortCollation()
+	}
+	return ""
+}
+
+// AccentInsensitive implements scim.AccentInsensitiveProvider. Filters ignore
+// accents if the plugin's accentInsensitive setting or the plugin itself says so.
+func (a *Adapter) AccentInsensitive() bool {
+	if a.accentInsensitive {
+		return true
+	}
+	provider, ok := a.plugin.(scim.AccentInsensitiveProvider)
+	return ok && provider.AccentInsensitive()
+}
+
+// Normalization implements scim.NormalizationProvider. The plugin's
+// normalize setting, or the gateway's, takes precedence over the plugin's
+// own rules.
+func (a *Adapter) Normalization() *scim.Normalization {
+	if a.normalization != nil {
+		return a.normalization
+	}
+	if provider, ok := a.plugin.(scim.NormalizationProvider); ok {
+		return provider.Normalization()
+	}
+	return nil
+}
+
+// BaseURL implements scim.BaseURLProvider. The plugin's baseURL setting
+// takes precedence over the plugin's own base URL.
+func (a *Adapter) BaseURL() string {
+	if a.baseURL != "" {
+		return a.baseURL
+	}
+	if provider, ok := a.plugin.(scim.BaseURLProvider); ok {
+		return provider.BaseURL()
+	}
+	return ""
+}
+
+// AllowedOperations implements scim.OperationsProvider. The plugin's
+// operations setting takes precedence over the plugin's own restrictions,
+// and its resource types match endpoints case-insensitively.
+func (a *Adapter) AllowedOperations(endpoint string) ([]string, bool) {
+	if a.operations != nil {
+		for resourceType, allowed := range a.operations {
+			if strings.EqualFold(resourceType, endpoint) {
+				return allowed, true
+			}
+		}
+		return nil, false
+	}
+	if provider, ok := a.plugin.(scim.OperationsProvider); ok {
+		return provider.AllowedOperations(endpoint)
+	}
+	return nil, false
+}
+
+// BulkLimits implements scim.BulkLimitsProvider. The limits of the
+// plugin's bulk setting take precedence over the plugin's own limits.
+func (a *Adapter) BulkLimits() (maxOperations, maxPayloadSize int) {
+	if provider, ok := a.plugin.(scim.BulkLimitsProvider); ok {
+		maxOperations, maxPayloadSize = provider.BulkLimits()
+	}
+	if a.maxBulkOperations > 0 {
+		maxOperations = a.maxBulkOperations
+	}
+	if a.maxBulkPayloadSize > 0 {
+		maxPayloadSize = a.maxBulkPayloadSize
+	}
+	return maxOperations, maxPayloadSize
+}
+
+// ClientCache implements scim.ClientCacheProvider. The plugin's clientCache
+// setting takes precedence over the plugin's own max ages.
+func (a *Adapter) ClientCache() scim.ClientCache {
+	if a.clientCache != nil {
+		return *a.clientCache
+	}
+	if provider, ok := a.plugin.(scim.ClientCacheProvider); ok {
+		return provider.ClientCache()
+	}
+	return scim.ClientCache{}
+}
+
 // GetUsers implements scim.PluginGetter
 // The adapter applies SCIM protocol operations (filtering, pagination, attribute selection)
 func (a *Adapter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
-	// Get raw data from plugin
-	users, err := a.plugin.GetUsers(ctx, params)
-	if err != nil {
-		return nil, err
+	// Plugins indexing externalId look up the resource it selects
+	if lookup, ok := a.plugin.(scim.ExternalIDLookup); ok {
+		if externalID, ok := scim.ExternalIDFilter(params.Filter); ok {
+			return scim.LookupUsersByExternalID(ctx, lookup, externalID, params)
+		}
+	}
+
+	// Plugins indexing userName look up the user it selects, unless they
+	// cannot answer with a single user
+	if lookup, ok := a.plugin.(scim.UserNameLookup); ok {
+		if userName, ok := scim.UserNameFilter(params.Filter); ok {
+			response, err := scim.LookupUsersByUserName(ctx, lookup, userName, params)
+			if !errors.Is(err, errors.ErrUnsupported) {
+				return response, err
+			}
+		}
+	}
+
+	// Plugins paginating natively return the requested page
+	if lister, ok := a.plugin.(UserLister); ok {
+		if params.Filter != "" {
+			scimcontext.TraceFilter(ctx, "pushed to plugin")
+		}
+		return lister.ListUsers(ctx, params)
 	}
 
-	// Apply SCIM query operations internally
-	return scim.ProcessListQuery(users, params)
+	// Apply the parts of the query the plugin does not apply natively
+	return scim.ListUsersWithCapabilities(ctx, a.plugin, params)
 }
 
 // CreateUser implements scim.PluginGetter
@@ -52,14 +212,34 @@ func (a *Adapter) DeleteUser(ctx context.Context, id string) error {
 // GetGroups implements scim.PluginGetter
 // The adapter applies SCIM protocol operations (filtering, pagination, attribute selection)
 func (a *Adapter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
-	// Get raw data from plugin
-	groups, err := a.plugin.GetGroups(ctx, params)
-	if err != nil {
-		return nil, err
+	// Plugins indexing externalId look up the resource it selects
+	if lookup, ok := a.plugin.(scim.ExternalIDLookup); ok {
+		if externalID, ok := scim.ExternalIDFilter(params.Filter); ok {
+			return scim.LookupGroupsByExternalID(ctx, lookup, externalID, params)
+		}
 	}
 
-	// Apply SCIM query operations internally
-	return scim.ProcessListQuery(groups, params)
+	// Plugins indexing displayName look up the group it selects, unless
+	// they cannot answer with a single group
+	if lookup, ok := a.plugin.(scim.GroupDisplayNameLookup); ok {
+		if displayName, ok := scim.DisplayNameFilter(params.Filter); ok {
+			response, err := scim.LookupGroupsByDisplayName(ctx, lookup, displayName, params)
+			if !errors.Is(err, errors.ErrUnsupported) {
+				return response, err
+			}
+		}
+	}
+
+	// Plugins paginating natively return the requested page
+	if lister, ok := a.plugin.(GroupLister); ok {
+		if params.Filter != "" {
+			scimcontext.TraceFilter(ctx, "pushed to plugin")
+		}
+		return lister.ListGroups(ctx, params)
+	}
+
+	// Apply the parts of the query the plugin does not apply natively
+	return scim.ListGroupsWithCapabilities(ctx, a.plugin, params)
 }
 
 // CreateGroup implements scim.PluginGetter
@@ -92,13 +272,80 @@ func NewAdaptedManager(manager *Manager) *AdaptedManager {
 	return &AdaptedManager{manager: manager}
 }
 
-// Get retrieves an adapted plugin by name
+// Get retrieves an adapted plugin by name.
+// Opt-in wrappers enabled in the plugin's configuration are applied around the adapter.
 func (am *AdaptedManager) Get(name string) (scim.PluginGetter, bool) {
+	return am.get(name, true, true)
+}
+
+// get retrieves an adapted plugin by name, holding its changes for approval
+// if approval is set and the plugin is configured with approval, and
+// queueing its writes if async is set and the plugin is configured with
+// asyncWrites. Approved changes are applied to the plugin without the
+// approval queue, and the worker applying the queued writes uses the plugin
+// without either.
+func (am *AdaptedManager) get(name string, approval, async bool) (scim.PluginGetter, bool) {
 	plugin, ok := am.manager.Get(name)
 	if !ok {
 		return nil, false
 	}
-	return NewAdapter(plugin), true
+
+	adapter := NewAdapter(plugin)
+	var getter scim.PluginGetter = adapter
+	if cfg, ok := am.manager.GetConfig(name); ok {
+		adapter.defaultExcluded = cfg.DefaultExcludedAttributes
+		adapter.baseURL = cfg.BaseURL
+		adapter.sortCollation = cfg.SortCollation
+		adapter.accentInsensitive = cfg.AccentInsensitive
+		adapter.operations = cfg.Operations
+		if normalize := am.manager.getNormalizeConfig(cfg); normalize != nil {
+			adapter.normalization = &scim.Normalization{
+				TrimWhitespace:     normalize.TrimWhitespace,
+				LowercaseUserName:  normalize.LowercaseUserName,
+				PhoneNumbers:       normalize.PhoneNumbers,
+				DefaultCountryCode: normalize.DefaultCountryCode,
+				ValidateEmails:     normalize.ValidateEmails,
+			}
+		}
+		if cfg.ClientCache != nil {
+			adapter.clientCache = &scim.ClientCache{
+				DiscoveryMaxAge: cfg.ClientCache.DiscoveryMaxAge,
+				ResourceMaxAge:  cfg.ClientCache.ResourceMaxAge,
+			}
+		}
+		if cfg.Bulk != nil {
+			adapter.maxBulkOperations = cfg.Bulk.MaxOperations
+			adapter.maxBulkPayloadSize = cfg.Bulk.MaxPayloadSize
+		}
+		if cfg.MembershipSync {
+			getter = scim.NewMembershipSync(getter, am.manager.getLogger())
+		}
+		if hasQuota(cfg) {
+			getter = newQuotaGetter(getter, name, cfg, am.manager)
+		}
+	}
+	if log, ok := am.manager.GetAuditLog(name); ok {
+		getter = newAuditGetter(getter, log)
+	}
+	if budget, ok := am.manager.GetErrorBudget(name); ok {
+		getter = newBudgetGetter(getter, budget, am.manager.budgetBreakers(name))
+	}
+	if breakers, ok := am.manager.GetBreakers(name); ok {
+		getter = newBreakerGetter(getter, name, breakers)
+	}
+	if cache, ok := am.manager.GetCache(name); ok {
+		getter = newCacheGetter(getter, cache)
+	}
+	if window, ok := am.manager.GetWriteWindow(name); ok {
+		getter = newWindowGetter(getter, window)
+	}
+	if queue, ok := am.manager.GetAsyncQueue(name); ok && async {
+		getter = newAsyncGetter(getter, queue)
+	}
+	if queue, ok := am.manager.GetApprovalQueue(name); ok && approval {
+		getter = newApprovalGetter(getter, queue)
+	}
+	return getter, true
 }
 
 // List returns all registered plugin names
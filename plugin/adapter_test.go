@@ -1,10 +1,18 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/config"
 	"github.com/marcelom97/scimgateway/scim"
 )
 
@@ -90,6 +98,98 @@ func TestAdapterGetUsers(t *testing.T) {
 	}
 }
 
+// TestSimpleAdapterServesPlugins verifies that plugins can be served without
+// the gateway through scim.NewSimpleAdapter
+func TestSimpleAdapterServesPlugins(t *testing.T) {
+	var p Plugin = &contextAwarePlugin{name: "test"}
+	getter := scim.NewSimpleAdapter(p)
+
+	response, err := getter.GetUsers(testCtx, scim.QueryParams{Filter: `userName eq "nobody"`})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if response.TotalResults != 0 {
+		t.Errorf("Expected the filter to match no user, got %d", response.TotalResults)
+	}
+}
+
+// listerPlugin paginates natively and returns a fixed page
+type listerPlugin struct {
+	contextAwarePlugin
+	params scim.QueryParams
+}
+
+func (p *listerPlugin) ListUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	p.params = params
+	return &scim.ListResponse[*scim.User]{
+		TotalResults: 250,
+		StartIndex:   params.StartIndex,
+		ItemsPerPage: 1,
+		Resources:    []*scim.User{{ID: "u101", UserName: "page"}},
+	}, nil
+}
+
+func (p *listerPlugin) ListGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return &scim.ListResponse[*scim.Group]{TotalResults: 7, StartIndex: params.StartIndex}, nil
+}
+
+func TestAdapterGetUsersLister(t *testing.T) {
+	p := &listerPlugin{contextAwarePlugin: contextAwarePlugin{name: "test"}}
+	adapter := NewAdapter(p)
+
+	params := scim.QueryParams{StartIndex: 101, Count: 1}
+	response, err := adapter.GetUsers(testCtx, params)
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+
+	// The page is returned as is, not paginated again
+	if response.TotalResults != 250 || len(response.Resources) != 1 || response.Resources[0].ID != "u101" {
+		t.Errorf("GetUsers() = %+v, want the plugin's page", response)
+	}
+	if !reflect.DeepEqual(p.params, params) {
+		t.Errorf("ListUsers() params = %+v, want %+v", p.params, params)
+	}
+
+	groups, err := adapter.GetGroups(testCtx, params)
+	if err != nil || groups.TotalResults != 7 {
+		t.Errorf("GetGroups() = %+v, %v, want the plugin's page", groups, err)
+	}
+}
+
+// filteringPlugin filters users natively and returns them unchanged
+type filteringPlugin struct {
+	contextAwarePlugin
+	params scim.QueryParams
+}
+
+func (p *filteringPlugin) Capabilities() scim.QueryCapabilities {
+	return scim.QueryCapabilities{Filtering: true}
+}
+
+func (p *filteringPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	p.params = params
+	return []*scim.User{{ID: "u2", UserName: "bob"}, {ID: "u1", UserName: "alice"}}, nil
+}
+
+func TestAdapterGetUsersCapabilities(t *testing.T) {
+	p := &filteringPlugin{contextAwarePlugin: contextAwarePlugin{name: "test"}}
+	params := scim.QueryParams{Filter: `userName eq "carol"`, SortBy: "userName", StartIndex: 1, Count: 1}
+
+	response, err := NewAdapter(p).GetUsers(testCtx, params)
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+
+	// The filter is left to the plugin; sorting and paging are applied
+	if p.params.Filter != params.Filter || p.params.Count != 0 {
+		t.Errorf("GetUsers() params = %+v, want the filter and every match", p.params)
+	}
+	if response.TotalResults != 2 || len(response.Resources) != 1 || response.Resources[0].UserName != "alice" {
+		t.Errorf("GetUsers() = %+v, want alice as the first of 2", response)
+	}
+}
+
 func TestAdapterCreateUser(t *testing.T) {
 	p := &contextAwarePlugin{name: "test"}
 	adapter := NewAdapter(p)
@@ -299,3 +399,420 @@ func TestAdaptedManagerList(t *testing.T) {
 		t.Errorf("Expected 2 plugins, got %d", len(list))
 	}
 }
+
+func TestAdaptedManagerGetMembershipSync(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "synced"}, &config.PluginConfig{Name: "synced", MembershipSync: true})
+
+	adaptedManager := NewAdaptedManager(manager)
+
+	plain, _ := adaptedManager.Get("plain")
+	if _, ok := plain.(*Adapter); !ok {
+		t.Errorf("Expected *Adapter without membership sync, got %T", plain)
+	}
+
+	synced, _ := adaptedManager.Get("synced")
+	if _, ok := synced.(*scim.MembershipSync); !ok {
+		t.Errorf("Expected *scim.MembershipSync when enabled, got %T", synced)
+	}
+}
+
+// readOnlyUsersPlugin fails to modify users
+type readOnlyUsersPlugin struct {
+	mockPlugin
+}
+
+func (p *readOnlyUsersPlugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return errors.New("users are read-only")
+}
+
+func TestAdaptedManagerMembershipSyncLogs(t *testing.T) {
+	var logs bytes.Buffer
+	manager := NewManager()
+	manager.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+	manager.Register(&readOnlyUsersPlugin{mockPlugin{name: "synced"}}, &config.PluginConfig{Name: "synced", MembershipSync: true})
+
+	getter, _ := NewAdaptedManager(manager).Get("synced")
+	if _, err := getter.CreateGroup(context.Background(), &scim.Group{ID: "g1", DisplayName: "Admins", Members: []scim.MemberRef{{Value: "u1"}}}); err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if !strings.Contains(logs.String(), "membership sync: failed to add group to user") || !strings.Contains(logs.String(), "users are read-only") {
+		t.Errorf("logs = %q, want the fan-out failure", logs.String())
+	}
+}
+
+// defaultsPlugin excludes groups by default
+type defaultsPlugin struct {
+	contextAwarePlugin
+}
+
+func (p *defaultsPlugin) DefaultExcludedAttributes() []string {
+	return []string{"groups"}
+}
+
+func TestAdaptedManagerDefaultExcludedAttributes(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{Name: "configured", DefaultExcludedAttributes: []string{"members"}})
+	manager.Register(&defaultsPlugin{contextAwarePlugin{name: "own"}}, &config.PluginConfig{Name: "own"})
+	manager.Register(&defaultsPlugin{contextAwarePlugin{name: "overridden"}}, &config.PluginConfig{Name: "overridden", DefaultExcludedAttributes: []string{"members"}})
+
+	adaptedManager := NewAdaptedManager(manager)
+
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"plain", nil},
+		{"configured", []string{"members"}},
+		{"own", []string{"groups"}},
+		{"overridden", []string{"members"}},
+	}
+	for _, tt := range tests {
+		getter, _ := adaptedManager.Get(tt.name)
+		got := getter.(scim.DefaultExcludedAttributesProvider).DefaultExcludedAttributes()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: DefaultExcludedAttributes() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptedManagerSortCollation(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{Name: "configured", SortCollation: config.SortCollationCaseInsensitive})
+
+	adaptedManager := NewAdaptedManager(manager)
+	for name, want := range map[string]string{"plain": "", "configured": scim.CollationCaseInsensitive} {
+		getter, _ := adaptedManager.Get(name)
+		if got := getter.(scim.CollationProvider).SortCollation(); got != want {
+			t.Errorf("%s: SortCollation() = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// accentInsensitivePlugin ignores accents in filters on its own
+type accentInsensitivePlugin struct {
+	contextAwarePlugin
+}
+
+func (p *accentInsensitivePlugin) AccentInsensitive() bool {
+	return true
+}
+
+func TestAdaptedManagerAccentInsensitive(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{Name: "configured", AccentInsensitive: true})
+	manager.Register(&accentInsensitivePlugin{contextAwarePlugin{name: "own"}}, &config.PluginConfig{Name: "own"})
+
+	adaptedManager := NewAdaptedManager(manager)
+	for name, want := range map[string]bool{"plain": false, "configured": true, "own": true} {
+		getter, _ := adaptedManager.Get(name)
+		if got := getter.(scim.AccentInsensitiveProvider).AccentInsensitive(); got != want {
+			t.Errorf("%s: AccentInsensitive() = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestAdaptedManagerNormalization(t *testing.T) {
+	manager := NewManager()
+	manager.SetNormalizeConfig(&config.NormalizeConfig{TrimWhitespace: true})
+	manager.Register(&contextAwarePlugin{name: "default"}, &config.PluginConfig{Name: "default"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{
+		Name:      "configured",
+		Normalize: &config.NormalizeConfig{LowercaseUserName: true},
+	})
+
+	adaptedManager := NewAdaptedManager(manager)
+	for name, want := range map[string]scim.Normalization{
+		"default":    {TrimWhitespace: true},
+		"configured": {LowercaseUserName: true},
+	} {
+		getter, _ := adaptedManager.Get(name)
+		if got := getter.(scim.NormalizationProvider).Normalization(); got == nil || *got != want {
+			t.Errorf("%s: Normalization() = %+v, want %+v", name, got, want)
+		}
+	}
+
+	manager.SetNormalizeConfig(nil)
+	getter, _ := adaptedManager.Get("default")
+	if got := getter.(scim.NormalizationProvider).Normalization(); got != nil {
+		t.Errorf("Normalization() without settings = %+v, want nil", got)
+	}
+}
+
+// baseURLPlugin serves its resources under its own base URL
+type baseURLPlugin struct {
+	contextAwarePlugin
+}
+
+func (p *baseURLPlugin) BaseURL() string {
+	return "https://own.example.com"
+}
+
+func TestAdaptedManagerBaseURL(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{Name: "configured", BaseURL: "https://scim.acme.example"})
+	manager.Register(&baseURLPlugin{contextAwarePlugin{name: "own"}}, &config.PluginConfig{Name: "own"})
+	manager.Register(&baseURLPlugin{contextAwarePlugin{name: "overridden"}}, &config.PluginConfig{Name: "overridden", BaseURL: "https://scim.acme.example"})
+
+	adaptedManager := NewAdaptedManager(manager)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"plain", ""},
+		{"configured", "https://scim.acme.example"},
+		{"own", "https://own.example.com"},
+		{"overridden", "https://scim.acme.example"},
+	}
+	for _, tt := range tests {
+		getter, _ := adaptedManager.Get(tt.name)
+		if got := getter.(scim.BaseURLProvider).BaseURL(); got != tt.want {
+			t.Errorf("%s: BaseURL() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAdaptedManagerClientCache(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "configured"}, &config.PluginConfig{
+		Name:        "configured",
+		ClientCache: &config.ClientCacheConfig{DiscoveryMaxAge: time.Hour, ResourceMaxAge: 30 * time.Second},
+	})
+
+	adaptedManager := NewAdaptedManager(manager)
+	for name, want := range map[string]scim.ClientCache{
+		"plain":      {},
+		"configured": {DiscoveryMaxAge: time.Hour, ResourceMaxAge: 30 * time.Second},
+	} {
+		getter, _ := adaptedManager.Get(name)
+		if got := getter.(scim.ClientCacheProvider).ClientCache(); got != want {
+			t.Errorf("%s: ClientCache() = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestAdaptedManagerOperations(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&contextAwarePlugin{name: "restricted"}, &config.PluginConfig{
+		Name:       "restricted",
+		Operations: map[string][]string{"Groups": {"read"}},
+	})
+	manager.Register(&contextAwarePlugin{name: "lowercase"}, &config.PluginConfig{
+		Name:       "lowercase",
+		Operations: map[string][]string{"users": {"read"}},
+	})
+
+	adaptedManager := NewAdaptedManager(manager)
+
+	tests := []struct {
+		name           string
+		endpoint       string
+		want           []string
+		wantRestricted bool
+	}{
+		{"plain", "Groups", nil, false},
+		{"restricted", "Users", nil, false},
+		{"restricted", "Groups", []string{"read"}, true},
+		{"lowercase", "Users", []string{"read"}, true},
+	}
+	for _, tt := range tests {
+		getter, _ := adaptedManager.Get(tt.name)
+		got, restricted := getter.(scim.OperationsProvider).AllowedOperations(tt.endpoint)
+		if !reflect.DeepEqual(got, tt.want) || restricted != tt.wantRestricted {
+			t.Errorf("%s: AllowedOperations(%q) = %v, %v; want %v, %v", tt.name, tt.endpoint, got, restricted, tt.want, tt.wantRestricted)
+		}
+	}
+}
+
+// bulkLimitsPlugin is a plugin with its own limits of bulk requests
+type bulkLimitsPlugin struct {
+	contextAwarePlugin
+}
+
+func (p *bulkLimitsPlugin) BulkLimits() (int, int) { return 10, 4096 }
+
+func TestAdaptedManagerBulkLimits(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&contextAwarePlugin{name: "plain"}, &config.PluginConfig{Name: "plain"})
+	manager.Register(&bulkLimitsPlugin{contextAwarePlugin{name: "own"}}, &config.PluginConfig{Name: "own"})
+	manager.Register(&bulkLimitsPlugin{contextAwarePlugin{name: "configured"}}, &config.PluginConfig{
+		Name: "configured",
+		Bulk: &config.BulkConfig{MaxOperations: 50},
+	})
+
+	adaptedManager := NewAdaptedManager(manager)
+
+	tests := []struct {
+		name                            string
+		wantOperations, wantPayloadSize int
+	}{
+		{"plain", 0, 0},
+		{"own", 10, 4096},
+		{"configured", 50, 4096},
+	}
+	for _, tt := range tests {
+		getter, _ := adaptedManager.Get(tt.name)
+		operations, payloadSize := getter.(scim.BulkLimitsProvider).BulkLimits()
+		if operations != tt.wantOperations || payloadSize != tt.wantPayloadSize {
+			t.Errorf("%s: BulkLimits() = %d, %d; want %d, %d", tt.name, operations, payloadSize, tt.wantOperations, tt.wantPayloadSize)
+		}
+	}
+}
+
+func TestManagerGetConfig(t *testing.T) {
+	manager := NewManager()
+	cfg := &config.PluginConfig{Name: "test", MembershipSync: true}
+	manager.Register(&contextAwarePlugin{name: "test"}, cfg)
+
+	got, ok := manager.GetConfig("test")
+	if !ok || got != cfg {
+		t.Errorf("GetConfig() = %v, %v; want registered config", got, ok)
+	}
+
+	manager.Register(&contextAwarePlugin{name: "test"}, nil)
+	if _, ok := manager.GetConfig("test"); ok {
+		t.Error("GetConfig() should return false after re-registering without config")
+	}
+}
+
+func TestAdapterUnwrap(t *testing.T) {
+	p := &contextAwarePlugin{name: "test"}
+	adapter := NewAdapter(p)
+
+	if adapter.Unwrap() != p {
+		t.Error("Unwrap() should return the wrapped plugin")
+	}
+}
+
+// externalIDPlugin looks up users by externalId and fails listing them
+type externalIDPlugin struct {
+	mockPlugin
+	lookups int
+}
+
+func (p *externalIDPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	return nil, errors.New("list not expected")
+}
+
+func (p *externalIDPlugin) LookupUserByExternalID(ctx context.Context, externalID string) (*scim.User, error) {
+	p.lookups++
+	if externalID != "ext-1" {
+		return nil, nil
+	}
+	return &scim.User{ID: "u1", UserName: "alice", ExternalID: "ext-1"}, nil
+}
+
+func (p *externalIDPlugin) LookupGroupByExternalID(ctx context.Context, externalID string) (*scim.Group, error) {
+	return nil, nil
+}
+
+func TestAdapterGetUsersExternalIDLookup(t *testing.T) {
+	p := &externalIDPlugin{mockPlugin: mockPlugin{name: "test"}}
+	adapter := NewAdapter(p)
+
+	list, err := adapter.GetUsers(context.Background(), scim.QueryParams{Filter: `externalId eq "ext-1"`})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if p.lookups != 1 || list.TotalResults != 1 || list.Resources[0].ID != "u1" {
+		t.Errorf("GetUsers() = %+v after %d lookups, want u1 from one lookup", list, p.lookups)
+	}
+
+	list, err = adapter.GetUsers(context.Background(), scim.QueryParams{Filter: `externalId eq "missing"`})
+	if err != nil || list.TotalResults != 0 {
+		t.Errorf("GetUsers() of a missing externalId = %+v, %v, want no resources", list, err)
+	}
+}
+
+// displayNamePlugin looks up groups by displayName, failing with
+// errors.ErrUnsupported for ambiguous names, and counts group lists
+type displayNamePlugin struct {
+	mockPlugin
+	lookups int
+	lists   int
+}
+
+func (p *displayNamePlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	p.lists++
+	return []*scim.Group{{ID: "g2", DisplayName: "Dup"}, {ID: "g3", DisplayName: "dup"}}, nil
+}
+
+func (p *displayNamePlugin) GetGroupByDisplayName(ctx context.Context, displayName string) (*scim.Group, error) {
+	p.lookups++
+	switch strings.ToLower(displayName) {
+	case "admins":
+		return &scim.Group{ID: "g1", DisplayName: "Admins"}, nil
+	case "dup":
+		return nil, fmt.Errorf("several groups are named %q: %w", displayName, errors.ErrUnsupported)
+	}
+	return nil, nil
+}
+
+func TestAdapterGetGroupsDisplayNameLookup(t *testing.T) {
+	p := &displayNamePlugin{mockPlugin: mockPlugin{name: "test"}}
+	adapter := NewAdapter(p)
+
+	list, err := adapter.GetGroups(context.Background(), scim.QueryParams{Filter: `displayName eq "admins"`})
+	if err != nil {
+		t.Fatalf("GetGroups() error = %v", err)
+	}
+	if p.lookups != 1 || p.lists != 0 || list.TotalResults != 1 || list.Resources[0].ID != "g1" {
+		t.Errorf("GetGroups() = %+v after %d lookups and %d lists, want g1 from one lookup", list, p.lookups, p.lists)
+	}
+
+	list, err = adapter.GetGroups(context.Background(), scim.QueryParams{Filter: `displayName eq "Dup"`})
+	if err != nil || p.lists != 1 || list.TotalResults != 2 {
+		t.Errorf("GetGroups() of an ambiguous displayName = %+v, %v after %d lists, want both groups listed", list, err, p.lists)
+	}
+}
+
+// userNamePlugin looks up users by userName and counts user lists
+type userNamePlugin struct {
+	mockPlugin
+	lookups int
+	lists   int
+}
+
+func (p *userNamePlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	p.lists++
+	return []*scim.User{{ID: "u1", UserName: "bjensen"}, {ID: "u2", UserName: "alice"}}, nil
+}
+
+func (p *userNamePlugin) GetUserByUserName(ctx context.Context, userName string) (*scim.User, error) {
+	p.lookups++
+	if !strings.EqualFold(userName, "bjensen") {
+		return nil, nil
+	}
+	return &scim.User{ID: "u1", UserName: "bjensen"}, nil
+}
+
+func TestAdapterGetUsersUserNameLookup(t *testing.T) {
+	p := &userNamePlugin{mockPlugin: mockPlugin{name: "test"}}
+	adapter := NewAdapter(p)
+
+	list, err := adapter.GetUsers(context.Background(), scim.QueryParams{Filter: `userName eq "BJensen"`, Attributes: []string{"userName"}})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if p.lookups != 1 || p.lists != 0 || list.TotalResults != 1 || list.Resources[0].ID != "u1" {
+		t.Errorf("GetUsers() = %+v after %d lookups and %d lists, want u1 from one lookup", list, p.lookups, p.lists)
+	}
+
+	list, err = adapter.GetUsers(context.Background(), scim.QueryParams{Filter: `userName eq "missing"`})
+	if err != nil || list.TotalResults != 0 || p.lists != 0 {
+		t.Errorf("GetUsers() of a missing userName = %+v, %v after %d lists, want no resources", list, err, p.lists)
+	}
+
+	// Other filters are evaluated on the list
+	if _, err := adapter.GetUsers(context.Background(), scim.QueryParams{Filter: `userName sw "b"`}); err != nil || p.lists != 1 {
+		t.Errorf("GetUsers() with sw = %v after %d lists, want one list", err, p.lists)
+	}
+}
@@ -0,0 +1,448 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// ErrOperationDecided is returned when a change that was already approved
+// or rejected is decided again
+var ErrOperationDecided = errors.New("operation already decided")
+
+// ApprovalQueue holds the replaces and PATCHes of a plugin changing
+// attributes that need approval, such as the manager or department of a
+// user. Held changes are stored as pending operations with an Approval in
+// an OperationStore and applied to the plugin once they are approved.
+// ApprovalQueue is safe for concurrent use.
+type ApprovalQueue struct {
+	name       string
+	attributes map[string][]string // attributes needing approval by resource type
+	retention  time.Duration
+	store      OperationStore
+	clock      clock.Clock
+
+	// getter returns the plugin approved changes are applied to
+	getter func() (scim.PluginGetter, bool)
+
+	// requested is called for every change held for approval
+	requested func(*Operation)
+}
+
+// newApprovalQueueFromConfig creates the approval queue of a plugin's
+// configuration, which was validated
+func newApprovalQueueFromConfig(name string, cfg *config.ApprovalConfig, store OperationStore, c clock.Clock) *ApprovalQueue {
+	retention := cfg.Retention
+	if retention == 0 {
+		retention = 24 * time.Hour
+	}
+	return &ApprovalQueue{name: name, attributes: cfg.Attributes, retention: retention, store: store, clock: c}
+}
+
+// Get returns the change of the queue's plugin with the given ID, or
+// ErrOperationNotFound
+func (q *ApprovalQueue) Get(ctx context.Context, id string) (*Operation, error) {
+	op, err := q.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Plugin != q.name || op.Approval == nil {
+		return nil, ErrOperationNotFound
+	}
+	return op, nil
+}
+
+// List returns the changes of the queue's plugin, oldest first
+func (q *ApprovalQueue) List(ctx context.Context) ([]*Operation, error) {
+	ops, err := q.store.List(ctx, q.name)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(ops, func(op *Operation) bool { return op.Approval == nil }), nil
+}
+
+// Approve applies a pending change to the plugin and returns it as applied,
+// or as failed with the plugin's error. The subject of ctx is recorded as
+// the approver. Decided changes return ErrOperationDecided.
+func (q *ApprovalQueue) Approve(ctx context.Context, id string) (*Operation, error) {
+	op, err := q.decide(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	getter, ok := q.getter()
+	if !ok {
+		return nil, scim.ErrServiceUnavailable(fmt.Sprintf("Plugin '%s' is not registered", q.name))
+	}
+	op.Attempts++
+	if _, err := applyOperation(operationContext(ctx, op), getter, op); err != nil {
+		op.Status = OperationFailed
+		op.Error = operationError(err)
+	} else {
+		op.Status = OperationApplied
+	}
+	if err := q.store.Save(ctx, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// Reject discards a pending change and returns it as rejected. The subject
+// of ctx is recorded as the decider. Decided changes return
+// ErrOperationDecided.
+func (q *ApprovalQueue) Reject(ctx context.Context, id string) (*Operation, error) {
+	op, err := q.decide(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	op.Status = OperationRejected
+	if err := q.store.Save(ctx, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// decide returns the pending change with the given ID, recording the
+// subject of ctx as the decider
+func (q *ApprovalQueue) decide(ctx context.Context, id string) (*Operation, error) {
+	op, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status != OperationPending {
+		return nil, ErrOperationDecided
+	}
+
+	approval := *op.Approval
+	if principal, ok := scim.PrincipalFromContext(ctx); ok {
+		approval.DecidedBy = principal.Subject
+	}
+	op.Approval = &approval
+	op.Updated = q.clock.Now()
+	return op, nil
+}
+
+// hold stores a change of attributes as a pending operation and
+// acknowledges it with a *scim.AcceptedError pointing to its status.
+// Changes decided longer than the retention ago are deleted.
+func (q *ApprovalQueue) hold(ctx context.Context, method, resourceType, resourceID string, body any, attributes []string) error {
+	now := q.clock.Now()
+	op := &Operation{
+		ID:           uuid.New().String(),
+		Plugin:       q.name,
+		Method:       method,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		BaseEntity:   scim.BaseEntityFromContext(ctx),
+		RequestID:    scimcontext.RequestID(ctx),
+		Status:       OperationPending,
+		Created:      now,
+		Updated:      now,
+		Approval:     &Approval{Attributes: attributes},
+	}
+	if principal, ok := scim.PrincipalFromContext(ctx); ok {
+		op.Approval.RequestedBy = principal.Subject
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode operation: %w", err)
+	}
+	op.Body = data
+
+	q.prune(ctx)
+	if err := q.store.Save(ctx, op); err != nil {
+		return scim.ErrServiceUnavailable(fmt.Sprintf("Cannot hold changes to plugin '%s' for approval: %v", q.name, err))
+	}
+	if q.requested != nil {
+		q.requested(op)
+	}
+	return &scim.AcceptedError{
+		Detail:   fmt.Sprintf("%s changes %s and awaits approval as operation %s", op.describe(), strings.Join(attributes, ", "), op.ID),
+		Location: "Approvals/" + op.ID,
+	}
+}
+
+// prune deletes the changes decided longer than the retention ago
+func (q *ApprovalQueue) prune(ctx context.Context) {
+	ops, err := q.List(ctx)
+	if err != nil {
+		return
+	}
+	for _, op := range ops {
+		if op.Status != OperationPending && q.clock.Now().Sub(op.Updated) > q.retention {
+			q.store.Delete(ctx, op.ID) // nolint:errcheck
+		}
+	}
+}
+
+// heldChanges returns the attributes needing approval whose values differ
+// between the before and after states of a resource
+func (q *ApprovalQueue) heldChanges(resourceType string, before, after any) []string {
+	attributes := q.attributes[resourceType]
+	if len(attributes) == 0 {
+		return nil
+	}
+	old, updated := auditAttributes(before), auditAttributes(after)
+
+	var changed []string
+	for _, attribute := range attributes {
+		if !reflect.DeepEqual(attributeValue(old, attribute), attributeValue(updated, attribute)) {
+			changed = append(changed, attribute)
+		}
+	}
+	return changed
+}
+
+// attributeValue returns the value of an attribute path such as
+// name.familyName or urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager
+// in the JSON attributes of a resource, matching names case-insensitively
+func attributeValue(attributes map[string]any, path string) any {
+	var value any = attributes
+	urn, relPath := scim.SplitSchemaURN(path)
+	if urn != "" && urn != scim.SchemaUser && urn != scim.SchemaGroup {
+		value = lookupAttribute(value, urn)
+	}
+	if relPath == "" {
+		return value
+	}
+	for _, name := range strings.Split(relPath, ".") {
+		value = lookupAttribute(value, name)
+	}
+	return value
+}
+
+// lookupAttribute returns the attribute name of a JSON object, or nil
+func lookupAttribute(object any, name string) any {
+	attributes, ok := object.(map[string]any)
+	if !ok {
+		return nil
+	}
+	for key, value := range attributes {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// applyApprovalConfig creates the approval queue of a plugin. Held changes
+// live in the operation store, so a recreated queue picks them up. Callers
+// must hold m.mu.
+func (m *Manager) applyApprovalConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.Approval == nil {
+		delete(m.approvals, name)
+		return
+	}
+
+	queue := newApprovalQueueFromConfig(name, cfg.Approval, m.operations, m.clock)
+	queue.getter = func() (scim.PluginGetter, bool) {
+		return NewAdaptedManager(m).get(name, false, true)
+	}
+	queue.requested = m.approvalRequested
+	m.approvals[name] = queue
+}
+
+// GetApprovalQueue retrieves the approval queue of a plugin configured with
+// approval
+func (m *Manager) GetApprovalQueue(name string) (*ApprovalQueue, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	queue, ok := m.approvals[name]
+	return queue, ok
+}
+
+// OnApprovalRequested registers fn to be called for every change held for
+// approval by a plugin configured with approval, e.g. to open a request in
+// the approval system, which then calls the approve or reject endpoint of
+// the change. Functions are called while the request is served, so they
+// must not block.
+func (m *Manager) OnApprovalRequested(fn func(*Operation)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.approvalListeners = append(m.approvalListeners, fn)
+}
+
+// approvalRequested calls the functions registered with OnApprovalRequested
+func (m *Manager) approvalRequested(op *Operation) {
+	m.mu.RLock()
+	listeners := m.approvalListeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(op)
+	}
+}
+
+// approvalGetter holds the replaces and PATCHes of a PluginGetter changing
+// attributes that need approval in an ApprovalQueue, and acknowledges them
+// with a *scim.AcceptedError. Other writes and reads are passed through.
+// The state before a change is read from the PluginGetter, and a PATCH is
+// applied to it in memory to tell the attributes it changes; writes whose
+// changes cannot be told this way fail rather than pass unapproved.
+type approvalGetter struct {
+	next  scim.PluginGetter
+	queue *ApprovalQueue
+}
+
+// newApprovalGetter wraps next with an approval queue
+func newApprovalGetter(next scim.PluginGetter, queue *ApprovalQueue) scim.PluginGetter {
+	return &approvalGetter{next: next, queue: queue}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *approvalGetter) Unwrap() any {
+	return g.next
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *approvalGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return g.next.GetUsers(ctx, params)
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *approvalGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return g.next.CreateUser(ctx, user)
+}
+
+// GetUser implements scim.PluginGetter
+func (g *approvalGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return g.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *approvalGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := g.ModifyUserResult(ctx, id, patch)
+	return err
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *approvalGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	before, err := g.next.GetUser(ctx, id, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		after, err := patchedCopy(before, patch)
+		if err != nil {
+			return nil, err
+		}
+		if changed := g.queue.heldChanges("Users", before, after); len(changed) > 0 {
+			return nil, g.queue.hold(ctx, http.MethodPatch, "Users", id, patch, changed)
+		}
+	}
+	return scim.ModifyUser(ctx, g.next, id, patch)
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *approvalGetter) DeleteUser(ctx context.Context, id string) error {
+	return g.next.DeleteUser(ctx, id)
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *approvalGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	before, err := g.next.GetUser(ctx, id, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if changed := g.queue.heldChanges("Users", before, user); len(changed) > 0 {
+			return nil, g.queue.hold(ctx, http.MethodPut, "Users", id, user, changed)
+		}
+	}
+	return scim.ReplaceUser(ctx, g.next, id, user)
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *approvalGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return g.next.GetGroups(ctx, params)
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *approvalGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return g.next.CreateGroup(ctx, group)
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *approvalGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return g.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *approvalGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := g.ModifyGroupResult(ctx, id, patch)
+	return err
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *approvalGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	before, err := g.next.GetGroup(ctx, id, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		after, err := patchedCopy(before, patch)
+		if err != nil {
+			return nil, err
+		}
+		if changed := g.queue.heldChanges("Groups", before, after); len(changed) > 0 {
+			return nil, g.queue.hold(ctx, http.MethodPatch, "Groups", id, patch, changed)
+		}
+	}
+	return scim.ModifyGroup(ctx, g.next, id, patch)
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *approvalGetter) DeleteGroup(ctx context.Context, id string) error {
+	return g.next.DeleteGroup(ctx, id)
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *approvalGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	before, err := g.next.GetGroup(ctx, id, nil)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if changed := g.queue.heldChanges("Groups", before, group); len(changed) > 0 {
+			return nil, g.queue.hold(ctx, http.MethodPut, "Groups", id, group, changed)
+		}
+	}
+	return scim.ReplaceGroup(ctx, g.next, id, group)
+}
+
+// isNotFound reports whether err reports a missing resource. Writes to it
+// are passed to the plugin, which answers them as usual; other failures to
+// read the resource fail the write, since its changes cannot be checked.
+func isNotFound(err error) bool {
+	var scimErr *scim.SCIMError
+	return errors.As(err, &scimErr) && scimErr.Status == http.StatusNotFound
+}
+
+// patchedCopy applies patch to a copy of a user or group, leaving the
+// original, which may be the plugin's storage, unchanged
+func patchedCopy[T any](resource *T, patch *scim.PatchOp) (*T, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	patched := new(T)
+	if err := json.Unmarshal(data, patched); err != nil {
+		return nil, err
+	}
+	if err := scim.NewPatchProcessor().ApplyPatch(patched, patch); err != nil {
+		return nil, err
+	}
+	return patched, nil
+}
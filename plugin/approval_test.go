@@ -0,0 +1,201 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+const enterpriseManager = scim.SchemaEnterpriseUser + ":manager"
+
+func TestApproval(t *testing.T) {
+	manager := NewManager()
+	manager.Register(testutil.NewMemoryPlugin("hr"), &config.PluginConfig{
+		Name:     "hr",
+		Approval: &config.ApprovalConfig{Attributes: map[string][]string{"Users": {"title", enterpriseManager}}},
+	})
+	var requested []*Operation
+	manager.OnApprovalRequested(func(op *Operation) { requested = append(requested, op) })
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	queue, _ := manager.GetApprovalQueue("hr")
+	ctx := scimcontext.WithIdentity(context.Background(), scimcontext.AuthIdentity{Subject: "okta"})
+
+	user, err := getter.CreateUser(ctx, &scim.User{UserName: "alice", Title: "Engineer"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// Changes of other attributes are applied right away
+	rename := &scim.PatchOp{
+		Schemas:    []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "Alice"}},
+	}
+	if err := getter.ModifyUser(ctx, user.ID, rename); err != nil {
+		t.Fatalf("ModifyUser() error = %v", err)
+	}
+
+	promote := &scim.PatchOp{
+		Schemas: []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{
+			{Op: "replace", Path: "title", Value: "Manager"},
+			{Op: "add", Path: enterpriseManager, Value: map[string]any{"value": "bob"}},
+		},
+	}
+	var accepted *scim.AcceptedError
+	if err := getter.ModifyUser(ctx, user.ID, promote); !errors.As(err, &accepted) {
+		t.Fatalf("ModifyUser() error = %v, want *scim.AcceptedError", err)
+	}
+	if current, _ := getter.GetUser(ctx, user.ID, nil); current.Title != "Engineer" || current.DisplayName != "Alice" {
+		t.Fatalf("user = %+v, want the held change not applied", current)
+	}
+
+	ops, _ := queue.List(ctx)
+	if len(ops) != 1 || accepted.Location != "Approvals/"+ops[0].ID || len(requested) != 1 {
+		t.Fatalf("List() = %+v, Location = %q, %d requested", ops, accepted.Location, len(requested))
+	}
+	op := ops[0]
+	if op.Status != OperationPending || op.Method != http.MethodPatch || op.Approval.RequestedBy != "okta" ||
+		len(op.Approval.Attributes) != 2 || op.Approval.Attributes[1] != enterpriseManager {
+		t.Errorf("held operation = %+v, approval = %+v", op, op.Approval)
+	}
+
+	// Approving applies the change on behalf of the approver
+	approver := scimcontext.WithIdentity(context.Background(), scimcontext.AuthIdentity{Subject: "hr-admin"})
+	op, err = queue.Approve(approver, op.ID)
+	if err != nil {
+		t.Fatalf("Approve() error = %v", err)
+	}
+	if op.Status != OperationApplied || op.Approval.DecidedBy != "hr-admin" {
+		t.Errorf("approved operation = %+v, approval = %+v", op, op.Approval)
+	}
+	if current, _ := getter.GetUser(ctx, user.ID, nil); current.Title != "Manager" {
+		t.Errorf("title = %q after approval, want Manager", current.Title)
+	}
+	if _, err := queue.Reject(approver, op.ID); !errors.Is(err, ErrOperationDecided) {
+		t.Errorf("Reject() of an approved change error = %v, want ErrOperationDecided", err)
+	}
+
+	// Rejected replaces are never applied
+	replaced := *user
+	replaced.Title = "Director"
+	if _, err := getter.(scim.UserReplacer).ReplaceUser(ctx, user.ID, &replaced); !errors.As(err, &accepted) {
+		t.Fatalf("ReplaceUser() error = %v, want *scim.AcceptedError", err)
+	}
+	ops, _ = queue.List(ctx)
+	if op, err := queue.Reject(approver, ops[1].ID); err != nil || op.Status != OperationRejected {
+		t.Fatalf("Reject() = %+v, %v", op, err)
+	}
+	if current, _ := getter.GetUser(ctx, user.ID, nil); current.Title != "Manager" {
+		t.Errorf("title = %q after rejection, want Manager", current.Title)
+	}
+}
+
+func TestApprovalSeparateFromAsyncWrites(t *testing.T) {
+	manager := NewManager()
+	manager.Register(testutil.NewMemoryPlugin("hr"), &config.PluginConfig{
+		Name:        "hr",
+		AsyncWrites: &config.AsyncWritesConfig{},
+		Approval:    &config.ApprovalConfig{Attributes: map[string][]string{"Groups": {"displayName"}}},
+	})
+	adapted := NewAdaptedManager(manager)
+	worker, _ := adapted.get("hr", false, false)
+	group, err := worker.CreateGroup(context.Background(), &scim.Group{DisplayName: "Admins"})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	getter, _ := adapted.Get("hr")
+	rename := &scim.PatchOp{
+		Schemas:    []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "Operators"}},
+	}
+	var accepted *scim.AcceptedError
+	if err := getter.ModifyGroup(context.Background(), group.ID, rename); !errors.As(err, &accepted) || accepted.Location[:10] != "Approvals/" {
+		t.Fatalf("ModifyGroup() error = %v, want the change held for approval", err)
+	}
+
+	// Held changes are neither listed nor applied by the asynchronous queue
+	asyncQueue, _ := manager.GetAsyncQueue("hr")
+	if ops, _ := asyncQueue.List(context.Background()); len(ops) != 0 {
+		t.Errorf("async List() = %+v, want no operations", ops)
+	}
+	if done, _ := asyncQueue.Process(context.Background(), worker); done != 0 {
+		t.Errorf("Process() applied %d operations, want none", done)
+	}
+
+	// Approved changes are queued like other writes
+	queue, _ := manager.GetApprovalQueue("hr")
+	ops, _ := queue.List(context.Background())
+	if op, err := queue.Approve(context.Background(), ops[0].ID); err != nil || op.Status != OperationApplied {
+		t.Fatalf("Approve() = %+v, %v", op, err)
+	}
+	if ops, _ := asyncQueue.List(context.Background()); len(ops) != 1 || ops[0].Method != http.MethodPatch {
+		t.Errorf("async List() = %+v, want the approved PATCH queued", ops)
+	}
+}
+
+// unreadablePlugin is a MemoryPlugin whose reads of users fail with readErr
+type unreadablePlugin struct {
+	*testutil.MemoryPlugin
+	readErr error
+}
+
+func (p *unreadablePlugin) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	if p.readErr != nil {
+		return nil, p.readErr
+	}
+	return p.MemoryPlugin.GetUser(ctx, id, attributes)
+}
+
+func TestApprovalFailsClosed(t *testing.T) {
+	p := &unreadablePlugin{MemoryPlugin: testutil.NewMemoryPlugin("hr")}
+	manager := NewManager()
+	manager.Register(p, &config.PluginConfig{
+		Name:     "hr",
+		Approval: &config.ApprovalConfig{Attributes: map[string][]string{"Users": {"title"}}},
+	})
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	ctx := context.Background()
+	user, err := getter.CreateUser(ctx, &scim.User{UserName: "alice", Title: "Engineer"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	promote := &scim.PatchOp{
+		Schemas:    []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{{Op: "replace", Path: "title", Value: "Manager"}},
+	}
+
+	// A PATCH that cannot be applied to the current state is not passed on
+	invalid := &scim.PatchOp{
+		Schemas:    []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{{Op: "move", Path: "title", Value: "x"}, promote.Operations[0]},
+	}
+	if err := getter.ModifyUser(ctx, user.ID, invalid); err == nil {
+		t.Error("ModifyUser() with an invalid PATCH error = nil")
+	}
+
+	// Writes are not passed on unapproved when the state before cannot be read
+	p.readErr = errors.New("connection refused")
+	if err := getter.ModifyUser(ctx, user.ID, promote); !errors.Is(err, p.readErr) {
+		t.Errorf("ModifyUser() error = %v, want the read error", err)
+	}
+	if _, err := scim.ReplaceUser(ctx, getter, user.ID, &scim.User{UserName: "alice", Title: "Manager"}); !errors.Is(err, p.readErr) {
+		t.Errorf("ReplaceUser() error = %v, want the read error", err)
+	}
+	p.readErr = nil
+	if current, _ := getter.GetUser(ctx, user.ID, nil); current.Title != "Engineer" {
+		t.Errorf("title = %q, want the unapproved changes not applied", current.Title)
+	}
+
+	// Writes to missing users are answered by the plugin
+	p.readErr = scim.ErrNotFound("User", "42")
+	if err := getter.ModifyUser(ctx, "42", promote); err == nil || err == p.readErr {
+		t.Errorf("ModifyUser() of a missing user error = %v, want the plugin's", err)
+	}
+}
@@ -0,0 +1,406 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// AsyncWritesPollInterval is how often RunAsyncWrites looks for queued
+// operations that are due, in addition to being woken by new ones
+const AsyncWritesPollInterval = time.Second
+
+// AsyncOptions configures an AsyncQueue. Zero values use the defaults.
+type AsyncOptions struct {
+	// MaxAttempts is the number of times an operation failing with a backend
+	// error is tried before it fails. Defaults to 5.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the first retry, doubled for every
+	// further retry. Defaults to 30s.
+	RetryDelay time.Duration
+
+	// Retention is how long applied and failed operations are kept for
+	// status requests. Defaults to 24h.
+	Retention time.Duration
+
+	// Clock timestamps operations and schedules retries. Nil uses
+	// clock.System.
+	Clock clock.Clock
+}
+
+// AsyncQueue queues the writes to a plugin in asynchronous write mode as
+// operations in an OperationStore, and applies them to the plugin in the
+// order they were queued. An operation failing with a backend error (5xx or
+// an error that is not a SCIM error) is retried with exponential backoff and
+// holds back the operations queued after it; other errors fail it right
+// away. AsyncQueue is safe for concurrent use.
+type AsyncQueue struct {
+	name  string
+	opts  AsyncOptions
+	store OperationStore
+	wake  chan struct{} // signals the worker that an operation was queued
+
+	processMu sync.Mutex // serializes Process
+}
+
+// NewAsyncQueue creates the asynchronous write queue of the plugin name,
+// storing its operations in store
+func NewAsyncQueue(name string, store OperationStore, opts AsyncOptions) *AsyncQueue {
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = 30 * time.Second
+	}
+	if opts.Retention == 0 {
+		opts.Retention = 24 * time.Hour
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	return &AsyncQueue{name: name, opts: opts, store: store, wake: make(chan struct{}, 1)}
+}
+
+// newAsyncQueueFromConfig creates the asynchronous write queue of a plugin's
+// configuration, which was validated
+func newAsyncQueueFromConfig(name string, cfg *config.AsyncWritesConfig, store OperationStore, c clock.Clock) *AsyncQueue {
+	return NewAsyncQueue(name, store, AsyncOptions{
+		MaxAttempts: cfg.MaxAttempts,
+		RetryDelay:  cfg.RetryDelay,
+		Retention:   cfg.Retention,
+		Clock:       c,
+	})
+}
+
+// Get returns the operation of the queue's plugin with the given ID, or
+// ErrOperationNotFound
+func (q *AsyncQueue) Get(ctx context.Context, id string) (*Operation, error) {
+	op, err := q.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Plugin != q.name || op.Approval != nil {
+		return nil, ErrOperationNotFound
+	}
+	return op, nil
+}
+
+// List returns the operations of the queue's plugin, oldest first
+func (q *AsyncQueue) List(ctx context.Context) ([]*Operation, error) {
+	ops, err := q.store.List(ctx, q.name)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(ops, func(op *Operation) bool { return op.Approval != nil }), nil
+}
+
+// enqueue stores a pending operation and acknowledges it with a
+// *scim.AcceptedError pointing to the operation's status
+func (q *AsyncQueue) enqueue(ctx context.Context, method, resourceType, resourceID string, body any) error {
+	now := q.opts.Clock.Now()
+	op := &Operation{
+		ID:           uuid.New().String(),
+		Plugin:       q.name,
+		Method:       method,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		BaseEntity:   scim.BaseEntityFromContext(ctx),
+		RequestID:    scimcontext.RequestID(ctx),
+		Status:       OperationPending,
+		Created:      now,
+		Updated:      now,
+		NextAttempt:  now,
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encode operation: %w", err)
+		}
+		op.Body = data
+	}
+	if err := q.store.Save(ctx, op); err != nil {
+		return scim.ErrServiceUnavailable(fmt.Sprintf("Cannot queue writes to plugin '%s': %v", q.name, err))
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return &scim.AcceptedError{
+		Detail:   fmt.Sprintf("%s queued as operation %s", op.describe(), op.ID),
+		Location: "Operations/" + op.ID,
+	}
+}
+
+// describe returns the operation as method and path, e.g. "PATCH Users/42"
+func (op *Operation) describe() string {
+	if op.ResourceID == "" || op.Method == http.MethodPost {
+		return op.Method + " " + op.ResourceType
+	}
+	return op.Method + " " + op.ResourceType + "/" + op.ResourceID
+}
+
+// Process applies the pending operations that are due to getter in the
+// order they were queued, until one is waiting for a retry or ctx is done,
+// and deletes the applied and failed operations older than the retention.
+// It returns the number of operations that were applied or failed.
+// RunAsyncWrites calls it for every plugin in asynchronous write mode.
+func (q *AsyncQueue) Process(ctx context.Context, getter scim.PluginGetter) (int, error) {
+	q.processMu.Lock()
+	defer q.processMu.Unlock()
+
+	ops, err := q.store.List(ctx, q.name)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, op := range ops {
+		if op.Approval != nil {
+			// Changes held for approval share the store
+			continue
+		}
+		if op.Status != OperationPending {
+			if q.opts.Clock.Now().Sub(op.Updated) > q.opts.Retention {
+				if err := q.store.Delete(ctx, op.ID); err != nil {
+					return done, err
+				}
+			}
+			continue
+		}
+		if ctx.Err() != nil || op.NextAttempt.After(q.opts.Clock.Now()) {
+			// Later operations may depend on this one
+			break
+		}
+
+		resourceID, err := applyOperation(operationContext(ctx, op), getter, op)
+		if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+			break
+		}
+
+		op.Attempts++
+		op.Updated = q.opts.Clock.Now()
+		switch {
+		case err == nil:
+			op.Status = OperationApplied
+			op.Error = nil
+			if resourceID != "" {
+				op.ResourceID = resourceID
+			}
+		case isBackendFailure(err) && op.Attempts < q.opts.MaxAttempts:
+			op.Error = operationError(err)
+			op.NextAttempt = op.Updated.Add(q.opts.RetryDelay << (op.Attempts - 1))
+		default:
+			op.Status = OperationFailed
+			op.Error = operationError(err)
+		}
+		if err := q.store.Save(ctx, op); err != nil {
+			return done, err
+		}
+		if op.Status == OperationPending {
+			break
+		}
+		done++
+	}
+	return done, nil
+}
+
+// operationContext returns ctx with the request ID and base entity of the
+// request that queued op
+func operationContext(ctx context.Context, op *Operation) context.Context {
+	if op.RequestID != "" {
+		ctx = scimcontext.WithRequestID(ctx, op.RequestID)
+	}
+	if op.BaseEntity != "" {
+		ctx = scim.WithBaseEntity(ctx, op.BaseEntity)
+	}
+	return ctx
+}
+
+// applyOperation applies an operation to getter and returns the ID of the
+// resource it created or replaced
+func applyOperation(ctx context.Context, getter scim.PluginGetter, op *Operation) (string, error) {
+	switch op.ResourceType {
+	case "Users":
+		switch op.Method {
+		case http.MethodPost, http.MethodPut:
+			var user scim.User
+			if err := json.Unmarshal(op.Body, &user); err != nil {
+				return "", scim.ErrInvalidSyntax(fmt.Sprintf("Invalid queued user: %v", err))
+			}
+			var result *scim.User
+			var err error
+			if op.Method == http.MethodPost {
+				result, err = getter.CreateUser(ctx, &user)
+			} else {
+				result, err = scim.ReplaceUser(ctx, getter, op.ResourceID, &user)
+			}
+			if err != nil || result == nil {
+				return "", ignoreAccepted(err)
+			}
+			return result.ID, nil
+		case http.MethodPatch:
+			var patch scim.PatchOp
+			if err := json.Unmarshal(op.Body, &patch); err != nil {
+				return "", scim.ErrInvalidSyntax(fmt.Sprintf("Invalid queued patch: %v", err))
+			}
+			return "", ignoreAccepted(getter.ModifyUser(ctx, op.ResourceID, &patch))
+		case http.MethodDelete:
+			return "", ignoreAccepted(getter.DeleteUser(ctx, op.ResourceID))
+		}
+	case "Groups":
+		switch op.Method {
+		case http.MethodPost, http.MethodPut:
+			var group scim.Group
+			if err := json.Unmarshal(op.Body, &group); err != nil {
+				return "", scim.ErrInvalidSyntax(fmt.Sprintf("Invalid queued group: %v", err))
+			}
+			var result *scim.Group
+			var err error
+			if op.Method == http.MethodPost {
+				result, err = getter.CreateGroup(ctx, &group)
+			} else {
+				result, err = scim.ReplaceGroup(ctx, getter, op.ResourceID, &group)
+			}
+			if err != nil || result == nil {
+				return "", ignoreAccepted(err)
+			}
+			return result.ID, nil
+		case http.MethodPatch:
+			var patch scim.PatchOp
+			if err := json.Unmarshal(op.Body, &patch); err != nil {
+				return "", scim.ErrInvalidSyntax(fmt.Sprintf("Invalid queued patch: %v", err))
+			}
+			return "", ignoreAccepted(getter.ModifyGroup(ctx, op.ResourceID, &patch))
+		case http.MethodDelete:
+			return "", ignoreAccepted(getter.DeleteGroup(ctx, op.ResourceID))
+		}
+	}
+	return "", scim.ErrInvalidValue(fmt.Sprintf("Unsupported queued operation %s", op.describe()))
+}
+
+// ignoreAccepted returns nil for a *scim.AcceptedError: the plugin took
+// over the operation, which is all the queue can tell about it
+func ignoreAccepted(err error) error {
+	var accepted *scim.AcceptedError
+	if errors.As(err, &accepted) {
+		return nil
+	}
+	return err
+}
+
+// operationError converts the error of an attempt to a SCIM error response
+func operationError(err error) *scim.Error {
+	status, detail, scimType := http.StatusInternalServerError, err.Error(), ""
+	var scimErr *scim.SCIMError
+	if errors.As(err, &scimErr) {
+		status, detail, scimType = scimErr.Status, scimErr.Detail, scimErr.ScimType
+	}
+	return &scim.Error{
+		Schemas:  []string{scim.SchemaError},
+		Status:   strconv.Itoa(status),
+		Detail:   detail,
+		ScimType: scimType,
+	}
+}
+
+// asyncGetter queues the writes to a PluginGetter in an AsyncQueue and
+// acknowledges them with a *scim.AcceptedError. Reads are passed through;
+// streaming and other capabilities are found by unwrapping it.
+type asyncGetter struct {
+	next  scim.PluginGetter
+	queue *AsyncQueue
+}
+
+// newAsyncGetter wraps next with an asynchronous write queue
+func newAsyncGetter(next scim.PluginGetter, queue *AsyncQueue) scim.PluginGetter {
+	return &asyncGetter{next: next, queue: queue}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *asyncGetter) Unwrap() any {
+	return g.next
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *asyncGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return g.next.GetUsers(ctx, params)
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *asyncGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPost, "Users", "", user)
+}
+
+// GetUser implements scim.PluginGetter
+func (g *asyncGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return g.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *asyncGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.queue.enqueue(ctx, http.MethodPatch, "Users", id, patch)
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *asyncGetter) DeleteUser(ctx context.Context, id string) error {
+	return g.queue.enqueue(ctx, http.MethodDelete, "Users", id, nil)
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *asyncGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPut, "Users", id, user)
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *asyncGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPatch, "Users", id, patch)
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *asyncGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return g.next.GetGroups(ctx, params)
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *asyncGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPost, "Groups", "", group)
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *asyncGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return g.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *asyncGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.queue.enqueue(ctx, http.MethodPatch, "Groups", id, patch)
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *asyncGetter) DeleteGroup(ctx context.Context, id string) error {
+	return g.queue.enqueue(ctx, http.MethodDelete, "Groups", id, nil)
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *asyncGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPut, "Groups", id, group)
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *asyncGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	return nil, g.queue.enqueue(ctx, http.MethodPatch, "Groups", id, patch)
+}
@@ -0,0 +1,222 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// flakyPlugin fails the first writes with a backend error
+type flakyPlugin struct {
+	writeLogPlugin
+	failures int
+}
+
+func (p *flakyPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	if p.failures > 0 {
+		p.failures--
+		return nil, scim.ErrServiceUnavailable("HR system busy")
+	}
+	user.ID = "id-" + user.UserName
+	return p.writeLogPlugin.CreateUser(ctx, user)
+}
+
+func TestAsyncWrites(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &flakyPlugin{writeLogPlugin: writeLogPlugin{mockPlugin: mockPlugin{name: "hr"}}, failures: 2}
+	manager := NewManager()
+	manager.SetClock(clk, 0)
+	manager.Register(p, &config.PluginConfig{Name: "hr", AsyncWrites: &config.AsyncWritesConfig{RetryDelay: time.Minute}})
+	adapted := NewAdaptedManager(manager)
+	getter, _ := adapted.Get("hr")
+	worker, _ := adapted.get("hr", false, false)
+	queue, _ := manager.GetAsyncQueue("hr")
+	ctx := context.Background()
+
+	var accepted *scim.AcceptedError
+	if _, err := getter.CreateUser(ctx, &scim.User{UserName: "alice"}); !errors.As(err, &accepted) {
+		t.Fatalf("CreateUser() error = %v, want *scim.AcceptedError", err)
+	}
+	if err := getter.DeleteUser(ctx, "missing"); !errors.As(err, &accepted) {
+		t.Fatalf("DeleteUser() error = %v, want *scim.AcceptedError", err)
+	}
+	if len(p.writes) != 0 {
+		t.Fatalf("writes reached the backend before the worker ran: %v", p.writes)
+	}
+
+	ops, _ := queue.List(ctx)
+	if len(ops) != 2 || accepted.Location != "Operations/"+ops[1].ID || ops[0].Status != OperationPending {
+		t.Fatalf("List() = %+v, Location = %q", ops, accepted.Location)
+	}
+
+	// A backend failure is retried after the delay and holds back the
+	// operations queued after it
+	if done, err := queue.Process(ctx, worker); err != nil || done != 0 {
+		t.Fatalf("Process() = %d, %v, want 0 operations done", done, err)
+	}
+	op, _ := queue.Get(ctx, ops[0].ID)
+	if op.Status != OperationPending || op.Attempts != 1 || op.Error == nil || op.Error.Status != "503" {
+		t.Fatalf("operation after a failed attempt = %+v", op)
+	}
+	if want := clk.Now().Add(time.Minute); !op.NextAttempt.Equal(want) {
+		t.Errorf("NextAttempt = %v, want %v", op.NextAttempt, want)
+	}
+
+	// Not due yet
+	clk.Advance(30 * time.Second)
+	queue.Process(ctx, worker) // nolint:errcheck
+	if op, _ := queue.Get(ctx, ops[0].ID); op.Attempts != 1 {
+		t.Fatalf("Attempts = %d before the retry is due, want 1", op.Attempts)
+	}
+
+	// The retry delay doubles
+	clk.Advance(30 * time.Second)
+	queue.Process(ctx, worker) // nolint:errcheck
+	op, _ = queue.Get(ctx, ops[0].ID)
+	if want := clk.Now().Add(2 * time.Minute); op.Attempts != 2 || !op.NextAttempt.Equal(want) {
+		t.Fatalf("operation after the second attempt = %+v, want next attempt at %v", op, want)
+	}
+
+	clk.Advance(2 * time.Minute)
+	if done, err := queue.Process(ctx, worker); err != nil || done != 2 {
+		t.Fatalf("Process() = %d, %v, want 2 operations done", done, err)
+	}
+	if want := []string{"create alice", "delete missing"}; !slices.Equal(p.writes, want) {
+		t.Errorf("writes = %v, want %v", p.writes, want)
+	}
+	op, _ = queue.Get(ctx, ops[0].ID)
+	if op.Status != OperationApplied || op.ResourceID != "id-alice" || op.Error != nil {
+		t.Errorf("created operation = %+v, want applied to id-alice", op)
+	}
+
+	// Client errors are not retried
+	op, _ = queue.Get(ctx, ops[1].ID)
+	if op.Status != OperationFailed || op.Attempts != 1 || op.Error.Status != "404" {
+		t.Errorf("deleted operation = %+v, want failed with 404", op)
+	}
+
+	// Done operations are deleted after the retention
+	clk.Advance(25 * time.Hour)
+	queue.Process(ctx, worker) // nolint:errcheck
+	if _, err := queue.Get(ctx, ops[0].ID); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("Get() after the retention error = %v, want ErrOperationNotFound", err)
+	}
+}
+
+func TestAsyncWritesMaxAttempts(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &flakyPlugin{writeLogPlugin: writeLogPlugin{mockPlugin: mockPlugin{name: "hr"}}, failures: 5}
+	queue := NewAsyncQueue("hr", NewMemoryOperationStore(), AsyncOptions{MaxAttempts: 2, RetryDelay: time.Second, Clock: clk})
+	getter := newAsyncGetter(NewAdapter(p), queue)
+	ctx := context.Background()
+
+	getter.CreateUser(ctx, &scim.User{UserName: "alice"}) // nolint:errcheck
+	queue.Process(ctx, NewAdapter(p))                     // nolint:errcheck
+	clk.Advance(time.Second)
+	queue.Process(ctx, NewAdapter(p)) // nolint:errcheck
+
+	ops, _ := queue.List(ctx)
+	if len(ops) != 1 || ops[0].Status != OperationFailed || ops[0].Attempts != 2 {
+		t.Errorf("operations = %+v, want one failed after 2 attempts", ops)
+	}
+}
+
+func TestAsyncWritesReplaceConfig(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockPlugin{name: "hr"}, &config.PluginConfig{Name: "hr", AsyncWrites: &config.AsyncWritesConfig{}})
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	ctx := context.Background()
+
+	getter.DeleteUser(ctx, "42") // nolint:errcheck
+
+	// Queued operations live in the store, so reloading keeps them
+	manager.UpdateConfig("hr", &config.PluginConfig{Name: "hr", AsyncWrites: &config.AsyncWritesConfig{MaxAttempts: 3}})
+	queue, _ := manager.GetAsyncQueue("hr")
+	if ops, _ := queue.List(ctx); len(ops) != 1 {
+		t.Fatalf("List() after reload = %+v, want 1 operation", ops)
+	}
+
+	manager.UpdateConfig("hr", &config.PluginConfig{Name: "hr"})
+	if _, ok := manager.GetAsyncQueue("hr"); ok {
+		t.Error("GetAsyncQueue() found a queue after asyncWrites was removed")
+	}
+	getter, _ = NewAdaptedManager(manager).Get("hr")
+	if err := getter.DeleteUser(ctx, "42"); err != nil {
+		t.Errorf("DeleteUser() without asyncWrites error = %v", err)
+	}
+}
+
+func TestFileOperationStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "operations.json")
+	store, err := NewFileOperationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	created := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	for _, op := range []*Operation{
+		{ID: "1", Plugin: "hr", Method: "POST", ResourceType: "Users", Body: []byte(`{"userName":"alice"}`), Status: OperationPending, Created: created},
+		{ID: "2", Plugin: "crm", Method: "DELETE", ResourceType: "Users", ResourceID: "42", Status: OperationPending, Created: created},
+		{ID: "3", Plugin: "hr", Method: "DELETE", ResourceType: "Groups", ResourceID: "7", Status: OperationPending, Created: created},
+	} {
+		if err := store.Save(ctx, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Save(ctx, &Operation{ID: "1", Plugin: "hr", Method: "POST", ResourceType: "Users", Status: OperationApplied, ResourceID: "u1"}) // nolint:errcheck
+	store.Delete(ctx, "3")                                                                                                                // nolint:errcheck
+
+	// A new store reads the operations back
+	reopened, err := NewFileOperationStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, _ := reopened.List(ctx, "hr")
+	if len(ops) != 1 || ops[0].Status != OperationApplied || ops[0].ResourceID != "u1" {
+		t.Errorf("List(hr) = %+v, want operation 1 applied", ops)
+	}
+	op, err := reopened.Get(ctx, "2")
+	if err != nil || op.ResourceID != "42" || !op.Created.Equal(created) {
+		t.Errorf("Get(2) = %+v, %v", op, err)
+	}
+	if _, err := reopened.Get(ctx, "3"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("Get(3) error = %v, want ErrOperationNotFound", err)
+	}
+}
+
+// requestIDPlugin records the request ID and base entity its DeleteUser is
+// called with
+type requestIDPlugin struct {
+	mockPlugin
+	requestID  string
+	baseEntity string
+}
+
+func (p *requestIDPlugin) DeleteUser(ctx context.Context, id string) error {
+	p.requestID = scimcontext.RequestID(ctx)
+	p.baseEntity = scim.BaseEntityFromContext(ctx)
+	return nil
+}
+
+func TestAsyncWritesRequestContext(t *testing.T) {
+	p := &requestIDPlugin{mockPlugin: mockPlugin{name: "hr"}}
+	queue := NewAsyncQueue("hr", NewMemoryOperationStore(), AsyncOptions{})
+	ctx := context.Background()
+
+	reqCtx := scim.WithBaseEntity(scimcontext.WithRequestID(ctx, "req-1"), "acme")
+	newAsyncGetter(NewAdapter(p), queue).DeleteUser(reqCtx, "42") // nolint:errcheck
+	queue.Process(ctx, NewAdapter(p))                             // nolint:errcheck
+
+	if p.requestID != "req-1" || p.baseEntity != "acme" {
+		t.Errorf("plugin saw request ID %q and base entity %q, want those of the request that queued the write", p.requestID, p.baseEntity)
+	}
+}
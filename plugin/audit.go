@@ -0,0 +1,442 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// DefaultAuditMaxEvents is the number of events a MemoryAuditStore keeps by
+// default
+const DefaultAuditMaxEvents = 10000
+
+// AuditChange is the change of one attribute of a resource by a write
+type AuditChange struct {
+	Attribute string `json:"attribute"`
+
+	// Old and New are the values before and after the write, nil if the
+	// attribute had none. Passwords are recorded without their values.
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// AuditEvent is a write to a user or group of a plugin recorded in its
+// audit log
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Plugin string    `json:"plugin"`
+
+	// BaseEntity is the base entity the write was scoped to, if any
+	BaseEntity string `json:"baseEntity,omitempty"`
+
+	// ResourceType is the endpoint of the resource type, Users or Groups
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceId"`
+
+	// Operation is scim.OperationCreate, OperationReplace, OperationPatch
+	// or OperationDelete
+	Operation string `json:"operation"`
+
+	// Subject is the authenticated client that made the write, if known
+	Subject string `json:"subject,omitempty"`
+
+	// RequestID is the X-Request-Id of the request that made the write
+	RequestID string `json:"requestId,omitempty"`
+
+	// Changes are the attributes the write changed, by name
+	Changes []AuditChange `json:"changes,omitempty"`
+}
+
+// AuditStore stores the audit events of plugins. Durable stores keep the
+// history of resources across restarts of the gateway. Implementations must
+// be safe for concurrent use.
+type AuditStore interface {
+	// Append stores an event
+	Append(ctx context.Context, event *AuditEvent) error
+
+	// History returns the events of a resource of a plugin in the order
+	// they were appended
+	History(ctx context.Context, plugin, resourceType, resourceID string) ([]*AuditEvent, error)
+}
+
+// MemoryAuditStore is an AuditStore keeping the latest events in memory, the
+// default
+type MemoryAuditStore struct {
+	maxEvents int
+
+	mu     sync.RWMutex
+	events []*AuditEvent // in append order
+}
+
+// NewMemoryAuditStore creates an empty in-memory audit store keeping at most
+// maxEvents events, or DefaultAuditMaxEvents if maxEvents is not positive.
+// The oldest events are dropped first.
+func NewMemoryAuditStore(maxEvents int) *MemoryAuditStore {
+	if maxEvents <= 0 {
+		maxEvents = DefaultAuditMaxEvents
+	}
+	return &MemoryAuditStore{maxEvents: maxEvents}
+}
+
+// Append implements AuditStore
+func (s *MemoryAuditStore) Append(ctx context.Context, event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) >= s.maxEvents {
+		s.events = slices.Delete(s.events, 0, len(s.events)-s.maxEvents+1)
+	}
+	saved := *event
+	s.events = append(s.events, &saved)
+	return nil
+}
+
+// History implements AuditStore
+func (s *MemoryAuditStore) History(ctx context.Context, plugin, resourceType, resourceID string) ([]*AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var events []*AuditEvent
+	for _, event := range s.events {
+		if event.Plugin == plugin && event.ResourceType == resourceType && event.ResourceID == resourceID {
+			found := *event
+			events = append(events, &found)
+		}
+	}
+	return events, nil
+}
+
+// AuditLog records the writes to the users and groups of a plugin in an
+// AuditStore, with the attributes they changed
+type AuditLog struct {
+	name  string
+	store AuditStore
+}
+
+// NewAuditLog creates the audit log of the plugin name, storing its events
+// in store
+func NewAuditLog(name string, store AuditStore) *AuditLog {
+	return &AuditLog{name: name, store: store}
+}
+
+// History returns the events of a resource, oldest first. Requests scoped
+// to a base entity only see its events.
+func (l *AuditLog) History(ctx context.Context, resourceType, resourceID string) ([]*AuditEvent, error) {
+	events, err := l.store.History(ctx, l.name, resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	entity := scim.BaseEntityFromContext(ctx)
+	return slices.DeleteFunc(events, func(event *AuditEvent) bool {
+		return entity != "" && event.BaseEntity != entity
+	}), nil
+}
+
+// record appends the event of a write that changed a resource from before
+// to after, either of which is nil if the resource did not exist. Failures
+// are not returned, as the write already happened.
+func (l *AuditLog) record(ctx context.Context, operation, resourceType, resourceID string, before, after any, password bool) {
+	event := &AuditEvent{
+		Time:         clock.Now(ctx),
+		Plugin:       l.name,
+		BaseEntity:   scim.BaseEntityFromContext(ctx),
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Operation:    operation,
+		RequestID:    scimcontext.RequestID(ctx),
+		Changes:      auditChanges(before, after, password),
+	}
+	if principal, ok := scim.PrincipalFromContext(ctx); ok {
+		event.Subject = principal.Subject
+	}
+	l.store.Append(ctx, event) // nolint:errcheck
+}
+
+// unaudited are the attributes left out of audit changes: the ID, which
+// names the resource, meta, which every write changes, and the password,
+// whose value is never recorded
+var unaudited = []string{"id", "meta", "password"}
+
+// auditChanges returns the attributes whose values differ between before
+// and after, sorted by name. A password change is recorded if password is
+// set, as plugins do not return passwords.
+func auditChanges(before, after any, password bool) []AuditChange {
+	old, updated := auditAttributes(before), auditAttributes(after)
+
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range updated {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if password {
+		names = append(names, "password")
+	}
+	slices.Sort(names)
+
+	var changes []AuditChange
+	for _, name := range names {
+		if name == "password" {
+			changes = append(changes, AuditChange{Attribute: name})
+			continue
+		}
+		if !reflect.DeepEqual(old[name], updated[name]) {
+			changes = append(changes, AuditChange{Attribute: name, Old: old[name], New: updated[name]})
+		}
+	}
+	return changes
+}
+
+// auditAttributes returns the JSON attributes of a user or group, without
+// the unaudited ones
+func auditAttributes(resource any) map[string]any {
+	if resource == nil || reflect.ValueOf(resource).IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil
+	}
+	var attributes map[string]any
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil
+	}
+	for _, name := range unaudited {
+		delete(attributes, name)
+	}
+	return attributes
+}
+
+// patchesPassword reports whether patch sets the password of a user
+func patchesPassword(patch *scim.PatchOp) bool {
+	for _, op := range patch.Operations {
+		if strings.EqualFold(op.Path, "password") {
+			return true
+		}
+		if value, ok := op.Value.(map[string]any); ok && op.Path == "" {
+			for name := range value {
+				if strings.EqualFold(name, "password") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// auditState holds the audit log of a plugin with the settings it was
+// created with
+type auditState struct {
+	settings config.AuditConfig
+	store    AuditStore // the shared store, nil for the plugin's memory store
+	log      *AuditLog
+}
+
+// applyAuditConfig creates the audit log of a plugin. A log whose settings
+// and store are unchanged is kept with its events. Callers must hold m.mu.
+func (m *Manager) applyAuditConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.Audit == nil {
+		delete(m.audits, name)
+		return
+	}
+
+	previous, ok := m.audits[name]
+	if ok && previous.store == m.auditStore && previous.settings == *cfg.Audit {
+		return
+	}
+	store := m.auditStore
+	if store == nil {
+		store = NewMemoryAuditStore(cfg.Audit.MaxEvents)
+	}
+	m.audits[name] = &auditState{
+		settings: *cfg.Audit,
+		store:    m.auditStore,
+		log:      NewAuditLog(name, store),
+	}
+}
+
+// GetAuditLog retrieves the audit log of a plugin configured with audit
+func (m *Manager) GetAuditLog(name string) (*AuditLog, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.audits[name]
+	if !ok {
+		return nil, false
+	}
+	return state.log, true
+}
+
+// SetAuditStore sets the store of the audit logs of plugins configured with
+// audit, e.g. a database keeping the history across restarts. Nil keeps
+// each plugin's events in a MemoryAuditStore of audit.maxEvents, the
+// default.
+func (m *Manager) SetAuditStore(store AuditStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.auditStore = store
+	for name, cfg := range m.configs {
+		m.applyAuditConfig(name, cfg)
+	}
+}
+
+// auditGetter records the writes to a PluginGetter in an AuditLog. The
+// state before a write is read from the PluginGetter, and the state after
+// it is the one the write returned or, for PATCH, fetched after it.
+type auditGetter struct {
+	next scim.PluginGetter
+	log  *AuditLog
+}
+
+// newAuditGetter wraps next with an audit log
+func newAuditGetter(next scim.PluginGetter, log *AuditLog) scim.PluginGetter {
+	return &auditGetter{next: next, log: log}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *auditGetter) Unwrap() any {
+	return g.next
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *auditGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return g.next.GetUsers(ctx, params)
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *auditGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	password := user.Password != ""
+	created, err := g.next.CreateUser(ctx, user)
+	if err == nil && created != nil {
+		g.log.record(ctx, scim.OperationCreate, "Users", created.ID, nil, created, password)
+	}
+	return created, err
+}
+
+// GetUser implements scim.PluginGetter
+func (g *auditGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return g.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *auditGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := g.ModifyUserResult(ctx, id, patch)
+	return err
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *auditGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	before := g.snapshotUser(ctx, id)
+	modified, err := scim.ModifyUser(ctx, g.next, id, patch)
+	if err == nil {
+		g.log.record(ctx, scim.OperationPatch, "Users", id, before, modified, patchesPassword(patch))
+	}
+	return modified, err
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *auditGetter) DeleteUser(ctx context.Context, id string) error {
+	before := g.snapshotUser(ctx, id)
+	err := g.next.DeleteUser(ctx, id)
+	if err == nil {
+		g.log.record(ctx, scim.OperationDelete, "Users", id, before, nil, false)
+	}
+	return err
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *auditGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	password := user.Password != ""
+	before := g.snapshotUser(ctx, id)
+	replaced, err := scim.ReplaceUser(ctx, g.next, id, user)
+	if err == nil {
+		g.log.record(ctx, scim.OperationReplace, "Users", id, before, replaced, password)
+	}
+	return replaced, err
+}
+
+// snapshotUser fetches a user before a write and copies it, since plugins
+// may return pointers to their storage that the write changes in place.
+// Users that cannot be fetched are recorded as not existing.
+func (g *auditGetter) snapshotUser(ctx context.Context, id string) map[string]any {
+	user, err := g.next.GetUser(ctx, id, nil)
+	if err != nil {
+		return nil
+	}
+	return auditAttributes(user)
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *auditGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return g.next.GetGroups(ctx, params)
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *auditGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	created, err := g.next.CreateGroup(ctx, group)
+	if err == nil && created != nil {
+		g.log.record(ctx, scim.OperationCreate, "Groups", created.ID, nil, created, false)
+	}
+	return created, err
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *auditGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return g.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *auditGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := g.ModifyGroupResult(ctx, id, patch)
+	return err
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *auditGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	before := g.snapshotGroup(ctx, id)
+	modified, err := scim.ModifyGroup(ctx, g.next, id, patch)
+	if err == nil {
+		g.log.record(ctx, scim.OperationPatch, "Groups", id, before, modified, false)
+	}
+	return modified, err
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *auditGetter) DeleteGroup(ctx context.Context, id string) error {
+	before := g.snapshotGroup(ctx, id)
+	err := g.next.DeleteGroup(ctx, id)
+	if err == nil {
+		g.log.record(ctx, scim.OperationDelete, "Groups", id, before, nil, false)
+	}
+	return err
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *auditGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	before := g.snapshotGroup(ctx, id)
+	replaced, err := scim.ReplaceGroup(ctx, g.next, id, group)
+	if err == nil {
+		g.log.record(ctx, scim.OperationReplace, "Groups", id, before, replaced, false)
+	}
+	return replaced, err
+}
+
+// snapshotGroup is the Group counterpart of snapshotUser
+func (g *auditGetter) snapshotGroup(ctx context.Context, id string) map[string]any {
+	group, err := g.next.GetGroup(ctx, id, nil)
+	if err != nil {
+		return nil
+	}
+	return auditAttributes(group)
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+func TestAuditLog(t *testing.T) {
+	manager := NewManager()
+	manager.Register(testutil.NewMemoryPlugin("hr"), &config.PluginConfig{Name: "hr", Audit: &config.AuditConfig{}})
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	log, ok := manager.GetAuditLog("hr")
+	if !ok {
+		t.Fatal("GetAuditLog() found no log")
+	}
+	ctx := scimcontext.WithIdentity(context.Background(), scimcontext.AuthIdentity{Subject: "okta"})
+
+	user, err := getter.CreateUser(ctx, &scim.User{UserName: "alice", Password: "s3cret"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	patch := &scim.PatchOp{
+		Schemas: []string{scim.SchemaPatchOp},
+		Operations: []scim.PatchOperation{
+			{Op: "replace", Path: "displayName", Value: "Alice"},
+			{Op: "replace", Path: "password", Value: "n3w"},
+		},
+	}
+	if err := getter.ModifyUser(ctx, user.ID, patch); err != nil {
+		t.Fatalf("ModifyUser() error = %v", err)
+	}
+	if err := getter.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	events, err := log.History(context.Background(), "Users", user.ID)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("History() = %d events, want 3", len(events))
+	}
+	for i, op := range []string{scim.OperationCreate, scim.OperationPatch, scim.OperationDelete} {
+		if events[i].Operation != op || events[i].Plugin != "hr" || events[i].ResourceType != "Users" {
+			t.Errorf("event %d = %+v, want a %s of hr Users", i, events[i], op)
+		}
+	}
+	if events[0].Subject != "okta" || events[2].Subject != "" {
+		t.Errorf("subjects = %q, %q, want okta and none", events[0].Subject, events[2].Subject)
+	}
+
+	changes := events[1].Changes
+	if len(changes) != 2 {
+		t.Fatalf("patch changes = %+v, want displayName and password", changes)
+	}
+	if changes[0] != (AuditChange{Attribute: "displayName", New: "Alice"}) {
+		t.Errorf("changes[0] = %+v, want displayName set to Alice", changes[0])
+	}
+	if changes[1] != (AuditChange{Attribute: "password"}) {
+		t.Errorf("changes[1] = %+v, want the password recorded without values", changes[1])
+	}
+	for _, change := range events[2].Changes {
+		if change.Attribute == "userName" && (change.Old != "alice" || change.New != nil) {
+			t.Errorf("delete change = %+v, want userName removed", change)
+		}
+	}
+
+	// Requests scoped to a base entity see its events only
+	if events, _ := log.History(scim.WithBaseEntity(context.Background(), "acme"), "Users", user.ID); len(events) != 0 {
+		t.Errorf("History() in another base entity = %d events, want none", len(events))
+	}
+}
+
+func TestMemoryAuditStoreMaxEvents(t *testing.T) {
+	store := NewMemoryAuditStore(2)
+	ctx := context.Background()
+	for _, op := range []string{scim.OperationCreate, scim.OperationPatch, scim.OperationDelete} {
+		store.Append(ctx, &AuditEvent{Plugin: "hr", ResourceType: "Users", ResourceID: "1", Operation: op}) // nolint:errcheck
+	}
+
+	events, _ := store.History(ctx, "hr", "Users", "1")
+	if len(events) != 2 || events[0].Operation != scim.OperationPatch || events[1].Operation != scim.OperationDelete {
+		t.Errorf("History() = %+v, want the latest two events", events)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
 // PerPluginAuthMiddleware creates middleware that applies authentication per plugin
@@ -24,8 +25,14 @@ func PerPluginAuthMiddleware(manager *Manager) func(http.Handler) http.Handler {
 
 			pluginName := parts[0]
 
-			// Get authenticator for this plugin
+			// Get authenticator for this plugin, or for the base entity the
+			// request is routed to if it has its own
 			authenticator, hasAuth := manager.GetAuthenticator(pluginName)
+			if entity := scimcontext.Tenant(r.Context()); entity != "" {
+				if entityAuth, ok := manager.GetBaseEntityAuthenticator(pluginName, entity); ok {
+					authenticator, hasAuth = entityAuth, true
+				}
+			}
 
 			if !hasAuth {
 				// No auth configured for this plugin, allow request
@@ -33,9 +40,29 @@ func PerPluginAuthMiddleware(manager *Manager) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Apply authentication for this plugin
-			authHandler := auth.Middleware(authenticator)(next)
+			// Apply authentication for this plugin and tell plugins who the
+			// client is once it passed
+			identified := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(scimcontext.WithIdentity(r.Context(), identify(manager, pluginName, r))))
+			})
+			authHandler := auth.Middleware(authenticator)(identified)
 			authHandler.ServeHTTP(w, r)
 		})
 	}
 }
+
+// identify describes the client of a request authenticated for pluginName,
+// as the authenticator described it when the request passed auth.Middleware
+func identify(manager *Manager, pluginName string, r *http.Request) scimcontext.AuthIdentity {
+	identity := scimcontext.AuthIdentity{Plugin: pluginName}
+	if cfg, ok := manager.GetConfig(pluginName); ok && cfg.Auth != nil {
+		identity.Method = cfg.Auth.Type
+	}
+	if entity, ok := manager.GetBaseEntity(pluginName, scimcontext.Tenant(r.Context())); ok && entity.Auth != nil {
+		identity.Method = entity.Auth.Type
+	}
+	if result, ok := auth.ResultFromContext(r.Context()); ok {
+		identity.Subject, identity.Scopes, identity.Claims = result.Subject, result.Scopes, result.Claims
+	}
+	return identity
+}
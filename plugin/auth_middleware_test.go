@@ -3,9 +3,12 @@ package plugin
 import (
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 
+	"github.com/marcelom97/scimgateway/auth"
 	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
 func TestPerPluginAuthMiddleware_NoAuth(t *testing.T) {
@@ -471,3 +474,64 @@ func TestPerPluginAuthMiddleware_UnknownPlugin(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestPerPluginAuthMiddleware_Identity(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockPlugin{name: "basic"}, &config.PluginConfig{
+		Name: "basic",
+		Auth: &config.AuthConfig{Type: "basic", Basic: &config.BasicAuth{Username: "okta", Password: "secret"}},
+	})
+	manager.Register(&mockPlugin{name: "bearer"}, &config.PluginConfig{
+		Name: "bearer",
+		Auth: &config.AuthConfig{Type: "bearer", Bearer: &config.BearerAuth{Token: "token"}},
+	})
+	manager.Register(&mockPlugin{name: "custom"}, &config.PluginConfig{
+		Name: "custom",
+		Auth: &config.AuthConfig{Type: "custom", Custom: &config.CustomAuth{Authenticator: principalAuthenticator{}}},
+	})
+	manager.Register(&mockPlugin{name: "public"}, nil)
+
+	var identity scimcontext.AuthIdentity
+	var identified bool
+	handler := PerPluginAuthMiddleware(manager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, identified = scimcontext.Identity(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/basic/Users", nil)
+	req.SetBasicAuth("okta", "secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if want := (scimcontext.AuthIdentity{Plugin: "basic", Method: "basic", Subject: "okta"}); !identified || !reflect.DeepEqual(identity, want) {
+		t.Errorf("basic identity = %+v, %v; want %+v", identity, identified, want)
+	}
+
+	req = httptest.NewRequest("GET", "/bearer/Users", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if want := (scimcontext.AuthIdentity{Plugin: "bearer", Method: "bearer"}); !identified || !reflect.DeepEqual(identity, want) {
+		t.Errorf("bearer identity = %+v, %v; want %+v", identity, identified, want)
+	}
+
+	req = httptest.NewRequest("GET", "/custom/Users", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	want := scimcontext.AuthIdentity{Plugin: "custom", Method: "custom", Subject: "okta", Scopes: []string{"scim.read"}, Claims: map[string]any{"tenant": "acme"}}
+	if !identified || !reflect.DeepEqual(identity, want) {
+		t.Errorf("custom identity = %+v, %v; want %+v", identity, identified, want)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/public/Users", nil))
+	if identified {
+		t.Errorf("public identity = %+v, want none", identity)
+	}
+}
+
+// principalAuthenticator accepts every request as a client with scopes and
+// claims
+type principalAuthenticator struct{}
+
+func (principalAuthenticator) Authenticate(*http.Request) error {
+	return nil
+}
+
+func (principalAuthenticator) AuthenticatePrincipal(*http.Request) (auth.AuthResult, error) {
+	return auth.AuthResult{Subject: "okta", Scopes: []string{"scim.read"}, Claims: map[string]any{"tenant": "acme"}}, nil
+}
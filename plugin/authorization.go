@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// AuthorizationMiddleware restricts the operations of authenticated clients
+// for plugins configured with authorization: a request is served only if
+// one of the client's scopes, or roles of the configured claim, grants its
+// operation, and is rejected with 403 otherwise. Searches are reads, bulk
+// requests need the operations of all their operations, and the history of
+// resources, approval decisions, simulations, recordings, the error budget
+// and the write queue need the admin operation.
+// It is placed behind PerPluginAuthMiddleware, which identifies the client
+// (see scimcontext.Identity). Requests to other plugins are passed on
+// unchanged.
+func AuthorizationMiddleware(manager *Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pluginName, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			cfg, ok := manager.GetConfig(pluginName)
+			if !ok || cfg.Authorization == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			operations, err := requestOperations(r, rest)
+			if err != nil {
+				scim.NewHandler("").WriteSCIMError(w, scim.ErrInvalidSyntax(err.Error()))
+				return
+			}
+			granted := grantedOperations(cfg.Authorization, r)
+			for _, operation := range operations {
+				if !slices.Contains(granted, operation) {
+					scim.NewHandler("").WriteSCIMError(w, scim.ErrOperationNotGranted(operation, pluginName))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// grantedOperations returns the operations granted to the client of r by
+// its scopes, or by its roles if authorization names a claim
+func grantedOperations(authorization *config.AuthorizationConfig, r *http.Request) []string {
+	identity, ok := scimcontext.Identity(r.Context())
+	if !ok {
+		return nil
+	}
+	grants := identity.Scopes
+	if authorization.Claim != "" {
+		grants = claimValues(identity.Claims[authorization.Claim])
+	}
+
+	var granted []string
+	for _, grant := range grants {
+		granted = append(granted, authorization.Grants[grant]...)
+	}
+	return granted
+}
+
+// claimValues returns the values of a claim holding a list of strings or a
+// space-separated string
+func claimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// requestOperations returns the operations a request to the plugin path
+// rest performs. The body of bulk requests is read to find them, and
+// restored for the handler.
+func requestOperations(r *http.Request, rest string) ([]string, error) {
+	if isAdminRequest(r, rest) {
+		return []string{scim.OperationAdmin}, nil
+	}
+	if r.Method != http.MethodPost {
+		return []string{methodOperation(r.Method)}, nil
+	}
+	switch {
+	case rest == ".search" || strings.HasSuffix(rest, "/.search"):
+		return []string{scim.OperationRead}, nil
+	case rest != "Bulk":
+		return []string{scim.OperationCreate}, nil
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var bulk scim.BulkRequest
+	if err := json.Unmarshal(body, &bulk); err != nil {
+		return nil, err
+	}
+	var operations []string
+	for _, op := range bulk.Operations {
+		operations = append(operations, methodOperation(strings.ToUpper(op.Method)))
+	}
+	return operations, nil
+}
+
+// isAdminRequest reports whether a request to the plugin path rest is one
+// to the admin endpoints: the history of a resource, the approval or
+// rejection of a held change, the simulation of a write, the recorded
+// exchanges, the error budget and the write queue, whose flush applies
+// writes outside the write window
+func isAdminRequest(r *http.Request, rest string) bool {
+	if rest == "_recordings" || strings.HasPrefix(rest, "_recordings/") || rest == "_errorBudget" {
+		return true
+	}
+	if rest == "WriteQueue" || rest == "WriteQueue/flush" {
+		return true
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return strings.HasSuffix(rest, "/_history")
+	case http.MethodPost:
+		if rest == "_simulate" {
+			return true
+		}
+		return strings.HasPrefix(rest, "Approvals/") && (strings.HasSuffix(rest, "/approve") || strings.HasSuffix(rest, "/reject"))
+	}
+	return false
+}
+
+// methodOperation returns the operation an HTTP method performs on a
+// resource. Methods performing none, such as OPTIONS, are reads.
+func methodOperation(method string) string {
+	switch method {
+	case http.MethodPost:
+		return scim.OperationCreate
+	case http.MethodPut:
+		return scim.OperationReplace
+	case http.MethodPatch:
+		return scim.OperationPatch
+	case http.MethodDelete:
+		return scim.OperationDelete
+	}
+	return scim.OperationRead
+}
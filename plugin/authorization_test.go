@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	grants := map[string][]string{
+		"scim.read":  {"read"},
+		"scim.write": {"read", "create", "replace", "patch", "delete"},
+		"hr-admin":   {"read", "create"},
+		"auditor":    {"admin"},
+	}
+	manager := NewManager()
+	manager.Register(&mockPlugin{name: "scoped"}, &config.PluginConfig{Name: "scoped", Authorization: &config.AuthorizationConfig{Grants: grants}})
+	manager.Register(&mockPlugin{name: "roles"}, &config.PluginConfig{Name: "roles", Authorization: &config.AuthorizationConfig{Claim: "roles", Grants: grants}})
+	manager.Register(&mockPlugin{name: "open"}, &config.PluginConfig{Name: "open"})
+
+	var body string
+	handler := AuthorizationMiddleware(manager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	bulk := `{"schemas":["` + scim.SchemaBulkRequest + `"],"Operations":[{"method":"POST","path":"/Users"},{"method":"delete","path":"/Users/1"}]}`
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		identity *scimcontext.AuthIdentity
+		want     int
+	}{
+		{"read scope reads", http.MethodGet, "/scoped/Users", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusOK},
+		{"read scope searches", http.MethodPost, "/scoped/Users/.search", "{}", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusOK},
+		{"read scope cannot create", http.MethodPost, "/scoped/Users", "{}", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusForbidden},
+		{"read scope cannot delete", http.MethodDelete, "/scoped/Users/1", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusForbidden},
+		{"write scope deletes", http.MethodDelete, "/scoped/Users/1", "", &scimcontext.AuthIdentity{Scopes: []string{"openid", "scim.write"}}, http.StatusOK},
+		{"unknown scope", http.MethodGet, "/scoped/Users", "", &scimcontext.AuthIdentity{Scopes: []string{"openid"}}, http.StatusForbidden},
+		{"no identity", http.MethodGet, "/scoped/Users", "", nil, http.StatusForbidden},
+		{"scopes ignored for roles", http.MethodGet, "/roles/Users", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusForbidden},
+		{"role list", http.MethodPost, "/roles/Users", "{}", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": []any{"hr-admin"}}}, http.StatusOK},
+		{"role string", http.MethodGet, "/roles/Users", "", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": "staff scim.read"}}, http.StatusOK},
+		{"bulk needs every operation", http.MethodPost, "/roles/Bulk", bulk, &scimcontext.AuthIdentity{Claims: map[string]any{"roles": []any{"hr-admin"}}}, http.StatusForbidden},
+		{"bulk granted", http.MethodPost, "/scoped/Bulk", bulk, &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusOK},
+		{"invalid bulk", http.MethodPost, "/scoped/Bulk", "{", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusBadRequest},
+		{"history needs admin", http.MethodGet, "/scoped/Users/1/_history", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusForbidden},
+		{"admin reads history", http.MethodGet, "/roles/Groups/1/_history", "", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": "auditor"}}, http.StatusOK},
+		{"approval needs admin", http.MethodPost, "/scoped/Approvals/1/approve", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusForbidden},
+		{"admin rejects", http.MethodPost, "/roles/Approvals/1/reject", "", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": "auditor"}}, http.StatusOK},
+		{"simulation needs admin", http.MethodPost, "/scoped/_simulate", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusForbidden},
+		{"recordings need admin", http.MethodGet, "/scoped/_recordings", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusForbidden},
+		{"error budget needs admin", http.MethodGet, "/scoped/_errorBudget", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusForbidden},
+		{"write queue needs admin", http.MethodGet, "/scoped/WriteQueue", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.read"}}, http.StatusForbidden},
+		{"flush needs admin", http.MethodPost, "/scoped/WriteQueue/flush", "", &scimcontext.AuthIdentity{Scopes: []string{"scim.write"}}, http.StatusForbidden},
+		{"admin flushes", http.MethodPost, "/roles/WriteQueue/flush", "", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": "auditor"}}, http.StatusOK},
+		{"admin replays", http.MethodPost, "/roles/_recordings/replay", "", &scimcontext.AuthIdentity{Claims: map[string]any{"roles": "auditor"}}, http.StatusOK},
+		{"plugin without authorization", http.MethodDelete, "/open/Users/1", "", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body = ""
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.identity != nil {
+				req = req.WithContext(scimcontext.WithIdentity(req.Context(), *tt.identity))
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusOK && body != tt.body {
+				t.Errorf("handler read body %q, want %q", body, tt.body)
+			}
+			if tt.want == http.StatusForbidden {
+				var scimErr map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil || scimErr["status"] != "403" {
+					t.Errorf("body = %s, want a SCIM error with status 403", w.Body.String())
+				}
+			}
+		})
+	}
+}
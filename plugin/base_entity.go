@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// BaseEntityMiddleware routes requests to /{plugin}/{baseEntity}/... for the
+// base entities configured for the plugin: the base entity segment is removed
+// from the path, and the base entity and its config are passed on in the
+// context (see scim.BaseEntityFromContext and scimcontext.TenantConfig). It is
+// placed in front of PerPluginAuthMiddleware, which authenticates the request
+// with the base entity's auth. Other requests are passed on unchanged.
+func BaseEntityMiddleware(manager *Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			segment, rest, _ := strings.Cut(rest, "/")
+			pluginName, err := url.PathUnescape(name)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			entityName, err := url.PathUnescape(segment)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			entity, ok := manager.GetBaseEntity(pluginName, entityName)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rawPath := "/" + name + "/" + rest
+			path, err := url.PathUnescape(rawPath)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := scimcontext.WithTenantConfig(scim.WithBaseEntity(r.Context(), entity.Name), entity.Config)
+			r = r.Clone(ctx)
+			r.URL.Path = path
+			r.URL.RawPath = ""
+			if rawPath != r.URL.EscapedPath() {
+				r.URL.RawPath = rawPath
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+func TestBaseEntityMiddleware(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockPlugin{name: "hr"}, &config.PluginConfig{
+		Name: "hr",
+		Auth: &config.AuthConfig{Type: "bearer", Bearer: &config.BearerAuth{Token: "hr-token"}},
+		BaseEntities: []config.BaseEntityConfig{
+			{Name: "acme", Auth: &config.AuthConfig{Type: "bearer", Bearer: &config.BearerAuth{Token: "acme-token"}}, Config: map[string]any{"ou": "ou=acme"}},
+			{Name: "globex"},
+			{Name: "public", Auth: &config.AuthConfig{Type: "none"}},
+		},
+	})
+
+	var path, rawPath, entity string
+	var entityConfig map[string]any
+	var identity scimcontext.AuthIdentity
+	handler := BaseEntityMiddleware(manager)(PerPluginAuthMiddleware(manager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, rawPath = r.URL.Path, r.URL.RawPath
+		entity = scim.BaseEntityFromContext(r.Context())
+		entityConfig = scimcontext.TenantConfig(r.Context())
+		identity, _ = scimcontext.Identity(r.Context())
+	})))
+
+	tests := []struct {
+		name        string
+		target      string
+		token       string
+		wantStatus  int
+		wantPath    string
+		wantRawPath string
+		wantEntity  string
+	}{
+		{"base entity with own auth", "/hr/acme/Users/42", "acme-token", http.StatusOK, "/hr/Users/42", "", "acme"},
+		{"other base entity's token", "/hr/acme/Users", "hr-token", http.StatusUnauthorized, "", "", ""},
+		{"base entity with the plugin's auth", "/hr/globex/Groups", "hr-token", http.StatusOK, "/hr/Groups", "", "globex"},
+		{"base entity without auth", "/hr/public/Users", "", http.StatusOK, "/hr/Users", "", "public"},
+		{"escaped resource ID", "/hr/acme/Users/a%2Fb", "acme-token", http.StatusOK, "/hr/Users/a/b", "/hr/Users/a%2Fb", "acme"},
+		{"unknown base entity", "/hr/initech/Users", "hr-token", http.StatusOK, "/hr/initech/Users", "", ""},
+		{"plugin route", "/hr/Users", "hr-token", http.StatusOK, "/hr/Users", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, rawPath, entity = "", "", ""
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if path != tt.wantPath || rawPath != tt.wantRawPath || entity != tt.wantEntity {
+				t.Errorf("handler saw path %q, raw path %q, base entity %q; want %q, %q, %q",
+					path, rawPath, entity, tt.wantPath, tt.wantRawPath, tt.wantEntity)
+			}
+		})
+	}
+
+	// The base entity's config and auth method are passed on
+	req := httptest.NewRequest(http.MethodGet, "/hr/acme/Users", nil)
+	req.Header.Set("Authorization", "Bearer acme-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if entityConfig["ou"] != "ou=acme" || identity.Plugin != "hr" || identity.Method != "bearer" {
+		t.Errorf("config = %v, identity = %+v", entityConfig, identity)
+	}
+}
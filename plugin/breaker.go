@@ -0,0 +1,391 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Default settings of a CircuitBreaker
+const (
+	DefaultBreakerFailureThreshold = 5
+	DefaultBreakerOpenDuration     = 30 * time.Second
+)
+
+// CircuitState is the state of a CircuitBreaker
+type CircuitState string
+
+// States of a CircuitBreaker
+const (
+	CircuitClosed   CircuitState = "closed"    // requests reach the backend
+	CircuitOpen     CircuitState = "open"      // requests are rejected
+	CircuitHalfOpen CircuitState = "half-open" // one trial request reaches the backend
+)
+
+// BreakerOptions configures a CircuitBreaker. Zero values use the defaults.
+type BreakerOptions struct {
+	// FailureThreshold is the number of consecutive failed requests that
+	// open the circuit
+	FailureThreshold int
+
+	// OpenDuration is how long an open circuit rejects requests before it
+	// lets a trial request through
+	OpenDuration time.Duration
+
+	// Clock tells when the open duration has passed. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// CircuitBreaker stops sending requests to a failing backend. After
+// FailureThreshold consecutive failures the circuit opens and requests are
+// rejected for OpenDuration. Then a single trial request is let through,
+// which closes the circuit when it succeeds and opens it again when it fails.
+//
+// Errors a client caused, such as SCIM errors with a status below 500, count
+// as successes since the backend answered; cancelled requests are not counted
+// at all. CircuitBreaker is safe for concurrent use.
+type CircuitBreaker struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the circuit last opened
+	trial    bool      // a trial request is in flight while half-open
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(opts BreakerOptions) *CircuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultBreakerFailureThreshold
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = DefaultBreakerOpenDuration
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	return &CircuitBreaker{opts: opts, state: CircuitClosed}
+}
+
+// State returns the state of the circuit
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.opts.Clock.Now().Sub(b.openedAt) >= b.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a request may reach the backend. Every allowed
+// request must be followed by a call to Record with its result.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if b.opts.Clock.Now().Sub(b.openedAt) < b.opts.OpenDuration {
+			return false
+		}
+		b.state = CircuitHalfOpen
+	}
+
+	// Half-open: let a single trial request through
+	if b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record records the result of an allowed request
+func (b *CircuitBreaker) Record(err error) {
+	failed := isBackendFailure(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.trial = false
+		if errors.Is(err, context.Canceled) {
+			// The trial told nothing about the backend; let the next one through
+			return
+		}
+		if failed {
+			b.open()
+		} else {
+			b.state = CircuitClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.opts.FailureThreshold {
+		b.open()
+	}
+}
+
+// Trip opens the circuit as if it had reached FailureThreshold, e.g. when
+// the error budget of the plugin is exhausted. A trial request in flight
+// no longer decides the state.
+func (b *CircuitBreaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open()
+	b.trial = false
+}
+
+// open opens the circuit. Callers must hold b.mu.
+func (b *CircuitBreaker) open() {
+	b.state = CircuitOpen
+	b.openedAt = b.opts.Clock.Now()
+	b.failures = 0
+}
+
+// isBackendFailure reports whether err means the backend failed, as opposed
+// to success, a client error or a cancelled request
+func isBackendFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var scimErr *scim.SCIMError
+	if errors.As(err, &scimErr) {
+		return scimErr.Status >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// ResourceBreakers holds independent circuit breakers for the users and the
+// groups of a plugin, so that a failing group store only takes the group
+// routes down while users keep being served
+type ResourceBreakers struct {
+	Users  *CircuitBreaker
+	Groups *CircuitBreaker
+}
+
+// NewResourceBreakers creates closed circuit breakers for users and groups
+func NewResourceBreakers(opts BreakerOptions) *ResourceBreakers {
+	return &ResourceBreakers{
+		Users:  NewCircuitBreaker(opts),
+		Groups: NewCircuitBreaker(opts),
+	}
+}
+
+// breakerGetter guards the user and group operations of a PluginGetter with
+// circuit breakers
+type breakerGetter struct {
+	next     scim.PluginGetter
+	name     string
+	breakers *ResourceBreakers
+}
+
+// newBreakerGetter wraps next with the circuit breakers of the plugin name.
+// The wrapper implements scim.UserStreamer and scim.GroupStreamer when next
+// does, so streamed lists are guarded too.
+func newBreakerGetter(next scim.PluginGetter, name string, breakers *ResourceBreakers) scim.PluginGetter {
+	g := &breakerGetter{next: next, name: name, breakers: breakers}
+	users, streamsUsers := findCapability[scim.UserStreamer](next)
+	groups, streamsGroups := findCapability[scim.GroupStreamer](next)
+	switch {
+	case streamsUsers && streamsGroups:
+		return &breakerStreamer{g, users, groups}
+	case streamsUsers:
+		return &breakerUserStreamer{g, users}
+	case streamsGroups:
+		return &breakerGroupStreamer{g, groups}
+	}
+	return g
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *breakerGetter) Unwrap() any {
+	return g.next
+}
+
+// guard runs op if the breaker allows it and records its result
+func guard[T any](g *breakerGetter, b *CircuitBreaker, endpoint string, op func() (T, error)) (T, error) {
+	if !b.Allow() {
+		var zero T
+		return zero, scim.NewSCIMErrorf(http.StatusServiceUnavailable, "",
+			"%s of plugin '%s' are temporarily unavailable", endpoint, g.name)
+	}
+	result, err := op()
+	b.Record(err)
+	return result, err
+}
+
+// guardErr is guard for operations without a result
+func guardErr(g *breakerGetter, b *CircuitBreaker, endpoint string, op func() error) error {
+	_, err := guard(g, b, endpoint, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *breakerGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return guard(g, g.breakers.Users, "Users", func() (*scim.ListResponse[*scim.User], error) {
+		return g.next.GetUsers(ctx, params)
+	})
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *breakerGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return guard(g, g.breakers.Users, "Users", func() (*scim.User, error) {
+		return g.next.CreateUser(ctx, user)
+	})
+}
+
+// GetUser implements scim.PluginGetter
+func (g *breakerGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return guard(g, g.breakers.Users, "Users", func() (*scim.User, error) {
+		return g.next.GetUser(ctx, id, attributes)
+	})
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *breakerGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return guardErr(g, g.breakers.Users, "Users", func() error {
+		return g.next.ModifyUser(ctx, id, patch)
+	})
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *breakerGetter) DeleteUser(ctx context.Context, id string) error {
+	return guardErr(g, g.breakers.Users, "Users", func() error {
+		return g.next.DeleteUser(ctx, id)
+	})
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *breakerGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	return guard(g, g.breakers.Users, "Users", func() (*scim.User, error) {
+		return scim.ReplaceUser(ctx, g.next, id, user)
+	})
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *breakerGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	return guard(g, g.breakers.Users, "Users", func() (*scim.User, error) {
+		return scim.ModifyUser(ctx, g.next, id, patch)
+	})
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *breakerGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return guard(g, g.breakers.Groups, "Groups", func() (*scim.ListResponse[*scim.Group], error) {
+		return g.next.GetGroups(ctx, params)
+	})
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *breakerGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return guard(g, g.breakers.Groups, "Groups", func() (*scim.Group, error) {
+		return g.next.CreateGroup(ctx, group)
+	})
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *breakerGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return guard(g, g.breakers.Groups, "Groups", func() (*scim.Group, error) {
+		return g.next.GetGroup(ctx, id, attributes)
+	})
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *breakerGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return guardErr(g, g.breakers.Groups, "Groups", func() error {
+		return g.next.ModifyGroup(ctx, id, patch)
+	})
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *breakerGetter) DeleteGroup(ctx context.Context, id string) error {
+	return guardErr(g, g.breakers.Groups, "Groups", func() error {
+		return g.next.DeleteGroup(ctx, id)
+	})
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *breakerGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	return guard(g, g.breakers.Groups, "Groups", func() (*scim.Group, error) {
+		return scim.ReplaceGroup(ctx, g.next, id, group)
+	})
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *breakerGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	return guard(g, g.breakers.Groups, "Groups", func() (*scim.Group, error) {
+		return scim.ModifyGroup(ctx, g.next, id, patch)
+	})
+}
+
+// streamUsers guards a StreamUsers call with the users breaker
+func (g *breakerGetter) streamUsers(ctx context.Context, s scim.UserStreamer, params scim.QueryParams, yield func(*scim.User) error) error {
+	return guardErr(g, g.breakers.Users, "Users", func() error {
+		return s.StreamUsers(ctx, params, yield)
+	})
+}
+
+// streamGroups guards a StreamGroups call with the groups breaker
+func (g *breakerGetter) streamGroups(ctx context.Context, s scim.GroupStreamer, params scim.QueryParams, yield func(*scim.Group) error) error {
+	return guardErr(g, g.breakers.Groups, "Groups", func() error {
+		return s.StreamGroups(ctx, params, yield)
+	})
+}
+
+// breakerUserStreamer is a breakerGetter for plugins streaming users
+type breakerUserStreamer struct {
+	*breakerGetter
+	users scim.UserStreamer
+}
+
+// StreamUsers implements scim.UserStreamer
+func (g *breakerUserStreamer) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	return g.streamUsers(ctx, g.users, params, yield)
+}
+
+// breakerGroupStreamer is a breakerGetter for plugins streaming groups
+type breakerGroupStreamer struct {
+	*breakerGetter
+	groups scim.GroupStreamer
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (g *breakerGroupStreamer) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	return g.streamGroups(ctx, g.groups, params, yield)
+}
+
+// breakerStreamer is a breakerGetter for plugins streaming users and groups
+type breakerStreamer struct {
+	*breakerGetter
+	users  scim.UserStreamer
+	groups scim.GroupStreamer
+}
+
+// StreamUsers implements scim.UserStreamer
+func (g *breakerStreamer) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	return g.streamUsers(ctx, g.users, params, yield)
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (g *breakerStreamer) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	return g.streamGroups(ctx, g.groups, params, yield)
+}
@@ -0,0 +1,171 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewCircuitBreaker(BreakerOptions{FailureThreshold: 2, OpenDuration: time.Minute, Clock: clk})
+	down := errors.New("connection refused")
+
+	call := func(err error) bool {
+		if !b.Allow() {
+			return false
+		}
+		b.Record(err)
+		return true
+	}
+
+	// A success resets the count of consecutive failures
+	call(down)
+	call(nil)
+	call(down)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State() = %s, want closed", got)
+	}
+
+	// Client errors are answers of a working backend and cancelled requests
+	// are not counted
+	call(scim.ErrNotFound("User", "42"))
+	call(down)
+	call(context.Canceled)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("State() = %s after client errors, want closed", got)
+	}
+
+	call(scim.ErrInternalServer("database is locked"))
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State() = %s, want open", got)
+	}
+	if call(nil) {
+		t.Error("open circuit allowed a request")
+	}
+
+	// After the open duration a single trial request is let through
+	clk.Advance(time.Minute)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("State() = %s, want half-open", got)
+	}
+	if !b.Allow() {
+		t.Fatal("half-open circuit rejected the trial request")
+	}
+	if b.Allow() {
+		t.Error("half-open circuit allowed a second request")
+	}
+	b.Record(down)
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("State() = %s after a failed trial, want open", got)
+	}
+
+	clk.Advance(time.Minute)
+	if !call(nil) {
+		t.Fatal("half-open circuit rejected the trial request")
+	}
+	if got := b.State(); got != CircuitClosed {
+		t.Errorf("State() = %s after a successful trial, want closed", got)
+	}
+}
+
+// splitPlugin serves users but fails on groups, like a plugin whose group
+// store runs on a separate backend
+type splitPlugin struct {
+	mockPlugin
+	groupErr error
+	groups   int // group calls that reached the backend
+}
+
+func (p *splitPlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	p.groups++
+	if p.groupErr != nil {
+		return nil, p.groupErr
+	}
+	return []*scim.Group{}, nil
+}
+
+func TestAdaptedManagerBreakers(t *testing.T) {
+	p := &splitPlugin{mockPlugin: mockPlugin{name: "split"}, groupErr: errors.New("connection refused")}
+	manager := NewManager()
+	manager.Register(p, &config.PluginConfig{Name: "split", CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 2}})
+	getter, _ := NewAdaptedManager(manager).Get("split")
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := getter.GetGroups(ctx, scim.QueryParams{}); !errors.Is(err, p.groupErr) {
+			t.Fatalf("GetGroups() error = %v, want the backend error", err)
+		}
+	}
+
+	_, err := getter.GetGroups(ctx, scim.QueryParams{})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("GetGroups() error = %v, want 503", err)
+	}
+	if p.groups != 2 {
+		t.Errorf("groups backend called %d times, want 2", p.groups)
+	}
+
+	// Users are served by their own breaker
+	if _, err := getter.GetUsers(ctx, scim.QueryParams{}); err != nil {
+		t.Errorf("GetUsers() error = %v", err)
+	}
+	if _, err := getter.GetUser(ctx, "1", nil); err != nil {
+		t.Errorf("GetUser() error = %v", err)
+	}
+
+	// Reloading unchanged settings keeps the circuit open
+	manager.UpdateConfig("split", &config.PluginConfig{Name: "split", CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 2}})
+	breakers, _ := manager.GetBreakers("split")
+	if got := breakers.Groups.State(); got != CircuitOpen {
+		t.Errorf("groups State() after reload = %s, want open", got)
+	}
+	if got := breakers.Users.State(); got != CircuitClosed {
+		t.Errorf("users State() = %s, want closed", got)
+	}
+
+	// Disabling circuitBreaker removes the breakers
+	manager.UpdateConfig("split", &config.PluginConfig{Name: "split"})
+	if _, ok := manager.GetBreakers("split"); ok {
+		t.Error("GetBreakers() found breakers after they were disabled")
+	}
+}
+
+// streamingPlugin streams users
+type streamingPlugin struct {
+	mockPlugin
+}
+
+func (p *streamingPlugin) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	return errors.New("stream broken")
+}
+
+func TestBreakerGetterStreams(t *testing.T) {
+	breakers := NewResourceBreakers(BreakerOptions{FailureThreshold: 1})
+
+	getter := newBreakerGetter(NewAdapter(&streamingPlugin{mockPlugin{name: "stream"}}), "stream", breakers)
+	streamer, ok := getter.(scim.UserStreamer)
+	if !ok {
+		t.Fatal("wrapper of a user streamer does not stream users")
+	}
+	if _, ok := getter.(scim.GroupStreamer); ok {
+		t.Error("wrapper streams groups the plugin does not stream")
+	}
+
+	_ = streamer.StreamUsers(context.Background(), scim.QueryParams{}, func(*scim.User) error { return nil })
+	if got := breakers.Users.State(); got != CircuitOpen {
+		t.Errorf("users State() = %s after a failed stream, want open", got)
+	}
+
+	plain := newBreakerGetter(NewAdapter(&mockPlugin{name: "plain"}), "plain", breakers)
+	if _, ok := plain.(scim.UserStreamer); ok {
+		t.Error("wrapper of a plain plugin streams users")
+	}
+}
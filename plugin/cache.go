@@ -0,0 +1,449 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Default settings of a Cache
+const (
+	DefaultCacheTTL        = time.Minute
+	DefaultCacheMaxEntries = 10000
+)
+
+// CacheStore stores the encoded users, groups and lists of a Cache.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the value of key, if it is stored and not expired
+	Get(ctx context.Context, key string) ([]byte, bool)
+
+	// Set stores value under key for ttl. Values with a ttl of zero do not
+	// expire.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration)
+
+	// Delete removes key, if it is stored
+	Delete(ctx context.Context, key string)
+}
+
+// MemoryCacheStore is a CacheStore keeping values in memory, the default
+type MemoryCacheStore struct {
+	maxEntries int
+	clock      clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+// memoryCacheEntry is a value of a MemoryCacheStore
+type memoryCacheEntry struct {
+	value   []byte
+	expires time.Time // zero for values that do not expire
+}
+
+// NewMemoryCacheStore creates an empty in-memory cache store holding at
+// most maxEntries values, or DefaultCacheMaxEntries if maxEntries is not
+// positive. Expiry is told by c; nil uses clock.System.
+func NewMemoryCacheStore(maxEntries int, c clock.Clock) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if c == nil {
+		c = clock.System
+	}
+	return &MemoryCacheStore{maxEntries: maxEntries, clock: c, entries: make(map[string]memoryCacheEntry)}
+}
+
+// Get implements CacheStore
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expires.IsZero() && !s.clock.Now().Before(entry.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set implements CacheStore. When the store is full, expired values are
+// swept, and all values are dropped if none expired.
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		for k, entry := range s.entries {
+			if !entry.expires.IsZero() && !now.Before(entry.expires) {
+				delete(s.entries, k)
+			}
+		}
+		if len(s.entries) >= s.maxEntries {
+			clear(s.entries)
+		}
+	}
+
+	entry := memoryCacheEntry{value: value}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	s.entries[key] = entry
+}
+
+// Delete implements CacheStore
+func (s *MemoryCacheStore) Delete(ctx context.Context, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// Len returns the number of values stored, including expired ones not yet
+// swept
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Generations of a Cache. Writes replace the generation of what they may
+// change with a random value, so the entries and lists keyed by the previous
+// one are no longer found and expire.
+const (
+	genUsers        = "Users"        // changed by every user write, for user lists
+	genGroups       = "Groups"       // changed by every group write, for users and group lists
+	genDeletedUsers = "DeletedUsers" // changed by user deletes, which remove members of groups
+)
+
+// Cache serves the users and groups a plugin returned from a CacheStore
+// until their TTL expires, sparing slow backends such as LDAP directories
+// or remote APIs the reads repeated by clients and by the precondition checks
+// of PUT, PATCH and DELETE requests.
+//
+// Writes through the cache invalidate what they may change: the written
+// resource and the lists of its resource type, cached users on group writes
+// (as users list their groups) and cached groups on user deletes (as the
+// deleted users may have been members). Entries are kept per base entity.
+// Invalidation state lives in the store, so gateway instances sharing a
+// store see each other's writes. Writes bypassing the gateway are seen once
+// the TTL expires.
+type Cache struct {
+	name  string
+	store CacheStore
+	ttl   time.Duration
+}
+
+// NewCache creates the cache of the plugin name in store, keeping entries
+// for ttl, or DefaultCacheTTL if ttl is not positive
+func NewCache(name string, store CacheStore, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &Cache{name: name, store: store, ttl: ttl}
+}
+
+// key returns the store key of the parts, scoped to the plugin and the
+// request's base entity
+func (c *Cache) key(ctx context.Context, parts ...string) string {
+	key := c.name + "/" + scim.BaseEntityFromContext(ctx)
+	for _, part := range parts {
+		key += "/" + part
+	}
+	return key
+}
+
+// generation returns the current value of a generation, creating it if the
+// store has none
+func (c *Cache) generation(ctx context.Context, name string) string {
+	key := c.key(ctx, "generation", name)
+	if gen, ok := c.store.Get(ctx, key); ok {
+		return string(gen)
+	}
+	return c.advance(ctx, name)
+}
+
+// advance replaces the value of the generations, invalidating the entries
+// keyed by them, and returns the last new value
+func (c *Cache) advance(ctx context.Context, names ...string) string {
+	var gen string
+	for _, name := range names {
+		gen = uuid.NewString()
+		c.store.Set(ctx, c.key(ctx, "generation", name), []byte(gen), 0)
+	}
+	return gen
+}
+
+// userKey, groupKey, usersKey and groupsKey return the keys of a user, a
+// group and the lists of users and groups with params
+func (c *Cache) userKey(ctx context.Context, id string) string {
+	return c.key(ctx, "Users", id, c.generation(ctx, genGroups))
+}
+
+func (c *Cache) groupKey(ctx context.Context, id string) string {
+	return c.key(ctx, "Groups", id, c.generation(ctx, genDeletedUsers))
+}
+
+func (c *Cache) usersKey(ctx context.Context, params scim.QueryParams) (string, bool) {
+	query, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	return c.key(ctx, "Users?"+string(query), c.generation(ctx, genUsers), c.generation(ctx, genGroups)), true
+}
+
+func (c *Cache) groupsKey(ctx context.Context, params scim.QueryParams) (string, bool) {
+	query, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	return c.key(ctx, "Groups?"+string(query), c.generation(ctx, genGroups), c.generation(ctx, genDeletedUsers)), true
+}
+
+// load decodes the value of key into v, reporting whether it was cached
+func (c *Cache) load(ctx context.Context, key string, v any) bool {
+	data, ok := c.store.Get(ctx, key)
+	return ok && json.Unmarshal(data, v) == nil
+}
+
+// save encodes v and stores it under key
+func (c *Cache) save(ctx context.Context, key string, v any) {
+	if data, err := json.Marshal(v); err == nil {
+		c.store.Set(ctx, key, data, c.ttl)
+	}
+}
+
+// WarmUsers implements Warmer, caching users listed by Warm as GetUser
+// would
+func (c *Cache) WarmUsers(ctx context.Context, users []*scim.User) {
+	for _, user := range users {
+		c.save(ctx, c.userKey(ctx, user.ID), user)
+	}
+}
+
+// WarmGroups implements Warmer, caching groups listed by Warm as GetGroup
+// would
+func (c *Cache) WarmGroups(ctx context.Context, groups []*scim.Group) {
+	for _, group := range groups {
+		c.save(ctx, c.groupKey(ctx, group.ID), group)
+	}
+}
+
+// cacheState holds the cache of a plugin with the settings it was created
+// with
+type cacheState struct {
+	settings config.CacheConfig
+	clock    clock.Clock
+	store    CacheStore // the shared store, nil for the plugin's memory store
+	cache    *Cache
+}
+
+// applyCacheConfig creates the cache of a plugin. A cache whose settings,
+// clock and store are unchanged is kept with its entries. Callers must hold
+// m.mu.
+func (m *Manager) applyCacheConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.Cache == nil {
+		delete(m.caches, name)
+		return
+	}
+
+	previous, ok := m.caches[name]
+	if ok && previous.clock == m.clock && previous.store == m.cacheStore && previous.settings == *cfg.Cache {
+		return
+	}
+	store := m.cacheStore
+	if store == nil {
+		store = NewMemoryCacheStore(cfg.Cache.MaxEntries, m.clock)
+	}
+	m.caches[name] = &cacheState{
+		settings: *cfg.Cache,
+		clock:    m.clock,
+		store:    m.cacheStore,
+		cache:    NewCache(name, store, cfg.Cache.TTL),
+	}
+}
+
+// GetCache retrieves the cache of a plugin configured with cache
+func (m *Manager) GetCache(name string) (*Cache, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.caches[name]
+	if !ok {
+		return nil, false
+	}
+	return state.cache, true
+}
+
+// SetCacheStore sets the store of the caches of plugins configured with
+// cache, e.g. one shared by several gateway instances. Keys are prefixed
+// with the plugin name. Nil keeps each plugin's entries in a
+// MemoryCacheStore of cache.maxEntries, the default.
+func (m *Manager) SetCacheStore(store CacheStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cacheStore = store
+	for name, cfg := range m.configs {
+		m.applyCacheConfig(name, cfg)
+	}
+}
+
+// cacheGetter serves the reads of a PluginGetter from a Cache and
+// invalidates it on writes. Streaming and other capabilities are found by
+// unwrapping it and are not cached.
+type cacheGetter struct {
+	next  scim.PluginGetter
+	cache *Cache
+}
+
+// newCacheGetter wraps next with a cache
+func newCacheGetter(next scim.PluginGetter, cache *Cache) scim.PluginGetter {
+	return &cacheGetter{next: next, cache: cache}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *cacheGetter) Unwrap() any {
+	return g.next
+}
+
+// cached returns the value of key if it is cached, and otherwise fetches
+// and caches it. Values are decoded from the store on every hit, so callers
+// may modify them. Reads in a transaction see its uncommitted writes, so
+// they are fetched and not cached.
+func cached[T any](ctx context.Context, g *cacheGetter, key string, store bool, fetch func() (T, error)) (T, error) {
+	if scim.InTransaction(ctx) {
+		return fetch()
+	}
+	var value T
+	if g.cache.load(ctx, key, &value) {
+		return value, nil
+	}
+	value, err := fetch()
+	if err == nil && store {
+		g.cache.save(ctx, key, value)
+	}
+	return value, err
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *cacheGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	key, ok := g.cache.usersKey(ctx, params)
+	if !ok {
+		return g.next.GetUsers(ctx, params)
+	}
+	return cached(ctx, g, key, true, func() (*scim.ListResponse[*scim.User], error) {
+		return g.next.GetUsers(ctx, params)
+	})
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *cacheGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	defer g.cache.advance(ctx, genUsers)
+	return g.next.CreateUser(ctx, user)
+}
+
+// GetUser implements scim.PluginGetter. Users are cached whole: requests
+// for some attributes are served from the cached user, as the server
+// selects the attributes returned, and fetch the user without caching it
+// otherwise.
+func (g *cacheGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return cached(ctx, g, g.cache.userKey(ctx, id), len(attributes) == 0, func() (*scim.User, error) {
+		return g.next.GetUser(ctx, id, attributes)
+	})
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *cacheGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	defer g.invalidateUser(ctx, id, genUsers)
+	return g.next.ModifyUser(ctx, id, patch)
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *cacheGetter) DeleteUser(ctx context.Context, id string) error {
+	defer g.invalidateUser(ctx, id, genUsers, genDeletedUsers)
+	return g.next.DeleteUser(ctx, id)
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *cacheGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	defer g.invalidateUser(ctx, id, genUsers)
+	return scim.ReplaceUser(ctx, g.next, id, user)
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *cacheGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	defer g.invalidateUser(ctx, id, genUsers)
+	return scim.ModifyUser(ctx, g.next, id, patch)
+}
+
+// invalidateUser removes a written user from the cache and advances the
+// generations the write changed
+func (g *cacheGetter) invalidateUser(ctx context.Context, id string, generations ...string) {
+	g.cache.store.Delete(ctx, g.cache.userKey(ctx, id))
+	g.cache.advance(ctx, generations...)
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *cacheGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	key, ok := g.cache.groupsKey(ctx, params)
+	if !ok {
+		return g.next.GetGroups(ctx, params)
+	}
+	return cached(ctx, g, key, true, func() (*scim.ListResponse[*scim.Group], error) {
+		return g.next.GetGroups(ctx, params)
+	})
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *cacheGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	defer g.cache.advance(ctx, genGroups)
+	return g.next.CreateGroup(ctx, group)
+}
+
+// GetGroup implements scim.PluginGetter. Groups are cached whole, as users
+// are by GetUser.
+func (g *cacheGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return cached(ctx, g, g.cache.groupKey(ctx, id), len(attributes) == 0, func() (*scim.Group, error) {
+		return g.next.GetGroup(ctx, id, attributes)
+	})
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *cacheGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	defer g.invalidateGroup(ctx, id)
+	return g.next.ModifyGroup(ctx, id, patch)
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *cacheGetter) DeleteGroup(ctx context.Context, id string) error {
+	defer g.invalidateGroup(ctx, id)
+	return g.next.DeleteGroup(ctx, id)
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *cacheGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	defer g.invalidateGroup(ctx, id)
+	return scim.ReplaceGroup(ctx, g.next, id, group)
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *cacheGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	defer g.invalidateGroup(ctx, id)
+	return scim.ModifyGroup(ctx, g.next, id, patch)
+}
+
+// invalidateGroup removes a written group from the cache and advances the
+// group generation, invalidating the group lists and the cached users
+func (g *cacheGetter) invalidateGroup(ctx context.Context, id string) {
+	g.cache.store.Delete(ctx, g.cache.groupKey(ctx, id))
+	g.cache.advance(ctx, genGroups)
+}
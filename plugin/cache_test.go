@@ -0,0 +1,189 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// countingPlugin counts the reads that reached it
+type countingPlugin struct {
+	mockPlugin
+	userReads, userLists, groupReads int
+}
+
+func (p *countingPlugin) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	p.userReads++
+	if id == "missing" {
+		return nil, scim.ErrNotFound("User", id)
+	}
+	return &scim.User{ID: id, UserName: "alice"}, nil
+}
+
+func (p *countingPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	p.userLists++
+	return []*scim.User{{ID: "1", UserName: "alice"}}, nil
+}
+
+func (p *countingPlugin) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	p.groupReads++
+	return &scim.Group{ID: id, DisplayName: "Admins"}, nil
+}
+
+func TestCache(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &countingPlugin{mockPlugin: mockPlugin{name: "ldap"}}
+	manager := NewManager()
+	manager.SetClock(clk, 0)
+	manager.Register(p, &config.PluginConfig{Name: "ldap", Cache: &config.CacheConfig{TTL: time.Minute}})
+	getter, _ := NewAdaptedManager(manager).Get("ldap")
+	ctx := context.Background()
+
+	getUser := func(ctx context.Context, wantReads int) {
+		t.Helper()
+		user, err := getter.GetUser(ctx, "1", nil)
+		if err != nil || user.UserName != "alice" {
+			t.Fatalf("GetUser() = %+v, %v", user, err)
+		}
+		user.UserName = "modified by the caller"
+		if p.userReads != wantReads {
+			t.Errorf("backend reads = %d, want %d", p.userReads, wantReads)
+		}
+	}
+
+	getUser(ctx, 1)
+	getUser(ctx, 1)
+
+	// Requests for some attributes are served from the cached user
+	if _, err := getter.GetUser(ctx, "1", []string{"userName"}); err != nil || p.userReads != 1 {
+		t.Errorf("GetUser() with attributes = %v, backend reads %d, want 1", err, p.userReads)
+	}
+
+	// Errors are not cached
+	for range 2 {
+		if _, err := getter.GetUser(ctx, "missing", nil); err == nil {
+			t.Fatal("GetUser() of a missing user succeeded")
+		}
+	}
+	if p.userReads != 3 {
+		t.Errorf("backend reads = %d, want missing users read every time", p.userReads)
+	}
+
+	// Writes invalidate the user, group writes all users
+	if err := getter.ModifyUser(ctx, "1", &scim.PatchOp{}); err != nil {
+		t.Fatal(err)
+	}
+	getUser(ctx, 4)
+	if err := getter.ModifyGroup(ctx, "admins", &scim.PatchOp{}); err != nil {
+		t.Fatal(err)
+	}
+	getUser(ctx, 5)
+	getUser(ctx, 5)
+
+	// Base entities are cached apart
+	getUser(scim.WithBaseEntity(ctx, "acme"), 6)
+
+	// Entries expire
+	clk.Advance(time.Minute)
+	getUser(ctx, 7)
+
+	// Lists are invalidated by creates
+	for range 2 {
+		if _, err := getter.GetUsers(ctx, scim.QueryParams{Filter: `userName eq "alice"`}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.userLists != 1 {
+		t.Errorf("backend lists = %d, want 1", p.userLists)
+	}
+	if _, err := getter.CreateUser(ctx, &scim.User{UserName: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getter.GetUsers(ctx, scim.QueryParams{Filter: `userName eq "alice"`}); err != nil || p.userLists != 2 {
+		t.Errorf("GetUsers() after create = %v, backend lists %d, want 2", err, p.userLists)
+	}
+
+	// Deleted users invalidate the groups they were members of
+	for range 2 {
+		if _, err := getter.GetGroup(ctx, "admins", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := getter.DeleteUser(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getter.GetGroup(ctx, "admins", nil); err != nil || p.groupReads != 2 {
+		t.Errorf("GetGroup() after user delete = %v, backend reads %d, want 2", err, p.groupReads)
+	}
+
+	// Reads in a transaction may see writes that are rolled back later
+	getUser(ctx, 8)
+	getUser(scim.WithTransaction(ctx), 9)
+	getUser(scim.WithTransaction(ctx), 10)
+	getUser(ctx, 10)
+
+	// Disabling cache reads from the plugin again
+	manager.UpdateConfig("ldap", &config.PluginConfig{Name: "ldap"})
+	getter, _ = NewAdaptedManager(manager).Get("ldap")
+	getUser(ctx, 11)
+	getUser(ctx, 12)
+}
+
+func TestCacheWarm(t *testing.T) {
+	p := &countingPlugin{mockPlugin: mockPlugin{name: "ldap"}}
+	manager := NewManager()
+	manager.Register(p, &config.PluginConfig{Name: "ldap", Cache: &config.CacheConfig{TTL: time.Minute}})
+	getter, _ := NewAdaptedManager(manager).Get("ldap")
+	cache, _ := manager.GetCache("ldap")
+	ctx := context.Background()
+
+	result, err := Warm(ctx, p, WarmOptions{Cache: cache})
+	if err != nil || result.Users != 1 {
+		t.Fatalf("Warm() = %+v, %v", result, err)
+	}
+	if user, err := getter.GetUser(ctx, "1", nil); err != nil || user.UserName != "alice" {
+		t.Fatalf("GetUser() = %+v, %v", user, err)
+	}
+	if p.userReads != 0 {
+		t.Errorf("backend reads = %d, want warmed users served from the cache", p.userReads)
+	}
+}
+
+func TestMemoryCacheStore(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	store := NewMemoryCacheStore(2, clk)
+	ctx := context.Background()
+
+	store.Set(ctx, "a", []byte("1"), time.Second)
+	store.Set(ctx, "generation", []byte("g"), 0)
+	clk.Advance(time.Second)
+	if _, ok := store.Get(ctx, "a"); ok {
+		t.Error("Get() returned an expired value")
+	}
+	if v, ok := store.Get(ctx, "generation"); !ok || string(v) != "g" {
+		t.Errorf("Get() = %q, %v, want the value without expiry", v, ok)
+	}
+
+	// A full store sweeps expired values first
+	store.Set(ctx, "b", []byte("2"), time.Second)
+	clk.Advance(time.Second)
+	store.Set(ctx, "c", []byte("3"), time.Second)
+	if _, ok := store.Get(ctx, "generation"); !ok || store.Len() != 2 {
+		t.Errorf("Len() = %d, want the expired value swept", store.Len())
+	}
+
+	// and is emptied if none expired
+	store.Set(ctx, "d", []byte("4"), time.Second)
+	if _, ok := store.Get(ctx, "d"); !ok || store.Len() != 1 {
+		t.Errorf("Len() = %d, want only the new value", store.Len())
+	}
+
+	store.Delete(ctx, "d")
+	if _, ok := store.Get(ctx, "d"); ok {
+		t.Error("Get() returned a deleted value")
+	}
+}
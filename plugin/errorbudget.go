@@ -0,0 +1,404 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Default settings of an ErrorBudget
+const (
+	DefaultErrorBudgetObjective   = 99.5
+	DefaultErrorBudgetWindow      = time.Hour
+	DefaultErrorBudgetMinRequests = 100
+)
+
+// errorBudgetBuckets is the number of buckets the window of an ErrorBudget
+// is divided into. Calls leave the window one bucket at a time.
+const errorBudgetBuckets = 60
+
+// ErrorBudgetOptions configures an ErrorBudget. Zero values use the
+// defaults.
+type ErrorBudgetOptions struct {
+	// Objective is the percentage of calls that should succeed
+	Objective float64
+
+	// Window is the rolling period calls are counted over
+	Window time.Duration
+
+	// MinRequests is the number of calls in the window below which the
+	// budget is not considered exhausted, so that a few early failures do
+	// not exhaust it
+	MinRequests int
+
+	// Clock tells which calls are in the window. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// ErrorBudgetStatus is the state of an ErrorBudget over its window
+type ErrorBudgetStatus struct {
+	Objective float64       `json:"objective"`
+	Window    time.Duration `json:"-"`
+	Requests  int           `json:"requests"`
+	Failures  int           `json:"failures"`
+
+	// SuccessRate is the percentage of calls that succeeded, 100 without
+	// calls
+	SuccessRate float64 `json:"successRate"`
+
+	// Remaining is the share of the failures the objective allows that is
+	// left: 1 without failures, 0 when they are used up and negative beyond
+	Remaining float64 `json:"remaining"`
+
+	// Exhausted reports whether the budget is used up over at least
+	// MinRequests calls
+	Exhausted bool `json:"exhausted"`
+}
+
+// ErrorBudget counts the successful and failed calls to a backend over a
+// rolling window, and tells how much of the failures an objective such as
+// 99.5% allows are left. Failures are those a CircuitBreaker counts: errors
+// a client caused count as successes and cancelled calls are not counted.
+// ErrorBudget is safe for concurrent use.
+type ErrorBudget struct {
+	opts  ErrorBudgetOptions
+	width time.Duration // of a bucket
+
+	mu      sync.Mutex
+	buckets [errorBudgetBuckets]budgetBucket
+}
+
+// budgetBucket counts the calls of a slice of the window
+type budgetBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// NewErrorBudget creates an error budget without calls
+func NewErrorBudget(opts ErrorBudgetOptions) *ErrorBudget {
+	if opts.Objective <= 0 {
+		opts.Objective = DefaultErrorBudgetObjective
+	}
+	if opts.Window <= 0 {
+		opts.Window = DefaultErrorBudgetWindow
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = DefaultErrorBudgetMinRequests
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	return &ErrorBudget{opts: opts, width: max(opts.Window/errorBudgetBuckets, time.Nanosecond)}
+}
+
+// Record records the result of a call, and reports whether the budget is
+// exhausted after a failure
+func (b *ErrorBudget) Record(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	failed := isBackendFailure(err)
+
+	b.mu.Lock()
+	now := b.opts.Clock.Now()
+	start := now.Truncate(b.width)
+	bucket := &b.buckets[int(start.UnixNano()/int64(b.width)%errorBudgetBuckets)]
+	if !bucket.start.Equal(start) {
+		*bucket = budgetBucket{start: start}
+	}
+	bucket.requests++
+	if failed {
+		bucket.failures++
+	}
+	b.mu.Unlock()
+
+	return failed && b.Status().Exhausted
+}
+
+// Status returns the state of the budget over the window ending now
+func (b *ErrorBudget) Status() ErrorBudgetStatus {
+	status := ErrorBudgetStatus{Objective: b.opts.Objective, Window: b.opts.Window}
+
+	b.mu.Lock()
+	since := b.opts.Clock.Now().Add(-b.opts.Window)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(since) {
+			status.Requests += bucket.requests
+			status.Failures += bucket.failures
+		}
+	}
+	b.mu.Unlock()
+
+	status.SuccessRate, status.Remaining = 100, 1
+	if status.Requests > 0 {
+		status.SuccessRate = 100 * float64(status.Requests-status.Failures) / float64(status.Requests)
+		allowed := float64(status.Requests) * (100 - b.opts.Objective) / 100
+		status.Remaining = 1 - float64(status.Failures)/allowed
+	}
+	status.Exhausted = status.Requests >= b.opts.MinRequests && status.Remaining <= 0
+	return status
+}
+
+// budgetState holds the error budget of a plugin with the settings it was
+// created with
+type budgetState struct {
+	settings config.ErrorBudgetConfig
+	clock    clock.Clock
+	budget   *ErrorBudget
+}
+
+// applyErrorBudgetConfig creates the error budget of a plugin. A budget
+// whose settings did not change is kept, so reloading the configuration
+// does not forget the calls counted. Callers must hold m.mu.
+func (m *Manager) applyErrorBudgetConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.ErrorBudget == nil {
+		delete(m.budgets, name)
+		return
+	}
+	if state, ok := m.budgets[name]; ok && state.settings == *cfg.ErrorBudget && state.clock == m.clock {
+		return
+	}
+	m.budgets[name] = &budgetState{
+		settings: *cfg.ErrorBudget,
+		clock:    m.clock,
+		budget: NewErrorBudget(ErrorBudgetOptions{
+			Objective:   cfg.ErrorBudget.Objective,
+			Window:      cfg.ErrorBudget.Window,
+			MinRequests: cfg.ErrorBudget.MinRequests,
+			Clock:       m.clock,
+		}),
+	}
+}
+
+// GetErrorBudget retrieves the error budget of a plugin configured with
+// errorBudget
+func (m *Manager) GetErrorBudget(name string) (*ErrorBudget, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.budgets[name]
+	if !ok {
+		return nil, false
+	}
+	return state.budget, true
+}
+
+// budgetBreakers returns the circuit breakers the error budget of a plugin
+// trips, nil unless it is configured with tripBreaker
+func (m *Manager) budgetBreakers(name string) *ResourceBreakers {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	budget, ok := m.budgets[name]
+	if !ok || !budget.settings.TripBreaker {
+		return nil
+	}
+	if state, ok := m.breakers[name]; ok {
+		return state.breakers
+	}
+	return nil
+}
+
+// budgetGetter records the results of the user and group operations of a
+// PluginGetter in an error budget
+type budgetGetter struct {
+	next   scim.PluginGetter
+	budget *ErrorBudget
+	trip   *ResourceBreakers // opened on failures once the budget is exhausted, nil for none
+}
+
+// newBudgetGetter wraps next with budget, tripping the breakers trip if not
+// nil. The wrapper implements scim.UserStreamer and scim.GroupStreamer when
+// next does, so streamed lists are counted too.
+func newBudgetGetter(next scim.PluginGetter, budget *ErrorBudget, trip *ResourceBreakers) scim.PluginGetter {
+	g := &budgetGetter{next: next, budget: budget, trip: trip}
+	users, streamsUsers := findCapability[scim.UserStreamer](next)
+	groups, streamsGroups := findCapability[scim.GroupStreamer](next)
+	switch {
+	case streamsUsers && streamsGroups:
+		return &budgetStreamer{g, users, groups}
+	case streamsUsers:
+		return &budgetUserStreamer{g, users}
+	case streamsGroups:
+		return &budgetGroupStreamer{g, groups}
+	}
+	return g
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *budgetGetter) Unwrap() any {
+	return g.next
+}
+
+// budgeted records the result of op, opening the breaker that breaker
+// selects of g.trip if the budget is exhausted
+func budgeted[T any](g *budgetGetter, breaker func(*ResourceBreakers) *CircuitBreaker, op func() (T, error)) (T, error) {
+	result, err := op()
+	if g.budget.Record(err) && g.trip != nil {
+		breaker(g.trip).Trip()
+	}
+	return result, err
+}
+
+// budgetedErr is budgeted for operations without a result
+func budgetedErr(g *budgetGetter, breaker func(*ResourceBreakers) *CircuitBreaker, op func() error) error {
+	_, err := budgeted(g, breaker, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// usersBreaker and groupsBreaker select the breaker of a resource type
+func usersBreaker(b *ResourceBreakers) *CircuitBreaker  { return b.Users }
+func groupsBreaker(b *ResourceBreakers) *CircuitBreaker { return b.Groups }
+
+// GetUsers implements scim.PluginGetter
+func (g *budgetGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return budgeted(g, usersBreaker, func() (*scim.ListResponse[*scim.User], error) {
+		return g.next.GetUsers(ctx, params)
+	})
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *budgetGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return budgeted(g, usersBreaker, func() (*scim.User, error) {
+		return g.next.CreateUser(ctx, user)
+	})
+}
+
+// GetUser implements scim.PluginGetter
+func (g *budgetGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return budgeted(g, usersBreaker, func() (*scim.User, error) {
+		return g.next.GetUser(ctx, id, attributes)
+	})
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *budgetGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return budgetedErr(g, usersBreaker, func() error {
+		return g.next.ModifyUser(ctx, id, patch)
+	})
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *budgetGetter) DeleteUser(ctx context.Context, id string) error {
+	return budgetedErr(g, usersBreaker, func() error {
+		return g.next.DeleteUser(ctx, id)
+	})
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *budgetGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	return budgeted(g, usersBreaker, func() (*scim.User, error) {
+		return scim.ReplaceUser(ctx, g.next, id, user)
+	})
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *budgetGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	return budgeted(g, usersBreaker, func() (*scim.User, error) {
+		return scim.ModifyUser(ctx, g.next, id, patch)
+	})
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *budgetGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return budgeted(g, groupsBreaker, func() (*scim.ListResponse[*scim.Group], error) {
+		return g.next.GetGroups(ctx, params)
+	})
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *budgetGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return budgeted(g, groupsBreaker, func() (*scim.Group, error) {
+		return g.next.CreateGroup(ctx, group)
+	})
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *budgetGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return budgeted(g, groupsBreaker, func() (*scim.Group, error) {
+		return g.next.GetGroup(ctx, id, attributes)
+	})
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *budgetGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return budgetedErr(g, groupsBreaker, func() error {
+		return g.next.ModifyGroup(ctx, id, patch)
+	})
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *budgetGetter) DeleteGroup(ctx context.Context, id string) error {
+	return budgetedErr(g, groupsBreaker, func() error {
+		return g.next.DeleteGroup(ctx, id)
+	})
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *budgetGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	return budgeted(g, groupsBreaker, func() (*scim.Group, error) {
+		return scim.ReplaceGroup(ctx, g.next, id, group)
+	})
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *budgetGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	return budgeted(g, groupsBreaker, func() (*scim.Group, error) {
+		return scim.ModifyGroup(ctx, g.next, id, patch)
+	})
+}
+
+// budgetUserStreamer is a budgetGetter for plugins streaming users
+type budgetUserStreamer struct {
+	*budgetGetter
+	users scim.UserStreamer
+}
+
+// StreamUsers implements scim.UserStreamer
+func (g *budgetUserStreamer) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	return budgetedErr(g.budgetGetter, usersBreaker, func() error {
+		return g.users.StreamUsers(ctx, params, yield)
+	})
+}
+
+// budgetGroupStreamer is a budgetGetter for plugins streaming groups
+type budgetGroupStreamer struct {
+	*budgetGetter
+	groups scim.GroupStreamer
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (g *budgetGroupStreamer) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	return budgetedErr(g.budgetGetter, groupsBreaker, func() error {
+		return g.groups.StreamGroups(ctx, params, yield)
+	})
+}
+
+// budgetStreamer is a budgetGetter for plugins streaming users and groups
+type budgetStreamer struct {
+	*budgetGetter
+	users  scim.UserStreamer
+	groups scim.GroupStreamer
+}
+
+// StreamUsers implements scim.UserStreamer
+func (g *budgetStreamer) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	return budgetedErr(g.budgetGetter, usersBreaker, func() error {
+		return g.users.StreamUsers(ctx, params, yield)
+	})
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (g *budgetStreamer) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	return budgetedErr(g.budgetGetter, groupsBreaker, func() error {
+		return g.groups.StreamGroups(ctx, params, yield)
+	})
+}
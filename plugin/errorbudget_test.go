@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestErrorBudget(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := NewErrorBudget(ErrorBudgetOptions{Objective: 98, Window: time.Hour, MinRequests: 100, Clock: clk})
+	down := errors.New("connection refused")
+
+	if got := b.Status(); got.SuccessRate != 100 || got.Remaining != 1 || got.Exhausted {
+		t.Errorf("Status() without calls = %+v, want an unused budget", got)
+	}
+
+	// Client errors are answers of a working backend and cancelled calls are
+	// not counted
+	for range 97 {
+		b.Record(nil)
+	}
+	b.Record(scim.ErrNotFound("User", "42"))
+	b.Record(context.Canceled)
+	if b.Record(down) {
+		t.Error("Record() reported an exhausted budget after 1 failure in 99 calls")
+	}
+	got := b.Status()
+	if got.Requests != 99 || got.Failures != 1 || got.Exhausted {
+		t.Fatalf("Status() = %+v, want 99 calls and 1 failure", got)
+	}
+	if got.Remaining < 0.49 || got.Remaining > 0.5 {
+		t.Errorf("Remaining = %v, want about half of 1.98 allowed failures left", got.Remaining)
+	}
+
+	// The 100th call reaches MinRequests with the 2 allowed failures used up
+	clk.Advance(30 * time.Minute)
+	if !b.Record(scim.ErrInternalServer("database is locked")) {
+		t.Error("Record() did not report the exhausted budget")
+	}
+	if got := b.Status(); !got.Exhausted || got.SuccessRate != 98 || got.Remaining != 0 {
+		t.Errorf("Status() = %+v, want an exhausted budget at 98%%", got)
+	}
+
+	// The first calls leave the window an hour after they were made
+	clk.Advance(31 * time.Minute)
+	if got := b.Status(); got.Requests != 1 || got.Failures != 1 || got.Exhausted {
+		t.Errorf("Status() = %+v, want the last call only", got)
+	}
+	clk.Advance(30 * time.Minute)
+	if got := b.Status(); got.Requests != 0 || got.Remaining != 1 {
+		t.Errorf("Status() = %+v, want no calls", got)
+	}
+}
+
+func TestAdaptedManagerErrorBudget(t *testing.T) {
+	p := &splitPlugin{mockPlugin: mockPlugin{name: "split"}, groupErr: errors.New("connection refused")}
+	cfg := &config.PluginConfig{
+		Name:           "split",
+		CircuitBreaker: &config.CircuitBreakerConfig{FailureThreshold: 100},
+		ErrorBudget:    &config.ErrorBudgetConfig{Objective: 50, MinRequests: 4, TripBreaker: true},
+	}
+	manager := NewManager()
+	manager.Register(p, cfg)
+	getter, _ := NewAdaptedManager(manager).Get("split")
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := getter.GetUsers(ctx, scim.QueryParams{}); err != nil {
+			t.Fatalf("GetUsers() error = %v", err)
+		}
+	}
+	for range 3 {
+		if _, err := getter.GetGroups(ctx, scim.QueryParams{}); !errors.Is(err, p.groupErr) {
+			t.Fatalf("GetGroups() error = %v, want the backend error", err)
+		}
+	}
+
+	// The third failure in six calls exhausts the budget and trips the
+	// groups breaker long before its failure threshold
+	budget, _ := manager.GetErrorBudget("split")
+	if got := budget.Status(); !got.Exhausted || got.Requests != 6 || got.Failures != 3 {
+		t.Errorf("Status() = %+v, want an exhausted budget", got)
+	}
+	_, err := getter.GetGroups(ctx, scim.QueryParams{})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("GetGroups() error = %v, want 503", err)
+	}
+	if got := budget.Status(); got.Requests != 6 {
+		t.Errorf("Requests = %d, want rejected calls not counted", got.Requests)
+	}
+	breakers, _ := manager.GetBreakers("split")
+	if got := breakers.Users.State(); got != CircuitClosed {
+		t.Errorf("users State() = %s, want closed", got)
+	}
+
+	// Reloading unchanged settings keeps the calls counted; without
+	// tripBreaker the budget only reports
+	manager.UpdateConfig("split", cfg)
+	if again, _ := manager.GetErrorBudget("split"); again != budget {
+		t.Error("reloading unchanged settings recreated the error budget")
+	}
+	manager.UpdateConfig("split", &config.PluginConfig{Name: "split", ErrorBudget: &config.ErrorBudgetConfig{Objective: 50, MinRequests: 1}})
+	getter, _ = NewAdaptedManager(manager).Get("split")
+	for range 2 {
+		if _, err := getter.GetGroups(ctx, scim.QueryParams{}); !errors.Is(err, p.groupErr) {
+			t.Fatalf("GetGroups() without tripBreaker error = %v, want the backend error", err)
+		}
+	}
+}
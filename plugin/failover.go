@@ -0,0 +1,327 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scheduler"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Default settings of a Failover pair
+const (
+	DefaultFailoverInterval         = 10 * time.Second
+	DefaultFailoverFailureThreshold = 3
+)
+
+// FailoverRole identifies the plugin of a Failover pair
+type FailoverRole string
+
+// Roles of the plugins of a Failover pair
+const (
+	FailoverPrimary FailoverRole = "primary"
+	FailoverStandby FailoverRole = "standby"
+)
+
+// FailoverEvent describes a switch between the plugins of a Failover pair
+type FailoverEvent struct {
+	Name string       // route name of the pair
+	From FailoverRole // role that served requests before the switch
+	To   FailoverRole // role that serves requests after the switch
+	Err  error        // last health check error of the primary, nil on fail-back
+	Time time.Time
+}
+
+// FailoverOptions configures the health checks of a Failover pair.
+// Zero values use the defaults.
+type FailoverOptions struct {
+	// Interval between health checks of the primary
+	Interval time.Duration
+
+	// FailureThreshold is the number of consecutive failed checks after which
+	// requests fail over to the standby, and the number of consecutive
+	// successful checks after which they fail back to the primary
+	FailureThreshold int
+
+	// Timeout bounds a single health check. Zero uses the interval.
+	Timeout time.Duration
+
+	// Clock timestamps events. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// Failover serves one route from a primary plugin and switches reads and
+// writes to a standby plugin when the primary's health check (see
+// HealthChecker) fails for FailureThreshold consecutive intervals. It fails
+// back automatically once the primary passed as many consecutive checks.
+//
+// Failover implements Plugin and is registered like any other plugin; its
+// health checks run on the gateway's scheduler (see Jobs), or while Run is
+// active:
+//
+//	pair := plugin.NewFailover("hr", primary, standby, plugin.FailoverOptions{})
+//	pair.OnSwitch(func(e plugin.FailoverEvent) { log.Printf("%s now served by %s", e.Name, e.To) })
+//	manager.Register(pair, cfg)
+//	go pair.Run(ctx)
+//
+// The standby does not receive the writes served by the primary, so both
+// should share a replicated backend.
+type Failover struct {
+	name    string
+	primary Plugin
+	standby Plugin
+	opts    FailoverOptions
+
+	mu        sync.RWMutex // protects the fields below
+	role      FailoverRole
+	failures  int // consecutive failed checks of the primary
+	successes int // consecutive successful checks of the primary
+	listeners []func(FailoverEvent)
+}
+
+// NewFailover creates a Failover pair serving the route name. Requests go to
+// the primary until its health checks fail.
+func NewFailover(name string, primary, standby Plugin, opts FailoverOptions) *Failover {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultFailoverInterval
+	}
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = DefaultFailoverFailureThreshold
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	return &Failover{
+		name:    name,
+		primary: primary,
+		standby: standby,
+		opts:    opts,
+		role:    FailoverPrimary,
+	}
+}
+
+// OnSwitch registers fn to be called after every switch between the plugins.
+// Listeners are called synchronously from the health check.
+func (f *Failover) OnSwitch(fn func(FailoverEvent)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners = append(f.listeners, fn)
+}
+
+// Active returns the role of the plugin currently serving requests
+func (f *Failover) Active() FailoverRole {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.role
+}
+
+// Jobs implements JobProvider: the gateway checks the health of the
+// primary every interval on its scheduler, as job "health-check"
+func (f *Failover) Jobs() []scheduler.Job {
+	return []scheduler.Job{{
+		Name:     "health-check",
+		Interval: f.opts.Interval,
+		Run: func(ctx context.Context) error {
+			f.Check(ctx)
+			return nil
+		},
+	}}
+}
+
+// Run checks the health of the primary every interval until ctx is
+// cancelled, so it is typically started in a goroutine. Pairs registered
+// with a gateway are checked by its scheduler instead (see Jobs).
+func (f *Failover) Run(ctx context.Context) error {
+	ticker := time.NewTicker(f.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		f.Check(ctx)
+	}
+}
+
+// Check runs one health check of the primary and switches plugins when a
+// threshold is reached. Run calls it every interval.
+func (f *Failover) Check(ctx context.Context) {
+	err := f.checkPrimary(ctx)
+
+	f.mu.Lock()
+	var event *FailoverEvent
+	if err != nil {
+		f.failures++
+		f.successes = 0
+		if f.role == FailoverPrimary && f.failures >= f.opts.FailureThreshold {
+			event = f.switchTo(FailoverStandby, err)
+		}
+	} else {
+		f.successes++
+		f.failures = 0
+		if f.role == FailoverStandby && f.successes >= f.opts.FailureThreshold {
+			event = f.switchTo(FailoverPrimary, nil)
+		}
+	}
+	listeners := f.listeners
+	f.mu.Unlock()
+
+	if event != nil {
+		for _, fn := range listeners {
+			fn(*event)
+		}
+	}
+}
+
+// switchTo makes role serve requests. Callers must hold f.mu.
+func (f *Failover) switchTo(role FailoverRole, err error) *FailoverEvent {
+	event := &FailoverEvent{
+		Name: f.name,
+		From: f.role,
+		To:   role,
+		Err:  err,
+		Time: f.opts.Clock.Now(),
+	}
+	f.role = role
+	return event
+}
+
+// checkPrimary runs the health check of the primary, if it has one
+func (f *Failover) checkPrimary(ctx context.Context) error {
+	checker, ok := f.primary.(HealthChecker)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+	return checker.HealthCheck(ctx)
+}
+
+// current returns the plugin currently serving requests
+func (f *Failover) current() Plugin {
+	if f.Active() == FailoverStandby {
+		return f.standby
+	}
+	return f.primary
+}
+
+// Unwrap returns the plugin currently serving requests so the server can
+// discover the optional capabilities it implements
+func (f *Failover) Unwrap() any {
+	return f.current()
+}
+
+// HealthCheck implements HealthChecker by checking the plugin currently
+// serving requests
+func (f *Failover) HealthCheck(ctx context.Context) error {
+	if checker, ok := f.current().(HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// Init implements Initializer by initializing the primary and the standby
+func (f *Failover) Init(ctx context.Context) error {
+	for _, p := range []Plugin{f.primary, f.standby} {
+		if initializer, ok := findCapability[Initializer](p); ok {
+			if err := initializer.Init(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close implements Closer by closing the primary and the standby
+func (f *Failover) Close() error {
+	var errs []error
+	for _, p := range []Plugin{f.primary, f.standby} {
+		if closer, ok := findCapability[Closer](p); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name returns the route name of the pair
+func (f *Failover) Name() string {
+	return f.name
+}
+
+// ListUsers implements UserLister, paginating natively when the active
+// plugin does
+func (f *Failover) ListUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	p := f.current()
+	if lister, ok := p.(UserLister); ok {
+		return lister.ListUsers(ctx, params)
+	}
+	return scim.ListUsersWithCapabilities(ctx, p, params)
+}
+
+// GetUsers implements Plugin
+func (f *Failover) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	return f.current().GetUsers(ctx, params)
+}
+
+// CreateUser implements Plugin
+func (f *Failover) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return f.current().CreateUser(ctx, user)
+}
+
+// GetUser implements Plugin
+func (f *Failover) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return f.current().GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements Plugin
+func (f *Failover) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return f.current().ModifyUser(ctx, id, patch)
+}
+
+// DeleteUser implements Plugin
+func (f *Failover) DeleteUser(ctx context.Context, id string) error {
+	return f.current().DeleteUser(ctx, id)
+}
+
+// ListGroups implements GroupLister, paginating natively when the active
+// plugin does
+func (f *Failover) ListGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	p := f.current()
+	if lister, ok := p.(GroupLister); ok {
+		return lister.ListGroups(ctx, params)
+	}
+	return scim.ListGroupsWithCapabilities(ctx, p, params)
+}
+
+// GetGroups implements Plugin
+func (f *Failover) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	return f.current().GetGroups(ctx, params)
+}
+
+// CreateGroup implements Plugin
+func (f *Failover) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return f.current().CreateGroup(ctx, group)
+}
+
+// GetGroup implements Plugin
+func (f *Failover) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return f.current().GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements Plugin
+func (f *Failover) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return f.current().ModifyGroup(ctx, id, patch)
+}
+
+// DeleteGroup implements Plugin
+func (f *Failover) DeleteGroup(ctx context.Context, id string) error {
+	return f.current().DeleteGroup(ctx, id)
+}
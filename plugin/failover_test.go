@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// healthPlugin is a mockPlugin with a settable health
+type healthPlugin struct {
+	mockPlugin
+	err error
+}
+
+func (p *healthPlugin) HealthCheck(ctx context.Context) error { return p.err }
+
+func TestFailoverSwitchesAfterThreshold(t *testing.T) {
+	primary := &healthPlugin{mockPlugin: mockPlugin{name: "primary"}}
+	standby := &mockPlugin{name: "standby"}
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pair := NewFailover("hr", primary, standby, FailoverOptions{FailureThreshold: 2, Clock: clock.NewFake(now)})
+
+	var events []FailoverEvent
+	pair.OnSwitch(func(e FailoverEvent) { events = append(events, e) })
+
+	ctx := context.Background()
+	primary.err = errors.New("connection refused")
+
+	pair.Check(ctx)
+	if pair.Active() != FailoverPrimary {
+		t.Fatalf("Active() = %s after one failure, want primary", pair.Active())
+	}
+	pair.Check(ctx)
+	if pair.Active() != FailoverStandby {
+		t.Fatalf("Active() = %s after two failures, want standby", pair.Active())
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if e := events[0]; e.Name != "hr" || e.From != FailoverPrimary || e.To != FailoverStandby || e.Err != primary.err || !e.Time.Equal(now) {
+		t.Errorf("failover event = %+v", e)
+	}
+
+	// Further failures do not emit events
+	pair.Check(ctx)
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+
+	// A single success does not fail back
+	primary.err = nil
+	pair.Check(ctx)
+	if pair.Active() != FailoverStandby {
+		t.Fatalf("Active() = %s after one success, want standby", pair.Active())
+	}
+	pair.Check(ctx)
+	if pair.Active() != FailoverPrimary {
+		t.Fatalf("Active() = %s after two successes, want primary", pair.Active())
+	}
+	if len(events) != 2 || events[1].To != FailoverPrimary || events[1].Err != nil {
+		t.Errorf("fail-back events = %+v", events)
+	}
+}
+
+func TestFailoverResetsFailuresOnSuccess(t *testing.T) {
+	primary := &healthPlugin{mockPlugin: mockPlugin{name: "primary"}}
+	pair := NewFailover("hr", primary, &mockPlugin{name: "standby"}, FailoverOptions{FailureThreshold: 2})
+
+	ctx := context.Background()
+	for _, err := range []error{errors.New("timeout"), nil, errors.New("timeout")} {
+		primary.err = err
+		pair.Check(ctx)
+	}
+	if pair.Active() != FailoverPrimary {
+		t.Errorf("Active() = %s, want primary: failures were not consecutive", pair.Active())
+	}
+}
+
+func TestFailoverWithoutHealthCheck(t *testing.T) {
+	pair := NewFailover("hr", &mockPlugin{name: "primary"}, &mockPlugin{name: "standby"}, FailoverOptions{FailureThreshold: 1})
+
+	pair.Check(context.Background())
+	if pair.Active() != FailoverPrimary {
+		t.Errorf("Active() = %s, want primary for a primary without health check", pair.Active())
+	}
+}
+
+func TestFailoverRoutesRequests(t *testing.T) {
+	primary := &healthPlugin{mockPlugin: mockPlugin{name: "primary"}}
+	standby := &listerPlugin{contextAwarePlugin: contextAwarePlugin{name: "standby"}}
+	pair := NewFailover("hr", primary, standby, FailoverOptions{FailureThreshold: 1})
+
+	if pair.Name() != "hr" {
+		t.Errorf("Name() = %q, want the route name", pair.Name())
+	}
+	if pair.Unwrap() != primary {
+		t.Error("Unwrap() should return the primary while it is healthy")
+	}
+
+	ctx := context.Background()
+	resp, err := pair.ListUsers(ctx, scim.QueryParams{})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if resp.TotalResults != 0 {
+		t.Errorf("TotalResults = %d, want the primary's empty list", resp.TotalResults)
+	}
+
+	primary.err = errors.New("down")
+	pair.Check(ctx)
+
+	if pair.Unwrap() != standby {
+		t.Error("Unwrap() should return the standby after failover")
+	}
+	resp, err = pair.ListUsers(ctx, scim.QueryParams{})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if resp.TotalResults != 250 {
+		t.Errorf("TotalResults = %d, want the standby's native page", resp.TotalResults)
+	}
+}
+
+func TestFailoverRun(t *testing.T) {
+	primary := &healthPlugin{mockPlugin: mockPlugin{name: "primary"}, err: errors.New("down")}
+	pair := NewFailover("hr", primary, &mockPlugin{name: "standby"}, FailoverOptions{Interval: time.Millisecond, FailureThreshold: 1})
+
+	switched := make(chan FailoverEvent, 1)
+	pair.OnSwitch(func(e FailoverEvent) {
+		select {
+		case switched <- e:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- pair.Run(ctx) }()
+
+	select {
+	case e := <-switched:
+		if e.To != FailoverStandby {
+			t.Errorf("event.To = %s, want standby", e.To)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run() did not fail over")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package plugin
+
+import (
+	"slices"
+
+	"github.com/marcelom97/scimgateway/scheduler"
+)
+
+// JobProvider is an optional interface for plugins, and wrappers such as
+// Failover, with periodic maintenance, e.g. purging soft-deleted rows.
+// Instead of starting goroutines of their own, they return their jobs, and
+// the gateway runs them on its scheduler.
+type JobProvider interface {
+	Jobs() []scheduler.Job
+}
+
+// Jobs returns the jobs of the registered plugins implementing JobProvider,
+// named <plugin>/<job>, e.g. "hr/purge-deleted"
+func (m *Manager) Jobs() []scheduler.Job {
+	names := m.List()
+	slices.Sort(names)
+
+	var jobs []scheduler.Job
+	for _, name := range names {
+		p, ok := m.Get(name)
+		if !ok {
+			continue
+		}
+		provider, ok := findCapability[JobProvider](p)
+		if !ok {
+			continue
+		}
+		for _, job := range provider.Jobs() {
+			job.Name = name + "/" + job.Name
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Default settings of keepalive pings
+const (
+	DefaultKeepaliveInterval = 5 * time.Minute
+	DefaultKeepaliveTimeout  = 10 * time.Second
+)
+
+// Pinger is an optional interface for plugins holding persistent
+// connections to their backend, such as LDAP binds or database sessions,
+// which firewalls and servers drop after a while of idleness. Ping sends a
+// cheap request over the connections so they are kept open, and fails if
+// they are stale.
+//
+// Plugins implementing DBProvider need not implement it: Keepalive pings
+// their pool.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Reconnector is an optional interface for plugins that can replace stale
+// connections. Reconnect closes the plugin's connections and opens new
+// ones; requests in progress may fail.
+type Reconnector interface {
+	Reconnect(ctx context.Context) error
+}
+
+// KeepaliveOptions configures Keepalive. Zero values use the defaults.
+type KeepaliveOptions struct {
+	// Timeout bounds each ping and reconnect
+	Timeout time.Duration
+}
+
+// HasKeepalive reports whether p holds connections Keepalive pings, i.e.
+// implements Pinger or DBProvider
+func HasKeepalive(p Plugin) bool {
+	_, ok := keepalivePing(p)
+	return ok
+}
+
+// Keepalive pings the connections of p, with Ping if it implements Pinger or
+// through its pool if it implements DBProvider, so the first request after
+// an idle period does not fail on a stale connection. If the ping fails and
+// p implements Reconnector, Keepalive reconnects and pings again, and
+// reports that it reconnected. Plugins without connections to ping are left
+// alone.
+func Keepalive(ctx context.Context, p Plugin, opts KeepaliveOptions) (reconnected bool, err error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultKeepaliveTimeout
+	}
+	ping, ok := keepalivePing(p)
+	if !ok {
+		return false, nil
+	}
+
+	withTimeout := func(f func(context.Context) error) error {
+		ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+		return f(ctx)
+	}
+
+	pingErr := withTimeout(ping)
+	if pingErr == nil {
+		return false, nil
+	}
+	reconnector, ok := findCapability[Reconnector](p)
+	if !ok {
+		return false, fmt.Errorf("ping failed: %w", pingErr)
+	}
+	if err := withTimeout(reconnector.Reconnect); err != nil {
+		return false, fmt.Errorf("reconnect after failed ping (%v) failed: %w", pingErr, err)
+	}
+	if err := withTimeout(ping); err != nil {
+		return true, fmt.Errorf("ping failed after reconnecting: %w", err)
+	}
+	return true, nil
+}
+
+// keepalivePing returns the function pinging the connections of p
+func keepalivePing(p Plugin) (func(context.Context) error, bool) {
+	if pinger, ok := findCapability[Pinger](p); ok {
+		return pinger.Ping, true
+	}
+	if provider, ok := findCapability[DBProvider](p); ok {
+		return func(ctx context.Context) error { return provider.DB().PingContext(ctx) }, true
+	}
+	return nil, false
+}
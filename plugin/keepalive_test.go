@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// connectedPlugin holds a connection that goes stale until it reconnects
+type connectedPlugin struct {
+	mockPlugin
+	stale        bool
+	reconnectErr error
+	pings        int
+	reconnects   int
+}
+
+func (p *connectedPlugin) Ping(ctx context.Context) error {
+	p.pings++
+	if p.stale {
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func (p *connectedPlugin) Reconnect(ctx context.Context) error {
+	p.reconnects++
+	if p.reconnectErr != nil {
+		return p.reconnectErr
+	}
+	p.stale = false
+	return nil
+}
+
+func TestKeepalive(t *testing.T) {
+	p := &connectedPlugin{mockPlugin: mockPlugin{name: "ldap"}}
+
+	reconnected, err := Keepalive(context.Background(), p, KeepaliveOptions{})
+	if err != nil || reconnected || p.pings != 1 || p.reconnects != 0 {
+		t.Errorf("Keepalive() = %v, %v with %d pings and %d reconnects, want one ping", reconnected, err, p.pings, p.reconnects)
+	}
+
+	p.stale = true
+	reconnected, err = Keepalive(context.Background(), p, KeepaliveOptions{})
+	if err != nil || !reconnected || p.reconnects != 1 || p.pings != 3 {
+		t.Errorf("Keepalive() of a stale connection = %v, %v with %d pings and %d reconnects, want a reconnect and a ping after it", reconnected, err, p.pings, p.reconnects)
+	}
+
+	p.stale, p.reconnectErr = true, errors.New("connection refused")
+	if _, err := Keepalive(context.Background(), p, KeepaliveOptions{}); err == nil {
+		t.Error("Keepalive() error = nil, want the failed reconnect")
+	}
+}
+
+func TestKeepaliveWithoutConnections(t *testing.T) {
+	p := &mockPlugin{name: "memory"}
+
+	if HasKeepalive(p) {
+		t.Error("HasKeepalive() = true, want false for a plugin without Pinger or DBProvider")
+	}
+	if reconnected, err := Keepalive(context.Background(), p, KeepaliveOptions{}); reconnected || err != nil {
+		t.Errorf("Keepalive() = %v, %v, want false, nil", reconnected, err)
+	}
+}
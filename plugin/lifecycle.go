@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Initializer is an optional interface for plugins that need to set up
+// their backend, e.g. open connections or load data, before serving
+// requests. Manager.Init calls it when the gateway initializes; an error
+// fails initialization.
+type Initializer interface {
+	Init(ctx context.Context) error
+}
+
+// HealthChecker is an optional interface for plugins that can tell whether
+// their backend is reachable. The gateway reports it on its health endpoints,
+// and a Failover pair checks the health of its primary to decide when to
+// switch to the standby. Plugins without it are always considered healthy.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Closer is an optional interface for plugins holding resources, such as
+// database connections, that must be released on shutdown. Manager.Close
+// calls it once the gateway stopped serving requests.
+type Closer interface {
+	Close() error
+}
+
+// sortedPlugins returns the registered plugins ordered by name
+func (m *Manager) sortedPlugins() ([]string, map[string]Plugin) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	plugins := make(map[string]Plugin, len(m.plugins))
+	for name, p := range m.plugins {
+		plugins[name] = p
+	}
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names, plugins
+}
+
+// Init initializes the registered plugins implementing Initializer in name
+// order and stops at the first error
+func (m *Manager) Init(ctx context.Context) error {
+	names, plugins := m.sortedPlugins()
+	for _, name := range names {
+		initializer, ok := findCapability[Initializer](plugins[name])
+		if !ok {
+			continue
+		}
+		if err := initializer.Init(ctx); err != nil {
+			return fmt.Errorf("failed to initialize plugin %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// HealthCheck checks the health of a registered plugin. Plugins without
+// HealthChecker are healthy.
+func (m *Manager) HealthCheck(ctx context.Context, name string) error {
+	p, ok := m.Get(name)
+	if !ok {
+		return fmt.Errorf("plugin %s is not registered", name)
+	}
+	if checker, ok := findCapability[HealthChecker](p); ok {
+		return checker.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// CheckHealth checks the health of all registered plugins concurrently and
+// returns the result of each plugin by name, nil meaning healthy
+func (m *Manager) CheckHealth(ctx context.Context) map[string]error {
+	names, _ := m.sortedPlugins()
+	results := make(map[string]error, len(names))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Go(func() {
+			err := m.HealthCheck(ctx, name)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	return results
+}
+
+// Close closes all registered plugins implementing Closer in name order.
+// Every plugin is closed even if others fail; the errors are returned
+// together.
+func (m *Manager) Close() error {
+	results := m.CloseEach()
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(results)) {
+		if err := results[name]; err != nil {
+			errs = append(errs, fmt.Errorf("failed to close plugin %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseEach closes all registered plugins implementing Closer in name order
+// and returns the result of each by name, nil meaning closed
+func (m *Manager) CloseEach() map[string]error {
+	names, plugins := m.sortedPlugins()
+	results := make(map[string]error)
+	for _, name := range names {
+		closer, ok := findCapability[Closer](plugins[name])
+		if !ok {
+			continue
+		}
+		results[name] = closer.Close()
+	}
+	return results
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// lifecyclePlugin records the lifecycle hooks called on it
+type lifecyclePlugin struct {
+	mockPlugin
+	calls     *[]string
+	initErr   error
+	healthErr error
+	closeErr  error
+}
+
+func (p *lifecyclePlugin) Init(context.Context) error {
+	*p.calls = append(*p.calls, "init "+p.name)
+	return p.initErr
+}
+
+func (p *lifecyclePlugin) HealthCheck(context.Context) error {
+	return p.healthErr
+}
+
+func (p *lifecyclePlugin) Close() error {
+	*p.calls = append(*p.calls, "close "+p.name)
+	return p.closeErr
+}
+
+func TestManagerLifecycle(t *testing.T) {
+	var calls []string
+	down := errors.New("connection refused")
+	m := NewManager()
+	m.Register(&lifecyclePlugin{mockPlugin: mockPlugin{name: "b"}, calls: &calls, healthErr: down, closeErr: down}, nil)
+	m.Register(&lifecyclePlugin{mockPlugin: mockPlugin{name: "a"}, calls: &calls}, nil)
+	m.Register(&mockPlugin{name: "plain"}, nil)
+
+	if err := m.Init(context.Background()); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	health := m.CheckHealth(context.Background())
+	want := map[string]error{"a": nil, "b": down, "plain": nil}
+	if !reflect.DeepEqual(health, want) {
+		t.Errorf("CheckHealth() = %v, want %v", health, want)
+	}
+	if err := m.HealthCheck(context.Background(), "missing"); err == nil {
+		t.Error("HealthCheck() of an unregistered plugin succeeded")
+	}
+
+	// Every plugin is closed even if one fails
+	if err := m.Close(); !errors.Is(err, down) {
+		t.Errorf("Close() error = %v, want the close error of b", err)
+	}
+	if want := []string{"init a", "init b", "close a", "close b"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestManagerInitError(t *testing.T) {
+	var calls []string
+	m := NewManager()
+	m.Register(&lifecyclePlugin{mockPlugin: mockPlugin{name: "a"}, calls: &calls, initErr: errors.New("schema missing")}, nil)
+	m.Register(&lifecyclePlugin{mockPlugin: mockPlugin{name: "b"}, calls: &calls}, nil)
+
+	if err := m.Init(context.Background()); err == nil {
+		t.Fatal("Init() succeeded, want the error of a")
+	}
+	if want := []string{"init a"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestFailoverLifecycle(t *testing.T) {
+	var calls []string
+	pair := NewFailover("hr",
+		&lifecyclePlugin{mockPlugin: mockPlugin{name: "primary"}, calls: &calls},
+		&lifecyclePlugin{mockPlugin: mockPlugin{name: "standby"}, calls: &calls},
+		FailoverOptions{})
+
+	m := NewManager()
+	m.Register(pair, nil)
+	if err := m.Init(context.Background()); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if want := []string{"init primary", "init standby", "close primary", "close standby"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
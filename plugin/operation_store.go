@@ -0,0 +1,261 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// States of an Operation
+const (
+	OperationPending  = "pending"  // queued, waiting for a retry or for approval
+	OperationApplied  = "applied"  // the plugin applied the write
+	OperationFailed   = "failed"   // the plugin rejected the write or retries ran out
+	OperationRejected = "rejected" // the write was not approved
+)
+
+// ErrOperationNotFound is returned by OperationStore.Get for unknown IDs
+var ErrOperationNotFound = errors.New("operation not found")
+
+// Operation is a write to a plugin in asynchronous write mode, queued until
+// a background worker applies it, or a change held until it is approved
+type Operation struct {
+	ID     string `json:"id"`
+	Plugin string `json:"plugin"`
+
+	// Method is the HTTP method of the write: POST, PUT, PATCH or DELETE
+	Method string `json:"method"`
+
+	// ResourceType is the endpoint of the resource type, Users or Groups
+	ResourceType string `json:"resourceType"`
+
+	// ResourceID is the ID of the resource written, set for POST once it
+	// was applied
+	ResourceID string `json:"resourceId,omitempty"`
+
+	// Body is the validated resource of a POST or PUT and the PatchOp of a
+	// PATCH, as passed to the plugin
+	Body json.RawMessage `json:"body,omitempty"`
+
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+
+	// Error is the error of the last attempt, if it failed
+	Error *scim.Error `json:"error,omitempty"`
+
+	// BaseEntity is the base entity the request that queued the operation
+	// was scoped to, which it is applied in
+	BaseEntity string `json:"baseEntity,omitempty"`
+
+	// RequestID is the X-Request-Id of the request that queued the
+	// operation, passed on to the plugin when it is applied
+	RequestID string `json:"requestId,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+
+	// NextAttempt is when a pending operation is tried next
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+
+	// Approval is set for changes held for approval, which are not applied
+	// by the asynchronous write queue
+	Approval *Approval `json:"approval,omitempty"`
+}
+
+// Approval is the approval a change held by an ApprovalQueue waits for
+type Approval struct {
+	// Attributes are the attributes whose changes need approval
+	Attributes []string `json:"attributes"`
+
+	// RequestedBy is the authenticated client that made the change, if known
+	RequestedBy string `json:"requestedBy,omitempty"`
+
+	// DecidedBy is the authenticated client that approved or rejected it
+	DecidedBy string `json:"decidedBy,omitempty"`
+}
+
+// OperationStore stores the operations of plugins in asynchronous write
+// mode. Durable stores keep queued writes across restarts of the gateway.
+// Implementations must be safe for concurrent use.
+type OperationStore interface {
+	// Save inserts an operation or updates the operation with its ID
+	Save(ctx context.Context, op *Operation) error
+
+	// Get returns the operation with the given ID, or ErrOperationNotFound
+	Get(ctx context.Context, id string) (*Operation, error)
+
+	// List returns the operations of a plugin in the order they were
+	// inserted
+	List(ctx context.Context, plugin string) ([]*Operation, error)
+
+	// Delete removes the operation with the given ID, if any
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryOperationStore is an OperationStore keeping operations in memory,
+// so queued writes are lost when the gateway stops. It is the default store.
+type MemoryOperationStore struct {
+	mu  sync.RWMutex
+	ops []*Operation // in insertion order
+}
+
+// NewMemoryOperationStore creates an empty in-memory operation store
+func NewMemoryOperationStore() *MemoryOperationStore {
+	return &MemoryOperationStore{}
+}
+
+// Save implements OperationStore
+func (s *MemoryOperationStore) Save(ctx context.Context, op *Operation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.save(op)
+	return nil
+}
+
+// save stores a copy of op. Callers must hold s.mu.
+func (s *MemoryOperationStore) save(op *Operation) {
+	saved := *op
+	if i := slices.IndexFunc(s.ops, func(o *Operation) bool { return o.ID == op.ID }); i != -1 {
+		s.ops[i] = &saved
+		return
+	}
+	s.ops = append(s.ops, &saved)
+}
+
+// Get implements OperationStore
+func (s *MemoryOperationStore) Get(ctx context.Context, id string) (*Operation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, op := range s.ops {
+		if op.ID == id {
+			found := *op
+			return &found, nil
+		}
+	}
+	return nil, ErrOperationNotFound
+}
+
+// List implements OperationStore
+func (s *MemoryOperationStore) List(ctx context.Context, plugin string) ([]*Operation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var ops []*Operation
+	for _, op := range s.ops {
+		if op.Plugin == plugin {
+			found := *op
+			ops = append(ops, &found)
+		}
+	}
+	return ops, nil
+}
+
+// Delete implements OperationStore
+func (s *MemoryOperationStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops = slices.DeleteFunc(s.ops, func(o *Operation) bool { return o.ID == id })
+	return nil
+}
+
+// FileOperationStore is an OperationStore persisting operations to a JSON
+// file, so queued writes survive restarts of the gateway. Every change
+// rewrites the file atomically, which suits the moderate write rates of
+// batch-oriented backends.
+type FileOperationStore struct {
+	path   string
+	memory MemoryOperationStore
+}
+
+// NewFileOperationStore creates a store persisting operations to the file at
+// path, loading the operations it holds if it exists
+func NewFileOperationStore(path string) (*FileOperationStore, error) {
+	s := &FileOperationStore{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read operation store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.memory.ops); err != nil {
+		return nil, fmt.Errorf("parse operation store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save implements OperationStore
+func (s *FileOperationStore) Save(ctx context.Context, op *Operation) error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	previous := slices.Clone(s.memory.ops)
+	s.memory.save(op)
+	if err := s.persist(); err != nil {
+		s.memory.ops = previous
+		return err
+	}
+	return nil
+}
+
+// Get implements OperationStore
+func (s *FileOperationStore) Get(ctx context.Context, id string) (*Operation, error) {
+	return s.memory.Get(ctx, id)
+}
+
+// List implements OperationStore
+func (s *FileOperationStore) List(ctx context.Context, plugin string) ([]*Operation, error) {
+	return s.memory.List(ctx, plugin)
+}
+
+// Delete implements OperationStore
+func (s *FileOperationStore) Delete(ctx context.Context, id string) error {
+	s.memory.mu.Lock()
+	defer s.memory.mu.Unlock()
+
+	previous := slices.Clone(s.memory.ops)
+	s.memory.ops = slices.DeleteFunc(s.memory.ops, func(o *Operation) bool { return o.ID == id })
+	if err := s.persist(); err != nil {
+		s.memory.ops = previous
+		return err
+	}
+	return nil
+}
+
+// persist writes the operations to a temporary file and renames it over the
+// store's file. Callers must hold s.memory.mu.
+func (s *FileOperationStore) persist() error {
+	data, err := json.Marshal(s.memory.ops)
+	if err != nil {
+		return fmt.Errorf("encode operation store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("write operation store: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() // nolint:errcheck
+		return fmt.Errorf("write operation store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() // nolint:errcheck
+		return fmt.Errorf("write operation store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write operation store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("write operation store: %w", err)
+	}
+	return nil
+}
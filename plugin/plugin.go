@@ -2,9 +2,18 @@ package plugin
 
 import (
 	"context"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/marcelom97/scimgateway/auth"
+	"github.com/marcelom97/scimgateway/auth/jwt"
+	"github.com/marcelom97/scimgateway/auth/mtls"
+	"github.com/marcelom97/scimgateway/auth/oauth2"
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/config"
 	"github.com/marcelom97/scimgateway/scim"
 )
@@ -27,7 +36,7 @@ import (
 //
 // Error Handling:
 //   - Use scim.ErrNotFound() for missing resources (becomes HTTP 404)
-//   - Use scim.ErrUniqueness() for duplicate keys (becomes HTTP 409)
+//   - Use scim.ErrConflict() for duplicate keys (becomes HTTP 409)
 //   - Use scim.ErrInternalServer() for backend errors (becomes HTTP 500)
 //   - See scim/errors.go for the complete list of error constructors
 //
@@ -45,6 +54,8 @@ type Plugin interface {
 	// Optimized Implementation:
 	//   Process params.Filter natively in your backend (e.g., convert to SQL WHERE clause).
 	//   This can dramatically improve performance with large datasets.
+	//   Declare what you apply natively with scim.CapabilitiesProvider so the
+	//   adapter applies only the rest of the query.
 	//
 	// Parameters:
 	//   - ctx: Request context for cancellation and timeouts. Always respect ctx.Done().
@@ -65,7 +76,7 @@ type Plugin interface {
 	//   - Generate user.ID if not provided (e.g., using uuid.New())
 	//   - Set user.Schemas if not provided (default: []string{scim.SchemaUser})
 	//   - Set user.Meta with Created, LastModified, Version, ResourceType
-	//   - Return scim.ErrUniqueness() if userName already exists
+	//   - Return scim.ErrConflict("User", "userName") if userName already exists
 	//
 	// The created user is returned with all metadata populated.
 	CreateUser(ctx context.Context, user *scim.User) (*scim.User, error)
@@ -134,6 +145,34 @@ type Plugin interface {
 	DeleteGroup(ctx context.Context, id string) error
 }
 
+// DBProvider is an optional interface for plugins backed by a database/sql pool.
+// The gateway applies the plugin's config.PoolConfig to the returned pool and
+// exposes its connection statistics through the metrics registry.
+//
+// Plugins using sqlx can return the embedded pool:
+//
+//	func (p *MyPlugin) DB() *sql.DB { return p.db.DB }
+type DBProvider interface {
+	DB() *sql.DB
+}
+
+// UserLister is an optional interface for plugins that filter, sort and
+// paginate users natively, e.g. with SQL WHERE, ORDER BY, LIMIT and OFFSET.
+// The adapter returns the response of ListUsers as is instead of calling
+// GetUsers and applying the query to all users, so the response must hold
+// only the requested page and TotalResults must count every match.
+//
+// Plugins that cannot translate a filter should apply it themselves, for
+// example with scim.ProcessListQuery, rather than return unfiltered results.
+type UserLister interface {
+	ListUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error)
+}
+
+// GroupLister is the Group counterpart of UserLister
+type GroupLister interface {
+	ListGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error)
+}
+
 // Manager manages multiple plugins and their authentication.
 //
 // Thread Safety:
@@ -149,9 +188,32 @@ type Plugin interface {
 // Note: While Manager supports concurrent access, plugins are typically registered
 // during application startup before the HTTP server starts serving requests.
 type Manager struct {
-	plugins        map[string]Plugin
-	authenticators map[string]auth.Authenticator
-	mu             sync.RWMutex // Protects concurrent access to both maps
+	plugins           map[string]Plugin
+	authenticators    map[string]auth.Authenticator
+	entityAuth        map[string]map[string]auth.Authenticator // per plugin and base entity
+	configs           map[string]*config.PluginConfig
+	breakers          map[string]*breakerState
+	budgets           map[string]*budgetState
+	windows           map[string]*windowState
+	async             map[string]*asyncState
+	approvals         map[string]*ApprovalQueue
+	caches            map[string]*cacheState
+	cacheStore        CacheStore // shared store of the caches, nil for a memory store per plugin
+	audits            map[string]*auditState
+	recordings        map[string]*recordingState
+	auditStore        AuditStore             // shared store of the audit logs, nil for a memory store per plugin
+	quotaLocks        map[string]*sync.Mutex // serialize the creates counted against a quota
+	quotaListeners    []func(QuotaEvent)
+	quotaWarnings     []func(QuotaEvent)
+	approvalListeners []func(*Operation)
+	operations        OperationStore           // stores the operations of asynchronous writes and approvals
+	asyncWake         chan struct{}            // signals RunAsyncWrites that an operation was queued
+	clock             clock.Clock              // time tokens are validated at
+	clockSkew         time.Duration            // leeway of token time checks, zero for the default
+	httpClient        *config.HTTPClientConfig // default outbound client settings, nil for the defaults
+	normalize         *config.NormalizeConfig  // default normalization rules, nil for none
+	logger            *slog.Logger             // logs failures of wrappers such as membership sync, nil for none
+	mu                sync.RWMutex             // Protects concurrent access to all maps
 }
 
 // NewManager creates a new plugin manager
@@ -159,7 +221,95 @@ func NewManager() *Manager {
 	return &Manager{
 		plugins:        make(map[string]Plugin),
 		authenticators: make(map[string]auth.Authenticator),
+		entityAuth:     make(map[string]map[string]auth.Authenticator),
+		configs:        make(map[string]*config.PluginConfig),
+		breakers:       make(map[string]*breakerState),
+		budgets:        make(map[string]*budgetState),
+		windows:        make(map[string]*windowState),
+		async:          make(map[string]*asyncState),
+		approvals:      make(map[string]*ApprovalQueue),
+		caches:         make(map[string]*cacheState),
+		audits:         make(map[string]*auditState),
+		recordings:     make(map[string]*recordingState),
+		quotaLocks:     make(map[string]*sync.Mutex),
+		operations:     NewMemoryOperationStore(),
+		asyncWake:      make(chan struct{}, 1),
+		clock:          clock.System,
+	}
+}
+
+// SetClock sets the clock and the clock skew that token-based authenticators
+// validate tokens with, and recreates the authenticators of registered
+// plugins if either changed. A nil clock uses clock.System and a zero skew
+// the authenticator's default.
+func (m *Manager) SetClock(c clock.Clock, skew time.Duration) {
+	if c == nil {
+		c = clock.System
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.clock == c && m.clockSkew == skew {
+		return
+	}
+	m.clock = c
+	m.clockSkew = skew
+	for name, cfg := range m.configs {
+		m.applyConfig(name, cfg)
+	}
+}
+
+// SetHTTPClientConfig sets the default outbound HTTP client settings of
+// authenticators calling remote servers, used for plugins without their own
+// httpClient settings, and recreates the authenticators of registered plugins
+// if they changed. Nil uses the defaults of config.HTTPClientConfig.
+func (m *Manager) SetHTTPClientConfig(cfg *config.HTTPClientConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.httpClient == cfg || (m.httpClient != nil && cfg != nil && *m.httpClient == *cfg) {
+		return
+	}
+	m.httpClient = cfg
+	for name, pluginCfg := range m.configs {
+		m.applyConfig(name, pluginCfg)
+	}
+}
+
+// SetNormalizeConfig sets the default normalization rules of the attribute
+// values of users and groups, used for plugins without their own normalize
+// settings. Nil passes values as sent.
+func (m *Manager) SetNormalizeConfig(cfg *config.NormalizeConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.normalize = cfg
+}
+
+// getNormalizeConfig returns the normalization rules of the plugin with cfg
+func (m *Manager) getNormalizeConfig(cfg *config.PluginConfig) *config.NormalizeConfig {
+	if cfg != nil && cfg.Normalize != nil {
+		return cfg.Normalize
 	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.normalize
+}
+
+// SetLogger sets the logger of failures the wrappers applied to plugins
+// handle rather than return, such as those of membershipSync updating the
+// other side of a membership. Nil discards them.
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger = logger
+}
+
+// getLogger returns the logger set with SetLogger
+func (m *Manager) getLogger() *slog.Logger {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.logger
 }
 
 // Register registers a plugin with its configuration
@@ -168,29 +318,183 @@ func (m *Manager) Register(plugin Plugin, cfg *config.PluginConfig) {
 	defer m.mu.Unlock()
 
 	m.plugins[plugin.Name()] = plugin
+	m.applyConfig(plugin.Name(), cfg)
+}
+
+// UpdateConfig replaces the configuration and authenticator of a registered plugin.
+// Requests already being served keep the authenticator they started with; new requests
+// use the updated one. Returns false if no plugin with that name is registered.
+func (m *Manager) UpdateConfig(name string, cfg *config.PluginConfig) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.plugins[name]; !ok {
+		return false
+	}
+	m.applyConfig(name, cfg)
+	return true
+}
+
+// applyConfig stores a plugin's config and authenticator. Callers must hold m.mu.
+func (m *Manager) applyConfig(name string, cfg *config.PluginConfig) {
+	// Clear any existing authenticator and config first
+	delete(m.authenticators, name)
+	delete(m.configs, name)
 
-	// Clear any existing authenticator first
-	delete(m.authenticators, plugin.Name())
+	if cfg != nil {
+		m.configs[name] = cfg
+	}
+
+	m.applyBreakerConfig(name, cfg)
+	m.applyErrorBudgetConfig(name, cfg)
+	m.applyWindowConfig(name, cfg)
+	m.applyAsyncConfig(name, cfg)
+	m.applyApprovalConfig(name, cfg)
+	m.applyCacheConfig(name, cfg)
+	m.applyAuditConfig(name, cfg)
+	m.applyRecordingConfig(name, cfg)
 
 	// Setup authentication from config if provided
 	if cfg != nil && cfg.Auth != nil {
-		authenticator := createAuthenticator(cfg.Auth)
+		authenticator := m.createAuthenticator(cfg.Auth, cfg.HTTPClient)
 		if authenticator != nil {
-			m.authenticators[plugin.Name()] = authenticator
+			m.authenticators[name] = authenticator
+		}
+	}
+
+	delete(m.entityAuth, name)
+	if cfg == nil {
+		return
+	}
+	for _, entity := range cfg.BaseEntities {
+		if entity.Auth == nil {
+			continue
+		}
+		authenticator := m.createAuthenticator(entity.Auth, cfg.HTTPClient)
+		if authenticator == nil {
+			// Type none opens the base entity even if the plugin has auth
+			authenticator = &auth.NoAuth{}
+		}
+		if m.entityAuth[name] == nil {
+			m.entityAuth[name] = make(map[string]auth.Authenticator)
 		}
+		m.entityAuth[name][entity.Name] = authenticator
 	}
 }
 
-// createAuthenticator creates an authenticator from config
-func createAuthenticator(authCfg *config.AuthConfig) auth.Authenticator {
+// secretProvider returns provider, or a provider reading the secret from
+// file if it is set, or nil if neither is
+func secretProvider(file string, provider auth.SecretProvider) auth.SecretProvider {
+	if provider != nil {
+		return provider
+	}
+	if file != "" {
+		return auth.FileSecret(file)
+	}
+	return nil
+}
+
+// createAuthenticator creates an authenticator from config, calling remote
+// servers with a client built from httpCfg or the manager's default settings.
+// Callers must hold m.mu.
+func (m *Manager) createAuthenticator(authCfg *config.AuthConfig, httpCfg *config.HTTPClientConfig) auth.Authenticator {
 	switch authCfg.Type {
 	case "basic":
 		if authCfg.Basic != nil {
-			return auth.NewBasicAuthenticator(authCfg.Basic.Username, authCfg.Basic.Password)
+			authenticator := auth.NewBasicAuthenticator(authCfg.Basic.Username, authCfg.Basic.Password)
+			authenticator.PasswordProvider = secretProvider(authCfg.Basic.PasswordFile, authCfg.Basic.PasswordProvider)
+			for _, c := range authCfg.Basic.Credentials {
+				authenticator.Credentials = append(authenticator.Credentials, auth.Credential{
+					Label: c.Label, Username: c.Username, Secret: c.Password, ExpiresAt: c.ExpiresAt,
+					SecretProvider: secretProvider(c.PasswordFile, c.PasswordProvider),
+				})
+			}
+			authenticator.Clock = m.clock
+			return authenticator
 		}
 	case "bearer":
 		if authCfg.Bearer != nil {
-			return auth.NewBearerAuthenticator(authCfg.Bearer.Token)
+			authenticator := auth.NewBearerAuthenticator(authCfg.Bearer.Token)
+			authenticator.TokenProvider = secretProvider(authCfg.Bearer.TokenFile, authCfg.Bearer.TokenProvider)
+			for _, t := range authCfg.Bearer.Tokens {
+				authenticator.Tokens = append(authenticator.Tokens, auth.Credential{
+					Label: t.Label, Secret: t.Token, ExpiresAt: t.ExpiresAt,
+					SecretProvider: secretProvider(t.TokenFile, t.TokenProvider),
+				})
+			}
+			authenticator.Clock = m.clock
+			return authenticator
+		}
+	case "oauth2":
+		if authCfg.OAuth2 != nil {
+			if httpCfg == nil {
+				httpCfg = m.httpClient
+			}
+			client, err := httpCfg.NewClient()
+			if err != nil {
+				return rejectAuthenticator{err: err}
+			}
+			authenticator, err := oauth2.New(oauth2.Config{
+				JWKSURL:          authCfg.OAuth2.JWKSURL,
+				IntrospectionURL: authCfg.OAuth2.IntrospectionURL,
+				ClientID:         authCfg.OAuth2.ClientID,
+				ClientSecret:     authCfg.OAuth2.ClientSecret,
+				Issuer:           authCfg.OAuth2.Issuer,
+				Audience:         authCfg.OAuth2.Audience,
+				RequiredScopes:   authCfg.OAuth2.RequiredScopes,
+				CacheTTL:         authCfg.OAuth2.CacheTTL,
+				HTTPClient:       client,
+				ClockSkew:        m.clockSkew,
+				Clock:            m.clock,
+			})
+			if err != nil {
+				// Fail closed: a misconfigured OAuth2 plugin must not become unauthenticated
+				return rejectAuthenticator{err: err}
+			}
+			return authenticator
+		}
+	case "jwt":
+		if authCfg.JWT != nil {
+			if httpCfg == nil {
+				httpCfg = m.httpClient
+			}
+			client, err := httpCfg.NewClient()
+			if err != nil {
+				return rejectAuthenticator{err: err}
+			}
+			skew := authCfg.JWT.ClockSkew
+			if skew == 0 {
+				skew = m.clockSkew
+			}
+			authenticator, err := jwt.New(jwt.Config{
+				JWKSURL:       authCfg.JWT.JWKSURL,
+				PublicKeyPEM:  []byte(authCfg.JWT.PublicKey),
+				PublicKeyFile: authCfg.JWT.PublicKeyFile,
+				Algorithms:    authCfg.JWT.Algorithms,
+				Issuer:        authCfg.JWT.Issuer,
+				Audience:      authCfg.JWT.Audience,
+				SubjectClaim:  authCfg.JWT.SubjectClaim,
+				ScopesClaim:   authCfg.JWT.ScopesClaim,
+				ClockSkew:     skew,
+				CacheTTL:      authCfg.JWT.CacheTTL,
+				HTTPClient:    client,
+				Clock:         m.clock,
+			})
+			if err != nil {
+				return rejectAuthenticator{err: err}
+			}
+			return authenticator
+		}
+	case "mtls":
+		if authCfg.MTLS != nil {
+			authenticator, err := mtls.New(mtls.Config{
+				CAFile:            authCfg.MTLS.CAFile,
+				AllowedIdentities: authCfg.MTLS.AllowedIdentities,
+			})
+			if err != nil {
+				return rejectAuthenticator{err: err}
+			}
+			return authenticator
 		}
 	case "custom":
 		if authCfg.Custom != nil && authCfg.Custom.Authenticator != nil {
@@ -202,6 +506,15 @@ func createAuthenticator(authCfg *config.AuthConfig) auth.Authenticator {
 	return nil
 }
 
+// rejectAuthenticator rejects every request with a configuration error
+type rejectAuthenticator struct {
+	err error
+}
+
+func (a rejectAuthenticator) Authenticate(*http.Request) error {
+	return a.err
+}
+
 // GetAuthenticator retrieves the authenticator for a plugin by name
 func (m *Manager) GetAuthenticator(name string) (auth.Authenticator, bool) {
 	m.mu.RLock()
@@ -211,6 +524,264 @@ func (m *Manager) GetAuthenticator(name string) (auth.Authenticator, bool) {
 	return authenticator, ok
 }
 
+// GetBaseEntity retrieves the configuration of a base entity the plugin name
+// serves
+func (m *Manager) GetBaseEntity(name, baseEntity string) (*config.BaseEntityConfig, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cfg, ok := m.configs[name]
+	if !ok {
+		return nil, false
+	}
+	i := slices.IndexFunc(cfg.BaseEntities, func(entity config.BaseEntityConfig) bool { return entity.Name == baseEntity })
+	if i == -1 {
+		return nil, false
+	}
+	return &cfg.BaseEntities[i], true
+}
+
+// GetBaseEntityAuthenticator retrieves the authenticator of a base entity
+// of a plugin configured with its own auth
+func (m *Manager) GetBaseEntityAuthenticator(name, baseEntity string) (auth.Authenticator, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	authenticator, ok := m.entityAuth[name][baseEntity]
+	return authenticator, ok
+}
+
+// breakerState holds the circuit breakers of a plugin with the settings they
+// were created with
+type breakerState struct {
+	settings config.CircuitBreakerConfig
+	clock    clock.Clock
+	breakers *ResourceBreakers
+}
+
+// applyBreakerConfig creates the circuit breakers of a plugin. Breakers whose
+// settings did not change are kept, so reloading the configuration does not
+// close an open circuit. Callers must hold m.mu.
+func (m *Manager) applyBreakerConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.CircuitBreaker == nil {
+		delete(m.breakers, name)
+		return
+	}
+	if state, ok := m.breakers[name]; ok && state.settings == *cfg.CircuitBreaker && state.clock == m.clock {
+		return
+	}
+	m.breakers[name] = &breakerState{
+		settings: *cfg.CircuitBreaker,
+		clock:    m.clock,
+		breakers: NewResourceBreakers(BreakerOptions{
+			FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+			OpenDuration:     cfg.CircuitBreaker.OpenDuration,
+			Clock:            m.clock,
+		}),
+	}
+}
+
+// GetBreakers retrieves the circuit breakers of a plugin configured with
+// circuitBreaker
+func (m *Manager) GetBreakers(name string) (*ResourceBreakers, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.breakers[name]
+	if !ok {
+		return nil, false
+	}
+	return state.breakers, true
+}
+
+// windowState holds the write window of a plugin with the settings it was
+// created with
+type windowState struct {
+	settings config.WriteWindowConfig
+	clock    clock.Clock
+	window   *WriteWindow
+}
+
+// applyWindowConfig creates the write window of a plugin. A window whose
+// settings did not change is kept, and a changed window takes over the
+// writes queued in the previous one, so reloading the configuration does not
+// lose queued writes unless the writeWindow setting is removed. Callers must
+// hold m.mu.
+func (m *Manager) applyWindowConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.WriteWindow == nil {
+		delete(m.windows, name)
+		return
+	}
+
+	previous, ok := m.windows[name]
+	if ok && previous.clock == m.clock && sameWriteWindow(previous.settings, *cfg.WriteWindow) {
+		return
+	}
+	window := newWriteWindowFromConfig(name, cfg.WriteWindow, m.clock)
+	if ok {
+		previous.window.mu.Lock()
+		window.queue, previous.window.queue = previous.window.queue, nil
+		window.onFlush = previous.window.onFlush
+		previous.window.mu.Unlock()
+	}
+	m.windows[name] = &windowState{settings: *cfg.WriteWindow, clock: m.clock, window: window}
+}
+
+// sameWriteWindow reports whether two write window settings are equal
+func sameWriteWindow(a, b config.WriteWindowConfig) bool {
+	return slices.Equal(a.Schedule, b.Schedule) && a.TimeZone == b.TimeZone && a.Mode == b.Mode
+}
+
+// GetWriteWindow retrieves the write window of a plugin configured with
+// writeWindow
+func (m *Manager) GetWriteWindow(name string) (*WriteWindow, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.windows[name]
+	if !ok {
+		return nil, false
+	}
+	return state.window, true
+}
+
+// RunWriteWindows applies the writes queued by plugins configured with
+// writeWindow in queue mode when their window opens, checking every
+// WriteWindowCheckInterval until ctx is done. Gateway.Start runs it;
+// embedded gateways run it in a goroutine:
+//
+//	go gw.PluginManager().RunWriteWindows(ctx)
+func (m *Manager) RunWriteWindows(ctx context.Context) {
+	ticker := time.NewTicker(WriteWindowCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		m.mu.RLock()
+		windows := make([]*WriteWindow, 0, len(m.windows))
+		for _, state := range m.windows {
+			windows = append(windows, state.window)
+		}
+		m.mu.RUnlock()
+
+		for _, window := range windows {
+			window.Check(ctx)
+		}
+	}
+}
+
+// SetOperationStore sets the store of the operations of plugins configured
+// with asyncWrites, e.g. a FileOperationStore to keep queued writes across
+// restarts. Nil uses a new MemoryOperationStore, the default. Operations in
+// the previous store are not moved.
+func (m *Manager) SetOperationStore(store OperationStore) {
+	if store == nil {
+		store = NewMemoryOperationStore()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.operations = store
+	for name, cfg := range m.configs {
+		m.applyConfig(name, cfg)
+	}
+}
+
+// asyncState holds the asynchronous write queue of a plugin with the
+// settings it was created with
+type asyncState struct {
+	settings config.AsyncWritesConfig
+	clock    clock.Clock
+	store    OperationStore
+	queue    *AsyncQueue
+}
+
+// applyAsyncConfig creates the asynchronous write queue of a plugin. The
+// queued operations live in the operation store, so a recreated queue picks
+// them up. Callers must hold m.mu.
+func (m *Manager) applyAsyncConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.AsyncWrites == nil {
+		delete(m.async, name)
+		return
+	}
+
+	previous, ok := m.async[name]
+	if ok && previous.clock == m.clock && previous.store == m.operations && previous.settings == *cfg.AsyncWrites {
+		return
+	}
+	queue := newAsyncQueueFromConfig(name, cfg.AsyncWrites, m.operations, m.clock)
+	queue.wake = m.asyncWake
+	m.async[name] = &asyncState{settings: *cfg.AsyncWrites, clock: m.clock, store: m.operations, queue: queue}
+}
+
+// GetAsyncQueue retrieves the asynchronous write queue of a plugin
+// configured with asyncWrites
+func (m *Manager) GetAsyncQueue(name string) (*AsyncQueue, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.async[name]
+	if !ok {
+		return nil, false
+	}
+	return state.queue, true
+}
+
+// RunAsyncWrites applies the operations queued by plugins configured with
+// asyncWrites until ctx is done, when they are queued and every
+// AsyncWritesPollInterval for retries. Operations of plugins whose write
+// window is closed wait for it to open. Gateway.Start runs it; embedded
+// gateways run it in a goroutine:
+//
+//	go gw.PluginManager().RunAsyncWrites(ctx)
+func (m *Manager) RunAsyncWrites(ctx context.Context) {
+	ticker := time.NewTicker(AsyncWritesPollInterval)
+	defer ticker.Stop()
+
+	adapted := NewAdaptedManager(m)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.asyncWake:
+		}
+
+		m.mu.RLock()
+		queues := make(map[string]*AsyncQueue, len(m.async))
+		for name, state := range m.async {
+			queues[name] = state.queue
+		}
+		m.mu.RUnlock()
+
+		for name, queue := range queues {
+			if window, ok := m.GetWriteWindow(name); ok && !window.Open() {
+				continue
+			}
+			getter, ok := adapted.get(name, false, false)
+			if !ok {
+				continue
+			}
+			queue.Process(ctx, getter) // nolint:errcheck
+		}
+	}
+}
+
+// GetConfig retrieves the configuration a plugin was registered with
+func (m *Manager) GetConfig(name string) (*config.PluginConfig, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cfg, ok := m.configs[name]
+	return cfg, ok
+}
+
 // Get retrieves a plugin by name
 func (m *Manager) Get(name string) (Plugin, bool) {
 	m.mu.RLock()
@@ -3,9 +3,15 @@ package plugin
 import (
 	"context"
 	"fmt"
+	"net/http/httptest"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/marcelom97/scimgateway/auth/jwt"
+	"github.com/marcelom97/scimgateway/auth/oauth2"
+	"github.com/marcelom97/scimgateway/clock"
 	"github.com/marcelom97/scimgateway/config"
 	"github.com/marcelom97/scimgateway/scim"
 )
@@ -289,6 +295,159 @@ func TestManager_RegisterWithAuth(t *testing.T) {
 	}
 }
 
+func TestManager_RegisterWithOAuth2(t *testing.T) {
+	manager := NewManager()
+
+	manager.Register(&mockPlugin{name: "jwks"}, &config.PluginConfig{
+		Name: "jwks",
+		Auth: &config.AuthConfig{
+			Type:   "oauth2",
+			OAuth2: &config.OAuth2Auth{JWKSURL: "https://idp.example.com/jwks"},
+		},
+	})
+
+	a, ok := manager.GetAuthenticator("jwks")
+	if !ok {
+		t.Fatal("Expected authenticator to be registered")
+	}
+	if _, ok := a.(*oauth2.Authenticator); !ok {
+		t.Errorf("Expected *oauth2.Authenticator, got %T", a)
+	}
+
+	// An unusable OAuth2 config rejects requests instead of disabling auth
+	manager.Register(&mockPlugin{name: "broken"}, &config.PluginConfig{
+		Name: "broken",
+		Auth: &config.AuthConfig{
+			Type:   "oauth2",
+			OAuth2: &config.OAuth2Auth{},
+		},
+	})
+
+	a, ok = manager.GetAuthenticator("broken")
+	if !ok {
+		t.Fatal("Expected authenticator to be registered")
+	}
+	if err := a.Authenticate(httptest.NewRequest("GET", "/Users", nil)); err == nil {
+		t.Error("Expected misconfigured oauth2 authenticator to reject requests")
+	}
+}
+
+func TestManager_RegisterWithJWT(t *testing.T) {
+	manager := NewManager()
+
+	manager.Register(&mockPlugin{name: "jwks"}, &config.PluginConfig{
+		Name: "jwks",
+		Auth: &config.AuthConfig{
+			Type: "jwt",
+			JWT:  &config.JWTAuth{JWKSURL: "https://idp.example.com/jwks", Audience: "scim"},
+		},
+	})
+
+	a, ok := manager.GetAuthenticator("jwks")
+	if !ok {
+		t.Fatal("Expected authenticator to be registered")
+	}
+	if _, ok := a.(*jwt.Authenticator); !ok {
+		t.Errorf("Expected *jwt.Authenticator, got %T", a)
+	}
+
+	// A missing key file rejects requests instead of disabling auth
+	manager.Register(&mockPlugin{name: "broken"}, &config.PluginConfig{
+		Name: "broken",
+		Auth: &config.AuthConfig{
+			Type: "jwt",
+			JWT:  &config.JWTAuth{PublicKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+		},
+	})
+
+	a, ok = manager.GetAuthenticator("broken")
+	if !ok {
+		t.Fatal("Expected authenticator to be registered")
+	}
+	if err := a.Authenticate(httptest.NewRequest("GET", "/Users", nil)); err == nil {
+		t.Error("Expected misconfigured jwt authenticator to reject requests")
+	}
+}
+
+func TestManager_SetClock(t *testing.T) {
+	manager := NewManager()
+	manager.Register(&mockPlugin{name: "jwks"}, &config.PluginConfig{
+		Name: "jwks",
+		Auth: &config.AuthConfig{
+			Type:   "oauth2",
+			OAuth2: &config.OAuth2Auth{JWKSURL: "https://idp.example.com/jwks"},
+		},
+	})
+	before, _ := manager.GetAuthenticator("jwks")
+
+	// Unchanged settings keep the authenticator and its caches
+	manager.SetClock(nil, 0)
+	if a, _ := manager.GetAuthenticator("jwks"); a != before {
+		t.Error("SetClock() with unchanged settings recreated the authenticator")
+	}
+
+	manager.SetClock(clock.NewFake(time.Now()), 2*time.Minute)
+	a, _ := manager.GetAuthenticator("jwks")
+	if a == before {
+		t.Error("SetClock() did not recreate the authenticator")
+	}
+	if _, ok := a.(*oauth2.Authenticator); !ok {
+		t.Errorf("Expected *oauth2.Authenticator, got %T", a)
+	}
+}
+
+func TestManager_SetHTTPClientConfig(t *testing.T) {
+	manager := NewManager()
+	oauth := &config.AuthConfig{
+		Type:   "oauth2",
+		OAuth2: &config.OAuth2Auth{JWKSURL: "https://idp.example.com/jwks"},
+	}
+	manager.Register(&mockPlugin{name: "jwks"}, &config.PluginConfig{Name: "jwks", Auth: oauth})
+	manager.Register(&mockPlugin{name: "own"}, &config.PluginConfig{
+		Name:       "own",
+		Auth:       oauth,
+		HTTPClient: &config.HTTPClientConfig{Timeout: 5 * time.Second},
+	})
+	before, _ := manager.GetAuthenticator("jwks")
+
+	manager.SetHTTPClientConfig(nil)
+	if a, _ := manager.GetAuthenticator("jwks"); a != before {
+		t.Error("SetHTTPClientConfig() with unchanged settings recreated the authenticator")
+	}
+
+	// Unusable default settings reject requests of plugins without their own
+	manager.SetHTTPClientConfig(&config.HTTPClientConfig{CAFile: "missing-ca.pem"})
+	a, _ := manager.GetAuthenticator("jwks")
+	if err := a.Authenticate(httptest.NewRequest("GET", "/Users", nil)); err == nil {
+		t.Error("Expected an authenticator without a usable HTTP client to reject requests")
+	}
+	a, _ = manager.GetAuthenticator("own")
+	if _, ok := a.(*oauth2.Authenticator); !ok {
+		t.Errorf("plugin with its own httpClient settings got %T, want *oauth2.Authenticator", a)
+	}
+}
+
+func TestManager_RegisterWithMTLS(t *testing.T) {
+	manager := NewManager()
+
+	// A missing CA bundle rejects requests instead of disabling auth
+	manager.Register(&mockPlugin{name: "okta"}, &config.PluginConfig{
+		Name: "okta",
+		Auth: &config.AuthConfig{
+			Type: "mtls",
+			MTLS: &config.MTLSAuth{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		},
+	})
+
+	a, ok := manager.GetAuthenticator("okta")
+	if !ok {
+		t.Fatal("Expected authenticator to be registered")
+	}
+	if err := a.Authenticate(httptest.NewRequest("GET", "/Users", nil)); err == nil {
+		t.Error("Expected misconfigured mtls authenticator to reject requests")
+	}
+}
+
 func TestManager_RegisterWithAuth_NilAuth(t *testing.T) {
 	manager := NewManager()
 	plugin := &mockPlugin{name: "test"}
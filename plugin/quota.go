@@ -0,0 +1,253 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// QuotaEvent describes a create rejected because a plugin, or one of its base
+// entities, reached its quota of users or groups, or a create bringing it
+// within the warnPercent of its quota
+type QuotaEvent struct {
+	// Plugin is the name of the plugin
+	Plugin string
+
+	// BaseEntity is the base entity the quota was counted for, empty for
+	// requests to the plugin itself
+	BaseEntity string
+
+	// ResourceType is "Users" or "Groups"
+	ResourceType string
+
+	// Limit is the quota and Count the number of resources existing,
+	// including the created one for warnings
+	Limit int
+	Count int
+
+	// Subject is the client the create was rejected for, when its
+	// authenticator names it (see scim.PrincipalFromContext)
+	Subject string
+}
+
+// OnQuotaExceeded registers fn to be called for every create rejected by a
+// quota of a plugin configured with quota. Functions are called while the
+// request is served, so they must not block.
+func (m *Manager) OnQuotaExceeded(fn func(QuotaEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotaListeners = append(m.quotaListeners, fn)
+}
+
+// quotaExceeded calls the functions registered with OnQuotaExceeded
+func (m *Manager) quotaExceeded(event QuotaEvent) {
+	m.mu.RLock()
+	listeners := m.quotaListeners
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+// OnQuotaWarning registers fn to be called for every create bringing a
+// plugin configured with quota.warnPercent within that share of its quota.
+// Functions are called while the request is served, so they must not block.
+func (m *Manager) OnQuotaWarning(fn func(QuotaEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotaWarnings = append(m.quotaWarnings, fn)
+}
+
+// quotaWarning calls the functions registered with OnQuotaWarning
+func (m *Manager) quotaWarning(event QuotaEvent) {
+	m.mu.RLock()
+	listeners := m.quotaWarnings
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+// quotaLock returns the lock serializing the creates counted against the
+// quota key, so concurrent creates cannot overshoot it
+func (m *Manager) quotaLock(key string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lock, ok := m.quotaLocks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.quotaLocks[key] = lock
+	}
+	return lock
+}
+
+// hasQuota reports whether the plugin or one of its base entities is
+// configured with a quota
+func hasQuota(cfg *config.PluginConfig) bool {
+	if cfg.Quota != nil {
+		return true
+	}
+	for _, entity := range cfg.BaseEntities {
+		if entity.Quota != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaGetter rejects the creates of users and groups to a PluginGetter
+// beyond the quota of the plugin or of the request's base entity. The
+// existing resources are counted with a list request before each create, so
+// plugins scoping requests by base entity are counted per base entity.
+// Streaming and other capabilities are found by unwrapping it.
+type quotaGetter struct {
+	next    scim.PluginGetter
+	name    string
+	cfg     *config.PluginConfig
+	manager *Manager
+}
+
+// newQuotaGetter wraps next with the quotas of the plugin config cfg
+func newQuotaGetter(next scim.PluginGetter, name string, cfg *config.PluginConfig, manager *Manager) scim.PluginGetter {
+	return &quotaGetter{next: next, name: name, cfg: cfg, manager: manager}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *quotaGetter) Unwrap() any {
+	return g.next
+}
+
+// quota returns the quota applying to the request: its base entity's,
+// or the plugin's for requests without a base entity or base entities
+// without a quota of their own
+func (g *quotaGetter) quota(ctx context.Context) (config.QuotaConfig, string) {
+	entity := scim.BaseEntityFromContext(ctx)
+	if entity != "" {
+		for _, e := range g.cfg.BaseEntities {
+			if e.Name == entity && e.Quota != nil {
+				return *e.Quota, entity
+			}
+		}
+	}
+	if g.cfg.Quota == nil {
+		return config.QuotaConfig{}, entity
+	}
+	return *g.cfg.Quota, entity
+}
+
+// checked runs the create op unless limit resources of resourceType exist,
+// as counted by count. Zero limits are unlimited. Creates bringing the count
+// within warnPercent of limit are reported (see scimcontext.WarnQuota).
+func checked[T any](ctx context.Context, g *quotaGetter, resourceType string, limit, warnPercent int, entity string, count func() (int, error), op func() (T, error)) (T, error) {
+	if limit <= 0 {
+		return op()
+	}
+
+	lock := g.manager.quotaLock(g.name + "/" + entity + "/" + resourceType)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var zero T
+	n, err := count()
+	if err != nil {
+		return zero, err
+	}
+	event := QuotaEvent{Plugin: g.name, BaseEntity: entity, ResourceType: resourceType, Limit: limit, Count: n}
+	if principal, ok := scim.PrincipalFromContext(ctx); ok {
+		event.Subject = principal.Subject
+	}
+	scope := fmt.Sprintf("plugin '%s'", g.name)
+	if entity != "" {
+		scope = fmt.Sprintf("base entity '%s' of plugin '%s'", entity, g.name)
+	}
+	if n >= limit {
+		g.manager.quotaExceeded(event)
+		return zero, scim.NewSCIMErrorf(http.StatusForbidden, "",
+			"The %s quota of %s is reached: %d of %d %s exist", resourceType, scope, n, limit, resourceType)
+	}
+
+	created, err := op()
+	if err != nil || warnPercent <= 0 || (n+1)*100 < limit*warnPercent {
+		return created, err
+	}
+	event.Count = n + 1
+	g.manager.quotaWarning(event)
+	scimcontext.WarnQuota(ctx, "%d%% of the %s quota of %s is used: %d of %d %s exist",
+		event.Count*100/limit, resourceType, scope, event.Count, limit, resourceType)
+	return created, nil
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *quotaGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	quota, entity := g.quota(ctx)
+	count := func() (int, error) {
+		list, err := g.next.GetUsers(ctx, scim.QueryParams{Attributes: []string{"id"}, Count: 1})
+		if err != nil {
+			return 0, err
+		}
+		return list.TotalResults, nil
+	}
+	return checked(ctx, g, "Users", quota.MaxUsers, quota.WarnPercent, entity, count, func() (*scim.User, error) {
+		return g.next.CreateUser(ctx, user)
+	})
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *quotaGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	quota, entity := g.quota(ctx)
+	count := func() (int, error) {
+		list, err := g.next.GetGroups(ctx, scim.QueryParams{Attributes: []string{"id"}, Count: 1})
+		if err != nil {
+			return 0, err
+		}
+		return list.TotalResults, nil
+	}
+	return checked(ctx, g, "Groups", quota.MaxGroups, quota.WarnPercent, entity, count, func() (*scim.Group, error) {
+		return g.next.CreateGroup(ctx, group)
+	})
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *quotaGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return g.next.GetUsers(ctx, params)
+}
+
+// GetUser implements scim.PluginGetter
+func (g *quotaGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return g.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *quotaGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.next.ModifyUser(ctx, id, patch)
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *quotaGetter) DeleteUser(ctx context.Context, id string) error {
+	return g.next.DeleteUser(ctx, id)
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *quotaGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return g.next.GetGroups(ctx, params)
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *quotaGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return g.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *quotaGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.next.ModifyGroup(ctx, id, patch)
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *quotaGetter) DeleteGroup(ctx context.Context, id string) error {
+	return g.next.DeleteGroup(ctx, id)
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// tenantPlugin keeps the users and groups of each base entity apart
+type tenantPlugin struct {
+	mockPlugin
+	users  map[string][]*scim.User
+	groups map[string][]*scim.Group
+}
+
+func (p *tenantPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	return p.users[scim.BaseEntityFromContext(ctx)], nil
+}
+
+func (p *tenantPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	entity := scim.BaseEntityFromContext(ctx)
+	p.users[entity] = append(p.users[entity], user)
+	return user, nil
+}
+
+func (p *tenantPlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	return p.groups[scim.BaseEntityFromContext(ctx)], nil
+}
+
+func (p *tenantPlugin) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	entity := scim.BaseEntityFromContext(ctx)
+	p.groups[entity] = append(p.groups[entity], group)
+	return group, nil
+}
+
+func TestQuota(t *testing.T) {
+	p := &tenantPlugin{
+		mockPlugin: mockPlugin{name: "hr"},
+		users:      make(map[string][]*scim.User),
+		groups:     make(map[string][]*scim.Group),
+	}
+	manager := NewManager()
+	manager.Register(p, &config.PluginConfig{
+		Name:  "hr",
+		Quota: &config.QuotaConfig{MaxUsers: 2},
+		BaseEntities: []config.BaseEntityConfig{
+			{Name: "acme"},
+			{Name: "globex", Quota: &config.QuotaConfig{MaxUsers: 1, MaxGroups: 1}},
+		},
+	})
+	var events []QuotaEvent
+	manager.OnQuotaExceeded(func(e QuotaEvent) { events = append(events, e) })
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+
+	create := func(ctx context.Context, n int) error {
+		var err error
+		for range n {
+			if _, err = getter.CreateUser(ctx, &scim.User{UserName: "user"}); err != nil {
+				break
+			}
+		}
+		return err
+	}
+
+	// The plugin's quota applies to each base entity without its own
+	acme := scimcontext.WithIdentity(scim.WithBaseEntity(context.Background(), "acme"), scimcontext.AuthIdentity{Subject: "okta"})
+	if err := create(acme, 2); err != nil {
+		t.Fatalf("CreateUser() within quota error = %v", err)
+	}
+	err := create(acme, 1)
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusForbidden {
+		t.Fatalf("CreateUser() beyond quota error = %v, want 403", err)
+	}
+	if want := "Users quota of base entity 'acme' of plugin 'hr' is reached: 2 of 2"; !strings.Contains(scimErr.Detail, want) {
+		t.Errorf("detail = %q, want it to contain %q", scimErr.Detail, want)
+	}
+	if len(p.users["acme"]) != 2 {
+		t.Errorf("acme has %d users, want 2", len(p.users["acme"]))
+	}
+	want := QuotaEvent{Plugin: "hr", BaseEntity: "acme", ResourceType: "Users", Limit: 2, Count: 2, Subject: "okta"}
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("events = %+v, want [%+v]", events, want)
+	}
+
+	// Requests to the plugin itself are counted apart
+	if err := create(context.Background(), 2); err != nil {
+		t.Errorf("CreateUser() without base entity error = %v", err)
+	}
+
+	// Base entities with a quota of their own
+	globex := scim.WithBaseEntity(context.Background(), "globex")
+	if err := create(globex, 2); err == nil {
+		t.Error("CreateUser() beyond the base entity's quota succeeded")
+	}
+	if _, err := getter.CreateGroup(globex, &scim.Group{DisplayName: "Admins"}); err != nil {
+		t.Errorf("CreateGroup() within quota error = %v", err)
+	}
+	if _, err := getter.CreateGroup(globex, &scim.Group{DisplayName: "Staff"}); err == nil {
+		t.Error("CreateGroup() beyond quota succeeded")
+	}
+
+	// Groups are unlimited without maxGroups
+	for range 3 {
+		if _, err := getter.CreateGroup(acme, &scim.Group{DisplayName: "Team"}); err != nil {
+			t.Fatalf("CreateGroup() without group quota error = %v", err)
+		}
+	}
+}
+
+func TestQuotaWarning(t *testing.T) {
+	p := &tenantPlugin{
+		mockPlugin: mockPlugin{name: "hr"},
+		users:      make(map[string][]*scim.User),
+		groups:     make(map[string][]*scim.Group),
+	}
+	manager := NewManager()
+	manager.Register(p, &config.PluginConfig{
+		Name:  "hr",
+		Quota: &config.QuotaConfig{MaxUsers: 4, MaxGroups: 4, WarnPercent: 75},
+	})
+	var events []QuotaEvent
+	manager.OnQuotaWarning(func(e QuotaEvent) { events = append(events, e) })
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+
+	var warned []string
+	for i := range 4 {
+		warnings := &scimcontext.QuotaWarnings{}
+		ctx := scimcontext.WithQuotaWarnings(context.Background(), warnings)
+		if _, err := getter.CreateUser(ctx, &scim.User{UserName: "user"}); err != nil {
+			t.Fatalf("CreateUser() %d error = %v", i, err)
+		}
+		warned = append(warned, strings.Join(warnings.Warnings(), "; "))
+	}
+
+	// Creates from 3 of 4 users on are warned of
+	if warned[0] != "" || warned[1] != "" {
+		t.Errorf("warnings below 75%% = %q, want none", warned[:2])
+	}
+	if want := "75% of the Users quota of plugin 'hr' is used: 3 of 4 Users exist"; warned[2] != want {
+		t.Errorf("warning = %q, want %q", warned[2], want)
+	}
+	if want := "100% of the Users quota"; !strings.HasPrefix(warned[3], want) {
+		t.Errorf("warning = %q, want it to start with %q", warned[3], want)
+	}
+	want := QuotaEvent{Plugin: "hr", ResourceType: "Users", Limit: 4, Count: 3}
+	if len(events) != 2 || events[0] != want || events[1].Count != 4 {
+		t.Errorf("events = %+v, want counts 3 and 4", events)
+	}
+
+	// Groups are counted apart, and warnings need no collector
+	if _, err := getter.CreateGroup(context.Background(), &scim.Group{DisplayName: "Admins"}); err != nil {
+		t.Errorf("CreateGroup() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Errorf("events = %d after the first group, want 2", len(events))
+	}
+}
@@ -0,0 +1,300 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// Default settings of recorders
+const (
+	DefaultRecordingMaxExchanges = 100
+	DefaultRecordingMaxBodySize  = 64 << 10
+)
+
+// recordedHeaders are the request headers recorded with an exchange, and
+// sent again when it is replayed. Credentials such as Authorization are
+// never recorded.
+var recordedHeaders = []string{"Accept", "Accept-Language", "Content-Type", "If-Match", "If-None-Match", "User-Agent", "X-Request-Id"}
+
+// Exchange is a request to a plugin and its response, recorded by a
+// Recorder. Bodies are recorded as JSON without the values of passwords;
+// bodies that are not JSON, or were truncated, are recorded as strings.
+type Exchange struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Plugin string    `json:"plugin"`
+
+	// BaseEntity is the base entity the request was routed to, if any
+	BaseEntity string `json:"baseEntity,omitempty"`
+
+	// Subject is the authenticated client that sent the request, if known
+	Subject string `json:"subject,omitempty"`
+
+	// RequestID is the X-Request-Id of the request
+	RequestID string `json:"requestId,omitempty"`
+
+	// Method, Path and Query are those of the request, whose path does not
+	// hold the base entity segment
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Header http.Header     `json:"header,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+
+	// BodyTruncated reports that the request body was longer than
+	// recording.maxBodySize, so the exchange cannot be replayed
+	BodyTruncated bool `json:"bodyTruncated,omitempty"`
+
+	// Redacted reports that passwords were removed from the request body,
+	// so a replay does not set them
+	Redacted bool `json:"redacted,omitempty"`
+
+	Status            int             `json:"status"`
+	ResponseBody      json.RawMessage `json:"responseBody,omitempty"`
+	ResponseTruncated bool            `json:"responseTruncated,omitempty"`
+
+	// DurationMS is the time the gateway took to respond, in milliseconds
+	DurationMS int64 `json:"durationMs"`
+}
+
+// Recorder keeps the latest exchanges with a plugin in a ring buffer, and
+// optionally appends them to a JSON Lines file, so payloads sent by
+// identity providers can be inspected and replayed. It is safe for
+// concurrent use.
+type Recorder struct {
+	name     string
+	settings config.RecordingConfig
+
+	mu        sync.Mutex
+	exchanges []*Exchange // in record order
+}
+
+// NewRecorder creates the recorder of the plugin name with the settings of
+// cfg. Zero values use the defaults.
+func NewRecorder(name string, cfg config.RecordingConfig) *Recorder {
+	if cfg.MaxExchanges <= 0 {
+		cfg.MaxExchanges = DefaultRecordingMaxExchanges
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultRecordingMaxBodySize
+	}
+	return &Recorder{name: name, settings: cfg}
+}
+
+// MaxBodySize returns the bytes recorded of each body
+func (r *Recorder) MaxBodySize() int {
+	return r.settings.MaxBodySize
+}
+
+// Record records the request req with body, which was answered with
+// status and responseBody after duration. Bodies longer than MaxBodySize
+// are truncated. The exchange is kept even if it cannot be appended to the
+// file, whose error is returned.
+func (r *Recorder) Record(req *http.Request, body []byte, status int, responseBody []byte, duration time.Duration) error {
+	ctx := req.Context()
+	ex := &Exchange{
+		ID:         uuid.New().String(),
+		Time:       clock.Now(ctx),
+		Plugin:     r.name,
+		BaseEntity: scim.BaseEntityFromContext(ctx),
+		RequestID:  scimcontext.RequestID(ctx),
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		Query:      req.URL.RawQuery,
+		Status:     status,
+		DurationMS: duration.Milliseconds(),
+	}
+	if principal, ok := scim.PrincipalFromContext(ctx); ok {
+		ex.Subject = principal.Subject
+	}
+	for _, name := range recordedHeaders {
+		if values := req.Header.Values(name); len(values) > 0 {
+			if ex.Header == nil {
+				ex.Header = make(http.Header)
+			}
+			ex.Header[name] = slices.Clone(values)
+		}
+	}
+	ex.Body, ex.BodyTruncated, ex.Redacted = r.recordedBody(body)
+	ex.ResponseBody, ex.ResponseTruncated, _ = r.recordedBody(responseBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.exchanges) >= r.settings.MaxExchanges {
+		r.exchanges = slices.Delete(r.exchanges, 0, len(r.exchanges)-r.settings.MaxExchanges+1)
+	}
+	r.exchanges = append(r.exchanges, ex)
+	return r.appendToFile(ex)
+}
+
+// appendToFile appends ex to the recording file, if any. Callers must hold
+// r.mu, so that lines are not interleaved.
+func (r *Recorder) appendToFile(ex *Exchange) error {
+	if r.settings.File == "" {
+		return nil
+	}
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	// Recordings hold payloads, so only the gateway's user may read them
+	f, err := os.OpenFile(r.settings.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Exchanges returns the latest count exchanges, or all if count is not
+// positive, oldest first. Requests scoped to a base entity only see its
+// exchanges.
+func (r *Recorder) Exchanges(ctx context.Context, count int) []*Exchange {
+	entity := scim.BaseEntityFromContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var exchanges []*Exchange
+	for i := len(r.exchanges) - 1; i >= 0 && (count <= 0 || len(exchanges) < count); i-- {
+		if ex := r.exchanges[i]; entity == "" || ex.BaseEntity == entity {
+			exchanges = append(exchanges, ex)
+		}
+	}
+	slices.Reverse(exchanges)
+	return exchanges
+}
+
+// Exchange returns the exchange with id, if it is kept and visible to the
+// base entity of ctx
+func (r *Recorder) Exchange(ctx context.Context, id string) (*Exchange, bool) {
+	entity := scim.BaseEntityFromContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, ex := range r.exchanges {
+		if ex.ID == id && (entity == "" || ex.BaseEntity == entity) {
+			return ex, true
+		}
+	}
+	return nil, false
+}
+
+// recordedBody returns the recorded form of body: JSON without the values
+// of passwords, or a string if body is not JSON or longer than
+// MaxBodySize, and whether it was truncated or redacted
+func (r *Recorder) recordedBody(body []byte) (recorded json.RawMessage, truncated, redacted bool) {
+	if len(body) == 0 {
+		return nil, false, false
+	}
+	if len(body) > r.settings.MaxBodySize {
+		body, truncated = body[:r.settings.MaxBodySize], true
+	}
+	var value any
+	if !truncated && json.Unmarshal(body, &value) == nil {
+		value, redacted = redactPasswords(value)
+		if data, err := json.Marshal(value); err == nil {
+			return data, false, redacted
+		}
+	}
+	// Passwords cannot be removed from bodies that do not parse, so these
+	// are cut before the first one
+	text := string(body)
+	if i := strings.Index(strings.ToLower(text), "password"); i >= 0 {
+		text, truncated, redacted = text[:i], true, true
+	}
+	data, _ := json.Marshal(text)
+	return data, truncated, redacted
+}
+
+// redactPasswords removes the password members of the objects in value, and
+// the PATCH operations whose path is the password, and reports whether it
+// removed any
+func redactPasswords(value any) (any, bool) {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]any:
+		for name, member := range v {
+			if strings.EqualFold(name, "password") {
+				delete(v, name)
+				redacted = true
+				continue
+			}
+			var removed bool
+			v[name], removed = redactPasswords(member)
+			redacted = redacted || removed
+		}
+	case []any:
+		kept := v[:0]
+		for _, item := range v {
+			if op, ok := item.(map[string]any); ok && isPasswordPath(op["path"]) {
+				redacted = true
+				continue
+			}
+			item, removed := redactPasswords(item)
+			redacted = redacted || removed
+			kept = append(kept, item)
+		}
+		return kept, redacted
+	}
+	return value, redacted
+}
+
+// isPasswordPath reports whether path, the path of a PATCH operation, is
+// the password of a user
+func isPasswordPath(path any) bool {
+	s, ok := path.(string)
+	if !ok {
+		return false
+	}
+	_, attr := scim.SplitSchemaURN(s)
+	return strings.EqualFold(attr, "password")
+}
+
+// recordingState holds the recorder of a plugin with the settings it was
+// created with
+type recordingState struct {
+	settings config.RecordingConfig
+	recorder *Recorder
+}
+
+// applyRecordingConfig creates the recorder of a plugin. A recorder whose
+// settings are unchanged is kept with its exchanges. Callers must hold
+// m.mu.
+func (m *Manager) applyRecordingConfig(name string, cfg *config.PluginConfig) {
+	if cfg == nil || cfg.Recording == nil {
+		delete(m.recordings, name)
+		return
+	}
+	if previous, ok := m.recordings[name]; ok && previous.settings == *cfg.Recording {
+		return
+	}
+	m.recordings[name] = &recordingState{
+		settings: *cfg.Recording,
+		recorder: NewRecorder(name, *cfg.Recording),
+	}
+}
+
+// GetRecorder retrieves the recorder of a plugin configured with recording
+func (m *Manager) GetRecorder(name string) (*Recorder, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	state, ok := m.recordings[name]
+	if !ok {
+		return nil, false
+	}
+	return state.recorder, true
+}
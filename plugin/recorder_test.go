@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func recordRequest(t *testing.T, r *Recorder, ctx context.Context, method, path, body string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/scim+json")
+	if err := r.Record(req, []byte(body), http.StatusOK, []byte(`{"id":"1"}`), 0); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+}
+
+func TestRecorderKeepsLatestExchanges(t *testing.T) {
+	r := NewRecorder("hr", config.RecordingConfig{MaxExchanges: 2})
+	entity := scim.WithBaseEntity(context.Background(), "emea")
+	recordRequest(t, r, testCtx, "GET", "/hr/Users/1", "")
+	recordRequest(t, r, entity, "GET", "/hr/Users/2", "")
+	recordRequest(t, r, testCtx, "GET", "/hr/Users/3", "")
+
+	exchanges := r.Exchanges(testCtx, 0)
+	if len(exchanges) != 2 || exchanges[0].Path != "/hr/Users/2" || exchanges[1].Path != "/hr/Users/3" {
+		t.Fatalf("Exchanges() = %+v, want the latest two, oldest first", exchanges)
+	}
+	if latest := r.Exchanges(testCtx, 1); len(latest) != 1 || latest[0].Path != "/hr/Users/3" {
+		t.Errorf("Exchanges(1) = %+v, want the latest", latest)
+	}
+	if scoped := r.Exchanges(entity, 0); len(scoped) != 1 || scoped[0].BaseEntity != "emea" {
+		t.Errorf("Exchanges() of emea = %+v, want its exchange only", scoped)
+	}
+	if _, ok := r.Exchange(entity, exchanges[1].ID); ok {
+		t.Error("Exchange() found an exchange of another base entity")
+	}
+	if exchanges[0].Header.Get("Authorization") != "" || exchanges[0].Header.Get("Content-Type") == "" {
+		t.Errorf("header = %v, want Content-Type without Authorization", exchanges[0].Header)
+	}
+}
+
+func TestRecorderRedactsPasswords(t *testing.T) {
+	r := NewRecorder("hr", config.RecordingConfig{MaxBodySize: 250})
+	recordRequest(t, r, testCtx, "PATCH", "/hr/Users/1", `{"schemas":["`+scim.SchemaPatchOp+`"],"Operations":[{"op":"replace","path":"password","value":"secret"},{"op":"replace","value":{"title":"CEO","Password":"secret"}}]}`)
+	recordRequest(t, r, testCtx, "POST", "/hr/Users", `{"userName":"alice","password":"secret"`)
+	recordRequest(t, r, testCtx, "POST", "/hr/Users", `{"userName":"`+strings.Repeat("a", 300)+`"}`)
+
+	exchanges := r.Exchanges(testCtx, 0)
+	patch := string(exchanges[0].Body)
+	if !exchanges[0].Redacted || strings.Contains(patch, "secret") || !strings.Contains(patch, "CEO") {
+		t.Errorf("patch body = %s, redacted = %v, want the title without passwords", patch, exchanges[0].Redacted)
+	}
+	if invalid := string(exchanges[1].Body); strings.Contains(invalid, "secret") || !exchanges[1].BodyTruncated {
+		t.Errorf("invalid body = %s, truncated = %v, want it cut before the password", invalid, exchanges[1].BodyTruncated)
+	}
+	if long := exchanges[2]; !long.BodyTruncated || len(long.Body) > 260 {
+		t.Errorf("long body = %s, truncated = %v, want it truncated", long.Body, long.BodyTruncated)
+	}
+}
@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Default settings of Warm
+const (
+	DefaultWarmPageSize = 100
+	DefaultWarmInterval = 100 * time.Millisecond
+)
+
+// Warmer is an optional interface for plugins, and wrappers such as caches,
+// that keep resources in memory. Warm lists every user and group of the
+// plugin and passes them to it page by page, so the first reconciliation
+// after a deploy is served from memory instead of a cold backend.
+type Warmer interface {
+	WarmUsers(ctx context.Context, users []*scim.User)
+	WarmGroups(ctx context.Context, groups []*scim.Group)
+}
+
+// WarmOptions bounds the load Warm puts on a backend. Zero values use the
+// defaults.
+type WarmOptions struct {
+	// PageSize is the number of resources requested per page
+	PageSize int
+
+	// Interval is the minimum time between two page requests
+	Interval time.Duration
+
+	// Cache, if set, is primed with the listed resources too, e.g. the
+	// plugin's cache from Manager.GetCache, which wraps the plugin and is
+	// not found on it
+	Cache *Cache
+}
+
+// WarmResult counts the resources listed by Warm
+type WarmResult struct {
+	Users  int
+	Groups int
+}
+
+// Warm lists all users and groups of p to prime its caches or in-memory
+// indexes, passing them to p if it implements Warmer. Plugins implementing
+// UserLister or GroupLister are listed page by page at most once per
+// interval; other plugins return all resources from a single GetUsers or
+// GetGroups call.
+//
+// Warm stops at the first error or when ctx is cancelled and returns the
+// resources listed so far.
+func Warm(ctx context.Context, p Plugin, opts WarmOptions) (WarmResult, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultWarmPageSize
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWarmInterval
+	}
+
+	var result WarmResult
+	warmer, _ := findCapability[Warmer](p)
+
+	// Requests after the first wait for the next tick
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	first := true
+	wait := func() error {
+		if first {
+			first = false
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			return nil
+		}
+	}
+
+	users, err := warmPages(ctx, wait, opts.PageSize, p.GetUsers, asUserLister(p), func(users []*scim.User) {
+		if warmer != nil {
+			warmer.WarmUsers(ctx, users)
+		}
+		if opts.Cache != nil {
+			opts.Cache.WarmUsers(ctx, users)
+		}
+	})
+	result.Users = users
+	if err != nil {
+		return result, fmt.Errorf("failed to warm users: %w", err)
+	}
+
+	groups, err := warmPages(ctx, wait, opts.PageSize, p.GetGroups, asGroupLister(p), func(groups []*scim.Group) {
+		if warmer != nil {
+			warmer.WarmGroups(ctx, groups)
+		}
+		if opts.Cache != nil {
+			opts.Cache.WarmGroups(ctx, groups)
+		}
+	})
+	result.Groups = groups
+	if err != nil {
+		return result, fmt.Errorf("failed to warm groups: %w", err)
+	}
+	return result, nil
+}
+
+// warmPages lists all resources, page by page with list if it is not nil,
+// calling wait before every request, and passes each page to warm. It
+// returns the number of resources listed.
+func warmPages[T any](
+	ctx context.Context,
+	wait func() error,
+	pageSize int,
+	getAll func(context.Context, scim.QueryParams) ([]T, error),
+	list func(context.Context, scim.QueryParams) (*scim.ListResponse[T], error),
+	warm func([]T),
+) (int, error) {
+	if list == nil {
+		if err := wait(); err != nil {
+			return 0, err
+		}
+		resources, err := getAll(ctx, scim.QueryParams{})
+		if err != nil {
+			return 0, err
+		}
+		warm(resources)
+		return len(resources), nil
+	}
+
+	listed := 0
+	for {
+		if err := wait(); err != nil {
+			return listed, err
+		}
+		resp, err := list(ctx, scim.QueryParams{StartIndex: listed + 1, Count: pageSize})
+		if err != nil {
+			return listed, err
+		}
+		if len(resp.Resources) == 0 {
+			return listed, nil
+		}
+		warm(resp.Resources)
+		listed += len(resp.Resources)
+		if listed >= resp.TotalResults {
+			return listed, nil
+		}
+	}
+}
+
+// asUserLister returns the ListUsers method of p, or nil if p lists users
+// with GetUsers only
+func asUserLister(p Plugin) func(context.Context, scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	if lister, ok := p.(UserLister); ok {
+		return lister.ListUsers
+	}
+	return nil
+}
+
+// asGroupLister is the Group counterpart of asUserLister
+func asGroupLister(p Plugin) func(context.Context, scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	if lister, ok := p.(GroupLister); ok {
+		return lister.ListGroups
+	}
+	return nil
+}
+
+// findCapability finds an implementation of T on p, following Unwrap
+// through wrappers such as failover pairs
+func findCapability[T any](p any) (T, bool) {
+	for p != nil {
+		if capability, ok := p.(T); ok {
+			return capability, true
+		}
+		unwrapper, ok := p.(interface{ Unwrap() any })
+		if !ok {
+			break
+		}
+		p = unwrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,125 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// pagedPlugin lists users natively and records the pages it was asked for
+type pagedPlugin struct {
+	mockPlugin
+	users  []*scim.User
+	pages  []scim.QueryParams
+	err    error
+	warmed []*scim.User
+	groups int // groups warmed
+}
+
+func newPagedPlugin(n int) *pagedPlugin {
+	p := &pagedPlugin{mockPlugin: mockPlugin{name: "paged"}}
+	for i := range n {
+		p.users = append(p.users, &scim.User{ID: fmt.Sprint(i), UserName: fmt.Sprintf("user%d", i)})
+	}
+	return p
+}
+
+func (p *pagedPlugin) ListUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	p.pages = append(p.pages, params)
+	if p.err != nil {
+		return nil, p.err
+	}
+	return scim.ProcessListQuery(p.users, params)
+}
+
+func (p *pagedPlugin) WarmUsers(ctx context.Context, users []*scim.User) {
+	p.warmed = append(p.warmed, users...)
+}
+
+func (p *pagedPlugin) WarmGroups(ctx context.Context, groups []*scim.Group) {
+	p.groups += len(groups)
+}
+
+func TestWarmPages(t *testing.T) {
+	p := newPagedPlugin(25)
+
+	result, err := Warm(context.Background(), p, WarmOptions{PageSize: 10, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if result.Users != 25 || result.Groups != 0 {
+		t.Errorf("result = %+v, want 25 users", result)
+	}
+	if len(p.pages) != 3 {
+		t.Fatalf("requested %d pages, want 3", len(p.pages))
+	}
+	for i, params := range p.pages {
+		if params.StartIndex != i*10+1 || params.Count != 10 {
+			t.Errorf("page %d params = %+v", i, params)
+		}
+	}
+	if len(p.warmed) != 25 {
+		t.Errorf("warmed %d users, want 25", len(p.warmed))
+	}
+}
+
+func TestWarmRate(t *testing.T) {
+	p := newPagedPlugin(3)
+
+	start := time.Now()
+	if _, err := Warm(context.Background(), p, WarmOptions{PageSize: 1, Interval: 20 * time.Millisecond}); err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	// Three user pages and one group request, the first without waiting
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("Warm() took %v, want at least three intervals", elapsed)
+	}
+}
+
+func TestWarmWithoutLister(t *testing.T) {
+	// Plugins without native pagination are listed with a single call
+	p := &contextAwarePlugin{name: "plain"}
+	result, err := Warm(context.Background(), p, WarmOptions{PageSize: 1, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if result != (WarmResult{Users: 1, Groups: 1}) {
+		t.Errorf("result = %+v, want all resources of the plugin", result)
+	}
+}
+
+func TestWarmErrors(t *testing.T) {
+	p := newPagedPlugin(5)
+	p.err = errors.New("backend down")
+	if _, err := Warm(context.Background(), p, WarmOptions{}); !errors.Is(err, p.err) {
+		t.Errorf("Warm() error = %v, want the backend error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p = newPagedPlugin(5)
+	result, err := Warm(ctx, p, WarmOptions{PageSize: 2, Interval: time.Hour})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Warm() error = %v, want context.Canceled", err)
+	}
+	if result.Users != 2 {
+		t.Errorf("result = %+v, want the first page", result)
+	}
+}
+
+func TestWarmFailover(t *testing.T) {
+	// Warmers are found behind wrappers
+	primary := newPagedPlugin(2)
+	pair := NewFailover("hr", primary, &mockPlugin{name: "standby"}, FailoverOptions{})
+
+	if _, err := Warm(context.Background(), pair, WarmOptions{Interval: time.Millisecond}); err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+	if len(primary.warmed) != 2 {
+		t.Errorf("warmed %d users, want 2", len(primary.warmed))
+	}
+}
@@ -0,0 +1,351 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/cron"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// WriteWindowCheckInterval is how often RunWriteWindows applies the writes
+// queued for windows that opened, the resolution of cron schedules
+const WriteWindowCheckInterval = time.Minute
+
+// WriteWindowOptions configures a WriteWindow
+type WriteWindowOptions struct {
+	// Schedule lists the schedules of the minutes writes are allowed in
+	Schedule []*cron.Schedule
+
+	// Location is the time zone the schedule is in. Nil uses UTC.
+	Location *time.Location
+
+	// Queue queues writes outside the windows instead of rejecting them
+	Queue bool
+
+	// Clock tells whether a window is open. Nil uses clock.System.
+	Clock clock.Clock
+}
+
+// QueuedWrite is a write held back until a write window opens
+type QueuedWrite struct {
+	// Operation describes the write, e.g. "PATCH Users/2819c223"
+	Operation string `json:"operation"`
+
+	// QueuedAt is when the write was queued
+	QueuedAt time.Time `json:"queuedAt"`
+
+	ctx   context.Context // the context of the request that queued the write
+	apply func(context.Context) error
+}
+
+// FailedWrite is a queued write the backend rejected when it was applied
+type FailedWrite struct {
+	Operation string `json:"operation"`
+	Error     string `json:"error"`
+}
+
+// FlushResult reports the queued writes a flush applied
+type FlushResult struct {
+	Plugin  string        `json:"plugin"`
+	Applied int           `json:"applied"`
+	Failed  []FailedWrite `json:"failed,omitempty"`
+}
+
+// WriteWindow restricts the writes to a plugin's backend to the minutes of
+// a schedule, for backends that must not change during business hours.
+// Writes outside the windows are rejected with 503, or queued and applied in
+// order when the next window opens (see Check) or when flushed by an
+// operator. Failed queued writes are reported and dropped, not retried.
+// WriteWindow is safe for concurrent use.
+type WriteWindow struct {
+	name string
+	opts WriteWindowOptions
+
+	mu      sync.Mutex // protects queue and onFlush
+	queue   []QueuedWrite
+	onFlush func(FlushResult)
+	applyMu sync.Mutex // serializes flushes with writes inside windows
+}
+
+// NewWriteWindow creates the write window of the plugin name
+func NewWriteWindow(name string, opts WriteWindowOptions) *WriteWindow {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
+	return &WriteWindow{name: name, opts: opts}
+}
+
+// newWriteWindowFromConfig creates the write window of a plugin's
+// configuration, which was validated
+func newWriteWindowFromConfig(name string, cfg *config.WriteWindowConfig, c clock.Clock) *WriteWindow {
+	opts := WriteWindowOptions{Queue: cfg.Mode == config.WriteWindowQueue, Clock: c}
+	for _, expr := range cfg.Schedule {
+		if schedule, err := cron.Parse(expr); err == nil {
+			opts.Schedule = append(opts.Schedule, schedule)
+		}
+	}
+	if loc, err := time.LoadLocation(cfg.TimeZone); err == nil {
+		opts.Location = loc
+	}
+	return NewWriteWindow(name, opts)
+}
+
+// Open reports whether writes are allowed now
+func (w *WriteWindow) Open() bool {
+	now := w.opts.Clock.Now().In(w.opts.Location)
+	for _, schedule := range w.opts.Schedule {
+		if schedule.Matches(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns when the next window opens, or the zero time if none
+// does within five years
+func (w *WriteWindow) NextOpen() time.Time {
+	now := w.opts.Clock.Now().In(w.opts.Location)
+	var next time.Time
+	for _, schedule := range w.opts.Schedule {
+		if t := schedule.Next(now); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Queued returns the writes waiting for a window, oldest first
+func (w *WriteWindow) Queued() []QueuedWrite {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]QueuedWrite(nil), w.queue...)
+}
+
+// OnFlush registers a function called with the result of every flush that
+// applied queued writes, e.g. to log failures, replacing the previous one
+func (w *WriteWindow) OnFlush(fn func(FlushResult)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onFlush = fn
+}
+
+// Flush applies the queued writes in order, whether a window is open or not.
+// Writes are applied with the values of the context of the request that
+// queued them, and are cancelled with ctx.
+func (w *WriteWindow) Flush(ctx context.Context) FlushResult {
+	w.applyMu.Lock()
+	defer w.applyMu.Unlock()
+	return w.flush(ctx)
+}
+
+// flush applies the queued writes. Callers must hold w.applyMu.
+func (w *WriteWindow) flush(ctx context.Context) FlushResult {
+	w.mu.Lock()
+	queue := w.queue
+	w.queue = nil
+	w.mu.Unlock()
+
+	result := FlushResult{Plugin: w.name}
+	for i, write := range queue {
+		if ctx.Err() != nil {
+			// Keep the writes not applied yet, ahead of those queued meanwhile
+			w.mu.Lock()
+			w.queue = append(queue[i:], w.queue...)
+			w.mu.Unlock()
+			break
+		}
+
+		applyCtx, cancel := context.WithCancel(write.ctx)
+		stop := context.AfterFunc(ctx, cancel)
+		err := write.apply(applyCtx)
+		stop()
+		cancel()
+
+		if err != nil {
+			result.Failed = append(result.Failed, FailedWrite{Operation: write.Operation, Error: err.Error()})
+		} else {
+			result.Applied++
+		}
+	}
+
+	w.mu.Lock()
+	onFlush := w.onFlush
+	w.mu.Unlock()
+	if onFlush != nil && len(queue) > 0 {
+		onFlush(result)
+	}
+	return result
+}
+
+// Check applies the queued writes if a window is open. RunWriteWindows
+// calls it every minute.
+func (w *WriteWindow) Check(ctx context.Context) {
+	if !w.Open() || len(w.Queued()) == 0 {
+		return
+	}
+	w.Flush(ctx)
+}
+
+// write applies op if a window is open, after the writes queued before it,
+// and otherwise rejects or queues it
+func (w *WriteWindow) write(ctx context.Context, operation string, op func(context.Context) error) error {
+	if w.Open() {
+		w.applyMu.Lock()
+		defer w.applyMu.Unlock()
+		w.flush(ctx)
+		return op(ctx)
+	}
+
+	next := w.NextOpen()
+	if !w.opts.Queue {
+		if next.IsZero() {
+			return scim.NewSCIMErrorf(http.StatusServiceUnavailable, "", "Writes to plugin '%s' are not allowed now", w.name)
+		}
+		return scim.NewSCIMErrorf(http.StatusServiceUnavailable, "",
+			"Writes to plugin '%s' are not allowed before %s", w.name, next.Format(time.RFC3339))
+	}
+
+	w.mu.Lock()
+	w.queue = append(w.queue, QueuedWrite{
+		Operation: operation,
+		QueuedAt:  w.opts.Clock.Now(),
+		ctx:       context.WithoutCancel(ctx),
+		apply:     op,
+	})
+	w.mu.Unlock()
+
+	detail := fmt.Sprintf("%s queued until the next write window of plugin '%s'", operation, w.name)
+	if !next.IsZero() {
+		detail = fmt.Sprintf("%s queued until the write window of plugin '%s' opens at %s", operation, w.name, next.Format(time.RFC3339))
+	}
+	return &scim.AcceptedError{Detail: detail}
+}
+
+// windowGetter passes the writes to a PluginGetter through a WriteWindow.
+// Reads are not restricted; streaming and other capabilities are found by
+// unwrapping it.
+type windowGetter struct {
+	next   scim.PluginGetter
+	window *WriteWindow
+}
+
+// newWindowGetter wraps next with a write window
+func newWindowGetter(next scim.PluginGetter, window *WriteWindow) scim.PluginGetter {
+	return &windowGetter{next: next, window: window}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (g *windowGetter) Unwrap() any {
+	return g.next
+}
+
+// windowed passes a write with a result through the window. Queued writes
+// return the zero result with the *scim.AcceptedError.
+func windowed[T any](ctx context.Context, g *windowGetter, operation string, op func(context.Context) (T, error)) (T, error) {
+	var result T
+	err := g.window.write(ctx, operation, func(ctx context.Context) error {
+		var err error
+		result, err = op(ctx)
+		return err
+	})
+	return result, err
+}
+
+// GetUsers implements scim.PluginGetter
+func (g *windowGetter) GetUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return g.next.GetUsers(ctx, params)
+}
+
+// CreateUser implements scim.PluginGetter
+func (g *windowGetter) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	return windowed(ctx, g, "POST Users", func(ctx context.Context) (*scim.User, error) {
+		return g.next.CreateUser(ctx, user)
+	})
+}
+
+// GetUser implements scim.PluginGetter
+func (g *windowGetter) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return g.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements scim.PluginGetter
+func (g *windowGetter) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.window.write(ctx, "PATCH Users/"+id, func(ctx context.Context) error {
+		return g.next.ModifyUser(ctx, id, patch)
+	})
+}
+
+// DeleteUser implements scim.PluginGetter
+func (g *windowGetter) DeleteUser(ctx context.Context, id string) error {
+	return g.window.write(ctx, "DELETE Users/"+id, func(ctx context.Context) error {
+		return g.next.DeleteUser(ctx, id)
+	})
+}
+
+// ReplaceUser implements scim.UserReplacer
+func (g *windowGetter) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	return windowed(ctx, g, "PUT Users/"+id, func(ctx context.Context) (*scim.User, error) {
+		return scim.ReplaceUser(ctx, g.next, id, user)
+	})
+}
+
+// ModifyUserResult implements scim.ModifyUserResult
+func (g *windowGetter) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	return windowed(ctx, g, "PATCH Users/"+id, func(ctx context.Context) (*scim.User, error) {
+		return scim.ModifyUser(ctx, g.next, id, patch)
+	})
+}
+
+// GetGroups implements scim.PluginGetter
+func (g *windowGetter) GetGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return g.next.GetGroups(ctx, params)
+}
+
+// CreateGroup implements scim.PluginGetter
+func (g *windowGetter) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	return windowed(ctx, g, "POST Groups", func(ctx context.Context) (*scim.Group, error) {
+		return g.next.CreateGroup(ctx, group)
+	})
+}
+
+// GetGroup implements scim.PluginGetter
+func (g *windowGetter) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return g.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements scim.PluginGetter
+func (g *windowGetter) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	return g.window.write(ctx, "PATCH Groups/"+id, func(ctx context.Context) error {
+		return g.next.ModifyGroup(ctx, id, patch)
+	})
+}
+
+// DeleteGroup implements scim.PluginGetter
+func (g *windowGetter) DeleteGroup(ctx context.Context, id string) error {
+	return g.window.write(ctx, "DELETE Groups/"+id, func(ctx context.Context) error {
+		return g.next.DeleteGroup(ctx, id)
+	})
+}
+
+// ReplaceGroup implements scim.GroupReplacer
+func (g *windowGetter) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	return windowed(ctx, g, "PUT Groups/"+id, func(ctx context.Context) (*scim.Group, error) {
+		return scim.ReplaceGroup(ctx, g.next, id, group)
+	})
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult
+func (g *windowGetter) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	return windowed(ctx, g, "PATCH Groups/"+id, func(ctx context.Context) (*scim.Group, error) {
+		return scim.ModifyGroup(ctx, g.next, id, patch)
+	})
+}
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// writeLogPlugin records the writes that reached it
+type writeLogPlugin struct {
+	mockPlugin
+	writes []string
+}
+
+func (p *writeLogPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	p.writes = append(p.writes, "create "+user.UserName)
+	return user, nil
+}
+
+func (p *writeLogPlugin) DeleteUser(ctx context.Context, id string) error {
+	p.writes = append(p.writes, "delete "+id)
+	if id == "missing" {
+		return scim.ErrNotFound("User", id)
+	}
+	return nil
+}
+
+// nightlyWindow allows writes from 22:00 to 05:59 UTC
+func nightlyWindow(mode string) *config.PluginConfig {
+	return &config.PluginConfig{
+		Name:        "hr",
+		WriteWindow: &config.WriteWindowConfig{Schedule: []string{"* 22-23,0-5 * * *"}, Mode: mode},
+	}
+}
+
+func TestWriteWindowReject(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &writeLogPlugin{mockPlugin: mockPlugin{name: "hr"}}
+	manager := NewManager()
+	manager.SetClock(clk, 0)
+	manager.Register(p, nightlyWindow(""))
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	ctx := context.Background()
+
+	_, err := getter.CreateUser(ctx, &scim.User{UserName: "alice"})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("CreateUser() error = %v, want 503", err)
+	}
+	if want := "not allowed before 2025-01-15T22:00:00Z"; !strings.Contains(scimErr.Detail, want) {
+		t.Errorf("detail = %q, want it to contain %q", scimErr.Detail, want)
+	}
+
+	// Reads are not restricted
+	if _, err := getter.GetUser(ctx, "1", nil); err != nil {
+		t.Errorf("GetUser() error = %v", err)
+	}
+
+	clk.Set(time.Date(2025, 1, 15, 22, 30, 0, 0, time.UTC))
+	if _, err := getter.CreateUser(ctx, &scim.User{UserName: "alice"}); err != nil {
+		t.Errorf("CreateUser() inside the window error = %v", err)
+	}
+	if !slices.Equal(p.writes, []string{"create alice"}) {
+		t.Errorf("writes = %v, want [create alice]", p.writes)
+	}
+}
+
+func TestWriteWindowQueue(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &writeLogPlugin{mockPlugin: mockPlugin{name: "hr"}}
+	manager := NewManager()
+	manager.SetClock(clk, 0)
+	manager.Register(p, nightlyWindow(config.WriteWindowQueue))
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	window, _ := manager.GetWriteWindow("hr")
+	ctx := context.Background()
+
+	var accepted *scim.AcceptedError
+	if _, err := getter.CreateUser(ctx, &scim.User{UserName: "alice"}); !errors.As(err, &accepted) {
+		t.Fatalf("CreateUser() error = %v, want *scim.AcceptedError", err)
+	}
+	if err := getter.DeleteUser(ctx, "missing"); !errors.As(err, &accepted) {
+		t.Fatalf("DeleteUser() error = %v, want *scim.AcceptedError", err)
+	}
+	if len(p.writes) != 0 {
+		t.Fatalf("writes reached the backend outside the window: %v", p.writes)
+	}
+
+	queued := window.Queued()
+	if len(queued) != 2 || queued[0].Operation != "POST Users" || queued[1].Operation != "DELETE Users/missing" {
+		t.Fatalf("Queued() = %+v", queued)
+	}
+
+	// Reloading with other settings keeps the queue
+	manager.UpdateConfig("hr", &config.PluginConfig{
+		Name:        "hr",
+		WriteWindow: &config.WriteWindowConfig{Schedule: []string{"* 22-23 * * *"}, Mode: config.WriteWindowQueue},
+	})
+	window, _ = manager.GetWriteWindow("hr")
+	if got := len(window.Queued()); got != 2 {
+		t.Fatalf("Queued() after reload has %d writes, want 2", got)
+	}
+	getter, _ = NewAdaptedManager(manager).Get("hr")
+
+	// Check leaves the queue alone until the window opens
+	window.Check(ctx)
+	if len(p.writes) != 0 {
+		t.Fatalf("Check() applied writes outside the window: %v", p.writes)
+	}
+
+	// A write inside the window applies the queued writes first
+	var flushed FlushResult
+	window.OnFlush(func(result FlushResult) { flushed = result })
+	clk.Set(time.Date(2025, 1, 15, 22, 0, 0, 0, time.UTC))
+	if err := getter.DeleteUser(ctx, "42"); err != nil {
+		t.Fatalf("DeleteUser() inside the window error = %v", err)
+	}
+	if want := []string{"create alice", "delete missing", "delete 42"}; !slices.Equal(p.writes, want) {
+		t.Errorf("writes = %v, want %v", p.writes, want)
+	}
+	if flushed.Plugin != "hr" || flushed.Applied != 1 || len(flushed.Failed) != 1 || flushed.Failed[0].Operation != "DELETE Users/missing" {
+		t.Errorf("flush result = %+v, want 1 applied and DELETE Users/missing failed", flushed)
+	}
+	if got := len(window.Queued()); got != 0 {
+		t.Errorf("Queued() has %d writes after the flush, want 0", got)
+	}
+}
+
+func TestWriteWindowFlush(t *testing.T) {
+	clk := clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC))
+	p := &writeLogPlugin{mockPlugin: mockPlugin{name: "hr"}}
+	manager := NewManager()
+	manager.SetClock(clk, 0)
+	manager.Register(p, nightlyWindow(config.WriteWindowQueue))
+	getter, _ := NewAdaptedManager(manager).Get("hr")
+	window, _ := manager.GetWriteWindow("hr")
+
+	// Queued writes keep the values of their request's context but not its
+	// cancellation
+	type key struct{}
+	reqCtx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "req-1"))
+	getter.CreateUser(reqCtx, &scim.User{UserName: "alice"}) // nolint:errcheck
+	cancel()
+
+	if got := window.Queued()[0].ctx.Value(key{}); got != "req-1" {
+		t.Errorf("queued context value = %v, want req-1", got)
+	}
+
+	result := window.Flush(context.Background())
+	if result.Applied != 1 || !slices.Equal(p.writes, []string{"create alice"}) {
+		t.Errorf("Flush() = %+v, writes = %v", result, p.writes)
+	}
+}
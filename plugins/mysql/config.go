@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+// DefaultName is the plugin name used when Config.Name is empty
+const DefaultName = "mysql"
+
+// Config configures a MySQLPlugin
+type Config struct {
+	// Name is the plugin name, the first path segment of its endpoints.
+	// Empty uses DefaultName.
+	Name string
+
+	// DSN is the primary database, e.g.
+	// "user:password@tcp(localhost:3306)/scim"
+	DSN string
+
+	// Pool tunes the connection pools of the primary and the replica. Zero
+	// values keep the defaults: 10 open and 2 idle connections, reused for at
+	// most 3 minutes.
+	Pool config.PoolConfig
+
+	// Deletion selects hard or soft deletes. The zero value deletes rows
+	// immediately.
+	Deletion DeletionConfig
+
+	// Replica routes reads to a read replica. The zero value reads from the
+	// primary.
+	Replica ReplicaConfig
+
+	// LenientFilters restores the behavior of earlier versions for filters
+	// that cannot be parsed: the filter is left out of the query instead of
+	// failing with 400 invalidFilter. Only enable it for clients depending
+	// on it.
+	LenientFilters bool
+}
+
+// ConfigFromPluginConfig builds a Config from a gateway plugin configuration:
+// its name, pool, and these keys of its config map:
+//
+//	config:
+//	  dsn: user:password@tcp(localhost:3306)/scim
+//	  deleteMode: soft
+//	  deleteRetention: 720h
+//	  readDSN: user:password@tcp(replica:3306)/scim
+//	  readPinWindow: 10s
+//	  lenientFilters: false
+func ConfigFromPluginConfig(pc *config.PluginConfig) (Config, error) {
+	cfg := Config{Name: pc.Name}
+
+	if dsn, ok := pc.Config["dsn"]; ok {
+		cfg.DSN = fmt.Sprint(dsn)
+	}
+	if pc.Pool != nil {
+		cfg.Pool = *pc.Pool
+	}
+
+	var err error
+	if cfg.Deletion, err = DeletionConfigFromSettings(pc.Config); err != nil {
+		return cfg, err
+	}
+	if cfg.Replica, err = ReplicaConfigFromSettings(pc.Config); err != nil {
+		return cfg, err
+	}
+	if lenient, ok := pc.Config["lenientFilters"]; ok {
+		if cfg.LenientFilters, err = strconv.ParseBool(fmt.Sprint(lenient)); err != nil {
+			return cfg, fmt.Errorf("invalid lenientFilters %q: must be true or false", lenient)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+func TestConfigFromPluginConfig(t *testing.T) {
+	pc := &config.PluginConfig{
+		Name: "my",
+		Pool: &config.PoolConfig{MaxOpenConns: 25},
+		Config: map[string]any{
+			"dsn":             "scim:secret@tcp(db:3306)/scimgateway",
+			"deleteMode":      "soft",
+			"deleteRetention": "720h",
+			"readDSN":         "scim:secret@tcp(replica:3306)/scimgateway",
+		},
+	}
+
+	cfg, err := ConfigFromPluginConfig(pc)
+	if err != nil {
+		t.Fatalf("ConfigFromPluginConfig() error = %v", err)
+	}
+	if cfg.Name != "my" || cfg.DSN != "scim:secret@tcp(db:3306)/scimgateway" || cfg.Pool.MaxOpenConns != 25 {
+		t.Errorf("ConfigFromPluginConfig() = %+v", cfg)
+	}
+	if cfg.Deletion.Mode != DeleteSoft || cfg.Deletion.Retention != 720*time.Hour {
+		t.Errorf("Deletion = %+v", cfg.Deletion)
+	}
+	if cfg.Replica.ReadDSN != "scim:secret@tcp(replica:3306)/scimgateway" {
+		t.Errorf("Replica = %+v", cfg.Replica)
+	}
+
+	if cfg.LenientFilters {
+		t.Error("LenientFilters = true, want false by default")
+	}
+
+	pc.Config["lenientFilters"] = true
+	if cfg, err = ConfigFromPluginConfig(pc); err != nil || !cfg.LenientFilters {
+		t.Errorf("ConfigFromPluginConfig() with lenientFilters = %+v, %v", cfg, err)
+	}
+	pc.Config["lenientFilters"] = "sometimes"
+	if _, err := ConfigFromPluginConfig(pc); err == nil {
+		t.Error("ConfigFromPluginConfig() with an invalid lenientFilters should fail")
+	}
+	delete(pc.Config, "lenientFilters")
+
+	pc.Config["deleteMode"] = "archive"
+	if _, err := ConfigFromPluginConfig(pc); err == nil {
+		t.Error("ConfigFromPluginConfig() with an invalid deleteMode should fail")
+	}
+}
+
+func TestNewMySQLPluginRequiresDSN(t *testing.T) {
+	if _, err := NewMySQLPlugin(Config{Name: "my"}); err == nil {
+		t.Error("NewMySQLPlugin() without a DSN should fail")
+	}
+}
@@ -0,0 +1,310 @@
+//go:build integration
+
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/test"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// TestMySQLCompliance runs the SCIM compliance suite against a real MySQL
+// server started in Docker. Run with: go test -tags integration ./...
+func TestMySQLCompliance(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	test.RunComplianceSuite(t, test.NewComplianceHandler(t, p))
+}
+
+// TestMySQLListUsers verifies that filters, sorting and pagination are pushed
+// down, and that untranslatable filters fall back to in-memory filtering
+func TestMySQLListUsers(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	for i := 1; i <= 5; i++ {
+		user := &scim.User{
+			UserName: fmt.Sprintf("user%d", i),
+			Name:     &scim.Name{FamilyName: fmt.Sprintf("Family%d", 6-i)},
+			Emails:   []scim.Email{{Value: fmt.Sprintf("user%d@example.com", i)}},
+		}
+		if _, err := p.CreateUser(ctx, user); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+	// Users of other base entities are not listed
+	if _, err := p.CreateUser(scim.WithBaseEntity(ctx, "other"), &scim.User{UserName: "user9"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	page, err := p.ListUsers(ctx, scim.QueryParams{
+		Filter:     `userName sw "USER"`,
+		SortBy:     "name.familyName",
+		StartIndex: 2,
+		Count:      2,
+	})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if page.TotalResults != 5 || page.StartIndex != 2 || page.ItemsPerPage != 2 {
+		t.Errorf("ListUsers() = total %d, start %d, items %d, want 5, 2, 2",
+			page.TotalResults, page.StartIndex, page.ItemsPerPage)
+	}
+	if len(page.Resources) != 2 || page.Resources[0].UserName != "user4" || page.Resources[1].UserName != "user3" {
+		t.Errorf("ListUsers() resources = %v, want user4 and user3", page.Resources)
+	}
+
+	// Multi-valued attributes are filtered in memory
+	page, err = p.ListUsers(ctx, scim.QueryParams{Filter: `emails.value eq "user2@example.com"`})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if page.TotalResults != 1 || page.Resources[0].UserName != "user2" {
+		t.Errorf("ListUsers() = %+v, want user2", page)
+	}
+
+	if _, err := p.ListUsers(ctx, scim.QueryParams{Filter: `userName eq`}); err == nil {
+		t.Error("ListUsers() should reject an invalid filter")
+	}
+}
+
+// TestMySQLPresence verifies that "pr" pushed down to MySQL agrees with the
+// gateway's in-memory evaluator
+func TestMySQLPresence(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	test.RunPresenceConformance(t,
+		func(user *scim.User) error {
+			_, err := p.CreateUser(ctx, user)
+			return err
+		},
+		func(filter string) ([]*scim.User, error) {
+			page, err := p.ListUsers(ctx, scim.QueryParams{Filter: filter})
+			if err != nil {
+				return nil, err
+			}
+			return page.Resources, nil
+		})
+}
+
+// TestMySQLSoftDelete verifies that soft-deleted rows are hidden from reads
+// and removed by PurgeDeleted
+func TestMySQLSoftDelete(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t), Deletion: DeletionConfig{Mode: DeleteSoft}})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := p.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() should not return a deleted user")
+	}
+	if users, _ := p.ListUsers(ctx, scim.QueryParams{}); users.TotalResults != 0 {
+		t.Errorf("ListUsers() returned %d users, want none", users.TotalResults)
+	}
+	if err := p.DeleteUser(ctx, user.ID); err == nil {
+		t.Error("DeleteUser() should not delete a user twice")
+	}
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john"}); err != nil {
+		t.Errorf("CreateUser() should reuse a deleted userName: %v", err)
+	}
+
+	purged, err := p.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeDeleted() = %d, want 1", purged)
+	}
+}
+
+// TestMySQLOptimisticLocking verifies that writes carrying an expected
+// version only apply to rows still at that version
+func TestMySQLOptimisticLocking(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	group, err := p.CreateGroup(ctx, &scim.Group{DisplayName: "Admins"})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	patch := &scim.PatchOp{Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "Admins 2"}}}
+	if err := p.ModifyGroup(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`}), group.ID, patch); err != nil {
+		t.Fatalf("ModifyGroup() at the current version error = %v", err)
+	}
+
+	isPreconditionFailed := func(err error) bool {
+		var scimErr *scim.SCIMError
+		return errors.As(err, &scimErr) && scimErr.Status == 412
+	}
+
+	stale := scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`})
+	if err := p.ModifyGroup(stale, group.ID, patch); !isPreconditionFailed(err) {
+		t.Errorf("ModifyGroup() at a stale version error = %v, want 412", err)
+	}
+	if err := p.DeleteGroup(stale, group.ID); !isPreconditionFailed(err) {
+		t.Errorf("DeleteGroup() at a stale version error = %v, want 412", err)
+	}
+	if err := p.DeleteGroup(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"2"`}), group.ID); err != nil {
+		t.Errorf("DeleteGroup() at the current version error = %v", err)
+	}
+}
+
+// TestMySQLReplaceUser verifies that a replace updates the row in place,
+// keeping the user's ID and creation time
+func TestMySQLReplaceUser(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	created := *user.Meta.Created
+
+	replaced, err := p.ReplaceUser(ctx, user.ID, &scim.User{UserName: "john.smith"})
+	if err != nil {
+		t.Fatalf("ReplaceUser() error = %v", err)
+	}
+	if replaced.Meta.Version != `W/"2"` {
+		t.Errorf("meta.version = %s, want W/\"2\"", replaced.Meta.Version)
+	}
+
+	stored, err := p.GetUser(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if stored.UserName != "john.smith" || !stored.Meta.Created.Equal(created) {
+		t.Errorf("stored user = %s created %v, want john.smith created %v", stored.UserName, stored.Meta.Created, created)
+	}
+
+	if _, err := p.ReplaceUser(ctx, "missing", &scim.User{UserName: "jane"}); err == nil {
+		t.Error("ReplaceUser() of a missing user succeeded")
+	}
+}
+
+// TestMySQLTransaction verifies that writes in a transaction are isolated
+// until commit and discarded by Rollback
+func TestMySQLTransaction(t *testing.T) {
+	p, err := NewMySQLPlugin(Config{Name: "test", DSN: startMySQL(t)})
+	if err != nil {
+		t.Fatalf("NewMySQLPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	txCtx, err := p.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	user, err := p.CreateUser(txCtx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// The transaction reads its own write; other requests do not see it
+	if _, err := p.GetUser(txCtx, user.ID, nil); err != nil {
+		t.Errorf("GetUser() in the transaction error = %v", err)
+	}
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() outside the transaction found an uncommitted user")
+	}
+
+	if err := p.Rollback(txCtx); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() found a rolled back user")
+	}
+}
+
+// TestMySQLMigrationsIdempotent verifies that a second plugin on a migrated
+// database has nothing to apply
+func TestMySQLMigrationsIdempotent(t *testing.T) {
+	dsn := startMySQL(t)
+	for range 2 {
+		p, err := NewMySQLPlugin(Config{Name: "test", DSN: dsn})
+		if err != nil {
+			t.Fatalf("NewMySQLPlugin() error = %v", err)
+		}
+		p.Close() // nolint:errcheck
+	}
+
+	pending, err := PendingMigrations(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("PendingMigrations() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("PendingMigrations() = %v, want none", pending)
+	}
+}
+
+// startMySQL starts a MySQL server in Docker and returns its DSN
+func startMySQL(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := testcontainers.Run(ctx, "mysql:8.4",
+		testcontainers.WithExposedPorts("3306/tcp"),
+		testcontainers.WithEnv(map[string]string{
+			"MYSQL_ROOT_PASSWORD": "mysql",
+			"MYSQL_DATABASE":      "scimgateway",
+		}),
+		// The server only listens on the port once initialization finished
+		testcontainers.WithWaitStrategy(
+			wait.ForListeningPort("3306/tcp").WithStartupTimeout(120*time.Second),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Failed to start mysql container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			t.Logf("Failed to terminate mysql container: %v", err)
+		}
+	})
+
+	endpoint, err := container.PortEndpoint(ctx, "3306/tcp", "")
+	if err != nil {
+		t.Fatalf("Failed to get mysql endpoint: %v", err)
+	}
+	return fmt.Sprintf("root:mysql@tcp(%s)/scimgateway", endpoint)
+}
@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// userQuery returns a query builder for the live users of the request's base entity
+func userQuery(ctx context.Context) *QueryBuilder {
+	return NewQueryBuilder(usersTable, "data", UserAttributeMapping).
+		WithScope("base_entity", scim.BaseEntityFromContext(ctx)).
+		WithCondition("deleted_at IS NULL").
+		WithColumns("version")
+}
+
+// groupQuery returns a query builder for the live groups of the request's base entity
+func groupQuery(ctx context.Context) *QueryBuilder {
+	return NewQueryBuilder(groupsTable, "data", GroupAttributeMapping).
+		WithScope("base_entity", scim.BaseEntityFromContext(ctx)).
+		WithCondition("deleted_at IS NULL").
+		WithColumns("version")
+}
+
+// userFromRow returns the user stored in row with its row version, and false
+// for rows without data
+func userFromRow(row *userRow) (*scim.User, bool) {
+	if row.Data.User == nil {
+		return nil, false
+	}
+	setMetaVersion(row.Data.User.Meta, row.Version)
+	return row.Data.User, true
+}
+
+// groupFromRow returns the group stored in row with its row version, and
+// false for rows without data
+func groupFromRow(row *groupRow) (*scim.Group, bool) {
+	if row.Data.Group == nil {
+		return nil, false
+	}
+	setMetaVersion(row.Data.Group.Meta, row.Version)
+	return row.Data.Group, true
+}
+
+// ListUsers implements plugin.UserLister. The filter, sort order and page are
+// pushed down to MySQL, so only the requested page is read.
+func (p *MySQLPlugin) ListUsers(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.User], error) {
+	return listRows(ctx, p.reader(ctx), func() *QueryBuilder { return userQuery(ctx) }, params, p.lenientFilters, userFromRow)
+}
+
+// ListGroups implements plugin.GroupLister
+func (p *MySQLPlugin) ListGroups(ctx context.Context, params scim.QueryParams) (*scim.ListResponse[*scim.Group], error) {
+	return listRows(ctx, p.reader(ctx), func() *QueryBuilder { return groupQuery(ctx) }, params, p.lenientFilters, groupFromRow)
+}
+
+// listRows answers a SCIM list query with a page query and a count query. If
+// the query builder cannot translate the filter or sort order, all rows of the
+// scope are read and the query is applied in memory instead, as for plugins
+// without native pagination. Filters that cannot be parsed fail with 400
+// invalidFilter before any row is read, unless lenientFilters is set.
+func listRows[R any, T any](ctx context.Context, db sqlx.QueryerContext, newQuery func() *QueryBuilder,
+	params scim.QueryParams, lenientFilters bool, resource func(*R) (T, bool)) (*scim.ListResponse[T], error) {
+	qb := newQuery().WithFilterTrace(ctx)
+	query, args := qb.Build(params)
+	if err := qb.Err(); err != nil && !lenientFilters {
+		return nil, scim.ErrInvalidFilter(err.Error())
+	}
+	if !qb.Translated() {
+		if params.Filter != "" {
+			scimcontext.TraceFilter(ctx, "evaluated in memory on all rows")
+		}
+		all, err := queryRows(ctx, db, newQuery(), scim.QueryParams{}, resource)
+		if err != nil {
+			return nil, err
+		}
+		return scim.ProcessListQuery(all, params)
+	}
+
+	page, err := selectRows(ctx, db, query, args, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	countQuery, countArgs := newQuery().BuildCount(params)
+	if err := sqlx.GetContext(ctx, db, &total, countQuery, countArgs...); err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to count rows: %v", err))
+	}
+
+	resources, err := scim.ApplyAttributeSelection(page, params.Attributes, params.ExcludedAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex := params.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	return &scim.ListResponse[T]{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// queryRows runs the query qb builds for params and returns its resources
+func queryRows[R any, T any](ctx context.Context, db sqlx.QueryerContext, qb *QueryBuilder,
+	params scim.QueryParams, resource func(*R) (T, bool)) ([]T, error) {
+	query, args := qb.Build(params)
+	return selectRows(ctx, db, query, args, resource)
+}
+
+// selectRows runs query and converts each row to a resource, skipping rows
+// resource rejects
+func selectRows[R any, T any](ctx context.Context, db sqlx.QueryerContext, query string, args []any,
+	resource func(*R) (T, bool)) ([]T, error) {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to query: %v", err))
+	}
+	defer rows.Close() // nolint:errcheck
+
+	resources := []T{}
+	for rows.Next() {
+		var row R
+		if err := rows.StructScan(&row); err != nil {
+			return nil, scim.ErrInternalServer(fmt.Sprintf("failed to scan row: %v", err))
+		}
+		if r, ok := resource(&row); ok {
+			resources = append(resources, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to read rows: %v", err))
+	}
+	return resources, nil
+}
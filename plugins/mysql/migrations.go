@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/marcelom97/scimgateway/migrate"
+)
+
+// migrationFiles holds the schema migrations, applied in version order on startup
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLock is the named lock held while migrating
+const migrationLock = "scimgateway_schema_migrations"
+
+// applyMigrations applies the pending schema migrations, or only returns them
+// when dryRun is set.
+//
+// MySQL commits DDL statements implicitly, so a lock taken in the migration
+// transaction would be released by the migration itself. Instead a named lock
+// is held on a separate connection while migrating, so gateway replicas
+// starting together apply every migration once.
+func applyMigrations(ctx context.Context, db *sql.DB, dryRun bool) ([]migrate.Migration, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	opts := migrate.Options{Placeholder: migrate.Question, DryRun: dryRun}
+	if dryRun {
+		return migrate.Apply(ctx, db, migrations, opts)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close() // nolint:errcheck
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", migrationLock).Scan(&locked); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	if locked.Int64 != 1 {
+		return nil, fmt.Errorf("failed to lock migrations: timed out waiting for another gateway")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", migrationLock) // nolint:errcheck
+
+	return migrate.Apply(ctx, db, migrations, opts)
+}
+
+// PendingMigrations returns the schema migrations NewMySQLPlugin would apply
+// to the database at dsn, without changing it
+func PendingMigrations(ctx context.Context, dsn string) ([]migrate.Migration, error) {
+	db, err := openDB(dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() // nolint:errcheck
+
+	return applyMigrations(ctx, db.DB, true)
+}
@@ -0,0 +1,15 @@
+-- Users are JSON documents scoped by the base entity of the request that
+-- created them. Soft-deleted rows keep deleted_at until they are purged, and
+-- version is incremented by every update for compare-and-swap writes.
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR(255) NOT NULL PRIMARY KEY,
+	base_entity VARCHAR(255) NOT NULL DEFAULT '',
+	username VARCHAR(255) NOT NULL,
+	data JSON NOT NULL,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	deleted_at DATETIME(6) NULL,
+	INDEX idx_users_base_entity_username (base_entity, username),
+	INDEX idx_users_deleted_at (deleted_at)
+) CHARACTER SET utf8mb4;
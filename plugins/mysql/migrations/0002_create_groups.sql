@@ -0,0 +1,13 @@
+-- groups is a reserved word since MySQL 8.0.2 and must be quoted
+CREATE TABLE IF NOT EXISTS `groups` (
+	id VARCHAR(255) NOT NULL PRIMARY KEY,
+	base_entity VARCHAR(255) NOT NULL DEFAULT '',
+	display_name VARCHAR(255) NOT NULL,
+	data JSON NOT NULL,
+	version BIGINT NOT NULL DEFAULT 1,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	deleted_at DATETIME(6) NULL,
+	INDEX idx_groups_base_entity_display_name (base_entity, display_name),
+	INDEX idx_groups_deleted_at (deleted_at)
+) CHARACTER SET utf8mb4;
@@ -0,0 +1,491 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+const (
+	selectUsers  = "SELECT id, username, data, created_at, updated_at FROM users"
+	selectGroups = "SELECT id, display_name, data, created_at, updated_at FROM `groups`"
+	defaultOrder = " ORDER BY created_at ASC, id ASC"
+)
+
+func TestQueryBuilder_Build(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		mapping  map[string]string
+		params   scim.QueryParams
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "simple select without params",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{},
+			wantSQL:  selectUsers + defaultOrder,
+			wantArgs: []any{},
+		},
+		{
+			name:     "simple select for groups",
+			table:    groupsTable,
+			mapping:  GroupAttributeMapping,
+			params:   scim.QueryParams{},
+			wantSQL:  selectGroups + defaultOrder,
+			wantArgs: []any{},
+		},
+		{
+			name:     "filter by userName eq",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{Filter: `userName eq "John"`},
+			wantSQL:  selectUsers + " WHERE LOWER(UPPER(username)) = ?" + defaultOrder,
+			wantArgs: []any{"john"},
+		},
+		{
+			name:     "filter by displayName eq for groups",
+			table:    groupsTable,
+			mapping:  GroupAttributeMapping,
+			params:   scim.QueryParams{Filter: `displayName eq "Admins"`},
+			wantSQL:  selectGroups + " WHERE LOWER(UPPER(display_name)) = ?" + defaultOrder,
+			wantArgs: []any{"admins"},
+		},
+		{
+			name:     "filter with pagination",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{Filter: `userName eq "john"`, StartIndex: 11, Count: 10},
+			wantSQL:  selectUsers + " WHERE LOWER(UPPER(username)) = ?" + defaultOrder + " LIMIT 10 OFFSET 10",
+			wantArgs: []any{"john"},
+		},
+		{
+			name:     "sorting ascending",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{SortBy: "userName", SortOrder: "ascending"},
+			wantSQL:  selectUsers + " ORDER BY username IS NULL, username ASC, id ASC",
+			wantArgs: []any{},
+		},
+		{
+			name:     "sorting descending by JSON attribute",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{SortBy: "name.familyName", SortOrder: "descending"},
+			wantSQL:  selectUsers + ` ORDER BY JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."familyName"')) IS NULL, JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."familyName"')) DESC, id ASC`,
+			wantArgs: []any{},
+		},
+		{
+			name:     "sorting ignoring case",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{SortBy: "userName", SortOrder: "ascending", Collation: scim.CollationCaseInsensitive},
+			wantSQL:  selectUsers + " ORDER BY username IS NULL, LOWER(UPPER(username)) ASC, username ASC, id ASC",
+			wantArgs: []any{},
+		},
+		{
+			name:     "filter with unicode case folding",
+			table:    usersTable,
+			mapping:  UserAttributeMapping,
+			params:   scim.QueryParams{Filter: `userName eq "IŞIK.ΟΔΟΣ"`},
+			wantSQL:  selectUsers + " WHERE LOWER(UPPER(username)) = ?" + defaultOrder,
+			wantArgs: []any{"işik.οδοσ"},
+		},
+		{
+			name:    "filter, sorting and pagination",
+			table:   usersTable,
+			mapping: UserAttributeMapping,
+			params: scim.QueryParams{
+				Filter:     `userName sw "john"`,
+				SortBy:     "userName",
+				StartIndex: 21,
+				Count:      20,
+			},
+			wantSQL:  selectUsers + " WHERE LOWER(UPPER(username)) LIKE ? ORDER BY username IS NULL, username ASC, id ASC LIMIT 20 OFFSET 20",
+			wantArgs: []any{"john%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(tt.table, "data", tt.mapping)
+			gotSQL, gotArgs := qb.Build(tt.params)
+
+			if gotSQL != tt.wantSQL {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+			if !qb.Translated() {
+				t.Error("Translated() = false, want true")
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_BuildCount(t *testing.T) {
+	qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping).
+		WithScope("base_entity", "tenant-a").
+		WithCondition("deleted_at IS NULL")
+	gotSQL, gotArgs := qb.BuildCount(scim.QueryParams{
+		Filter:     `name.givenName co "an"`,
+		SortBy:     "userName",
+		StartIndex: 3,
+		Count:      2,
+	})
+
+	// Sorting and pagination do not change the count
+	wantSQL := `SELECT COUNT(*) FROM users WHERE base_entity = ? AND deleted_at IS NULL AND (LOWER(UPPER(JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."givenName"')))) LIKE ?)`
+	if gotSQL != wantSQL {
+		t.Errorf("BuildCount() SQL =\n%v\nwant:\n%v", gotSQL, wantSQL)
+	}
+	if want := []any{"tenant-a", "%an%"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("BuildCount() args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestQueryBuilder_FilterOperators(t *testing.T) {
+	const titleJSON = `JSON_EXTRACT(data, '$."title"')`
+	const title = "JSON_UNQUOTE(" + titleJSON + ")"
+	const active = `JSON_UNQUOTE(JSON_EXTRACT(data, '$."active"'))`
+
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "eq with string value",
+			filter:   `title eq "Engineer"`,
+			wantSQL:  "LOWER(UPPER(" + title + ")) = ?",
+			wantArgs: []any{"engineer"},
+		},
+		{
+			name:     "ne with string value",
+			filter:   `title ne "Engineer"`,
+			wantSQL:  "LOWER(UPPER(" + title + ")) <> ?",
+			wantArgs: []any{"engineer"},
+		},
+		{
+			name:     "eq with boolean",
+			filter:   `active eq true`,
+			wantSQL:  active + " = ?",
+			wantArgs: []any{"true"},
+		},
+		{
+			name:     "eq with number",
+			filter:   `level eq 3`,
+			wantSQL:  `CAST(JSON_UNQUOTE(JSON_EXTRACT(data, '$."level"')) AS DECIMAL(65,10)) = ?`,
+			wantArgs: []any{"3"},
+		},
+		{
+			name:     "eq null",
+			filter:   `title eq null`,
+			wantSQL:  title + " IS NULL",
+			wantArgs: []any{},
+		},
+		{
+			name:     "co",
+			filter:   `title co "eng"`,
+			wantSQL:  "LOWER(UPPER(" + title + ")) LIKE ?",
+			wantArgs: []any{"%eng%"},
+		},
+		{
+			name:     "sw",
+			filter:   `title sw "Eng"`,
+			wantSQL:  "LOWER(UPPER(" + title + ")) LIKE ?",
+			wantArgs: []any{"eng%"},
+		},
+		{
+			name:     "ew",
+			filter:   `title ew "neer"`,
+			wantSQL:  "LOWER(UPPER(" + title + ")) LIKE ?",
+			wantArgs: []any{"%neer"},
+		},
+		{
+			name:     "pr",
+			filter:   `title pr`,
+			wantSQL:  "COALESCE(JSON_TYPE(" + titleJSON + ") <> 'NULL' AND JSON_LENGTH(" + titleJSON + ") > 0 AND " + title + " <> '', FALSE)",
+			wantArgs: []any{},
+		},
+		{
+			name:     "gt",
+			filter:   `level gt 2`,
+			wantSQL:  `CAST(JSON_UNQUOTE(JSON_EXTRACT(data, '$."level"')) AS DECIMAL(65,10)) > ?`,
+			wantArgs: []any{"2"},
+		},
+		{
+			name:     "le",
+			filter:   `level le 2.5`,
+			wantSQL:  `CAST(JSON_UNQUOTE(JSON_EXTRACT(data, '$."level"')) AS DECIMAL(65,10)) <= ?`,
+			wantArgs: []any{"2.5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+			gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: tt.filter})
+
+			if want := selectUsers + " WHERE " + tt.wantSQL + defaultOrder; gotSQL != want {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_LogicalOperators(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "and",
+			filter:   `userName eq "john" and active eq true`,
+			wantSQL:  `(LOWER(UPPER(username)) = ? AND JSON_UNQUOTE(JSON_EXTRACT(data, '$."active"')) = ?)`,
+			wantArgs: []any{"john", "true"},
+		},
+		{
+			name:     "or",
+			filter:   `userName eq "john" or userName eq "jane"`,
+			wantSQL:  `(LOWER(UPPER(username)) = ? OR LOWER(UPPER(username)) = ?)`,
+			wantArgs: []any{"john", "jane"},
+		},
+		{
+			name:     "not",
+			filter:   `not (userName eq "john")`,
+			wantSQL:  `NOT ((LOWER(UPPER(username)) = ?))`,
+			wantArgs: []any{"john"},
+		},
+		{
+			name:     "grouped",
+			filter:   `(userName sw "j" or userName sw "a") and active eq true`,
+			wantSQL:  `(((LOWER(UPPER(username)) LIKE ? OR LOWER(UPPER(username)) LIKE ?)) AND JSON_UNQUOTE(JSON_EXTRACT(data, '$."active"')) = ?)`,
+			wantArgs: []any{"j%", "a%", "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+			gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: tt.filter})
+
+			if want := selectUsers + " WHERE " + tt.wantSQL + defaultOrder; gotSQL != want {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Untranslated(t *testing.T) {
+	tests := []struct {
+		name   string
+		params scim.QueryParams
+	}{
+		{name: "invalid filter syntax", params: scim.QueryParams{Filter: "userName eq"}},
+		{name: "unclosed quote", params: scim.QueryParams{Filter: `userName eq "john`}},
+		{name: "multi-valued attribute", params: scim.QueryParams{Filter: `emails.value eq "john@example.com"`}},
+		{name: "value path", params: scim.QueryParams{Filter: `emails[type eq "work"].value pr`}},
+		{name: "group members", params: scim.QueryParams{Filter: `members.value eq "123"`}},
+		{name: "one side of and", params: scim.QueryParams{Filter: `userName eq "john" and emails.value co "x"`}},
+		{name: "one side of or", params: scim.QueryParams{Filter: `emails.value co "x" or userName eq "john"`}},
+		{name: "co on a number", params: scim.QueryParams{Filter: `level co 3`}},
+		{name: "accent-insensitive eq", params: scim.QueryParams{Filter: `userName eq "José"`, AccentInsensitive: true}},
+		{name: "accent-insensitive sw", params: scim.QueryParams{Filter: `name.familyName sw "Mü"`, AccentInsensitive: true}},
+		{name: "sort by multi-valued attribute", params: scim.QueryParams{SortBy: "emails.value"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping).WithScope("base_entity", "")
+			gotSQL, gotArgs := qb.Build(tt.params)
+
+			if qb.Translated() {
+				t.Error("Translated() = true, want false")
+			}
+			// Untranslated parts are left out entirely, with their parameters
+			if want := selectUsers + " WHERE base_entity = ?" + defaultOrder; gotSQL != want {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+			}
+			if want := []any{""}; !reflect.DeepEqual(gotArgs, want) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_PaginationEdgeCases(t *testing.T) {
+	tests := []struct {
+		name       string
+		startIndex int
+		count      int
+		want       string
+	}{
+		{name: "no pagination", startIndex: 0, count: 0, want: ""},
+		{name: "startIndex 1 only", startIndex: 1, count: 0, want: ""},
+		{name: "count only", startIndex: 1, count: 25, want: " LIMIT 25"},
+		{name: "negative startIndex", startIndex: -5, count: 10, want: " LIMIT 10"},
+		{name: "offset without count", startIndex: 51, count: 0, want: " LIMIT 18446744073709551615 OFFSET 50"},
+		{name: "offset and count", startIndex: 101, count: 50, want: " LIMIT 50 OFFSET 100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+			gotSQL, _ := qb.Build(scim.QueryParams{StartIndex: tt.startIndex, Count: tt.count})
+
+			if want := selectUsers + defaultOrder + tt.want; gotSQL != want {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_SpecialCharacters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		wantArg string
+	}{
+		{name: "percent", filter: `userName co "50%"`, wantArg: `%50\%%`},
+		{name: "underscore", filter: `userName sw "john_"`, wantArg: `john\_%`},
+		{name: "single quote", filter: `userName eq "o'brien"`, wantArg: "o'brien"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+			_, gotArgs := qb.Build(scim.QueryParams{Filter: tt.filter})
+
+			if len(gotArgs) != 1 || gotArgs[0] != tt.wantArg {
+				t.Errorf("Build() args = %v, want [%v]", gotArgs, tt.wantArg)
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_ExtensionLayout(t *testing.T) {
+	filter := scim.SchemaEnterpriseUser + `:department eq "Sales"`
+
+	qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+	gotSQL, _ := qb.Build(scim.QueryParams{Filter: filter})
+	want := selectUsers + ` WHERE LOWER(UPPER(JSON_UNQUOTE(JSON_EXTRACT(data, '$."` + scim.SchemaEnterpriseUser + `"."department"')))) = ?` + defaultOrder
+	if gotSQL != want {
+		t.Errorf("nested Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+	}
+
+	qb = NewQueryBuilder(usersTable, "data", UserAttributeMapping).WithExtensionLayout(ExtensionFlat)
+	gotSQL, _ = qb.Build(scim.QueryParams{Filter: filter})
+	want = selectUsers + ` WHERE LOWER(UPPER(JSON_UNQUOTE(JSON_EXTRACT(data, '$."department"')))) = ?` + defaultOrder
+	if gotSQL != want {
+		t.Errorf("flat Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+	}
+}
+
+func TestQueryBuilder_ScopeConditionColumns(t *testing.T) {
+	qb := NewQueryBuilder(groupsTable, "data", GroupAttributeMapping).
+		WithScope("base_entity", "tenant-a").
+		WithCondition("deleted_at IS NULL").
+		WithColumns("version")
+	gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: `displayName sw "Adm"`, Count: 5})
+
+	want := "SELECT id, display_name, data, created_at, updated_at, version FROM `groups`" +
+		" WHERE base_entity = ? AND deleted_at IS NULL AND (LOWER(UPPER(display_name)) LIKE ?)" + defaultOrder + " LIMIT 5"
+	if gotSQL != want {
+		t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+	}
+	if want := []any{"tenant-a", "adm%"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("Build() args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestQueryBuilder_FilterTrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{
+			name:   "translated",
+			filter: `userName eq "john"`,
+			want:   []string{"translated to SQL: LOWER(UPPER(username)) = ?"},
+		},
+		{
+			name:   "dropped operand",
+			filter: `userName eq "john" and emails.value co "x"`,
+			want: []string{
+				`dropped emails.value co "x": cannot translate to SQL`,
+				"dropped and: an operand cannot be translated to SQL",
+				"filter not translated to SQL",
+			},
+		},
+		{
+			name:   "invalid filter",
+			filter: "userName eq",
+			want:   []string{"invalid filter not translated to SQL: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := &scimcontext.FilterTrace{}
+			ctx := scimcontext.WithFilterTracer(context.Background(), trace)
+			NewQueryBuilder(usersTable, "data", UserAttributeMapping).
+				WithFilterTrace(ctx).
+				Build(scim.QueryParams{Filter: tt.filter})
+
+			got := trace.Decisions()
+			if len(got) != len(tt.want) {
+				t.Fatalf("decisions = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("decisions[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Err(t *testing.T) {
+	qb := NewQueryBuilder(usersTable, "data", UserAttributeMapping)
+	qb.Build(scim.QueryParams{Filter: `emails[type eq "work"].value pr`})
+	if err := qb.Err(); err != nil {
+		t.Errorf("Err() for a valid filter = %v, want nil", err)
+	}
+
+	qb.BuildCount(scim.QueryParams{Filter: `userName eq "john`})
+	var invalid *InvalidFilterError
+	if err := qb.Err(); !errors.As(err, &invalid) || invalid.Filter != `userName eq "john` {
+		t.Errorf("Err() for an invalid filter = %v, want an *InvalidFilterError", err)
+	}
+}
+
+// TestInvalidFilterRejected verifies that an invalid filter fails before the
+// database is queried, instead of listing every row
+func TestInvalidFilterRejected(t *testing.T) {
+	p := &MySQLPlugin{}
+	_, err := p.ListUsers(context.Background(), scim.QueryParams{Filter: `userName eq`})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusBadRequest || scimErr.ScimType != scim.ScimTypeInvalidFilter {
+		t.Errorf("ListUsers() error = %v, want 400 invalidFilter", err)
+	}
+}
@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// DefaultPinWindow is how long reads stay on the primary after a write when
+// ReplicaConfig.PinWindow is zero
+const DefaultPinWindow = 5 * time.Second
+
+// ReplicaConfig routes reads to a read replica while writes go to the primary
+// database of Config.DSN
+type ReplicaConfig struct {
+	// ReadDSN is the replica's connection string. Empty reads from the
+	// primary.
+	ReadDSN string
+
+	// PinWindow is how long reads in a base entity go to the primary after a
+	// write in it, so that clients read their own writes despite replication
+	// lag. Zero uses DefaultPinWindow.
+	PinWindow time.Duration
+}
+
+// ReplicaConfigFromSettings reads the replica settings from a plugin's
+// config.PluginConfig.Config map:
+//
+//	config:
+//	  readDSN: scim:secret@tcp(replica:3306)/scimgateway
+//	  readPinWindow: 10s      # read from the primary for 10s after a write
+func ReplicaConfigFromSettings(settings map[string]any) (ReplicaConfig, error) {
+	var cfg ReplicaConfig
+
+	if dsn, ok := settings["readDSN"]; ok {
+		cfg.ReadDSN = fmt.Sprint(dsn)
+	}
+
+	if window, ok := settings["readPinWindow"]; ok {
+		d, err := time.ParseDuration(fmt.Sprint(window))
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid readPinWindow %q: must be a non-negative duration", window)
+		}
+		cfg.PinWindow = d
+	}
+
+	return cfg, nil
+}
+
+// openReplica opens the configured read replica. It is a no-op without a
+// ReadDSN.
+func (p *MySQLPlugin) openReplica() error {
+	if p.replicaConfig.ReadDSN == "" {
+		return nil
+	}
+
+	replica, err := openDB(p.replicaConfig.ReadDSN)
+	if err != nil {
+		return fmt.Errorf("failed to open read replica: %w", err)
+	}
+
+	replica.SetConnMaxLifetime(time.Minute * 3)
+	replica.SetMaxOpenConns(10)
+	replica.SetMaxIdleConns(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := replica.PingContext(ctx); err != nil {
+		replica.Close() // nolint:errcheck
+		return fmt.Errorf("failed to ping read replica: %w", err)
+	}
+
+	window := p.replicaConfig.PinWindow
+	if window <= 0 {
+		window = DefaultPinWindow
+	}
+	p.replica = replica
+	p.pins = newWritePins(window)
+	return nil
+}
+
+// reader returns the database to read from: the transaction started by
+// Begin, or else the replica, unless there is none or the request's base
+// entity was written to within the pin window
+func (p *MySQLPlugin) reader(ctx context.Context) dbtx {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	if p.replica == nil || p.pins.pinned(scim.BaseEntityFromContext(ctx)) {
+		return p.db
+	}
+	return p.replica
+}
+
+// markWrite pins reads in the request's base entity to the primary
+func (p *MySQLPlugin) markWrite(ctx context.Context) {
+	if p.replica != nil {
+		p.pins.mark(scim.BaseEntityFromContext(ctx))
+	}
+}
+
+// writePins records when each session last wrote. A session is a base entity,
+// so a client reading right after its own create or update sees the change.
+type writePins struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   map[string]time.Time
+	now    func() time.Time
+}
+
+func newWritePins(window time.Duration) *writePins {
+	return &writePins{
+		window: window,
+		last:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// mark records a write in session and forgets sessions whose pin expired
+func (w *writePins) mark(session string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := w.now()
+	for s, t := range w.last {
+		if now.Sub(t) >= w.window {
+			delete(w.last, s)
+		}
+	}
+	w.last[session] = now
+}
+
+// pinned reports whether session wrote within the pin window
+func (w *writePins) pinned(session string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	t, ok := w.last[session]
+	return ok && w.now().Sub(t) < w.window
+}
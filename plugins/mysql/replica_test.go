@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplicaConfigFromSettings(t *testing.T) {
+	dsn := "scim:secret@tcp(replica:3306)/scimgateway"
+	cfg, err := ReplicaConfigFromSettings(map[string]any{"readDSN": dsn, "readPinWindow": "10s"})
+	if err != nil {
+		t.Fatalf("ReplicaConfigFromSettings() error = %v", err)
+	}
+	if cfg.ReadDSN != dsn || cfg.PinWindow != 10*time.Second {
+		t.Errorf("ReplicaConfigFromSettings() = %+v", cfg)
+	}
+
+	if cfg, err := ReplicaConfigFromSettings(nil); err != nil || cfg.ReadDSN != "" {
+		t.Errorf("ReplicaConfigFromSettings(nil) = %+v, %v, want no replica", cfg, err)
+	}
+
+	for _, window := range []string{"soon", "-1s"} {
+		if _, err := ReplicaConfigFromSettings(map[string]any{"readPinWindow": window}); err == nil {
+			t.Errorf("ReplicaConfigFromSettings() with readPinWindow %q should fail", window)
+		}
+	}
+}
+
+func TestWritePins(t *testing.T) {
+	now := time.Now()
+	pins := newWritePins(5 * time.Second)
+	pins.now = func() time.Time { return now }
+
+	pins.mark("tenant-a")
+	if !pins.pinned("tenant-a") {
+		t.Error("tenant-a should be pinned right after a write")
+	}
+	if pins.pinned("tenant-b") {
+		t.Error("tenant-b should not be pinned by a write in tenant-a")
+	}
+
+	now = now.Add(5 * time.Second)
+	if pins.pinned("tenant-a") {
+		t.Error("tenant-a should not be pinned after the window")
+	}
+
+	// Expired sessions are forgotten on the next write
+	pins.mark("tenant-b")
+	if _, ok := pins.last["tenant-a"]; ok || len(pins.last) != 1 {
+		t.Errorf("pins = %v, want only tenant-b", pins.last)
+	}
+}
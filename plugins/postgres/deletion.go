@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scheduler"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// DeleteMode selects what DELETE requests do to rows
+type DeleteMode string
+
+const (
+	// DeleteHard removes rows immediately (the default)
+	DeleteHard DeleteMode = "hard"
+
+	// DeleteSoft marks rows with a deleted_at timestamp and hides them from
+	// every read. The purge job removes them once the retention period passed.
+	DeleteSoft DeleteMode = "soft"
+)
+
+// DefaultPurgeInterval is how often soft-deleted rows past their retention
+// period are purged
+const DefaultPurgeInterval = time.Hour
+
+// DeletionConfig configures how the plugin deletes rows
+type DeletionConfig struct {
+	Mode DeleteMode
+
+	// Retention is how long soft-deleted rows are kept before they are purged.
+	// Zero keeps them until PurgeDeleted is called.
+	Retention time.Duration
+
+	// PurgeInterval is how often the purge job runs. Zero uses DefaultPurgeInterval.
+	PurgeInterval time.Duration
+}
+
+// DeletionConfigFromSettings reads the deletion settings from a plugin's
+// config.PluginConfig.Config map:
+//
+//	config:
+//	  deleteMode: soft        # hard (default) or soft
+//	  deleteRetention: 720h   # purge soft-deleted rows after 30 days
+func DeletionConfigFromSettings(settings map[string]any) (DeletionConfig, error) {
+	cfg := DeletionConfig{Mode: DeleteHard}
+
+	if mode, ok := settings["deleteMode"]; ok {
+		switch DeleteMode(fmt.Sprint(mode)) {
+		case DeleteHard, "":
+		case DeleteSoft:
+			cfg.Mode = DeleteSoft
+		default:
+			return cfg, fmt.Errorf("invalid deleteMode %q: must be 'hard' or 'soft'", mode)
+		}
+	}
+
+	if retention, ok := settings["deleteRetention"]; ok {
+		d, err := time.ParseDuration(fmt.Sprint(retention))
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid deleteRetention %q: must be a non-negative duration", retention)
+		}
+		cfg.Retention = d
+	}
+
+	return cfg, nil
+}
+
+// deleteRow deletes the row with id in the request's base entity, or marks it
+// deleted in soft delete mode. It returns the number of rows affected.
+func (p *PostgresPlugin) deleteRow(ctx context.Context, table, id string) (int64, error) {
+	baseEntity := scim.BaseEntityFromContext(ctx)
+
+	// Only delete an unchanged row for conditional writes
+	condition := "id = ? AND base_entity = ? AND deleted_at IS NULL"
+	args := []any{id, baseEntity}
+	if expected, ok := expectedVersion(ctx); ok {
+		condition += " AND version = ?"
+		args = append(args, expected)
+	}
+
+	var result sql.Result
+	var err error
+	if p.deletion.Mode == DeleteSoft {
+		result, err = p.writer(ctx).ExecContext(ctx,
+			p.db.Rebind("UPDATE "+table+" SET deleted_at = ? WHERE "+condition),
+			append([]any{clock.Now(ctx)}, args...)...)
+	} else {
+		result, err = p.writer(ctx).ExecContext(ctx, p.db.Rebind("DELETE FROM "+table+" WHERE "+condition), args...)
+	}
+	if err != nil {
+		return 0, err
+	}
+	p.markWrite(ctx)
+	return result.RowsAffected()
+}
+
+// PurgeDeleted permanently removes rows soft-deleted before the given time,
+// across all base entities, and returns how many were removed
+func (p *PostgresPlugin) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	for _, table := range []string{"users", "groups"} {
+		result, err := p.db.ExecContext(ctx,
+			"DELETE FROM "+table+" WHERE deleted_at IS NOT NULL AND deleted_at < $1", before)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return purged, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+// Jobs returns the job purging rows soft-deleted past the retention period,
+// "purge-deleted", when soft delete mode has a retention. The gateway runs
+// it on its scheduler (plugin.JobProvider).
+func (p *PostgresPlugin) Jobs() []scheduler.Job {
+	if p.deletion.Mode != DeleteSoft || p.deletion.Retention <= 0 {
+		return nil
+	}
+	interval := p.deletion.PurgeInterval
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	return []scheduler.Job{{
+		Name:     "purge-deleted",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			_, err := p.PurgeDeleted(ctx, clock.Now(ctx).Add(-p.deletion.Retention))
+			return err
+		},
+	}}
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletionConfigFromSettings(t *testing.T) {
+	cfg, err := DeletionConfigFromSettings(map[string]any{"deleteMode": "soft", "deleteRetention": "720h"})
+	if err != nil {
+		t.Fatalf("DeletionConfigFromSettings() error = %v", err)
+	}
+	if cfg.Mode != DeleteSoft || cfg.Retention != 720*time.Hour {
+		t.Errorf("DeletionConfigFromSettings() = %+v", cfg)
+	}
+
+	if cfg, err := DeletionConfigFromSettings(nil); err != nil || cfg.Mode != DeleteHard {
+		t.Errorf("DeletionConfigFromSettings(nil) = %+v, %v, want hard deletion", cfg, err)
+	}
+
+	for _, settings := range []map[string]any{
+		{"deleteMode": "archive"},
+		{"deleteRetention": "30 days"},
+		{"deleteRetention": "-1h"},
+	} {
+		if _, err := DeletionConfigFromSettings(settings); err == nil {
+			t.Errorf("DeletionConfigFromSettings(%v) should fail", settings)
+		}
+	}
+}
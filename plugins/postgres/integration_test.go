@@ -0,0 +1,249 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/test"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// TestPostgresCompliance runs the SCIM compliance suite against a real PostgreSQL
+// server started in Docker. Run with: go test -tags integration ./...
+func TestPostgresCompliance(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	test.RunComplianceSuite(t, test.NewComplianceHandler(t, p))
+}
+
+// TestPostgresPresence verifies that "pr" pushed down to PostgreSQL agrees
+// with the gateway's in-memory evaluator
+func TestPostgresPresence(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	test.RunPresenceConformance(t,
+		func(user *scim.User) error {
+			_, err := p.CreateUser(ctx, user)
+			return err
+		},
+		func(filter string) ([]*scim.User, error) {
+			return p.GetUsers(ctx, scim.QueryParams{Filter: filter})
+		})
+}
+
+// TestPostgresSoftDelete verifies that soft-deleted rows are hidden from reads
+// and removed by PurgeDeleted
+func TestPostgresSoftDelete(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t), Deletion: DeletionConfig{Mode: DeleteSoft}})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := p.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() should not return a deleted user")
+	}
+	if users, _ := p.GetUsers(ctx, scim.QueryParams{}); len(users) != 0 {
+		t.Errorf("GetUsers() returned %d users, want none", len(users))
+	}
+	if err := p.DeleteUser(ctx, user.ID); err == nil {
+		t.Error("DeleteUser() should not delete a user twice")
+	}
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john"}); err != nil {
+		t.Errorf("CreateUser() should reuse a deleted userName: %v", err)
+	}
+
+	purged, err := p.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeDeleted() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeDeleted() = %d, want 1", purged)
+	}
+}
+
+// TestPostgresOptimisticLocking verifies that writes carrying an expected
+// version only apply to rows still at that version
+func TestPostgresOptimisticLocking(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	group, err := p.CreateGroup(ctx, &scim.Group{DisplayName: "Admins"})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	patch := &scim.PatchOp{Operations: []scim.PatchOperation{{Op: "replace", Path: "displayName", Value: "Admins 2"}}}
+	if err := p.ModifyGroup(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`}), group.ID, patch); err != nil {
+		t.Fatalf("ModifyGroup() at the current version error = %v", err)
+	}
+
+	isPreconditionFailed := func(err error) bool {
+		var scimErr *scim.SCIMError
+		return errors.As(err, &scimErr) && scimErr.Status == 412
+	}
+
+	stale := scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"1"`})
+	if err := p.ModifyGroup(stale, group.ID, patch); !isPreconditionFailed(err) {
+		t.Errorf("ModifyGroup() at a stale version error = %v, want 412", err)
+	}
+	if err := p.DeleteGroup(stale, group.ID); !isPreconditionFailed(err) {
+		t.Errorf("DeleteGroup() at a stale version error = %v, want 412", err)
+	}
+	if err := p.DeleteGroup(scim.WithPrecondition(ctx, scim.Precondition{Version: `W/"2"`}), group.ID); err != nil {
+		t.Errorf("DeleteGroup() at the current version error = %v", err)
+	}
+}
+
+// TestPostgresReplaceUser verifies that a replace updates the row in place,
+// keeping the user's ID and creation time
+func TestPostgresReplaceUser(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	user, err := p.CreateUser(ctx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	created := *user.Meta.Created
+
+	replaced, err := p.ReplaceUser(ctx, user.ID, &scim.User{UserName: "john.smith"})
+	if err != nil {
+		t.Fatalf("ReplaceUser() error = %v", err)
+	}
+	if replaced.Meta.Version != `W/"2"` {
+		t.Errorf("meta.version = %s, want W/\"2\"", replaced.Meta.Version)
+	}
+
+	stored, err := p.GetUser(ctx, user.ID, nil)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if stored.UserName != "john.smith" || !stored.Meta.Created.Equal(created) {
+		t.Errorf("stored user = %s created %v, want john.smith created %v", stored.UserName, stored.Meta.Created, created)
+	}
+
+	if _, err := p.ReplaceUser(ctx, "missing", &scim.User{UserName: "jane"}); err == nil {
+		t.Error("ReplaceUser() of a missing user succeeded")
+	}
+}
+
+func TestPostgresExternalIDConflict(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john", ExternalID: "e1"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// The index rejects writes that passed the server's lookup concurrently
+	_, err = p.CreateUser(ctx, &scim.User{UserName: "jane", ExternalID: "e1"})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != 409 {
+		t.Errorf("CreateUser() with a taken externalId error = %v, want 409", err)
+	}
+	if _, err := p.CreateUser(scim.WithBaseEntity(ctx, "acme"), &scim.User{UserName: "jane", ExternalID: "e1"}); err != nil {
+		t.Errorf("CreateUser() in another base entity error = %v", err)
+	}
+}
+
+// startPostgres starts a PostgreSQL server in Docker and returns its connection string
+func TestPostgresTransaction(t *testing.T) {
+	p, err := NewPostgresPlugin(Config{Name: "test", DSN: startPostgres(t)})
+	if err != nil {
+		t.Fatalf("NewPostgresPlugin() error = %v", err)
+	}
+	t.Cleanup(func() { p.Close() }) // nolint:errcheck
+
+	ctx := context.Background()
+	txCtx, err := p.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	user, err := p.CreateUser(txCtx, &scim.User{UserName: "john"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	// The transaction reads its own write; other requests do not see it
+	if _, err := p.GetUser(txCtx, user.ID, nil); err != nil {
+		t.Errorf("GetUser() in the transaction error = %v", err)
+	}
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() outside the transaction found an uncommitted user")
+	}
+
+	if err := p.Rollback(txCtx); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := p.GetUser(ctx, user.ID, nil); err == nil {
+		t.Error("GetUser() found a rolled back user")
+	}
+}
+
+func startPostgres(t *testing.T) string {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx, "postgres:16-alpine",
+		postgres.WithDatabase("scimgateway"),
+		postgres.WithUsername("postgres"),
+		postgres.WithPassword("postgres"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(60*time.Second),
+		),
+	)
+	if err != nil {
+		t.Fatalf("Failed to start postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			t.Logf("Failed to terminate postgres container: %v", err)
+		}
+	})
+
+	connStr, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to get connection string: %v", err)
+	}
+	return connStr
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/migrate"
+)
+
+// migrationFiles holds the schema migrations, applied in version order on startup
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// applyMigrations applies the pending schema migrations, or only returns them
+// when dryRun is set. Each migration locks the version table, so gateway
+// replicas starting together apply every migration once.
+func applyMigrations(ctx context.Context, db *sql.DB, dryRun bool) ([]migrate.Migration, error) {
+	migrations, err := migrate.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Apply(ctx, db, migrations, migrate.Options{
+		Placeholder: migrate.Dollar,
+		Lock:        "LOCK TABLE " + migrate.DefaultTable + " IN EXCLUSIVE MODE",
+		DryRun:      dryRun,
+	})
+}
+
+// PendingMigrations returns the schema migrations NewPostgresPlugin would apply
+// to the database at connStr, without changing it
+func PendingMigrations(ctx context.Context, connStr string) ([]migrate.Migration, error) {
+	db, err := sqlx.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close() // nolint:errcheck
+
+	return applyMigrations(ctx, db.DB, true)
+}
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	username TEXT NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+-- GIN index for efficient JSONB queries
+CREATE INDEX IF NOT EXISTS idx_users_data ON users USING GIN(data);
+
+CREATE TABLE IF NOT EXISTS groups (
+	id TEXT PRIMARY KEY,
+	display_name TEXT NOT NULL,
+	data JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_groups_display_name ON groups(display_name);
+CREATE INDEX IF NOT EXISTS idx_groups_data ON groups USING GIN(data);
@@ -0,0 +1,8 @@
+-- Row-level tenancy: rows belong to the base entity of the request that created them
+ALTER TABLE users ADD COLUMN IF NOT EXISTS base_entity TEXT NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS idx_users_username;
+CREATE INDEX IF NOT EXISTS idx_users_base_entity_username ON users(base_entity, username);
+
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS base_entity TEXT NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS idx_groups_display_name;
+CREATE INDEX IF NOT EXISTS idx_groups_base_entity_display_name ON groups(base_entity, display_name);
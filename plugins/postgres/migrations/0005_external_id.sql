@@ -0,0 +1,4 @@
+-- externalId lookups (scim.ExternalIDLookup) of the rows of a base entity,
+-- unique so that concurrent writes cannot assign an externalId twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_id ON users(base_entity, (data->>'externalId')) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_groups_external_id ON groups(base_entity, (data->>'externalId')) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Case-insensitive displayName lookups (scim.GroupDisplayNameLookup) of the
+-- groups of a base entity
+CREATE INDEX IF NOT EXISTS idx_groups_lower_display_name ON groups(base_entity, lower(display_name)) WHERE deleted_at IS NULL;
@@ -0,0 +1,3 @@
+-- Case-insensitive userName lookups (scim.UserNameLookup) of the users of a
+-- base entity
+CREATE INDEX IF NOT EXISTS idx_users_lower_username ON users(base_entity, lower(username)) WHERE deleted_at IS NULL;
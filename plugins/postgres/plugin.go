@@ -0,0 +1,703 @@
+// Package postgres implements a PostgreSQL storage plugin for the gateway.
+//
+// Users and groups are stored as JSON documents. Filters and sorting are
+// pushed down to SQL, and the schema is migrated when the plugin is created:
+//
+//	p, err := postgres.NewPostgresPlugin(postgres.Config{
+//	    Name: "postgres",
+//	    DSN:  os.Getenv("DATABASE_URL"),
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer p.Close()
+//	gw.RegisterPlugin(p)
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/scim"
+
+	"github.com/lib/pq"
+)
+
+// PostgresPlugin implements a PostgreSQL-backed SCIM plugin.
+//
+// Rows are scoped by a base_entity column holding scim.BaseEntityFromContext of
+// the request, so several tenants can share one database. Requests without a
+// base entity use the empty string and see only unscoped rows. With a
+// Config.Replica, reads go to a replica except shortly after a write in the
+// same base entity.
+type PostgresPlugin struct {
+	name     string
+	db       *sqlx.DB
+	deletion DeletionConfig
+
+	lenientFilters bool // leave invalid filters out instead of failing
+
+	replicaConfig ReplicaConfig
+	replica       *sqlx.DB   // read replica, nil when reads go to the primary
+	pins          *writePins // sessions whose reads are pinned to the primary
+}
+
+// UserData wraps scim.User and implements sql.Scanner and driver.Valuer
+type UserData struct {
+	User *scim.User
+}
+
+// Scan implements sql.Scanner interface for reading from database
+func (u *UserData) Scan(value any) error {
+	if value == nil {
+		u.User = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("failed to scan UserData: expected []byte or string, got %T", value)
+		}
+		bytes = []byte(str)
+	}
+
+	u.User = &scim.User{}
+	if err := json.Unmarshal(bytes, u.User); err != nil {
+		return fmt.Errorf("failed to unmarshal user data: %w", err)
+	}
+
+	return nil
+}
+
+// Value implements driver.Valuer interface for writing to database
+func (u UserData) Value() (driver.Value, error) {
+	if u.User == nil {
+		return nil, nil
+	}
+
+	bytes, err := json.Marshal(u.User)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user data: %w", err)
+	}
+
+	return string(bytes), nil
+}
+
+// GroupData wraps scim.Group and implements sql.Scanner and driver.Valuer
+type GroupData struct {
+	Group *scim.Group
+}
+
+// Scan implements sql.Scanner interface for reading from database
+func (g *GroupData) Scan(value any) error {
+	if value == nil {
+		g.Group = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("failed to scan GroupData: expected []byte or string, got %T", value)
+		}
+		bytes = []byte(str)
+	}
+
+	g.Group = &scim.Group{}
+	if err := json.Unmarshal(bytes, g.Group); err != nil {
+		return fmt.Errorf("failed to unmarshal group data: %w", err)
+	}
+
+	return nil
+}
+
+// Value implements driver.Valuer interface for writing to database
+func (g GroupData) Value() (driver.Value, error) {
+	if g.Group == nil {
+		return nil, nil
+	}
+
+	bytes, err := json.Marshal(g.Group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal group data: %w", err)
+	}
+
+	return string(bytes), nil
+}
+
+// userRow represents a user row in the database
+type userRow struct {
+	ID        string    `db:"id"`
+	Username  string    `db:"username"`
+	Data      UserData  `db:"data"`
+	Version   int64     `db:"version"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// groupRow represents a group row in the database
+type groupRow struct {
+	ID          string    `db:"id"`
+	DisplayName string    `db:"display_name"`
+	Data        GroupData `db:"data"`
+	Version     int64     `db:"version"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// NewPostgresPlugin connects to the database of cfg.DSN and creates or
+// upgrades its schema. It returns an error if the database is unreachable or a
+// migration fails.
+func NewPostgresPlugin(cfg Config) (*PostgresPlugin, error) {
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("postgres plugin: DSN is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+
+	db, err := sqlx.Open("postgres", cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(2)
+	cfg.Pool.Apply(db.DB)
+
+	// Verify connection with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close() // nolint:errcheck
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	plugin := &PostgresPlugin{
+		name:           cfg.Name,
+		db:             db,
+		deletion:       cfg.Deletion,
+		replicaConfig:  cfg.Replica,
+		lenientFilters: cfg.LenientFilters,
+	}
+
+	// Create or upgrade the database schema; a replica receives it through replication
+	if _, err := applyMigrations(context.Background(), db.DB, false); err != nil {
+		db.Close() // nolint:errcheck
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	if err := plugin.openReplica(); err != nil {
+		db.Close() // nolint:errcheck
+		return nil, err
+	}
+	if plugin.replica != nil {
+		cfg.Pool.Apply(plugin.replica.DB)
+	}
+
+	return plugin, nil
+}
+
+// Name returns the plugin name
+func (p *PostgresPlugin) Name() string {
+	return p.name
+}
+
+// DB returns the underlying connection pool so the gateway can apply
+// pool settings and expose connection metrics (plugin.DBProvider)
+func (p *PostgresPlugin) DB() *sql.DB {
+	return p.db.DB
+}
+
+// Close closes the database connections
+func (p *PostgresPlugin) Close() error {
+	if p.replica != nil {
+		p.replica.Close() // nolint:errcheck
+	}
+	return p.db.Close()
+}
+
+// GetUsers retrieves users with optional filtering, sorting, and pagination
+func (p *PostgresPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	users := []*scim.User{}
+	err := p.StreamUsers(ctx, params, func(user *scim.User) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// CreateUser creates a new user
+func (p *PostgresPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	// Generate ID if not provided
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+
+	// Set schemas if not provided
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{scim.SchemaUser}
+	}
+
+	baseEntity := scim.BaseEntityFromContext(ctx)
+
+	var exists bool
+	// Check for existing username within the base entity
+	err := p.writer(ctx).GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 AND base_entity = $2 AND deleted_at IS NULL)", user.UserName, baseEntity)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to check existing username: %v", err))
+	}
+
+	if exists {
+		return nil, scim.ErrUniqueness(
+			fmt.Sprintf("userName '%s' already exists", user.UserName),
+		)
+	}
+
+	// Set meta
+	now := clock.Now(ctx)
+	user.Meta = &scim.Meta{
+		ResourceType: "User",
+		Created:      &now,
+		LastModified: &now,
+		Version:      rowVersion(1),
+	}
+
+	// Insert user into database
+	query := `INSERT INTO users (id, base_entity, username, data, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	userData := UserData{User: user}
+	if _, err := p.writer(ctx).ExecContext(ctx, query, user.ID, baseEntity, user.UserName, userData, now, now); err != nil {
+		return nil, writeError(err, "User", "failed to insert user")
+	}
+	p.markWrite(ctx)
+
+	return user, nil
+}
+
+// GetUser retrieves a specific user by ID
+func (p *PostgresPlugin) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	return p.getUser(ctx, p.reader(ctx), id)
+}
+
+// getUser reads a user from db
+func (p *PostgresPlugin) getUser(ctx context.Context, db dbtx, id string) (*scim.User, error) {
+	var row userRow
+	query := `SELECT id, username, data, version, created_at, updated_at FROM users WHERE id = $1 AND base_entity = $2 AND deleted_at IS NULL`
+
+	if err := db.GetContext(ctx, &row, query, id, scim.BaseEntityFromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, scim.ErrNotFound("User", id)
+		}
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to get user: %v", err))
+	}
+
+	setMetaVersion(row.Data.User.Meta, row.Version)
+	return row.Data.User, nil
+}
+
+// GetUserByUserName implements scim.UserNameLookup with the case-insensitive
+// username index of the users of the request's base entity. Names are only
+// unique as written, so several users differing in case are reported with
+// errors.ErrUnsupported.
+func (p *PostgresPlugin) GetUserByUserName(ctx context.Context, userName string) (*scim.User, error) {
+	var rows []userRow
+	query := `SELECT id, username, data, version, created_at, updated_at FROM users WHERE lower(username) = lower($1) AND base_entity = $2 AND deleted_at IS NULL LIMIT 2`
+
+	if err := sqlx.SelectContext(ctx, p.reader(ctx), &rows, query, userName, scim.BaseEntityFromContext(ctx)); err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to look up user: %v", err))
+	}
+	switch len(rows) {
+	case 0:
+		return nil, nil
+	case 1:
+		setMetaVersion(rows[0].Data.User.Meta, rows[0].Version)
+		return rows[0].Data.User, nil
+	}
+	return nil, fmt.Errorf("several users are named %q: %w", userName, errors.ErrUnsupported)
+}
+
+// LookupUserByExternalID implements scim.ExternalIDLookup with the unique
+// externalId index of the users of the request's base entity
+func (p *PostgresPlugin) LookupUserByExternalID(ctx context.Context, externalID string) (*scim.User, error) {
+	var row userRow
+	query := `SELECT id, username, data, version, created_at, updated_at FROM users WHERE data->>'externalId' = $1 AND base_entity = $2 AND deleted_at IS NULL LIMIT 1`
+
+	if err := p.reader(ctx).GetContext(ctx, &row, query, externalID, scim.BaseEntityFromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to look up user: %v", err))
+	}
+
+	setMetaVersion(row.Data.User.Meta, row.Version)
+	return row.Data.User, nil
+}
+
+// ModifyUser updates a user's attributes
+func (p *PostgresPlugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := p.ModifyUserResult(ctx, id, patch)
+	return err
+}
+
+// ModifyUserResult implements scim.ModifyUserResult, returning the user it
+// wrote so that the server need not fetch it again
+func (p *PostgresPlugin) ModifyUserResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.User, error) {
+	// Get existing user from the primary (returns ErrNotFound if not exists)
+	user, err := p.getUser(ctx, p.writer(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := parseRowVersion(user.Meta.Version)
+
+	// Apply patch operations
+	patcher := scim.NewPatchProcessor()
+	if err := patcher.ApplyPatch(user, patch); err != nil {
+		return nil, scim.ErrInvalidSyntax(fmt.Sprintf("failed to apply patch: %v", err))
+	}
+
+	if err := p.updateUser(ctx, user, version); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ReplaceUser implements scim.UserReplacer, updating the user in place so
+// that a PUT keeps its ID and meta.created
+func (p *PostgresPlugin) ReplaceUser(ctx context.Context, id string, user *scim.User) (*scim.User, error) {
+	// Get existing user from the primary (returns ErrNotFound if not exists)
+	existing, err := p.getUser(ctx, p.writer(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := parseRowVersion(existing.Meta.Version)
+
+	user.ID = id
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{scim.SchemaUser}
+	}
+	user.Meta = existing.Meta
+
+	if err := p.updateUser(ctx, user, version); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// updateUser writes user over its row, which was read at version
+func (p *PostgresPlugin) updateUser(ctx context.Context, user *scim.User, version int64) error {
+	// Update metadata
+	now := clock.Now(ctx)
+	user.Meta.LastModified = &now
+	user.Meta.Version = rowVersion(version + 1)
+
+	// Update user in database; conditional writes only apply to the version read above
+	query := `UPDATE users SET username = $1, data = $2, version = version + 1, updated_at = $3 WHERE id = $4 AND base_entity = $5 AND deleted_at IS NULL`
+	args := []any{user.UserName, UserData{User: user}, now, user.ID, scim.BaseEntityFromContext(ctx)}
+	if expected, ok := expectedVersion(ctx); ok {
+		if expected != version {
+			return noRowsError(ctx, "User", user.ID)
+		}
+		query += ` AND version = $6`
+		args = append(args, version)
+	}
+
+	result, err := p.writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return writeError(err, "User", "failed to update user")
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return noRowsError(ctx, "User", user.ID)
+	}
+	p.markWrite(ctx)
+
+	return nil
+}
+
+// DeleteUser deletes a user
+func (p *PostgresPlugin) DeleteUser(ctx context.Context, id string) error {
+	rows, err := p.deleteRow(ctx, "users", id)
+	if err != nil {
+		return scim.ErrInternalServer(fmt.Sprintf("failed to delete user: %v", err))
+	}
+
+	if rows == 0 {
+		return noRowsError(ctx, "User", id)
+	}
+
+	return nil
+}
+
+// GetGroups retrieves groups with optional filtering, sorting, and pagination
+func (p *PostgresPlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	groups := []*scim.Group{}
+	err := p.StreamGroups(ctx, params, func(group *scim.Group) error {
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// CreateGroup creates a new group
+func (p *PostgresPlugin) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	// Generate ID if not provided
+	if group.ID == "" {
+		group.ID = uuid.New().String()
+	}
+
+	// Set schemas if not provided
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{scim.SchemaGroup}
+	}
+
+	baseEntity := scim.BaseEntityFromContext(ctx)
+
+	var exists bool
+	// Check for existing displayName within the base entity
+	err := p.writer(ctx).GetContext(ctx, &exists, "SELECT EXISTS(SELECT 1 FROM groups WHERE display_name = $1 AND base_entity = $2 AND deleted_at IS NULL)", group.DisplayName, baseEntity)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to check existing displayName: %v", err))
+	}
+
+	if exists {
+		return nil, scim.ErrUniqueness(
+			fmt.Sprintf("displayName '%s' already exists", group.DisplayName),
+		)
+	}
+
+	// Set meta
+	now := clock.Now(ctx)
+	group.Meta = &scim.Meta{
+		ResourceType: "Group",
+		Created:      &now,
+		LastModified: &now,
+		Version:      rowVersion(1),
+	}
+
+	// Insert group into database
+	query := `INSERT INTO groups (id, base_entity, display_name, data, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+
+	groupData := GroupData{Group: group}
+	if _, err := p.writer(ctx).ExecContext(ctx, query, group.ID, baseEntity, group.DisplayName, groupData, now, now); err != nil {
+		return nil, writeError(err, "Group", "failed to insert group")
+	}
+	p.markWrite(ctx)
+
+	return group, nil
+}
+
+// GetGroup retrieves a specific group by ID
+func (p *PostgresPlugin) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	return p.getGroup(ctx, p.reader(ctx), id)
+}
+
+// getGroup reads a group from db
+func (p *PostgresPlugin) getGroup(ctx context.Context, db dbtx, id string) (*scim.Group, error) {
+	var row groupRow
+	query := `SELECT id, display_name, data, version, created_at, updated_at FROM groups WHERE id = $1 AND base_entity = $2 AND deleted_at IS NULL`
+
+	if err := db.GetContext(ctx, &row, query, id, scim.BaseEntityFromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, scim.ErrNotFound("Group", id)
+		}
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to get group: %v", err))
+	}
+
+	setMetaVersion(row.Data.Group.Meta, row.Version)
+	return row.Data.Group, nil
+}
+
+// LookupGroupByExternalID implements scim.ExternalIDLookup with the unique
+// externalId index of the groups of the request's base entity
+func (p *PostgresPlugin) LookupGroupByExternalID(ctx context.Context, externalID string) (*scim.Group, error) {
+	var row groupRow
+	query := `SELECT id, display_name, data, version, created_at, updated_at FROM groups WHERE data->>'externalId' = $1 AND base_entity = $2 AND deleted_at IS NULL LIMIT 1`
+
+	if err := p.reader(ctx).GetContext(ctx, &row, query, externalID, scim.BaseEntityFromContext(ctx)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to look up group: %v", err))
+	}
+
+	setMetaVersion(row.Data.Group.Meta, row.Version)
+	return row.Data.Group, nil
+}
+
+// GetGroupByDisplayName implements scim.GroupDisplayNameLookup with the
+// case-insensitive displayName index of the groups of the request's base
+// entity. Names are only unique as written, so several groups differing in
+// case are reported with errors.ErrUnsupported.
+func (p *PostgresPlugin) GetGroupByDisplayName(ctx context.Context, displayName string) (*scim.Group, error) {
+	var rows []groupRow
+	query := `SELECT id, display_name, data, version, created_at, updated_at FROM groups WHERE lower(display_name) = lower($1) AND base_entity = $2 AND deleted_at IS NULL LIMIT 2`
+
+	if err := sqlx.SelectContext(ctx, p.reader(ctx), &rows, query, displayName, scim.BaseEntityFromContext(ctx)); err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to look up group: %v", err))
+	}
+	switch len(rows) {
+	case 0:
+		return nil, nil
+	case 1:
+		setMetaVersion(rows[0].Data.Group.Meta, rows[0].Version)
+		return rows[0].Data.Group, nil
+	}
+	return nil, fmt.Errorf("several groups are named %q: %w", displayName, errors.ErrUnsupported)
+}
+
+// ModifyGroup updates a group's attributes
+func (p *PostgresPlugin) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	_, err := p.ModifyGroupResult(ctx, id, patch)
+	return err
+}
+
+// ModifyGroupResult implements scim.ModifyGroupResult, returning the group it
+// wrote so that the server need not fetch it again
+func (p *PostgresPlugin) ModifyGroupResult(ctx context.Context, id string, patch *scim.PatchOp) (*scim.Group, error) {
+	// Get existing group from the primary (returns ErrNotFound if not exists)
+	group, err := p.getGroup(ctx, p.writer(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := parseRowVersion(group.Meta.Version)
+
+	// Apply patch operations
+	patcher := scim.NewPatchProcessor()
+	if err := patcher.ApplyPatch(group, patch); err != nil {
+		return nil, scim.ErrInvalidSyntax(fmt.Sprintf("failed to apply patch: %v", err))
+	}
+
+	if err := p.updateGroup(ctx, group, version); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// ReplaceGroup implements scim.GroupReplacer, updating the group in place so
+// that a PUT keeps its ID and meta.created
+func (p *PostgresPlugin) ReplaceGroup(ctx context.Context, id string, group *scim.Group) (*scim.Group, error) {
+	// Get existing group from the primary (returns ErrNotFound if not exists)
+	existing, err := p.getGroup(ctx, p.writer(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	version, _ := parseRowVersion(existing.Meta.Version)
+
+	group.ID = id
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{scim.SchemaGroup}
+	}
+	group.Meta = existing.Meta
+
+	if err := p.updateGroup(ctx, group, version); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// updateGroup writes group over its row, which was read at version
+func (p *PostgresPlugin) updateGroup(ctx context.Context, group *scim.Group, version int64) error {
+	// Update metadata
+	now := clock.Now(ctx)
+	group.Meta.LastModified = &now
+	group.Meta.Version = rowVersion(version + 1)
+
+	// Update group in database; conditional writes only apply to the version read above
+	query := `UPDATE groups SET display_name = $1, data = $2, version = version + 1, updated_at = $3 WHERE id = $4 AND base_entity = $5 AND deleted_at IS NULL`
+	args := []any{group.DisplayName, GroupData{Group: group}, now, group.ID, scim.BaseEntityFromContext(ctx)}
+	if expected, ok := expectedVersion(ctx); ok {
+		if expected != version {
+			return noRowsError(ctx, "Group", group.ID)
+		}
+		query += ` AND version = $6`
+		args = append(args, version)
+	}
+
+	result, err := p.writer(ctx).ExecContext(ctx, query, args...)
+	if err != nil {
+		return writeError(err, "Group", "failed to update group")
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return noRowsError(ctx, "Group", group.ID)
+	}
+	p.markWrite(ctx)
+
+	return nil
+}
+
+// DeleteGroup deletes a group
+func (p *PostgresPlugin) DeleteGroup(ctx context.Context, id string) error {
+	rows, err := p.deleteRow(ctx, "groups", id)
+	if err != nil {
+		return scim.ErrInternalServer(fmt.Sprintf("failed to delete group: %v", err))
+	}
+
+	if rows == 0 {
+		return noRowsError(ctx, "Group", id)
+	}
+
+	return nil
+}
+
+func (p *PostgresPlugin) HealthCheck(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database health check failed: %w", err)
+	}
+	if p.replica != nil {
+		if err := p.replica.PingContext(ctx); err != nil {
+			return fmt.Errorf("read replica health check failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Ping pings the primary database and the read replica, so the gateway's
+// keepalive (plugin.Pinger) keeps the connections of both pools open
+func (p *PostgresPlugin) Ping(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	if p.replica != nil {
+		if err := p.replica.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping read replica: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeError is the error for a failed insert or update of a resource of
+// resourceType: a conflict if it violates the unique externalId index, and
+// an internal error otherwise
+func writeError(err error, resourceType, message string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" &&
+		(pqErr.Constraint == "idx_users_external_id" || pqErr.Constraint == "idx_groups_external_id") {
+		return scim.ErrConflict(resourceType, "externalId")
+	}
+	return scim.ErrInternalServer(fmt.Sprintf("%s: %v", message, err))
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import "github.com/marcelom97/scimgateway/sqlutil"
+
+// QueryBuilder constructs PostgreSQL queries from SCIM QueryParams. See
+// sqlutil.QueryBuilder.
+type QueryBuilder = sqlutil.QueryBuilder
+
+// ExtensionLayout describes where schema extension attributes live in the stored JSON
+type ExtensionLayout = sqlutil.ExtensionLayout
+
+const (
+	// ExtensionNested stores extension attributes under their schema URN key
+	ExtensionNested = sqlutil.ExtensionNested
+
+	// ExtensionFlat stores extension attributes at the top level of the document
+	ExtensionFlat = sqlutil.ExtensionFlat
+)
+
+// InvalidFilterError reports a SCIM filter that cannot be parsed
+type InvalidFilterError = sqlutil.InvalidFilterError
+
+// NewQueryBuilder creates a PostgreSQL query builder for the specified table.
+// Parameters are written as ? for compatibility with sqlx.Rebind().
+func NewQueryBuilder(table string, dataColumn string, attrMapping map[string]string) *QueryBuilder {
+	return sqlutil.NewQueryBuilder(sqlutil.Postgres, table, dataColumn, attrMapping).
+		WithPlaceholders(sqlutil.Question)
+}
+
+// UserAttributeMapping defines how SCIM user attributes map to database columns
+var UserAttributeMapping = sqlutil.UserAttributeMapping
+
+// GroupAttributeMapping defines how SCIM group attributes map to database columns
+var GroupAttributeMapping = sqlutil.GroupAttributeMapping
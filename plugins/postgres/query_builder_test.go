@@ -1,9 +1,14 @@
-package main
+package postgres
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
 func TestQueryBuilder_Build(t *testing.T) {
@@ -42,7 +47,7 @@ func TestQueryBuilder_Build(t *testing.T) {
 			params: scim.QueryParams{
 				Filter: `userName eq "john"`,
 			},
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"john"},
 		},
 		{
@@ -53,7 +58,7 @@ func TestQueryBuilder_Build(t *testing.T) {
 			params: scim.QueryParams{
 				Filter: `displayName eq "Admins"`,
 			},
-			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(display_name) = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(UPPER(display_name)) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"admins"},
 		},
 		{
@@ -66,7 +71,7 @@ func TestQueryBuilder_Build(t *testing.T) {
 				StartIndex: 11,
 				Count:      10,
 			},
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) = ? ORDER BY created_at ASC LIMIT 10 OFFSET 10",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) = ? ORDER BY created_at ASC LIMIT 10 OFFSET 10",
 			wantArgs: []any{"john"},
 		},
 		{
@@ -129,7 +134,7 @@ func TestQueryBuilder_Build(t *testing.T) {
 				StartIndex: 21,
 				Count:      20,
 			},
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY username ASC NULLS LAST LIMIT 20 OFFSET 20",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY username ASC NULLS LAST LIMIT 20 OFFSET 20",
 			wantArgs: []any{"john%"},
 		},
 	}
@@ -198,7 +203,7 @@ func TestQueryBuilder_BuildCount(t *testing.T) {
 				Count:      5,
 				SortBy:     "displayName",
 			},
-			wantSQL:  "SELECT COUNT(*) FROM groups WHERE LOWER(display_name) LIKE ?",
+			wantSQL:  "SELECT COUNT(*) FROM groups WHERE LOWER(UPPER(display_name)) LIKE ?",
 			wantArgs: []any{"%admin%"},
 		},
 	}
@@ -237,7 +242,7 @@ func TestQueryBuilder_FilterOperators(t *testing.T) {
 		{
 			name:     "eq with string value",
 			filter:   `userName eq "john.doe"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"john.doe"},
 		},
 		{
@@ -255,7 +260,7 @@ func TestQueryBuilder_FilterOperators(t *testing.T) {
 		{
 			name:     "ne with string value",
 			filter:   `userName ne "admin"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) <> ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) <> ? ORDER BY created_at ASC",
 			wantArgs: []any{"admin"},
 		},
 
@@ -263,19 +268,19 @@ func TestQueryBuilder_FilterOperators(t *testing.T) {
 		{
 			name:     "co contains",
 			filter:   `userName co "john"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%john%"},
 		},
 		{
 			name:     "sw starts with",
 			filter:   `userName sw "john"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"john%"},
 		},
 		{
 			name:     "ew ends with",
 			filter:   `userName ew "doe"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%doe"},
 		},
 
@@ -286,6 +291,12 @@ func TestQueryBuilder_FilterOperators(t *testing.T) {
 			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (username IS NOT NULL AND username <> '') ORDER BY created_at ASC",
 			wantArgs: []any{},
 		},
+		{
+			name:     "pr on a multi-valued attribute",
+			filter:   `emails pr`,
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE COALESCE(data->'emails' NOT IN ('null', '""', '[]', '{}'), false) ORDER BY created_at ASC`,
+			wantArgs: []any{},
+		},
 
 		// Comparison operators
 		{
@@ -347,37 +358,37 @@ func TestQueryBuilder_LogicalOperators(t *testing.T) {
 		{
 			name:     "AND operator",
 			filter:   `userName eq "john" and active eq true`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(username) = ? AND data->>'active' = ?) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(UPPER(username)) = ? AND data->>'active' = ?) ORDER BY created_at ASC",
 			wantArgs: []any{"john", "true"},
 		},
 		{
 			name:     "OR operator",
 			filter:   `userName eq "john" or userName eq "jane"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(username) = ? OR LOWER(username) = ?) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(UPPER(username)) = ? OR LOWER(UPPER(username)) = ?) ORDER BY created_at ASC",
 			wantArgs: []any{"john", "jane"},
 		},
 		{
 			name:     "NOT operator",
 			filter:   `not userName eq "admin"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE NOT (LOWER(username) = ?) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE NOT (LOWER(UPPER(username)) = ?) ORDER BY created_at ASC",
 			wantArgs: []any{"admin"},
 		},
 		{
 			name:     "complex AND OR combination",
 			filter:   `userName eq "john" and (active eq true or role eq "admin")`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(username) = ? AND ((data->>'active' = ? OR LOWER(data->>'role') = ?))) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(UPPER(username)) = ? AND ((data->>'active' = ? OR LOWER(UPPER(data->>'role')) = ?))) ORDER BY created_at ASC",
 			wantArgs: []any{"john", "true", "admin"},
 		},
 		{
 			name:     "multiple AND",
 			filter:   `userName eq "john" and active eq true and verified eq true`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE ((LOWER(username) = ? AND data->>'active' = ?) AND data->>'verified' = ?) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE ((LOWER(UPPER(username)) = ? AND data->>'active' = ?) AND data->>'verified' = ?) ORDER BY created_at ASC",
 			wantArgs: []any{"john", "true", "true"},
 		},
 		{
 			name:     "grouped expression",
 			filter:   `(userName eq "john")`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(username) = ?) ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (LOWER(UPPER(username)) = ?) ORDER BY created_at ASC",
 			wantArgs: []any{"john"},
 		},
 	}
@@ -415,31 +426,31 @@ func TestQueryBuilder_NestedAttributes(t *testing.T) {
 		{
 			name:     "single level nested attribute",
 			filter:   `name.givenName eq "John"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(data->'name'->>'givenName') = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->'name'->>'givenName')) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"john"},
 		},
 		{
 			name:     "two level nested attribute",
 			filter:   `name.familyName eq "Doe"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(data->'name'->>'familyName') = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->'name'->>'familyName')) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"doe"},
 		},
 		{
 			name:     "three level nested attribute",
 			filter:   `enterprise.manager.displayName eq "Boss"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(data->'enterprise'->'manager'->>'displayName') = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->'enterprise'->'manager'->>'displayName')) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"boss"},
 		},
 		{
 			name:     "nested attribute with sw operator",
 			filter:   `name.givenName sw "Jo"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(data->'name'->>'givenName') LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->'name'->>'givenName')) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"jo%"},
 		},
 		{
 			name:     "nested attribute presence check",
 			filter:   `name.givenName pr`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE (data->'name'->>'givenName' IS NOT NULL AND data->'name'->>'givenName' <> '') ORDER BY created_at ASC",
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE COALESCE(data->'name'->'givenName' NOT IN ('null', '""', '[]', '{}'), false) ORDER BY created_at ASC`,
 			wantArgs: []any{},
 		},
 	}
@@ -472,6 +483,7 @@ func TestQueryBuilder_SortingNestedAttributes(t *testing.T) {
 		name      string
 		sortBy    string
 		sortOrder string
+		collation string
 		wantSQL   string
 	}{
 		{
@@ -498,6 +510,13 @@ func TestQueryBuilder_SortingNestedAttributes(t *testing.T) {
 			sortOrder: "ascending",
 			wantSQL:   "SELECT id, username, data, created_at, updated_at FROM users ORDER BY data->>'active' ASC NULLS LAST",
 		},
+		{
+			name:      "sort ignoring case",
+			sortBy:    "name.familyName",
+			sortOrder: "descending",
+			collation: scim.CollationCaseInsensitive,
+			wantSQL:   "SELECT id, username, data, created_at, updated_at FROM users ORDER BY LOWER(UPPER(data->'name'->>'familyName')) DESC NULLS LAST, data->'name'->>'familyName' DESC",
+		},
 	}
 
 	for _, tt := range tests {
@@ -506,6 +525,7 @@ func TestQueryBuilder_SortingNestedAttributes(t *testing.T) {
 			gotSQL, _ := qb.Build(scim.QueryParams{
 				SortBy:    tt.sortBy,
 				SortOrder: tt.sortOrder,
+				Collation: tt.collation,
 			})
 
 			if gotSQL != tt.wantSQL {
@@ -579,25 +599,25 @@ func TestQueryBuilder_SpecialCharacters(t *testing.T) {
 		{
 			name:     "LIKE pattern with percent",
 			filter:   `userName co "100%"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%100\\%%"},
 		},
 		{
 			name:     "LIKE pattern with underscore",
 			filter:   `userName co "user_name"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%user\\_name%"},
 		},
 		{
 			name:     "LIKE pattern with backslash",
 			filter:   `userName co "path\\file"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(username) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%path\\\\\\\\file%"}, // Double escaping: filter parser + LIKE escape
 		},
 		{
 			name:     "string with spaces",
 			filter:   `displayName eq "John Doe"`,
-			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(data->>'displayName') = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->>'displayName')) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"john doe"},
 		},
 	}
@@ -723,6 +743,11 @@ func TestQueryBuilder_CaseInsensitivity(t *testing.T) {
 			filter:   `userName sw "Super"`,
 			wantArgs: []any{"super%"},
 		},
+		{
+			name:     "unicode case folding",
+			filter:   `userName eq "IŞIK.ΟΔΟΣ"`,
+			wantArgs: []any{"işik.οδοσ"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -744,56 +769,93 @@ func TestQueryBuilder_CaseInsensitivity(t *testing.T) {
 	}
 }
 
-func TestEscapeLikePattern(t *testing.T) {
+func TestQueryBuilder_AccentInsensitive(t *testing.T) {
 	tests := []struct {
-		input    string
-		expected string
+		filter   string
+		wantSQL  string
+		wantArgs []any
 	}{
-		{"simple", "simple"},
-		{"100%", "100\\%"},
-		{"user_name", "user\\_name"},
-		{"path\\file", "path\\\\file"},
-		{"a%b_c\\d", "a\\%b\\_c\\\\d"},
-		{"", ""},
+		{filter: `userName eq "José"`},
+		{filter: `name.familyName co "ü"`},
+		{filter: `active eq true`, wantSQL: "WHERE data->>'active' = ?", wantArgs: []any{true}},
+		{filter: `userName pr`, wantSQL: "WHERE (username IS NOT NULL AND username <> '')"},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := escapeLikePattern(tt.input)
-			if got != tt.expected {
-				t.Errorf("escapeLikePattern(%q) = %q, want %q", tt.input, got, tt.expected)
+		t.Run(tt.filter, func(t *testing.T) {
+			qb := NewQueryBuilder("users", "data", UserAttributeMapping)
+			gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: tt.filter, AccentInsensitive: true})
+
+			if tt.wantSQL == "" && strings.Contains(gotSQL, "WHERE") {
+				t.Errorf("Build() SQL = %s, want the filter left to the gateway", gotSQL)
+			}
+			if tt.wantSQL != "" && !strings.Contains(gotSQL, tt.wantSQL) {
+				t.Errorf("Build() SQL = %s, want %s", gotSQL, tt.wantSQL)
+			}
+			if len(gotArgs) != len(tt.wantArgs) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, tt.wantArgs)
 			}
 		})
 	}
 }
 
-func TestGetSQLPath(t *testing.T) {
-	qb := NewQueryBuilder("users", "data", UserAttributeMapping)
-
+func TestQueryBuilder_ExtensionURNs(t *testing.T) {
 	tests := []struct {
-		attrPath string
-		expected string
+		name     string
+		layout   ExtensionLayout
+		filter   string
+		sortBy   string
+		wantSQL  string
+		wantArgs []any
 	}{
-		// Direct column mappings
-		{"id", "id"},
-		{"userName", "username"},
-		{"username", "username"},
-
-		// Single-level JSONB
-		{"active", "data->>'active'"},
-		{"displayName", "data->>'displayName'"},
-
-		// Nested JSONB paths
-		{"name.givenName", "data->'name'->>'givenName'"},
-		{"name.familyName", "data->'name'->>'familyName'"},
-		{"enterprise.manager.displayName", "data->'enterprise'->'manager'->>'displayName'"},
+		{
+			name:     "nested extension attribute",
+			layout:   ExtensionNested,
+			filter:   `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "X"`,
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->'urn:ietf:params:scim:schemas:extension:enterprise:2.0:User'->>'department')) = ? ORDER BY created_at ASC",
+			wantArgs: []any{"x"},
+		},
+		{
+			name:     "flat extension attribute",
+			layout:   ExtensionFlat,
+			filter:   `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "X"`,
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(data->>'department')) = ? ORDER BY created_at ASC",
+			wantArgs: []any{"x"},
+		},
+		{
+			name:     "core schema attribute uses column mapping",
+			layout:   ExtensionNested,
+			filter:   `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "john"`,
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE LOWER(UPPER(username)) = ? ORDER BY created_at ASC",
+			wantArgs: []any{"john"},
+		},
+		{
+			name:     "sort by extension attribute",
+			layout:   ExtensionNested,
+			sortBy:   "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users ORDER BY data->'urn:ietf:params:scim:schemas:extension:enterprise:2.0:User'->>'employeeNumber' ASC NULLS LAST",
+			wantArgs: []any{},
+		},
 	}
 
 	for _, tt := range tests {
-		t.Run(tt.attrPath, func(t *testing.T) {
-			got := qb.getSQLPath(tt.attrPath)
-			if got != tt.expected {
-				t.Errorf("getSQLPath(%q) = %q, want %q", tt.attrPath, got, tt.expected)
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder("users", "data", UserAttributeMapping).WithExtensionLayout(tt.layout)
+			gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: tt.filter, SortBy: tt.sortBy})
+
+			if gotSQL != tt.wantSQL {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, tt.wantSQL)
+			}
+
+			if len(gotArgs) != len(tt.wantArgs) {
+				t.Errorf("Build() args count = %d, want %d", len(gotArgs), len(tt.wantArgs))
+				return
+			}
+
+			for i, arg := range gotArgs {
+				if arg != tt.wantArgs[i] {
+					t.Errorf("Build() args[%d] = %v, want %v", i, arg, tt.wantArgs[i])
+				}
 			}
 		})
 	}
@@ -809,20 +871,21 @@ func TestQueryBuilder_GroupsTable(t *testing.T) {
 		{
 			name:     "filter by displayName",
 			filter:   `displayName eq "Administrators"`,
-			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(display_name) = ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(UPPER(display_name)) = ? ORDER BY created_at ASC",
 			wantArgs: []any{"administrators"},
 		},
 		{
 			name:     "filter by displayName contains",
 			filter:   `displayName co "admin"`,
-			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(display_name) LIKE ? ORDER BY created_at ASC",
+			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(UPPER(display_name)) LIKE ? ORDER BY created_at ASC",
 			wantArgs: []any{"%admin%"},
 		},
 		{
+			// A path into an array is left to the in-memory filter
 			name:     "filter by nested members",
 			filter:   `members.value eq "user123"`,
-			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups WHERE LOWER(data->'members'->>'value') = ? ORDER BY created_at ASC",
-			wantArgs: []any{"user123"},
+			wantSQL:  "SELECT id, display_name, data, created_at, updated_at FROM groups ORDER BY created_at ASC",
+			wantArgs: []any{},
 		},
 	}
 
@@ -848,3 +911,159 @@ func TestQueryBuilder_GroupsTable(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryBuilder_Scope(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   scim.QueryParams
+		count    bool
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "scope without filter",
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? ORDER BY created_at ASC",
+			wantArgs: []any{"acme"},
+		},
+		{
+			name:     "scope with filter",
+			params:   scim.QueryParams{Filter: `userName eq "john" or userName eq "jane"`},
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND ((LOWER(UPPER(username)) = ? OR LOWER(UPPER(username)) = ?)) ORDER BY created_at ASC",
+			wantArgs: []any{"acme", "john", "jane"},
+		},
+		{
+			name:     "scope with count",
+			params:   scim.QueryParams{Filter: `userName eq "john"`},
+			count:    true,
+			wantSQL:  "SELECT COUNT(*) FROM users WHERE base_entity = ? AND (LOWER(UPPER(username)) = ?)",
+			wantArgs: []any{"acme", "john"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder("users", "data", UserAttributeMapping).WithScope("base_entity", "acme")
+
+			var gotSQL string
+			var gotArgs []any
+			if tt.count {
+				gotSQL, gotArgs = qb.BuildCount(tt.params)
+			} else {
+				gotSQL, gotArgs = qb.Build(tt.params)
+			}
+
+			if gotSQL != tt.wantSQL {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, tt.wantSQL)
+			}
+
+			if len(gotArgs) != len(tt.wantArgs) {
+				t.Errorf("Build() args count = %d, want %d", len(gotArgs), len(tt.wantArgs))
+				return
+			}
+
+			for i, arg := range gotArgs {
+				if arg != tt.wantArgs[i] {
+					t.Errorf("Build() args[%d] = %v, want %v", i, arg, tt.wantArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Condition(t *testing.T) {
+	qb := NewQueryBuilder("users", "data", UserAttributeMapping).
+		WithScope("base_entity", "acme").
+		WithCondition("deleted_at IS NULL")
+
+	gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: `userName eq "john"`})
+	wantSQL := "SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND deleted_at IS NULL AND (LOWER(UPPER(username)) = ?) ORDER BY created_at ASC"
+	if gotSQL != wantSQL {
+		t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, wantSQL)
+	}
+	if len(gotArgs) != 2 || gotArgs[0] != "acme" || gotArgs[1] != "john" {
+		t.Errorf("Build() args = %v, want [acme john]", gotArgs)
+	}
+}
+
+func TestQueryBuilder_Columns(t *testing.T) {
+	qb := NewQueryBuilder("groups", "data", GroupAttributeMapping).WithColumns("version")
+
+	gotSQL, _ := qb.Build(scim.QueryParams{})
+	wantSQL := "SELECT id, display_name, data, created_at, updated_at, version FROM groups ORDER BY created_at ASC"
+	if gotSQL != wantSQL {
+		t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, wantSQL)
+	}
+}
+
+func TestQueryBuilder_FilterTrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   []string
+	}{
+		{
+			name:   "translated",
+			filter: `userName eq "john"`,
+			want:   []string{"translated to SQL: LOWER(UPPER(username)) = ?"},
+		},
+		{
+			name:   "dropped operand",
+			filter: `userName eq "john" and title co 5`,
+			want: []string{
+				"dropped title co 5: cannot translate to SQL",
+				"dropped and: an operand cannot be translated to SQL",
+				"filter not translated to SQL",
+			},
+		},
+		{
+			name:   "invalid filter",
+			filter: "userName eq",
+			want:   []string{"invalid filter not translated to SQL: "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := &scimcontext.FilterTrace{}
+			ctx := scimcontext.WithFilterTracer(context.Background(), trace)
+			NewQueryBuilder("users", "data", UserAttributeMapping).
+				WithFilterTrace(ctx).
+				Build(scim.QueryParams{Filter: tt.filter})
+
+			got := trace.Decisions()
+			if len(got) != len(tt.want) {
+				t.Fatalf("decisions = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if !strings.HasPrefix(got[i], tt.want[i]) {
+					t.Errorf("decisions[%d] = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Err(t *testing.T) {
+	qb := NewQueryBuilder("users", "data", UserAttributeMapping)
+	qb.Build(scim.QueryParams{Filter: `emails[type eq "work"].value pr`})
+	if err := qb.Err(); err != nil {
+		t.Errorf("Err() for a valid filter = %v, want nil", err)
+	}
+
+	qb.BuildCount(scim.QueryParams{Filter: `userName eq "john`})
+	var invalid *InvalidFilterError
+	if err := qb.Err(); !errors.As(err, &invalid) || invalid.Filter != `userName eq "john` {
+		t.Errorf("Err() for an invalid filter = %v, want an *InvalidFilterError", err)
+	}
+}
+
+// TestInvalidFilterRejected verifies that an invalid filter fails before the
+// database is queried, instead of listing every row
+func TestInvalidFilterRejected(t *testing.T) {
+	p := &PostgresPlugin{}
+	_, err := p.GetUsers(context.Background(), scim.QueryParams{Filter: `userName eq`})
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusBadRequest || scimErr.ScimType != scim.ScimTypeInvalidFilter {
+		t.Errorf("GetUsers() error = %v, want 400 invalidFilter", err)
+	}
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// streamRows runs query and calls fn for each row as it is read from the
+// cursor, so large result sets are never loaded into memory at once. Errors
+// returned by fn are passed through unchanged.
+func streamRows[T any](ctx context.Context, db sqlx.QueryerContext, query string, args []any, fn func(*T) error) error {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close() // nolint:errcheck
+
+	for rows.Next() {
+		var row T
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+	return nil
+}
+
+// StreamUsers implements scim.UserStreamer. The filter and sort order are pushed
+// down to PostgreSQL; the gateway paginates the stream.
+func (p *PostgresPlugin) StreamUsers(ctx context.Context, params scim.QueryParams, yield func(*scim.User) error) error {
+	qb := NewQueryBuilder("users", "data", UserAttributeMapping).
+		WithScope("base_entity", scim.BaseEntityFromContext(ctx)).
+		WithCondition("deleted_at IS NULL").
+		WithFilterTrace(ctx).
+		WithColumns("version")
+	query, args := qb.Build(params)
+	if err := qb.Err(); err != nil && !p.lenientFilters {
+		return scim.ErrInvalidFilter(err.Error())
+	}
+
+	return streamRows(ctx, p.reader(ctx), p.db.Rebind(query), args, func(row *userRow) error {
+		if row.Data.User == nil {
+			return nil
+		}
+		setMetaVersion(row.Data.User.Meta, row.Version)
+		return yield(row.Data.User)
+	})
+}
+
+// StreamGroups implements scim.GroupStreamer
+func (p *PostgresPlugin) StreamGroups(ctx context.Context, params scim.QueryParams, yield func(*scim.Group) error) error {
+	qb := NewQueryBuilder("groups", "data", GroupAttributeMapping).
+		WithScope("base_entity", scim.BaseEntityFromContext(ctx)).
+		WithCondition("deleted_at IS NULL").
+		WithFilterTrace(ctx).
+		WithColumns("version")
+	query, args := qb.Build(params)
+	if err := qb.Err(); err != nil && !p.lenientFilters {
+		return scim.ErrInvalidFilter(err.Error())
+	}
+
+	return streamRows(ctx, p.reader(ctx), p.db.Rebind(query), args, func(row *groupRow) error {
+		if row.Data.Group == nil {
+			return nil
+		}
+		setMetaVersion(row.Data.Group.Meta, row.Version)
+		return yield(row.Data.Group)
+	})
+}
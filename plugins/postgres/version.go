@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Rows carry a version counter that every update increments. meta.version of
+// a resource is its row version, so when the gateway passes the version an
+// If-Match precondition was checked against (scim.ExpectedVersionFromContext),
+// updates and deletes only apply if the row still has it. This closes the race
+// between the gateway's ETag check and the write.
+
+// rowVersion formats a row version as meta.version
+func rowVersion(version int64) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// parseRowVersion parses a meta.version formatted by rowVersion
+func parseRowVersion(version string) (int64, bool) {
+	version = strings.Trim(strings.TrimPrefix(version, "W/"), `"`)
+	n, err := strconv.ParseInt(version, 10, 64)
+	return n, err == nil
+}
+
+// expectedVersion returns the row version a conditional write expects, and
+// false for unconditional writes. A version this plugin did not issue expects
+// -1, which matches no row.
+func expectedVersion(ctx context.Context) (int64, bool) {
+	version, ok := scim.ExpectedVersionFromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	if n, ok := parseRowVersion(version); ok {
+		return n, true
+	}
+	return -1, true
+}
+
+// setMetaVersion sets meta.version to the row version
+func setMetaVersion(meta *scim.Meta, version int64) {
+	if meta != nil {
+		meta.Version = rowVersion(version)
+	}
+}
+
+// noRowsError is the error for a write that affected no rows: the resource
+// changed since the precondition check for conditional writes, or it does not
+// exist
+func noRowsError(ctx context.Context, resourceType, id string) error {
+	if _, ok := expectedVersion(ctx); ok {
+		return scim.ErrPreconditionFailed(fmt.Sprintf("%s %s was modified concurrently", resourceType, id))
+	}
+	return scim.ErrNotFound(resourceType, id)
+}
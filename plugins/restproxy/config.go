@@ -0,0 +1,321 @@
+package restproxy
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+// DefaultName is the plugin name used when Config.Name is empty
+const DefaultName = "restproxy"
+
+// DefaultTimeout bounds each request to the backend when Config.Timeout is zero
+const DefaultTimeout = 30 * time.Second
+
+// Config configures a RESTProxyPlugin
+type Config struct {
+	// Name is the plugin name, the first path segment of its endpoints.
+	// Empty uses DefaultName.
+	Name string
+
+	// BaseURL is prepended to every endpoint path, e.g.
+	// "https://hr.example.com/api/v1"
+	BaseURL string
+
+	// Users and Groups describe the backend endpoints and fields of each
+	// resource type
+	Users  ResourceConfig
+	Groups ResourceConfig
+
+	// Auth authenticates the gateway to the backend
+	Auth AuthConfig
+
+	// Headers are added to every request to the backend
+	Headers map[string]string
+
+	// Timeout bounds each request to the backend. Zero uses DefaultTimeout.
+	Timeout time.Duration
+
+	// HTTPClient is used to call the backend. Nil uses a client with Timeout.
+	HTTPClient *http.Client
+}
+
+// ResourceConfig maps a SCIM resource type to backend endpoints. Operations
+// without an endpoint fail with 501 Not Implemented.
+type ResourceConfig struct {
+	// List returns every record, e.g. "GET /employees". The gateway filters,
+	// sorts and pages the records.
+	List Endpoint
+
+	// Get, Create, Update and Delete operate on a single record, e.g.
+	// "GET /employees/{id}". Update receives the whole mapped record; PATCH
+	// requests are applied to the record read with Get before it is sent.
+	Get    Endpoint
+	Create Endpoint
+	Update Endpoint
+	Delete Endpoint
+
+	// ItemsPath is the field path of the records in the List response, e.g.
+	// "data.items". Empty means the response body is the array of records.
+	ItemsPath string
+
+	// Fields maps SCIM attribute paths to backend field paths, e.g.
+	// "name.givenName": "first_name". Paths are dot separated field names of
+	// the JSON encoding; "emails[0].value" addresses an array element and
+	// "members[].value" each element. Attributes without a mapping are not
+	// sent to or read from the backend, and the mapping must include "id".
+	// An empty Fields exchanges SCIM resources unchanged.
+	Fields map[string]string
+}
+
+// Endpoint is an HTTP method and a path template. The placeholders {id} and
+// {baseEntity} are replaced with the escaped resource ID and the request's
+// scim.BaseEntityFromContext.
+type Endpoint struct {
+	Method string
+	Path   string
+}
+
+// ParseEndpoint parses an endpoint of the form "METHOD /path". An empty
+// string returns the zero Endpoint, which disables the operation.
+func ParseEndpoint(s string) (Endpoint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Endpoint{}, nil
+	}
+	method, path, ok := strings.Cut(s, " ")
+	path = strings.TrimSpace(path)
+	if !ok || !strings.HasPrefix(path, "/") {
+		return Endpoint{}, fmt.Errorf("invalid endpoint %q: must be 'METHOD /path'", s)
+	}
+	return Endpoint{Method: strings.ToUpper(method), Path: path}, nil
+}
+
+// IsZero reports whether the endpoint is unset
+func (e Endpoint) IsZero() bool {
+	return e.Method == "" && e.Path == ""
+}
+
+// String returns the endpoint in the form ParseEndpoint accepts
+func (e Endpoint) String() string {
+	return e.Method + " " + e.Path
+}
+
+// expand returns the endpoint path with its placeholders replaced
+func (e Endpoint) expand(id, baseEntity string) string {
+	return strings.NewReplacer(
+		"{id}", url.PathEscape(id),
+		"{baseEntity}", url.PathEscape(baseEntity),
+	).Replace(e.Path)
+}
+
+// AuthType selects how the gateway authenticates to the backend
+type AuthType string
+
+const (
+	// AuthNone sends no credentials (the default)
+	AuthNone AuthType = ""
+
+	// AuthBearer sends "Authorization: Bearer <Token>"
+	AuthBearer AuthType = "bearer"
+
+	// AuthBasic sends HTTP Basic credentials of Username and Password
+	AuthBasic AuthType = "basic"
+
+	// AuthHeader sends Value in the header named Header, e.g. an X-API-Key
+	AuthHeader AuthType = "header"
+)
+
+// AuthConfig holds the backend credentials
+type AuthConfig struct {
+	Type     AuthType
+	Token    string
+	Username string
+	Password string
+	Header   string
+	Value    string
+}
+
+// Validate checks that the credentials of the auth type are set
+func (a AuthConfig) Validate() error {
+	switch a.Type {
+	case AuthNone:
+	case AuthBearer:
+		if a.Token == "" {
+			return fmt.Errorf("bearer auth requires a token")
+		}
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("basic auth requires a username")
+		}
+	case AuthHeader:
+		if a.Header == "" || a.Value == "" {
+			return fmt.Errorf("header auth requires a header and a value")
+		}
+	default:
+		return fmt.Errorf("invalid auth type %q: must be 'bearer', 'basic' or 'header'", a.Type)
+	}
+	return nil
+}
+
+// apply adds the credentials to req
+func (a AuthConfig) apply(req *http.Request) {
+	switch a.Type {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case AuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case AuthHeader:
+		req.Header.Set(a.Header, a.Value)
+	}
+}
+
+// ConfigFromPluginConfig builds a Config from a gateway plugin configuration:
+// its name, its httpClient settings (see config.HTTPClientConfig) and these
+// keys of its config map:
+//
+//	config:
+//	  baseURL: https://hr.example.com/api/v1
+//	  timeout: 10s
+//	  auth:
+//	    type: bearer
+//	    token: ${HR_API_TOKEN}
+//	  headers:
+//	    Accept-Language: en
+//	  users:
+//	    list: GET /employees
+//	    get: GET /employees/{id}
+//	    create: POST /employees
+//	    update: PUT /employees/{id}
+//	    delete: DELETE /employees/{id}
+//	    itemsPath: data
+//	    fields:
+//	      id: employeeId
+//	      userName: login
+//	      name.givenName: firstName
+//	      emails[0].value: email
+//	      active: enabled
+//	  groups:
+//	    ...
+func ConfigFromPluginConfig(pc *config.PluginConfig) (Config, error) {
+	cfg := Config{Name: pc.Name}
+
+	if baseURL, ok := pc.Config["baseURL"]; ok {
+		cfg.BaseURL = fmt.Sprint(baseURL)
+	}
+	if timeout, ok := pc.Config["timeout"]; ok {
+		d, err := time.ParseDuration(fmt.Sprint(timeout))
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("invalid timeout %q: must be a non-negative duration", timeout)
+		}
+		cfg.Timeout = d
+	}
+
+	// The plugin's httpClient settings configure proxy, trusted CAs and
+	// connection pooling; the timeout key still bounds each request
+	if pc.HTTPClient != nil {
+		httpCfg := *pc.HTTPClient
+		if httpCfg.Timeout == 0 {
+			httpCfg.Timeout = cmp.Or(cfg.Timeout, DefaultTimeout)
+		}
+		client, err := httpCfg.NewClient()
+		if err != nil {
+			return cfg, fmt.Errorf("httpClient: %w", err)
+		}
+		cfg.HTTPClient = client
+	}
+
+	auth, err := settingsMap(pc.Config, "auth")
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Auth = AuthConfig{
+		Type:     AuthType(settingString(auth, "type")),
+		Token:    settingString(auth, "token"),
+		Username: settingString(auth, "username"),
+		Password: settingString(auth, "password"),
+		Header:   settingString(auth, "header"),
+		Value:    settingString(auth, "value"),
+	}
+
+	headers, err := settingsMap(pc.Config, "headers")
+	if err != nil {
+		return cfg, err
+	}
+	if len(headers) > 0 {
+		cfg.Headers = make(map[string]string, len(headers))
+		for name := range headers {
+			cfg.Headers[name] = settingString(headers, name)
+		}
+	}
+
+	if cfg.Users, err = resourceConfigFromSettings(pc.Config, "users"); err != nil {
+		return cfg, err
+	}
+	if cfg.Groups, err = resourceConfigFromSettings(pc.Config, "groups"); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// resourceConfigFromSettings reads the resource settings under key
+func resourceConfigFromSettings(settings map[string]any, key string) (ResourceConfig, error) {
+	var cfg ResourceConfig
+	resource, err := settingsMap(settings, key)
+	if err != nil {
+		return cfg, err
+	}
+
+	endpoints := map[string]*Endpoint{
+		"list":   &cfg.List,
+		"get":    &cfg.Get,
+		"create": &cfg.Create,
+		"update": &cfg.Update,
+		"delete": &cfg.Delete,
+	}
+	for name, endpoint := range endpoints {
+		if *endpoint, err = ParseEndpoint(settingString(resource, name)); err != nil {
+			return cfg, fmt.Errorf("%s.%s: %w", key, name, err)
+		}
+	}
+	cfg.ItemsPath = settingString(resource, "itemsPath")
+
+	fields, err := settingsMap(resource, "fields")
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %w", key, err)
+	}
+	if len(fields) > 0 {
+		cfg.Fields = make(map[string]string, len(fields))
+		for attr := range fields {
+			cfg.Fields[attr] = settingString(fields, attr)
+		}
+	}
+	return cfg, nil
+}
+
+// settingsMap returns the nested settings under key, or nil if key is unset
+func settingsMap(settings map[string]any, key string) (map[string]any, error) {
+	value, ok := settings[key]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: must be a map", key)
+	}
+	return m, nil
+}
+
+// settingString returns the setting under key as a string, or "" if unset
+func settingString(settings map[string]any, key string) string {
+	value, ok := settings[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
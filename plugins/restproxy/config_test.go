@@ -0,0 +1,82 @@
+package restproxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+func TestConfigFromPluginConfig(t *testing.T) {
+	pc := &config.PluginConfig{
+		Name: "hr",
+		Config: map[string]any{
+			"baseURL": "https://hr.example.com/api",
+			"timeout": "5s",
+			"auth":    map[string]any{"type": "header", "header": "X-API-Key", "value": "secret"},
+			"headers": map[string]any{"Accept-Language": "en"},
+			"users": map[string]any{
+				"list":      "GET /employees",
+				"get":       "get /employees/{id}",
+				"itemsPath": "data",
+				"fields":    map[string]any{"id": "employeeId", "userName": "login"},
+			},
+		},
+	}
+
+	cfg, err := ConfigFromPluginConfig(pc)
+	if err != nil {
+		t.Fatalf("ConfigFromPluginConfig() error = %v", err)
+	}
+	if cfg.Name != "hr" || cfg.BaseURL != "https://hr.example.com/api" || cfg.Timeout != 5*time.Second {
+		t.Errorf("ConfigFromPluginConfig() = %+v", cfg)
+	}
+	if cfg.Auth != (AuthConfig{Type: AuthHeader, Header: "X-API-Key", Value: "secret"}) {
+		t.Errorf("Auth = %+v", cfg.Auth)
+	}
+	if cfg.Headers["Accept-Language"] != "en" {
+		t.Errorf("Headers = %v", cfg.Headers)
+	}
+	if cfg.Users.List != (Endpoint{Method: "GET", Path: "/employees"}) ||
+		cfg.Users.Get != (Endpoint{Method: "GET", Path: "/employees/{id}"}) ||
+		!cfg.Users.Create.IsZero() {
+		t.Errorf("Users endpoints = %+v", cfg.Users)
+	}
+	if cfg.Users.ItemsPath != "data" || cfg.Users.Fields["userName"] != "login" {
+		t.Errorf("Users = %+v", cfg.Users)
+	}
+	if _, err := NewRESTProxyPlugin(cfg); err != nil {
+		t.Errorf("NewRESTProxyPlugin() error = %v", err)
+	}
+
+	// httpClient settings build the client, keeping the timeout key
+	pc.HTTPClient = &config.HTTPClientConfig{ProxyURL: "http://proxy.internal:3128"}
+	if cfg, err = ConfigFromPluginConfig(pc); err != nil {
+		t.Fatalf("ConfigFromPluginConfig() with httpClient error = %v", err)
+	}
+	if cfg.HTTPClient == nil || cfg.HTTPClient.Timeout != 5*time.Second {
+		t.Errorf("HTTPClient = %+v, want a client with the 5s timeout", cfg.HTTPClient)
+	}
+	pc.HTTPClient = &config.HTTPClientConfig{CAFile: "missing-ca.pem"}
+	if _, err := ConfigFromPluginConfig(pc); err == nil {
+		t.Error("ConfigFromPluginConfig() with a missing CA file should fail")
+	}
+	pc.HTTPClient = nil
+
+	pc.Config["timeout"] = "soon"
+	if _, err := ConfigFromPluginConfig(pc); err == nil {
+		t.Error("ConfigFromPluginConfig() with an invalid timeout should fail")
+	}
+	pc.Config["timeout"] = "5s"
+	pc.Config["groups"] = map[string]any{"create": "/teams"}
+	if _, err := ConfigFromPluginConfig(pc); err == nil {
+		t.Error("ConfigFromPluginConfig() with an endpoint without method should fail")
+	}
+}
+
+func TestEndpointExpand(t *testing.T) {
+	e := Endpoint{Method: "GET", Path: "/tenants/{baseEntity}/users/{id}"}
+	if got := e.expand("a/b c", "acme"); got != "/tenants/acme/users/a%2Fb%20c" {
+		t.Errorf("expand() = %q", got)
+	}
+}
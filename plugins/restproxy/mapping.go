@@ -0,0 +1,273 @@
+package restproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathSegment is one field of a field path: a name, optionally followed by
+// an array index ("emails[0]") or the spread of every element ("members[]")
+type pathSegment struct {
+	name   string
+	index  int // -1 unless the segment addresses an array element
+	spread bool
+}
+
+// fieldPath is a parsed field path such as "name.givenName"
+type fieldPath []pathSegment
+
+// parseFieldPath parses a dot separated field path
+func parseFieldPath(path string) (fieldPath, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+
+	var segments fieldPath
+	for _, part := range strings.Split(path, ".") {
+		segment := pathSegment{name: part, index: -1}
+		if name, rest, ok := strings.Cut(part, "["); ok {
+			inner, ok := strings.CutSuffix(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("invalid field path %q: unterminated [", path)
+			}
+			segment.name = name
+			if inner == "" {
+				segment.spread = true
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid field path %q: index must be a non-negative integer", path)
+				}
+				segment.index = index
+			}
+		}
+		if segment.name == "" {
+			return nil, fmt.Errorf("invalid field path %q: empty field name", path)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// get returns the value at the path in doc. A spread segment returns the
+// values found in every element, and ok is false if none were found.
+func (p fieldPath) get(doc any) (value any, ok bool) {
+	current := doc
+	for i, segment := range p {
+		object, isObject := current.(map[string]any)
+		if !isObject {
+			return nil, false
+		}
+		if current, ok = object[segment.name]; !ok {
+			return nil, false
+		}
+
+		switch {
+		case segment.index >= 0:
+			array, isArray := current.([]any)
+			if !isArray || segment.index >= len(array) {
+				return nil, false
+			}
+			current = array[segment.index]
+		case segment.spread:
+			array, isArray := current.([]any)
+			if !isArray {
+				return nil, false
+			}
+			rest := p[i+1:]
+			if len(rest) == 0 {
+				return array, true
+			}
+			var values []any
+			for _, element := range array {
+				if v, found := rest.get(element); found {
+					values = append(values, v)
+				}
+			}
+			if len(values) == 0 {
+				return nil, false
+			}
+			return values, true
+		}
+	}
+	return current, true
+}
+
+// set stores value at the path in doc, creating the objects and arrays on
+// the way. The value of a spread segment must be an array, whose elements are
+// stored in the corresponding elements of the target array.
+func (p fieldPath) set(doc map[string]any, value any) error {
+	segment, rest := p[0], p[1:]
+
+	switch {
+	case segment.index >= 0:
+		array, _ := doc[segment.name].([]any)
+		for len(array) <= segment.index {
+			array = append(array, nil)
+		}
+		if len(rest) == 0 {
+			array[segment.index] = value
+		} else {
+			element, _ := array[segment.index].(map[string]any)
+			if element == nil {
+				element = make(map[string]any)
+			}
+			if err := rest.set(element, value); err != nil {
+				return err
+			}
+			array[segment.index] = element
+		}
+		doc[segment.name] = array
+
+	case segment.spread:
+		values, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("field %s[] requires an array, got %T", segment.name, value)
+		}
+		if len(rest) == 0 {
+			doc[segment.name] = values
+			return nil
+		}
+		array, _ := doc[segment.name].([]any)
+		for len(array) < len(values) {
+			array = append(array, nil)
+		}
+		for i, v := range values {
+			element, _ := array[i].(map[string]any)
+			if element == nil {
+				element = make(map[string]any)
+			}
+			if err := rest.set(element, v); err != nil {
+				return err
+			}
+			array[i] = element
+		}
+		doc[segment.name] = array
+
+	case len(rest) == 0:
+		doc[segment.name] = value
+
+	default:
+		child, _ := doc[segment.name].(map[string]any)
+		if child == nil {
+			child = make(map[string]any)
+		}
+		if err := rest.set(child, value); err != nil {
+			return err
+		}
+		doc[segment.name] = child
+	}
+	return nil
+}
+
+// fieldMapping maps one SCIM attribute path to a backend field path
+type fieldMapping struct {
+	attribute fieldPath
+	field     fieldPath
+}
+
+// mapping translates between SCIM resources and backend records. A nil
+// mapping exchanges resources unchanged.
+type mapping []fieldMapping
+
+// newMapping parses the field mappings of a ResourceConfig
+func newMapping(fields map[string]string) (mapping, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if _, ok := fields["id"]; !ok {
+		return nil, fmt.Errorf("fields must map id")
+	}
+
+	// Apply mappings in a stable order, so overlapping paths behave the same
+	// on every request
+	attributes := make([]string, 0, len(fields))
+	for attr := range fields {
+		attributes = append(attributes, attr)
+	}
+	sort.Strings(attributes)
+
+	m := make(mapping, 0, len(fields))
+	for _, attr := range attributes {
+		attribute, err := parseFieldPath(attr)
+		if err != nil {
+			return nil, err
+		}
+		field, err := parseFieldPath(fields[attr])
+		if err != nil {
+			return nil, fmt.Errorf("field of %s: %w", attr, err)
+		}
+		m = append(m, fieldMapping{attribute: attribute, field: field})
+	}
+	return m, nil
+}
+
+// toRecord returns the backend record of a SCIM resource
+func (m mapping) toRecord(resource any) (any, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(data)
+	if err != nil || m == nil {
+		return doc, err
+	}
+
+	source, _ := doc.(map[string]any)
+	record := make(map[string]any)
+	for _, fm := range m {
+		if value, ok := fm.attribute.get(source); ok {
+			if err := fm.field.set(record, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return record, nil
+}
+
+// fromRecord decodes a backend record into the SCIM resource out
+func (m mapping) fromRecord(record any, out any) error {
+	doc := record
+	if m != nil {
+		resource := make(map[string]any)
+		for _, fm := range m {
+			if value, ok := fm.field.get(record); ok {
+				if err := fm.attribute.set(resource, value); err != nil {
+					return err
+				}
+			}
+		}
+		doc = resource
+	}
+
+	// Backends often use numeric IDs; SCIM IDs are strings
+	if resource, ok := doc.(map[string]any); ok {
+		if id, ok := resource["id"]; ok && id != nil {
+			if _, isString := id.(string); !isString {
+				resource["id"] = fmt.Sprint(id)
+			}
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// decodeJSON decodes data keeping numbers exact, so large numeric IDs
+// survive the round trip
+func decodeJSON(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
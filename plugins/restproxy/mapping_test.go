@@ -0,0 +1,123 @@
+package restproxy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestFieldPathGet(t *testing.T) {
+	doc := map[string]any{
+		"name": map[string]any{"givenName": "John"},
+		"emails": []any{
+			map[string]any{"value": "work@example.com"},
+			map[string]any{"value": "home@example.com"},
+		},
+		"members": []any{map[string]any{"value": "1"}, map[string]any{"display": "no value"}},
+	}
+
+	tests := []struct {
+		path   string
+		want   any
+		wantOK bool
+	}{
+		{"name.givenName", "John", true},
+		{"emails[1].value", "home@example.com", true},
+		{"emails[2].value", nil, false},
+		{"members[].value", []any{"1"}, true},
+		{"members[].type", nil, false},
+		{"name.familyName", nil, false},
+		{"name.givenName.first", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := parseFieldPath(tt.path)
+			if err != nil {
+				t.Fatalf("parseFieldPath() error = %v", err)
+			}
+			got, ok := path.get(doc)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("get() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFieldPathSet(t *testing.T) {
+	doc := make(map[string]any)
+	sets := []struct {
+		path  string
+		value any
+	}{
+		{"profile.firstName", "John"},
+		{"profile.lastName", "Doe"},
+		{"emails[1].value", "home@example.com"},
+		{"members[].value", []any{"1", "2"}},
+		{"members[].type", []any{"User"}},
+		{"tags[]", []any{"a"}},
+	}
+	for _, s := range sets {
+		path, err := parseFieldPath(s.path)
+		if err != nil {
+			t.Fatalf("parseFieldPath(%q) error = %v", s.path, err)
+		}
+		if err := path.set(doc, s.value); err != nil {
+			t.Fatalf("set(%q) error = %v", s.path, err)
+		}
+	}
+
+	want := `{"emails":[null,{"value":"home@example.com"}],` +
+		`"members":[{"type":"User","value":"1"},{"value":"2"}],` +
+		`"profile":{"firstName":"John","lastName":"Doe"},"tags":["a"]}`
+	if got, _ := json.Marshal(doc); string(got) != want {
+		t.Errorf("set() = %s, want %s", got, want)
+	}
+
+	path, _ := parseFieldPath("members[].value")
+	if err := path.set(doc, "1"); err == nil {
+		t.Error("set() of a spread path to a scalar should fail")
+	}
+}
+
+func TestParseFieldPathErrors(t *testing.T) {
+	for _, path := range []string{"", "name.", ".name", "emails[0", "emails[-1]", "emails[x]", "[0]"} {
+		if _, err := parseFieldPath(path); err == nil {
+			t.Errorf("parseFieldPath(%q) should fail", path)
+		}
+	}
+}
+
+func TestMappingRoundTrip(t *testing.T) {
+	m, err := newMapping(map[string]string{
+		"id":       "uid",
+		"userName": "login",
+	})
+	if err != nil {
+		t.Fatalf("newMapping() error = %v", err)
+	}
+
+	record, err := m.toRecord(map[string]any{"id": "7", "userName": "jdoe", "title": "Engineer"})
+	if err != nil {
+		t.Fatalf("toRecord() error = %v", err)
+	}
+	if want := (map[string]any{"uid": "7", "login": "jdoe"}); !reflect.DeepEqual(record, want) {
+		t.Errorf("toRecord() = %v, want %v", record, want)
+	}
+
+	// Large numeric IDs are kept exactly and become strings
+	doc, err := decodeJSON([]byte(`{"uid": 9007199254740993, "login": "jdoe"}`))
+	if err != nil {
+		t.Fatalf("decodeJSON() error = %v", err)
+	}
+	var out struct {
+		ID       string `json:"id"`
+		UserName string `json:"userName"`
+	}
+	if err := m.fromRecord(doc, &out); err != nil {
+		t.Fatalf("fromRecord() error = %v", err)
+	}
+	if out.ID != "9007199254740993" || out.UserName != "jdoe" {
+		t.Errorf("fromRecord() = %+v", out)
+	}
+}
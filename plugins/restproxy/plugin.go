@@ -0,0 +1,469 @@
+// Package restproxy implements a plugin that forwards SCIM operations to an
+// existing REST API, so a gateway can bridge SCIM to an internal HTTP service
+// without a custom plugin.
+//
+// Each resource type is described by endpoint templates and a mapping of SCIM
+// attributes to backend fields:
+//
+//	p, err := restproxy.NewRESTProxyPlugin(restproxy.Config{
+//	    Name:    "hr",
+//	    BaseURL: "https://hr.example.com/api/v1",
+//	    Auth:    restproxy.AuthConfig{Type: restproxy.AuthBearer, Token: token},
+//	    Users: restproxy.ResourceConfig{
+//	        List:   restproxy.Endpoint{Method: "GET", Path: "/employees"},
+//	        Get:    restproxy.Endpoint{Method: "GET", Path: "/employees/{id}"},
+//	        Create: restproxy.Endpoint{Method: "POST", Path: "/employees"},
+//	        Fields: map[string]string{
+//	            "id":       "employeeId",
+//	            "userName": "login",
+//	        },
+//	    },
+//	})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	gw.RegisterPlugin(p)
+package restproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+	"github.com/marcelom97/scimgateway/version"
+)
+
+// maxErrorBody limits how much of a backend error response is included in
+// the SCIM error detail
+const maxErrorBody = 512
+
+// RESTProxyPlugin forwards SCIM operations to a REST API. It holds no state
+// of its own: every operation is one or more requests to the backend, and
+// list requests read every record and leave filtering, sorting and paging to
+// the gateway.
+type RESTProxyPlugin struct {
+	name    string
+	baseURL string
+	client  *http.Client
+	auth    AuthConfig
+	headers map[string]string
+
+	users  resource
+	groups resource
+}
+
+// resource is a ResourceConfig with its parsed mapping
+type resource struct {
+	ResourceConfig
+	resourceType string
+	itemsPath    fieldPath // nil when the List response is the array
+	mapping      mapping
+}
+
+// NewRESTProxyPlugin validates cfg and returns a plugin forwarding to
+// cfg.BaseURL. It does not contact the backend.
+func NewRESTProxyPlugin(cfg Config) (*RESTProxyPlugin, error) {
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("restproxy plugin: baseURL is required")
+	}
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("restproxy plugin: invalid baseURL %q: must be an http or https URL", config.Redact(cfg.BaseURL))
+	}
+	if err := cfg.Auth.Validate(); err != nil {
+		return nil, fmt.Errorf("restproxy plugin: %w", err)
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		timeout := cfg.Timeout
+		if timeout == 0 {
+			timeout = DefaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	p := &RESTProxyPlugin{
+		name:    cfg.Name,
+		baseURL: strings.TrimSuffix(cfg.BaseURL, "/"),
+		client:  client,
+		auth:    cfg.Auth,
+		headers: cfg.Headers,
+	}
+	if p.users, err = newResource("User", cfg.Users); err != nil {
+		return nil, fmt.Errorf("restproxy plugin: users: %w", err)
+	}
+	if p.groups, err = newResource("Group", cfg.Groups); err != nil {
+		return nil, fmt.Errorf("restproxy plugin: groups: %w", err)
+	}
+	return p, nil
+}
+
+// newResource parses the items path and field mapping of cfg
+func newResource(resourceType string, cfg ResourceConfig) (resource, error) {
+	r := resource{ResourceConfig: cfg, resourceType: resourceType}
+
+	var err error
+	if cfg.ItemsPath != "" {
+		if r.itemsPath, err = parseFieldPath(cfg.ItemsPath); err != nil {
+			return r, fmt.Errorf("itemsPath: %w", err)
+		}
+	}
+	if r.mapping, err = newMapping(cfg.Fields); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// Name returns the plugin name
+func (p *RESTProxyPlugin) Name() string {
+	return p.name
+}
+
+// GetUsers retrieves every user record from the backend
+func (p *RESTProxyPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	records, err := p.list(ctx, &p.users)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]*scim.User, 0, len(records))
+	for _, record := range records {
+		user := &scim.User{}
+		if err := p.decode(&p.users, record, user); err != nil {
+			return nil, err
+		}
+		users = append(users, withUserDefaults(user))
+	}
+	return users, nil
+}
+
+// CreateUser creates a user record and returns the record the backend
+// responded with, or user if the response has no body
+func (p *RESTProxyPlugin) CreateUser(ctx context.Context, user *scim.User) (*scim.User, error) {
+	if p.users.Create.IsZero() {
+		return nil, scim.ErrNotImplemented("creating users")
+	}
+	record, err := p.write(ctx, &p.users, p.users.Create, "", user)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		user = &scim.User{}
+		if err := p.decode(&p.users, record, user); err != nil {
+			return nil, err
+		}
+	}
+	if user.ID == "" {
+		return nil, scim.ErrInternalServer("backend did not return the ID of the created user")
+	}
+	return withUserDefaults(user), nil
+}
+
+// GetUser retrieves a user record by ID
+func (p *RESTProxyPlugin) GetUser(ctx context.Context, id string, attributes []string) (*scim.User, error) {
+	record, err := p.get(ctx, &p.users, id)
+	if err != nil {
+		return nil, err
+	}
+	user := &scim.User{}
+	if err := p.decode(&p.users, record, user); err != nil {
+		return nil, err
+	}
+	return withUserDefaults(user), nil
+}
+
+// ModifyUser applies the patch to the user record read from the backend and
+// sends the result to the Update endpoint
+func (p *RESTProxyPlugin) ModifyUser(ctx context.Context, id string, patch *scim.PatchOp) error {
+	if p.users.Update.IsZero() {
+		return scim.ErrNotImplemented("updating users")
+	}
+	user, err := p.GetUser(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+
+	patcher := scim.NewPatchProcessor()
+	if err := patcher.ApplyPatch(user, patch); err != nil {
+		return scim.ErrInvalidSyntax(fmt.Sprintf("failed to apply patch: %v", err))
+	}
+
+	_, err = p.write(ctx, &p.users, p.users.Update, id, user)
+	return err
+}
+
+// DeleteUser deletes a user record
+func (p *RESTProxyPlugin) DeleteUser(ctx context.Context, id string) error {
+	if p.users.Delete.IsZero() {
+		return scim.ErrNotImplemented("deleting users")
+	}
+	_, err := p.do(ctx, &p.users, p.users.Delete, id, nil)
+	return err
+}
+
+// GetGroups retrieves every group record from the backend
+func (p *RESTProxyPlugin) GetGroups(ctx context.Context, params scim.QueryParams) ([]*scim.Group, error) {
+	records, err := p.list(ctx, &p.groups)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*scim.Group, 0, len(records))
+	for _, record := range records {
+		group := &scim.Group{}
+		if err := p.decode(&p.groups, record, group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, withGroupDefaults(group))
+	}
+	return groups, nil
+}
+
+// CreateGroup creates a group record and returns the record the backend
+// responded with, or group if the response has no body
+func (p *RESTProxyPlugin) CreateGroup(ctx context.Context, group *scim.Group) (*scim.Group, error) {
+	if p.groups.Create.IsZero() {
+		return nil, scim.ErrNotImplemented("creating groups")
+	}
+	record, err := p.write(ctx, &p.groups, p.groups.Create, "", group)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		group = &scim.Group{}
+		if err := p.decode(&p.groups, record, group); err != nil {
+			return nil, err
+		}
+	}
+	if group.ID == "" {
+		return nil, scim.ErrInternalServer("backend did not return the ID of the created group")
+	}
+	return withGroupDefaults(group), nil
+}
+
+// GetGroup retrieves a group record by ID
+func (p *RESTProxyPlugin) GetGroup(ctx context.Context, id string, attributes []string) (*scim.Group, error) {
+	record, err := p.get(ctx, &p.groups, id)
+	if err != nil {
+		return nil, err
+	}
+	group := &scim.Group{}
+	if err := p.decode(&p.groups, record, group); err != nil {
+		return nil, err
+	}
+	return withGroupDefaults(group), nil
+}
+
+// ModifyGroup applies the patch to the group record read from the backend
+// and sends the result to the Update endpoint
+func (p *RESTProxyPlugin) ModifyGroup(ctx context.Context, id string, patch *scim.PatchOp) error {
+	if p.groups.Update.IsZero() {
+		return scim.ErrNotImplemented("updating groups")
+	}
+	group, err := p.GetGroup(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+
+	patcher := scim.NewPatchProcessor()
+	if err := patcher.ApplyPatch(group, patch); err != nil {
+		return scim.ErrInvalidSyntax(fmt.Sprintf("failed to apply patch: %v", err))
+	}
+
+	_, err = p.write(ctx, &p.groups, p.groups.Update, id, group)
+	return err
+}
+
+// DeleteGroup deletes a group record
+func (p *RESTProxyPlugin) DeleteGroup(ctx context.Context, id string) error {
+	if p.groups.Delete.IsZero() {
+		return scim.ErrNotImplemented("deleting groups")
+	}
+	_, err := p.do(ctx, &p.groups, p.groups.Delete, id, nil)
+	return err
+}
+
+// MapUser implements scim.BackendMapper, returning the record CreateUser
+// would send for user
+func (p *RESTProxyPlugin) MapUser(ctx context.Context, user *scim.User) (any, error) {
+	return p.users.mapping.toRecord(user)
+}
+
+// MapGroup implements scim.BackendMapper
+func (p *RESTProxyPlugin) MapGroup(ctx context.Context, group *scim.Group) (any, error) {
+	return p.groups.mapping.toRecord(group)
+}
+
+// withUserDefaults sets the schemas and meta the backend does not store
+func withUserDefaults(user *scim.User) *scim.User {
+	if len(user.Schemas) == 0 {
+		user.Schemas = []string{scim.SchemaUser}
+	}
+	if user.Meta == nil {
+		user.Meta = &scim.Meta{}
+	}
+	user.Meta.ResourceType = "User"
+	return user
+}
+
+// withGroupDefaults sets the schemas and meta the backend does not store
+func withGroupDefaults(group *scim.Group) *scim.Group {
+	if len(group.Schemas) == 0 {
+		group.Schemas = []string{scim.SchemaGroup}
+	}
+	if group.Meta == nil {
+		group.Meta = &scim.Meta{}
+	}
+	group.Meta.ResourceType = "Group"
+	return group
+}
+
+// list calls the List endpoint of r and returns its records
+func (p *RESTProxyPlugin) list(ctx context.Context, r *resource) ([]any, error) {
+	if r.List.IsZero() {
+		return nil, scim.ErrNotImplemented(fmt.Sprintf("listing %ss", strings.ToLower(r.resourceType)))
+	}
+	body, err := p.do(ctx, r, r.List, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := body
+	if r.itemsPath != nil {
+		var ok bool
+		if items, ok = r.itemsPath.get(body); !ok {
+			return nil, nil
+		}
+	}
+	if items == nil {
+		return nil, nil
+	}
+	records, ok := items.([]any)
+	if !ok {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("backend list response is not an array at %q", r.ItemsPath))
+	}
+	return records, nil
+}
+
+// get calls the Get endpoint of r for id
+func (p *RESTProxyPlugin) get(ctx context.Context, r *resource, id string) (any, error) {
+	if r.Get.IsZero() {
+		return nil, scim.ErrNotImplemented(fmt.Sprintf("reading %ss", strings.ToLower(r.resourceType)))
+	}
+	record, err := p.do(ctx, r, r.Get, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, scim.ErrNotFound(r.resourceType, id)
+	}
+	return record, nil
+}
+
+// write sends the mapped resource to endpoint and returns the response record
+func (p *RESTProxyPlugin) write(ctx context.Context, r *resource, endpoint Endpoint, id string, resource any) (any, error) {
+	record, err := r.mapping.toRecord(resource)
+	if err != nil {
+		return nil, scim.ErrInvalidValue(fmt.Sprintf("failed to map %s: %v", strings.ToLower(r.resourceType), err))
+	}
+	return p.do(ctx, r, endpoint, id, record)
+}
+
+// decode maps a backend record into the SCIM resource out
+func (p *RESTProxyPlugin) decode(r *resource, record any, out any) error {
+	if err := r.mapping.fromRecord(record, out); err != nil {
+		return scim.ErrInternalServer(fmt.Sprintf("failed to map backend %s: %v", strings.ToLower(r.resourceType), err))
+	}
+	return nil
+}
+
+// do sends a request to endpoint and returns the decoded JSON response body,
+// or nil if it is empty. Error statuses are translated to SCIM errors.
+func (p *RESTProxyPlugin) do(ctx context.Context, r *resource, endpoint Endpoint, id string, body any) (any, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, scim.ErrInternalServer(fmt.Sprintf("failed to encode request: %v", err))
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	target := p.baseURL + endpoint.expand(id, scim.BaseEntityFromContext(ctx))
+	req, err := http.NewRequestWithContext(ctx, endpoint.Method, target, reader)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("invalid backend request: %v", err))
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+	scimcontext.InjectTraceHeaders(ctx, req.Header)
+	p.auth.apply(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("backend request %s failed: %v", endpoint, err))
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("failed to read backend response: %v", err))
+	}
+	if resp.StatusCode >= 300 {
+		return nil, statusError(r.resourceType, id, resp.StatusCode, data)
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	decoded, err := decodeJSON(data)
+	if err != nil {
+		return nil, scim.ErrInternalServer(fmt.Sprintf("backend response is not JSON: %v", err))
+	}
+	return decoded, nil
+}
+
+// statusError translates a backend error status to a SCIM error. Backend
+// authentication failures are the gateway's problem, not the client's, so
+// they become internal errors.
+func statusError(resourceType, id string, status int, body []byte) error {
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	detail := fmt.Sprintf("backend returned %d", status)
+	if text := strings.TrimSpace(string(body)); text != "" {
+		detail += ": " + text
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		if id != "" {
+			return scim.ErrNotFound(resourceType, id)
+		}
+	case http.StatusConflict:
+		return scim.ErrUniqueness(detail)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return scim.ErrInvalidValue(detail)
+	case http.StatusPreconditionFailed:
+		return scim.ErrPreconditionFailed(detail)
+	}
+	return scim.ErrInternalServer(detail)
+}
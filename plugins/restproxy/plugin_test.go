@@ -0,0 +1,333 @@
+package restproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+	"github.com/marcelom97/scimgateway/test"
+	"github.com/marcelom97/scimgateway/version"
+)
+
+// fakeBackend is an in-memory REST API with numeric IDs, stored in the
+// field "id" unless idFields names another. Each collection rejects a second
+// record with the same value of its unique field.
+type fakeBackend struct {
+	mu       sync.Mutex
+	nextID   int
+	records  map[string]map[string]map[string]any // collection -> id -> record
+	unique   map[string]string                    // collection -> unique field
+	idFields map[string]string                    // collection -> ID field
+	requests []*http.Request
+}
+
+func newFakeBackend(unique, idFields map[string]string) *fakeBackend {
+	return &fakeBackend{
+		records:  make(map[string]map[string]map[string]any),
+		unique:   unique,
+		idFields: idFields,
+	}
+}
+
+func (b *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, r)
+
+	collection, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	idField := b.idFields[collection]
+	if idField == "" {
+		idField = "id"
+	}
+	records := b.records[collection]
+	if records == nil {
+		records = make(map[string]map[string]any)
+		b.records[collection] = records
+	}
+
+	var body map[string]any
+	if r.Method == http.MethodPost || r.Method == http.MethodPut {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if field := b.unique[collection]; field != "" {
+			for existingID, existing := range records {
+				if existingID != id && existing[field] == body[field] {
+					http.Error(w, "duplicate "+field, http.StatusConflict)
+					return
+				}
+			}
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && id == "":
+		items := make([]map[string]any, 0, len(records))
+		for _, record := range records {
+			items = append(items, record)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": items}) // nolint:errcheck
+	case r.Method == http.MethodPost && id == "":
+		b.nextID++
+		body[idField] = b.nextID
+		records[strconv.Itoa(b.nextID)] = body
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body) // nolint:errcheck
+	case records[id] == nil:
+		http.Error(w, "not found", http.StatusNotFound)
+	case r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(records[id]) // nolint:errcheck
+	case r.Method == http.MethodPut:
+		body[idField], _ = strconv.Atoi(id)
+		records[id] = body
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(records, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// crudEndpoints returns a ResourceConfig with every endpoint of collection
+func crudEndpoints(collection string, fields map[string]string) ResourceConfig {
+	return ResourceConfig{
+		List:      Endpoint{Method: "GET", Path: "/" + collection},
+		Get:       Endpoint{Method: "GET", Path: "/" + collection + "/{id}"},
+		Create:    Endpoint{Method: "POST", Path: "/" + collection},
+		Update:    Endpoint{Method: "PUT", Path: "/" + collection + "/{id}"},
+		Delete:    Endpoint{Method: "DELETE", Path: "/" + collection + "/{id}"},
+		ItemsPath: "data",
+		Fields:    fields,
+	}
+}
+
+// TestRESTProxyCompliance runs the SCIM compliance suite against a backend
+// storing SCIM resources unchanged
+func TestRESTProxyCompliance(t *testing.T) {
+	backend := httptest.NewServer(newFakeBackend(map[string]string{"users": "userName"}, nil))
+	t.Cleanup(backend.Close)
+
+	p, err := NewRESTProxyPlugin(Config{
+		Name:    "test",
+		BaseURL: backend.URL,
+		Users:   crudEndpoints("users", nil),
+		Groups:  crudEndpoints("groups", nil),
+	})
+	if err != nil {
+		t.Fatalf("NewRESTProxyPlugin() error = %v", err)
+	}
+
+	test.RunComplianceSuite(t, test.NewComplianceHandler(t, p))
+}
+
+func TestRESTProxyFieldMapping(t *testing.T) {
+	fake := newFakeBackend(
+		map[string]string{"employees": "login"},
+		map[string]string{"employees": "employeeId", "teams": "teamId"},
+	)
+	backend := httptest.NewServer(fake)
+	t.Cleanup(backend.Close)
+
+	p, err := NewRESTProxyPlugin(Config{
+		Name:    "hr",
+		BaseURL: backend.URL,
+		Auth:    AuthConfig{Type: AuthBearer, Token: "secret"},
+		Headers: map[string]string{"X-Tenant": "acme"},
+		Users: crudEndpoints("employees", map[string]string{
+			"id":              "employeeId",
+			"userName":        "login",
+			"name.givenName":  "profile.firstName",
+			"emails[0].value": "email",
+			"active":          "enabled",
+		}),
+		Groups: crudEndpoints("teams", map[string]string{
+			"id":              "teamId",
+			"displayName":     "title",
+			"members[].value": "memberIds",
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewRESTProxyPlugin() error = %v", err)
+	}
+
+	// The trace of the SCIM request continues at the backend
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := scimcontext.WithTraceHeaders(context.Background(), http.Header{"Traceparent": {traceparent}})
+	user, err := p.CreateUser(ctx, &scim.User{
+		UserName: "jdoe",
+		Name:     &scim.Name{GivenName: "John"},
+		Emails:   []scim.Email{{Value: "jdoe@example.com", Primary: scim.Bool(true)}},
+		Active:   scim.Bool(false),
+		Title:    "Engineer",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if user.ID != "1" {
+		t.Errorf("CreateUser() ID = %q, want the backend's numeric ID as a string", user.ID)
+	}
+
+	// Only mapped fields are sent
+	want := map[string]any{
+		"employeeId": 1,
+		"login":      "jdoe",
+		"profile":    map[string]any{"firstName": "John"},
+		"email":      "jdoe@example.com",
+		"enabled":    false,
+	}
+	if got, _ := json.Marshal(fake.records["employees"]["1"]); string(got) != mustJSON(t, want) {
+		t.Errorf("backend record = %s, want %s", got, mustJSON(t, want))
+	}
+
+	request := fake.requests[0]
+	if got := request.Header.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization = %q, want bearer token", got)
+	}
+	if got := request.Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("X-Tenant = %q, want acme", got)
+	}
+	if got := request.Header.Get("Traceparent"); got != traceparent {
+		t.Errorf("Traceparent = %q, want %q", got, traceparent)
+	}
+	if got := request.Header.Get("User-Agent"); got != version.UserAgent() {
+		t.Errorf("User-Agent = %q, want %q", got, version.UserAgent())
+	}
+
+	got, err := p.GetUser(ctx, "1", nil)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if got.UserName != "jdoe" || got.Name.GivenName != "John" || got.Emails[0].Value != "jdoe@example.com" ||
+		got.Active == nil || bool(*got.Active) || got.Title != "" {
+		t.Errorf("GetUser() = %+v", got)
+	}
+	if got.Meta.ResourceType != "User" || len(got.Schemas) != 1 || got.Schemas[0] != scim.SchemaUser {
+		t.Errorf("GetUser() schemas = %v, meta = %+v", got.Schemas, got.Meta)
+	}
+
+	patch := &scim.PatchOp{Operations: []scim.PatchOperation{{Op: "replace", Path: "active", Value: true}}}
+	if err := p.ModifyUser(ctx, "1", patch); err != nil {
+		t.Fatalf("ModifyUser() error = %v", err)
+	}
+	if enabled := fake.records["employees"]["1"]["enabled"]; enabled != true {
+		t.Errorf("ModifyUser() enabled = %v, want true", enabled)
+	}
+
+	group, err := p.CreateGroup(ctx, &scim.Group{
+		DisplayName: "Engineering",
+		Members:     []scim.MemberRef{{Value: "1"}, {Value: "2"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if len(group.Members) != 2 || group.Members[0].Value != "1" || group.Members[1].Value != "2" {
+		t.Errorf("CreateGroup() members = %+v", group.Members)
+	}
+	if ids := fake.records["teams"]["2"]["memberIds"]; mustJSON(t, ids) != `["1","2"]` {
+		t.Errorf("backend memberIds = %v", ids)
+	}
+
+	users, err := p.GetUsers(ctx, scim.QueryParams{})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if len(users) != 1 || users[0].UserName != "jdoe" {
+		t.Errorf("GetUsers() = %v", users)
+	}
+
+	// Simulations report the record a write would send
+	record, err := p.MapUser(ctx, &scim.User{UserName: "asmith", Active: scim.Bool(true)})
+	if err != nil {
+		t.Fatalf("MapUser() error = %v", err)
+	}
+	if got, want := mustJSON(t, record), `{"employeeId":"","enabled":true,"login":"asmith"}`; got != want {
+		t.Errorf("MapUser() = %s, want %s", got, want)
+	}
+}
+
+func TestRESTProxyErrors(t *testing.T) {
+	backend := httptest.NewServer(newFakeBackend(map[string]string{"users": "userName"}, nil))
+	t.Cleanup(backend.Close)
+
+	p, err := NewRESTProxyPlugin(Config{
+		Name:    "test",
+		BaseURL: backend.URL,
+		Users:   crudEndpoints("users", nil),
+		Groups:  ResourceConfig{Get: Endpoint{Method: "GET", Path: "/groups/{id}"}},
+	})
+	if err != nil {
+		t.Fatalf("NewRESTProxyPlugin() error = %v", err)
+	}
+
+	status := func(err error) int {
+		var scimErr *scim.SCIMError
+		if errors.As(err, &scimErr) {
+			return scimErr.Status
+		}
+		return 0
+	}
+
+	ctx := context.Background()
+	if _, err := p.GetUser(ctx, "42", nil); status(err) != http.StatusNotFound {
+		t.Errorf("GetUser() of a missing user error = %v, want 404", err)
+	}
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if _, err := p.CreateUser(ctx, &scim.User{UserName: "john"}); status(err) != http.StatusConflict {
+		t.Errorf("CreateUser() of a duplicate error = %v, want 409", err)
+	}
+	if _, err := p.CreateGroup(ctx, &scim.Group{DisplayName: "Admins"}); status(err) != http.StatusNotImplemented {
+		t.Errorf("CreateGroup() without an endpoint error = %v, want 501", err)
+	}
+	if err := p.DeleteGroup(ctx, "1"); status(err) != http.StatusNotImplemented {
+		t.Errorf("DeleteGroup() without an endpoint error = %v, want 501", err)
+	}
+
+	backend.Close()
+	if _, err := p.GetUsers(ctx, scim.QueryParams{}); status(err) != http.StatusInternalServerError {
+		t.Errorf("GetUsers() of an unreachable backend error = %v, want 500", err)
+	}
+}
+
+func TestNewRESTProxyPluginValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"missing baseURL", Config{}},
+		{"relative baseURL", Config{BaseURL: "/api"}},
+		{"unsupported scheme", Config{BaseURL: "ftp://example.com"}},
+		{"bearer without token", Config{BaseURL: "https://example.com", Auth: AuthConfig{Type: AuthBearer}}},
+		{"unknown auth", Config{BaseURL: "https://example.com", Auth: AuthConfig{Type: "digest"}}},
+		{"fields without id", Config{BaseURL: "https://example.com", Users: ResourceConfig{Fields: map[string]string{"userName": "login"}}}},
+		{"invalid field path", Config{BaseURL: "https://example.com", Groups: ResourceConfig{Fields: map[string]string{"id": "id", "members[x]": "ids"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRESTProxyPlugin(tt.cfg); err == nil {
+				t.Error("NewRESTProxyPlugin() should fail")
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return string(data)
+}
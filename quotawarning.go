@@ -0,0 +1,51 @@
+package scimgateway
+
+import (
+	"net/http"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// QuotaWarningMiddleware collects the warnings of creates bringing a plugin
+// near its quota (see config.QuotaConfig.WarnPercent) and sets each as an
+// X-Quota-Warning response header, so identity provider operators see them
+// before creates are rejected
+func QuotaWarningMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			warnings := &scimcontext.QuotaWarnings{}
+			r = r.WithContext(scimcontext.WithQuotaWarnings(r.Context(), warnings))
+			next.ServeHTTP(&quotaWarningWriter{ResponseWriter: w, warnings: warnings}, r)
+		})
+	}
+}
+
+// quotaWarningWriter sets the X-Quota-Warning headers when the response
+// header is written, after the plugin created the resource
+type quotaWarningWriter struct {
+	http.ResponseWriter
+	warnings *scimcontext.QuotaWarnings
+	written  bool
+}
+
+func (qw *quotaWarningWriter) WriteHeader(code int) {
+	if !qw.written {
+		qw.written = true
+		for _, warning := range qw.warnings.Warnings() {
+			qw.Header().Add("X-Quota-Warning", warning)
+		}
+	}
+	qw.ResponseWriter.WriteHeader(code)
+}
+
+func (qw *quotaWarningWriter) Write(b []byte) (int, error) {
+	if !qw.written {
+		qw.WriteHeader(http.StatusOK)
+	}
+	return qw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (qw *quotaWarningWriter) Unwrap() http.ResponseWriter {
+	return qw.ResponseWriter
+}
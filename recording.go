@@ -0,0 +1,231 @@
+package scimgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// replayRequest is the body of POST /{plugin}/_recordings/replay: the
+// exchanges to replay, by ID or the latest count
+type replayRequest struct {
+	IDs   []string `json:"ids"`
+	Count int      `json:"count"`
+}
+
+// replayResult is the outcome of replaying a recorded exchange
+type replayResult struct {
+	ID             string          `json:"id"`
+	Method         string          `json:"method"`
+	Path           string          `json:"path"`
+	RecordedStatus int             `json:"recordedStatus"`
+	Status         int             `json:"status,omitempty"`
+	Body           json.RawMessage `json:"body,omitempty"`
+
+	// Error is the reason the exchange was not replayed
+	Error string `json:"error,omitempty"`
+}
+
+// RecordingMiddleware records the requests to plugins configured with
+// recording and their responses (see plugin.Recorder), and serves them:
+// GET /{plugin}/_recordings lists the latest exchanges, oldest first, or
+// the latest count ones with the count query parameter, GET
+// /{plugin}/_recordings/{id} returns one, and POST
+// /{plugin}/_recordings/replay sends the exchanges of its body, with ids or
+// the latest count, to the plugin again, oldest first, and returns their
+// new responses. Replays are not recorded, and authorization checks each
+// replayed request for the replaying client, so it replays only what it
+// could send itself. Requests routed to a base entity see its exchanges
+// only. It is placed behind the plugin's authentication, and authorization
+// grants its endpoints with the admin operation. Other requests to plugins
+// without recording are passed to next.
+func RecordingMiddleware(manager *plugin.Manager, logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			recorder, ok := manager.GetRecorder(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rest, isRecordings := strings.CutPrefix(endpoint, "_recordings")
+			switch {
+			case isRecordings && rest == "" && r.Method == http.MethodGet:
+				count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+				exchanges := recorder.Exchanges(r.Context(), count)
+				if exchanges == nil {
+					exchanges = []*plugin.Exchange{}
+				}
+				writeAdminJSON(w, scim.ListResponse[*plugin.Exchange]{
+					Schemas:      []string{scim.SchemaListResponse},
+					TotalResults: len(exchanges),
+					StartIndex:   1,
+					ItemsPerPage: len(exchanges),
+					Resources:    exchanges,
+				})
+			case isRecordings && rest == "/replay" && r.Method == http.MethodPost:
+				replayExchanges(w, r, next, manager, recorder)
+			case isRecordings && strings.HasPrefix(rest, "/") && !strings.Contains(rest[1:], "/") && r.Method == http.MethodGet:
+				id := rest[1:]
+				ex, ok := recorder.Exchange(r.Context(), id)
+				if !ok {
+					scim.NewHandler("").WriteError(w, http.StatusNotFound, fmt.Sprintf("Exchange %s not found", id), "")
+					return
+				}
+				writeAdminJSON(w, ex)
+			case isRecordings && (rest == "" || rest[0] == '/'):
+				next.ServeHTTP(w, r)
+			default:
+				record(w, r, next, recorder, logger)
+			}
+		})
+	}
+}
+
+// record serves r by next and records the exchange with recorder
+func record(w http.ResponseWriter, r *http.Request, next http.Handler, recorder *plugin.Recorder, logger *slog.Logger) {
+	limit := recorder.MaxBodySize()
+
+	// Record the beginning of the body, and pass all of it on
+	var body []byte
+	if r.Body != nil && r.Body != http.NoBody {
+		// A read error is met again by the handler reading the rest
+		body, _ = io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	}
+
+	rw := &recordingWriter{ResponseWriter: w, limit: limit}
+	start := time.Now()
+	next.ServeHTTP(rw, r)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	if err := recorder.Record(r, body, rw.status, rw.body.Bytes(), time.Since(start)); err != nil {
+		logger.WarnContext(r.Context(), "failed to write recorded exchange", "path", r.URL.Path, "error", err)
+	}
+}
+
+// recordingWriter captures the status and the beginning of the body of a
+// response
+type recordingWriter struct {
+	http.ResponseWriter
+	limit  int
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	if rw.status == 0 {
+		rw.status = code
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if room := rw.limit + 1 - rw.body.Len(); room > 0 {
+		rw.body.Write(b[:min(room, len(b))])
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// replayExchanges serves POST /{plugin}/_recordings/replay
+func replayExchanges(w http.ResponseWriter, r *http.Request, next http.Handler, manager *plugin.Manager, recorder *plugin.Recorder) {
+	var req replayRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		scim.NewHandler("").WriteSCIMError(w, scim.ErrInvalidSyntax("Invalid JSON"))
+		return
+	}
+	if (len(req.IDs) > 0) == (req.Count > 0) {
+		scim.NewHandler("").WriteSCIMError(w, scim.ErrInvalidValue("either ids or a positive count is required"))
+		return
+	}
+
+	var exchanges []*plugin.Exchange
+	if len(req.IDs) > 0 {
+		for _, id := range req.IDs {
+			ex, ok := recorder.Exchange(r.Context(), id)
+			if !ok {
+				scim.NewHandler("").WriteError(w, http.StatusNotFound, fmt.Sprintf("Exchange %s not found", id), "")
+				return
+			}
+			exchanges = append(exchanges, ex)
+		}
+	} else {
+		exchanges = recorder.Exchanges(r.Context(), req.Count)
+	}
+
+	// The replaying client is authorized for each recorded request like for
+	// its own, not only for the replay endpoint
+	target := plugin.AuthorizationMiddleware(manager)(next)
+	results := make([]replayResult, 0, len(exchanges))
+	for _, ex := range exchanges {
+		results = append(results, replay(r, target, manager, ex))
+	}
+	writeAdminJSON(w, results)
+}
+
+// replay sends the recorded request of ex to next again, on behalf of the
+// client of r, scoped to the base entity of ex
+func replay(r *http.Request, next http.Handler, manager *plugin.Manager, ex *plugin.Exchange) replayResult {
+	result := replayResult{ID: ex.ID, Method: ex.Method, Path: ex.Path, RecordedStatus: ex.Status}
+	if ex.BodyTruncated {
+		result.Error = "the request body was truncated"
+		return result
+	}
+
+	ctx := r.Context()
+	if ex.BaseEntity != "" && scim.BaseEntityFromContext(ctx) == "" {
+		entity, ok := manager.GetBaseEntity(ex.Plugin, ex.BaseEntity)
+		if !ok {
+			result.Error = fmt.Sprintf("base entity %s is not configured", ex.BaseEntity)
+			return result
+		}
+		ctx = scimcontext.WithTenantConfig(scim.WithBaseEntity(ctx, entity.Name), entity.Config)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, ex.Method, (&url.URL{Path: ex.Path, RawQuery: ex.Query}).String(), bytes.NewReader(ex.Body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header = ex.Header.Clone()
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	req.RemoteAddr = r.RemoteAddr
+
+	resp := &responseBuffer{header: make(http.Header)}
+	next.ServeHTTP(resp, req)
+	result.Status = resp.status
+	if resp.status == 0 {
+		result.Status = http.StatusOK
+	}
+	if body := bytes.TrimSpace(resp.body.Bytes()); json.Valid(body) {
+		result.Body = body
+	}
+	return result
+}
@@ -0,0 +1,144 @@
+package scimgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/plugin"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+func TestRecording(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "recording.jsonl")
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].Recording = &config.RecordingConfig{File: file}
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	var user scim.User
+	if err := json.Unmarshal(do("POST", "/test/Users", `{"userName": "alice", "password": "secret"}`, "token").Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	do("DELETE", "/test/Users/"+user.ID, "", "token")
+
+	// The recordings require the plugin's credentials
+	if w := do("GET", "/test/_recordings", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+
+	w := do("GET", "/test/_recordings", "", "token")
+	var list scim.ListResponse[*plugin.Exchange]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("recordings: %v, body: %s", err, w.Body.String())
+	}
+	if w.Code != http.StatusOK || list.TotalResults != 2 {
+		t.Fatalf("recordings status = %d, body: %s, want the create and the delete", w.Code, w.Body.String())
+	}
+	created := list.Resources[0]
+	if created.Method != "POST" || created.Status != http.StatusCreated || !created.Redacted {
+		t.Errorf("create = %+v, want a redacted 201 POST", created)
+	}
+	if strings.Contains(string(created.Body), "secret") || created.Header.Get("Authorization") != "" {
+		t.Errorf("create = %+v, want no password and credentials", created)
+	}
+	if list.Resources[1].Status != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", list.Resources[1].Status)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil || bytes.Count(data, []byte("\n")) != 2 || bytes.Contains(data, []byte("secret")) {
+		t.Errorf("recording file = %s, %v, want two exchanges without the password", data, err)
+	}
+
+	if w := do("GET", "/test/_recordings/"+created.ID, "", "token"); w.Code != http.StatusOK {
+		t.Errorf("exchange status = %d, want 200", w.Code)
+	}
+	if w := do("GET", "/test/_recordings/unknown", "", "token"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown exchange status = %d, want 404", w.Code)
+	}
+
+	// Replaying the create creates the deleted user again
+	w = do("POST", "/test/_recordings/replay", `{"ids": ["`+created.ID+`"]}`, "token")
+	var results []replayResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("replay: %v, body: %s", err, w.Body.String())
+	}
+	if len(results) != 1 || results[0].Status != http.StatusCreated || results[0].RecordedStatus != http.StatusCreated {
+		t.Errorf("replay = %s, want the user created again", w.Body.String())
+	}
+
+	// Admin requests and replays are not recorded
+	do("GET", "/test/_recordings", "", "token")
+	if exchanges := do("GET", "/test/_recordings?count=10", "", "token"); !strings.Contains(exchanges.Body.String(), `"totalResults":2`) {
+		t.Errorf("recordings = %s, want the two recorded exchanges only", exchanges.Body.String())
+	}
+
+	if w := do("POST", "/test/_recordings/replay", `{}`, "token"); w.Code != http.StatusBadRequest {
+		t.Errorf("replay without exchanges status = %d, want 400", w.Code)
+	}
+}
+
+func TestRecordingReplayAuthorization(t *testing.T) {
+	grants := map[string][]string{"scim.write": {"read", "delete"}, "auditor": {"admin"}}
+	manager := plugin.NewManager()
+	manager.Register(testutil.NewMemoryPlugin("test"), &config.PluginConfig{
+		Name:          "test",
+		Recording:     &config.RecordingConfig{},
+		Authorization: &config.AuthorizationConfig{Grants: grants},
+	})
+
+	var deletes int
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletes++
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := plugin.AuthorizationMiddleware(manager)(RecordingMiddleware(manager, slog.Default())(backend))
+	do := func(method, path, body, scope string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(scimcontext.WithIdentity(req.Context(), scimcontext.AuthIdentity{Scopes: []string{scope}}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	do("DELETE", "/test/Users/42", "", "scim.write")
+	if w := do("DELETE", "/test/Users/42", "", "auditor"); w.Code != http.StatusForbidden {
+		t.Fatalf("auditor delete status = %d, want 403", w.Code)
+	}
+
+	// The auditor may replay, but not the delete it may not send itself
+	w := do("POST", "/test/_recordings/replay", `{"count": 1}`, "auditor")
+	var results []replayResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatalf("replay: %v, body: %s", err, w.Body.String())
+	}
+	if len(results) != 1 || results[0].Status != http.StatusForbidden || deletes != 1 {
+		t.Errorf("replay = %s after %d deletes, want the delete rejected with 403", w.Body.String(), deletes)
+	}
+}
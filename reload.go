@@ -0,0 +1,177 @@
+package scimgateway
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+// Reload applies a new configuration at runtime without restarting the server.
+//
+// Plugin credentials, per-plugin settings, the base URL and TLS certificates are
+// replaced atomically: requests already in flight complete with the configuration
+// they started with, and new requests use the reloaded one. The configuration is
+// validated first and the current one is kept if validation fails.
+//
+// The listening port and whether TLS is enabled cannot change at runtime.
+// Plugins must still be registered with RegisterPlugin; configs for unregistered
+// plugins are ignored, as in Initialize.
+func (g *Gateway) Reload(cfg *config.Config) error {
+	g.reloadMu.Lock()
+	defer g.reloadMu.Unlock()
+
+	if err := cfg.Validate(); err != nil {
+		g.logger.Error("configuration reload rejected", "error", err)
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	current := g.Config()
+	if cfg.Gateway.Port != current.Gateway.Port {
+		return fmt.Errorf("gateway.port cannot be changed at runtime (%d -> %d): restart the gateway",
+			current.Gateway.Port, cfg.Gateway.Port)
+	}
+	if tlsEnabled(cfg) != tlsEnabled(current) {
+		return fmt.Errorf("gateway.tls.enabled cannot be changed at runtime: restart the gateway")
+	}
+
+	// Load the new certificate before changing anything, so a bad pair leaves
+	// the gateway untouched
+	g.mu.RLock()
+	certs := g.certs
+	g.mu.RUnlock()
+
+	var cert *tls.Certificate
+	if certs != nil {
+		var err error
+		if cert, err = loadCertificate(cfg.Gateway.TLS); err != nil {
+			g.logger.Error("configuration reload rejected", "error", err)
+			return err
+		}
+	}
+
+	for _, name := range g.pluginManager.List() {
+		g.pluginManager.UpdateConfig(name, findPluginConfig(cfg, name))
+	}
+
+	g.mu.Lock()
+	g.config = cfg
+	g.mu.Unlock()
+
+	g.setupDBPlugins()
+	g.scheduleJobs()
+
+	if g.handler != nil {
+		g.install(cfg)
+	}
+	if cert != nil {
+		certs.cert.Store(cert)
+	}
+
+	g.logger.Info("configuration reloaded",
+		"base_url", cfg.Gateway.BaseURL,
+		"plugins", g.pluginManager.List(),
+		"tls_certificate_reloaded", cert != nil,
+	)
+	g.logger.Debug("effective configuration", "config", cfg)
+	g.logDeprecations(cfg)
+
+	return nil
+}
+
+// WatchConfigFile polls a configuration file (see config.LoadFile) and the TLS
+// certificate files it references, and calls Reload whenever one of them changes.
+// Reload failures are logged and the current configuration stays active.
+//
+// It blocks until ctx is cancelled, so it is typically started in a goroutine:
+//
+//	go gw.WatchConfigFile(ctx, "gateway.yaml", 10*time.Second)
+func (g *Gateway) WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	last := g.watchedModTimes(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		modTimes := g.watchedModTimes(path)
+		if modTimes == last {
+			continue
+		}
+		last = modTimes
+
+		cfg, err := config.LoadFile(path)
+		if err != nil {
+			g.logger.Error("failed to load changed config file", "path", path, "error", err)
+			continue
+		}
+		if err := g.Reload(cfg); err != nil {
+			g.logger.Error("failed to reload changed config file", "path", path, "error", err)
+		}
+	}
+}
+
+// watchedModTimes returns the modification times of the config file and the
+// current TLS certificate and key files. Missing files report a zero time.
+func (g *Gateway) watchedModTimes(path string) [3]time.Time {
+	var modTimes [3]time.Time
+	files := []string{path}
+	if cfg := g.Config(); tlsEnabled(cfg) {
+		files = append(files, cfg.Gateway.TLS.CertFile, cfg.Gateway.TLS.KeyFile)
+	}
+	for i, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[i] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// certificateStore serves the current TLS certificate and allows it to be
+// replaced while the server is running
+type certificateStore struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+// loadCertificateStore creates a store holding the configured certificate
+func loadCertificateStore(cfg *config.TLS) (*certificateStore, error) {
+	cert, err := loadCertificate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store := &certificateStore{}
+	store.cert.Store(cert)
+	return store, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (s *certificateStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert.Load(), nil
+}
+
+// loadCertificate loads the configured certificate and key pair
+func loadCertificate(cfg *config.TLS) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &cert, nil
+}
+
+// tlsEnabled reports whether cfg enables TLS
+func tlsEnabled(cfg *config.Config) bool {
+	return cfg.Gateway.TLS != nil && cfg.Gateway.TLS.Enabled
+}
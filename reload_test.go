@@ -0,0 +1,347 @@
+package scimgateway
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func bearerConfig(token string) *config.Config {
+	return &config.Config{
+		Gateway: config.GatewayConfig{
+			BaseURL: "http://localhost:8080",
+			Port:    8080,
+		},
+		Plugins: []config.PluginConfig{
+			{
+				Name: "test",
+				Auth: &config.AuthConfig{
+					Type:   "bearer",
+					Bearer: &config.BearerAuth{Token: token},
+				},
+			},
+		},
+	}
+}
+
+func getUsersStatus(t *testing.T, handler http.Handler, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/test/Users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code
+}
+
+func newReloadGateway(t *testing.T, cfg *config.Config) (*Gateway, http.Handler) {
+	t.Helper()
+	gw := New(cfg)
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, err := gw.Handler()
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	return gw, handler
+}
+
+func TestReloadRotatesCredentials(t *testing.T) {
+	gw, handler := newReloadGateway(t, bearerConfig("old-token"))
+
+	if code := getUsersStatus(t, handler, "old-token"); code != http.StatusOK {
+		t.Fatalf("old token before reload: status = %d, want %d", code, http.StatusOK)
+	}
+
+	if err := gw.Reload(bearerConfig("new-token")); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	// The handler returned before the reload picks up the new credentials
+	if code := getUsersStatus(t, handler, "old-token"); code != http.StatusUnauthorized {
+		t.Errorf("old token after reload: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := getUsersStatus(t, handler, "new-token"); code != http.StatusOK {
+		t.Errorf("new token after reload: status = %d, want %d", code, http.StatusOK)
+	}
+	if gw.Config().Plugins[0].Auth.Bearer.Token != "new-token" {
+		t.Error("Config() should return the reloaded configuration")
+	}
+}
+
+func TestReloadBaseURL(t *testing.T) {
+	gw, handler := newReloadGateway(t, bearerConfig("token"))
+
+	cfg := bearerConfig("token")
+	cfg.Gateway.BaseURL = "https://scim.example.com"
+	if err := gw.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/test/Users", strings.NewReader(`{"userName":"john"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	if location := w.Header().Get("Location"); !strings.HasPrefix(location, "https://scim.example.com/test/Users/") {
+		t.Errorf("Location = %q, want reloaded base URL", location)
+	}
+}
+
+func TestReloadRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(*config.Config)
+		errContains string
+	}{
+		{
+			name:        "invalid configuration",
+			modify:      func(c *config.Config) { c.Plugins[0].Auth.Bearer.Token = "" },
+			errContains: "token cannot be empty",
+		},
+		{
+			name:        "port change",
+			modify:      func(c *config.Config) { c.Gateway.Port = 9090 },
+			errContains: "gateway.port cannot be changed",
+		},
+		{
+			name: "enabling TLS",
+			modify: func(c *config.Config) {
+				c.Gateway.TLS = &config.TLS{Enabled: true, CertFile: "cert.pem", KeyFile: "key.pem"}
+			},
+			errContains: "gateway.tls.enabled cannot be changed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, handler := newReloadGateway(t, bearerConfig("token"))
+
+			cfg := bearerConfig("token")
+			tt.modify(cfg)
+
+			err := gw.Reload(cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Fatalf("Reload() error = %v, want to contain %q", err, tt.errContains)
+			}
+
+			// The current configuration stays active
+			if code := getUsersStatus(t, handler, "token"); code != http.StatusOK {
+				t.Errorf("status after rejected reload = %d, want %d", code, http.StatusOK)
+			}
+		})
+	}
+}
+
+// blockingPlugin holds GetUsers calls until released
+type blockingPlugin struct {
+	*testutil.MemoryPlugin
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	close(p.started)
+	<-p.release
+	return p.MemoryPlugin.GetUsers(ctx, params)
+}
+
+func TestReloadKeepsInFlightRequests(t *testing.T) {
+	gw := New(bearerConfig("old-token"))
+	p := &blockingPlugin{
+		MemoryPlugin: testutil.NewMemoryPlugin("test"),
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	gw.RegisterPlugin(p)
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	done := make(chan int)
+	go func() {
+		done <- getUsersStatus(t, handler, "old-token")
+	}()
+
+	<-p.started
+	if err := gw.Reload(bearerConfig("new-token")); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	close(p.release)
+
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and key for commonName
+func writeTestCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile = filepath.Join(dir, commonName+".crt")
+	keyFile = filepath.Join(dir, commonName+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestReloadRotatesTLSCertificate(t *testing.T) {
+	dir := t.TempDir()
+	oldCert, oldKey := writeTestCertificate(t, dir, "old")
+	newCert, newKey := writeTestCertificate(t, dir, "new")
+
+	cfg := bearerConfig("token")
+	cfg.Gateway.TLS = &config.TLS{Enabled: true, CertFile: oldCert, KeyFile: oldKey}
+	gw, _ := newReloadGateway(t, cfg)
+
+	// Start installs the certificate store before serving
+	certs, err := loadCertificateStore(cfg.Gateway.TLS)
+	if err != nil {
+		t.Fatalf("loadCertificateStore() error = %v", err)
+	}
+	gw.certs = certs
+
+	commonName := func() string {
+		cert, _ := certs.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("failed to parse certificate: %v", err)
+		}
+		return leaf.Subject.CommonName
+	}
+
+	if commonName() != "old" {
+		t.Fatalf("certificate = %q before reload, want old", commonName())
+	}
+
+	// A missing key pair is rejected and the current certificate kept
+	broken := bearerConfig("token")
+	broken.Gateway.TLS = &config.TLS{Enabled: true, CertFile: filepath.Join(dir, "missing.crt"), KeyFile: newKey}
+	if err := gw.Reload(broken); err == nil {
+		t.Error("Reload() with missing certificate should fail")
+	}
+	if commonName() != "old" {
+		t.Errorf("certificate = %q after failed reload, want old", commonName())
+	}
+
+	rotated := bearerConfig("token")
+	rotated.Gateway.TLS = &config.TLS{Enabled: true, CertFile: newCert, KeyFile: newKey}
+	if err := gw.Reload(rotated); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if commonName() != "new" {
+		t.Errorf("certificate = %q after reload, want new", commonName())
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	writeConfig := func(token string, modTime time.Time) {
+		content := `gateway:
+  baseURL: http://localhost:8080
+  port: 8080
+plugins:
+  - name: test
+    auth:
+      type: bearer
+      bearer:
+        token: ` + token + "\n"
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		// Set mod times explicitly so coarse filesystem timestamps don't hide changes
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Add(-time.Minute)
+	writeConfig("old-token", start)
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	gw, handler := newReloadGateway(t, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- gw.WatchConfigFile(ctx, path, 10*time.Millisecond)
+	}()
+
+	// Keep bumping the mod time, since the watcher may take its baseline after
+	// the first write
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 1; getUsersStatus(t, handler, "new-token") != http.StatusOK; i++ {
+		if time.Now().After(deadline) {
+			t.Fatal("config file change was not reloaded")
+		}
+		writeConfig("new-token", start.Add(time.Duration(i)*time.Second))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// An invalid file is logged and ignored
+	if err := os.WriteFile(path, []byte("gateway: [broken"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, time.Now(), time.Now()) // nolint:errcheck
+	time.Sleep(50 * time.Millisecond)
+	if code := getUsersStatus(t, handler, "new-token"); code != http.StatusOK {
+		t.Errorf("status after invalid file = %d, want %d", code, http.StatusOK)
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("WatchConfigFile() error = %v", err)
+	}
+
+	if err := gw.WatchConfigFile(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"), time.Second); err == nil {
+		t.Error("WatchConfigFile() on missing file should fail")
+	}
+}
@@ -0,0 +1,253 @@
+package scimgateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// RootEndpointsMiddleware serves /Users, /Groups and /ServiceProviderConfig
+// without a plugin path prefix, as configured by cfg (see
+// config.RootEndpointsConfig). Requests are rewritten to /{plugin}/... and
+// passed to next, so each is authenticated by the plugin serving it. plugins
+// lists the plugins aggregated, in order. Other requests are passed on
+// unchanged.
+func RootEndpointsMiddleware(cfg *config.RootEndpointsConfig, plugins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if !slices.Contains(config.RootEndpoints, endpoint) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.Aggregate && r.Method == http.MethodGet {
+				switch {
+				case endpoint == "ServiceProviderConfig":
+					aggregateServiceProviderConfig(next, w, r, plugins)
+					return
+				case rest == "":
+					aggregateList(next, w, r, plugins)
+					return
+				case !strings.Contains(rest, "/"):
+					findResource(next, w, r, plugins)
+					return
+				}
+			}
+
+			if cfg.Plugin == "" {
+				scim.NewHandler("").WriteSCIMError(w, scim.ErrNotImplemented(
+					fmt.Sprintf("%s %s without gateway.rootEndpoints.plugin", r.Method, r.URL.Path)))
+				return
+			}
+			next.ServeHTTP(w, pluginRequest(r, cfg.Plugin, r.URL.RawQuery))
+		})
+	}
+}
+
+// pluginRequest returns r sent to /{plugin}/... with query
+func pluginRequest(r *http.Request, plugin, query string) *http.Request {
+	r = r.Clone(r.Context())
+	r.URL.Path = "/" + plugin + r.URL.Path
+	if r.URL.RawPath != "" {
+		r.URL.RawPath = "/" + url.PathEscape(plugin) + r.URL.RawPath
+	}
+	r.URL.RawQuery = query
+	return r
+}
+
+// fanOut serves r by each plugin with query and returns the responses of the
+// plugins the client is authenticated for. If there are none, the response
+// of the first plugin, an authentication error, is returned as the only one.
+func fanOut(next http.Handler, r *http.Request, plugins []string, query string) []*responseBuffer {
+	var responses []*responseBuffer
+	var denied *responseBuffer
+	for _, plugin := range plugins {
+		resp := &responseBuffer{header: make(http.Header)}
+		next.ServeHTTP(resp, pluginRequest(r, plugin, query))
+		if resp.status == http.StatusUnauthorized || resp.status == http.StatusForbidden {
+			if denied == nil {
+				denied = resp
+			}
+			continue
+		}
+		responses = append(responses, resp)
+	}
+	if len(responses) == 0 && denied != nil {
+		return []*responseBuffer{denied}
+	}
+	return responses
+}
+
+// aggregateList lists the resources of every plugin. Each plugin returns
+// its matches up to the end of the requested page, so the merged and sorted
+// matches hold the page.
+func aggregateList(next http.Handler, w http.ResponseWriter, r *http.Request, plugins []string) {
+	handler := scim.NewHandler("")
+	params, err := handler.ParseQueryParams(r)
+	if err != nil {
+		handler.WriteSCIMError(w, scim.ErrInvalidValue(err.Error()))
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("startIndex", "1")
+	query.Set("count", strconv.Itoa(params.StartIndex+params.Count-1))
+	// Keep the sort attribute in the responses to merge them in order
+	widened := params.SortBy != "" && len(params.Attributes) > 0 && !slices.Contains(params.Attributes, params.SortBy)
+	if widened {
+		query.Set("attributes", strings.Join(append(slices.Clone(params.Attributes), params.SortBy), ","))
+	}
+
+	merged := &scim.ListResponse[map[string]any]{Schemas: []string{scim.SchemaListResponse}}
+	for _, resp := range fanOut(next, r, plugins, query.Encode()) {
+		if resp.status != http.StatusOK {
+			resp.copyTo(w)
+			return
+		}
+		var list scim.ListResponse[map[string]any]
+		if err := json.Unmarshal(resp.body.Bytes(), &list); err != nil {
+			handler.WriteSCIMError(w, scim.ErrInternalServer(fmt.Sprintf("invalid list response: %v", err)))
+			return
+		}
+		merged.TotalResults += list.TotalResults
+		merged.Resources = append(merged.Resources, list.Resources...)
+	}
+
+	resources := scim.SortResources(merged.Resources, params.SortBy, params.SortOrder)
+	resources, merged.StartIndex, merged.ItemsPerPage = scim.ApplyResourcePagination(resources, params.StartIndex, params.Count)
+	if widened {
+		if resources, err = scim.ApplyAttributeSelection(resources, params.Attributes, nil); err != nil {
+			handler.WriteSCIMError(w, scim.ErrInternalServer(err.Error()))
+			return
+		}
+	}
+	merged.Resources = resources
+	handler.WriteJSON(w, http.StatusOK, merged)
+}
+
+// findResource returns the resource from the first plugin having it
+func findResource(next http.Handler, w http.ResponseWriter, r *http.Request, plugins []string) {
+	responses := fanOut(next, r, plugins, r.URL.RawQuery)
+	for _, resp := range responses {
+		if resp.status != http.StatusNotFound {
+			resp.copyTo(w)
+			return
+		}
+	}
+	if len(responses) > 0 {
+		responses[0].copyTo(w)
+	}
+}
+
+// aggregateServiceProviderConfig returns the features all plugins support:
+// a feature is supported if every plugin supports it, and limits are the
+// lowest of any plugin
+func aggregateServiceProviderConfig(next http.Handler, w http.ResponseWriter, r *http.Request, plugins []string) {
+	var merged any
+	for _, resp := range fanOut(next, r, plugins, r.URL.RawQuery) {
+		if resp.status != http.StatusOK {
+			resp.copyTo(w)
+			return
+		}
+		var config any
+		if err := json.Unmarshal(resp.body.Bytes(), &config); err != nil {
+			scim.NewHandler("").WriteSCIMError(w, scim.ErrInternalServer(fmt.Sprintf("invalid service provider config: %v", err)))
+			return
+		}
+		if merged == nil {
+			merged = config
+		} else {
+			merged = mergeServiceProviderConfig(merged, config)
+		}
+	}
+	scim.NewHandler("").WriteJSON(w, http.StatusOK, merged)
+}
+
+// mergeServiceProviderConfig combines two decoded service provider configs:
+// booleans with and, numbers with min, arrays such as authenticationSchemes
+// with the elements of b not in a. Other values are taken from a.
+func mergeServiceProviderConfig(a, b any) any {
+	switch a := a.(type) {
+	case bool:
+		if b, ok := b.(bool); ok {
+			return a && b
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return min(a, b)
+		}
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			merged := make(map[string]any, len(a))
+			for key, value := range a {
+				if other, ok := b[key]; ok {
+					value = mergeServiceProviderConfig(value, other)
+				}
+				merged[key] = value
+			}
+			return merged
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			merged := slices.Clone(a)
+			for _, item := range b {
+				if !slices.ContainsFunc(merged, func(m any) bool { return reflect.DeepEqual(m, item) }) {
+					merged = append(merged, item)
+				}
+			}
+			return merged
+		}
+	}
+	return a
+}
+
+// responseBuffer holds the response of a plugin to a fanned out request
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// copyTo writes the buffered response to w. Plugins respond to fanned out
+// requests in JSON, so the body is encoded again if w has another encoder.
+func (b *responseBuffer) copyTo(w http.ResponseWriter) {
+	for name, values := range b.header {
+		w.Header()[name] = values
+	}
+	if _, ok := scim.ResponseEncoder(w).(scim.JSONEncoder); !ok && b.body.Len() > 0 {
+		var body any
+		if err := json.Unmarshal(b.body.Bytes(), &body); err == nil {
+			scim.NewHandler("").WriteJSON(w, b.status, body)
+			return
+		}
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes()) // nolint:errcheck
+}
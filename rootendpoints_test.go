@@ -0,0 +1,161 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// rootEndpointsHandler serves plugins "a" and "b" accepting "token" and "c"
+// accepting "other"
+func rootEndpointsHandler(t *testing.T, root *config.RootEndpointsConfig) http.Handler {
+	t.Helper()
+	cfg := bearerConfig("token")
+	cfg.Gateway.RootEndpoints = root
+	cfg.Plugins = nil
+	for _, p := range []struct{ name, token string }{{"a", "token"}, {"b", "token"}, {"c", "other"}} {
+		cfg.Plugins = append(cfg.Plugins, config.PluginConfig{
+			Name: p.name,
+			Auth: &config.AuthConfig{Type: "bearer", Bearer: &config.BearerAuth{Token: p.token}},
+		})
+	}
+
+	gw := New(cfg)
+	for _, p := range cfg.Plugins {
+		gw.RegisterPlugin(testutil.NewMemoryPlugin(p.Name))
+	}
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+	return handler
+}
+
+func serveRoot(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestRootEndpointsPlugin(t *testing.T) {
+	handler := rootEndpointsHandler(t, &config.RootEndpointsConfig{Plugin: "b"})
+
+	w := serveRoot(handler, "POST", "/Users", `{"userName": "alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /Users status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var user scim.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.Meta.Location, "http://localhost:8080/b/Users/") {
+		t.Errorf("location = %q, want under /b/Users", user.Meta.Location)
+	}
+
+	if w := serveRoot(handler, "GET", "/Users/"+user.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("GET /Users/{id} status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRoot(handler, "GET", "/a/Users/"+user.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /a/Users/{id} status = %d, want 404", w.Code)
+	}
+	if w := serveRoot(handler, "GET", "/ServiceProviderConfig", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /ServiceProviderConfig status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// The plugin's authentication applies
+	req := httptest.NewRequest("GET", "/Users", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /Users with another plugin's token status = %d, want 401", w.Code)
+	}
+}
+
+func TestRootEndpointsAggregate(t *testing.T) {
+	handler := rootEndpointsHandler(t, &config.RootEndpointsConfig{Aggregate: true})
+
+	ids := map[string]string{}
+	for _, u := range []struct{ plugin, userName string }{{"a", "carol"}, {"b", "alice"}, {"a", "bob"}} {
+		w := serveRoot(handler, "POST", "/"+u.plugin+"/Users", `{"userName": "`+u.userName+`"}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s status = %d, body: %s", u.userName, w.Code, w.Body.String())
+		}
+		var user scim.User
+		if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+			t.Fatal(err)
+		}
+		ids[u.userName] = user.ID
+	}
+
+	list := func(path string) scim.ListResponse[scim.User] {
+		t.Helper()
+		w := serveRoot(handler, "GET", path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, body: %s", path, w.Code, w.Body.String())
+		}
+		var resp scim.ListResponse[scim.User]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	userNames := func(resp scim.ListResponse[scim.User]) string {
+		var names []string
+		for _, u := range resp.Resources {
+			names = append(names, u.UserName)
+		}
+		return strings.Join(names, ",")
+	}
+
+	// Plugin "c" does not accept the token and is left out
+	if resp := list("/Users?sortBy=userName"); resp.TotalResults != 3 || userNames(resp) != "alice,bob,carol" {
+		t.Errorf("GET /Users = %d %s, want 3 alice,bob,carol", resp.TotalResults, userNames(resp))
+	}
+	resp := list("/Users?sortBy=userName&sortOrder=descending&startIndex=2&count=1&attributes=id")
+	if resp.TotalResults != 3 || resp.StartIndex != 2 || resp.ItemsPerPage != 1 || len(resp.Resources) != 1 {
+		t.Fatalf("GET /Users page = %+v", resp)
+	}
+	if got := resp.Resources[0]; got.ID != ids["bob"] || got.UserName != "" {
+		t.Errorf("page resource = %+v, want bob's id only", got)
+	}
+
+	if w := serveRoot(handler, "GET", "/Users/"+ids["alice"], ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"alice"`) {
+		t.Errorf("GET /Users/{id} status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := serveRoot(handler, "GET", "/Users/missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /Users/missing status = %d, want 404", w.Code)
+	}
+	if w := serveRoot(handler, "GET", "/ServiceProviderConfig", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"patch"`) {
+		t.Errorf("GET /ServiceProviderConfig status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Writes need a plugin
+	if w := serveRoot(handler, "POST", "/Users", `{"userName": "dave"}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("POST /Users status = %d, want 501", w.Code)
+	}
+	// Plugin routes are unaffected
+	if resp := list("/b/Users"); resp.TotalResults != 1 {
+		t.Errorf("GET /b/Users totalResults = %d, want 1", resp.TotalResults)
+	}
+}
+
+func TestMergeServiceProviderConfig(t *testing.T) {
+	var a, b any
+	json.Unmarshal([]byte(`{"patch": {"supported": true}, "bulk": {"supported": true, "maxOperations": 100}, "authenticationSchemes": [{"type": "oauthbearertoken"}]}`), &a)                        // nolint:errcheck
+	json.Unmarshal([]byte(`{"patch": {"supported": false}, "bulk": {"supported": true, "maxOperations": 10}, "authenticationSchemes": [{"type": "oauthbearertoken"}, {"type": "httpbasic"}]}`), &b) // nolint:errcheck
+
+	got, _ := json.Marshal(mergeServiceProviderConfig(a, b))
+	want := `{"authenticationSchemes":[{"type":"oauthbearertoken"},{"type":"httpbasic"}],"bulk":{"maxOperations":10,"supported":true},"patch":{"supported":false}}`
+	if string(got) != want {
+		t.Errorf("merged = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,425 @@
+// Package scenario runs request sequences described in YAML against a
+// gateway handler, so interoperability scenarios can be written without Go:
+//
+//	name: create and fetch a user
+//	variables:
+//	  domain: example.com
+//	steps:
+//	  - name: create
+//	    request:
+//	      method: POST
+//	      path: /test/Users
+//	      headers:
+//	        Authorization: Bearer ${TOKEN}
+//	      body:
+//	        userName: alice@${domain}
+//	    expect:
+//	      status: 201
+//	      json:
+//	        userName: alice@${domain}
+//	      present: [id, meta.created]
+//	    capture:
+//	      userId: id
+//	  - name: fetch
+//	    request:
+//	      method: GET
+//	      path: /test/Users/${userId}
+//	    expect:
+//	      status: 200
+//
+// References of the form ${name} in paths, headers, bodies and expected values
+// are replaced with a variable, a value captured by an earlier step or, failing
+// both, the environment variable name. A string that is only a reference keeps
+// the type of a captured value, so numbers and objects can be passed on.
+//
+// JSON paths select members by name and array elements by index, as in
+// Resources[0].emails[1].value. Member names may contain dots, such as the
+// URNs of schema extensions: the longest member name matching the path wins.
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a sequence of requests with expectations on their responses
+type Scenario struct {
+	Name string `yaml:"name"`
+
+	// Variables are the values available to ${name} references
+	Variables map[string]string `yaml:"variables"`
+
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is one request of a scenario
+type Step struct {
+	Name    string  `yaml:"name"`
+	Request Request `yaml:"request"`
+	Expect  Expect  `yaml:"expect"`
+
+	// Capture names values of the response body by JSON path, to be
+	// referenced by later steps
+	Capture map[string]string `yaml:"capture"`
+}
+
+// Request describes the HTTP request of a step
+type Request struct {
+	Method  string            `yaml:"method"`
+	Path    string            `yaml:"path"`
+	Headers map[string]string `yaml:"headers"`
+
+	// Body is sent as JSON; a string is sent as it is. The Content-Type
+	// defaults to application/scim+json when there is a body.
+	Body any `yaml:"body"`
+}
+
+// Expect describes the expected response of a step
+type Expect struct {
+	// Status is the expected status code; any status is accepted if zero
+	Status int `yaml:"status"`
+
+	// Headers are expected header values
+	Headers map[string]string `yaml:"headers"`
+
+	// JSON maps JSON paths to their expected values
+	JSON map[string]any `yaml:"json"`
+
+	// Present and Absent list JSON paths that must and must not exist
+	Present []string `yaml:"present"`
+	Absent  []string `yaml:"absent"`
+}
+
+// Result is the outcome of running a scenario
+type Result struct {
+	Scenario string
+	Steps    []StepResult
+}
+
+// StepResult is the outcome of a step
+type StepResult struct {
+	Name string
+
+	// Status is the status code of the response
+	Status int
+
+	// Failures describe the expectations the response did not meet
+	Failures []string
+}
+
+// Failed reports whether any step failed
+func (r *Result) Failed() bool {
+	for _, step := range r.Steps {
+		if len(step.Failures) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the failures of all steps as one error, or nil if the scenario
+// passed
+func (r *Result) Err() error {
+	var errs []error
+	for _, step := range r.Steps {
+		for _, failure := range step.Failures {
+			errs = append(errs, fmt.Errorf("%s: step %q: %s", r.Scenario, step.Name, failure))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load reads a scenario from a YAML file
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse parses a scenario from YAML. Unknown keys are errors, so that typos
+// do not silently skip expectations.
+func Parse(data []byte) (*Scenario, error) {
+	var s Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, err
+	}
+
+	if len(s.Steps) == 0 {
+		return nil, errors.New("scenario has no steps")
+	}
+	for i := range s.Steps {
+		step := &s.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if step.Request.Method == "" || step.Request.Path == "" {
+			return nil, fmt.Errorf("%s: request method and path are required", step.Name)
+		}
+	}
+	return &s, nil
+}
+
+// Run runs the steps of the scenario in order against handler. It stops at
+// the first step that fails, since later steps usually depend on it.
+func (s *Scenario) Run(handler http.Handler) *Result {
+	result := &Result{Scenario: s.Name}
+	vars := make(map[string]any, len(s.Variables))
+	for name, value := range s.Variables {
+		vars[name] = value
+	}
+
+	for _, step := range s.Steps {
+		stepResult := runStep(handler, step, vars)
+		result.Steps = append(result.Steps, stepResult)
+		if len(stepResult.Failures) > 0 {
+			break
+		}
+	}
+	return result
+}
+
+// runStep sends the request of a step and checks its response, adding the
+// values it captures to vars
+func runStep(handler http.Handler, step Step, vars map[string]any) StepResult {
+	result := StepResult{Name: step.Name}
+	t := &templater{vars: vars}
+
+	var body []byte
+	switch b := t.expand(step.Request.Body).(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			result.Failures = append(result.Failures, fmt.Sprintf("encode body: %v", err))
+		}
+	}
+
+	// Spaces are common in filters, so they need not be escaped
+	target := strings.ReplaceAll(t.string(step.Request.Path), " ", "%20")
+	req := httptest.NewRequest(strings.ToUpper(step.Request.Method), target, bytes.NewReader(body))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/scim+json")
+	}
+	for name, value := range step.Request.Headers {
+		req.Header.Set(name, t.string(value))
+	}
+	if len(t.missing) > 0 || len(result.Failures) > 0 {
+		result.Failures = append(result.Failures, t.failures()...)
+		return result
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	result.Status = w.Code
+	fail := func(format string, args ...any) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	if step.Expect.Status != 0 && w.Code != step.Expect.Status {
+		fail("status = %d, want %d; body: %s", w.Code, step.Expect.Status, w.Body.String())
+	}
+	for name, want := range step.Expect.Headers {
+		if got, want := w.Header().Get(name), t.string(want); got != want {
+			fail("header %s = %q, want %q", name, got, want)
+		}
+	}
+
+	var doc any
+	needsBody := len(step.Expect.JSON) > 0 || len(step.Expect.Present) > 0 || len(step.Expect.Absent) > 0 || len(step.Capture) > 0
+	if needsBody {
+		if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+			fail("response is not JSON: %v; body: %s", err, w.Body.String())
+			return result
+		}
+	}
+
+	for path, want := range step.Expect.JSON {
+		got, ok := lookup(doc, path)
+		want = normalize(t.expand(want))
+		switch {
+		case !ok:
+			fail("%s is missing, want %s", path, encode(want))
+		case !reflect.DeepEqual(got, want):
+			fail("%s = %s, want %s", path, encode(got), encode(want))
+		}
+	}
+	for _, path := range step.Expect.Present {
+		if _, ok := lookup(doc, path); !ok {
+			fail("%s is missing", path)
+		}
+	}
+	for _, path := range step.Expect.Absent {
+		if got, ok := lookup(doc, path); ok {
+			fail("%s = %s, want it absent", path, encode(got))
+		}
+	}
+	for name, path := range step.Capture {
+		got, ok := lookup(doc, path)
+		if !ok {
+			fail("cannot capture %s: %s is missing", name, path)
+			continue
+		}
+		vars[name] = got
+	}
+
+	result.Failures = append(result.Failures, t.failures()...)
+	return result
+}
+
+// refPattern matches ${name} references
+var refPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.-]*)\}`)
+
+// templater replaces references with the values of variables, recording
+// references it cannot resolve
+type templater struct {
+	vars    map[string]any
+	missing []string
+}
+
+// resolve returns the value of a reference
+func (t *templater) resolve(name string) (any, bool) {
+	if value, ok := t.vars[name]; ok {
+		return value, true
+	}
+	if value, ok := os.LookupEnv(name); ok {
+		return value, true
+	}
+	if !slices.Contains(t.missing, name) {
+		t.missing = append(t.missing, name)
+	}
+	return nil, false
+}
+
+// string replaces the references in s with their values formatted as text
+func (t *templater) string(s string) string {
+	return refPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		value, ok := t.resolve(refPattern.FindStringSubmatch(ref)[1])
+		if !ok {
+			return ref
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return encode(value)
+	})
+}
+
+// expand replaces the references in the strings of a YAML value. A string
+// that is a single reference is replaced with the value as it is.
+func (t *templater) expand(value any) any {
+	switch v := value.(type) {
+	case string:
+		if m := refPattern.FindStringSubmatch(v); m != nil && m[0] == v {
+			if resolved, ok := t.resolve(m[1]); ok {
+				return resolved
+			}
+			return v
+		}
+		return t.string(v)
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded[key] = t.expand(item)
+		}
+		return expanded
+	case []any:
+		expanded := make([]any, len(v))
+		for i, item := range v {
+			expanded[i] = t.expand(item)
+		}
+		return expanded
+	}
+	return value
+}
+
+// failures describes the references that could not be resolved
+func (t *templater) failures() []string {
+	failures := make([]string, len(t.missing))
+	for i, name := range t.missing {
+		failures[i] = fmt.Sprintf("${%s} is not a variable, captured value or environment variable", name)
+	}
+	return failures
+}
+
+// lookup returns the value at a JSON path of doc
+func lookup(doc any, path string) (any, bool) {
+	current := doc
+	for path != "" {
+		if strings.HasPrefix(path, "[") {
+			end := strings.Index(path, "]")
+			if end == -1 {
+				return nil, false
+			}
+			index, err := strconv.Atoi(path[1:end])
+			items, ok := current.([]any)
+			if err != nil || !ok || index < 0 || index >= len(items) {
+				return nil, false
+			}
+			current = items[index]
+			path = strings.TrimPrefix(path[end+1:], ".")
+			continue
+		}
+
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		key := ""
+		for name := range object {
+			if len(name) > len(key) && (path == name || strings.HasPrefix(path, name+".") || strings.HasPrefix(path, name+"[")) {
+				key = name
+			}
+		}
+		if key == "" {
+			return nil, false
+		}
+		current = object[key]
+		path = strings.TrimPrefix(path[len(key):], ".")
+	}
+	return current, true
+}
+
+// normalize converts a YAML value to the types encoding/json decodes into,
+// so it can be compared with a response value
+func normalize(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// encode formats a value as JSON for messages and templates
+func encode(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
@@ -0,0 +1,194 @@
+package scenario
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// echoHandler answers POST requests with the body and an id, and GET
+// requests with the path, query and Authorization header
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/scim+json")
+	if r.Method == http.MethodPost {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body["id"] = "42"
+		body["count"] = 3
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(body) // nolint:errcheck
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{ // nolint:errcheck
+		"path":   r.URL.Path,
+		"filter": r.URL.Query().Get("filter"),
+		"auth":   r.Header.Get("Authorization"),
+	})
+})
+
+func TestRun(t *testing.T) {
+	t.Setenv("SCENARIO_TOKEN", "secret")
+	s, err := Parse([]byte(`
+name: echo
+variables:
+  domain: example.com
+steps:
+  - name: create
+    request:
+      method: post
+      path: /test/Users
+      body:
+        userName: alice@${domain}
+        emails: [{value: "alice@${domain}", primary: true}]
+        urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:
+          employeeNumber: "7"
+    expect:
+      status: 201
+      headers:
+        Content-Type: application/scim+json
+      json:
+        userName: alice@example.com
+        emails[0].primary: true
+        urn:ietf:params:scim:schemas:extension:enterprise:2.0:User.employeeNumber: "7"
+        count: 3
+      present: [id]
+      absent: [password, "emails[1]"]
+    capture:
+      userId: id
+      count: count
+  - request:
+      method: GET
+      path: /test/Users/${userId}?filter=userName eq "alice"
+      headers:
+        Authorization: Bearer ${SCENARIO_TOKEN}
+    expect:
+      status: 200
+      json:
+        path: /test/Users/42
+        filter: userName eq "alice"
+        auth: Bearer secret
+  - request:
+      method: POST
+      path: /test/Users
+      body: {total: "${count}"}
+    expect:
+      json:
+        total: ${count}
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	result := s.Run(echoHandler)
+	if err := result.Err(); err != nil {
+		t.Fatalf("Run() failed: %v", err)
+	}
+	if len(result.Steps) != 3 || result.Steps[1].Name != "step 2" || result.Steps[1].Status != http.StatusOK {
+		t.Errorf("steps = %+v", result.Steps)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	s, err := Parse([]byte(`
+name: failing
+steps:
+  - name: create
+    request: {method: POST, path: /test/Users, body: {userName: bob}}
+    expect:
+      status: 200
+      json: {userName: alice, displayName: Bob}
+      present: [meta]
+      absent: [id]
+    capture: {groupId: group.id}
+  - name: never runs
+    request: {method: GET, path: /test/Users}
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	result := s.Run(echoHandler)
+	if !result.Failed() || len(result.Steps) != 1 {
+		t.Fatalf("Run() = %+v, want only the first step, failed", result.Steps)
+	}
+	failures := strings.Join(result.Steps[0].Failures, "\n")
+	for _, want := range []string{
+		"status = 201, want 200",
+		`userName = "bob", want "alice"`,
+		"displayName is missing",
+		"meta is missing",
+		`id = "42", want it absent`,
+		"cannot capture groupId",
+	} {
+		if !strings.Contains(failures, want) {
+			t.Errorf("failures do not contain %q:\n%s", want, failures)
+		}
+	}
+	if err := result.Err(); err == nil || !strings.Contains(err.Error(), `failing: step "create"`) {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestRunUnresolvedReference(t *testing.T) {
+	s, err := Parse([]byte(`
+steps:
+  - request: {method: GET, path: "/test/Users/${userId}"}
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	result := s.Run(echoHandler)
+	if !result.Failed() || result.Steps[0].Status != 0 || !strings.Contains(result.Steps[0].Failures[0], "${userId}") {
+		t.Errorf("Run() = %+v, want a failure for ${userId} before sending the request", result.Steps)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, want string
+	}{
+		{"no steps", "name: empty", "no steps"},
+		{"unknown key", "steps:\n  - request: {method: GET, path: /}\n    expects: {status: 200}", "expects"},
+		{"no path", "steps:\n  - request: {method: GET}", "method and path are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.yaml)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc any
+	err := json.Unmarshal([]byte(`{
+		"Resources": [{"emails": [{"value": "a"}, {"value": "b"}]}],
+		"urn:example:2.0:User": {"manager": {"value": "m"}},
+		"urn:example:2.0": {"manager": "wrong"}
+	}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want any
+		ok   bool
+	}{
+		{"Resources[0].emails[1].value", "b", true},
+		{"urn:example:2.0:User.manager.value", "m", true},
+		{"Resources[1]", nil, false},
+		{"Resources[0].emails.value", nil, false},
+		{"missing", nil, false},
+	}
+	for _, tt := range tests {
+		if got, ok := lookup(doc, tt.path); got != tt.want || ok != tt.ok {
+			t.Errorf("lookup(%q) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
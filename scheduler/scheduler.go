@@ -0,0 +1,314 @@
+// Package scheduler runs the gateway's periodic maintenance jobs, such as
+// purging soft-deleted rows, refreshing plugin caches and health checks of
+// failover pairs, on one scheduler instead of a goroutine per subsystem.
+//
+// Each job runs every Interval, randomly shortened or lengthened by up to
+// its Jitter so the replicas of a gateway do not hit a shared backend at
+// the same moment. A job never overlaps itself: a run that takes longer
+// than the interval delays the next one.
+//
+//	s := scheduler.New()
+//	s.Add(scheduler.Job{Name: "purge", Interval: time.Hour, Run: purge})
+//	go s.Run(ctx)
+package scheduler
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+)
+
+// DefaultJitter is the jitter of jobs leaving it zero: their intervals
+// vary by up to 10%
+const DefaultJitter = 0.1
+
+// Job is a task run periodically by a Scheduler
+type Job struct {
+	// Name identifies the job, e.g. "hr/purge-deleted"
+	Name string
+
+	// Interval is the time between the end of a run and the start of the
+	// next. The first run starts one interval after the job is scheduled.
+	Interval time.Duration
+
+	// Jitter is the largest fraction of Interval randomly added to or
+	// removed from each wait, between 0 and 1. Zero uses DefaultJitter; a
+	// negative value runs the job at exact intervals.
+	Jitter float64
+
+	// Disabled jobs are kept, and listed, but not run until enabled with
+	// SetEnabled
+	Disabled bool
+
+	// Run performs the task. Its context is cancelled when the scheduler
+	// stops or the job is removed, and carries the scheduler's clock for
+	// clock.Now.
+	Run func(ctx context.Context) error
+}
+
+// Validate validates the job
+func (j Job) Validate() error {
+	switch {
+	case j.Name == "":
+		return errors.New("job name cannot be empty")
+	case j.Interval <= 0:
+		return fmt.Errorf("job %s: interval must be positive", j.Name)
+	case j.Jitter > 1:
+		return fmt.Errorf("job %s: jitter %g must be at most 1", j.Name, j.Jitter)
+	case j.Run == nil:
+		return fmt.Errorf("job %s: run function cannot be nil", j.Name)
+	}
+	return nil
+}
+
+// wait returns the jittered time until the next run
+func (j Job) wait() time.Duration {
+	jitter := j.Jitter
+	if jitter == 0 {
+		jitter = DefaultJitter
+	}
+	if jitter < 0 {
+		return j.Interval
+	}
+	return time.Duration(float64(j.Interval) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// JobStatus describes a scheduled job and its last run
+type JobStatus struct {
+	Name     string
+	Interval time.Duration
+	Enabled  bool
+
+	// Runs is the number of completed runs, and Failures the number of
+	// them returning an error
+	Runs     int
+	Failures int
+
+	// LastRun is when the last run started, zero if the job has not run.
+	// LastErr is the error it returned.
+	LastRun time.Time
+	LastErr error
+}
+
+// Scheduler runs jobs periodically while Run is active. Its methods are
+// safe for concurrent use; jobs can be added and removed while it runs.
+type Scheduler struct {
+	clock clock.Clock
+
+	mu      sync.Mutex // protects the fields below
+	jobs    map[string]*entry
+	running context.Context // context of Run while it is active
+	wg      sync.WaitGroup  // tracks the loops of jobs
+}
+
+// entry is a scheduled job with its state
+type entry struct {
+	job     Job
+	enabled bool
+	stop    context.CancelFunc // stops the loop of the job, nil if it has none
+
+	runMu  sync.Mutex // serializes runs of the job
+	status JobStatus  // protected by Scheduler.mu
+}
+
+// New creates a Scheduler without jobs
+func New() *Scheduler {
+	return &Scheduler{
+		clock: clock.System,
+		jobs:  make(map[string]*entry),
+	}
+}
+
+// SetClock sets the clock timestamping runs and passed to jobs. Nil uses
+// clock.System. Waits between runs always use real time.
+func (s *Scheduler) SetClock(c clock.Clock) {
+	if c == nil {
+		c = clock.System
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
+// Add schedules job, replacing a job of the same name. A replaced job keeps
+// its status, and its wait for the next run unless the interval, jitter or
+// Disabled changed.
+func (s *Scheduler) Add(job Job) error {
+	if err := job.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.jobs[job.Name]; ok {
+		previous := e.job
+		e.job = job
+		e.status.Interval = job.Interval
+		if previous.Interval == job.Interval && previous.Jitter == job.Jitter && previous.Disabled == job.Disabled {
+			return nil
+		}
+		e.enabled = !job.Disabled
+		e.status.Enabled = e.enabled
+		s.restart(e)
+		return nil
+	}
+
+	e := &entry{
+		job:     job,
+		enabled: !job.Disabled,
+		status:  JobStatus{Name: job.Name, Interval: job.Interval, Enabled: !job.Disabled},
+	}
+	s.jobs[job.Name] = e
+	s.restart(e)
+	return nil
+}
+
+// Remove unschedules the job called name, cancelling a run in progress.
+// It reports whether the job existed.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+	if e.stop != nil {
+		e.stop()
+	}
+	delete(s.jobs, name)
+	return true
+}
+
+// SetEnabled enables or disables the job called name
+func (s *Scheduler) SetEnabled(name string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.jobs[name]
+	if !ok {
+		return fmt.Errorf("job %s not found", name)
+	}
+	if e.enabled == enabled {
+		return nil
+	}
+	e.enabled = enabled
+	e.status.Enabled = enabled
+	s.restart(e)
+	return nil
+}
+
+// Trigger runs the job called name now, even if it is disabled, and
+// returns its error. It waits for a run of the job in progress first.
+func (s *Scheduler) Trigger(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("job %s not found", name)
+	}
+	return s.run(ctx, e)
+}
+
+// Jobs returns the status of all jobs, sorted by name
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		statuses = append(statuses, e.status)
+	}
+	slices.SortFunc(statuses, func(a, b JobStatus) int { return cmp.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+// Run runs the enabled jobs until ctx is done, then waits for runs in
+// progress to return. It blocks, so it is typically started in a
+// goroutine. Run returns an error if the scheduler is already running.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running != nil {
+		s.mu.Unlock()
+		return errors.New("scheduler is already running")
+	}
+	s.running = ctx
+	for _, e := range s.jobs {
+		s.restart(e)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	s.running = nil
+	for _, e := range s.jobs {
+		if e.stop != nil {
+			e.stop()
+			e.stop = nil
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// restart stops the loop of e and starts a new one if e is enabled and the
+// scheduler runs. Callers must hold s.mu.
+func (s *Scheduler) restart(e *entry) {
+	if e.stop != nil {
+		e.stop()
+		e.stop = nil
+	}
+	if s.running == nil || !e.enabled {
+		return
+	}
+
+	ctx, stop := context.WithCancel(s.running)
+	e.stop = stop
+	wait := e.job.wait
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			timer := time.NewTimer(wait())
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			s.run(ctx, e) // nolint:errcheck // recorded in the job's status
+		}
+	}()
+}
+
+// run runs e once and records the result in its status
+func (s *Scheduler) run(ctx context.Context, e *entry) error {
+	e.runMu.Lock()
+	defer e.runMu.Unlock()
+
+	s.mu.Lock()
+	job, clk := e.job, s.clock
+	s.mu.Unlock()
+
+	started := clk.Now()
+	err := job.Run(clock.WithContext(ctx, clk))
+
+	s.mu.Lock()
+	e.status.Runs++
+	if err != nil {
+		e.status.Failures++
+	}
+	e.status.LastRun, e.status.LastErr = started, err
+	s.mu.Unlock()
+	return err
+}
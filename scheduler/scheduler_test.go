@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+)
+
+// waitFor polls cond until it holds or a second passed
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	s := New()
+	var runs, disabledRuns atomic.Int32
+	failure := errors.New("backend down")
+	if err := s.Add(Job{Name: "purge", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		runs.Add(1)
+		return failure
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Job{Name: "refresh", Interval: time.Millisecond, Disabled: true, Run: func(context.Context) error {
+		disabledRuns.Add(1)
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	waitFor(t, func() bool { return runs.Load() >= 3 })
+	if err := s.Run(ctx); err == nil {
+		t.Error("second Run() error = nil, want already running")
+	}
+	if n := disabledRuns.Load(); n != 0 {
+		t.Errorf("disabled job ran %d times", n)
+	}
+
+	if err := s.SetEnabled("refresh", true); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return disabledRuns.Load() >= 1 })
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Error("job ran after Run returned")
+	}
+
+	statuses := s.Jobs()
+	if len(statuses) != 2 || statuses[0].Name != "purge" || statuses[1].Name != "refresh" {
+		t.Fatalf("Jobs() = %+v, want purge and refresh", statuses)
+	}
+	purge := statuses[0]
+	if purge.Runs != int(stopped) || purge.Failures != purge.Runs || !errors.Is(purge.LastErr, failure) || purge.LastRun.IsZero() {
+		t.Errorf("purge status = %+v, want %d failed runs", purge, stopped)
+	}
+	if !statuses[1].Enabled {
+		t.Error("refresh not enabled")
+	}
+}
+
+func TestSchedulerAddWhileRunning(t *testing.T) {
+	s := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx) // nolint:errcheck
+
+	var runs atomic.Int32
+	job := Job{Name: "probe", Interval: time.Millisecond, Jitter: -1, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+	waitFor(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.running != nil
+	})
+	if err := s.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return runs.Load() >= 2 })
+
+	if !s.Remove("probe") {
+		t.Fatal("Remove() = false, want true")
+	}
+	time.Sleep(5 * time.Millisecond)
+	removed := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != removed {
+		t.Error("removed job kept running")
+	}
+	if s.Remove("probe") {
+		t.Error("second Remove() = true, want false")
+	}
+}
+
+func TestSchedulerTrigger(t *testing.T) {
+	s := New()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.SetClock(clock.NewFake(now))
+	var ranAt time.Time
+	s.Add(Job{Name: "snapshot", Interval: time.Hour, Disabled: true, Run: func(ctx context.Context) error { // nolint:errcheck
+		ranAt = clock.Now(ctx)
+		return nil
+	}})
+
+	if err := s.Trigger(context.Background(), "snapshot"); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if !ranAt.Equal(now) {
+		t.Errorf("job clock = %v, want the scheduler's %v", ranAt, now)
+	}
+	if status := s.Jobs()[0]; status.Runs != 1 || !status.LastRun.Equal(now) || status.Enabled {
+		t.Errorf("status = %+v, want one run at %v while disabled", status, now)
+	}
+	if err := s.Trigger(context.Background(), "unknown"); err == nil {
+		t.Error("Trigger() of an unknown job error = nil")
+	}
+}
+
+func TestJobValidate(t *testing.T) {
+	run := func(context.Context) error { return nil }
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{"valid", Job{Name: "a", Interval: time.Second, Run: run}, false},
+		{"no name", Job{Interval: time.Second, Run: run}, true},
+		{"no interval", Job{Name: "a", Run: run}, true},
+		{"jitter above 1", Job{Name: "a", Interval: time.Second, Jitter: 1.5, Run: run}, true},
+		{"no run", Job{Name: "a", Interval: time.Second}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.job.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestJobWait(t *testing.T) {
+	job := Job{Interval: time.Second}
+	for range 100 {
+		if d := job.wait(); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("wait() = %v, want within 10%% of a second", d)
+		}
+	}
+	job.Jitter = -1
+	if d := job.wait(); d != time.Second {
+		t.Errorf("wait() without jitter = %v, want 1s", d)
+	}
+}
@@ -0,0 +1,63 @@
+package scim
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// AccentInsensitiveProvider is an optional interface for plugins whose filters
+// ignore accents. The server sets QueryParams.AccentInsensitive of list and
+// search requests from it. The plugin adapter provides it from the plugin's
+// accentInsensitive setting.
+type AccentInsensitiveProvider interface {
+	AccentInsensitive() bool
+}
+
+// FoldAccents returns s decomposed to NFKD with its combining marks removed,
+// then folded with FoldCase, so "José" matches "jose", "Ångström" matches
+// "angstrom" and "ﬁle" matches "file". Letters that do not decompose, such as
+// "ø" or "ł", are only case-folded.
+func FoldAccents(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range norm.NFKD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return FoldCase(b.String())
+}
+
+// applyAccentInsensitive sets params.AccentInsensitive if the plugin ignores accents
+func applyAccentInsensitive(params *QueryParams, plugin PluginGetter) {
+	if provider, ok := lookupCapability[AccentInsensitiveProvider](plugin); ok && provider.AccentInsensitive() {
+		params.AccentInsensitive = true
+	}
+}
+
+// parseQueryFilter parses params.Filter, comparing strings with FoldAccents
+// instead of FoldCase if params.AccentInsensitive is set. It returns nil for
+// an empty filter.
+func parseQueryFilter(params QueryParams) (Filter, error) {
+	expr, err := NewFilterParser(params.Filter).Parse()
+	if err != nil || expr == nil || !params.AccentInsensitive {
+		return expr, err
+	}
+	setFilterFold(expr, FoldAccents)
+	return expr, nil
+}
+
+// setFilterFold sets the string normalization of every comparison in f
+func setFilterFold(f Filter, fold func(string) string) {
+	switch f := f.(type) {
+	case *AttributeExpression:
+		f.fold = fold
+	case *LogicalExpression:
+		setFilterFold(f.Left, fold)
+		setFilterFold(f.Right, fold)
+	case *GroupExpression:
+		setFilterFold(f.Filter, fold)
+	}
+}
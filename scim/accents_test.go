@@ -0,0 +1,112 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFoldAccents(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"José", "jose"},
+		{"Ångström", "angstrom"},
+		{"MÜLLER", "muller"},
+		{"ﬁle", "file"},
+		{"İstanbul", "istanbul"},
+		{"Ελένη", "ελενη"},
+		{"Øyvind", "øyvind"},
+	}
+
+	for _, tt := range tests {
+		if got := FoldAccents(tt.in); got != tt.want {
+			t.Errorf("FoldAccents(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestProcessListQuery_AccentInsensitive(t *testing.T) {
+	users := []*User{
+		{UserName: "josé@example.com", Name: &Name{FamilyName: "Müller"}},
+		{UserName: "jose@example.com", Name: &Name{FamilyName: "Muller"}},
+		{UserName: "joao@example.com", Name: &Name{FamilyName: "Mueller"}},
+	}
+
+	tests := []struct {
+		filter    string
+		exact     int
+		unaccents int
+	}{
+		{`userName eq "jose@example.com"`, 1, 2},
+		{`userName ne "JOSE@example.com"`, 2, 1},
+		{`userName sw "José"`, 1, 2},
+		{`name.familyName co "ull"`, 1, 2},
+		{`name.familyName ew "üller"`, 1, 2},
+		{`name.familyName sw "mu" and not (userName co "sé")`, 2, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			exact, err := ProcessListQuery(users, QueryParams{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exact.TotalResults != tt.exact {
+				t.Errorf("accent-sensitive totalResults = %d, want %d", exact.TotalResults, tt.exact)
+			}
+
+			folded, err := ProcessListQuery(users, QueryParams{Filter: tt.filter, AccentInsensitive: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if folded.TotalResults != tt.unaccents {
+				t.Errorf("accent-insensitive totalResults = %d, want %d", folded.TotalResults, tt.unaccents)
+			}
+		})
+	}
+}
+
+// accentInsensitivePlugin ignores accents in filters, evaluated in memory
+type accentInsensitivePlugin struct {
+	*mockPlugin
+}
+
+func (p *accentInsensitivePlugin) AccentInsensitive() bool {
+	return true
+}
+
+func (p *accentInsensitivePlugin) GetUsers(ctx context.Context, params QueryParams) (*ListResponse[*User], error) {
+	users := make([]*User, 0, len(p.users))
+	for _, user := range p.users {
+		users = append(users, user)
+	}
+	return ProcessListQuery(users, params)
+}
+
+func TestServer_AccentInsensitive(t *testing.T) {
+	plugin := &accentInsensitivePlugin{mockPlugin: newMockPlugin()}
+	plugin.users["1"] = &User{ID: "1", UserName: "josé"}
+	plugin.users["2"] = &User{ID: "2", UserName: "jose"}
+	plugin.users["3"] = &User{ID: "3", UserName: "joão"}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/test/Users?filter="+url.QueryEscape(`userName eq "Jose"`), nil),
+		httptest.NewRequest(http.MethodPost, "/test/.search", strings.NewReader(`{"schemas": ["`+SchemaSearchRequest+`"], "filter": "userName eq \"Jose\""}`)),
+	} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		var resp ListResponse[User]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.TotalResults != 2 {
+			t.Errorf("%s %s: totalResults = %d, want 2", req.Method, req.URL.Path, resp.TotalResults)
+		}
+	}
+}
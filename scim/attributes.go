@@ -29,29 +29,23 @@ func NewAttributeSelector(attributes, excluded []string) *AttributeSelector {
 	}
 
 	for _, attr := range attributes {
-		lowerAttr := strings.ToLower(attr)
-		as.attributes[lowerAttr] = true
-
 		// Parse sub-attributes (e.g., "emails.type" -> parent: "emails", sub: "type")
 		// Supports arbitrary nesting levels (e.g., "name.formatted", "addresses.street.postalCode")
-		if strings.Contains(lowerAttr, ".") {
-			parts := strings.SplitN(lowerAttr, ".", 2)
-			parent := parts[0]
-			sub := parts[1]
+		parent, sub := splitSelectorPath(attr)
+		if sub == "" {
+			as.attributes[parent] = true
+		} else {
 			as.subAttributes[parent] = append(as.subAttributes[parent], sub)
 		}
 	}
 
 	for _, attr := range excluded {
-		lowerAttr := strings.ToLower(attr)
-		as.excluded[lowerAttr] = true
-
 		// Parse excluded sub-attributes (e.g., "name.familyName" -> parent: "name", sub: "familyName")
 		// Supports arbitrary nesting levels
-		if strings.Contains(lowerAttr, ".") {
-			parts := strings.SplitN(lowerAttr, ".", 2)
-			parent := parts[0]
-			sub := parts[1]
+		parent, sub := splitSelectorPath(attr)
+		if sub == "" {
+			as.excluded[parent] = true
+		} else {
 			as.excludedSubAttributes[parent] = append(as.excludedSubAttributes[parent], sub)
 		}
 	}
@@ -59,6 +53,27 @@ func NewAttributeSelector(attributes, excluded []string) *AttributeSelector {
 	return as
 }
 
+// splitSelectorPath splits an attribute path into the lowercase name of a
+// top-level attribute and its sub-attribute path. Attributes of extensions are
+// qualified by the extension URN, which is the top-level member holding them:
+//
+//	urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value
+//	-> "urn:ietf:params:scim:schemas:extension:enterprise:2.0:user", "manager.value"
+//
+// The URN prefix of core schema attributes is dropped.
+func splitSelectorPath(attr string) (parent, sub string) {
+	urn, attrPath := SplitSchemaURN(attr)
+	if urn != "" && isCoreSchema(urn) && attrPath != "" {
+		urn, attr = "", attrPath
+	}
+	if urn != "" {
+		return strings.ToLower(urn), strings.ToLower(attrPath)
+	}
+
+	parent, sub, _ = strings.Cut(strings.ToLower(attr), ".")
+	return parent, sub
+}
+
 // FilterResource filters a resource based on attribute selection
 func (as *AttributeSelector) FilterResource(resource any) (any, error) {
 	// If no filtering needed, return as-is
@@ -128,6 +143,12 @@ func (as *AttributeSelector) FilterResource(resource any) (any, error) {
 		}
 	}
 
+	if projectionAssertions.Load() {
+		if err := as.checkProjection(result, filtered); err != nil {
+			return nil, err
+		}
+	}
+
 	return filtered, nil
 }
 
@@ -303,7 +324,16 @@ func (as *AttributeSelector) excludeFromMap(objMap map[string]any, exclusions ma
 // SortResources sorts resources based on sortBy and sortOrder.
 // Pre-extracts attribute values once per resource for optimal performance,
 // especially important for nested attributes that require JSON marshaling.
+// The sort is stable: resources with equal values keep the plugin's order, so
+// pages of a sorted listing are deterministic. Strings are sorted in code
+// point order.
 func SortResources[T any](resources []T, sortBy, sortOrder string) []T {
+	return SortResourcesCollated(resources, sortBy, sortOrder, "")
+}
+
+// SortResourcesCollated sorts resources like SortResources, with strings
+// sorted by collation (see QueryParams.Collation)
+func SortResourcesCollated[T any](resources []T, sortBy, sortOrder, collation string) []T {
 	if sortBy == "" || len(resources) == 0 {
 		return resources
 	}
@@ -325,8 +355,8 @@ func SortResources[T any](resources []T, sortBy, sortOrder string) []T {
 		}
 	}
 
-	sort.Slice(pairs, func(i, j int) bool {
-		cmp := compareForSort(pairs[i].value, pairs[j].value)
+	sort.SliceStable(pairs, func(i, j int) bool {
+		cmp := compareForSort(pairs[i].value, pairs[j].value, collation)
 		if ascending {
 			return cmp < 0
 		}
@@ -340,10 +370,10 @@ func SortResources[T any](resources []T, sortBy, sortOrder string) []T {
 	return sorted
 }
 
-// compareForSort compares two values for sorting.
+// compareForSort compares two values for sorting, strings by collation.
 //
 // Returns -1 if a < b, 0 if a == b, 1 if a > b.
-func compareForSort(a, b any) int {
+func compareForSort(a, b any, collation string) int {
 	if a == nil && b == nil {
 		return 0
 	}
@@ -357,13 +387,7 @@ func compareForSort(a, b any) int {
 	aStr, aIsStr := a.(string)
 	bStr, bIsStr := b.(string)
 	if aIsStr && bIsStr {
-		if aStr < bStr {
-			return -1
-		}
-		if aStr > bStr {
-			return 1
-		}
-		return 0
+		return compareStrings(aStr, bStr, collation)
 	}
 
 	aNum := toFloat64(a)
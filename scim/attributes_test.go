@@ -13,7 +13,7 @@ func TestAttributeSelector(t *testing.T) {
 		DisplayName: "John Doe",
 		Active:      Bool(true),
 		Emails: []Email{
-			{Value: "john@example.com", Primary: true, Type: "work"},
+			{Value: "john@example.com", Primary: Bool(true), Type: "work"},
 		},
 		Meta: &Meta{
 			ResourceType: "User",
@@ -87,8 +87,8 @@ func TestAttributeSelectorSubAttributes(t *testing.T) {
 		DisplayName: "John Doe",
 		Active:      Bool(true),
 		Emails: []Email{
-			{Value: "john@example.com", Type: "work", Primary: true},
-			{Value: "john.personal@example.com", Type: "personal", Primary: false},
+			{Value: "john@example.com", Type: "work", Primary: Bool(true)},
+			{Value: "john.personal@example.com", Type: "personal", Primary: Bool(false)},
 		},
 		Meta: &Meta{
 			ResourceType: "User",
@@ -676,7 +676,7 @@ func TestAttributeSelectorExcludedNestedPaths(t *testing.T) {
 			Formatted:  "John Doe",
 		},
 		Emails: []Email{
-			{Value: "john@example.com", Type: "work", Primary: true},
+			{Value: "john@example.com", Type: "work", Primary: Bool(true)},
 		},
 		Meta: &Meta{
 			ResourceType: "User",
@@ -1133,7 +1133,7 @@ func TestCompareForSort_TimeValues(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := compareForSort(tt.a, tt.b)
+			result := compareForSort(tt.a, tt.b, "")
 			if result != tt.expected {
 				t.Errorf("compareForSort(%v, %v) = %d, expected %d",
 					tt.a, tt.b, result, tt.expected)
@@ -1182,3 +1182,32 @@ func TestSortResources_TemporalFieldsRegression(t *testing.T) {
 		}
 	})
 }
+
+func TestAttributeSelectorSchemaURNs(t *testing.T) {
+	user := &User{
+		ID:             "1",
+		UserName:       "alice",
+		DisplayName:    "Alice",
+		EnterpriseUser: map[string]any{"department": "Sales", "costCenter": "CC-1"},
+	}
+
+	selected, err := NewAttributeSelector([]string{SchemaEnterpriseUser + ":department", SchemaUser + ":userName"}, nil).FilterResource(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := selected.(map[string]any)
+	if got["userName"] != "alice" || got["displayName"] != nil {
+		t.Errorf("selected = %v, want userName only from the core schema", got)
+	}
+	if ext := got[SchemaEnterpriseUser].(map[string]any); ext["department"] != "Sales" || ext["costCenter"] != nil {
+		t.Errorf("enterprise = %v, want department only", ext)
+	}
+
+	excluded, err := NewAttributeSelector(nil, []string{SchemaEnterpriseUser + ":costCenter"}).FilterResource(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := excluded.(map[string]any)[SchemaEnterpriseUser].(map[string]any); ext["costCenter"] != nil || ext["department"] != "Sales" {
+		t.Errorf("enterprise = %v, want costCenter excluded", ext)
+	}
+}
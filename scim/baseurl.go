@@ -0,0 +1,90 @@
+package scim
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// BaseURLProvider is an optional interface for plugins exposed under their
+// own public base URL, such as a vanity domain per customer. The server builds
+// the Location header, meta.location and the $ref of group members and user
+// groups from it instead of the server's base URL. The plugin name is still
+// appended, e.g. https://scim.acme.example/hr/Users/2819c223.
+//
+// The server discovers the interface through wrappers such as the plugin
+// adapter, which provides it from the plugin's baseURL setting. An empty
+// base URL uses the server's.
+type BaseURLProvider interface {
+	BaseURL() string
+}
+
+// resourceBaseURL returns the URL the resources of a plugin are served
+// under, e.g. http://localhost:8080/hr, followed by the base entity of a
+// request scoped to one, e.g. http://localhost:8080/hr/acme
+func (s *Server) resourceBaseURL(ctx context.Context, plugin PluginGetter, pluginName string) string {
+	base := s.baseURL
+	if provider, ok := lookupCapability[BaseURLProvider](plugin); ok {
+		if custom := provider.BaseURL(); custom != "" {
+			base = strings.TrimSuffix(custom, "/")
+		}
+	}
+	if entity := BaseEntityFromContext(ctx); entity != "" {
+		return base + "/" + pluginName + "/" + url.PathEscape(entity)
+	}
+	return base + "/" + pluginName
+}
+
+// resourceLocation returns the location URL of a resource of a plugin
+func (s *Server) resourceLocation(ctx context.Context, plugin PluginGetter, pluginName, resourceType, id string) string {
+	return s.resourceBaseURL(ctx, plugin, pluginName) + "/" + resourceType + "/" + id
+}
+
+// linkGroups returns groups with the $ref the plugin left empty set. The
+// slice is copied before it is changed, as plugins may share it.
+func linkGroups(groups []GroupRef, base string) []GroupRef {
+	var linked []GroupRef
+	for i, g := range groups {
+		if g.Ref != "" || g.Value == "" {
+			continue
+		}
+		if linked == nil {
+			linked = slices.Clone(groups)
+		}
+		linked[i].Ref = base + "/Groups/" + g.Value
+	}
+	if linked == nil {
+		return groups
+	}
+	return linked
+}
+
+// linkMembers returns members with the $ref the plugin left empty set,
+// copying the slice like linkGroups. Members without a type are left as they
+// are, as they could be users or groups.
+func linkMembers(members []MemberRef, base string) []MemberRef {
+	var linked []MemberRef
+	for i, m := range members {
+		if m.Ref != "" || m.Value == "" {
+			continue
+		}
+		var endpoint string
+		switch m.Type {
+		case ResourceTypeUser:
+			endpoint = "/Users/"
+		case ResourceTypeGroup:
+			endpoint = "/Groups/"
+		default:
+			continue
+		}
+		if linked == nil {
+			linked = slices.Clone(members)
+		}
+		linked[i].Ref = base + endpoint + m.Value
+	}
+	if linked == nil {
+		return members
+	}
+	return linked
+}
@@ -0,0 +1,103 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// vanityPlugin is served under its own public hostname
+type vanityPlugin struct {
+	*mockPlugin
+}
+
+func (p *vanityPlugin) BaseURL() string {
+	return "https://scim.acme.example/"
+}
+
+func TestServer_BaseURLLocation(t *testing.T) {
+	tests := []struct {
+		name   string
+		plugin PluginGetter
+		want   string
+	}{
+		{"server base URL", newMockPlugin(), "http://localhost:8080/test/Users/"},
+		{"plugin base URL", &vanityPlugin{newMockPlugin()}, "https://scim.acme.example/test/Users/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: tt.plugin})
+
+			req := httptest.NewRequest(http.MethodPost, "/test/Users", strings.NewReader(`{"userName": "alice"}`))
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+
+			var created User
+			if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+				t.Fatal(err)
+			}
+			if want := tt.want + created.ID; w.Header().Get("Location") != want {
+				t.Errorf("Location = %q, want %q", w.Header().Get("Location"), want)
+			}
+			if created.Meta == nil || created.Meta.Location != w.Header().Get("Location") {
+				t.Errorf("meta = %+v, want the Location header", created.Meta)
+			}
+		})
+	}
+}
+
+func TestServer_BaseURLRefs(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.users["u1"] = &User{ID: "u1", UserName: "alice", Groups: []GroupRef{{Value: "g1"}}}
+	plugin.groups["g1"] = &Group{ID: "g1", DisplayName: "Eng", Members: []MemberRef{
+		{Value: "u1", Type: "User"},
+		{Value: "g2", Type: "Group"},
+		{Value: "u2"},
+		{Value: "u3", Type: "User", Ref: "https://elsewhere.example/Users/u3"},
+	}}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: &vanityPlugin{plugin}})
+
+	get := func(path string, v any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %s", path, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var user User
+	get("/test/Users/u1", &user)
+	if got := user.Groups[0].Ref; got != "https://scim.acme.example/test/Groups/g1" {
+		t.Errorf("groups[0].$ref = %q", got)
+	}
+
+	var group Group
+	get("/test/Groups/g1", &group)
+	want := []string{
+		"https://scim.acme.example/test/Users/u1",
+		"https://scim.acme.example/test/Groups/g2",
+		"", // untyped members could be users or groups
+		"https://elsewhere.example/Users/u3",
+	}
+	for i, ref := range want {
+		if got := group.Members[i].Ref; got != ref {
+			t.Errorf("members[%d].$ref = %q, want %q", i, got, ref)
+		}
+	}
+
+	var list ListResponse[*Group]
+	get("/test/Groups", &list)
+	if got := list.Resources[0].Members[0].Ref; got != want[0] {
+		t.Errorf("listed members[0].$ref = %q, want %q", got, want[0])
+	}
+}
@@ -3,9 +3,12 @@ package scim
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +17,35 @@ const (
 	SchemaBulkResponse = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
 )
 
+// Default limits of bulk requests, advertised in ServiceProviderConfig
+const (
+	DefaultBulkMaxOperations  = 1000
+	DefaultBulkMaxPayloadSize = 1 << 20 // 1MB
+)
+
+// BulkLimitsProvider is an optional interface for plugins limiting the size
+// of bulk requests. Requests with more operations than maxOperations or a
+// body larger than maxPayloadSize bytes are rejected with 413 Payload Too
+// Large. Zero values use DefaultBulkMaxOperations and
+// DefaultBulkMaxPayloadSize.
+type BulkLimitsProvider interface {
+	BulkLimits() (maxOperations, maxPayloadSize int)
+}
+
+// bulkLimits returns the limits of bulk requests to plugin
+func bulkLimits(plugin PluginGetter) (maxOperations, maxPayloadSize int) {
+	if provider, ok := lookupCapability[BulkLimitsProvider](plugin); ok {
+		maxOperations, maxPayloadSize = provider.BulkLimits()
+	}
+	if maxOperations <= 0 {
+		maxOperations = DefaultBulkMaxOperations
+	}
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = DefaultBulkMaxPayloadSize
+	}
+	return maxOperations, maxPayloadSize
+}
+
 // BulkRequest represents a SCIM bulk request
 type BulkRequest struct {
 	Schemas      []string        `json:"schemas"`
@@ -53,11 +85,45 @@ func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request, pluginName s
 		return
 	}
 
+	// Get plugin
+	plugin, ok := s.pluginManager.Get(pluginName)
+	if !ok {
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	config := s.serviceProviderConfig(plugin)
+	if !config.Bulk.Supported {
+		s.handler.WriteSCIMError(w, ErrNotImplemented("Bulk"))
+		return
+	}
+
+	// Enforce the limits advertised in ServiceProviderConfig (RFC 7644
+	// Section 3.7.4)
+	maxPayloadSize := config.Bulk.MaxPayloadSize
+	if r.ContentLength > int64(maxPayloadSize) {
+		s.handler.WriteSCIMError(w, ErrPayloadTooLarge(maxPayloadSize))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxPayloadSize)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.handler.WriteSCIMError(w, ErrPayloadTooLarge(maxPayloadSize))
+			return
+		}
+		s.handler.WriteError(w, http.StatusBadRequest, "Failed to read request body", "invalidSyntax")
+		return
+	}
+
 	var bulkReq BulkRequest
-	if err := json.NewDecoder(r.Body).Decode(&bulkReq); err != nil {
+	if err := json.Unmarshal(body, &bulkReq); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
 		return
 	}
+	if len(bulkReq.Operations) > config.Bulk.MaxOperations {
+		s.handler.WriteSCIMError(w, ErrTooManyOperations(config.Bulk.MaxOperations))
+		return
+	}
 
 	// Validate schemas
 	validSchema := slices.Contains(bulkReq.Schemas, SchemaBulkRequest)
@@ -72,23 +138,46 @@ func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request, pluginName s
 		return
 	}
 
-	// Get plugin
-	plugin, ok := s.pluginManager.Get(pluginName)
-	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
-		return
-	}
-
 	// Process operations
 	bulkResp := BulkResponse{
 		Schemas:    []string{SchemaBulkResponse},
 		Operations: make([]BulkOperationResponse, 0, len(bulkReq.Operations)),
 	}
 
+	// Run the operations in one transaction if the plugin supports it, so that
+	// reaching failOnErrors rolls back the operations already applied
+	ctx := r.Context()
+	var tx TransactionalPlugin
+	txDone := false
+	if bulkReq.FailOnErrors > 0 {
+		if t, ok := lookupCapability[TransactionalPlugin](plugin); ok {
+			txCtx, err := t.Begin(ctx)
+			if err != nil {
+				s.handler.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to begin transaction: %v", err), "")
+				return
+			}
+			tx, ctx = t, WithTransaction(txCtx)
+
+			// Roll back transactions left open by a panic or an early return,
+			// even if the request was canceled
+			defer func() {
+				if !txDone {
+					_ = t.Rollback(context.WithoutCancel(ctx))
+				}
+			}()
+		}
+	}
+
 	errorCount := 0
+	failed := false
 	bulkIDMap := make(map[string]string) // Maps bulkId to actual resource ID
 
-	for _, op := range bulkReq.Operations {
+	// Run operations after the operations whose bulkIds they reference, and
+	// respond in request order
+	results := make([]*BulkOperationResponse, len(bulkReq.Operations))
+	for _, i := range bulkExecutionOrder(bulkReq.Operations) {
+		op := bulkReq.Operations[i]
+
 		// Replace bulkId references in path
 		path := op.Path
 		for bulkID, resourceID := range bulkIDMap {
@@ -103,18 +192,43 @@ func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request, pluginName s
 		}
 
 		// Process operation
-		opResp := s.processBulkOperation(r.Context(), plugin, pluginName, op, path, bulkIDMap)
-		bulkResp.Operations = append(bulkResp.Operations, opResp)
+		opResp := s.processBulkOperation(ctx, plugin, pluginName, op, path, bulkIDMap)
+		if opResp.Status == strconv.Itoa(http.StatusAccepted) && opResp.Location != "" {
+			// Accepted operations report a location relative to the plugin
+			opResp.Location = s.resourceBaseURL(ctx, plugin, pluginName) + "/" + opResp.Location
+		}
+		results[i] = &opResp
 
 		// Check error count
-		if opResp.Status != "200" && opResp.Status != "201" && opResp.Status != "204" {
+		if !isBulkSuccess(opResp.Status) {
 			errorCount++
 			if bulkReq.FailOnErrors > 0 && errorCount >= bulkReq.FailOnErrors {
+				failed = true
 				break
 			}
 		}
 	}
 
+	if tx != nil {
+		txDone = true
+		if failed {
+			if err := tx.Rollback(ctx); err != nil {
+				s.handler.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to roll back transaction: %v", err), "")
+				return
+			}
+			markRolledBack(results, bulkReq.FailOnErrors)
+		} else if err := tx.Commit(ctx); err != nil {
+			s.handler.WriteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to commit transaction: %v", err), "")
+			return
+		}
+	}
+
+	for _, opResp := range results {
+		if opResp != nil {
+			bulkResp.Operations = append(bulkResp.Operations, *opResp)
+		}
+	}
+
 	s.handler.WriteJSON(w, http.StatusOK, bulkResp)
 }
 
@@ -125,25 +239,55 @@ func (s *Server) processBulkOperation(ctx context.Context, plugin PluginGetter,
 		BulkID: op.BulkID,
 	}
 
-	// Parse path to determine resource type and ID
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) < 1 {
-		resp.Status = "400"
-		resp.Response = map[string]any{
-			"detail": "Invalid path",
+	target, err := parseBulkPath(path)
+	if err != nil {
+		return bulkPathError(op, http.StatusBadRequest, err.Error())
+	}
+
+	// Resolve the resource type against the endpoints the plugin serves
+	var custom ResourceType
+	if target.resourceType != "Users" && target.resourceType != "Groups" {
+		rt, ok := s.findResourceType(plugin, target.resourceType)
+		if !ok {
+			return bulkPathError(op, http.StatusNotFound, fmt.Sprintf("Resource type '%s' not found", target.resourceType))
 		}
-		return resp
+		custom = rt
 	}
 
-	resourceType := parts[0]
-	var resourceID string
-	if len(parts) > 1 {
-		resourceID = parts[1]
+	method := strings.ToUpper(op.Method)
+	switch method {
+	case "POST":
+		if target.id != "" {
+			return bulkPathError(op, http.StatusBadRequest, fmt.Sprintf("POST path '%s' must not include a resource ID", path))
+		}
+	case "PUT", "PATCH", "DELETE":
+		if target.id == "" {
+			return bulkPathError(op, http.StatusBadRequest, fmt.Sprintf("%s path '%s' must include a resource ID", method, path))
+		}
+	default:
+		return bulkError(op, ErrInvalidSyntax("Invalid method"), http.StatusBadRequest)
 	}
 
-	switch strings.ToUpper(op.Method) {
+	if operation := bulkOperation(method); !allowsOperation(plugin, target.resourceType, operation) {
+		return bulkError(op, ErrOperationNotAllowed(operation, target.resourceType, pluginName), http.StatusForbidden)
+	}
+
+	if custom != nil {
+		return s.processBulkResourceOperation(ctx, plugin, pluginName, custom, target, op, bulkIDMap)
+	}
+
+	// The version of an operation is its If-Match precondition
+	if op.Version != "" && method != "POST" {
+		conditional, failure := s.checkBulkVersion(ctx, plugin, pluginName, target, op)
+		if failure != nil {
+			return *failure
+		}
+		ctx = conditional
+	}
+
+	switch method {
 	case "POST":
-		switch resourceType {
+		switch target.resourceType {
 		case "Users":
 			resp = s.bulkCreateUser(ctx, plugin, op, pluginName, bulkIDMap)
 		case "Groups":
@@ -151,34 +295,206 @@ func (s *Server) processBulkOperation(ctx context.Context, plugin PluginGetter,
 		}
 
 	case "PUT":
-		switch resourceType {
+		switch target.resourceType {
 		case "Users":
-			resp = s.bulkUpdateUser(ctx, plugin, resourceID, op)
+			resp = s.bulkUpdateUser(ctx, plugin, target.id, op)
 		case "Groups":
-			resp = s.bulkUpdateGroup(ctx, plugin, resourceID, op)
+			resp = s.bulkUpdateGroup(ctx, plugin, target.id, op)
 		}
 
 	case "PATCH":
-		switch resourceType {
+		switch target.resourceType {
 		case "Users":
-			resp = s.bulkPatchUser(ctx, plugin, resourceID, op)
+			resp = s.bulkPatchUser(ctx, plugin, target.id, op)
 		case "Groups":
-			resp = s.bulkPatchGroup(ctx, plugin, resourceID, op)
+			resp = s.bulkPatchGroup(ctx, plugin, target.id, op)
 		}
 
 	case "DELETE":
-		switch resourceType {
+		switch target.resourceType {
 		case "Users":
-			resp = s.bulkDeleteUser(ctx, plugin, resourceID, op)
+			resp = s.bulkDeleteUser(ctx, plugin, target.id, op)
 		case "Groups":
-			resp = s.bulkDeleteGroup(ctx, plugin, resourceID, op)
+			resp = s.bulkDeleteGroup(ctx, plugin, target.id, op)
 		}
+	}
 
-	default:
-		resp.Status = "400"
-		resp.Response = map[string]any{
-			"detail": "Invalid method",
+	return resp
+}
+
+// bulkPath is the target of a bulk operation
+type bulkPath struct {
+	resourceType string // endpoint of the resource type, e.g. "Users"
+	id           string // resource ID, "" for POST
+}
+
+// parseBulkPath splits an operation path like "/Users/2819c223" into the
+// resource type endpoint and resource ID. Trailing slashes and fragments,
+// which some IdPs send, are ignored. Query strings are rejected since bulk
+// operations address single resources or endpoints.
+func parseBulkPath(path string) (bulkPath, error) {
+	path, _, _ = strings.Cut(path, "#")
+	if strings.Contains(path, "?") {
+		return bulkPath{}, fmt.Errorf("Bulk operation path '%s' must not contain a query", path)
+	}
+
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return bulkPath{}, fmt.Errorf("Bulk operation path is required")
+	}
+
+	parts := strings.Split(trimmed, "/")
+	if len(parts) > 2 || slices.Contains(parts, "") {
+		return bulkPath{}, fmt.Errorf("Invalid bulk operation path '%s'", path)
+	}
+
+	target := bulkPath{resourceType: parts[0]}
+	if len(parts) == 2 {
+		target.id = parts[1]
+	}
+	return target, nil
+}
+
+// bulkPathError is the response of an operation whose path does not address
+// a resource or endpoint of the plugin
+func bulkPathError(op BulkOperation, status int, detail string) BulkOperationResponse {
+	return bulkError(op, NewSCIMError(status, detail, ScimTypeInvalidPath), status)
+}
+
+// bulkError is the response of an operation that failed with err. SCIM
+// errors keep their status, like in handlePluginError, and other errors get
+// fallbackStatus. Operations a plugin accepted for later get status 202.
+func bulkError(op BulkOperation, err error, fallbackStatus int) BulkOperationResponse {
+	var accepted *AcceptedError
+	if errors.As(err, &accepted) {
+		return BulkOperationResponse{Method: op.Method, BulkID: op.BulkID, Location: accepted.Location, Status: strconv.Itoa(http.StatusAccepted)}
+	}
+
+	body := Error{Schemas: []string{SchemaError}, Status: strconv.Itoa(fallbackStatus), Detail: err.Error()}
+	var scimErr *SCIMError
+	if errors.As(err, &scimErr) {
+		body.Status = strconv.Itoa(scimErr.Status)
+		body.ScimType = scimErr.ScimType
+	}
+	return BulkOperationResponse{
+		Method:   op.Method,
+		BulkID:   op.BulkID,
+		Status:   body.Status,
+		Response: body,
+	}
+}
+
+// checkBulkVersion checks the version of an operation against the ETag of
+// the resource it targets, as the If-Match header of a PUT, PATCH or DELETE
+// request is checked, and returns the context passing the precondition to
+// the plugin. Operations whose version does not match fail with 412.
+func (s *Server) checkBulkVersion(ctx context.Context, plugin PluginGetter, pluginName string, target bulkPath, op BulkOperation) (context.Context, *BulkOperationResponse) {
+	fail := func(err error, fallbackStatus int) (context.Context, *BulkOperationResponse) {
+		resp := bulkError(op, err, fallbackStatus)
+		return ctx, &resp
+	}
+
+	var current any
+	var meta *Meta
+	switch target.resourceType {
+	case "Users":
+		user, err := plugin.GetUser(ctx, target.id, nil)
+		if err != nil {
+			return fail(err, http.StatusNotFound)
+		}
+		s.normalizeUser(user, s.resourceBaseURL(ctx, plugin, pluginName))
+		current, meta = user, user.Meta
+	case "Groups":
+		group, err := plugin.GetGroup(ctx, target.id, nil)
+		if err != nil {
+			return fail(err, http.StatusNotFound)
+		}
+		s.normalizeGroup(group, s.resourceBaseURL(ctx, plugin, pluginName))
+		current, meta = group, group.Meta
+	}
+
+	etag, err := s.etagGen.Generate(current)
+	if err != nil {
+		return fail(errors.New("Failed to generate ETag"), http.StatusInternalServerError)
+	}
+	if !s.etagGen.matchesETag(op.Version, etag) {
+		return fail(ErrPreconditionFailed("precondition failed: ETag mismatch"), http.StatusPreconditionFailed)
+	}
+
+	precondition := Precondition{IfMatch: op.Version, CurrentETag: etag}
+	if meta != nil {
+		precondition.Version = meta.Version
+	}
+	return WithPrecondition(ctx, precondition), nil
+}
+
+// processBulkResourceOperation runs a bulk operation on a custom resource
+// type, the way the resource handlers serve the corresponding request
+func (s *Server) processBulkResourceOperation(ctx context.Context, plugin PluginGetter, pluginName string, rt ResourceType, target bulkPath, op BulkOperation, bulkIDMap map[string]string) BulkOperationResponse {
+	resp := BulkOperationResponse{Method: op.Method, BulkID: op.BulkID}
+
+	decode := func() (CustomResource, error) {
+		data, _ := json.Marshal(op.Data)
+		resource, err := rt.decode(data)
+		if err != nil || resource == nil {
+			return nil, NewSCIMErrorf(http.StatusBadRequest, ScimTypeInvalidSyntax, "Invalid %s data", rt.Definition().Name)
+		}
+		if err := NewValidator().ValidateResource(rt, resource); err != nil {
+			return nil, err
 		}
+		return resource, nil
+	}
+
+	switch strings.ToUpper(op.Method) {
+	case "POST":
+		resource, err := decode()
+		if err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		created, err := rt.create(ctx, resource)
+		if err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		s.normalizeResource(rt, created)
+		common := created.Base()
+		if op.BulkID != "" {
+			bulkIDMap[op.BulkID] = common.ID
+		}
+		resp.Status = "201"
+		resp.Location = s.resourceLocation(ctx, plugin, pluginName, target.resourceType, common.ID)
+		common.Meta.Location = resp.Location
+		resp.Response = created
+
+	case "PUT":
+		resource, err := decode()
+		if err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		// Replace like handleReplaceResource
+		if _, err := rt.replace(ctx, target.id, resource); err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		resp.Status = "200"
+
+	case "PATCH":
+		data, _ := json.Marshal(op.Data)
+		var patch PatchOp
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return bulkError(op, ErrInvalidSyntax("Invalid patch data"), http.StatusBadRequest)
+		}
+		if err := NewValidator().ValidatePatchOp(&patch); err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		if err := rt.modify(ctx, target.id, &patch); err != nil {
+			return bulkError(op, err, http.StatusBadRequest)
+		}
+		resp.Status = "204"
+
+	case "DELETE":
+		if err := rt.delete(ctx, target.id); err != nil {
+			return bulkError(op, err, http.StatusNotFound)
+		}
+		resp.Status = "204"
 	}
 
 	return resp
@@ -192,17 +508,26 @@ func (s *Server) bulkCreateUser(ctx context.Context, plugin PluginGetter, op Bul
 	data, _ := json.Marshal(op.Data)
 	var user User
 	if err := json.Unmarshal(data, &user); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid user data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid user data"), http.StatusBadRequest)
+	}
+	if err := normalizeUserInput(plugin, &user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateUser(&user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkExternalID(ctx, plugin, ResourceTypeUser, "", user.ExternalID); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.hashUserPassword(&user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	created, err := plugin.CreateUser(ctx, &user)
 	if err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusBadRequest)
 	}
+	s.normalizeUser(created, s.resourceBaseURL(ctx, plugin, pluginName))
 
 	// Store bulkId mapping
 	if op.BulkID != "" {
@@ -210,7 +535,7 @@ func (s *Server) bulkCreateUser(ctx context.Context, plugin PluginGetter, op Bul
 	}
 
 	resp.Status = "201"
-	resp.Location = s.handler.GetResourceLocation(pluginName, "Users", created.ID)
+	resp.Location = s.resourceLocation(ctx, plugin, pluginName, "Users", created.ID)
 	resp.Response = created
 	return resp
 }
@@ -221,24 +546,30 @@ func (s *Server) bulkCreateGroup(ctx context.Context, plugin PluginGetter, op Bu
 	data, _ := json.Marshal(op.Data)
 	var group Group
 	if err := json.Unmarshal(data, &group); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid group data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid group data"), http.StatusBadRequest)
+	}
+	if err := normalizeGroupInput(plugin, &group); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateGroup(&group); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkExternalID(ctx, plugin, ResourceTypeGroup, "", group.ExternalID); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	created, err := plugin.CreateGroup(ctx, &group)
 	if err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusBadRequest)
 	}
+	s.normalizeGroup(created, s.resourceBaseURL(ctx, plugin, pluginName))
 
 	if op.BulkID != "" {
 		bulkIDMap[op.BulkID] = created.ID
 	}
 
 	resp.Status = "201"
-	resp.Location = s.handler.GetResourceLocation(pluginName, "Groups", created.ID)
+	resp.Location = s.resourceLocation(ctx, plugin, pluginName, "Groups", created.ID)
 	resp.Response = created
 	return resp
 }
@@ -249,20 +580,32 @@ func (s *Server) bulkUpdateUser(ctx context.Context, plugin PluginGetter, id str
 	data, _ := json.Marshal(op.Data)
 	var user User
 	if err := json.Unmarshal(data, &user); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid user data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid user data"), http.StatusBadRequest)
 	}
-
-	patch := &PatchOp{
-		Schemas:    []string{SchemaPatchOp},
-		Operations: []PatchOperation{{Op: "replace", Value: user}},
+	if err := normalizeUserInput(plugin, &user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateUser(&user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkExternalID(ctx, plugin, ResourceTypeUser, id, user.ExternalID); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	current, err := plugin.GetUser(ctx, id, nil)
+	if err != nil {
+		return bulkError(op, err, http.StatusNotFound)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateReplaceMutability(ResourceTypeUser, &user, current); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.hashUserPassword(&user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
-	if err := plugin.ModifyUser(ctx, id, patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+	// Replace like replaceUser: in place if the plugin can, else delete and
+	// recreate
+	if _, err := ReplaceUser(ctx, plugin, id, &user); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	resp.Status = "200"
@@ -275,20 +618,29 @@ func (s *Server) bulkUpdateGroup(ctx context.Context, plugin PluginGetter, id st
 	data, _ := json.Marshal(op.Data)
 	var group Group
 	if err := json.Unmarshal(data, &group); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid group data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid group data"), http.StatusBadRequest)
 	}
-
-	patch := &PatchOp{
-		Schemas:    []string{SchemaPatchOp},
-		Operations: []PatchOperation{{Op: "replace", Value: group}},
+	if err := normalizeGroupInput(plugin, &group); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateGroup(&group); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkExternalID(ctx, plugin, ResourceTypeGroup, id, group.ExternalID); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	current, err := plugin.GetGroup(ctx, id, nil)
+	if err != nil {
+		return bulkError(op, err, http.StatusNotFound)
+	}
+	if err := NewValidatorWithSchemas(s.schemas).ValidateReplaceMutability(ResourceTypeGroup, &group, current); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
-	if err := plugin.ModifyGroup(ctx, id, patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+	// Replace like replaceGroup: in place if the plugin can, else delete and
+	// recreate
+	if _, err := ReplaceGroup(ctx, plugin, id, &group); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	resp.Status = "200"
@@ -301,15 +653,31 @@ func (s *Server) bulkPatchUser(ctx context.Context, plugin PluginGetter, id stri
 	data, _ := json.Marshal(op.Data)
 	var patch PatchOp
 	if err := json.Unmarshal(data, &patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid patch data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid patch data"), http.StatusBadRequest)
+	}
+	if err := normalizePatchInput(plugin, ResourceTypeUser, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
+	if err := validator.ValidatePatchExtensions(ResourceTypeUser, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkPatchExternalID(ctx, plugin, ResourceTypeUser, id, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	current, err := plugin.GetUser(ctx, id, nil)
+	if err != nil {
+		return bulkError(op, err, http.StatusNotFound)
+	}
+	if err := validator.ValidatePatchMutability(ResourceTypeUser, &patch, current); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.hashPatchPasswords(&patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	if err := plugin.ModifyUser(ctx, id, &patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	resp.Status = "204"
@@ -322,15 +690,28 @@ func (s *Server) bulkPatchGroup(ctx context.Context, plugin PluginGetter, id str
 	data, _ := json.Marshal(op.Data)
 	var patch PatchOp
 	if err := json.Unmarshal(data, &patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": "Invalid patch data"}
-		return resp
+		return bulkError(op, ErrInvalidSyntax("Invalid patch data"), http.StatusBadRequest)
+	}
+	if err := normalizePatchInput(plugin, ResourceTypeGroup, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
+	if err := validator.ValidatePatchExtensions(ResourceTypeGroup, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	if err := s.checkPatchExternalID(ctx, plugin, ResourceTypeGroup, id, &patch); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
+	}
+	current, err := plugin.GetGroup(ctx, id, nil)
+	if err != nil {
+		return bulkError(op, err, http.StatusNotFound)
+	}
+	if err := validator.ValidatePatchMutability(ResourceTypeGroup, &patch, current); err != nil {
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	if err := plugin.ModifyGroup(ctx, id, &patch); err != nil {
-		resp.Status = "400"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusBadRequest)
 	}
 
 	resp.Status = "204"
@@ -341,9 +722,7 @@ func (s *Server) bulkDeleteUser(ctx context.Context, plugin PluginGetter, id str
 	resp := BulkOperationResponse{Method: op.Method, BulkID: op.BulkID}
 
 	if err := plugin.DeleteUser(ctx, id); err != nil {
-		resp.Status = "404"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusNotFound)
 	}
 
 	resp.Status = "204"
@@ -354,9 +733,7 @@ func (s *Server) bulkDeleteGroup(ctx context.Context, plugin PluginGetter, id st
 	resp := BulkOperationResponse{Method: op.Method, BulkID: op.BulkID}
 
 	if err := plugin.DeleteGroup(ctx, id); err != nil {
-		resp.Status = "404"
-		resp.Response = map[string]any{"detail": err.Error()}
-		return resp
+		return bulkError(op, err, http.StatusNotFound)
 	}
 
 	resp.Status = "204"
@@ -392,6 +769,17 @@ func extractBulkIdReferences(data any) []string {
 	return references
 }
 
+// extractPathBulkIdReference returns the bulkId referenced by an operation
+// path like "/Users/bulkId:user1", or "" if there is none
+func extractPathBulkIdReference(path string) string {
+	_, after, found := strings.Cut(path, "bulkId:")
+	if !found {
+		return ""
+	}
+	bulkId, _, _ := strings.Cut(after, "/")
+	return bulkId
+}
+
 // buildDependencyGraph creates a directed graph of bulkId dependencies
 // Returns: adjacency list (graph), bulkId to index mapping, error if duplicate bulkIds found
 func buildDependencyGraph(operations []BulkOperation) (map[int][]int, map[string]int, error) {
@@ -410,6 +798,9 @@ func buildDependencyGraph(operations []BulkOperation) (map[int][]int, map[string
 	graph := make(map[int][]int)
 	for i, op := range operations {
 		references := extractBulkIdReferences(op.Data)
+		if ref := extractPathBulkIdReference(op.Path); ref != "" {
+			references = append(references, ref)
+		}
 		for _, bulkId := range references {
 			if depIndex, exists := bulkIdToIndex[bulkId]; exists {
 				graph[i] = append(graph[i], depIndex)
@@ -510,6 +901,62 @@ func validateBulkOperations(operations []BulkOperation) error {
 	return nil
 }
 
+// bulkExecutionOrder returns the operation indices in an order where every
+// operation comes after the operations whose bulkIds it references, so that
+// "bulkId:user1" resolves even if user1 is created later in the request.
+// Otherwise operations keep their request order. The dependency graph must be
+// acyclic (see validateBulkOperations).
+func bulkExecutionOrder(operations []BulkOperation) []int {
+	order := make([]int, 0, len(operations))
+
+	graph, _, err := buildDependencyGraph(operations)
+	if err != nil {
+		for i := range operations {
+			order = append(order, i)
+		}
+		return order
+	}
+
+	// Kahn's algorithm, always taking the earliest operation whose
+	// dependencies have run
+	waiting := make([]int, len(operations)) // dependencies not yet run
+	dependents := make(map[int][]int)
+	for i, deps := range graph {
+		for _, dep := range deps {
+			waiting[i]++
+			dependents[dep] = append(dependents[dep], i)
+		}
+	}
+
+	done := make([]bool, len(operations))
+	for len(order) < len(operations) {
+		next := -1
+		for i := range operations {
+			if !done[i] && waiting[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			// A cycle; validateBulkOperations rejects these before execution
+			for i := range operations {
+				if !done[i] {
+					order = append(order, i)
+				}
+			}
+			break
+		}
+
+		done[next] = true
+		order = append(order, next)
+		for _, dependent := range dependents[next] {
+			waiting[dependent]--
+		}
+	}
+
+	return order
+}
+
 // replaceBulkIdReferences recursively replaces bulkId references in operation data
 // with actual resource IDs from the bulkIDMap
 func replaceBulkIdReferences(data any, bulkIDMap map[string]string) any {
@@ -2,6 +2,7 @@ package scim
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -514,3 +515,469 @@ func TestBulkCircularReferences(t *testing.T) {
 		})
 	}
 }
+
+func TestBulkExecutionOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []BulkOperation
+		want       []int
+	}{
+		{
+			name: "request order without references",
+			operations: []BulkOperation{
+				{Method: "POST", Path: "/Users", BulkID: "user1"},
+				{Method: "POST", Path: "/Users", BulkID: "user2"},
+			},
+			want: []int{0, 1},
+		},
+		{
+			name: "referenced operation runs first",
+			operations: []BulkOperation{
+				{Method: "POST", Path: "/Groups", BulkID: "group1", Data: map[string]any{
+					"members": []any{map[string]any{"value": "bulkId:user1"}},
+				}},
+				{Method: "POST", Path: "/Users", BulkID: "user1"},
+				{Method: "POST", Path: "/Users", BulkID: "user2"},
+			},
+			want: []int{1, 0, 2},
+		},
+		{
+			name: "chain listed in reverse",
+			operations: []BulkOperation{
+				{Method: "POST", Path: "/Users", BulkID: "user3", Data: map[string]any{"manager": map[string]any{"value": "bulkId:user2"}}},
+				{Method: "POST", Path: "/Users", BulkID: "user2", Data: map[string]any{"manager": map[string]any{"value": "bulkId:user1"}}},
+				{Method: "POST", Path: "/Users", BulkID: "user1"},
+			},
+			want: []int{2, 1, 0},
+		},
+		{
+			name: "path reference",
+			operations: []BulkOperation{
+				{Method: "PATCH", Path: "/Users/bulkId:user1"},
+				{Method: "POST", Path: "/Users", BulkID: "user1"},
+			},
+			want: []int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bulkExecutionOrder(tt.operations)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("bulkExecutionOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_BulkForwardReference(t *testing.T) {
+	plugin := newMockPlugin()
+	pm := &mockPluginManager{plugin: plugin}
+	server := NewServer("http://localhost:8880", pm)
+
+	// The group and the patch reference user1 before it is listed
+	bulkJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{
+				"method": "POST",
+				"path": "/Groups",
+				"bulkId": "group1",
+				"data": {
+					"displayName": "Admins",
+					"members": [{"value": "bulkId:user1"}]
+				}
+			},
+			{
+				"method": "PATCH",
+				"path": "/Users/bulkId:user1",
+				"data": {
+					"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+					"Operations": [{"op": "replace", "path": "displayName", "value": "Alice"}]
+				}
+			},
+			{
+				"method": "POST",
+				"path": "/Users",
+				"bulkId": "user1",
+				"data": {"userName": "alice"}
+			}
+		]
+	}`
+
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	var resp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v, body: %s", err, w.Body.String())
+	}
+	if len(resp.Operations) != 3 {
+		t.Fatalf("Operations = %d, want 3", len(resp.Operations))
+	}
+
+	// Responses keep the request order
+	for i, want := range []string{"201", "204", "201"} {
+		if resp.Operations[i].Status != want {
+			t.Errorf("Operation %d status = %v, want %v: %v", i, resp.Operations[i].Status, want, resp.Operations[i].Response)
+		}
+	}
+	if resp.Operations[0].BulkID != "group1" || resp.Operations[2].BulkID != "user1" {
+		t.Errorf("responses out of request order: %+v", resp.Operations)
+	}
+
+	var user *User
+	for _, u := range plugin.users {
+		user = u
+	}
+	if user == nil || user.DisplayName != "Alice" {
+		t.Fatalf("user = %+v, want the patched user", user)
+	}
+	for _, group := range plugin.groups {
+		if len(group.Members) != 1 || group.Members[0].Value != user.ID {
+			t.Errorf("group members = %+v, want %s", group.Members, user.ID)
+		}
+	}
+}
+
+func TestParseBulkPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    bulkPath
+		wantErr bool
+	}{
+		{path: "/Users", want: bulkPath{resourceType: "Users"}},
+		{path: "/Users/", want: bulkPath{resourceType: "Users"}},
+		{path: "Users/42", want: bulkPath{resourceType: "Users", id: "42"}},
+		{path: "/Groups/42/", want: bulkPath{resourceType: "Groups", id: "42"}},
+		{path: "/Users/42#section", want: bulkPath{resourceType: "Users", id: "42"}},
+		{path: "/Devices#", want: bulkPath{resourceType: "Devices"}},
+		{path: "", wantErr: true},
+		{path: "/", wantErr: true},
+		{path: "/Users?filter=userName", wantErr: true},
+		{path: "/Users/42/members", wantErr: true},
+		{path: "/Users//42", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseBulkPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseBulkPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseBulkPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestServer_BulkInvalidPaths(t *testing.T) {
+	server := NewServer("http://localhost:8880", &mockPluginManager{plugin: newMockPlugin()})
+
+	bulkJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Devices", "data": {"displayName": "laptop"}},
+			{"method": "POST", "path": "/Users?attributes=id", "data": {"userName": "alice"}},
+			{"method": "POST", "path": "/Users/42", "data": {"userName": "alice"}},
+			{"method": "DELETE", "path": "/Users"},
+			{"method": "PATCH", "path": "/Users/42/emails", "data": {}},
+			{"method": "POST", "path": "/Users/", "data": {"userName": "bob"}}
+		]
+	}`
+
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var resp struct {
+		Operations []struct {
+			Status   string `json:"status"`
+			Response Error  `json:"response"`
+		} `json:"Operations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatuses := []string{"404", "400", "400", "400", "400", "201"}
+	if len(resp.Operations) != len(wantStatuses) {
+		t.Fatalf("Operations = %d, want %d. Body: %s", len(resp.Operations), len(wantStatuses), w.Body.String())
+	}
+	for i, op := range resp.Operations {
+		if op.Status != wantStatuses[i] {
+			t.Errorf("operation %d status = %s, want %s", i, op.Status, wantStatuses[i])
+		}
+		if op.Status != "201" && op.Response.ScimType != ScimTypeInvalidPath {
+			t.Errorf("operation %d scimType = %q, want invalidPath", i, op.Response.ScimType)
+		}
+	}
+}
+
+func TestServer_BulkCustomResourceType(t *testing.T) {
+	server, devices := newDeviceServer(t)
+
+	bulkJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Devices", "bulkId": "laptop", "data": {"schemas": ["` + testDeviceSchema + `"], "displayName": "laptop"}},
+			{"method": "PATCH", "path": "/Devices/bulkId:laptop", "data": {
+				"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+				"Operations": [{"op": "replace", "path": "serialNumber", "value": "SN-1"}]
+			}},
+			{"method": "DELETE", "path": "/Devices/missing"}
+		]
+	}`
+
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var resp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Operations) != 3 {
+		t.Fatalf("Operations = %d, want 3. Body: %s", len(resp.Operations), w.Body.String())
+	}
+	if resp.Operations[0].Status != "201" || !strings.HasSuffix(resp.Operations[0].Location, "/test/Devices/d1") {
+		t.Errorf("create = %+v, want 201 with location", resp.Operations[0])
+	}
+	if resp.Operations[1].Status != "204" {
+		t.Errorf("patch status = %s, want 204", resp.Operations[1].Status)
+	}
+	if resp.Operations[2].Status != "404" {
+		t.Errorf("delete status = %s, want 404", resp.Operations[2].Status)
+	}
+	if device := devices.devices["d1"]; device == nil || device.SerialNumber != "SN-1" {
+		t.Errorf("device = %+v, want patched serial number", device)
+	}
+}
+
+func TestServer_BulkVersion(t *testing.T) {
+	plugin := &versionedPlugin{mockPlugin: newMockPlugin()}
+	plugin.users["u1"] = &User{ID: "u1", UserName: "alice"}
+	plugin.groups["g1"] = &Group{ID: "g1", DisplayName: "Admins"}
+	server := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	req := httptest.NewRequest("GET", "/test/Users/u1", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+
+	patch := `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "replace", "path": "displayName", "value": "Alice"}]}`
+	bulkJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "PATCH", "path": "/Users/u1", "version": "W/\"stale\"", "data": ` + patch + `},
+			{"method": "PATCH", "path": "/Users/u1", "version": ` + fmt.Sprintf("%q", etag) + `, "data": ` + patch + `},
+			{"method": "DELETE", "path": "/Users/missing", "version": "*"},
+			{"method": "DELETE", "path": "/Groups/g1", "version": "*"}
+		]
+	}`
+
+	req = httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var resp struct {
+		Operations []struct {
+			Status   string `json:"status"`
+			Response Error  `json:"response"`
+		} `json:"Operations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatuses := []string{"412", "204", "404", "204"}
+	if len(resp.Operations) != len(wantStatuses) {
+		t.Fatalf("Operations = %d, want %d. Body: %s", len(resp.Operations), len(wantStatuses), w.Body.String())
+	}
+	for i, op := range resp.Operations {
+		if op.Status != wantStatuses[i] {
+			t.Errorf("operation %d status = %s, want %s", i, op.Status, wantStatuses[i])
+		}
+	}
+	if resp.Operations[0].Response.ScimType != ScimTypeInvalidVers {
+		t.Errorf("stale version scimType = %q, want invalidVers", resp.Operations[0].Response.ScimType)
+	}
+
+	// Only the matching operation reached the plugin, with its precondition
+	if len(plugin.preconditions) != 1 {
+		t.Fatalf("plugin saw %d writes, want 1", len(plugin.preconditions))
+	}
+	if got := plugin.preconditions[0]; got.IfMatch != etag || got.CurrentETag != etag || got.Version != `W/"7"` {
+		t.Errorf("precondition = %+v, want If-Match and ETag %s, version W/\"7\"", got, etag)
+	}
+	if _, ok := plugin.groups["g1"]; ok {
+		t.Error("group g1 was not deleted")
+	}
+
+	// Conflicts the plugin detects on write keep their status
+	plugin.conflict = true
+	bulkJSON = `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [{"method": "PATCH", "path": "/Users/u1", "version": "*", "data": ` + patch + `}]
+	}`
+	req = httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Operations) != 1 || resp.Operations[0].Status != "412" {
+		t.Errorf("conflicting write = %s, want status 412", w.Body.String())
+	}
+}
+
+// conflictPlugin is a mockPlugin that already holds every userName
+type conflictPlugin struct {
+	*mockPlugin
+}
+
+func (p *conflictPlugin) CreateUser(ctx context.Context, user *User) (*User, error) {
+	return nil, fmt.Errorf("insert user: %w", ErrConflict("User", "userName"))
+}
+
+func TestServer_BulkErrorResponses(t *testing.T) {
+	server := NewServer("http://localhost:8880", &mockPluginManager{plugin: &conflictPlugin{newMockPlugin()}})
+
+	bulkJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice"}},
+			{"method": "GET", "path": "/Users/42"},
+			{"method": "PATCH", "path": "/Users/42", "data": {"Operations": "none"}},
+			{"method": "DELETE", "path": "/Groups/missing"}
+		]
+	}`
+
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(bulkJSON))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	var resp struct {
+		Operations []struct {
+			Status   string `json:"status"`
+			Response Error  `json:"response"`
+		} `json:"Operations"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ status, scimType string }{
+		{"409", ScimTypeUniqueness},
+		{"400", ScimTypeInvalidSyntax},
+		{"400", ScimTypeInvalidSyntax},
+		{"404", ""},
+	}
+	if len(resp.Operations) != len(want) {
+		t.Fatalf("Operations = %d, want %d. Body: %s", len(resp.Operations), len(want), w.Body.String())
+	}
+	for i, op := range resp.Operations {
+		if op.Status != want[i].status || op.Response.Status != want[i].status {
+			t.Errorf("operation %d status = %s, response status = %s, want %s", i, op.Status, op.Response.Status, want[i].status)
+		}
+		if op.Response.ScimType != want[i].scimType {
+			t.Errorf("operation %d scimType = %q, want %q", i, op.Response.ScimType, want[i].scimType)
+		}
+		if len(op.Response.Schemas) != 1 || op.Response.Schemas[0] != SchemaError || op.Response.Detail == "" {
+			t.Errorf("operation %d response = %+v, want a SCIM error", i, op.Response)
+		}
+	}
+}
+
+// bulkLimitsPlugin is a mockPlugin limiting the size of bulk requests
+type bulkLimitsPlugin struct {
+	*mockPlugin
+	maxOperations, maxPayloadSize int
+}
+
+func (p *bulkLimitsPlugin) BulkLimits() (int, int) {
+	return p.maxOperations, p.maxPayloadSize
+}
+
+func TestServer_BulkLimits(t *testing.T) {
+	bulkRequest := func(operations int) string {
+		ops := make([]string, operations)
+		for i := range ops {
+			ops[i] = fmt.Sprintf(`{"method": "POST", "path": "/Users", "data": {"userName": "user%d"}}`, i)
+		}
+		return `{"schemas": ["` + SchemaBulkRequest + `"], "Operations": [` + strings.Join(ops, ",") + `]}`
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		chunked      bool
+		wantStatus   int
+		wantScimType string
+		wantDetail   string
+	}{
+		{"within limits", bulkRequest(3), false, http.StatusOK, "", ""},
+		{"too many operations", bulkRequest(4), false, http.StatusRequestEntityTooLarge, ScimTypeTooMany, "maxOperations (3)"},
+		{"payload too large", bulkRequest(1) + strings.Repeat(" ", 1024), false, http.StatusRequestEntityTooLarge, "", "maxPayloadSize (1024)"},
+		{"payload too large without Content-Length", bulkRequest(1) + strings.Repeat(" ", 1024), true, http.StatusRequestEntityTooLarge, "", "maxPayloadSize (1024)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &bulkLimitsPlugin{mockPlugin: newMockPlugin(), maxOperations: 3, maxPayloadSize: 1024}
+			server := NewServer("http://localhost:8880", &mockPluginManager{plugin: plugin})
+
+			req := httptest.NewRequest("POST", "/test/Bulk", strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d. Body: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+			var scimErr Error
+			if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil {
+				t.Fatal(err)
+			}
+			if scimErr.Status != "413" || scimErr.ScimType != tt.wantScimType || !strings.Contains(scimErr.Detail, tt.wantDetail) {
+				t.Errorf("error = %+v, want status 413, scimType %q and detail containing %q", scimErr, tt.wantScimType, tt.wantDetail)
+			}
+			if len(plugin.users) != 0 {
+				t.Errorf("%d users created by a rejected request", len(plugin.users))
+			}
+		})
+	}
+}
+
+func TestServiceProviderConfigBulkLimits(t *testing.T) {
+	tests := []struct {
+		name                            string
+		plugin                          PluginGetter
+		wantOperations, wantPayloadSize int
+	}{
+		{"defaults", newMockPlugin(), DefaultBulkMaxOperations, DefaultBulkMaxPayloadSize},
+		{"plugin limits", &bulkLimitsPlugin{mockPlugin: newMockPlugin(), maxOperations: 50}, 50, DefaultBulkMaxPayloadSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer("http://localhost:8880", &mockPluginManager{plugin: tt.plugin})
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("GET", "/test/ServiceProviderConfig", nil))
+
+			var config ServiceProviderConfig
+			if err := json.Unmarshal(w.Body.Bytes(), &config); err != nil {
+				t.Fatal(err)
+			}
+			if config.Bulk.MaxOperations != tt.wantOperations || config.Bulk.MaxPayloadSize != tt.wantPayloadSize {
+				t.Errorf("bulk = %+v, want maxOperations %d and maxPayloadSize %d", config.Bulk, tt.wantOperations, tt.wantPayloadSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// cacheControlNoStore keeps clients and intermediaries from storing a
+// response. It is the default of every response of the Server, as SCIM
+// resources hold personal data.
+const cacheControlNoStore = "no-store"
+
+// ClientCache is how long clients may cache successful GET responses of a
+// plugin. Zero durations keep the responses from being cached.
+type ClientCache struct {
+	// DiscoveryMaxAge applies to ServiceProviderConfig, ResourceTypes and
+	// Schemas, which only change with the configuration
+	DiscoveryMaxAge time.Duration
+
+	// ResourceMaxAge applies to users, groups and custom resources and their
+	// lists. Once it expires, clients revalidate single resources with
+	// If-None-Match and the ETag they got, answered with 304 Not Modified
+	// while the resource is unchanged.
+	ResourceMaxAge time.Duration
+}
+
+// ClientCacheProvider is an optional interface for plugins whose responses
+// clients may cache, such as read-heavy deployments whose clients poll the
+// same resources. Responses are cached privately, by the client only.
+//
+// The server discovers the interface through wrappers such as the plugin
+// adapter, which also provides it from the plugin's clientCache setting.
+type ClientCacheProvider interface {
+	ClientCache() ClientCache
+}
+
+// clientCache returns how long clients may cache responses of plugin
+func clientCache(plugin PluginGetter) ClientCache {
+	if provider, ok := lookupCapability[ClientCacheProvider](plugin); ok {
+		return provider.ClientCache()
+	}
+	return ClientCache{}
+}
+
+// setCacheControl lets the client cache the response to a GET request for
+// maxAge, or keeps the no-store default if maxAge is not positive. Error
+// responses written afterwards are not cached (see responseWriter).
+func setCacheControl(w http.ResponseWriter, r *http.Request, maxAge time.Duration) {
+	if maxAge <= 0 || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return
+	}
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge/time.Second)))
+}
@@ -0,0 +1,77 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// cachingPlugin is a mockPlugin whose responses clients may cache
+type cachingPlugin struct {
+	*mockPlugin
+	cache ClientCache
+}
+
+func (p *cachingPlugin) ClientCache() ClientCache {
+	return p.cache
+}
+
+func TestCacheControl(t *testing.T) {
+	mock := newMockPlugin()
+	mock.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+	cached := &cachingPlugin{mockPlugin: mock, cache: ClientCache{DiscoveryMaxAge: time.Hour, ResourceMaxAge: 30 * time.Second}}
+
+	tests := []struct {
+		name   string
+		plugin PluginGetter
+		method string
+		target string
+		want   string
+	}{
+		{"default", mock, http.MethodGet, "/test/Users/u1", "no-store"},
+		{"default discovery", mock, http.MethodGet, "/test/ServiceProviderConfig", "no-store"},
+		{"resource", cached, http.MethodGet, "/test/Users/u1", "private, max-age=30"},
+		{"list", cached, http.MethodGet, "/test/Users", "private, max-age=30"},
+		{"discovery", cached, http.MethodGet, "/test/Schemas", "private, max-age=3600"},
+		{"not found", cached, http.MethodGet, "/test/Users/missing", "no-store"},
+		{"write", cached, http.MethodDelete, "/test/Users/u1", "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: tt.plugin})
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q (status %d)", got, tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestCacheControlNotModified(t *testing.T) {
+	mock := newMockPlugin()
+	mock.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+	plugin := &cachingPlugin{mockPlugin: mock, cache: ClientCache{ResourceMaxAge: time.Minute}}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users/u1", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("response has no ETag")
+	}
+
+	// Revalidating with the ETag extends the cached response
+	req := httptest.NewRequest(http.MethodGet, "/test/Users/u1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("Cache-Control = %q, want private, max-age=60", got)
+	}
+}
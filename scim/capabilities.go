@@ -0,0 +1,156 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// QueryCapabilities declares the parts of a list query a plugin applies
+// natively in GetUsers and GetGroups. The adapter applies the remaining parts
+// to the returned resources, so nothing is applied twice or skipped.
+type QueryCapabilities struct {
+	// Filtering means only resources matching params.Filter are returned
+	Filtering bool
+
+	// Sorting means resources are returned ordered by params.SortBy and
+	// params.SortOrder
+	Sorting bool
+
+	// Pagination means only the page selected by params.StartIndex and
+	// params.Count is returned. totalResults is read from CountUsers or
+	// CountGroups, so the plugin must also implement UserCounter or
+	// GroupCounter. Native pagination is only used when the filter and sort
+	// order of the request are applied natively as well; otherwise the
+	// plugin receives StartIndex 1 and Count 0 and must return every match.
+	Pagination bool
+}
+
+// CapabilitiesProvider is an optional interface for plugins declaring the
+// query features they implement natively. Plugins without it receive the
+// full params and may ignore them: the adapter applies the whole query to
+// the returned resources. Plugins paginating natively with a total count in
+// one query should implement plugin.UserLister instead.
+type CapabilitiesProvider interface {
+	Capabilities() QueryCapabilities
+}
+
+// UserCounter is an optional interface for plugins paginating users natively
+// (see QueryCapabilities.Pagination). CountUsers returns the number of users
+// matching params.Filter.
+type UserCounter interface {
+	CountUsers(ctx context.Context, params QueryParams) (int, error)
+}
+
+// GroupCounter is the Group counterpart of UserCounter
+type GroupCounter interface {
+	CountGroups(ctx context.Context, params QueryParams) (int, error)
+}
+
+// ListWithCapabilities lists resources with list and applies the parts of
+// params that caps does not declare as native. count returns totalResults
+// for native pagination; nil disables native pagination.
+func ListWithCapabilities[T any](ctx context.Context, caps QueryCapabilities, params QueryParams,
+	list func(ctx context.Context, params QueryParams) ([]T, error),
+	count func(ctx context.Context, params QueryParams) (int, error)) (*ListResponse[T], error) {
+	// Paging before an in-memory filter or sort would select the wrong page
+	nativePaging := caps.Pagination && count != nil &&
+		(caps.Filtering || params.Filter == "") && (caps.Sorting || params.SortBy == "")
+
+	pluginParams := params
+	if !nativePaging {
+		pluginParams = streamParams(params)
+	}
+
+	if params.Filter != "" {
+		if caps.Filtering {
+			scimcontext.TraceFilter(ctx, "pushed to plugin")
+		} else {
+			scimcontext.TraceFilter(ctx, "evaluated in memory")
+		}
+	}
+
+	resources, err := list(ctx, pluginParams)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Filtering {
+		if resources, err = applyQueryFilter(resources, params); err != nil {
+			return nil, err
+		}
+	}
+	if !caps.Sorting {
+		resources = SortResourcesCollated(resources, params.SortBy, params.SortOrder, params.Collation)
+	}
+
+	totalResults := len(resources)
+	startIndex := max(params.StartIndex, 1)
+	if nativePaging {
+		if totalResults, err = count(ctx, params); err != nil {
+			return nil, err
+		}
+		if params.Count > 0 && len(resources) > params.Count {
+			resources = resources[:params.Count]
+		}
+	} else {
+		resources, startIndex, _ = ApplyResourcePagination(resources, params.StartIndex, params.Count)
+	}
+
+	resources, err = ApplyAttributeSelection(resources, params.Attributes, params.ExcludedAttr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ListResponse[T]{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: totalResults,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// ListUsersWithCapabilities lists the users of plugin, applying the parts of
+// params it does not declare as native through CapabilitiesProvider. Plugins
+// without capabilities get the whole query applied as with ProcessListQuery.
+func ListUsersWithCapabilities(ctx context.Context, plugin SimplePlugin, params QueryParams) (*ListResponse[*User], error) {
+	provider, ok := plugin.(CapabilitiesProvider)
+	if !ok {
+		if params.Filter != "" {
+			scimcontext.TraceFilter(ctx, "evaluated in memory")
+		}
+		users, err := plugin.GetUsers(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return ProcessListQuery(users, params)
+	}
+
+	var count func(context.Context, QueryParams) (int, error)
+	if counter, ok := plugin.(UserCounter); ok {
+		count = counter.CountUsers
+	}
+	return ListWithCapabilities(ctx, provider.Capabilities(), params, plugin.GetUsers, count)
+}
+
+// ListGroupsWithCapabilities is the Group counterpart of
+// ListUsersWithCapabilities
+func ListGroupsWithCapabilities(ctx context.Context, plugin SimplePlugin, params QueryParams) (*ListResponse[*Group], error) {
+	provider, ok := plugin.(CapabilitiesProvider)
+	if !ok {
+		if params.Filter != "" {
+			scimcontext.TraceFilter(ctx, "evaluated in memory")
+		}
+		groups, err := plugin.GetGroups(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		return ProcessListQuery(groups, params)
+	}
+
+	var count func(context.Context, QueryParams) (int, error)
+	if counter, ok := plugin.(GroupCounter); ok {
+		count = counter.CountGroups
+	}
+	return ListWithCapabilities(ctx, provider.Capabilities(), params, plugin.GetGroups, count)
+}
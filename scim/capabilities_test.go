@@ -0,0 +1,127 @@
+package scim
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// capablePlugin is a slicePlugin declaring query capabilities. It returns
+// its users unchanged whatever it declares, so a part of the query applied
+// by ListUsersWithCapabilities shows in the result.
+type capablePlugin struct {
+	slicePlugin
+	caps   QueryCapabilities
+	params QueryParams // params of the last GetUsers call
+	total  int
+}
+
+func (p *capablePlugin) Capabilities() QueryCapabilities {
+	return p.caps
+}
+
+func (p *capablePlugin) GetUsers(ctx context.Context, params QueryParams) ([]*User, error) {
+	p.params = params
+	return p.slicePlugin.GetUsers(ctx, params)
+}
+
+func (p *capablePlugin) CountUsers(ctx context.Context, params QueryParams) (int, error) {
+	return p.total, nil
+}
+
+func newCapablePlugin(caps QueryCapabilities, userNames ...string) *capablePlugin {
+	p := &capablePlugin{slicePlugin: slicePlugin{newMockPlugin()}, caps: caps}
+	for _, name := range userNames {
+		p.CreateUser(context.Background(), &User{ID: name, UserName: name}) // nolint:errcheck
+	}
+	return p
+}
+
+func TestListUsersWithCapabilities(t *testing.T) {
+	ctx := context.Background()
+	params := QueryParams{Filter: `userName sw "a"`, SortBy: "userName", StartIndex: 2, Count: 1}
+
+	// A natively applied filter is trusted rather than applied again
+	p := newCapablePlugin(QueryCapabilities{Filtering: true}, "bob", "alice", "carol")
+	resp, err := ListUsersWithCapabilities(ctx, p, params)
+	if err != nil {
+		t.Fatalf("ListUsersWithCapabilities() error = %v", err)
+	}
+	if p.params.Filter != params.Filter || p.params.StartIndex != 1 || p.params.Count != 0 {
+		t.Errorf("plugin params = %+v, want the filter and every match", p.params)
+	}
+	if resp.TotalResults != 3 || len(resp.Resources) != 1 || resp.Resources[0].UserName != "bob" {
+		t.Errorf("response = %d total, %v, want 3 total, page [bob]", resp.TotalResults, resp.Resources)
+	}
+
+	// Without native filtering the plugin cannot page either
+	p = newCapablePlugin(QueryCapabilities{Sorting: true, Pagination: true}, "alice", "anna", "bob")
+	if resp, err = ListUsersWithCapabilities(ctx, p, params); err != nil {
+		t.Fatalf("ListUsersWithCapabilities() error = %v", err)
+	}
+	if p.params.StartIndex != 1 || p.params.Count != 0 {
+		t.Errorf("plugin params = %+v, want every match", p.params)
+	}
+	if resp.TotalResults != 2 || resp.StartIndex != 2 || len(resp.Resources) != 1 {
+		t.Errorf("response = %d total from %d, %v, want the second of 2 matches", resp.TotalResults, resp.StartIndex, resp.Resources)
+	}
+
+	// A plugin applying the whole query returns the page; the total is counted
+	p = newCapablePlugin(QueryCapabilities{Filtering: true, Sorting: true, Pagination: true}, "anna")
+	p.total = 7
+	if resp, err = ListUsersWithCapabilities(ctx, p, params); err != nil {
+		t.Fatalf("ListUsersWithCapabilities() error = %v", err)
+	}
+	if p.params.StartIndex != 2 || p.params.Count != 1 {
+		t.Errorf("plugin params = %+v, want the requested page", p.params)
+	}
+	if resp.TotalResults != 7 || resp.StartIndex != 2 || resp.ItemsPerPage != 1 || resp.Resources[0].UserName != "anna" {
+		t.Errorf("response = %+v, want anna as the second of 7", resp)
+	}
+}
+
+func TestListWithCapabilitiesWithoutCounter(t *testing.T) {
+	var got QueryParams
+	list := func(ctx context.Context, params QueryParams) ([]*User, error) {
+		got = params
+		return []*User{{UserName: "alice"}, {UserName: "bob"}}, nil
+	}
+
+	// Native pagination needs a count for totalResults
+	caps := QueryCapabilities{Filtering: true, Sorting: true, Pagination: true}
+	resp, err := ListWithCapabilities(context.Background(), caps, QueryParams{StartIndex: 1, Count: 1}, list, nil)
+	if err != nil {
+		t.Fatalf("ListWithCapabilities() error = %v", err)
+	}
+	if got.Count != 0 {
+		t.Errorf("plugin Count = %d, want 0", got.Count)
+	}
+	if resp.TotalResults != 2 || len(resp.Resources) != 1 {
+		t.Errorf("response = %d total, %d resources, want 2 total, 1 resource", resp.TotalResults, len(resp.Resources))
+	}
+}
+
+func TestListWithCapabilitiesFilterTrace(t *testing.T) {
+	params := QueryParams{Filter: `userName sw "a"`}
+	for _, tt := range []struct {
+		name   string
+		plugin SimplePlugin
+		want   string
+	}{
+		{"native filter", newCapablePlugin(QueryCapabilities{Filtering: true}), "pushed to plugin"},
+		{"in-memory filter", newCapablePlugin(QueryCapabilities{Sorting: true}), "evaluated in memory"},
+		{"no capabilities", &slicePlugin{newMockPlugin()}, "evaluated in memory"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := &scimcontext.FilterTrace{}
+			ctx := scimcontext.WithFilterTracer(context.Background(), trace)
+			if _, err := ListUsersWithCapabilities(ctx, tt.plugin, params); err != nil {
+				t.Fatalf("ListUsersWithCapabilities() error = %v", err)
+			}
+			if got := trace.String(); got != tt.want {
+				t.Errorf("trace = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package scim
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CollationCaseInsensitive sorts strings by their FoldCase form, and strings
+// folding the same in code point order. It is a QueryParams.Collation.
+const CollationCaseInsensitive = "caseInsensitive"
+
+// CollationProvider is an optional interface for plugins that sort strings
+// other than in code point order. The server sets QueryParams.Collation of
+// list and search requests from it, and sorts in memory accordingly. The
+// plugin adapter provides it from the plugin's sortCollation setting.
+type CollationProvider interface {
+	SortCollation() string
+}
+
+// FoldCase returns s with every letter mapped to its case-folded form, the
+// lower case of its upper case. Unlike strings.ToLower it folds the Greek
+// final sigma with sigma, and the Turkish dotless and dotted i with i, so
+// "ΟΔΟΣ" matches "οδος" and "IŞIK" matches "ışık". The filter evaluator
+// compares strings folded, and plugins filtering natively fold their
+// parameters with it to match the same resources.
+func FoldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		return unicode.ToLower(unicode.ToUpper(r))
+	}, s)
+}
+
+// applySortCollation sets the plugin's collation on params if they have none
+func applySortCollation(params *QueryParams, plugin PluginGetter) {
+	if params.Collation != "" {
+		return
+	}
+	if provider, ok := lookupCapability[CollationProvider](plugin); ok {
+		params.Collation = provider.SortCollation()
+	}
+}
+
+// compareStrings orders a and b by collation, -1, 0 or 1
+func compareStrings(a, b, collation string) int {
+	if collation == CollationCaseInsensitive {
+		if cmp := strings.Compare(FoldCase(a), FoldCase(b)); cmp != 0 {
+			return cmp
+		}
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,136 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFoldCase(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Łukasz", "łukasz", true},
+		{"ÉMILE", "émile", true},
+		{"ΟΔΟΣ", "οδος", true},
+		{"ΟΔΟΣ", "οδοσ", true},
+		{"οδος", "οδοσ", true},
+		{"IŞIK", "ışık", true},
+		{"İstanbul", "istanbul", true},
+		{"KELVIN", "Kelvin", true},
+		{"José", "Jose", false},
+		{"alice", "alicia", false},
+	}
+
+	for _, tt := range tests {
+		if got := FoldCase(tt.a) == FoldCase(tt.b); got != tt.want {
+			t.Errorf("FoldCase(%q) == FoldCase(%q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFilterUnicodeCaseFolding(t *testing.T) {
+	users := []*User{
+		{UserName: "łukasz@example.com"},
+		{UserName: "ışık@example.com"},
+		{UserName: "ΟΔΟΣ@example.com"},
+	}
+
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{`userName eq "ŁUKASZ@EXAMPLE.COM"`, "łukasz@example.com"},
+		{`userName sw "IŞ"`, "ışık@example.com"},
+		{`userName co "Işık"`, "ışık@example.com"},
+		{`userName sw "οδος"`, "ΟΔΟΣ@example.com"},
+		{`userName ew "Σ@EXAMPLE.COM"`, "ΟΔΟΣ@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			matched, err := FilterByFilter(users, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matched) != 1 || matched[0].UserName != tt.want {
+				t.Errorf("%s matched %v, want %s", tt.filter, matched, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortResourcesCollated(t *testing.T) {
+	users := []*User{
+		{UserName: "charlie"},
+		{UserName: "Bob"},
+		{UserName: "Émile"},
+		{UserName: "alice"},
+		{UserName: "bob"},
+	}
+	userNames := func(users []*User) string {
+		var names []string
+		for _, u := range users {
+			names = append(names, u.UserName)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := userNames(SortResourcesCollated(users, "userName", "ascending", "")); got != "Bob,alice,bob,charlie,Émile" {
+		t.Errorf("code point order = %s", got)
+	}
+	if got := userNames(SortResourcesCollated(users, "userName", "ascending", CollationCaseInsensitive)); got != "alice,Bob,bob,charlie,Émile" {
+		t.Errorf("case-insensitive order = %s", got)
+	}
+	if got := userNames(SortResourcesCollated(users, "userName", "descending", CollationCaseInsensitive)); got != "Émile,charlie,bob,Bob,alice" {
+		t.Errorf("case-insensitive descending order = %s", got)
+	}
+}
+
+// collatedPlugin sorts strings ignoring case, in memory
+type collatedPlugin struct {
+	*mockPlugin
+	collation string
+}
+
+func (p *collatedPlugin) SortCollation() string {
+	return CollationCaseInsensitive
+}
+
+func (p *collatedPlugin) GetUsers(ctx context.Context, params QueryParams) (*ListResponse[*User], error) {
+	p.collation = params.Collation
+	users := make([]*User, 0, len(p.users))
+	for _, user := range p.users {
+		users = append(users, user)
+	}
+	return ProcessListQuery(users, params)
+}
+
+func TestServer_SortCollation(t *testing.T) {
+	plugin := &collatedPlugin{mockPlugin: newMockPlugin()}
+	for _, name := range []string{"charlie", "Bob", "alice"} {
+		plugin.users[name] = &User{ID: name, UserName: name}
+	}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users?sortBy=userName", nil))
+	var resp ListResponse[User]
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, u := range resp.Resources {
+		names = append(names, u.UserName)
+	}
+	if got := strings.Join(names, ","); got != "alice,Bob,charlie" {
+		t.Errorf("sorted users = %s, want alice,Bob,charlie", got)
+	}
+	if plugin.collation != CollationCaseInsensitive {
+		t.Errorf("QueryParams.Collation = %q, want %s", plugin.collation, CollationCaseInsensitive)
+	}
+}
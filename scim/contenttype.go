@@ -0,0 +1,155 @@
+package scim
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types of SCIM messages (RFC 7644 Section 3.1)
+const (
+	MediaTypeSCIM = "application/scim+json"
+	MediaTypeJSON = "application/json"
+)
+
+// StrictContentTypeMiddleware negotiates the media types of requests and
+// responses as RFC 7644 Section 3.8 describes, for clients and conformance
+// suites checking them:
+//
+//   - request bodies must be application/scim+json or application/json,
+//     optionally with charset utf-8, or fail with 415 Unsupported Media Type
+//   - requests whose Accept header allows neither fail with 406 Not
+//     Acceptable
+//   - SCIM responses are application/scim+json; charset=utf-8, or
+//     application/json; charset=utf-8 if the client prefers it
+//
+// Without it, request media types are not checked and responses are
+// application/scim+json without a charset.
+func StrictContentTypeMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, ok := negotiateMediaType(r.Header.Values("Accept"))
+			cw := &contentTypeWriter{ResponseWriter: w, mediaType: mediaType}
+			if !ok {
+				cw.mediaType = MediaTypeSCIM
+				NewHandler("").WriteError(cw, http.StatusNotAcceptable,
+					"Accept must allow application/scim+json or application/json", "")
+				return
+			}
+			if hasBody(r) && !supportedContentType(r.Header.Get("Content-Type")) {
+				NewHandler("").WriteError(cw, http.StatusUnsupportedMediaType,
+					"Content-Type must be application/scim+json or application/json", "")
+				return
+			}
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// hasBody reports whether r has a body, which GET and DELETE requests
+// usually do not
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// supportedContentType reports whether a request body of contentType can be
+// read: SCIM or plain JSON, in UTF-8
+func supportedContentType(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != MediaTypeSCIM && mediaType != MediaTypeJSON) {
+		return false
+	}
+	charset, ok := params["charset"]
+	return !ok || strings.EqualFold(charset, "utf-8")
+}
+
+// negotiateMediaType returns the media type of responses to a request with
+// the Accept headers accept (RFC 9110 Section 12.5.1): application/json if
+// the client prefers it, application/scim+json otherwise, and false if it
+// accepts neither. Requests without an Accept header accept any type.
+func negotiateMediaType(accept []string) (string, bool) {
+	if len(accept) == 0 {
+		return MediaTypeSCIM, true
+	}
+	scimQuality, jsonQuality := acceptQuality(accept, MediaTypeSCIM), acceptQuality(accept, MediaTypeJSON)
+	switch {
+	case scimQuality <= 0 && jsonQuality <= 0:
+		return "", false
+	case jsonQuality > scimQuality:
+		return MediaTypeJSON, true
+	}
+	return MediaTypeSCIM, true
+}
+
+// acceptQuality returns the quality the Accept headers accept give
+// mediaType: that of the most specific media range matching it, or 0
+func acceptQuality(accept []string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	quality, specificity := 0.0, -1
+	for _, header := range accept {
+		for part := range strings.SplitSeq(header, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			mediaRange, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			var rank int
+			switch mediaRange {
+			case mediaType:
+				rank = 2
+			case typ + "/*":
+				rank = 1
+			case "*/*":
+				rank = 0
+			default:
+				continue
+			}
+			if rank <= specificity {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			quality, specificity = q, rank
+		}
+	}
+	return quality
+}
+
+// contentTypeWriter sets the negotiated media type on SCIM responses
+type contentTypeWriter struct {
+	http.ResponseWriter
+	mediaType   string
+	wroteHeader bool
+}
+
+// WriteHeader replaces the media type of SCIM responses with the negotiated
+// one, with charset utf-8
+func (cw *contentTypeWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		header := cw.Header()
+		if mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil && mediaType == MediaTypeSCIM {
+			header.Set("Content-Type", cw.mediaType+"; charset=utf-8")
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *contentTypeWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (cw *contentTypeWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,83 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		accept []string
+		want   string
+		ok     bool
+	}{
+		{nil, MediaTypeSCIM, true},
+		{[]string{"*/*"}, MediaTypeSCIM, true},
+		{[]string{"application/*"}, MediaTypeSCIM, true},
+		{[]string{"application/scim+json"}, MediaTypeSCIM, true},
+		{[]string{"application/json"}, MediaTypeJSON, true},
+		{[]string{"application/json, application/scim+json"}, MediaTypeSCIM, true},
+		{[]string{"application/scim+json;q=0.5, application/json"}, MediaTypeJSON, true},
+		{[]string{"text/html", "application/json;q=0.1"}, MediaTypeJSON, true},
+		{[]string{"*/*;q=0.1, application/scim+json;q=0"}, MediaTypeJSON, true},
+		{[]string{"text/html, application/xml"}, "", false},
+		{[]string{"application/*;q=0"}, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := negotiateMediaType(tt.accept)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("negotiateMediaType(%q) = %q, %v, want %q, %v", tt.accept, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestStrictContentTypeMiddleware(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.CreateUser(context.Background(), &User{ID: "user1", UserName: "alice"}) // nolint:errcheck
+	handler := StrictContentTypeMiddleware()(NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin}))
+
+	tests := []struct {
+		name, method, path, contentType, accept, body string
+		wantStatus                                    int
+		wantContentType                               string
+	}{
+		{"get", "GET", "/test/Users/user1", "", "", "", http.StatusOK, "application/scim+json; charset=utf-8"},
+		{"list", "GET", "/test/Users", "", "", "", http.StatusOK, "application/scim+json; charset=utf-8"},
+		{"json accepted", "GET", "/test/Users/user1", "", "application/json", "", http.StatusOK, "application/json; charset=utf-8"},
+		{"not found", "GET", "/test/Users/missing", "", "", "", http.StatusNotFound, "application/scim+json; charset=utf-8"},
+		{"not acceptable", "GET", "/test/Users/user1", "", "text/html", "", http.StatusNotAcceptable, "application/scim+json; charset=utf-8"},
+		{"scim body", "POST", "/test/Users", "application/scim+json; charset=UTF-8", "", `{"userName": "bob"}`, http.StatusCreated, "application/scim+json; charset=utf-8"},
+		{"json body", "POST", "/test/Users", "application/json", "", `{"userName": "carol"}`, http.StatusCreated, "application/scim+json; charset=utf-8"},
+		{"form body", "POST", "/test/Users", "application/x-www-form-urlencoded", "", "userName=dave", http.StatusUnsupportedMediaType, "application/scim+json; charset=utf-8"},
+		{"missing content type", "POST", "/test/Users", "", "", `{"userName": "dave"}`, http.StatusUnsupportedMediaType, "application/scim+json; charset=utf-8"},
+		{"latin-1 body", "POST", "/test/Users", "application/scim+json; charset=iso-8859-1", "", `{"userName": "dave"}`, http.StatusUnsupportedMediaType, "application/scim+json; charset=utf-8"},
+		{"delete", "DELETE", "/test/Users/user1", "", "", "", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body == "" {
+				req = httptest.NewRequest(tt.method, tt.path, nil)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// WithBaseEntity returns a context carrying the base entity (tenant, OU, ...)
+// a request is scoped to. Plugins that store several tenants in one backend
+// read it with BaseEntityFromContext and scope every query by it. It is
+// scimcontext.WithTenant.
+func WithBaseEntity(ctx context.Context, baseEntity string) context.Context {
+	return scimcontext.WithTenant(ctx, baseEntity)
+}
+
+// BaseEntityFromContext returns the base entity set with WithBaseEntity,
+// or "" when the request is not scoped to one. It is scimcontext.Tenant.
+func BaseEntityFromContext(ctx context.Context) string {
+	return scimcontext.Tenant(ctx)
+}
+
+// PrincipalFromContext returns the client a request was authenticated as:
+// the plugin and authentication method that accepted it, and the subject,
+// scopes and claims the authenticator reported (see
+// auth.PrincipalAuthenticator). Plugins use it to authorize operations per
+// caller and to attribute changes in audit logs. Requests to plugins without
+// authentication have none. It is scimcontext.Identity.
+func PrincipalFromContext(ctx context.Context) (scimcontext.AuthIdentity, bool) {
+	return scimcontext.Identity(ctx)
+}
+
+// preconditionKey is the context key for the precondition of a conditional write
+type preconditionKey struct{}
+
+// Precondition is the If-Match precondition of a write request, as checked by
+// the gateway before it calls ModifyUser, DeleteUser or their Group
+// counterparts. The resource can change between the check and the write;
+// backends that support conditional writes use the precondition to reject the
+// write atomically.
+type Precondition struct {
+	// IfMatch is the client's If-Match header value, e.g. `W/"a1b2"` or "*"
+	IfMatch string
+
+	// CurrentETag is the ETag the gateway computed for the resource when it
+	// checked IfMatch
+	CurrentETag string
+
+	// Version is the meta.version the plugin returned for that resource, or ""
+	Version string
+}
+
+// WithPrecondition returns a context carrying the precondition of a write.
+// The gateway sets it for requests with If-Match that passed the check.
+func WithPrecondition(ctx context.Context, precondition Precondition) context.Context {
+	return context.WithValue(ctx, preconditionKey{}, precondition)
+}
+
+// PreconditionFromContext returns the precondition set with WithPrecondition.
+// Writes without one are unconditional.
+func PreconditionFromContext(ctx context.Context) (Precondition, bool) {
+	precondition, ok := ctx.Value(preconditionKey{}).(Precondition)
+	return precondition, ok
+}
+
+// ExpectedVersionFromContext returns the meta.version a conditional write
+// expects, from PreconditionFromContext. Plugins that can write conditionally
+// compare it with the stored version in the same statement as the write, and
+// return ErrPreconditionFailed when the resource changed since the check.
+func ExpectedVersionFromContext(ctx context.Context) (string, bool) {
+	precondition, ok := PreconditionFromContext(ctx)
+	if !ok || precondition.Version == "" {
+		return "", false
+	}
+	return precondition.Version, true
+}
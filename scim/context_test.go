@@ -0,0 +1,116 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+func TestBaseEntityContext(t *testing.T) {
+	ctx := context.Background()
+	if got := BaseEntityFromContext(ctx); got != "" {
+		t.Errorf("BaseEntityFromContext() = %q, want empty", got)
+	}
+
+	ctx = WithBaseEntity(ctx, "acme")
+	if got := BaseEntityFromContext(ctx); got != "acme" {
+		t.Errorf("BaseEntityFromContext() = %q, want acme", got)
+	}
+
+	ctx = WithBaseEntity(ctx, "globex")
+	if got := BaseEntityFromContext(ctx); got != "globex" {
+		t.Errorf("BaseEntityFromContext() = %q, want globex", got)
+	}
+}
+
+// requestIDPlugin records the request ID and trace headers its GetUser is
+// called with
+type requestIDPlugin struct {
+	*mockPlugin
+	requestID    string
+	traceHeaders http.Header
+}
+
+func (p *requestIDPlugin) GetUser(ctx context.Context, id string, attributes []string) (*User, error) {
+	p.requestID = scimcontext.RequestID(ctx)
+	p.traceHeaders = scimcontext.TraceHeaders(ctx)
+	return p.mockPlugin.GetUser(ctx, id, attributes)
+}
+
+func TestServerPassesRequestID(t *testing.T) {
+	p := &requestIDPlugin{mockPlugin: newMockPlugin()}
+	srv := NewServer("http://localhost", &mockPluginManager{plugin: p})
+
+	req := httptest.NewRequest(http.MethodGet, "/test/Users/1", nil)
+	req.Header.Set("X-Request-Id", "req-789")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	if p.requestID != "req-789" {
+		t.Errorf("plugin saw request ID %q, want req-789", p.requestID)
+	}
+}
+
+func TestServerReturnsRequestID(t *testing.T) {
+	srv := NewServer("http://localhost", &mockPluginManager{plugin: newMockPlugin()})
+
+	req := httptest.NewRequest(http.MethodGet, "/test/Users/missing", nil)
+	req.Header.Set("X-Request-Id", "req-790")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var scimErr Error
+	if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("X-Request-Id") != "req-790" || scimErr.RequestID != "req-790" {
+		t.Errorf("X-Request-Id = %q, error requestId = %q, want req-790", w.Header().Get("X-Request-Id"), scimErr.RequestID)
+	}
+
+	// Errors of requests without an ID have none
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users/missing", nil))
+	if strings.Contains(w.Body.String(), "requestId") {
+		t.Errorf("error without a request ID = %s", w.Body.String())
+	}
+}
+
+func TestServerPassesTraceHeaders(t *testing.T) {
+	p := &requestIDPlugin{mockPlugin: newMockPlugin()}
+	srv := NewServer("http://localhost", &mockPluginManager{plugin: p})
+
+	get := func() {
+		req := httptest.NewRequest(http.MethodGet, "/test/Users/1", nil)
+		req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set("X-B3-TraceId", "80f198ee56343ba864fe8b2a57d3eff7")
+		req.Header.Set("Authorization", "Bearer secret")
+		srv.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get()
+	if got := p.traceHeaders.Get("Traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("plugin saw traceparent %q", got)
+	}
+	if len(p.traceHeaders) != 1 {
+		t.Errorf("plugin saw trace headers %v, want traceparent only", p.traceHeaders)
+	}
+
+	srv.SetPropagatedHeaders([]string{"x-b3-traceid"})
+	get()
+	if got := p.traceHeaders.Get("X-B3-TraceId"); got != "80f198ee56343ba864fe8b2a57d3eff7" {
+		t.Errorf("plugin saw X-B3-TraceId %q", got)
+	}
+	if p.traceHeaders.Get("Authorization") != "" {
+		t.Error("plugin saw a header that is not propagated")
+	}
+
+	// Requests without trace headers carry none
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/Users/1", nil))
+	if p.traceHeaders != nil {
+		t.Errorf("plugin saw trace headers %v", p.traceHeaders)
+	}
+}
@@ -0,0 +1,209 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DecodeOption configures the Decode functions
+type DecodeOption func(*decodeOptions)
+
+type decodeOptions struct {
+	strict bool
+}
+
+// Strict makes the Decode functions reject responses that a conforming SCIM
+// service does not send: a missing or wrong message schema URN, resources
+// whose schemas lack their core schema or omit an extension they carry, and
+// members unknown to the decoded type. Nested attributes are not checked.
+func Strict() DecodeOption {
+	return func(o *decodeOptions) {
+		o.strict = true
+	}
+}
+
+// DecodeListResponse decodes a SCIM list response, such as the body of
+// GET /Users, with resources of type T, for clients and tests of the gateway:
+//
+//	list, err := scim.DecodeListResponse[*scim.User](resp.Body, scim.Strict())
+func DecodeListResponse[T any](r io.Reader, opts ...DecodeOption) (*ListResponse[T], error) {
+	o := newDecodeOptions(opts)
+
+	var envelope struct {
+		Schemas      []string          `json:"schemas"`
+		TotalResults int               `json:"totalResults"`
+		StartIndex   int               `json:"startIndex"`
+		ItemsPerPage int               `json:"itemsPerPage"`
+		Resources    []json.RawMessage `json:"Resources"`
+	}
+	if err := o.decode(r, &envelope); err != nil {
+		return nil, fmt.Errorf("decoding list response: %w", err)
+	}
+	if o.strict && !slices.Contains(envelope.Schemas, SchemaListResponse) {
+		return nil, fmt.Errorf("decoding list response: schemas %v do not include %s", envelope.Schemas, SchemaListResponse)
+	}
+
+	list := &ListResponse[T]{
+		Schemas:      envelope.Schemas,
+		TotalResults: envelope.TotalResults,
+		StartIndex:   envelope.StartIndex,
+		ItemsPerPage: envelope.ItemsPerPage,
+		Resources:    make([]T, 0, len(envelope.Resources)),
+	}
+	for i, data := range envelope.Resources {
+		resource, err := decodeResource[T](data, o)
+		if err != nil {
+			return nil, fmt.Errorf("decoding list response: Resources[%d]: %w", i, err)
+		}
+		list.Resources = append(list.Resources, resource)
+	}
+	return list, nil
+}
+
+// DecodeResource decodes a single SCIM resource of type T, such as the body
+// of GET /Users/{id}
+func DecodeResource[T any](r io.Reader, opts ...DecodeOption) (T, error) {
+	o := newDecodeOptions(opts)
+
+	var data json.RawMessage
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		var zero T
+		return zero, fmt.Errorf("decoding resource: %w", err)
+	}
+	resource, err := decodeResource[T](data, o)
+	if err != nil {
+		return resource, fmt.Errorf("decoding resource: %w", err)
+	}
+	return resource, nil
+}
+
+// DecodeError decodes a SCIM error response into a *SCIMError with the
+// status, detail and scimType it reports
+func DecodeError(r io.Reader, opts ...DecodeOption) (*SCIMError, error) {
+	o := newDecodeOptions(opts)
+
+	var body Error
+	if err := o.decode(r, &body); err != nil {
+		return nil, fmt.Errorf("decoding error response: %w", err)
+	}
+	if o.strict && !slices.Contains(body.Schemas, SchemaError) {
+		return nil, fmt.Errorf("decoding error response: schemas %v do not include %s", body.Schemas, SchemaError)
+	}
+	status, err := strconv.Atoi(body.Status)
+	if err != nil {
+		return nil, fmt.Errorf("decoding error response: invalid status %q", body.Status)
+	}
+	return NewSCIMError(status, body.Detail, body.ScimType), nil
+}
+
+func newDecodeOptions(opts []DecodeOption) *decodeOptions {
+	o := &decodeOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// decode decodes the JSON value read from r into v, rejecting unknown
+// members in strict mode
+func (o *decodeOptions) decode(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	if o.strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+// decodeResource decodes data into a T, checking its members and schemas
+// in strict mode
+func decodeResource[T any](data json.RawMessage, o *decodeOptions) (T, error) {
+	var resource T
+	if o.strict {
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(data, &members); err != nil {
+			return resource, err
+		}
+		if err := checkResourceMembers(reflect.TypeFor[T](), members); err != nil {
+			return resource, err
+		}
+	}
+
+	if err := o.decode(bytes.NewReader(data), &resource); err != nil {
+		return resource, err
+	}
+	return resource, nil
+}
+
+// checkResourceMembers reports members of a resource that t has no field for
+// and schemas that do not match its members. Extension members, named by a
+// schema URN, must be listed in schemas; User and Group must list their core
+// schema.
+func checkResourceMembers(t reflect.Type, members map[string]json.RawMessage) error {
+	var schemas []string
+	if raw, ok := members["schemas"]; ok {
+		if err := json.Unmarshal(raw, &schemas); err != nil {
+			return fmt.Errorf("invalid schemas: %w", err)
+		}
+	}
+	if len(schemas) == 0 {
+		return fmt.Errorf("missing schemas")
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case reflect.TypeFor[User]():
+		if !slices.Contains(schemas, SchemaUser) {
+			return fmt.Errorf("schemas %v do not include %s", schemas, SchemaUser)
+		}
+	case reflect.TypeFor[Group]():
+		if !slices.Contains(schemas, SchemaGroup) {
+			return fmt.Errorf("schemas %v do not include %s", schemas, SchemaGroup)
+		}
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	known := jsonFieldNames(t)
+	for name := range members {
+		if strings.HasPrefix(strings.ToLower(name), "urn:") {
+			if !slices.Contains(schemas, name) {
+				return fmt.Errorf("extension %s not listed in schemas", name)
+			}
+			continue
+		}
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("unknown member %q", name)
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames returns the lowercase JSON member names of the fields of
+// struct type t, as SCIM attribute names are case-insensitive
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
@@ -0,0 +1,100 @@
+package scim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeListResponse(t *testing.T) {
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+		"totalResults": 3, "startIndex": 2, "itemsPerPage": 1,
+		"Resources": [{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User", "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"],
+			"id": "u1", "userName": "alice", "active": true,
+			"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Sales"}
+		}]
+	}`
+
+	list, err := DecodeListResponse[*User](strings.NewReader(body), Strict())
+	if err != nil {
+		t.Fatalf("DecodeListResponse() error = %v", err)
+	}
+	if list.TotalResults != 3 || list.StartIndex != 2 || list.ItemsPerPage != 1 {
+		t.Errorf("DecodeListResponse() = %+v, want totalResults 3, startIndex 2, itemsPerPage 1", list)
+	}
+	if len(list.Resources) != 1 {
+		t.Fatalf("DecodeListResponse() Resources = %d, want 1", len(list.Resources))
+	}
+	user := list.Resources[0]
+	if user.UserName != "alice" || user.Active == nil || !bool(*user.Active) || user.EnterpriseUser["department"] != "Sales" {
+		t.Errorf("DecodeListResponse() user = %+v", user)
+	}
+}
+
+func TestDecodeListResponse_Strict(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "missing message schema",
+			body: `{"schemas": [], "totalResults": 0, "Resources": []}`,
+		},
+		{
+			name: "unknown envelope member",
+			body: `{"schemas": ["` + SchemaListResponse + `"], "totalResults": 0, "cursor": "x", "Resources": []}`,
+		},
+		{
+			name: "resource without core schema",
+			body: `{"schemas": ["` + SchemaListResponse + `"], "totalResults": 1, "Resources": [{"schemas": ["` + SchemaGroup + `"], "id": "u1"}]}`,
+		},
+		{
+			name: "unknown resource member",
+			body: `{"schemas": ["` + SchemaListResponse + `"], "totalResults": 1, "Resources": [{"schemas": ["` + SchemaUser + `"], "id": "u1", "nickname2": "x"}]}`,
+		},
+		{
+			name: "extension not listed in schemas",
+			body: `{"schemas": ["` + SchemaListResponse + `"], "totalResults": 1, "Resources": [{"schemas": ["` + SchemaUser + `"], "id": "u1", "` + SchemaEnterpriseUser + `": {}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeListResponse[*User](strings.NewReader(tt.body), Strict()); err == nil {
+				t.Error("DecodeListResponse(Strict()) error = nil, want error")
+			}
+			if _, err := DecodeListResponse[*User](strings.NewReader(tt.body)); err != nil {
+				t.Errorf("DecodeListResponse() error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestDecodeResource_CaseInsensitiveMembers(t *testing.T) {
+	body := `{"schemas": ["` + SchemaGroup + `"], "id": "g1", "DisplayName": "Admins"}`
+
+	group, err := DecodeResource[*Group](strings.NewReader(body), Strict())
+	if err != nil {
+		t.Fatalf("DecodeResource() error = %v", err)
+	}
+	if group.DisplayName != "Admins" {
+		t.Errorf("DecodeResource() displayName = %q, want Admins", group.DisplayName)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	body := `{"schemas": ["` + SchemaError + `"], "status": "400", "scimType": "invalidFilter", "detail": "bad filter"}`
+
+	scimErr, err := DecodeError(strings.NewReader(body), Strict())
+	if err != nil {
+		t.Fatalf("DecodeError() error = %v", err)
+	}
+	if scimErr.Status != 400 || scimErr.ScimType != ScimTypeInvalidFilter || scimErr.Detail != "bad filter" {
+		t.Errorf("DecodeError() = %+v", scimErr)
+	}
+
+	if _, err := DecodeError(strings.NewReader(`{"schemas": ["` + SchemaError + `"], "status": "bad"}`)); err == nil {
+		t.Error("DecodeError() with invalid status error = nil, want error")
+	}
+}
@@ -0,0 +1,55 @@
+package scim
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// DefaultExcludedAttributesProvider is an optional interface for plugins that
+// leave attributes out of responses unless a client asks for them, such as the
+// groups of users or the members of groups, which can make responses large.
+//
+// The attributes are excluded from GET and search responses when the request
+// has neither attributes nor excludedAttributes, and are passed to the plugin in
+// QueryParams.ExcludedAttr like client exclusions. The server discovers the
+// interface through wrappers such as the plugin adapter, which also provides it
+// from the plugin's defaultExcludedAttributes setting.
+type DefaultExcludedAttributesProvider interface {
+	DefaultExcludedAttributes() []string
+}
+
+// parseQueryParams parses the query parameters of r and applies the plugin's
+// default excluded attributes, collation and accent folding. Filters and
+// sort orders on the password are rejected.
+func (s *Server) parseQueryParams(r *http.Request, plugin PluginGetter) (QueryParams, error) {
+	params, err := s.handler.ParseQueryParams(r)
+	if err != nil {
+		return params, err
+	}
+	if err := checkPasswordQuery(params); err != nil {
+		return params, err
+	}
+	applyDefaultExcludedAttributes(&params, plugin)
+	applySortCollation(&params, plugin)
+	applyAccentInsensitive(&params, plugin)
+	return params, nil
+}
+
+// applyDefaultExcludedAttributes excludes the plugin's default excluded
+// attributes if params select no attributes
+func applyDefaultExcludedAttributes(params *QueryParams, plugin PluginGetter) {
+	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
+		return
+	}
+	if provider, ok := lookupCapability[DefaultExcludedAttributesProvider](plugin); ok {
+		params.ExcludedAttr = slices.Clone(provider.DefaultExcludedAttributes())
+	}
+}
+
+// withoutAttribute removes attr, case-insensitively, from attributes
+func withoutAttribute(attributes []string, attr string) []string {
+	return slices.DeleteFunc(attributes, func(a string) bool {
+		return strings.EqualFold(a, attr)
+	})
+}
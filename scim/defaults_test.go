@@ -0,0 +1,96 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// defaultsPlugin excludes groups and members by default
+type defaultsPlugin struct {
+	*mockPlugin
+}
+
+func (p *defaultsPlugin) DefaultExcludedAttributes() []string {
+	return []string{"groups", "members"}
+}
+
+func TestServer_DefaultExcludedAttributes(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.users["u1"] = &User{
+		ID:       "u1",
+		UserName: "alice",
+		Emails:   []Email{{Value: "alice@example.com"}},
+		Groups:   []GroupRef{{Value: "g1"}},
+	}
+	plugin.groups["g1"] = &Group{ID: "g1", DisplayName: "Eng", Members: []MemberRef{{Value: "u1"}}}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: &defaultsPlugin{plugin}})
+
+	get := func(path string) map[string]any {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d: %s", path, w.Code, w.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if resources, ok := body["Resources"].([]any); ok {
+			return resources[0].(map[string]any)
+		}
+		return body
+	}
+
+	tests := []struct {
+		path    string
+		attr    string
+		present bool
+	}{
+		{"/test/Users/u1", "groups", false},
+		{"/test/Users/u1", "emails", true},
+		{"/test/Users", "groups", false},
+		{"/test/Groups/g1", "members", false},
+		{"/test/Groups", "members", false},
+		// Selecting attributes replaces the defaults
+		{"/test/Users/u1?attributes=userName,groups", "groups", true},
+		{"/test/Users/u1?excludedAttributes=emails", "groups", true},
+		{"/test/Users/u1?excludedAttributes=emails", "emails", false},
+		// Paging members requests them
+		{"/test/Groups/g1?members.count=10", "members", true},
+		{"/test/Groups/g1?members.count=10&excludedAttributes=members", "members", false},
+	}
+
+	for _, tt := range tests {
+		resource := get(tt.path)
+		if _, ok := resource[tt.attr]; ok != tt.present {
+			t.Errorf("GET %s: %s present = %v, want %v", tt.path, tt.attr, ok, tt.present)
+		}
+	}
+}
+
+func TestServer_SearchDefaultExcludedAttributes(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.users["u1"] = &User{ID: "u1", UserName: "alice", Groups: []GroupRef{{Value: "g1"}}}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: &defaultsPlugin{plugin}})
+
+	body := `{"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"], "filter": "userName eq \"alice\""}`
+	req := httptest.NewRequest(http.MethodPost, "/test/Users/.search", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp struct {
+		Resources []map[string]any `json:"Resources"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Resources) != 1 {
+		t.Fatalf("search response = %s, %v", w.Body.String(), err)
+	}
+	if _, ok := resp.Resources[0]["groups"]; ok {
+		t.Errorf("search returned excluded groups: %v", resp.Resources[0])
+	}
+}
@@ -111,8 +111,8 @@ func GetServiceProviderConfig(authSchemes []AuthenticationScheme) *ServiceProvid
 		},
 		Bulk: BulkFeature{
 			Supported:      true,
-			MaxOperations:  1000,
-			MaxPayloadSize: 1048576, // 1MB
+			MaxOperations:  DefaultBulkMaxOperations,
+			MaxPayloadSize: DefaultBulkMaxPayloadSize,
 		},
 		Filter: FilterFeature{
 			Supported:  true,
@@ -138,6 +138,16 @@ func GetUserSchema() *SchemaDefinition {
 		Name:        "User",
 		Description: "User Account",
 		Attributes: []AttributeDefinition{
+			{
+				Name:        "externalId",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				CaseExact:   true,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				Uniqueness:  "server",
+			},
 			{
 				Name:        "userName",
 				Type:        "string",
@@ -172,6 +182,63 @@ func GetUserSchema() *SchemaDefinition {
 				Mutability:  "readWrite",
 				Returned:    "default",
 			},
+			{
+				Name:        "nickName",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
+			{
+				Name:           "profileUrl",
+				Type:           "reference",
+				MultiValued:    false,
+				Required:       false,
+				Mutability:     "readWrite",
+				Returned:       "default",
+				ReferenceTypes: []string{"external"},
+			},
+			{
+				Name:        "title",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
+			{
+				Name:        "userType",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
+			{
+				Name:        "preferredLanguage",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
+			{
+				Name:        "locale",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
+			{
+				Name:        "timezone",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+			},
 			{
 				Name:        "emails",
 				Type:        "complex",
@@ -180,12 +247,128 @@ func GetUserSchema() *SchemaDefinition {
 				Mutability:  "readWrite",
 				Returned:    "default",
 				SubAttributes: []AttributeDefinition{
-					{Name: "value", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"work", "home", "other"}},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "phoneNumbers",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"work", "home", "mobile", "fax", "pager", "other"}},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "ims",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"aim", "gtalk", "icq", "xmpp", "msn", "skype", "qq", "yahoo"}},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "photos",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "reference", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default", ReferenceTypes: []string{"external"}},
 					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"photo", "thumbnail"}},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "addresses",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "formatted", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "streetAddress", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "locality", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "region", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "postalCode", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "country", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
 					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"work", "home", "other"}},
 					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
 				},
 			},
+			{
+				Name:        "groups",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readOnly",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readOnly", Returned: "default"},
+					{Name: "$ref", Type: "reference", MultiValued: false, Mutability: "readOnly", Returned: "default", ReferenceTypes: []string{"User", "Group"}},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readOnly", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readOnly", Returned: "default", CanonicalValues: []string{"direct", "indirect"}},
+				},
+			},
+			{
+				Name:        "entitlements",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "roles",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
+			{
+				Name:        "x509Certificates",
+				Type:        "complex",
+				MultiValued: true,
+				Required:    false,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				SubAttributes: []AttributeDefinition{
+					{Name: "value", Type: "binary", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "primary", Type: "boolean", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+				},
+			},
 			{
 				Name:        "active",
 				Type:        "boolean",
@@ -194,6 +377,16 @@ func GetUserSchema() *SchemaDefinition {
 				Mutability:  "readWrite",
 				Returned:    "default",
 			},
+			{
+				Name:        "password",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				CaseExact:   false,
+				Mutability:  "writeOnly",
+				Returned:    "never",
+				Uniqueness:  "none",
+			},
 		},
 	}
 }
@@ -205,6 +398,16 @@ func GetGroupSchema() *SchemaDefinition {
 		Name:        "Group",
 		Description: "Group",
 		Attributes: []AttributeDefinition{
+			{
+				Name:        "externalId",
+				Type:        "string",
+				MultiValued: false,
+				Required:    false,
+				CaseExact:   true,
+				Mutability:  "readWrite",
+				Returned:    "default",
+				Uniqueness:  "server",
+			},
 			{
 				Name:        "displayName",
 				Type:        "string",
@@ -223,9 +426,10 @@ func GetGroupSchema() *SchemaDefinition {
 				Mutability:  "readWrite",
 				Returned:    "default",
 				SubAttributes: []AttributeDefinition{
-					{Name: "value", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
+					{Name: "value", Type: "string", MultiValued: false, Required: true, Mutability: "readWrite", Returned: "default"},
 					{Name: "$ref", Type: "reference", MultiValued: false, Mutability: "readWrite", Returned: "default", ReferenceTypes: []string{"User", "Group"}},
 					{Name: "type", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default", CanonicalValues: []string{"User", "Group"}},
+					{Name: "display", Type: "string", MultiValued: false, Mutability: "readWrite", Returned: "default"},
 				},
 			},
 		},
@@ -244,7 +448,7 @@ func GetResourceTypes() []ResourceTypeDefinition {
 			Schema:      SchemaUser,
 			SchemaExtensions: []SchemaExtensionRef{
 				{
-					Schema:   "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+					Schema:   SchemaEnterpriseUser,
 					Required: false,
 				},
 			},
@@ -0,0 +1,73 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// GroupDisplayNameLookup is an optional interface for plugins that index the
+// displayName of their groups. The plugin adapter answers
+// GET /Groups?filter=displayName eq "...", the most common group query of
+// identity providers such as Okta, with a single lookup instead of a list
+// request.
+//
+// displayName is not case-exact (RFC 7643 Section 4.2), so lookups must
+// ignore case. They are scoped like other requests, e.g. by
+// BaseEntityFromContext, and return nil without error if no group has the
+// name. Plugins that cannot answer with a single group, e.g. because
+// several groups have the name in different case, return an error wrapping
+// errors.ErrUnsupported, and the groups are listed as usual.
+type GroupDisplayNameLookup interface {
+	GetGroupByDisplayName(ctx context.Context, displayName string) (*Group, error)
+}
+
+// DisplayNameFilter returns the displayName a filter selects if it is
+// exactly displayName eq "<value>", optionally with the core schema URN,
+// which can be answered with a GroupDisplayNameLookup
+func DisplayNameFilter(filterStr string) (string, bool) {
+	return eqFilterValue(filterStr, "displayName")
+}
+
+// LookupGroupsByDisplayName answers params, whose filter selects
+// displayName, with lookup, applying pagination and attribute selection to
+// the group found. Errors wrapping errors.ErrUnsupported are returned as
+// is, for the caller to list the groups instead.
+func LookupGroupsByDisplayName(ctx context.Context, lookup GroupDisplayNameLookup, displayName string, params QueryParams) (*ListResponse[*Group], error) {
+	group, err := lookup.GetGroupByDisplayName(ctx, displayName)
+	if err != nil {
+		return nil, err
+	}
+	scimcontext.TraceFilter(ctx, "answered by displayName lookup")
+	var groups []*Group
+	if group != nil {
+		groups = append(groups, group)
+	}
+	return ProcessListQuery(groups, params)
+}
+
+// answeredByLookup reports whether the plugin answers a list of
+// resourceType with filterStr by a lookup, which the server prefers to
+// streaming
+func answeredByLookup(plugin PluginGetter, resourceType, filterStr string) bool {
+	if _, ok := lookupCapability[ExternalIDLookup](plugin); ok {
+		if _, ok := ExternalIDFilter(filterStr); ok {
+			return true
+		}
+	}
+	switch resourceType {
+	case ResourceTypeUser:
+		if _, ok := lookupCapability[UserNameLookup](plugin); ok {
+			if _, ok := UserNameFilter(filterStr); ok {
+				return true
+			}
+		}
+	case ResourceTypeGroup:
+		if _, ok := lookupCapability[GroupDisplayNameLookup](plugin); ok {
+			if _, ok := DisplayNameFilter(filterStr); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// displayNamePlugin is a streamingPlugin looking up users by externalId and
+// userName, and groups by displayName
+type displayNamePlugin struct {
+	*streamingPlugin
+}
+
+func (p *displayNamePlugin) LookupUserByExternalID(ctx context.Context, externalID string) (*User, error) {
+	return nil, nil
+}
+
+func (p *displayNamePlugin) LookupGroupByExternalID(ctx context.Context, externalID string) (*Group, error) {
+	return nil, nil
+}
+
+func (p *displayNamePlugin) GetUserByUserName(ctx context.Context, userName string) (*User, error) {
+	return nil, nil
+}
+
+func (p *displayNamePlugin) GetGroupByDisplayName(ctx context.Context, displayName string) (*Group, error) {
+	return nil, nil
+}
+
+func TestDisplayNameFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+		ok     bool
+	}{
+		{`displayName eq "Admins"`, "Admins", true},
+		{`(DisplayName eq "Admins")`, "Admins", true},
+		{SchemaGroup + `:displayName eq "Admins"`, "Admins", true},
+		{`displayName co "Admins"`, "", false},
+		{`displayName eq "Admins" or displayName eq "Users"`, "", false},
+		{`members.display eq "Admins"`, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := DisplayNameFilter(tt.filter)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("DisplayNameFilter(%q) = %q, %v, want %q, %v", tt.filter, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAnsweredByLookup(t *testing.T) {
+	plugin := &displayNamePlugin{newStreamingPlugin(0)}
+
+	tests := []struct {
+		resourceType string
+		filter       string
+		want         bool
+	}{
+		{ResourceTypeUser, `externalId eq "ext-1"`, true},
+		{ResourceTypeGroup, `externalId eq "ext-1"`, true},
+		{ResourceTypeGroup, `displayName eq "Admins"`, true},
+		{ResourceTypeUser, `userName eq "alice"`, true},
+		{ResourceTypeGroup, `userName eq "alice"`, false},
+		{ResourceTypeUser, `displayName eq "Alice"`, false},
+		{ResourceTypeGroup, `displayName sw "Ad"`, false},
+		{ResourceTypeGroup, "", false},
+	}
+	for _, tt := range tests {
+		if got := answeredByLookup(plugin, tt.resourceType, tt.filter); got != tt.want {
+			t.Errorf("answeredByLookup(%s, %q) = %v, want %v", tt.resourceType, tt.filter, got, tt.want)
+		}
+	}
+	if answeredByLookup(newMockPlugin(), ResourceTypeGroup, `displayName eq "Admins"`) {
+		t.Error("answeredByLookup() = true for a plugin without lookups")
+	}
+}
+
+func TestListAnsweredByLookupIsNotStreamed(t *testing.T) {
+	plugin := &displayNamePlugin{newStreamingPlugin(3)}
+
+	w := getList(t, plugin, `/test/Users?filter=externalId%20eq%20%22ext-1%22`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if plugin.streamCalls != 0 || plugin.listCalls != 1 {
+		t.Errorf("stream calls = %d, list calls = %d, want the list the lookup answers", plugin.streamCalls, plugin.listCalls)
+	}
+}
@@ -0,0 +1,170 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// Encoder serializes response bodies. The server writes every response,
+// resources, lists and errors alike, through the encoder of the request, so
+// alternative representations such as compact JSON or a binary format for
+// internal service-to-service calls need no changes to the handlers.
+// Request bodies are always read as JSON.
+type Encoder interface {
+	// ContentType returns the media type of the encoded bodies
+	ContentType() string
+
+	// Encode returns the body representing v, a value encoding/json can
+	// marshal
+	Encode(v any) ([]byte, error)
+}
+
+// JSONEncoder writes responses as application/scim+json (default behavior)
+type JSONEncoder struct{}
+
+// ContentType implements Encoder
+func (JSONEncoder) ContentType() string {
+	return "application/scim+json"
+}
+
+// Encode implements Encoder. The body ends with a newline.
+func (JSONEncoder) Encode(v any) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// CompactJSONEncoder writes responses as application/scim+json without the
+// null members of objects, which RFC 7643 Section 2.5 treats as unassigned
+type CompactJSONEncoder struct{}
+
+// ContentType implements Encoder
+func (CompactJSONEncoder) ContentType() string {
+	return "application/scim+json"
+}
+
+// Encode implements Encoder. The body ends with a newline.
+func (CompactJSONEncoder) Encode(v any) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, err
+	}
+	return JSONEncoder{}.Encode(withoutNulls(value))
+}
+
+// CanonicalJSONEncoder writes responses as application/scim+json whose
+// object members are sorted by name, including those of extensions, so a
+// resource is written byte for byte the same by every replica and release,
+// e.g. for tools comparing responses as text. Numbers are written as the
+// plugin returned them. Lists of streaming plugins are streamed canonically
+// too.
+type CanonicalJSONEncoder struct{}
+
+// ContentType implements Encoder
+func (CanonicalJSONEncoder) ContentType() string {
+	return "application/scim+json"
+}
+
+// Encode implements Encoder. The body ends with a newline.
+func (CanonicalJSONEncoder) Encode(v any) ([]byte, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	body, err = canonicalJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+// canonicalJSON returns the JSON body with the members of its objects
+// sorted by name
+func canonicalJSON(body []byte) ([]byte, error) {
+	// encoding/json writes the members of maps sorted by name, while those
+	// of structs follow the order of their fields
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// withoutNulls removes the null members of the objects in value
+func withoutNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, member := range v {
+			if member == nil {
+				delete(v, name)
+				continue
+			}
+			v[name] = withoutNulls(member)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = withoutNulls(item)
+		}
+	}
+	return value
+}
+
+// EncoderMiddleware serves the requests of next with responses encoded by
+// enc, e.g. on a separate listener for internal clients:
+//
+//	internal := scim.EncoderMiddleware(msgpackEncoder{})(handler)
+//	go http.ListenAndServe("127.0.0.1:9090", internal)
+func EncoderMiddleware(enc Encoder) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&encoderWriter{ResponseWriter: w, encoder: enc}, r)
+		})
+	}
+}
+
+// encoderWriter carries the encoder of a response to the handlers writing it
+type encoderWriter struct {
+	http.ResponseWriter
+	encoder Encoder
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (ew *encoderWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// ResponseEncoder returns the encoder of the response w writes: the one
+// EncoderMiddleware set on w or a writer it wraps, or JSONEncoder.
+// Middlewares writing their own responses encode them with it.
+func ResponseEncoder(w http.ResponseWriter) Encoder {
+	for w != nil {
+		if ew, ok := w.(*encoderWriter); ok && ew.encoder != nil {
+			return ew.encoder
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = unwrapper.Unwrap()
+	}
+	return JSONEncoder{}
+}
+
+// streamsJSON reports whether list responses to w can be streamed, which
+// writes them as JSON or canonical JSON
+func streamsJSON(w http.ResponseWriter) bool {
+	switch ResponseEncoder(w).(type) {
+	case JSONEncoder, CanonicalJSONEncoder:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// indentEncoder is an alternative encoder writing indented JSON under its
+// own media type
+type indentEncoder struct{}
+
+func (indentEncoder) ContentType() string { return "application/x-indented+json" }
+
+func (indentEncoder) Encode(v any) ([]byte, error) { return json.MarshalIndent(v, "", "  ") }
+
+func serveEncoded(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	EncoderMiddleware(indentEncoder{})(handler).ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if got := w.Header().Get("Content-Type"); got != "application/x-indented+json" {
+		t.Errorf("Content-Type = %q, want application/x-indented+json", got)
+	}
+	if !strings.Contains(w.Body.String(), "\n  ") {
+		t.Errorf("body = %s, want indented", w.Body.String())
+	}
+	return w
+}
+
+func TestEncoderMiddleware(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.CreateUser(context.Background(), &User{ID: "user1", UserName: "alice"}) // nolint:errcheck
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	w := serveEncoded(t, srv, "/test/Users/user1")
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil || user.UserName != "alice" {
+		t.Errorf("user = %+v, %v, want alice", user, err)
+	}
+
+	w = serveEncoded(t, srv, "/test/Users/missing")
+	var scimErr Error
+	if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil || w.Code != http.StatusNotFound || scimErr.Status != "404" {
+		t.Errorf("status = %d, error = %+v, %v, want 404", w.Code, scimErr, err)
+	}
+}
+
+func TestEncoderMiddlewareListsStreamingPlugins(t *testing.T) {
+	plugin := newStreamingPlugin(3)
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	w := serveEncoded(t, srv, "/test/Users")
+	var list ListResponse[*User]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || list.TotalResults != 3 || len(list.Resources) != 3 {
+		t.Errorf("list = %+v, %v, want 3 users", list, err)
+	}
+	if plugin.streamCalls != 0 || plugin.listCalls != 1 {
+		t.Errorf("stream calls = %d, list calls = %d, want the list, which the encoder can encode", plugin.streamCalls, plugin.listCalls)
+	}
+}
+
+func TestCompactJSONEncoder(t *testing.T) {
+	body, err := CompactJSONEncoder{}.Encode(map[string]any{
+		"userName": "alice",
+		"title":    nil,
+		"emails":   []any{map[string]any{"value": "alice@example.com", "type": nil}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"emails":[{"value":"alice@example.com"}],"userName":"alice"}` + "\n"
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestCanonicalJSONEncoder(t *testing.T) {
+	user := &User{
+		ID:         "user1",
+		UserName:   "alice",
+		Schemas:    []string{SchemaUser, "urn:example:2.0:User"},
+		Extensions: map[string]map[string]any{"urn:example:2.0:User": {"badge": 9007199254740993, "active": true}},
+	}
+	body, err := CanonicalJSONEncoder{}.Encode(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id":"user1","schemas":["` + SchemaUser + `","urn:example:2.0:User"],` +
+		`"urn:example:2.0:User":{"active":true,"badge":9007199254740993},"userName":"alice"}` + "\n"
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+}
+
+func TestCanonicalJSONEncoderStreams(t *testing.T) {
+	plugin := newStreamingPlugin(3)
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	w := httptest.NewRecorder()
+	EncoderMiddleware(CanonicalJSONEncoder{})(srv).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users?count=2", nil))
+	if plugin.streamCalls != 1 {
+		t.Errorf("stream calls = %d, want the list streamed", plugin.streamCalls)
+	}
+	var list any
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("body = %s: %v", w.Body.String(), err)
+	}
+	want, err := CanonicalJSONEncoder{}.Encode(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.String() != string(want) {
+		t.Errorf("body = %s, want %s", w.Body.String(), want)
+	}
+}
+
+func TestResponseEncoderDefault(t *testing.T) {
+	if _, ok := ResponseEncoder(httptest.NewRecorder()).(JSONEncoder); !ok {
+		t.Error("encoder of a plain writer is not JSONEncoder")
+	}
+}
@@ -24,6 +24,11 @@ type SCIMError struct {
 	Status   int
 	Detail   string
 	ScimType string
+
+	// format and args are the unformatted Detail of errors created with
+	// NewSCIMErrorf, which a MessageCatalog translates
+	format string
+	args   []any
 }
 
 // Error implements the error interface
@@ -31,6 +36,24 @@ func (e *SCIMError) Error() string {
 	return e.Detail
 }
 
+// AcceptedError is returned by plugins that accepted a write without
+// applying it yet, e.g. because they queued it for later. It is not a
+// failure: the server answers 202 Accepted with its detail, and bulk
+// operations get status 202.
+type AcceptedError struct {
+	Detail string
+
+	// Location is the path of a resource reporting the progress of the
+	// write relative to the plugin's base URL, e.g. "Operations/42", sent
+	// in the Location header. Empty sends none.
+	Location string
+}
+
+// Error implements the error interface
+func (e *AcceptedError) Error() string {
+	return e.Detail
+}
+
 // NewSCIMError creates a new SCIM error
 func NewSCIMError(status int, detail, scimType string) *SCIMError {
 	return &SCIMError{
@@ -40,6 +63,19 @@ func NewSCIMError(status int, detail, scimType string) *SCIMError {
 	}
 }
 
+// NewSCIMErrorf creates a new SCIM error with a formatted detail. Unlike a
+// detail formatted by the caller, it can be translated by a MessageCatalog,
+// which looks up the translation of format.
+func NewSCIMErrorf(status int, scimType, format string, args ...any) *SCIMError {
+	return &SCIMError{
+		Status:   status,
+		Detail:   fmt.Sprintf(format, args...),
+		ScimType: scimType,
+		format:   format,
+		args:     args,
+	}
+}
+
 // Common SCIM errors
 var (
 	ErrInvalidFilter = func(detail string) *SCIMError {
@@ -83,7 +119,7 @@ var (
 	}
 
 	ErrNotFound = func(resourceType, id string) *SCIMError {
-		return NewSCIMError(http.StatusNotFound, fmt.Sprintf("%s %s not found", resourceType, id), "")
+		return NewSCIMErrorf(http.StatusNotFound, "", "%s %s not found", resourceType, id)
 	}
 
 	ErrUnauthorized = func() *SCIMError {
@@ -94,24 +130,66 @@ var (
 		return NewSCIMError(http.StatusForbidden, "Forbidden", "")
 	}
 
+	// ErrOperationNotAllowed reports that a plugin's configuration does not
+	// allow operation on resourceType
+	ErrOperationNotAllowed = func(operation, resourceType, plugin string) *SCIMError {
+		return NewSCIMErrorf(http.StatusForbidden, "", "Operation %s on %s not allowed by plugin '%s'", operation, resourceType, plugin)
+	}
+
+	// ErrOperationNotGranted reports that none of the client's scopes or
+	// roles grants operation on a plugin's resources
+	ErrOperationNotGranted = func(operation, plugin string) *SCIMError {
+		return NewSCIMErrorf(http.StatusForbidden, "", "Operation %s not granted to the client by plugin '%s'", operation, plugin)
+	}
+
 	ErrMethodNotAllowed = func(method string) *SCIMError {
-		return NewSCIMError(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed", method), "")
+		return NewSCIMErrorf(http.StatusMethodNotAllowed, "", "Method %s not allowed", method)
+	}
+
+	ErrPreconditionFailed = func(detail string) *SCIMError {
+		return NewSCIMError(http.StatusPreconditionFailed, detail, ScimTypeInvalidVers)
+	}
+
+	// ErrConflict reports that a resourceType with the same value of
+	// attribute, such as a User's userName, already exists
+	ErrConflict = func(resourceType, attribute string) *SCIMError {
+		return NewSCIMErrorf(http.StatusConflict, ScimTypeUniqueness, "%s with the same %s already exists", resourceType, attribute)
 	}
 
-	ErrConflict = func(detail string) *SCIMError {
-		return NewSCIMError(http.StatusConflict, detail, "")
+	ErrPluginNotFound = func(name string) *SCIMError {
+		return NewSCIMErrorf(http.StatusNotFound, ScimTypeInvalidPath, "Plugin '%s' not found", name)
 	}
 
 	ErrInternalServer = func(detail string) *SCIMError {
 		return NewSCIMError(http.StatusInternalServerError, detail, "")
 	}
 
+	ErrServiceUnavailable = func(detail string) *SCIMError {
+		return NewSCIMError(http.StatusServiceUnavailable, detail, "")
+	}
+
+	// ErrPayloadTooLarge reports a bulk request larger than the
+	// maxPayloadSize of the plugin, in bytes
+	ErrPayloadTooLarge = func(maxPayloadSize int) *SCIMError {
+		return NewSCIMErrorf(http.StatusRequestEntityTooLarge, "", "The size of the bulk operation exceeds the maxPayloadSize (%d)", maxPayloadSize)
+	}
+
+	// ErrTooManyOperations reports a bulk request with more operations than
+	// the maxOperations of the plugin
+	ErrTooManyOperations = func(maxOperations int) *SCIMError {
+		return NewSCIMErrorf(http.StatusRequestEntityTooLarge, ScimTypeTooMany, "The number of operations exceeds the maxOperations (%d)", maxOperations)
+	}
+
 	ErrNotImplemented = func(feature string) *SCIMError {
-		return NewSCIMError(http.StatusNotImplemented, fmt.Sprintf("%s not implemented", feature), "")
+		return NewSCIMErrorf(http.StatusNotImplemented, "", "%s not implemented", feature)
 	}
 )
 
 // WriteSCIMError writes a SCIM error response
 func (h *Handler) WriteSCIMError(w http.ResponseWriter, err *SCIMError) {
-	h.WriteError(w, err.Status, err.Detail, err.ScimType)
+	if err.format == "" {
+		h.WriteError(w, err.Status, err.Detail, err.ScimType)
+		return
+	}
+	h.writeError(w, err.Status, h.localize(w, err.format, err.args), err.ScimType)
 }
@@ -73,6 +73,13 @@ func (e *ETagGenerator) CheckPreconditions(r *http.Request, currentETag string)
 	return http.StatusOK, nil
 }
 
+// hasPreconditions reports whether a request carries If-Match or
+// If-None-Match, so that writes without them need not fetch the current
+// resource to check them
+func hasPreconditions(r *http.Request) bool {
+	return r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != ""
+}
+
 // matchesETag checks if an ETag matches
 func (e *ETagGenerator) matchesETag(headerValue, currentETag string) bool {
 	// Handle * (any)
@@ -112,3 +119,29 @@ func UpdateResourceVersion(meta *Meta, etag string) {
 		meta.Version = version
 	}
 }
+
+// withVersion returns a shallow copy of a user or group whose meta.version is
+// etag, as responses show it
+func withVersion(resource any, etag string) any {
+	switch r := resource.(type) {
+	case *User:
+		copied := *r
+		copied.Meta = versionedMeta(r.Meta, etag)
+		return &copied
+	case *Group:
+		copied := *r
+		copied.Meta = versionedMeta(r.Meta, etag)
+		return &copied
+	}
+	return resource
+}
+
+// versionedMeta returns a copy of meta with the version of etag
+func versionedMeta(meta *Meta, etag string) *Meta {
+	if meta == nil {
+		return nil
+	}
+	versioned := *meta
+	UpdateResourceVersion(&versioned, etag)
+	return &versioned
+}
@@ -1,8 +1,11 @@
 package scim
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -182,3 +185,78 @@ func TestETagGenerator_MatchesETag(t *testing.T) {
 		})
 	}
 }
+
+// versionedPlugin reports a fixed meta.version, records the preconditions the
+// gateway passes on writes and fails them if conflict is set
+type versionedPlugin struct {
+	*mockPlugin
+	preconditions []Precondition
+	conflict      bool
+}
+
+func (p *versionedPlugin) GetUser(ctx context.Context, id string, attributes []string) (*User, error) {
+	user, err := p.mockPlugin.GetUser(ctx, id, attributes)
+	if err != nil {
+		return nil, err
+	}
+	clone := *user
+	clone.Meta = &Meta{ResourceType: ResourceTypeUser, Version: `W/"7"`}
+	return &clone, nil
+}
+
+func (p *versionedPlugin) ModifyUser(ctx context.Context, id string, patch *PatchOp) error {
+	precondition, _ := PreconditionFromContext(ctx)
+	p.preconditions = append(p.preconditions, precondition)
+	if p.conflict {
+		return ErrPreconditionFailed("user was modified concurrently")
+	}
+	return p.mockPlugin.ModifyUser(ctx, id, patch)
+}
+
+func TestServerPassesPrecondition(t *testing.T) {
+	plugin := &versionedPlugin{mockPlugin: newMockPlugin()}
+	plugin.users["u1"] = &User{ID: "u1", UserName: "alice"}
+	server := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	patch := func(ifMatch string) *httptest.ResponseRecorder {
+		body := `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "replace", "path": "displayName", "value": "Alice"}]}`
+		req := httptest.NewRequest("PATCH", "/test/Users/u1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	getETag := func() string {
+		req := httptest.NewRequest("GET", "/test/Users/u1", nil)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Header().Get("ETag")
+	}
+
+	// Unconditional writes carry no precondition
+	if w := patch(""); w.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body: %s", w.Code, w.Body.String())
+	}
+	etag := getETag()
+	if w := patch(etag); w.Code != http.StatusOK {
+		t.Fatalf("PATCH with If-Match status = %d, body: %s", w.Code, w.Body.String())
+	}
+	want := []Precondition{{}, {IfMatch: etag, CurrentETag: etag, Version: `W/"7"`}}
+	if !reflect.DeepEqual(plugin.preconditions, want) {
+		t.Errorf("preconditions = %+v, want %+v", plugin.preconditions, want)
+	}
+	ctx := WithPrecondition(context.Background(), want[1])
+	if version, ok := ExpectedVersionFromContext(ctx); !ok || version != `W/"7"` {
+		t.Errorf("ExpectedVersionFromContext() = %q, %v", version, ok)
+	}
+
+	// A plugin detecting a concurrent change fails the request with 412
+	plugin.conflict = true
+	if w := patch(getETag()); w.Code != http.StatusPreconditionFailed || !strings.Contains(w.Body.String(), "invalidVers") {
+		t.Errorf("PATCH status = %d, body: %s, want 412 invalidVers", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,367 @@
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// userJSON and groupJSON have the fields of User and Group without their JSON
+// methods, for the default encoding of the struct fields
+type (
+	userJSON  User
+	groupJSON Group
+)
+
+// MarshalJSON encodes the user with its extension attributes under their schema URNs
+func (u User) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(userJSON(u))
+	if err != nil {
+		return nil, err
+	}
+	return appendExtensions(data, u.Extensions, SchemaEnterpriseUser)
+}
+
+// UnmarshalJSON decodes a user, collecting attributes under extension schema URNs
+// without a struct field into Extensions
+func (u *User) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*userJSON)(u)); err != nil {
+		return err
+	}
+	extensions, err := decodeExtensions(data, SchemaEnterpriseUser)
+	if err != nil {
+		return err
+	}
+	if extensions != nil {
+		u.Extensions = extensions
+	}
+	return nil
+}
+
+// MarshalJSON encodes the group with its extension attributes under their schema URNs
+func (g Group) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(groupJSON(g))
+	if err != nil {
+		return nil, err
+	}
+	return appendExtensions(data, g.Extensions)
+}
+
+// UnmarshalJSON decodes a group, collecting attributes under extension schema
+// URNs into Extensions
+func (g *Group) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*groupJSON)(g)); err != nil {
+		return err
+	}
+	extensions, err := decodeExtensions(data)
+	if err != nil {
+		return err
+	}
+	if extensions != nil {
+		g.Extensions = extensions
+	}
+	return nil
+}
+
+// appendExtensions adds the non-empty extensions as members of the JSON object
+// data, in URN order. URNs of typed struct fields are skipped.
+func appendExtensions(data []byte, extensions map[string]map[string]any, typed ...string) ([]byte, error) {
+	if len(extensions) == 0 {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1]) // without the closing brace
+	for _, urn := range slices.Sorted(maps.Keys(extensions)) {
+		if len(extensions[urn]) == 0 || slices.Contains(typed, urn) {
+			continue
+		}
+		key, err := json.Marshal(urn)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(extensions[urn])
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// decodeExtensions returns the members of the JSON object data keyed by an
+// extension schema URN, except the core schemas and the typed URNs. It returns
+// nil if there are none.
+func decodeExtensions(data []byte, typed ...string) (map[string]map[string]any, error) {
+	if !bytes.Contains(data, []byte(`"urn:`)) {
+		return nil, nil
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	var extensions map[string]map[string]any
+	for key, raw := range members {
+		if !strings.HasPrefix(strings.ToLower(key), "urn:") || isCoreSchema(key) || slices.Contains(typed, key) {
+			continue
+		}
+		var attributes map[string]any
+		if err := json.Unmarshal(raw, &attributes); err != nil {
+			return nil, fmt.Errorf("extension %s must be a JSON object", key)
+		}
+		if extensions == nil {
+			extensions = make(map[string]map[string]any)
+		}
+		extensions[key] = attributes
+	}
+	return extensions, nil
+}
+
+// extensionKeys adds to present whether each extension holds attributes, for
+// EnsureUserSchemas and EnsureGroupSchemas
+func extensionKeys(extensions map[string]map[string]any, present map[string]bool) map[string]bool {
+	if present == nil {
+		present = make(map[string]bool, len(extensions))
+	}
+	for urn, attributes := range extensions {
+		present[urn] = len(attributes) > 0
+	}
+	return present
+}
+
+// extensionsType is the type of the Extensions field of User and Group
+var extensionsType = reflect.TypeFor[map[string]map[string]any]()
+
+// extensionTarget returns the Extensions field of resource if path addresses an
+// extension without a struct field of its own, or an invalid value otherwise
+func extensionTarget(resource any, path *Path) reflect.Value {
+	if len(path.Segments) == 0 || !strings.HasPrefix(strings.ToLower(path.Segments[0].Attribute), "urn:") {
+		return reflect.Value{}
+	}
+
+	v := reflect.ValueOf(resource)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || findField(v, path.Segments[0].Attribute).IsValid() {
+		return reflect.Value{}
+	}
+
+	field := v.FieldByName("Extensions")
+	if !field.IsValid() || field.Type() != extensionsType || !field.CanSet() {
+		return reflect.Value{}
+	}
+	return field
+}
+
+// patchExtension applies a PATCH operation to the attributes of the extension
+// named by the first path segment, stored in the Extensions field
+func patchExtension(field reflect.Value, op string, path *Path, value any) error {
+	// Normalize the value to its JSON representation (maps, slices, float64)
+	if value != nil {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+
+	extensions := field.Interface().(map[string]map[string]any)
+	if extensions == nil {
+		extensions = make(map[string]map[string]any)
+		field.Set(reflect.ValueOf(extensions))
+	}
+
+	urn := path.Segments[0].Attribute
+	for key := range extensions {
+		if strings.EqualFold(key, urn) {
+			urn = key
+			break
+		}
+	}
+
+	attributes, err := patchAttributes(extensions[urn], op, path.Segments[1:], value)
+	if err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		delete(extensions, urn)
+	} else {
+		extensions[urn] = attributes
+	}
+	return nil
+}
+
+// patchAttributes applies a PATCH operation at the path segments of an
+// extension's attributes and returns the updated attributes
+func patchAttributes(attributes map[string]any, op string, segments []PathSegment, value any) (map[string]any, error) {
+	if len(segments) == 0 {
+		switch op {
+		case "remove":
+			return nil, nil
+		case "replace":
+			attrMap, ok := value.(map[string]any)
+			if !ok {
+				return nil, ErrInvalidValue("extension value must be a JSON object")
+			}
+			return attrMap, nil
+		default:
+			attrMap, ok := value.(map[string]any)
+			if !ok {
+				return nil, ErrInvalidValue("extension value must be a JSON object")
+			}
+			if attributes == nil {
+				attributes = make(map[string]any, len(attrMap))
+			}
+			for name, v := range attrMap {
+				key, ok := findKey(attributes, name)
+				if !ok {
+					key = name
+				}
+				attributes[key] = v
+			}
+			return attributes, nil
+		}
+	}
+	if len(segments) > 2 {
+		return nil, ErrInvalidPath("extension attribute paths support at most one sub-attribute")
+	}
+
+	if attributes == nil {
+		if op == "remove" {
+			return nil, nil
+		}
+		attributes = make(map[string]any)
+	}
+
+	segment := segments[0]
+	key, exists := findKey(attributes, segment.Attribute)
+	if !exists {
+		key = segment.Attribute
+	}
+
+	if segment.Filter != nil {
+		values, _ := attributes[key].([]any)
+		updated, err := patchFilteredValues(values, op, segment.Filter, segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		setOrDelete(attributes, key, updated)
+		return attributes, nil
+	}
+
+	if len(segments) == 2 {
+		// Sub-attribute of a complex attribute, e.g. manager.displayName
+		complexValue, _ := attributes[key].(map[string]any)
+		if complexValue == nil {
+			if op == "remove" {
+				return attributes, nil
+			}
+			complexValue = make(map[string]any)
+		}
+		updated, err := patchAttributes(complexValue, op, segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		setOrDelete(attributes, key, updated)
+		return attributes, nil
+	}
+
+	switch op {
+	case "remove":
+		delete(attributes, key)
+	case "add":
+		// Values added to a multi-valued attribute are appended
+		if existing, ok := attributes[key].([]any); ok {
+			if added, ok := value.([]any); ok {
+				attributes[key] = append(existing, added...)
+			} else {
+				attributes[key] = append(existing, value)
+			}
+			break
+		}
+		attributes[key] = value
+	default:
+		attributes[key] = value
+	}
+	return attributes, nil
+}
+
+// patchFilteredValues applies a PATCH operation to the elements of a
+// multi-valued attribute matching filter, e.g. devices[type eq "laptop"]
+func patchFilteredValues(values []any, op string, filter *AttributeExpression, sub []PathSegment, value any) ([]any, error) {
+	matched := false
+	result := make([]any, 0, len(values))
+	for _, elem := range values {
+		elemMap, ok := elem.(map[string]any)
+		if !ok || !filter.Matches(elemMap) {
+			result = append(result, elem)
+			continue
+		}
+		matched = true
+
+		if len(sub) == 0 {
+			switch op {
+			case "remove":
+				continue
+			case "replace":
+				result = append(result, value)
+				continue
+			}
+		}
+
+		updated, err := patchAttributes(elemMap, op, sub, value)
+		if err != nil {
+			return nil, err
+		}
+		if len(updated) > 0 {
+			result = append(result, updated)
+		}
+	}
+
+	if !matched && op != "remove" {
+		if op == "replace" && len(sub) == 0 {
+			return nil, ErrNoTarget(fmt.Sprintf("no values match filter %s", filter.AttributePath))
+		}
+		// Add an element carrying the filter's equality value
+		elem := make(map[string]any)
+		if filter.Operator == "eq" {
+			elem[filter.AttributePath] = filter.Value
+		}
+		updated, err := patchAttributes(elem, op, sub, value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, updated)
+	}
+	return result, nil
+}
+
+// setOrDelete sets key to value, or deletes it if value is an empty array or object
+func setOrDelete(attributes map[string]any, key string, value any) {
+	switch v := value.(type) {
+	case []any:
+		if len(v) == 0 {
+			delete(attributes, key)
+			return
+		}
+	case map[string]any:
+		if len(v) == 0 {
+			delete(attributes, key)
+			return
+		}
+	}
+	attributes[key] = value
+}
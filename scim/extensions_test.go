@@ -0,0 +1,169 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUserExtensionsJSON(t *testing.T) {
+	data := []byte(`{
+		"schemas": ["` + SchemaUser + `", "` + testExtensionURN + `"],
+		"userName": "alice",
+		"` + SchemaEnterpriseUser + `": {"department": "Sales"},
+		"` + testExtensionURN + `": {"costCenter": "CC-1", "level": 2}
+	}`)
+
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if user.UserName != "alice" || user.EnterpriseUser["department"] != "Sales" {
+		t.Errorf("core or enterprise attributes not decoded: %+v", user)
+	}
+	want := map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "level": float64(2)}}
+	if !reflect.DeepEqual(user.Extensions, want) {
+		t.Errorf("Extensions = %v, want %v", user.Extensions, want)
+	}
+
+	encoded, err := json.Marshal(&user)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var roundTrip User
+	if err := json.Unmarshal(encoded, &roundTrip); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip.Extensions, want) || roundTrip.EnterpriseUser["department"] != "Sales" {
+		t.Errorf("round trip = %s", encoded)
+	}
+	if strings.Count(string(encoded), SchemaEnterpriseUser+`":`) != 1 {
+		t.Errorf("enterprise extension encoded more than once: %s", encoded)
+	}
+
+	// Users without extensions encode exactly as before
+	plain, _ := json.Marshal(User{ID: "1", UserName: "bob"})
+	if string(plain) != `{"id":"1","schemas":null,"userName":"bob"}` {
+		t.Errorf("Marshal() = %s", plain)
+	}
+
+	if err := json.Unmarshal([]byte(`{"userName": "x", "`+testExtensionURN+`": "CC-1"}`), &user); err == nil {
+		t.Error("Unmarshal() of a non-object extension should fail")
+	}
+}
+
+func TestGroupExtensionsJSON(t *testing.T) {
+	urn := "urn:example:scim:schemas:extension:acme:1.0:Group"
+	group := Group{ID: "g1", DisplayName: "Admins", Extensions: map[string]map[string]any{urn: {"owner": "alice"}}}
+
+	encoded, err := json.Marshal(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Group
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.DisplayName != "Admins" || decoded.Extensions[urn]["owner"] != "alice" {
+		t.Errorf("round trip = %s", encoded)
+	}
+
+	EnsureGroupSchemas(&decoded)
+	if !reflect.DeepEqual(decoded.Schemas, []string{SchemaGroup, urn}) {
+		t.Errorf("schemas = %v, want the extension announced", decoded.Schemas)
+	}
+}
+
+func TestPatchExtensions(t *testing.T) {
+	newUser := func() *User {
+		return &User{
+			UserName: "alice",
+			Extensions: map[string]map[string]any{testExtensionURN: {
+				"costCenter": "CC-1",
+				"badges":     []any{"a"},
+				"devices":    []any{map[string]any{"type": "laptop", "serial": "X1"}},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		op   PatchOperation
+		want map[string]any // nil expects the extension removed
+	}{
+		{
+			name: "replace attribute with differently cased name",
+			op:   PatchOperation{Op: "replace", Path: testExtensionURN + ":COSTCENTER", Value: "CC-2"},
+			want: map[string]any{"costCenter": "CC-2", "badges": []any{"a"}, "devices": []any{map[string]any{"type": "laptop", "serial": "X1"}}},
+		},
+		{
+			name: "add appends to multi-valued attribute",
+			op:   PatchOperation{Op: "add", Path: testExtensionURN + ":badges", Value: []any{"b", "c"}},
+			want: map[string]any{"costCenter": "CC-1", "badges": []any{"a", "b", "c"}, "devices": []any{map[string]any{"type": "laptop", "serial": "X1"}}},
+		},
+		{
+			name: "add sub-attribute of new complex attribute",
+			op:   PatchOperation{Op: "add", Path: testExtensionURN + ":sponsor.value", Value: "u1"},
+			want: map[string]any{"costCenter": "CC-1", "badges": []any{"a"}, "devices": []any{map[string]any{"type": "laptop", "serial": "X1"}}, "sponsor": map[string]any{"value": "u1"}},
+		},
+		{
+			name: "replace sub-attribute of filtered element",
+			op:   PatchOperation{Op: "replace", Path: testExtensionURN + `:devices[type eq "laptop"].serial`, Value: "X2"},
+			want: map[string]any{"costCenter": "CC-1", "badges": []any{"a"}, "devices": []any{map[string]any{"type": "laptop", "serial": "X2"}}},
+		},
+		{
+			name: "add creates element for unmatched filter",
+			op:   PatchOperation{Op: "add", Path: testExtensionURN + `:devices[type eq "phone"].serial`, Value: "P1"},
+			want: map[string]any{"costCenter": "CC-1", "badges": []any{"a"}, "devices": []any{
+				map[string]any{"type": "laptop", "serial": "X1"},
+				map[string]any{"type": "phone", "serial": "P1"},
+			}},
+		},
+		{
+			name: "remove filtered element",
+			op:   PatchOperation{Op: "remove", Path: testExtensionURN + `:devices[type eq "laptop"]`},
+			want: map[string]any{"costCenter": "CC-1", "badges": []any{"a"}},
+		},
+		{
+			name: "remove extension",
+			op:   PatchOperation{Op: "remove", Path: testExtensionURN},
+		},
+		{
+			name: "add without path merges extension",
+			op:   PatchOperation{Op: "add", Value: map[string]any{testExtensionURN: map[string]any{"level": 3}}},
+			want: map[string]any{"costCenter": "CC-1", "level": float64(3), "badges": []any{"a"}, "devices": []any{map[string]any{"type": "laptop", "serial": "X1"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := newUser()
+			patch := &PatchOp{Schemas: []string{SchemaPatchOp}, Operations: []PatchOperation{tt.op}}
+			if err := NewPatchProcessor().ApplyPatch(user, patch); err != nil {
+				t.Fatalf("ApplyPatch() error = %v", err)
+			}
+
+			got, ok := user.Extensions[testExtensionURN]
+			if tt.want == nil {
+				if ok {
+					t.Errorf("extension = %v, want removed", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extension = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A new extension is created on first add
+	user := &User{UserName: "bob"}
+	patch := &PatchOp{Operations: []PatchOperation{{Op: "add", Path: testExtensionURN + ":costCenter", Value: "CC-9"}}}
+	if err := NewPatchProcessor().ApplyPatch(user, patch); err != nil {
+		t.Fatal(err)
+	}
+	if user.Extensions[testExtensionURN]["costCenter"] != "CC-9" {
+		t.Errorf("Extensions = %v", user.Extensions)
+	}
+}
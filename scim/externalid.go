@@ -0,0 +1,182 @@
+package scim
+
+import (
+	"context"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/filter"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// ExternalIDLookup is an optional interface for plugins that index the
+// externalId of their users and groups, the identifier the provisioning
+// client assigns to them (RFC 7643 Section 3.1).
+//
+// The plugin adapter answers GET /Users?filter=externalId eq "..." and its
+// Group counterpart with a single lookup instead of a list request, and the
+// server looks up the externalId of every write to keep it unique. Lookups
+// are case-exact and scoped like other requests, e.g. by
+// BaseEntityFromContext. They return nil without error if no resource has
+// the externalId.
+//
+// The check before a write does not lock out concurrent writes; plugins
+// should also back the index with a unique constraint and return
+// ErrConflict(resourceType, "externalId") when it is violated.
+type ExternalIDLookup interface {
+	LookupUserByExternalID(ctx context.Context, externalID string) (*User, error)
+	LookupGroupByExternalID(ctx context.Context, externalID string) (*Group, error)
+}
+
+// ValidateExternalID validates the externalId of a User or Group: it must
+// not be blank, and it must be unique among the resources of resourceType.
+// id is the ID of the resource written, empty for a create, and ownerID the
+// ID of the resource already having externalID, empty if none does.
+func (v *Validator) ValidateExternalID(resourceType, id, externalID, ownerID string) error {
+	if externalID == "" {
+		return nil
+	}
+	if strings.TrimSpace(externalID) == "" {
+		return ErrInvalidValue("externalId cannot be blank")
+	}
+	if ownerID != "" && ownerID != id {
+		return ErrConflict(resourceType, "externalId")
+	}
+	return nil
+}
+
+// checkExternalID validates externalID, written to the resource of
+// resourceType with id, against the resource the plugin finds with it.
+// Plugins without ExternalIDLookup are not checked for uniqueness.
+func (s *Server) checkExternalID(ctx context.Context, plugin PluginGetter, resourceType, id, externalID string) error {
+	validator := NewValidatorWithSchemas(s.schemas)
+	lookup, ok := lookupCapability[ExternalIDLookup](plugin)
+	if !ok || strings.TrimSpace(externalID) == "" {
+		return validator.ValidateExternalID(resourceType, id, externalID, "")
+	}
+
+	var ownerID string
+	switch resourceType {
+	case ResourceTypeUser:
+		user, err := lookup.LookupUserByExternalID(ctx, externalID)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			ownerID = user.ID
+		}
+	case ResourceTypeGroup:
+		group, err := lookup.LookupGroupByExternalID(ctx, externalID)
+		if err != nil {
+			return err
+		}
+		if group != nil {
+			ownerID = group.ID
+		}
+	}
+	return validator.ValidateExternalID(resourceType, id, externalID, ownerID)
+}
+
+// checkPatchExternalID validates the externalId a PATCH sets, if any, as
+// checkExternalID
+func (s *Server) checkPatchExternalID(ctx context.Context, plugin PluginGetter, resourceType, id string, patch *PatchOp) error {
+	externalID, ok := patchExternalID(patch)
+	if !ok {
+		return nil
+	}
+	return s.checkExternalID(ctx, plugin, resourceType, id, externalID)
+}
+
+// patchExternalID returns the externalId the add and replace operations of
+// patch set last, either by path or as a member of a value without path
+func patchExternalID(patch *PatchOp) (string, bool) {
+	var externalID string
+	var found bool
+	for _, op := range patch.Operations {
+		if op.Op != "add" && op.Op != "replace" {
+			continue
+		}
+		if op.Path == "" {
+			if value, ok := op.Value.(map[string]any); ok {
+				for name, member := range value {
+					if s, ok := member.(string); ok && strings.EqualFold(name, "externalId") {
+						externalID, found = s, true
+					}
+				}
+			}
+			continue
+		}
+		urn, attrPath := SplitSchemaURN(op.Path)
+		if (urn == "" || isCoreSchema(urn)) && strings.EqualFold(attrPath, "externalId") {
+			if s, ok := op.Value.(string); ok {
+				externalID, found = s, true
+			}
+		}
+	}
+	return externalID, found
+}
+
+// ExternalIDFilter returns the externalId a filter selects if it is exactly
+// externalId eq "<value>", optionally with the core schema URN, which can
+// be answered with an ExternalIDLookup
+func ExternalIDFilter(filterStr string) (string, bool) {
+	return eqFilterValue(filterStr, "externalId")
+}
+
+// eqFilterValue returns the value a filter selects if it is exactly
+// <attribute> eq "<value>", optionally parenthesized and with the core
+// schema URN
+func eqFilterValue(filterStr, attribute string) (string, bool) {
+	if filterStr == "" {
+		return "", false
+	}
+	expr, err := filter.Parse(filterStr)
+	if err != nil {
+		return "", false
+	}
+	for {
+		group, ok := expr.(*filter.GroupExpr)
+		if !ok {
+			break
+		}
+		expr = group.Expr
+	}
+	attr, ok := expr.(*filter.AttributeExpr)
+	if !ok || attr.Operator != filter.Eq || attr.Path.Filter != nil || attr.Path.SubAttribute != "" {
+		return "", false
+	}
+	if !strings.EqualFold(attr.Path.Attribute, attribute) || (attr.Path.URN != "" && !isCoreSchema(attr.Path.URN)) {
+		return "", false
+	}
+	value, ok := attr.Value.(string)
+	return value, ok
+}
+
+// LookupUsersByExternalID answers params, whose filter selects externalID,
+// with lookup, applying pagination and attribute selection to the user found
+func LookupUsersByExternalID(ctx context.Context, lookup ExternalIDLookup, externalID string, params QueryParams) (*ListResponse[*User], error) {
+	scimcontext.TraceFilter(ctx, "answered by externalId lookup")
+	user, err := lookup.LookupUserByExternalID(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+	var users []*User
+	if user != nil {
+		users = append(users, user)
+	}
+	return ProcessListQuery(users, params)
+}
+
+// LookupGroupsByExternalID is the Group counterpart of
+// LookupUsersByExternalID
+func LookupGroupsByExternalID(ctx context.Context, lookup ExternalIDLookup, externalID string, params QueryParams) (*ListResponse[*Group], error) {
+	scimcontext.TraceFilter(ctx, "answered by externalId lookup")
+	group, err := lookup.LookupGroupByExternalID(ctx, externalID)
+	if err != nil {
+		return nil, err
+	}
+	var groups []*Group
+	if group != nil {
+		groups = append(groups, group)
+	}
+	return ProcessListQuery(groups, params)
+}
@@ -0,0 +1,120 @@
+package scim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// lookupPlugin is a mockPlugin looking up resources by externalId
+type lookupPlugin struct {
+	*mockPlugin
+}
+
+func (p *lookupPlugin) LookupUserByExternalID(ctx context.Context, externalID string) (*User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, user := range p.users {
+		if user.ExternalID == externalID {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *lookupPlugin) LookupGroupByExternalID(ctx context.Context, externalID string) (*Group, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, group := range p.groups {
+		if group.ExternalID == externalID {
+			return group, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestExternalIDFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+		ok     bool
+	}{
+		{`externalId eq "ext-1"`, "ext-1", true},
+		{`ExternalID eq "ext-1"`, "ext-1", true},
+		{`(externalId eq "ext-1")`, "ext-1", true},
+		{SchemaUser + `:externalId eq "ext-1"`, "ext-1", true},
+		{SchemaEnterpriseUser + `:externalId eq "ext-1"`, "", false},
+		{`externalId sw "ext"`, "", false},
+		{`externalId eq "ext-1" and active eq true`, "", false},
+		{`externalId eq 1`, "", false},
+		{`userName eq "ext-1"`, "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ExternalIDFilter(tt.filter)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ExternalIDFilter(%q) = %q, %v, want %q, %v", tt.filter, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestValidateExternalID(t *testing.T) {
+	v := NewValidator()
+	if err := v.ValidateExternalID(ResourceTypeUser, "u1", "ext-1", "u1"); err != nil {
+		t.Errorf("ValidateExternalID() of the owner error = %v, want nil", err)
+	}
+	if err := v.ValidateExternalID(ResourceTypeUser, "", "ext-1", "u1"); err == nil || err.(*SCIMError).Status != http.StatusConflict {
+		t.Errorf("ValidateExternalID() of another resource error = %v, want 409", err)
+	}
+	if err := v.ValidateExternalID(ResourceTypeGroup, "", "  ", ""); err == nil {
+		t.Error("ValidateExternalID() of a blank externalId error = nil, want error")
+	}
+}
+
+func TestPatchExternalID(t *testing.T) {
+	patch := &PatchOp{Operations: []PatchOperation{
+		{Op: "replace", Path: "externalId", Value: "ext-1"},
+		{Op: "add", Value: map[string]any{"externalID": "ext-2"}},
+		{Op: "replace", Path: SchemaEnterpriseUser + ":externalId", Value: "ext-3"},
+	}}
+	if got, ok := patchExternalID(patch); !ok || got != "ext-2" {
+		t.Errorf("patchExternalID() = %q, %v, want ext-2", got, ok)
+	}
+	if _, ok := patchExternalID(&PatchOp{Operations: []PatchOperation{{Op: "remove", Path: "externalId"}}}); ok {
+		t.Error("patchExternalID() of a remove = true, want false")
+	}
+}
+
+func TestExternalIDUniqueness(t *testing.T) {
+	plugin := &lookupPlugin{newMockPlugin()}
+	plugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice", ExternalID: "ext-1"})
+	plugin.CreateUser(context.Background(), &User{ID: "u2", UserName: "bob", ExternalID: "ext-2"})
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"create with a taken externalId", http.MethodPost, "/test/Users", `{"schemas":["` + SchemaUser + `"],"userName":"carol","externalId":"ext-1"}`, http.StatusConflict},
+		{"create with a new externalId", http.MethodPost, "/test/Users", `{"schemas":["` + SchemaUser + `"],"userName":"carol","externalId":"ext-3"}`, http.StatusCreated},
+		{"replace keeping the externalId", http.MethodPut, "/test/Users/u1", `{"schemas":["` + SchemaUser + `"],"userName":"alice","externalId":"ext-1"}`, http.StatusOK},
+		{"patch to a taken externalId", http.MethodPatch, "/test/Users/u2", `{"schemas":["` + SchemaPatchOp + `"],"Operations":[{"op":"replace","path":"externalId","value":"ext-1"}]}`, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
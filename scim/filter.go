@@ -2,17 +2,17 @@ package scim
 
 import (
 	"encoding/json"
-	"fmt"
 	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
+
+	"github.com/marcelom97/scimgateway/filter"
 )
 
-// FilterParser parses SCIM filter expressions
+// FilterParser parses SCIM filter expressions into Filters evaluated in
+// memory
 type FilterParser struct {
 	input string
-	pos   int
 }
 
 // Filter represents a parsed SCIM filter
@@ -25,6 +25,9 @@ type AttributeExpression struct {
 	AttributePath string
 	Operator      string
 	Value         any
+
+	// fold normalizes strings before they are compared, FoldCase if nil
+	fold func(string) string
 }
 
 // LogicalExpression represents a logical operation (AND, OR, NOT)
@@ -41,276 +44,64 @@ type GroupExpression struct {
 
 // NewFilterParser creates a new filter parser
 func NewFilterParser(filter string) *FilterParser {
-	return &FilterParser{
-		input: strings.TrimSpace(filter),
-		pos:   0,
-	}
+	return &FilterParser{input: filter}
 }
 
-// Parse parses the filter expression
+// Parse parses the filter expression. Plugins translating filters to the
+// query language of their backend use the syntax tree of filter.Parse
+// instead.
 func (p *FilterParser) Parse() (Filter, error) {
-	if p.input == "" {
-		return nil, nil
-	}
-	return p.parseLogicalOr()
-}
-
-// parseLogicalOr parses OR expressions
-func (p *FilterParser) parseLogicalOr() (Filter, error) {
-	left, err := p.parseLogicalAnd()
-	if err != nil {
-		return nil, err
-	}
-
-	for {
-		p.skipWhitespace()
-		if !p.matchKeyword("or") {
-			break
-		}
-		p.pos += 2
-		p.skipWhitespace()
-
-		right, err := p.parseLogicalAnd()
-		if err != nil {
-			return nil, err
-		}
-
-		left = &LogicalExpression{
-			Operator: "or",
-			Left:     left,
-			Right:    right,
-		}
-	}
-
-	return left, nil
-}
-
-// parseLogicalAnd parses AND expressions
-func (p *FilterParser) parseLogicalAnd() (Filter, error) {
-	left, err := p.parseNot()
-	if err != nil {
+	expr, err := filter.Parse(p.input)
+	if err != nil || expr == nil {
 		return nil, err
 	}
-
-	for {
-		p.skipWhitespace()
-		if !p.matchKeyword("and") {
-			break
-		}
-		p.pos += 3
-		p.skipWhitespace()
-
-		right, err := p.parseNot()
-		if err != nil {
-			return nil, err
-		}
-
-		left = &LogicalExpression{
-			Operator: "and",
-			Left:     left,
-			Right:    right,
-		}
-	}
-
-	return left, nil
+	return compileFilter(expr), nil
 }
 
-// parseNot parses NOT expressions
-func (p *FilterParser) parseNot() (Filter, error) {
-	p.skipWhitespace()
-	if p.matchKeyword("not") {
-		p.pos += 3
-		p.skipWhitespace()
-
-		filter, err := p.parsePrimary()
-		if err != nil {
-			return nil, err
+// compileFilter converts a filter syntax tree to the Filter evaluating it
+func compileFilter(expr filter.Expr) Filter {
+	switch e := expr.(type) {
+	case *filter.AttributeExpr:
+		return &AttributeExpression{
+			AttributePath: e.Path.String(),
+			Operator:      string(e.Operator),
+			Value:         e.Value,
 		}
-
+	case *filter.LogicalExpr:
 		return &LogicalExpression{
-			Operator: "not",
-			Left:     filter,
-		}, nil
-	}
-
-	return p.parsePrimary()
-}
-
-// parsePrimary parses primary expressions (attribute expressions or grouped expressions)
-func (p *FilterParser) parsePrimary() (Filter, error) {
-	p.skipWhitespace()
-
-	// Check for grouped expression
-	if p.peek() == '(' {
-		p.pos++
-		filter, err := p.parseLogicalOr()
-		if err != nil {
-			return nil, err
-		}
-		p.skipWhitespace()
-		if p.peek() != ')' {
-			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
-		}
-		p.pos++
-		return &GroupExpression{Filter: filter}, nil
-	}
-
-	// Parse attribute expression
-	return p.parseAttributeExpression()
-}
-
-// parseAttributeExpression parses an attribute comparison
-func (p *FilterParser) parseAttributeExpression() (Filter, error) {
-	p.skipWhitespace()
-
-	// Parse attribute path
-	attrPath := p.parseAttributePath()
-	if attrPath == "" {
-		return nil, fmt.Errorf("expected attribute path at position %d", p.pos)
-	}
-
-	p.skipWhitespace()
-
-	// Parse operator
-	op := p.parseOperator()
-	if op == "" {
-		return nil, fmt.Errorf("expected operator at position %d", p.pos)
-	}
-
-	p.skipWhitespace()
-
-	var value any
-	// pr (present) operator doesn't need a value
-	if op != "pr" {
-		// Parse value
-		var err error
-		value, err = p.parseValue()
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return &AttributeExpression{
-		AttributePath: attrPath,
-		Operator:      op,
-		Value:         value,
-	}, nil
-}
-
-// parseAttributePath parses an attribute path
-func (p *FilterParser) parseAttributePath() string {
-	start := p.pos
-	for p.pos < len(p.input) {
-		ch := p.input[p.pos]
-		if !isAlphaNumeric(ch) && ch != '.' && ch != '[' && ch != ']' && ch != '"' && ch != ' ' {
-			break
-		}
-		// Handle complex paths like emails[type eq "work"].value
-		if ch == ' ' && p.pos > start {
-			// Check if we're in a bracket expression
-			inBracket := false
-			for i := start; i < p.pos; i++ {
-				switch p.input[i] {
-				case '[':
-					inBracket = true
-				case ']':
-					inBracket = false
-				}
-			}
-			if !inBracket {
-				break
-			}
-		}
-		p.pos++
-	}
-	return strings.TrimSpace(p.input[start:p.pos])
-}
-
-// parseOperator parses a comparison operator
-func (p *FilterParser) parseOperator() string {
-	p.skipWhitespace()
-	operators := []string{"eq", "ne", "co", "sw", "ew", "pr", "gt", "ge", "lt", "le"}
-
-	for _, op := range operators {
-		if p.matchKeyword(op) {
-			p.pos += len(op)
-			return op
-		}
-	}
-
-	return ""
-}
-
-// parseValue parses a value (string, number, boolean, null)
-func (p *FilterParser) parseValue() (any, error) {
-	p.skipWhitespace()
-
-	if p.pos >= len(p.input) {
-		return nil, fmt.Errorf("expected value at position %d", p.pos)
-	}
-
-	// String value
-	if p.peek() == '"' {
-		p.pos++
-		start := p.pos
-		for p.pos < len(p.input) && p.input[p.pos] != '"' {
-			p.pos++
-		}
-		if p.pos >= len(p.input) {
-			return nil, fmt.Errorf("unterminated string at position %d", start)
-		}
-		value := p.input[start:p.pos]
-		p.pos++ // Skip closing quote
-		return value, nil
-	}
-
-	// Boolean or null
-	if p.matchKeyword("true") {
-		p.pos += 4
-		return true, nil
-	}
-	if p.matchKeyword("false") {
-		p.pos += 5
-		return false, nil
-	}
-	if p.matchKeyword("null") {
-		p.pos += 4
-		return nil, nil
-	}
-
-	// Number
-	start := p.pos
-	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '-') {
-		p.pos++
-	}
-	if p.pos > start {
-		numStr := p.input[start:p.pos]
-		if strings.Contains(numStr, ".") {
-			return strconv.ParseFloat(numStr, 64)
+			Operator: string(e.Operator),
+			Left:     compileFilter(e.Left),
+			Right:    compileFilter(e.Right),
 		}
-		return strconv.ParseInt(numStr, 10, 64)
+	case *filter.NotExpr:
+		return &LogicalExpression{Operator: "not", Left: compileFilter(e.Expr)}
+	case *filter.GroupExpr:
+		return &GroupExpression{Filter: compileFilter(e.Expr)}
 	}
-
-	return nil, fmt.Errorf("invalid value at position %d", p.pos)
+	return nil
 }
 
 // Matches checks if an attribute expression matches a resource
 func (ae *AttributeExpression) Matches(resource any) bool {
 	value := getAttributeValue(resource, ae.AttributePath)
+	fold := ae.fold
+	if fold == nil {
+		fold = FoldCase
+	}
 
 	switch ae.Operator {
 	case "eq":
-		return compareEqual(value, ae.Value)
+		return compareEqual(value, ae.Value, fold)
 	case "ne":
-		return !compareEqual(value, ae.Value)
+		return !compareEqual(value, ae.Value, fold)
 	case "co":
-		return contains(value, ae.Value)
+		return contains(value, ae.Value, fold)
 	case "sw":
-		return startsWith(value, ae.Value)
+		return startsWith(value, ae.Value, fold)
 	case "ew":
-		return endsWith(value, ae.Value)
+		return endsWith(value, ae.Value, fold)
 	case "pr":
-		return value != nil && !isZeroValue(value)
+		return isPresent(value)
 	case "gt":
 		return compareGreater(value, ae.Value)
 	case "ge":
@@ -342,53 +133,25 @@ func (ge *GroupExpression) Matches(resource any) bool {
 	return ge.Filter.Matches(resource)
 }
 
-// Helper functions
-
-func (p *FilterParser) peek() byte {
-	if p.pos >= len(p.input) {
-		return 0
-	}
-	return p.input[p.pos]
-}
-
-func (p *FilterParser) skipWhitespace() {
-	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
-		p.pos++
-	}
-}
-
-func (p *FilterParser) matchKeyword(keyword string) bool {
-	if p.pos+len(keyword) > len(p.input) {
-		return false
-	}
-	match := strings.EqualFold(p.input[p.pos:p.pos+len(keyword)], keyword)
-	if !match {
-		return false
-	}
-	// Check that keyword is not part of a larger word
-	if p.pos+len(keyword) < len(p.input) {
-		nextChar := p.input[p.pos+len(keyword)]
-		if isAlphaNumeric(nextChar) {
-			return false
-		}
-	}
-	return true
-}
-
-func isAlphaNumeric(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_'
-}
-
-func isDigit(ch byte) bool {
-	return ch >= '0' && ch <= '9'
-}
-
 // getAttributeValue extracts a value from a resource by attribute path
 func getAttributeValue(resource any, path string) any {
 	if resource == nil {
 		return nil
 	}
 
+	// Resolve schema URN prefixes: core schema attributes live at the top level,
+	// extension attributes under the extension URN key
+	if urn, attrPath := SplitSchemaURN(path); urn != "" {
+		if isCoreSchema(urn) {
+			return getAttributeValue(resource, attrPath)
+		}
+		parts := []string{urn}
+		if attrPath != "" {
+			parts = append(parts, strings.Split(attrPath, ".")...)
+		}
+		return getAttributeValueJSON(resource, parts)
+	}
+
 	// Handle complex paths like emails[type eq "work"].value
 	if strings.Contains(path, "[") {
 		return getComplexAttributeValue(resource, path)
@@ -420,6 +183,11 @@ func getAttributeValue(resource any, path string) any {
 // getNestedAttributeValueJSON uses JSON marshaling to navigate nested paths
 // This works reliably for any JSON-serializable resource and nested paths like "meta.created"
 func getNestedAttributeValueJSON(resource any, path string) any {
+	return getAttributeValueJSON(resource, strings.Split(path, "."))
+}
+
+// getAttributeValueJSON navigates a resource's JSON representation by path segments
+func getAttributeValueJSON(resource any, parts []string) any {
 	// Marshal resource to JSON
 	data, err := json.Marshal(resource)
 	if err != nil {
@@ -433,7 +201,6 @@ func getNestedAttributeValueJSON(resource any, path string) any {
 	}
 
 	// Navigate through the path
-	parts := strings.Split(path, ".")
 	var current any = resourceMap
 
 	for _, part := range parts {
@@ -511,17 +278,28 @@ func getComplexAttributeValue(resource any, path string) any {
 	return nil
 }
 
-// findField finds a struct field by name (case-insensitive)
+// findField finds a struct field by name (case-insensitive).
+// Fields of embedded structs (e.g. CommonAttributes) are searched as well.
 func findField(v reflect.Value, name string) reflect.Value {
 	t := v.Type()
 	for i := 0; i < v.NumField(); i++ {
 		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			if embedded := findField(v.Field(i), name); embedded.IsValid() {
+				return embedded
+			}
+			continue
+		}
+		// Fields excluded from JSON, such as Extensions, are not attributes
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
 		// Check field name
 		if strings.EqualFold(field.Name, name) {
 			return v.Field(i)
 		}
 		// Check json tag
-		jsonTag := field.Tag.Get("json")
 		if jsonTag != "" {
 			jsonName := strings.Split(jsonTag, ",")[0]
 			if strings.EqualFold(jsonName, name) {
@@ -534,11 +312,21 @@ func findField(v reflect.Value, name string) reflect.Value {
 
 // Comparison functions
 
-func compareEqual(a, b any) bool {
+// compareEqual checks if a equals b, comparing strings normalized by fold
+func compareEqual(a, b any, fold func(string) string) bool {
 	if a == nil || b == nil {
 		return a == b
 	}
 
+	// An unset optional boolean is false (RFC 7643 Section 2.4), so a nil
+	// *Boolean matches "eq false"
+	if v, ok := a.(*Boolean); ok && v == nil {
+		a = Boolean(false)
+	}
+	if v, ok := b.(*Boolean); ok && v == nil {
+		b = Boolean(false)
+	}
+
 	// Dereference pointers for comparison
 	aVal := reflect.ValueOf(a)
 	bVal := reflect.ValueOf(b)
@@ -557,7 +345,7 @@ func compareEqual(a, b any) bool {
 	aStr, aIsStr := a.(string)
 	bStr, bIsStr := b.(string)
 	if aIsStr && bIsStr {
-		return strings.EqualFold(aStr, bStr)
+		return fold(aStr) == fold(bStr)
 	}
 
 	// Handle comparison between bool and custom bool types (like Boolean)
@@ -584,8 +372,8 @@ func compareEqual(a, b any) bool {
 	return reflect.DeepEqual(a, b)
 }
 
-// contains checks if string a contains string b (case-insensitive)
-func contains(a, b any) bool {
+// contains checks if string a contains string b, both normalized by fold
+func contains(a, b any, fold func(string) string) bool {
 	aStr, ok := a.(string)
 	if !ok {
 		return false
@@ -594,11 +382,11 @@ func contains(a, b any) bool {
 	if !ok {
 		return false
 	}
-	return strings.Contains(strings.ToLower(aStr), strings.ToLower(bStr))
+	return strings.Contains(fold(aStr), fold(bStr))
 }
 
-// startsWith checks if string a starts with string b (case-insensitive)
-func startsWith(a, b any) bool {
+// startsWith checks if string a starts with string b, both normalized by fold
+func startsWith(a, b any, fold func(string) string) bool {
 	aStr, ok := a.(string)
 	if !ok {
 		return false
@@ -607,11 +395,11 @@ func startsWith(a, b any) bool {
 	if !ok {
 		return false
 	}
-	return strings.HasPrefix(strings.ToLower(aStr), strings.ToLower(bStr))
+	return strings.HasPrefix(fold(aStr), fold(bStr))
 }
 
-// endsWith checks if string a ends with string b (case-insensitive)
-func endsWith(a, b any) bool {
+// endsWith checks if string a ends with string b, both normalized by fold
+func endsWith(a, b any, fold func(string) string) bool {
 	aStr, ok := a.(string)
 	if !ok {
 		return false
@@ -620,7 +408,7 @@ func endsWith(a, b any) bool {
 	if !ok {
 		return false
 	}
-	return strings.HasSuffix(strings.ToLower(aStr), strings.ToLower(bStr))
+	return strings.HasSuffix(fold(aStr), fold(bStr))
 }
 
 func compareGreater(a, b any) bool {
@@ -667,6 +455,26 @@ func toFloat64(v any) *float64 {
 	return &result
 }
 
+// isPresent reports whether a value matches "pr": it is present unless it is
+// null, an empty string, an empty array or an object without members. The
+// value is checked in its JSON form, so the in-memory evaluator agrees with
+// SQL stores checking the stored document. Only the value itself counts, so
+// an array of empty objects is present.
+func isPresent(value any) bool {
+	if value == nil {
+		return false
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	switch string(data) {
+	case "null", `""`, "[]", "{}":
+		return false
+	}
+	return true
+}
+
 func isZeroValue(v any) bool {
 	if v == nil {
 		return true
@@ -46,9 +46,11 @@ func TestFilterMatching(t *testing.T) {
 		DisplayName: "John Doe",
 		Active:      Bool(true),
 		Emails: []Email{
-			{Value: "john@example.com", Type: "work", Primary: true},
+			{Value: "john@example.com", Type: "work", Primary: Bool(true)},
 			{Value: "john@personal.com", Type: "home"},
 		},
+		PhoneNumbers: []PhoneNumber{},
+		Name:         &Name{},
 	}
 
 	tests := []struct {
@@ -66,6 +68,8 @@ func TestFilterMatching(t *testing.T) {
 		{"ew match", `userName ew "doe"`, true, false},
 		{"pr match", `emails pr`, true, false},
 		{"pr no match", `phoneNumbers pr`, false, false},
+		{"pr empty complex", `name pr`, false, false},
+		{"pr missing sub-attribute", `name.givenName pr`, false, false},
 		{"boolean eq", `active eq true`, true, false},
 		{"and true", `userName eq "john.doe" and active eq true`, true, false},
 		{"and false", `userName eq "john.doe" and active eq false`, false, false},
@@ -105,7 +109,7 @@ func TestFilterWithComplexPaths(t *testing.T) {
 	user := &User{
 		UserName: "john.doe",
 		Emails: []Email{
-			{Value: "john@work.com", Type: "work", Primary: true},
+			{Value: "john@work.com", Type: "work", Primary: Bool(true)},
 			{Value: "john@home.com", Type: "home"},
 		},
 	}
@@ -136,6 +140,47 @@ func TestFilterWithComplexPaths(t *testing.T) {
 	}
 }
 
+func TestFilterWithSchemaURNPaths(t *testing.T) {
+	user := &User{
+		UserName: "john.doe",
+		Name:     &Name{GivenName: "John"},
+		EnterpriseUser: map[string]any{
+			"department": "Engineering",
+			"manager":    map[string]any{"value": "m1"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		want   bool
+	}{
+		{"core schema attribute", `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "john.doe"`, true},
+		{"core schema sub-attribute", `urn:ietf:params:scim:schemas:core:2.0:User:name.givenName eq "John"`, true},
+		{"extension attribute", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "engineering"`, true},
+		{"extension attribute no match", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department eq "Sales"`, false},
+		{"extension sub-attribute", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value eq "m1"`, true},
+		{"extension attribute absent", `urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:costCenter pr`, false},
+		{"combined with core attribute", `userName sw "john" and urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department pr`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := NewFilterParser(tt.filter)
+			filter, err := parser.Parse()
+			if err != nil {
+				t.Errorf("Parse() error = %v", err)
+				return
+			}
+
+			got := filter.Matches(user)
+			if got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCompareEqual_BooleanType(t *testing.T) {
 	tests := []struct {
 		name string
@@ -174,13 +219,19 @@ func TestCompareEqual_BooleanType(t *testing.T) {
 		{"bool(true) == string \"True\"", true, "True", true},
 		{"bool(false) == string \"false\"", false, "false", true},
 		{"bool(false) == string \"False\"", false, "False", true},
+		// Test optional *Boolean; unset is false
+		{"*Boolean(false) == bool(false)", Bool(false), false, true},
+		{"*Boolean(true) == string \"true\"", Bool(true), "true", true},
+		{"nil *Boolean == bool(false)", (*Boolean)(nil), false, true},
+		{"nil *Boolean != bool(true)", (*Boolean)(nil), true, false},
+
 		{"Boolean(true) != int", Boolean(true), 1, false},
 		{"Boolean(true) != string \"yes\"", Boolean(true), "yes", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := compareEqual(tt.a, tt.b)
+			got := compareEqual(tt.a, tt.b, FoldCase)
 			if got != tt.want {
 				t.Errorf("compareEqual(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
 			}
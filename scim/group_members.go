@@ -0,0 +1,167 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/filter"
+)
+
+// HasMember reports whether the group has a member with value, the ID of a
+// User or Group
+func (g *Group) HasMember(value string) bool {
+	for _, member := range g.Members {
+		if member.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AddMember adds member to the group unless the group already has a member
+// with its value, and reports whether it was added
+func (g *Group) AddMember(member MemberRef) bool {
+	if member.Value == "" || g.HasMember(member.Value) {
+		return false
+	}
+	g.Members = append(g.Members, member)
+	return true
+}
+
+// RemoveMember removes the members with value from the group and reports
+// whether there were any
+func (g *Group) RemoveMember(value string) bool {
+	kept := g.Members[:0]
+	for _, member := range g.Members {
+		if member.Value != value {
+			kept = append(kept, member)
+		}
+	}
+	removed := len(kept) != len(g.Members)
+	clear(g.Members[len(kept):])
+	g.Members = kept
+	if len(g.Members) == 0 {
+		g.Members = nil
+	}
+	return removed
+}
+
+// applyMemberOperation applies the operations of identity providers on the
+// members of a group by member value, which the generic path handling would
+// apply to the whole attribute:
+//
+//   - add with path members and a member or an array of them adds those not
+//     yet members
+//   - add without path and a members value adds them the same way, besides
+//     the other attributes of the value
+//   - remove with path members[value eq "id"] removes that member, and
+//     remove with path members and a value, as Azure AD sends it, removes
+//     the members of the value instead of all members
+//   - replace with path members replaces the members, without duplicates
+//
+// It reports whether it applied op.
+func (pp *PatchProcessor) applyMemberOperation(group *Group, op PatchOperation) (bool, error) {
+	operation := strings.ToLower(op.Op)
+	if op.Path == "" {
+		if operation != "add" {
+			return false, nil
+		}
+		value, ok := op.Value.(map[string]any)
+		if !ok {
+			return false, nil
+		}
+		var members any
+		rest := make(map[string]any, len(value))
+		for name, v := range value {
+			if strings.EqualFold(name, "members") {
+				members = v
+				continue
+			}
+			rest[name] = v
+		}
+		if members == nil {
+			return false, nil
+		}
+		if err := pp.addToRoot(group, rest); err != nil {
+			return true, err
+		}
+		return true, addMembers(group, members)
+	}
+
+	path, err := filter.ParsePath(op.Path)
+	if err != nil || (path.URN != "" && path.URN != SchemaGroup) || !strings.EqualFold(path.Attribute, "members") || path.SubAttribute != "" {
+		return false, nil
+	}
+
+	if path.Filter != nil {
+		value, ok := memberValueFilter(path.Filter)
+		if !ok || operation != "remove" {
+			return false, nil
+		}
+		group.RemoveMember(value)
+		return true, nil
+	}
+
+	switch operation {
+	case "add":
+		return true, addMembers(group, op.Value)
+	case "remove":
+		if op.Value == nil {
+			group.Members = nil
+			return true, nil
+		}
+		members, err := memberRefs(op.Value)
+		if err != nil {
+			return true, err
+		}
+		for _, member := range members {
+			group.RemoveMember(member.Value)
+		}
+		return true, nil
+	case "replace":
+		group.Members = nil
+		return true, addMembers(group, op.Value)
+	}
+	return false, nil
+}
+
+// addMembers adds the members of value, a member or an array of them, to
+// group unless they are members already
+func addMembers(group *Group, value any) error {
+	members, err := memberRefs(value)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		group.AddMember(member)
+	}
+	return nil
+}
+
+// memberRefs decodes value, a member or an array of them, into MemberRefs
+func memberRefs(value any) ([]MemberRef, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var members []MemberRef
+	if err := json.Unmarshal(data, &members); err == nil {
+		return members, nil
+	}
+	var member MemberRef
+	if err := json.Unmarshal(data, &member); err != nil {
+		return nil, ErrInvalidValue("members must be members or an array of members")
+	}
+	return []MemberRef{member}, nil
+}
+
+// memberValueFilter returns the value a members value filter selects if it
+// is exactly value eq "<id>"
+func memberValueFilter(expr filter.Expr) (string, bool) {
+	attr, ok := expr.(*filter.AttributeExpr)
+	if !ok || attr.Operator != filter.Eq || !strings.EqualFold(attr.Path.Attribute, "value") || attr.Path.SubAttribute != "" || attr.Path.URN != "" {
+		return "", false
+	}
+	value, ok := attr.Value.(string)
+	return value, ok
+}
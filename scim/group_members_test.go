@@ -0,0 +1,115 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGroupAddRemoveMember(t *testing.T) {
+	group := &Group{DisplayName: "Admins"}
+
+	if !group.AddMember(MemberRef{Value: "u1"}) || !group.AddMember(MemberRef{Value: "u2"}) {
+		t.Fatal("AddMember() = false, want true for new members")
+	}
+	if group.AddMember(MemberRef{Value: "u1", Display: "Alice"}) {
+		t.Error("AddMember() of an existing member = true, want false")
+	}
+	if group.AddMember(MemberRef{}) {
+		t.Error("AddMember() without value = true, want false")
+	}
+	if !group.HasMember("u2") || group.HasMember("U2") {
+		t.Error("HasMember() should match member values exactly")
+	}
+
+	if !group.RemoveMember("u1") || group.RemoveMember("u1") {
+		t.Error("RemoveMember() should report whether the member existed")
+	}
+	if !group.RemoveMember("u2") || group.Members != nil {
+		t.Errorf("Members = %v, want nil after removing every member", group.Members)
+	}
+}
+
+func TestPatchGroupMembers_IdentityProviderPayloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []string
+		patch   string
+		want    []string
+	}{
+		{
+			name:    "azure add with array",
+			members: []string{"u1"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"Add","path":"members","value":[{"value":"u1"},{"value":"u2"},{"value":"u2"}]}]}`,
+			want: []string{"u1", "u2"},
+		},
+		{
+			name:    "azure remove with value array",
+			members: []string{"u1", "u2", "u3"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"Remove","path":"members","value":[{"value":"u1"},{"value":"u3"}]}]}`,
+			want: []string{"u2"},
+		},
+		{
+			name:    "azure remove by value filter",
+			members: []string{"u1", "u2"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"Remove","path":"members[value eq \"u2\"]"}]}`,
+			want: []string{"u1"},
+		},
+		{
+			name:    "okta add and remove",
+			members: []string{"00u1"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"remove","path":"members[value eq \"00u1\"]"},
+				{"op":"add","path":"members","value":[{"value":"00u2","display":"bob@example.com"}]}]}`,
+			want: []string{"00u2"},
+		},
+		{
+			name:    "add without path",
+			members: []string{"u1"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"add","value":{"displayName":"Renamed","members":[{"value":"u1"},{"value":"u2"}]}}]}`,
+			want: []string{"u1", "u2"},
+		},
+		{
+			name:    "replace members",
+			members: []string{"u1", "u2"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"replace","path":"urn:ietf:params:scim:schemas:core:2.0:Group:members","value":[{"value":"u3"},{"value":"u3"}]}]}`,
+			want: []string{"u3"},
+		},
+		{
+			name:    "remove all members",
+			members: []string{"u1", "u2"},
+			patch: `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[
+				{"op":"remove","path":"members"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &Group{ID: "g1", DisplayName: "Admins"}
+			for _, value := range tt.members {
+				group.AddMember(MemberRef{Value: value})
+			}
+			var patch PatchOp
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatalf("invalid patch: %v", err)
+			}
+
+			if err := NewPatchProcessor().ApplyPatch(group, &patch); err != nil {
+				t.Fatalf("ApplyPatch() error = %v", err)
+			}
+
+			var got []string
+			for _, member := range group.Members {
+				got = append(got, member.Value)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("members = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package scim
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -9,16 +8,24 @@ import (
 )
 
 const (
-	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
-	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
-	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
-	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
-	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	SchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaMembersPage    = "urn:scimgateway:params:scim:api:messages:2.0:MembersPage"
+)
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
 )
 
 // Handler handles HTTP requests and routing for SCIM endpoints
 type Handler struct {
-	baseURL string
+	baseURL  string
+	messages *MessageCatalog // translates error details, nil writes them in English
 }
 
 // NewHandler creates a new SCIM handler
@@ -28,26 +35,54 @@ func NewHandler(baseURL string) *Handler {
 	}
 }
 
-// WriteError writes a SCIM error response
+// WriteError writes a SCIM error response. The detail is translated for the
+// language the client accepts if the handler has a message catalog.
 func (h *Handler) WriteError(w http.ResponseWriter, status int, detail string, scimType string) {
-	w.Header().Set("Content-Type", "application/scim+json")
-	w.WriteHeader(status)
+	h.writeError(w, status, h.localize(w, detail, nil), scimType)
+}
 
-	err := Error{
-		Schemas:  []string{SchemaError},
-		Status:   strconv.Itoa(status),
-		Detail:   detail,
-		ScimType: scimType,
+// writeError writes a SCIM error response with a translated detail
+func (h *Handler) writeError(w http.ResponseWriter, status int, detail string, scimType string) {
+	resp := Error{
+		Schemas:   []string{SchemaError},
+		Status:    strconv.Itoa(status),
+		Detail:    detail,
+		ScimType:  scimType,
+		RequestID: w.Header().Get("X-Request-Id"),
 	}
 
-	json.NewEncoder(w).Encode(err)
+	enc := ResponseEncoder(w)
+	body, err := enc.Encode(resp)
+	if err != nil {
+		// Fall back to JSON rather than sending an error without a body
+		reportWriteFailure(w, writeFailureEncode, err)
+		enc = JSONEncoder{}
+		body, _ = enc.Encode(resp)
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType())
+	w.WriteHeader(status)
+	w.Write(body) // nolint:errcheck
 }
 
-// WriteJSON writes a successful JSON response
+// WriteJSON writes a successful response, encoded by the encoder of the
+// response (see ResponseEncoder), JSON by default. The response is encoded
+// before anything is sent, so a value that cannot be encoded results in a
+// 500 error instead of a truncated body.
 func (h *Handler) WriteJSON(w http.ResponseWriter, status int, data any) {
-	w.Header().Set("Content-Type", "application/scim+json")
+	enc := ResponseEncoder(w)
+	body, err := enc.Encode(data)
+	if err != nil {
+		reportWriteFailure(w, writeFailureEncode, err)
+		h.WriteError(w, http.StatusInternalServerError, "failed to encode response", "")
+		return
+	}
+
+	w.Header().Set("Content-Type", enc.ContentType())
 	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(data)
+	// A failed write, e.g. after the client disconnected, is reported by the
+	// server's response writer
+	w.Write(body) // nolint:errcheck
 }
 
 // ParseQueryParams extracts SCIM query parameters from the request
@@ -0,0 +1,100 @@
+package scim
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// Query parameters paging the members of a single group, e.g.
+// GET /Groups/{id}?members.startIndex=1&members.count=100. Large groups can
+// otherwise have hundreds of thousands of members in one response.
+const (
+	membersStartIndexParam = "members.startIndex"
+	membersCountParam      = "members.count"
+)
+
+// memberPaging is the members page requested by a group GET
+type memberPaging struct {
+	startIndex int // 1-based
+	count      int // -1 for all remaining members
+}
+
+// parseMemberPaging returns the members page requested by r, and false if
+// neither paging parameter is set. Like startIndex and count of list
+// requests, startIndex below 1 is treated as 1 and a negative count as 0.
+func parseMemberPaging(r *http.Request) (memberPaging, bool, error) {
+	query := r.URL.Query()
+	page := memberPaging{startIndex: 1, count: -1}
+	if !query.Has(membersStartIndexParam) && !query.Has(membersCountParam) {
+		return page, false, nil
+	}
+
+	if v := query.Get(membersStartIndexParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return page, false, ErrInvalidValue(membersStartIndexParam + " must be an integer")
+		}
+		page.startIndex = max(n, 1)
+	}
+	if v := query.Get(membersCountParam); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return page, false, ErrInvalidValue(membersCountParam + " must be an integer")
+		}
+		page.count = max(n, 0)
+	}
+	return page, true, nil
+}
+
+// returnsMembers reports whether a response selecting params includes the
+// members of a group
+func returnsMembers(params QueryParams) bool {
+	for _, attr := range params.ExcludedAttr {
+		if parent, sub := splitSelectorPath(attr); parent == "members" && sub == "" {
+			return false
+		}
+	}
+	if len(params.Attributes) == 0 {
+		return true
+	}
+	for _, attr := range params.Attributes {
+		if parent, _ := splitSelectorPath(attr); parent == "members" {
+			return true
+		}
+	}
+	return false
+}
+
+// pageMembers returns a copy of group with only the members of page. Like a
+// ListResponse describes the resources of a list, the copy describes its
+// members with totalResults, startIndex and itemsPerPage under
+// SchemaMembersPage, which it adds to its schemas. group itself is not
+// modified.
+func pageMembers(group *Group, page memberPaging) *Group {
+	total := len(group.Members)
+	start := min(page.startIndex-1, total)
+	end := total
+	if page.count >= 0 {
+		end = min(start+page.count, total)
+	}
+
+	paged := *group
+	paged.Members = group.Members[start:end:end]
+	if len(paged.Members) == 0 {
+		paged.Members = nil
+	}
+
+	paged.Extensions = maps.Clone(group.Extensions)
+	if paged.Extensions == nil {
+		paged.Extensions = make(map[string]map[string]any)
+	}
+	paged.Schemas = append(slices.Clip(group.Schemas), SchemaMembersPage)
+	paged.Extensions[SchemaMembersPage] = map[string]any{
+		"totalResults": total,
+		"startIndex":   page.startIndex,
+		"itemsPerPage": end - start,
+	}
+	return &paged
+}
@@ -0,0 +1,128 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPageMembers(t *testing.T) {
+	group := &Group{ID: "g1", DisplayName: "Eng"}
+	for i := range 5 {
+		group.Members = append(group.Members, MemberRef{Value: fmt.Sprintf("u%d", i+1)})
+	}
+
+	tests := []struct {
+		name         string
+		page         memberPaging
+		wantFirst    string
+		itemsPerPage int
+	}{
+		{"first page", memberPaging{startIndex: 1, count: 2}, "u1", 2},
+		{"last partial page", memberPaging{startIndex: 4, count: 10}, "u4", 2},
+		{"all remaining", memberPaging{startIndex: 2, count: -1}, "u2", 4},
+		{"count only", memberPaging{startIndex: 1, count: 0}, "", 0},
+		{"past the end", memberPaging{startIndex: 9, count: 2}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged := pageMembers(group, tt.page)
+
+			if len(paged.Members) != tt.itemsPerPage {
+				t.Errorf("members = %d, want %d", len(paged.Members), tt.itemsPerPage)
+			}
+			if tt.wantFirst != "" && paged.Members[0].Value != tt.wantFirst {
+				t.Errorf("first member = %s, want %s", paged.Members[0].Value, tt.wantFirst)
+			}
+			page := paged.Extensions[SchemaMembersPage]
+			if page["totalResults"] != 5 || page["itemsPerPage"] != tt.itemsPerPage || page["startIndex"] != tt.page.startIndex {
+				t.Errorf("page = %v", page)
+			}
+			if !slices.Contains(paged.Schemas, SchemaMembersPage) {
+				t.Errorf("schemas = %v, want %s", paged.Schemas, SchemaMembersPage)
+			}
+			if len(group.Members) != 5 || group.Extensions != nil || group.Schemas != nil {
+				t.Errorf("pageMembers() modified the group: %+v", group)
+			}
+		})
+	}
+}
+
+func TestServer_GetGroupMembersPage(t *testing.T) {
+	plugin := newMockPlugin()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	group := &Group{ID: "g1", DisplayName: "Eng"}
+	for i := range 250 {
+		group.Members = append(group.Members, MemberRef{Value: fmt.Sprintf("u%d", i+1)})
+	}
+	plugin.groups["g1"] = group
+
+	get := func(query string) (int, map[string]any, string) {
+		req := httptest.NewRequest(http.MethodGet, "/test/Groups/g1"+query, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body) // nolint:errcheck
+		return w.Code, body, w.Header().Get("ETag")
+	}
+
+	_, _, fullETag := get("")
+
+	code, body, etag := get("?members.startIndex=101&members.count=100")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", code, http.StatusOK)
+	}
+	members := body["members"].([]any)
+	if len(members) != 100 || members[0].(map[string]any)["value"] != "u101" {
+		t.Errorf("members = %d starting at %v, want 100 starting at u101", len(members), members[0])
+	}
+	want := map[string]any{"totalResults": 250.0, "startIndex": 101.0, "itemsPerPage": 100.0}
+	if page := body[SchemaMembersPage]; fmt.Sprint(page) != fmt.Sprint(want) {
+		t.Errorf("%s = %v, want %v", SchemaMembersPage, page, want)
+	}
+	if schemas := fmt.Sprint(body["schemas"]); !strings.Contains(schemas, SchemaMembersPage) {
+		t.Errorf("schemas = %s, want %s", schemas, SchemaMembersPage)
+	}
+	if etag != fullETag {
+		t.Errorf("ETag = %s, want the ETag of the whole group %s", etag, fullETag)
+	}
+
+	// members.count=0 returns only the member count, also with attribute selection
+	_, body, _ = get("?attributes=displayName,members&members.count=0")
+	if _, ok := body["members"]; ok {
+		t.Errorf("members returned with members.count=0: %v", body["members"])
+	}
+	if page, ok := body[SchemaMembersPage].(map[string]any); !ok || page["totalResults"] != 250.0 {
+		t.Errorf("%s = %v, want totalResults 250", SchemaMembersPage, body[SchemaMembersPage])
+	}
+	if schemas := fmt.Sprint(body["schemas"]); !strings.Contains(schemas, SchemaMembersPage) {
+		t.Errorf("schemas = %s, want %s", schemas, SchemaMembersPage)
+	}
+
+	// Responses without members describe no page
+	_, body, _ = get("?attributes=displayName&members.count=1")
+	if body[SchemaMembersPage] != nil || strings.Contains(fmt.Sprint(body["schemas"]), SchemaMembersPage) {
+		t.Errorf("GET without members returned page %v with schemas %v", body[SchemaMembersPage], body["schemas"])
+	}
+	_, body, _ = get("?attributes=members.value&members.count=1")
+	if len(body["members"].([]any)) != 1 || body[SchemaMembersPage] == nil {
+		t.Errorf("members.value returned %v and page %v, want 1 member and its page", body["members"], body[SchemaMembersPage])
+	}
+
+	// Without paging parameters all members are returned
+	_, body, _ = get("")
+	if len(body["members"].([]any)) != 250 || body[SchemaMembersPage] != nil {
+		t.Errorf("unpaged GET returned %d members and page %v", len(body["members"].([]any)), body[SchemaMembersPage])
+	}
+
+	if code, _, _ := get("?members.count=ten"); code != http.StatusBadRequest {
+		t.Errorf("invalid members.count status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
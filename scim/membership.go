@@ -0,0 +1,294 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+)
+
+// MembershipSync wraps a PluginGetter and keeps group membership consistent in both
+// directions: a group's members list and each member user's groups attribute.
+//
+// When a Group is created, patched, replaced or deleted, the referenced users get their
+// groups attribute updated accordingly. When a User is deleted, it is removed from the
+// members of every group it belonged to. This keeps plugins free of the bidirectional
+// bookkeeping that RFC 7643 expects from the service provider.
+//
+// Fan-out is best-effort: the group operation itself is authoritative, and failures
+// while updating referenced resources are logged rather than returned.
+type MembershipSync struct {
+	next   PluginGetter
+	logger *slog.Logger
+}
+
+// NewMembershipSync wraps a PluginGetter with membership fan-out.
+// Pass nil for logger to disable logging of fan-out failures.
+func NewMembershipSync(next PluginGetter, logger *slog.Logger) *MembershipSync {
+	if logger == nil {
+		logger = discardLogger()
+	}
+	return &MembershipSync{next: next, logger: logger}
+}
+
+// Unwrap returns the wrapped PluginGetter
+func (m *MembershipSync) Unwrap() any {
+	return m.next
+}
+
+// GetUsers implements PluginGetter
+func (m *MembershipSync) GetUsers(ctx context.Context, params QueryParams) (*ListResponse[*User], error) {
+	return m.next.GetUsers(ctx, params)
+}
+
+// CreateUser implements PluginGetter
+func (m *MembershipSync) CreateUser(ctx context.Context, user *User) (*User, error) {
+	return m.next.CreateUser(ctx, user)
+}
+
+// GetUser implements PluginGetter
+func (m *MembershipSync) GetUser(ctx context.Context, id string, attributes []string) (*User, error) {
+	return m.next.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements PluginGetter
+func (m *MembershipSync) ModifyUser(ctx context.Context, id string, patch *PatchOp) error {
+	return m.next.ModifyUser(ctx, id, patch)
+}
+
+// DeleteUser implements PluginGetter and removes the user from all groups it belonged to
+func (m *MembershipSync) DeleteUser(ctx context.Context, id string) error {
+	// Capture memberships before the user disappears
+	var groupIDs []string
+	if user, err := m.next.GetUser(ctx, id, nil); err == nil && user != nil {
+		for _, ref := range user.Groups {
+			groupIDs = append(groupIDs, ref.Value)
+		}
+	}
+
+	if err := m.next.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	for _, groupID := range groupIDs {
+		patch := &PatchOp{
+			Schemas:    []string{SchemaPatchOp},
+			Operations: []PatchOperation{{Op: "remove", Path: fmt.Sprintf("members[value eq %q]", id)}},
+		}
+		if err := m.next.ModifyGroup(ctx, groupID, patch); err != nil {
+			m.logger.WarnContext(ctx, "membership sync: failed to remove deleted user from group",
+				"user_id", id,
+				"group_id", groupID,
+				"error", err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// GetGroups implements PluginGetter
+func (m *MembershipSync) GetGroups(ctx context.Context, params QueryParams) (*ListResponse[*Group], error) {
+	return m.next.GetGroups(ctx, params)
+}
+
+// CreateGroup implements PluginGetter and adds the new group to each member's groups
+func (m *MembershipSync) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	created, err := m.next.CreateGroup(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	m.syncMembers(ctx, nil, created)
+	return created, nil
+}
+
+// GetGroup implements PluginGetter
+func (m *MembershipSync) GetGroup(ctx context.Context, id string, attributes []string) (*Group, error) {
+	return m.next.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements PluginGetter and propagates member additions and removals
+func (m *MembershipSync) ModifyGroup(ctx context.Context, id string, patch *PatchOp) error {
+	before, err := m.snapshotGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.next.ModifyGroup(ctx, id, patch); err != nil {
+		return err
+	}
+
+	after, err := m.snapshotGroup(ctx, id)
+	if err != nil {
+		m.logger.WarnContext(ctx, "membership sync: failed to read group after modification",
+			"group_id", id,
+			"error", err,
+		)
+		return nil
+	}
+
+	m.syncMembers(ctx, before, after)
+	return nil
+}
+
+// ModifyGroupResult implements ModifyGroupResult and propagates member
+// additions and removals, comparing against the group the plugin returned
+func (m *MembershipSync) ModifyGroupResult(ctx context.Context, id string, patch *PatchOp) (*Group, error) {
+	before, err := m.snapshotGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	modified, err := ModifyGroup(ctx, m.next, id, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	m.syncMembers(ctx, before, modified)
+	return modified, nil
+}
+
+// DeleteGroup implements PluginGetter and removes the group from each member's groups
+func (m *MembershipSync) DeleteGroup(ctx context.Context, id string) error {
+	before, err := m.snapshotGroup(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := m.next.DeleteGroup(ctx, id); err != nil {
+		return err
+	}
+
+	m.syncMembers(ctx, before, nil)
+	return nil
+}
+
+// ReplaceUser implements UserReplacer. Replacing a user keeps its group
+// memberships, so nothing is synced.
+func (m *MembershipSync) ReplaceUser(ctx context.Context, id string, user *User) (*User, error) {
+	return ReplaceUser(ctx, m.next, id, user)
+}
+
+// ModifyUserResult implements ModifyUserResult. Modifying a user keeps its
+// group memberships, so nothing is synced.
+func (m *MembershipSync) ModifyUserResult(ctx context.Context, id string, patch *PatchOp) (*User, error) {
+	return ModifyUser(ctx, m.next, id, patch)
+}
+
+// ReplaceGroup implements GroupReplacer and propagates member additions and removals
+func (m *MembershipSync) ReplaceGroup(ctx context.Context, id string, group *Group) (*Group, error) {
+	before, err := m.snapshotGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	replaced, err := ReplaceGroup(ctx, m.next, id, group)
+	if err != nil {
+		return nil, err
+	}
+
+	m.syncMembers(ctx, before, replaced)
+	return replaced, nil
+}
+
+// snapshotGroup fetches a group and copies its members, since plugins may return
+// pointers to their internal storage that are mutated in place by later calls
+func (m *MembershipSync) snapshotGroup(ctx context.Context, id string) (*Group, error) {
+	group, err := m.next.GetGroup(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *group
+	snapshot.Members = slices.Clone(group.Members)
+	return &snapshot, nil
+}
+
+// syncMembers updates the groups attribute of users whose membership changed between
+// the before and after states of a group. A nil state means the group did not exist.
+func (m *MembershipSync) syncMembers(ctx context.Context, before, after *Group) {
+	previous := userMembers(before)
+	current := userMembers(after)
+
+	renamed := before != nil && after != nil && before.DisplayName != after.DisplayName
+
+	for userID := range current {
+		if previous[userID] && !renamed {
+			continue
+		}
+		if previous[userID] {
+			// Display name changed: drop the stale reference before re-adding it
+			m.removeGroupRef(ctx, userID, after.ID)
+		}
+		m.addGroupRef(ctx, userID, after)
+	}
+
+	for userID := range previous {
+		if !current[userID] {
+			m.removeGroupRef(ctx, userID, before.ID)
+		}
+	}
+}
+
+// addGroupRef adds a direct group reference to a user's groups attribute,
+// skipping users that already reference the group with the same display name
+func (m *MembershipSync) addGroupRef(ctx context.Context, userID string, group *Group) {
+	if user, err := m.next.GetUser(ctx, userID, nil); err == nil && user != nil {
+		for _, ref := range user.Groups {
+			if ref.Value == group.ID && ref.Display == group.DisplayName {
+				return
+			}
+		}
+	}
+
+	patch := &PatchOp{
+		Schemas: []string{SchemaPatchOp},
+		Operations: []PatchOperation{{
+			Op:   "add",
+			Path: "groups",
+			Value: []GroupRef{{
+				Value:   group.ID,
+				Display: group.DisplayName,
+				Type:    "direct",
+			}},
+		}},
+	}
+	if err := m.next.ModifyUser(ctx, userID, patch); err != nil {
+		m.logger.WarnContext(ctx, "membership sync: failed to add group to user",
+			"user_id", userID,
+			"group_id", group.ID,
+			"error", err,
+		)
+	}
+}
+
+// removeGroupRef removes a group reference from a user's groups attribute
+func (m *MembershipSync) removeGroupRef(ctx context.Context, userID, groupID string) {
+	patch := &PatchOp{
+		Schemas:    []string{SchemaPatchOp},
+		Operations: []PatchOperation{{Op: "remove", Path: fmt.Sprintf("groups[value eq %q]", groupID)}},
+	}
+	if err := m.next.ModifyUser(ctx, userID, patch); err != nil {
+		m.logger.WarnContext(ctx, "membership sync: failed to remove group from user",
+			"user_id", userID,
+			"group_id", groupID,
+			"error", err,
+		)
+	}
+}
+
+// userMembers returns the set of member IDs in a group that reference users.
+// Members without a type are treated as users, as most IdPs omit it.
+func userMembers(group *Group) map[string]bool {
+	members := make(map[string]bool)
+	if group == nil {
+		return members
+	}
+	for _, member := range group.Members {
+		if member.Value == "" || (member.Type != "" && member.Type != "User") {
+			continue
+		}
+		members[member.Value] = true
+	}
+	return members
+}
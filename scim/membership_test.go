@@ -0,0 +1,191 @@
+package scim
+
+import (
+	"context"
+	"testing"
+)
+
+func newMembershipFixture(t *testing.T) (*mockPlugin, *MembershipSync) {
+	t.Helper()
+	mock := newMockPlugin()
+	for _, id := range []string{"u1", "u2", "u3"} {
+		if _, err := mock.CreateUser(context.Background(), &User{ID: id, UserName: id}); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", id, err)
+		}
+	}
+	return mock, NewMembershipSync(mock, nil)
+}
+
+func groupRefs(t *testing.T, mock *mockPlugin, userID string) []GroupRef {
+	t.Helper()
+	user, err := mock.GetUser(context.Background(), userID, nil)
+	if err != nil {
+		t.Fatalf("GetUser(%s) error = %v", userID, err)
+	}
+	return user.Groups
+}
+
+func TestMembershipSyncCreateGroup(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{
+		DisplayName: "Engineering",
+		Members:     []MemberRef{{Value: "u1"}, {Value: "u2", Type: "User"}, {Value: "g-nested", Type: "Group"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	for _, id := range []string{"u1", "u2"} {
+		refs := groupRefs(t, mock, id)
+		if len(refs) != 1 {
+			t.Fatalf("user %s: expected 1 group ref, got %d", id, len(refs))
+		}
+		if refs[0].Value != group.ID || refs[0].Display != "Engineering" || refs[0].Type != "direct" {
+			t.Errorf("user %s: unexpected group ref %+v", id, refs[0])
+		}
+	}
+
+	if refs := groupRefs(t, mock, "u3"); len(refs) != 0 {
+		t.Errorf("non-member u3 should have no group refs, got %v", refs)
+	}
+}
+
+func TestMembershipSyncModifyGroup(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{
+		DisplayName: "Engineering",
+		Members:     []MemberRef{{Value: "u1"}, {Value: "u2"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	patch := &PatchOp{
+		Schemas: []string{SchemaPatchOp},
+		Operations: []PatchOperation{
+			{Op: "remove", Path: `members[value eq "u1"]`},
+			{Op: "add", Path: "members", Value: []any{map[string]any{"value": "u3"}}},
+		},
+	}
+	if err := sync.ModifyGroup(ctx, group.ID, patch); err != nil {
+		t.Fatalf("ModifyGroup() error = %v", err)
+	}
+
+	if refs := groupRefs(t, mock, "u1"); len(refs) != 0 {
+		t.Errorf("removed member u1 should have no group refs, got %v", refs)
+	}
+	if refs := groupRefs(t, mock, "u2"); len(refs) != 1 {
+		t.Errorf("unchanged member u2 should keep exactly 1 group ref, got %v", refs)
+	}
+	if refs := groupRefs(t, mock, "u3"); len(refs) != 1 || refs[0].Value != group.ID {
+		t.Errorf("added member u3 should reference group, got %v", refs)
+	}
+}
+
+func TestMembershipSyncRenameGroup(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{DisplayName: "Eng", Members: []MemberRef{{Value: "u1"}}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	patch := &PatchOp{
+		Schemas:    []string{SchemaPatchOp},
+		Operations: []PatchOperation{{Op: "replace", Path: "displayName", Value: "Engineering"}},
+	}
+	if err := sync.ModifyGroup(ctx, group.ID, patch); err != nil {
+		t.Fatalf("ModifyGroup() error = %v", err)
+	}
+
+	refs := groupRefs(t, mock, "u1")
+	if len(refs) != 1 || refs[0].Display != "Engineering" {
+		t.Errorf("expected single ref with updated display, got %v", refs)
+	}
+}
+
+func TestMembershipSyncReplaceGroup(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{DisplayName: "Eng", Members: []MemberRef{{Value: "u1"}, {Value: "u2"}}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	replaced, err := sync.ReplaceGroup(ctx, group.ID, &Group{DisplayName: "Eng", Members: []MemberRef{{Value: "u2"}, {Value: "u3"}}})
+	if err != nil {
+		t.Fatalf("ReplaceGroup() error = %v", err)
+	}
+	if replaced.ID != group.ID {
+		t.Errorf("replaced group ID = %q, want %q", replaced.ID, group.ID)
+	}
+
+	if refs := groupRefs(t, mock, "u1"); len(refs) != 0 {
+		t.Errorf("removed member u1 should have no group refs, got %v", refs)
+	}
+	for _, id := range []string{"u2", "u3"} {
+		if refs := groupRefs(t, mock, id); len(refs) != 1 || refs[0].Value != group.ID {
+			t.Errorf("member %s should reference group once, got %v", id, refs)
+		}
+	}
+}
+
+func TestMembershipSyncDeleteGroup(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{DisplayName: "Eng", Members: []MemberRef{{Value: "u1"}, {Value: "u2"}}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	if err := sync.DeleteGroup(ctx, group.ID); err != nil {
+		t.Fatalf("DeleteGroup() error = %v", err)
+	}
+
+	for _, id := range []string{"u1", "u2"} {
+		if refs := groupRefs(t, mock, id); len(refs) != 0 {
+			t.Errorf("user %s should have no group refs after group deletion, got %v", id, refs)
+		}
+	}
+}
+
+func TestMembershipSyncDeleteUser(t *testing.T) {
+	mock, sync := newMembershipFixture(t)
+	ctx := context.Background()
+
+	group, err := sync.CreateGroup(ctx, &Group{DisplayName: "Eng", Members: []MemberRef{{Value: "u1"}, {Value: "u2"}}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+
+	if err := sync.DeleteUser(ctx, "u1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	stored, err := mock.GetGroup(ctx, group.ID, nil)
+	if err != nil {
+		t.Fatalf("GetGroup() error = %v", err)
+	}
+	if len(stored.Members) != 1 || stored.Members[0].Value != "u2" {
+		t.Errorf("expected only u2 to remain a member, got %v", stored.Members)
+	}
+}
+
+func TestMembershipSyncUnknownMemberDoesNotFail(t *testing.T) {
+	_, sync := newMembershipFixture(t)
+
+	_, err := sync.CreateGroup(context.Background(), &Group{
+		DisplayName: "Eng",
+		Members:     []MemberRef{{Value: "missing-user"}},
+	})
+	if err != nil {
+		t.Errorf("CreateGroup() should not fail when a member cannot be updated, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SourceLocale is the language error details are written in. Requests
+// preferring it are answered without translation.
+const SourceLocale = "en"
+
+// MessageCatalog translates the detail of error responses into the language
+// a client prefers in its Accept-Language header. The status and scimType of
+// errors are never translated, so clients can keep dispatching on them.
+//
+// Translations are keyed by the English message, or by its format string for
+// errors created with NewSCIMErrorf, such as ErrNotFound:
+//
+//	catalog := scim.NewMessageCatalog()
+//	catalog.Add("de", map[string]string{
+//	    "%s %s not found": "%s %s nicht gefunden",
+//	    "Invalid JSON":    "Ungültiges JSON",
+//	})
+//
+// Translated formats may reorder their arguments with explicit indexes, such
+// as "%[2]s: %[1]s". Details without a translation are returned in English.
+// MessageCatalog is safe for concurrent use.
+type MessageCatalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string // locale -> English message -> translation
+}
+
+// NewMessageCatalog creates an empty message catalog
+func NewMessageCatalog() *MessageCatalog {
+	return &MessageCatalog{messages: make(map[string]map[string]string)}
+}
+
+// Add adds translations for a locale such as "de" or "pt-BR", replacing
+// existing translations of the same messages
+func (c *MessageCatalog) Add(locale string, messages map[string]string) {
+	locale = strings.ToLower(locale)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]string, len(messages))
+	}
+	for message, translation := range messages {
+		c.messages[locale][message] = translation
+	}
+}
+
+// Locales returns the locales the catalog has translations for, sorted
+func (c *MessageCatalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := make([]string, 0, len(c.messages))
+	for locale := range c.messages {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+// Localize formats message in the most preferred language of an
+// Accept-Language header that has a translation for it. It returns the
+// locale of the translation, or "" if the English message was used.
+func (c *MessageCatalog) Localize(acceptLanguage, message string, args ...any) (detail, locale string) {
+	if c != nil {
+		c.mu.RLock()
+		translation, locale, ok := c.lookup(acceptLanguage, message)
+		c.mu.RUnlock()
+		if ok {
+			return sprintf(translation, args), locale
+		}
+	}
+	return sprintf(message, args), ""
+}
+
+// lookup finds the translation of message for the preferred languages of an
+// Accept-Language header. Callers must hold c.mu.
+func (c *MessageCatalog) lookup(acceptLanguage, message string) (string, string, bool) {
+	for _, tag := range preferredLanguages(acceptLanguage) {
+		if tag == "*" {
+			break
+		}
+		// Try the full tag, then its primary language: de-CH falls back to de
+		for _, locale := range []string{tag, primaryLanguage(tag)} {
+			if locale == SourceLocale {
+				return "", "", false
+			}
+			if translation, ok := c.messages[locale][message]; ok {
+				return translation, locale, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// sprintf formats a message, leaving messages without arguments as they are
+// so that details containing % are not mangled
+func sprintf(format string, args []any) string {
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// primaryLanguage returns the primary language subtag of a language tag
+func primaryLanguage(tag string) string {
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		return tag[:i]
+	}
+	return tag
+}
+
+// preferredLanguages returns the lowercase language tags of an
+// Accept-Language header (RFC 9110 Section 12.5.4), most preferred first.
+// Tags with a quality of 0 are left out.
+func preferredLanguages(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+
+	var ranges []weighted
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			v, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = v
+		}
+		if quality <= 0 {
+			continue
+		}
+		ranges = append(ranges, weighted{tag, quality})
+	}
+
+	// Equally weighted tags keep their order
+	slices.SortStableFunc(ranges, func(a, b weighted) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		}
+		return 0
+	})
+
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
+
+// localize translates the detail of an error response written to w for the
+// language of its request. The Content-Language header is set when the
+// detail was translated.
+func (h *Handler) localize(w http.ResponseWriter, message string, args []any) string {
+	rw, ok := w.(*responseWriter)
+	if !ok || h.messages == nil {
+		return sprintf(message, args)
+	}
+	detail, locale := h.messages.Localize(rw.request.Header.Get("Accept-Language"), message, args...)
+	w.Header().Add("Vary", "Accept-Language")
+	if locale != "" {
+		w.Header().Set("Content-Language", locale)
+	}
+	return detail
+}
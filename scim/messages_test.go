@@ -0,0 +1,157 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreferredLanguages(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"de", []string{"de"}},
+		{"de-CH, fr;q=0.8, en;q=0.9", []string{"de-ch", "en", "fr"}},
+		{"fr;q=0.5, de;q=0.5", []string{"fr", "de"}},
+		{"de;q=0, en", []string{"en"}},
+		{"de;q=abc, en", []string{"en"}},
+		{"*;q=0.1, pt-BR", []string{"pt-br", "*"}},
+	}
+
+	for _, tt := range tests {
+		if got := preferredLanguages(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preferredLanguages(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessageCatalogLocalize(t *testing.T) {
+	catalog := NewMessageCatalog()
+	catalog.Add("de", map[string]string{"%s %s not found": "%s %s nicht gefunden"})
+	catalog.Add("fr", map[string]string{"%s %s not found": "%[2]s : %[1]s introuvable"})
+	catalog.Add("pt-BR", map[string]string{"Invalid JSON": "JSON inválido"})
+
+	tests := []struct {
+		acceptLanguage string
+		message        string
+		wantDetail     string
+		wantLocale     string
+	}{
+		{"", "%s %s not found", "User 42 not found", ""},
+		{"de", "%s %s not found", "User 42 nicht gefunden", "de"},
+		{"de-CH", "%s %s not found", "User 42 nicht gefunden", "de"},
+		{"fr", "%s %s not found", "42 : User introuvable", "fr"},
+		{"it, de;q=0.5", "%s %s not found", "User 42 nicht gefunden", "de"},
+		// English is preferred over a translation
+		{"en, de;q=0.5", "%s %s not found", "User 42 not found", ""},
+		{"*, de;q=0.5", "%s %s not found", "User 42 not found", ""},
+		{"pt-br", "Invalid JSON", "JSON inválido", "pt-br"},
+		{"pt", "Invalid JSON", "Invalid JSON", ""},
+		{"de", "Invalid JSON", "Invalid JSON", ""},
+	}
+
+	for _, tt := range tests {
+		var args []any
+		if strings.Contains(tt.message, "%s") {
+			args = []any{"User", "42"}
+		}
+		detail, locale := catalog.Localize(tt.acceptLanguage, tt.message, args...)
+		if detail != tt.wantDetail || locale != tt.wantLocale {
+			t.Errorf("Localize(%q, %q) = %q, %q; want %q, %q", tt.acceptLanguage, tt.message, detail, locale, tt.wantDetail, tt.wantLocale)
+		}
+	}
+
+	if got := catalog.Locales(); !reflect.DeepEqual(got, []string{"de", "fr", "pt-br"}) {
+		t.Errorf("Locales() = %v", got)
+	}
+
+	// A nil catalog writes messages in English
+	var none *MessageCatalog
+	if detail, locale := none.Localize("de", "%s %s not found", "User", "42"); detail != "User 42 not found" || locale != "" {
+		t.Errorf("nil Localize() = %q, %q", detail, locale)
+	}
+}
+
+func TestServer_LocalizedErrors(t *testing.T) {
+	catalog := NewMessageCatalog()
+	catalog.Add("de", map[string]string{
+		"Plugin '%s' not found": "Plugin '%s' nicht gefunden",
+		"Invalid JSON":          "Ungültiges JSON",
+	})
+
+	tests := []struct {
+		name           string
+		manager        *mockPluginManager
+		method, path   string
+		body           string
+		acceptLanguage string
+		wantStatus     int
+		wantScimType   string
+		wantDetail     string
+		wantLanguage   string
+	}{
+		{
+			name:    "formatted error",
+			manager: &mockPluginManager{},
+			method:  http.MethodGet, path: "/acme/Users",
+			acceptLanguage: "de-DE,de;q=0.9",
+			wantStatus:     http.StatusNotFound,
+			wantScimType:   ScimTypeInvalidPath,
+			wantDetail:     "Plugin 'acme' nicht gefunden",
+			wantLanguage:   "de",
+		},
+		{
+			name:    "literal error",
+			manager: &mockPluginManager{plugin: newMockPlugin()},
+			method:  http.MethodPost, path: "/test/Users", body: "{",
+			acceptLanguage: "de",
+			wantStatus:     http.StatusBadRequest,
+			wantScimType:   ScimTypeInvalidSyntax,
+			wantDetail:     "Ungültiges JSON",
+			wantLanguage:   "de",
+		},
+		{
+			name:    "no translation",
+			manager: &mockPluginManager{},
+			method:  http.MethodGet, path: "/acme/Users",
+			acceptLanguage: "ja",
+			wantStatus:     http.StatusNotFound,
+			wantScimType:   ScimTypeInvalidPath,
+			wantDetail:     "Plugin 'acme' not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer("http://localhost:8080", tt.manager)
+			srv.SetMessageCatalog(catalog)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var scimErr Error
+			if err := json.Unmarshal(w.Body.Bytes(), &scimErr); err != nil {
+				t.Fatal(err)
+			}
+			if scimErr.Detail != tt.wantDetail || scimErr.ScimType != tt.wantScimType {
+				t.Errorf("error = %+v, want detail %q and scimType %q", scimErr, tt.wantDetail, tt.wantScimType)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLanguage)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("Vary = %q, want Accept-Language", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package scim
+
+// EnsureResourceType sets meta.resourceType to the given resource type name,
+// overriding whatever the plugin provided, and returns the (possibly new) Meta.
+//
+// Plugins that copy structs between resource types can leave a stale or empty
+// resourceType behind, which confuses IdPs that dispatch on it.
+func EnsureResourceType(meta *Meta, resourceType string) *Meta {
+	if meta == nil {
+		meta = &Meta{}
+	}
+	meta.ResourceType = resourceType
+	return meta
+}
@@ -0,0 +1,113 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnsureResourceType(t *testing.T) {
+	if meta := EnsureResourceType(nil, ResourceTypeUser); meta == nil || meta.ResourceType != ResourceTypeUser {
+		t.Errorf("EnsureResourceType(nil) = %+v, want new Meta with resourceType User", meta)
+	}
+
+	existing := &Meta{ResourceType: "Group", Version: `W/"1"`}
+	meta := EnsureResourceType(existing, ResourceTypeUser)
+	if meta != existing {
+		t.Error("EnsureResourceType() should update the existing Meta in place")
+	}
+	if meta.ResourceType != ResourceTypeUser || meta.Version != `W/"1"` {
+		t.Errorf("EnsureResourceType() = %+v, want resourceType overridden and other fields kept", meta)
+	}
+}
+
+func TestServerNormalizesResourceType(t *testing.T) {
+	plugin := newMockPlugin()
+	pm := &mockPluginManager{plugin: plugin}
+	srv := NewServer("http://localhost:8080", pm)
+
+	// Plugin copied meta from a group onto a user, and left group meta empty
+	plugin.users["u1"] = &User{ID: "u1", UserName: "jdoe", Meta: &Meta{ResourceType: "Group"}}
+	plugin.groups["g1"] = &Group{ID: "g1", DisplayName: "Eng"}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/test/Users/u1", ResourceTypeUser},
+		{"/test/Users", ResourceTypeUser},
+		{"/test/Groups/g1", ResourceTypeGroup},
+		{"/test/Groups", ResourceTypeGroup},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want %d", tt.path, w.Code, http.StatusOK)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		resource := body
+		if resources, ok := body["Resources"].([]any); ok {
+			resource = resources[0].(map[string]any)
+		}
+
+		meta, _ := resource["meta"].(map[string]any)
+		if meta["resourceType"] != tt.want {
+			t.Errorf("GET %s meta.resourceType = %v, want %s", tt.path, meta["resourceType"], tt.want)
+		}
+	}
+}
+
+func TestServerRejectsMismatchedResourceType(t *testing.T) {
+	pm := &mockPluginManager{plugin: newMockPlugin()}
+	srv := NewServer("http://localhost:8080", pm)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{
+			name: "user with group resourceType",
+			path: "/test/Users",
+			body: `{"schemas":["` + SchemaUser + `"],"userName":"jdoe","meta":{"resourceType":"Group"}}`,
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "user with matching resourceType",
+			path: "/test/Users",
+			body: `{"schemas":["` + SchemaUser + `"],"userName":"jdoe","meta":{"resourceType":"User"}}`,
+			want: http.StatusCreated,
+		},
+		{
+			name: "group with user resourceType",
+			path: "/test/Groups",
+			body: `{"schemas":["` + SchemaGroup + `"],"displayName":"Eng","meta":{"resourceType":"User"}}`,
+			want: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/scim+json")
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("POST %s status = %d, want %d: %s", tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package scim
+
+import "context"
+
+// ModifyUserResult is an optional interface for plugins that return the
+// user a PATCH request modified. The server prefers it over ModifyUser,
+// which leaves it to fetch the modified user with GetUser for the response.
+// ModifyUserResult returns the user as GetUser would, with all attributes.
+//
+// The server discovers the interface through wrappers such as the plugin
+// adapter, so plugin.Plugin implementations can implement it directly.
+type ModifyUserResult interface {
+	ModifyUserResult(ctx context.Context, id string, patch *PatchOp) (*User, error)
+}
+
+// ModifyGroupResult is the Group counterpart of ModifyUserResult
+type ModifyGroupResult interface {
+	ModifyGroupResult(ctx context.Context, id string, patch *PatchOp) (*Group, error)
+}
+
+// ModifyUser applies patch to the user id of plugin and returns the
+// modified user, through its ModifyUserResult if it has one and by fetching
+// the user after modifying it otherwise. Wrappers of PluginGetter use it to
+// forward modifications.
+func ModifyUser(ctx context.Context, plugin PluginGetter, id string, patch *PatchOp) (*User, error) {
+	if modifier, ok := lookupCapability[ModifyUserResult](plugin); ok {
+		return modifier.ModifyUserResult(ctx, id, patch)
+	}
+	if err := plugin.ModifyUser(ctx, id, patch); err != nil {
+		return nil, err
+	}
+	return plugin.GetUser(ctx, id, nil)
+}
+
+// ModifyGroup is the Group counterpart of ModifyUser
+func ModifyGroup(ctx context.Context, plugin PluginGetter, id string, patch *PatchOp) (*Group, error) {
+	if modifier, ok := lookupCapability[ModifyGroupResult](plugin); ok {
+		return modifier.ModifyGroupResult(ctx, id, patch)
+	}
+	if err := plugin.ModifyGroup(ctx, id, patch); err != nil {
+		return nil, err
+	}
+	return plugin.GetGroup(ctx, id, nil)
+}
@@ -0,0 +1,147 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// resultPlugin is a mockPlugin that returns the users and groups it
+// modifies and counts the users it is asked for
+type resultPlugin struct {
+	*mockPlugin
+	gets     int
+	modified int
+}
+
+func (p *resultPlugin) GetUser(ctx context.Context, id string, attributes []string) (*User, error) {
+	p.gets++
+	return p.mockPlugin.GetUser(ctx, id, attributes)
+}
+
+func (p *resultPlugin) ModifyUserResult(ctx context.Context, id string, patch *PatchOp) (*User, error) {
+	p.modified++
+	if err := p.mockPlugin.ModifyUser(ctx, id, patch); err != nil {
+		return nil, ErrNotFound("User", id)
+	}
+	return p.mockPlugin.GetUser(ctx, id, nil)
+}
+
+func (p *resultPlugin) ModifyGroupResult(ctx context.Context, id string, patch *PatchOp) (*Group, error) {
+	p.modified++
+	if err := p.mockPlugin.ModifyGroup(ctx, id, patch); err != nil {
+		return nil, ErrNotFound("Group", id)
+	}
+	return p.mockPlugin.GetGroup(ctx, id, nil)
+}
+
+func writeResource(t *testing.T, plugin PluginGetter, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+const renamePatch = `{"schemas":["` + SchemaPatchOp + `"],"Operations":[{"op":"replace","path":"displayName","value":"Alice"}]}`
+
+func TestModifyUserWithoutPreconditions(t *testing.T) {
+	p := &resultPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+
+	// The plugin is found behind wrappers such as the plugin adapter
+	plugin := &unwrappingPlugin{PluginGetter: p, inner: p}
+	w := writeResource(t, plugin, http.MethodPatch, "/test/Users/u1", renamePatch, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if p.modified != 1 || p.gets != 0 {
+		t.Errorf("modified = %d, gets = %d, want a single modify", p.modified, p.gets)
+	}
+
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if user.DisplayName != "Alice" {
+		t.Errorf("displayName = %q, want Alice", user.DisplayName)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("response has no ETag")
+	}
+}
+
+func TestModifyUserWithIfMatch(t *testing.T) {
+	p := &resultPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+
+	w := writeResource(t, p, http.MethodPatch, "/test/Users/u1", renamePatch, http.Header{"If-Match": {`W/"stale"`}})
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412: %s", w.Code, w.Body.String())
+	}
+	if p.gets != 1 || p.modified != 0 {
+		t.Errorf("gets = %d, modified = %d, want the precondition checked only", p.gets, p.modified)
+	}
+}
+
+func TestModifyUserProtectedAttributes(t *testing.T) {
+	p := &resultPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+
+	// Writes to readOnly attributes are checked against the current user
+	body := `{"schemas":["` + SchemaPatchOp + `"],"Operations":[{"op":"replace","path":"id","value":"u2"}]}`
+	w := writeResource(t, p, http.MethodPatch, "/test/Users/u1", body, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+	}
+	if p.gets != 1 || p.modified != 0 {
+		t.Errorf("gets = %d, modified = %d, want the user fetched for the check", p.gets, p.modified)
+	}
+}
+
+func TestModifyGroupFallback(t *testing.T) {
+	mock := newMockPlugin()
+	mock.CreateGroup(context.Background(), &Group{ID: "g1", DisplayName: "Admins"}) // nolint:errcheck
+
+	patch := &PatchOp{
+		Schemas:    []string{SchemaPatchOp},
+		Operations: []PatchOperation{{Op: "replace", Path: "displayName", Value: "Operators"}},
+	}
+	group, err := ModifyGroup(context.Background(), mock, "g1", patch)
+	if err != nil {
+		t.Fatalf("ModifyGroup() error = %v", err)
+	}
+	if group.DisplayName != "Operators" {
+		t.Errorf("displayName = %q, want Operators", group.DisplayName)
+	}
+
+	if _, err := ModifyGroup(context.Background(), mock, "missing", patch); err == nil {
+		t.Error("ModifyGroup() of a missing group succeeded")
+	}
+}
+
+func TestDeleteUserWithoutPreconditions(t *testing.T) {
+	p := &resultPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+
+	w := writeResource(t, p, http.MethodDelete, "/test/Users/u1", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204: %s", w.Code, w.Body.String())
+	}
+	if p.gets != 0 {
+		t.Errorf("gets = %d, want no precondition fetch", p.gets)
+	}
+
+	w = writeResource(t, p, http.MethodDelete, "/test/Users/u1", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d for a missing user, want 404", w.Code)
+	}
+}
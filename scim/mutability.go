@@ -0,0 +1,493 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Attribute mutability values (RFC 7643 Section 2.2)
+const (
+	MutabilityReadOnly  = "readOnly"
+	MutabilityReadWrite = "readWrite"
+	MutabilityImmutable = "immutable"
+	MutabilityWriteOnly = "writeOnly"
+)
+
+// mutabilities are the valid attribute mutability values
+var mutabilities = []string{MutabilityReadOnly, MutabilityReadWrite, MutabilityImmutable, MutabilityWriteOnly}
+
+// defaultMutability holds the attributes of the built-in resource types that
+// are not readWrite, keyed by resource type and lower-case attribute path.
+// Group memberships of a user are managed through the members of groups.
+var defaultMutability = map[string]map[string]string{
+	ResourceTypeUser: {
+		"id":       MutabilityReadOnly,
+		"meta":     MutabilityReadOnly,
+		"groups":   MutabilityReadOnly,
+		"password": MutabilityWriteOnly,
+	},
+	ResourceTypeGroup: {
+		"id":   MutabilityReadOnly,
+		"meta": MutabilityReadOnly,
+	},
+}
+
+// SetMutability overrides the mutability of a User or Group attribute.
+// attribute is an attribute path such as "userName", "name.givenName" or
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber";
+// the mutability of an attribute applies to its sub-attributes unless they
+// are overridden themselves. Overrides take precedence over the built-in
+// defaults (id, meta and User groups are readOnly) and over the mutability
+// of registered extension attribute definitions.
+func (r *SchemaRegistry) SetMutability(resourceType, attribute, mutability string) error {
+	if resourceType != ResourceTypeUser && resourceType != ResourceTypeGroup {
+		return fmt.Errorf("mutability resource type must be %s or %s, got %q",
+			ResourceTypeUser, ResourceTypeGroup, resourceType)
+	}
+	if attribute == "" {
+		return errors.New("mutability requires an attribute")
+	}
+	if !slices.Contains(mutabilities, mutability) {
+		return fmt.Errorf("attribute %s has unknown mutability %q", attribute, mutability)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mutability == nil {
+		r.mutability = make(map[string]map[string]string)
+	}
+	if r.mutability[resourceType] == nil {
+		r.mutability[resourceType] = make(map[string]string)
+	}
+	r.mutability[resourceType][strings.ToLower(attribute)] = mutability
+	return nil
+}
+
+// Mutability returns the mutability of a User or Group attribute path. Value
+// filters are ignored, so "emails[type eq \"work\"].value" is looked up as
+// "emails.value". Attributes without a mutability are readWrite.
+func (r *SchemaRegistry) Mutability(resourceType, attribute string) string {
+	path := strings.ToLower(stripValueFilters(attribute))
+
+	if r != nil {
+		r.mu.RLock()
+		overrides := r.mutability[resourceType]
+		mutability, ok := lookupMutability(overrides, path)
+		r.mu.RUnlock()
+		if ok {
+			return mutability
+		}
+	}
+	if mutability, ok := lookupMutability(defaultMutability[resourceType], path); ok {
+		return mutability
+	}
+	if mutability := r.extensionMutability(resourceType, attribute); mutability != "" {
+		return mutability
+	}
+	return MutabilityReadWrite
+}
+
+// lookupMutability returns the mutability of path in mutabilities, or of its
+// closest parent attribute
+func lookupMutability(mutabilities map[string]string, path string) (string, bool) {
+	for {
+		if mutability, ok := mutabilities[path]; ok {
+			return mutability, true
+		}
+		// Dots before the attribute name belong to a schema URN
+		i := strings.LastIndex(path, ".")
+		if i <= strings.LastIndex(path, ":") {
+			return "", false
+		}
+		path = path[:i]
+	}
+}
+
+// extensionMutability returns the mutability declared by the definition of
+// an extension attribute, or "" if there is none
+func (r *SchemaRegistry) extensionMutability(resourceType, attribute string) string {
+	urn, attrPath := SplitSchemaURN(stripValueFilters(attribute))
+	if urn == "" || attrPath == "" {
+		return ""
+	}
+	ext, ok := r.Lookup(urn)
+	if !ok || ext.ResourceType != resourceType {
+		return ""
+	}
+
+	name, sub, _ := strings.Cut(attrPath, ".")
+	def := findAttributeDefinition(ext.Schema.Attributes, name)
+	if def == nil {
+		return ""
+	}
+	if sub != "" {
+		if subDef := findAttributeDefinition(def.SubAttributes, sub); subDef != nil && subDef.Mutability != "" {
+			return subDef.Mutability
+		}
+	}
+	return def.Mutability
+}
+
+// applyMutability sets the mutability of the attributes of a built-in schema
+// definition to their effective mutability, so the Schemas endpoint lists the
+// mutability the server enforces
+func (r *SchemaRegistry) applyMutability(resourceType string, schema *SchemaDefinition) *SchemaDefinition {
+	for i := range schema.Attributes {
+		attr := &schema.Attributes[i]
+		attr.Mutability = r.Mutability(resourceType, attr.Name)
+		for j := range attr.SubAttributes {
+			sub := &attr.SubAttributes[j]
+			sub.Mutability = r.Mutability(resourceType, attr.Name+"."+sub.Name)
+		}
+	}
+	return schema
+}
+
+// stripValueFilters removes the value filters of an attribute path, e.g.
+// emails[type eq "work"].value becomes emails.value
+func stripValueFilters(path string) string {
+	for {
+		start := strings.Index(path, "[")
+		if start == -1 {
+			return path
+		}
+		end := strings.Index(path[start:], "]")
+		if end == -1 {
+			return path[:start]
+		}
+		path = path[:start] + path[start+end+1:]
+	}
+}
+
+// ValidatePatchMutability rejects PATCH operations that modify readOnly
+// attributes, or immutable attributes that already have a value in current,
+// the resource being patched. Operations setting such an attribute to its
+// current value are accepted, so clients may send back what they read.
+func (v *Validator) ValidatePatchMutability(resourceType string, patch *PatchOp, current any) error {
+	currentData, err := resourceData(current)
+	if err != nil {
+		return err
+	}
+
+	for i, op := range patch.Operations {
+		if op.Path == "" {
+			data, ok := op.Value.(map[string]any)
+			if !ok {
+				continue
+			}
+			err = v.checkMutability(resourceType, "", data, currentData, false)
+		} else {
+			err = v.checkPatchPath(resourceType, op, currentData)
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkPatchPath checks the mutability of the attribute an operation's path
+// targets
+func (v *Validator) checkPatchPath(resourceType string, op PatchOperation, current map[string]any) error {
+	mutability := v.schemas.Mutability(resourceType, op.Path)
+	if mutability != MutabilityReadOnly && mutability != MutabilityImmutable {
+		if data, ok := op.Value.(map[string]any); ok && !strings.Contains(op.Path, "[") {
+			// A complex value may set sub-attributes that are not writable
+			prefix := op.Path + "."
+			if urn, attrPath := SplitSchemaURN(op.Path); urn != "" && attrPath == "" {
+				prefix = urn + ":"
+			}
+			existing, _ := lookupData(current, op.Path).(map[string]any)
+			return v.checkMutability(resourceType, prefix, data, existing, false)
+		}
+		return nil
+	}
+
+	existing := lookupData(current, op.Path)
+	if mutability == MutabilityImmutable && isEmptyValue(existing) {
+		return nil
+	}
+	if !strings.EqualFold(op.Op, "remove") && !strings.Contains(op.Path, "[") && matchesValue(op.Value, existing) {
+		return nil
+	}
+	return mutabilityError(op.Path, mutability)
+}
+
+// ValidateReplaceMutability rejects PUT requests whose resource changes
+// readOnly attributes of current, the resource being replaced, or immutable
+// attributes that already have a value. Attributes the request leaves empty
+// are not changed by it: readOnly attributes keep their value.
+func (v *Validator) ValidateReplaceMutability(resourceType string, resource, current any) error {
+	data, err := resourceData(resource)
+	if err != nil {
+		return err
+	}
+	currentData, err := resourceData(current)
+	if err != nil {
+		return err
+	}
+	return v.checkMutability(resourceType, "", data, currentData, true)
+}
+
+// patchNeedsCurrent reports whether ValidatePatchMutability needs the
+// resource being patched, that is whether the patch writes readOnly or
+// immutable attributes. Patches that do not are valid against any resource.
+func (v *Validator) patchNeedsCurrent(resourceType string, patch *PatchOp) bool {
+	for _, op := range patch.Operations {
+		if op.Path == "" {
+			if data, ok := op.Value.(map[string]any); ok && v.writesProtected(resourceType, "", data, false) {
+				return true
+			}
+			continue
+		}
+		switch v.schemas.Mutability(resourceType, op.Path) {
+		case MutabilityReadOnly, MutabilityImmutable:
+			return true
+		}
+		if data, ok := op.Value.(map[string]any); ok && !strings.Contains(op.Path, "[") {
+			prefix := op.Path + "."
+			if urn, attrPath := SplitSchemaURN(op.Path); urn != "" && attrPath == "" {
+				prefix = urn + ":"
+			}
+			if v.writesProtected(resourceType, prefix, data, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// replaceNeedsCurrent is the PUT counterpart of patchNeedsCurrent
+func (v *Validator) replaceNeedsCurrent(resourceType string, resource any) bool {
+	data, err := resourceData(resource)
+	if err != nil {
+		return true
+	}
+	return v.writesProtected(resourceType, "", data, true)
+}
+
+// writesProtected reports whether data, a complex value written at prefix,
+// sets readOnly or immutable attributes, walking it as checkMutability does
+func (v *Validator) writesProtected(resourceType, prefix string, data map[string]any, skipEmpty bool) bool {
+	for key, value := range data {
+		if skipEmpty && isEmptyValue(value) {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(key), "urn:") {
+			if ext, ok := value.(map[string]any); ok && v.writesProtected(resourceType, key+":", ext, skipEmpty) {
+				return true
+			}
+			continue
+		}
+
+		path := prefix + key
+		switch v.schemas.Mutability(resourceType, path) {
+		case MutabilityReadOnly, MutabilityImmutable:
+			return true
+		default:
+			if sub, ok := value.(map[string]any); ok && v.writesProtected(resourceType, path+".", sub, skipEmpty) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkMutability checks the attributes of data, a complex value written at
+// prefix, against their current values. Empty values are skipped when
+// skipEmpty is set, as PUT payloads omit readOnly attributes.
+func (v *Validator) checkMutability(resourceType, prefix string, data, current map[string]any, skipEmpty bool) error {
+	for key, value := range data {
+		if skipEmpty && isEmptyValue(value) {
+			continue
+		}
+		existing := lookupData(current, key)
+
+		// Extension data is qualified by its URN rather than nested
+		if strings.HasPrefix(strings.ToLower(key), "urn:") {
+			ext, ok := value.(map[string]any)
+			existingExt, _ := existing.(map[string]any)
+			if ok {
+				if err := v.checkMutability(resourceType, key+":", ext, existingExt, skipEmpty); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path := prefix + key
+		switch mutability := v.schemas.Mutability(resourceType, path); mutability {
+		case MutabilityReadOnly, MutabilityImmutable:
+			if mutability == MutabilityImmutable && isEmptyValue(existing) {
+				continue
+			}
+			if !matchesValue(value, existing) {
+				return mutabilityError(path, mutability)
+			}
+		default:
+			if sub, ok := value.(map[string]any); ok {
+				existingSub, _ := existing.(map[string]any)
+				if err := v.checkMutability(resourceType, path+".", sub, existingSub, skipEmpty); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// mutabilityError returns the error for a write to a readOnly or immutable
+// attribute
+func mutabilityError(path, mutability string) error {
+	return ErrMutability(fmt.Sprintf("attribute %s is %s", path, mutability))
+}
+
+// resourceData returns the JSON representation of a resource as a map
+func resourceData(resource any) (map[string]any, error) {
+	if resource == nil {
+		return nil, nil
+	}
+	if data, ok := resource.(map[string]any); ok {
+		return data, nil
+	}
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// lookupData returns the value of an attribute path in the JSON
+// representation of a resource, comparing names case-insensitively. Paths
+// with value filters resolve to the filtered multi-valued attribute.
+func lookupData(data map[string]any, path string) any {
+	if data == nil {
+		return nil
+	}
+
+	var current any = data
+	urn, attrPath := SplitSchemaURN(stripValueFilters(path))
+	if urn != "" {
+		key, ok := findKey(data, urn)
+		if !ok {
+			return nil
+		}
+		if current = data[key]; attrPath == "" {
+			return current
+		}
+	}
+
+	name, sub, _ := strings.Cut(attrPath, ".")
+	if strings.Contains(path, "[") {
+		sub = ""
+	}
+	for _, segment := range []string{name, sub} {
+		if segment == "" {
+			break
+		}
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		key, ok := findKey(m, segment)
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+// matchesValue reports whether a written value leaves the current value
+// unchanged. Complex values match when every sub-attribute they set matches
+// the current one, so a client may send back the meta or groups it read, and multi-valued
+// attributes match when each element matches one of the current elements.
+func matchesValue(value, current any) bool {
+	switch value := value.(type) {
+	case map[string]any:
+		currentMap, ok := current.(map[string]any)
+		if !ok {
+			return len(value) == 0
+		}
+		for key, sub := range value {
+			existingKey, found := findKey(currentMap, key)
+			if !found {
+				// Sub-attributes the plugin does not return, such as
+				// meta.location, cannot be compared
+				continue
+			}
+			if !matchesValue(sub, currentMap[existingKey]) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		currentValues, _ := current.([]any)
+		if len(value) != len(currentValues) {
+			return false
+		}
+		for _, elem := range value {
+			matched := false
+			for _, existing := range currentValues {
+				if matchesValue(elem, existing) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+		return true
+
+	case nil:
+		return isEmptyValue(current)
+	}
+
+	if s, ok := value.(string); ok {
+		if existing, ok := current.(string); ok {
+			return s == existing
+		}
+	}
+	return reflect.DeepEqual(normalizeValue(value), normalizeValue(current))
+}
+
+// normalizeValue converts a value to its JSON representation, so typed
+// values compare equal to decoded ones
+func normalizeValue(value any) any {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized any
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// isEmptyValue reports whether a JSON value is null, an empty string, an
+// empty array or an empty object
+func isEmptyValue(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []any:
+		return len(value) == 0
+	case map[string]any:
+		return len(value) == 0
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSchemaRegistryMutability(t *testing.T) {
+	registry := newTestRegistry(t, false)
+	if err := registry.SetMutability(ResourceTypeUser, "userName", MutabilityImmutable); err != nil {
+		t.Fatalf("SetMutability() error = %v", err)
+	}
+	if err := registry.SetMutability(ResourceTypeUser, "groups", MutabilityReadWrite); err != nil {
+		t.Fatalf("SetMutability() error = %v", err)
+	}
+	if err := registry.SetMutability(ResourceTypeUser, testExtensionURN+":costCenter", MutabilityReadOnly); err != nil {
+		t.Fatalf("SetMutability() error = %v", err)
+	}
+
+	tests := []struct {
+		resourceType, attribute, want string
+	}{
+		{ResourceTypeUser, "id", MutabilityReadOnly},
+		{ResourceTypeUser, "meta.created", MutabilityReadOnly},
+		{ResourceTypeGroup, "META", MutabilityReadOnly},
+		{ResourceTypeUser, "displayName", MutabilityReadWrite},
+		{ResourceTypeUser, "username", MutabilityImmutable},
+		{ResourceTypeUser, "groups", MutabilityReadWrite},
+		{ResourceTypeGroup, "groups", MutabilityReadWrite},
+		{ResourceTypeUser, `emails[type eq "work"].value`, MutabilityReadWrite},
+		{ResourceTypeUser, testExtensionURN + ":costCenter", MutabilityReadOnly},
+		{ResourceTypeUser, testExtensionURN + ":level", MutabilityReadWrite},
+	}
+	for _, tt := range tests {
+		if got := registry.Mutability(tt.resourceType, tt.attribute); got != tt.want {
+			t.Errorf("Mutability(%s, %s) = %q, want %q", tt.resourceType, tt.attribute, got, tt.want)
+		}
+	}
+
+	if err := registry.SetMutability(ResourceTypeUser, "userName", "sometimes"); err == nil {
+		t.Error("SetMutability() with an unknown mutability should fail")
+	}
+	if err := registry.SetMutability("Device", "name", MutabilityReadOnly); err == nil {
+		t.Error("SetMutability() for an unknown resource type should fail")
+	}
+}
+
+func TestValidatePatchMutability(t *testing.T) {
+	registry := NewSchemaRegistry()
+	registry.SetMutability(ResourceTypeUser, "externalId", MutabilityImmutable) // nolint:errcheck
+	validator := NewValidatorWithSchemas(registry)
+
+	current := &User{
+		ID:       "u1",
+		UserName: "alice",
+		Meta:     &Meta{ResourceType: ResourceTypeUser, Version: "v1"},
+		Groups:   []GroupRef{{Value: "g1", Display: "Admins"}},
+	}
+
+	tests := []struct {
+		name    string
+		op      PatchOperation
+		current *User
+		wantErr string
+	}{
+		{name: "readWrite attribute", op: PatchOperation{Op: "replace", Path: "displayName", Value: "Alice"}},
+		{name: "readOnly id", op: PatchOperation{Op: "replace", Path: "id", Value: "u2"}, wantErr: "id is readOnly"},
+		{name: "readOnly sub-attribute", op: PatchOperation{Op: "replace", Path: "meta.version", Value: "v2"}, wantErr: "meta.version is readOnly"},
+		{name: "readOnly groups", op: PatchOperation{Op: "add", Path: "groups", Value: []any{map[string]any{"value": "g2"}}}, wantErr: "groups is readOnly"},
+		{name: "readOnly removed", op: PatchOperation{Op: "remove", Path: "groups"}, wantErr: "groups is readOnly"},
+		{name: "readOnly in root value", op: PatchOperation{Op: "replace", Value: map[string]any{"displayName": "Alice", "id": "u2"}}, wantErr: "id is readOnly"},
+		{name: "readOnly unchanged", op: PatchOperation{Op: "replace", Value: map[string]any{"id": "u1", "meta": map[string]any{"resourceType": "User", "version": "v1"}}}},
+		{name: "groups unchanged", op: PatchOperation{Op: "replace", Path: "groups", Value: []any{map[string]any{"value": "g1", "display": "Admins"}}}},
+		{name: "immutable without value", op: PatchOperation{Op: "add", Path: "externalId", Value: "ext-1"}},
+		{
+			name:    "immutable with value",
+			op:      PatchOperation{Op: "replace", Path: "externalId", Value: "ext-2"},
+			current: &User{ID: "u1", UserName: "alice", ExternalID: "ext-1"},
+			wantErr: "externalId is immutable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := current
+			if tt.current != nil {
+				resource = tt.current
+			}
+			err := validator.ValidatePatchMutability(ResourceTypeUser, &PatchOp{Operations: []PatchOperation{tt.op}}, resource)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePatchMutability() error = %v", err)
+				}
+				return
+			}
+			var scimErr *SCIMError
+			if !errors.As(err, &scimErr) || scimErr.ScimType != ScimTypeMutability || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePatchMutability() error = %v, want mutability error %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReplaceMutability(t *testing.T) {
+	validator := NewValidatorWithSchemas(NewSchemaRegistry())
+	current := &Group{ID: "g1", DisplayName: "Admins", Meta: &Meta{ResourceType: ResourceTypeGroup}}
+
+	if err := validator.ValidateReplaceMutability(ResourceTypeGroup, &Group{DisplayName: "Staff"}, current); err != nil {
+		t.Errorf("ValidateReplaceMutability() without readOnly attributes error = %v", err)
+	}
+	if err := validator.ValidateReplaceMutability(ResourceTypeGroup, &Group{ID: "g1", DisplayName: "Staff", Meta: &Meta{ResourceType: ResourceTypeGroup}}, current); err != nil {
+		t.Errorf("ValidateReplaceMutability() with unchanged readOnly attributes error = %v", err)
+	}
+	err := validator.ValidateReplaceMutability(ResourceTypeGroup, &Group{ID: "g2", DisplayName: "Staff"}, current)
+	if err == nil || !strings.Contains(err.Error(), "id is readOnly") {
+		t.Errorf("ValidateReplaceMutability() with a changed id error = %v", err)
+	}
+}
+
+func TestServerMutability(t *testing.T) {
+	plugin := newMockPlugin()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	registry := NewSchemaRegistry()
+	registry.SetMutability(ResourceTypeUser, "userName", MutabilityImmutable) // nolint:errcheck
+	srv.SetSchemaRegistry(registry)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice"}`)
+	var created User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct{ method, body string }{
+		"patch of meta":       {"PATCH", `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "replace", "path": "meta.created", "value": "2020-01-01T00:00:00Z"}]}`},
+		"patch of groups":     {"PATCH", `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "add", "path": "groups", "value": [{"value": "g1"}]}]}`},
+		"patch of immutable":  {"PATCH", `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "replace", "value": {"userName": "bob"}}]}`},
+		"put with another id": {"PUT", `{"id": "other", "userName": "alice"}`},
+	} {
+		w := do(tc.method, "/test/Users/"+created.ID, tc.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"scimType":"mutability"`) {
+			t.Errorf("%s: status = %d, body: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	// The representation read from the server can be written back
+	w = do("GET", "/test/Users/"+created.ID, "")
+	w = do("PUT", "/test/Users/"+created.ID, w.Body.String())
+	if w.Code != http.StatusOK {
+		t.Errorf("PUT of the current user: status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Discovery lists the effective mutability
+	w = do("GET", "/test/Schemas", "")
+	if !strings.Contains(w.Body.String(), `"name":"userName","type":"string","multiValued":false,"required":true,"caseExact":false,"mutability":"immutable"`) {
+		t.Errorf("Schemas does not list userName as immutable: %s", w.Body.String())
+	}
+}
@@ -0,0 +1,312 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// PhoneFormatE164 formats phone numbers as E.164, e.g. +4930123456
+const PhoneFormatE164 = "e164"
+
+// Normalization holds the rules canonicalizing the attribute values of users
+// and groups clients send, so data quality rules live in the gateway rather
+// than in every backend. The server applies them to creates, replaces and
+// PATCH operations, including bulk operations, before validating them and
+// passing them to the plugin. Values violating a rule fail with 400 and
+// scimType invalidValue, listing every invalid attribute.
+type Normalization struct {
+	// TrimWhitespace removes leading and trailing whitespace from every
+	// string value, including those of extensions, except passwords
+	TrimWhitespace bool
+
+	// LowercaseUserName lowercases the userName of users
+	LowercaseUserName bool
+
+	// PhoneNumbers formats the values of phoneNumbers: PhoneFormatE164, or
+	// empty to pass them as sent
+	PhoneNumbers string
+
+	// DefaultCountryCode is the calling code of E.164 phone numbers sent
+	// without one, e.g. "49", whose national trunk prefix 0 is removed.
+	// Empty rejects such numbers.
+	DefaultCountryCode string
+
+	// ValidateEmails rejects values of emails that are not plain email
+	// addresses (RFC 5322), e.g. with a display name
+	ValidateEmails bool
+}
+
+// NormalizationProvider is an optional interface for plugins whose users and
+// groups are normalized before they reach them. The plugin adapter provides
+// it from the plugin's normalize setting, or the gateway's. Nil applies no
+// rules.
+type NormalizationProvider interface {
+	Normalization() *Normalization
+}
+
+// NormalizeUser applies the rules to user
+func (n *Normalization) NormalizeUser(user *User) error {
+	if n.TrimWhitespace {
+		if err := trimResource(user); err != nil {
+			return err
+		}
+	}
+	if n.LowercaseUserName {
+		user.UserName = strings.ToLower(user.UserName)
+	}
+
+	var errs attributeErrors
+	for i := range user.PhoneNumbers {
+		n.normalizePhoneNumber(&errs, fmt.Sprintf("phoneNumbers[%d].value", i), &user.PhoneNumbers[i].Value)
+	}
+	for i := range user.Emails {
+		n.checkEmail(&errs, fmt.Sprintf("emails[%d].value", i), user.Emails[i].Value)
+	}
+	return errs.err()
+}
+
+// NormalizeGroup applies the rules to group
+func (n *Normalization) NormalizeGroup(group *Group) error {
+	if n.TrimWhitespace {
+		return trimResource(group)
+	}
+	return nil
+}
+
+// NormalizePatch applies the rules to the values PATCH operations on a
+// resource of resourceType set, whether through their path or the members
+// of a value without a path
+func (n *Normalization) NormalizePatch(resourceType string, patch *PatchOp) error {
+	var errs attributeErrors
+	for i := range patch.Operations {
+		op := &patch.Operations[i]
+		if strings.EqualFold(op.Op, "remove") || op.Value == nil {
+			continue
+		}
+
+		if op.Path != "" {
+			_, attrPath := SplitSchemaURN(stripValueFilters(op.Path))
+			name, sub, _ := strings.Cut(attrPath, ".")
+			op.Value = n.normalizeValue(&errs, fmt.Sprintf("operation %d: ", i), resourceType, name, sub, op.Value)
+			continue
+		}
+
+		data, ok := op.Value.(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range data {
+			data[key] = n.normalizeValue(&errs, fmt.Sprintf("operation %d: ", i), resourceType, key, "", value)
+		}
+	}
+	return errs.err()
+}
+
+// normalizeValue applies the rules to value, set on the attribute name or
+// its sub-attribute sub by a PATCH operation, and returns the result.
+// Problems are added to errs, prefixed with prefix.
+func (n *Normalization) normalizeValue(errs *attributeErrors, prefix, resourceType, name, sub string, value any) any {
+	if resourceType == ResourceTypeUser && strings.EqualFold(name, "password") {
+		return value
+	}
+	if n.TrimWhitespace {
+		value = trimStrings(value)
+	}
+	if resourceType != ResourceTypeUser {
+		return value
+	}
+
+	switch {
+	case strings.EqualFold(name, "userName") && n.LowercaseUserName:
+		if s, ok := value.(string); ok {
+			value = strings.ToLower(s)
+		}
+	case strings.EqualFold(name, "phoneNumbers"):
+		value = forEachValue(value, sub, func(v *string) {
+			n.normalizePhoneNumber(errs, prefix+"phoneNumbers.value", v)
+		})
+	case strings.EqualFold(name, "emails"):
+		value = forEachValue(value, sub, func(v *string) {
+			n.checkEmail(errs, prefix+"emails.value", *v)
+		})
+	}
+	return value
+}
+
+// forEachValue calls fn with the value sub-attributes set by value, a
+// multi-valued attribute's value or elements, or, if sub is "value", the
+// sub-attribute itself, and returns value with the strings fn set
+func forEachValue(value any, sub string, fn func(*string)) any {
+	switch v := value.(type) {
+	case string:
+		if strings.EqualFold(sub, "value") {
+			fn(&v)
+		}
+		return v
+	case map[string]any:
+		if sub != "" {
+			break
+		}
+		if key, ok := findKey(v, "value"); ok {
+			if s, ok := v[key].(string); ok {
+				fn(&s)
+				v[key] = s
+			}
+		}
+	case []any:
+		if sub != "" {
+			break
+		}
+		for _, elem := range v {
+			forEachValue(elem, "", fn)
+		}
+	}
+	return value
+}
+
+// normalizePhoneNumber formats the phone number *value as configured
+func (n *Normalization) normalizePhoneNumber(errs *attributeErrors, path string, value *string) {
+	if n.PhoneNumbers != PhoneFormatE164 || *value == "" {
+		return
+	}
+	formatted, ok := FormatE164(*value, n.DefaultCountryCode)
+	if !ok {
+		errs.addf("%s %q is not a valid E.164 phone number", path, *value)
+		return
+	}
+	*value = formatted
+}
+
+// checkEmail checks the email address value, if emails are validated
+func (n *Normalization) checkEmail(errs *attributeErrors, path, value string) {
+	if !n.ValidateEmails || value == "" {
+		return
+	}
+	if addr, err := mail.ParseAddress(value); err != nil || addr.Name != "" || addr.Address != value {
+		errs.addf("%s %q is not a valid email address", path, value)
+	}
+}
+
+// FormatE164 returns the phone number formatted as E.164, e.g. "+49 (0)30
+// 123-456" as "+4930123456". Spaces, dashes, dots, slashes and parentheses
+// are removed and a 00 international prefix is replaced by +. Numbers
+// without either get defaultCountryCode, their trunk prefix 0 removed. It
+// reports false for numbers that cannot be formatted, e.g. with letters or
+// an extension, or without a country code when defaultCountryCode is empty.
+func FormatE164(number, defaultCountryCode string) (string, bool) {
+	number = strings.TrimSpace(number)
+	international := strings.HasPrefix(number, "+")
+	if international {
+		// The trunk prefix is written in parentheses after the country code
+		number = strings.Replace(number[1:], "(0)", "", 1)
+	}
+
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune(" -./()", r):
+		default:
+			return "", false
+		}
+	}
+
+	digits := b.String()
+	switch {
+	case international:
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case defaultCountryCode != "":
+		digits = defaultCountryCode + strings.TrimPrefix(digits, "0")
+	default:
+		return "", false
+	}
+	// E.164 numbers have at most 15 digits, and country codes do not start with 0
+	if len(digits) < 7 || len(digits) > 15 || digits[0] == '0' {
+		return "", false
+	}
+	return "+" + digits, true
+}
+
+// trimResource removes the leading and trailing whitespace of the string
+// values of resource, a *User or *Group, except its password
+func trimResource[T any](resource *T) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	for key, member := range value {
+		if !strings.EqualFold(key, "password") {
+			value[key] = trimStrings(member)
+		}
+	}
+	if data, err = json.Marshal(value); err != nil {
+		return err
+	}
+	var trimmed T
+	if err := json.Unmarshal(data, &trimmed); err != nil {
+		return err
+	}
+	*resource = trimmed
+	return nil
+}
+
+// trimStrings removes the leading and trailing whitespace of the strings in
+// value
+func trimStrings(value any) any {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]any:
+		for key, member := range v {
+			v[key] = trimStrings(member)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = trimStrings(item)
+		}
+	}
+	return value
+}
+
+// normalization returns the normalization rules of plugin, or nil
+func normalization(plugin PluginGetter) *Normalization {
+	if provider, ok := lookupCapability[NormalizationProvider](plugin); ok {
+		return provider.Normalization()
+	}
+	return nil
+}
+
+// normalizeUserInput applies the normalization rules of plugin to a user a
+// client sent
+func normalizeUserInput(plugin PluginGetter, user *User) error {
+	if n := normalization(plugin); n != nil {
+		return n.NormalizeUser(user)
+	}
+	return nil
+}
+
+// normalizeGroupInput applies the normalization rules of plugin to a group a
+// client sent
+func normalizeGroupInput(plugin PluginGetter, group *Group) error {
+	if n := normalization(plugin); n != nil {
+		return n.NormalizeGroup(group)
+	}
+	return nil
+}
+
+// normalizePatchInput applies the normalization rules of plugin to a PATCH
+// request a client sent for a resource of resourceType
+func normalizePatchInput(plugin PluginGetter, resourceType string, patch *PatchOp) error {
+	if n := normalization(plugin); n != nil {
+		return n.NormalizePatch(resourceType, patch)
+	}
+	return nil
+}
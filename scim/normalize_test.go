@@ -0,0 +1,150 @@
+package scim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFormatE164(t *testing.T) {
+	tests := []struct {
+		number, countryCode string
+		want                string
+		ok                  bool
+	}{
+		{"+49 (0)30 123-456", "", "+4930123456", true},
+		{"+1 (555) 010.0199", "", "+15550100199", true},
+		{"0049 30 123456", "", "+4930123456", true},
+		{"030 / 123456", "49", "+4930123456", true},
+		{"030 123456", "", "", false},
+		{"+49 30 123456 ext. 12", "", "", false},
+		{"+0 30 123456", "", "", false},
+		{"+123", "", "", false},
+		{"+1234567890123456", "", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := FormatE164(tt.number, tt.countryCode)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("FormatE164(%q, %q) = %q, %v, want %q, %v", tt.number, tt.countryCode, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalization_NormalizeUser(t *testing.T) {
+	n := &Normalization{TrimWhitespace: true, LowercaseUserName: true, PhoneNumbers: PhoneFormatE164, DefaultCountryCode: "49", ValidateEmails: true}
+	user := &User{
+		UserName:     "  BJensen@Example.com ",
+		Password:     " secret ",
+		Name:         &Name{GivenName: " Barbara"},
+		Emails:       []Email{{Value: " bjensen@example.com"}},
+		PhoneNumbers: []PhoneNumber{{Value: "030 123456"}},
+	}
+	if err := n.NormalizeUser(user); err != nil {
+		t.Fatalf("NormalizeUser() error = %v", err)
+	}
+	if user.UserName != "bjensen@example.com" || user.Name.GivenName != "Barbara" || user.Emails[0].Value != "bjensen@example.com" {
+		t.Errorf("NormalizeUser() = %+v, want trimmed values and a lower-case userName", user)
+	}
+	if user.Password != " secret " {
+		t.Errorf("password = %q, want it unchanged", user.Password)
+	}
+	if got := user.PhoneNumbers[0].Value; got != "+4930123456" {
+		t.Errorf("phoneNumbers[0].value = %q, want +4930123456", got)
+	}
+
+	invalid := &User{
+		UserName:     "bjensen",
+		Emails:       []Email{{Value: "bjensen@example.com"}, {Value: "Barbara <bjensen@example.com>"}},
+		PhoneNumbers: []PhoneNumber{{Value: "call me"}},
+	}
+	err := n.NormalizeUser(invalid)
+	if err == nil {
+		t.Fatal("NormalizeUser() error = nil, want invalid attributes")
+	}
+	for _, path := range []string{"emails[1].value", "phoneNumbers[0].value"} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("NormalizeUser() error = %v, want %s listed", err, path)
+		}
+	}
+}
+
+func TestNormalization_NormalizePatch(t *testing.T) {
+	n := &Normalization{TrimWhitespace: true, LowercaseUserName: true, PhoneNumbers: PhoneFormatE164, ValidateEmails: true}
+	patch := &PatchOp{Operations: []PatchOperation{
+		{Op: "replace", Path: "userName", Value: " BJensen "},
+		{Op: "replace", Path: "password", Value: " secret "},
+		{Op: "add", Path: `phoneNumbers[type eq "work"].value`, Value: "+49 30 123456"},
+		{Op: "add", Value: map[string]any{
+			"displayName":  " Babs ",
+			"phoneNumbers": []any{map[string]any{"value": "0049 40 654321", "type": "home"}},
+		}},
+	}}
+	if err := n.NormalizePatch(ResourceTypeUser, patch); err != nil {
+		t.Fatalf("NormalizePatch() error = %v", err)
+	}
+	if got := patch.Operations[0].Value; got != "bjensen" {
+		t.Errorf("userName = %v, want bjensen", got)
+	}
+	if got := patch.Operations[1].Value; got != " secret " {
+		t.Errorf("password = %v, want it unchanged", got)
+	}
+	if got := patch.Operations[2].Value; got != "+4930123456" {
+		t.Errorf("phoneNumbers value = %v, want +4930123456", got)
+	}
+	value := patch.Operations[3].Value.(map[string]any)
+	if got := value["displayName"]; got != "Babs" {
+		t.Errorf("displayName = %v, want Babs", got)
+	}
+	if got := value["phoneNumbers"].([]any)[0].(map[string]any)["value"]; got != "+4940654321" {
+		t.Errorf("phoneNumbers[0].value = %v, want +4940654321", got)
+	}
+
+	invalid := &PatchOp{Operations: []PatchOperation{
+		{Op: "add", Path: "emails", Value: []any{map[string]any{"value": "not an address"}}},
+	}}
+	if err := n.NormalizePatch(ResourceTypeUser, invalid); err == nil || !strings.Contains(err.Error(), "emails.value") {
+		t.Errorf("NormalizePatch() error = %v, want emails.value error", err)
+	}
+}
+
+// normalizingPlugin normalizes the users it is sent
+type normalizingPlugin struct {
+	*mockPlugin
+	normalization *Normalization
+}
+
+func (p *normalizingPlugin) Normalization() *Normalization {
+	return p.normalization
+}
+
+func TestServer_Normalization(t *testing.T) {
+	plugin := &normalizingPlugin{
+		mockPlugin:    newMockPlugin(),
+		normalization: &Normalization{TrimWhitespace: true, LowercaseUserName: true, ValidateEmails: true},
+	}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	body := `{"schemas": ["` + SchemaUser + `"], "userName": " BJensen ", "emails": [{"value": "bjensen@example.com "}]}`
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/test/Users", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", w.Code, w.Body.String())
+	}
+	for _, user := range plugin.users {
+		if user.UserName != "bjensen" || user.Emails[0].Value != "bjensen@example.com" {
+			t.Errorf("plugin got %+v, want normalized values", user)
+		}
+	}
+
+	body = `{"schemas": ["` + SchemaUser + `"], "userName": "jsmith", "emails": [{"value": "jsmith at example.com"}]}`
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/test/Users", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "emails[0].value") {
+		t.Errorf("status = %d, body = %s, want 400 listing emails[0].value", w.Code, w.Body.String())
+	}
+	if len(plugin.users) != 1 {
+		t.Errorf("plugin has %d users, want the invalid one rejected", len(plugin.users))
+	}
+}
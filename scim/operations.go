@@ -0,0 +1,119 @@
+package scim
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Operations on a resource type that a plugin can allow or deny
+const (
+	OperationRead    = "read"    // GET of resources and lists, searches
+	OperationCreate  = "create"  // POST
+	OperationReplace = "replace" // PUT
+	OperationPatch   = "patch"   // PATCH
+	OperationDelete  = "delete"  // DELETE
+)
+
+// OperationAdmin is the operation of the gateway's admin endpoints, such as
+// the history of a resource. Authorization may grant it to scopes or roles;
+// it is not an operation on a resource type.
+const OperationAdmin = "admin"
+
+// OperationsProvider is an optional interface for plugins restricting the
+// operations clients may perform on their resource types, e.g. allowing
+// Group reads but no Group writes. The server rejects other operations with
+// 403, or with 501 for PATCH and bulk requests when no resource type allows
+// them, and reflects the allowed operations in ServiceProviderConfig.
+//
+// The plugin adapter implements it from the operations setting of the
+// plugin's configuration.
+type OperationsProvider interface {
+	// AllowedOperations returns the operations allowed on the resource type
+	// with the given endpoint name, e.g. "Users", and false if the resource
+	// type is not restricted
+	AllowedOperations(endpoint string) ([]string, bool)
+}
+
+// allowsOperation reports whether plugin allows operation on the resource
+// type with the given endpoint name
+func allowsOperation(plugin PluginGetter, endpoint, operation string) bool {
+	provider, ok := lookupCapability[OperationsProvider](plugin)
+	if !ok {
+		return true
+	}
+	allowed, restricted := provider.AllowedOperations(endpoint)
+	return !restricted || slices.Contains(allowed, operation)
+}
+
+// supportsOperation reports whether plugin allows operation on any of its
+// resource types
+func (s *Server) supportsOperation(plugin PluginGetter, operation string) bool {
+	for _, endpoint := range s.endpoints(plugin) {
+		if allowsOperation(plugin, endpoint, operation) {
+			return true
+		}
+	}
+	return false
+}
+
+// endpoints returns the endpoint names of the resource types plugin serves
+func (s *Server) endpoints(plugin PluginGetter) []string {
+	endpoints := []string{"Users", "Groups"}
+	for _, rt := range s.resourceTypes(plugin) {
+		endpoints = append(endpoints, strings.TrimPrefix(rt.Definition().Endpoint, "/"))
+	}
+	return endpoints
+}
+
+// allowOperation reports whether plugin allows operation on the resource
+// type with the given endpoint name, and writes the error response if not:
+// 501 for PATCH when the plugin allows it on no resource type, as
+// ServiceProviderConfig then reports PATCH unsupported, and 403 otherwise.
+func (s *Server) allowOperation(w http.ResponseWriter, plugin PluginGetter, pluginName, endpoint, operation string) bool {
+	if allowsOperation(plugin, endpoint, operation) {
+		return true
+	}
+	if operation == OperationPatch && !s.supportsOperation(plugin, OperationPatch) {
+		s.handler.WriteSCIMError(w, ErrNotImplemented("PATCH"))
+		return false
+	}
+	s.handler.WriteSCIMError(w, ErrOperationNotAllowed(operation, endpoint, pluginName))
+	return false
+}
+
+// bulkOperation returns the operation a bulk request method performs
+func bulkOperation(method string) string {
+	switch method {
+	case http.MethodPost:
+		return OperationCreate
+	case http.MethodPut:
+		return OperationReplace
+	case http.MethodPatch:
+		return OperationPatch
+	case http.MethodDelete:
+		return OperationDelete
+	}
+	return ""
+}
+
+// applyOperations reflects the operations plugin allows in its service
+// provider configuration: PATCH and change password are supported if some
+// resource type, respectively Users, allows PATCH, and bulk requests if some
+// resource type allows a write
+func (s *Server) applyOperations(config *ServiceProviderConfig, plugin PluginGetter) *ServiceProviderConfig {
+	if _, ok := lookupCapability[OperationsProvider](plugin); !ok {
+		return config
+	}
+
+	config.Patch.Supported = s.supportsOperation(plugin, OperationPatch)
+	config.ChangePassword.Supported = allowsOperation(plugin, "Users", OperationPatch)
+	config.Bulk.Supported = false
+	for _, operation := range []string{OperationCreate, OperationReplace, OperationPatch, OperationDelete} {
+		if s.supportsOperation(plugin, operation) {
+			config.Bulk.Supported = true
+			break
+		}
+	}
+	return config
+}
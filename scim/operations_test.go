@@ -0,0 +1,131 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// operationsPlugin is a mockPlugin restricting the operations per resource type
+type operationsPlugin struct {
+	*mockPlugin
+	operations map[string][]string
+}
+
+func (p *operationsPlugin) AllowedOperations(endpoint string) ([]string, bool) {
+	allowed, ok := p.operations[endpoint]
+	return allowed, ok
+}
+
+func TestServerAllowedOperations(t *testing.T) {
+	plugin := &operationsPlugin{
+		mockPlugin: newMockPlugin(),
+		operations: map[string][]string{
+			"Users":  {OperationRead, OperationCreate, OperationReplace},
+			"Groups": {OperationRead},
+		},
+	}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create user: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{"get user", "GET", "/test/Users/" + user.ID, "", http.StatusOK},
+		{"delete user", "DELETE", "/test/Users/" + user.ID, "", http.StatusForbidden},
+		{"patch user", "PATCH", "/test/Users/" + user.ID, `{"schemas": ["` + SchemaPatchOp + `"], "Operations": [{"op": "replace", "path": "active", "value": false}]}`, http.StatusNotImplemented},
+		{"list groups", "GET", "/test/Groups", "", http.StatusOK},
+		{"search groups", "POST", "/test/Groups/.search", `{"schemas": ["` + SchemaSearchRequest + `"]}`, http.StatusOK},
+		{"create group", "POST", "/test/Groups", `{"displayName": "admins"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if w := do(tt.method, tt.path, tt.body); w.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d, body: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+
+	// ServiceProviderConfig reports PATCH and change password unsupported
+	var config ServiceProviderConfig
+	if err := json.Unmarshal(do("GET", "/test/ServiceProviderConfig", "").Body.Bytes(), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Patch.Supported || config.ChangePassword.Supported || !config.Bulk.Supported {
+		t.Errorf("ServiceProviderConfig patch = %v, changePassword = %v, bulk = %v; want false, false, true",
+			config.Patch.Supported, config.ChangePassword.Supported, config.Bulk.Supported)
+	}
+
+	// Bulk operations the plugin does not allow fail individually
+	w = do("POST", "/test/Bulk", `{"schemas": ["`+SchemaBulkRequest+`"], "Operations": [
+		{"method": "DELETE", "path": "/Users/`+user.ID+`"},
+		{"method": "POST", "path": "/Users", "bulkId": "b1", "data": {"userName": "bob"}}
+	]}`)
+	var bulkResp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &bulkResp); err != nil {
+		t.Fatalf("bulk: %v, body: %s", err, w.Body.String())
+	}
+	if len(bulkResp.Operations) != 2 || bulkResp.Operations[0].Status != "403" || bulkResp.Operations[1].Status != "201" {
+		t.Errorf("bulk operations = %+v, want statuses 403 and 201", bulkResp.Operations)
+	}
+}
+
+func TestServerAllowedOperationsReadOnly(t *testing.T) {
+	plugin := &operationsPlugin{
+		mockPlugin: newMockPlugin(),
+		operations: map[string][]string{
+			"Users":  {},
+			"Groups": {OperationRead},
+		},
+	}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	plugin.users["u1"] = &User{ID: "u1", UserName: "alice"}
+	plugin.groups["g1"] = &Group{ID: "g1", DisplayName: "admins"}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	// Bulk requests are unsupported without any write
+	if w := do("POST", "/test/Bulk", `{"schemas": ["`+SchemaBulkRequest+`"], "Operations": []}`); w.Code != http.StatusNotImplemented {
+		t.Errorf("bulk: status = %d, want 501, body: %s", w.Code, w.Body.String())
+	}
+
+	if w := do("GET", "/test/Users", ""); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Operation read on Users not allowed") {
+		t.Errorf("list users: status = %d, want 403, body: %s", w.Code, w.Body.String())
+	}
+
+	// Searches across resource types skip those the plugin does not allow to read
+	w := do("POST", "/test/.search", `{"schemas": ["`+SchemaSearchRequest+`"]}`)
+	var list ListResponse[map[string]any]
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("search: %v, body: %s", err, w.Body.String())
+	}
+	if list.TotalResults != 1 || list.Resources[0]["id"] != "g1" {
+		t.Errorf("search resources = %v, want group g1 only", list.Resources)
+	}
+}
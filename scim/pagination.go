@@ -0,0 +1,53 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SetPaginationLinks makes GET list responses carry an RFC 8288 Link header
+// with the URLs of the first, previous and next page, computed from the
+// request's startIndex and count, e.g.
+//
+//	Link: <https://scim.example.com/hr/Users?count=10&startIndex=1>; rel="first",
+//	      <https://scim.example.com/hr/Users?count=10&startIndex=11>; rel="next"
+//
+// POST searches are left without links, as their pages are requested with a
+// body. It must be called before the server handles requests.
+func (s *Server) SetPaginationLinks(enabled bool) {
+	s.paginationLinks = enabled
+}
+
+// setPaginationLinks sets the Link header of a GET list response for the page
+// of params of the list at listURL, if pagination links are enabled. hasNext
+// tells whether matches follow the page.
+func (s *Server) setPaginationLinks(w http.ResponseWriter, r *http.Request, listURL string, params QueryParams, hasNext bool) {
+	if !s.paginationLinks || r.Method != http.MethodGet || params.Count <= 0 {
+		return
+	}
+
+	link := func(startIndex int, rel string) string {
+		query := r.URL.Query()
+		query.Set("startIndex", strconv.Itoa(startIndex))
+		query.Set("count", strconv.Itoa(params.Count))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, listURL, query.Encode(), rel)
+	}
+
+	startIndex := max(params.StartIndex, 1)
+	links := []string{link(1, "first")}
+	if startIndex > 1 {
+		links = append(links, link(max(startIndex-params.Count, 1), "prev"))
+	}
+	if hasNext {
+		links = append(links, link(startIndex+params.Count, "next"))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// hasNextPage reports whether matches follow the page of params in a list of
+// totalResults matches
+func hasNextPage(params QueryParams, totalResults int) bool {
+	return params.Count > 0 && max(params.StartIndex, 1)+params.Count-1 < totalResults
+}
@@ -0,0 +1,82 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestServer_PaginationLinks(t *testing.T) {
+	const users = "http://localhost:8080/test/Users"
+	filter := url.QueryEscape("active eq true")
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			query: "startIndex=1&count=10",
+			want:  `<` + users + `?count=10&startIndex=1>; rel="first", <` + users + `?count=10&startIndex=11>; rel="next"`,
+		},
+		{
+			query: "startIndex=11&count=10",
+			want:  `<` + users + `?count=10&startIndex=1>; rel="first", <` + users + `?count=10&startIndex=1>; rel="prev", <` + users + `?count=10&startIndex=21>; rel="next"`,
+		},
+		{
+			query: "startIndex=16&count=10",
+			want:  `<` + users + `?count=10&startIndex=1>; rel="first", <` + users + `?count=10&startIndex=6>; rel="prev"`,
+		},
+		{
+			query: "filter=" + filter + "&startIndex=11&count=5",
+			want:  `<` + users + `?count=5&filter=` + filter + `&startIndex=1>; rel="first", <` + users + `?count=5&filter=` + filter + `&startIndex=6>; rel="prev"`,
+		},
+	}
+
+	for _, streamed := range []bool{false, true} {
+		plugin := newStreamingPlugin(25)
+		var getter PluginGetter = plugin
+		if !streamed {
+			getter = plugin.mockPlugin
+		}
+		srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: getter})
+		srv.SetPaginationLinks(true)
+
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users?"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("streamed %v, %s: status = %d: %s", streamed, tt.query, w.Code, w.Body.String())
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("streamed %v, %s: invalid body %s", streamed, tt.query, w.Body.String())
+			}
+			if got := w.Header().Get("Link"); got != tt.want {
+				t.Errorf("streamed %v, %s: Link =\n%s\nwant\n%s", streamed, tt.query, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestServer_PaginationLinksOmitted(t *testing.T) {
+	plugin := newStreamingPlugin(25)
+
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users?count=10", nil))
+	if link := w.Header().Get("Link"); link != "" {
+		t.Errorf("Link = %s without SetPaginationLinks", link)
+	}
+
+	srv.SetPaginationLinks(true)
+	w = httptest.NewRecorder()
+	body := `{"schemas": ["` + SchemaSearchRequest + `"], "count": 10}`
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/test/Users/.search", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if link := w.Header().Get("Link"); link != "" {
+		t.Errorf("Link = %s on a POST search", link)
+	}
+}
@@ -0,0 +1,155 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/filter"
+)
+
+// PasswordHasher hashes the passwords clients set on users before the server
+// passes them to plugins, so plugins store hashes rather than clear text.
+// Implementations typically wrap bcrypt or argon2 from golang.org/x/crypto.
+// Errors are reported to the client as invalid values, e.g. a password
+// longer than the 72 bytes bcrypt accepts.
+type PasswordHasher interface {
+	HashPassword(password string) (string, error)
+}
+
+// PasswordHasherFunc adapts a function to the PasswordHasher interface
+type PasswordHasherFunc func(password string) (string, error)
+
+// HashPassword calls f(password)
+func (f PasswordHasherFunc) HashPassword(password string) (string, error) {
+	return f(password)
+}
+
+// SetPasswordHasher sets the hasher applied to the password of users created
+// or replaced and to PATCH operations setting the password, the SCIM change
+// password pattern (RFC 7644 Section 3.5.2). Nil passes passwords to plugins
+// as sent (default). Passwords are never returned in responses either way.
+// It must be called before the server handles requests.
+func (s *Server) SetPasswordHasher(hasher PasswordHasher) {
+	s.passwords = hasher
+}
+
+// hashUserPassword replaces the password of user with its hash
+func (s *Server) hashUserPassword(user *User) error {
+	if s.passwords == nil || user.Password == "" {
+		return nil
+	}
+	hashed, err := s.hashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	return nil
+}
+
+// hashPatchPasswords replaces the passwords PATCH operations set with their
+// hashes, whether the operation's path is password or it has no path and
+// its value sets the password
+func (s *Server) hashPatchPasswords(patch *PatchOp) error {
+	if s.passwords == nil {
+		return nil
+	}
+
+	for i := range patch.Operations {
+		op := &patch.Operations[i]
+		if strings.EqualFold(op.Op, "remove") {
+			continue
+		}
+
+		if op.Path != "" {
+			if !isPasswordPath(op.Path) || op.Value == nil {
+				continue
+			}
+			hashed, err := s.hashPasswordValue(op.Value)
+			if err != nil {
+				return fmt.Errorf("operation %d: %w", i, err)
+			}
+			op.Value = hashed
+			continue
+		}
+
+		data, ok := op.Value.(map[string]any)
+		if !ok {
+			continue
+		}
+		key, ok := findKey(data, "password")
+		if !ok || data[key] == nil {
+			continue
+		}
+		hashed, err := s.hashPasswordValue(data[key])
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+		data[key] = hashed
+	}
+	return nil
+}
+
+// hashPasswordValue hashes the value of a password attribute in a PATCH
+// operation, which must be a string
+func (s *Server) hashPasswordValue(value any) (string, error) {
+	password, ok := value.(string)
+	if !ok {
+		return "", ErrInvalidValue("password must be of type string")
+	}
+	return s.hashPassword(password)
+}
+
+// hashPassword hashes a password, reporting hasher failures as invalid values
+func (s *Server) hashPassword(password string) (string, error) {
+	hashed, err := s.passwords.HashPassword(password)
+	if err != nil {
+		var scimErr *SCIMError
+		if errors.As(err, &scimErr) {
+			return "", err
+		}
+		return "", ErrInvalidValue(fmt.Sprintf("password cannot be set: %v", err))
+	}
+	return hashed, nil
+}
+
+// isPasswordPath reports whether a PATCH path addresses the password of a
+// user, with or without the core User schema URN
+func isPasswordPath(path string) bool {
+	path = strings.TrimPrefix(path, SchemaUser+":")
+	return strings.EqualFold(path, "password")
+}
+
+// checkPasswordQuery rejects filters and sort orders on the password of
+// users, which is never returned (RFC 7643 Section 4.1.1): matching or
+// ordering by it would reveal what is stored, e.g. password sw "a" probing
+// a prefix of the hash
+func checkPasswordQuery(params QueryParams) error {
+	if params.SortBy != "" && isPasswordPath(params.SortBy) {
+		return fmt.Errorf("sortBy %s is not allowed: the attribute is never returned", params.SortBy)
+	}
+	if params.Filter == "" {
+		return nil
+	}
+	expr, err := filter.Parse(params.Filter)
+	if err != nil {
+		// Invalid filters are reported where they are evaluated
+		return nil
+	}
+	var never string
+	filter.Walk(expr, func(e filter.Expr) bool {
+		attr, ok := e.(*filter.AttributeExpr)
+		if !ok {
+			return true
+		}
+		// Value filters compare sub-attributes, e.g. emails[value eq "x"]
+		if isPasswordPath(attr.Path.String()) {
+			never = attr.Path.String()
+		}
+		return false
+	})
+	if never != "" {
+		return fmt.Errorf("filtering on %s is not allowed: the attribute is never returned", never)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// passwordPlugin is a mockPlugin recording the passwords it receives
+type passwordPlugin struct {
+	*mockPlugin
+	passwords []string
+}
+
+func (p *passwordPlugin) CreateUser(ctx context.Context, user *User) (*User, error) {
+	p.passwords = append(p.passwords, user.Password)
+	return p.mockPlugin.CreateUser(ctx, user)
+}
+
+func (p *passwordPlugin) ModifyUser(ctx context.Context, id string, patch *PatchOp) error {
+	for _, op := range patch.Operations {
+		if value, ok := op.Value.(map[string]any); ok {
+			p.passwords = append(p.passwords, value["password"].(string))
+		} else {
+			p.passwords = append(p.passwords, op.Value.(string))
+		}
+	}
+	return p.mockPlugin.ModifyUser(ctx, id, patch)
+}
+
+func TestServerPasswordHasher(t *testing.T) {
+	plugin := &passwordPlugin{mockPlugin: newMockPlugin()}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	srv.SetPasswordHasher(PasswordHasherFunc(func(password string) (string, error) {
+		if len(password) < 4 {
+			return "", errors.New("password too short")
+		}
+		return "hashed:" + password, nil
+	}))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice", "password": "s3cret"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Errorf("create response returns the password: %s", w.Body.String())
+	}
+	var created User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+
+	// ChangePassword by path and without a path
+	patch := func(op string) *httptest.ResponseRecorder {
+		return do("PATCH", "/test/Users/"+created.ID, `{"schemas": ["`+SchemaPatchOp+`"], "Operations": [`+op+`]}`)
+	}
+	if w := patch(`{"op": "replace", "path": "password", "value": "n3w-secret"}`); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "password") {
+		t.Errorf("patch by path: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := patch(`{"op": "replace", "value": {"password": "other-secret"}}`); w.Code != http.StatusOK {
+		t.Errorf("patch without path: status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	want := []string{"hashed:s3cret", "hashed:n3w-secret", "hashed:other-secret"}
+	if strings.Join(plugin.passwords, ",") != strings.Join(want, ",") {
+		t.Errorf("plugin passwords = %v, want %v", plugin.passwords, want)
+	}
+
+	// Lists never return passwords either
+	if w := do("GET", "/test/Users", ""); strings.Contains(w.Body.String(), "password") {
+		t.Errorf("list returns the password: %s", w.Body.String())
+	}
+
+	// Hasher failures and invalid values are rejected before reaching the plugin
+	if w := patch(`{"op": "replace", "path": "password", "value": "abc"}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "password too short") {
+		t.Errorf("patch with rejected password: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if w := patch(`{"op": "replace", "path": "password", "value": 42}`); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalidValue") {
+		t.Errorf("patch with non-string password: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if len(plugin.passwords) != 3 {
+		t.Errorf("plugin received %d passwords, want 3", len(plugin.passwords))
+	}
+}
+
+func TestServerRejectsPasswordQueries(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.users["1"] = &User{ID: "1", UserName: "alice", Password: "hunter2"}
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, query := range []string{
+		`filter=password+sw+"hun"`,
+		`filter=userName+pr+and+not+(urn:ietf:params:scim:schemas:core:2.0:User:PASSWORD+pr)`,
+		`sortBy=password`,
+	} {
+		if w := do("GET", "/test/Users?"+query, ""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalidFilter") {
+			t.Errorf("GET ?%s status = %d, body: %s, want 400 invalidFilter", query, w.Code, w.Body.String())
+		}
+	}
+	search := `{"schemas": ["` + SchemaSearchRequest + `"], "filter": "password sw \"hun\""}`
+	for _, path := range []string{"/test/.search", "/test/Users/.search"} {
+		if w := do("POST", path, search); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400", path, w.Code)
+		}
+	}
+	if w := do("GET", `/test/Users?filter=userName+sw+"al"`, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "alice") {
+		t.Errorf("userName filter status = %d, body: %s, want alice", w.Code, w.Body.String())
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"strings"
 )
 
-// PatchProcessor processes SCIM PATCH operations
+// PatchProcessor processes SCIM PATCH operations.
+//
+// Paths qualified by an extension URN without a struct field of its own (see
+// User.Extensions) patch the attributes of that extension.
 type PatchProcessor struct{}
 
 // NewPatchProcessor creates a new patch processor
@@ -27,6 +30,13 @@ func (pp *PatchProcessor) ApplyPatch(resource any, patch *PatchOp) error {
 
 // applyOperation applies a single patch operation
 func (pp *PatchProcessor) applyOperation(resource any, op PatchOperation) error {
+	// Group members are added and removed by value
+	if group, ok := resource.(*Group); ok {
+		if applied, err := pp.applyMemberOperation(group, op); applied {
+			return err
+		}
+	}
+
 	switch strings.ToLower(op.Op) {
 	case "add":
 		return pp.applyAdd(resource, op)
@@ -48,6 +58,9 @@ func (pp *PatchProcessor) applyAdd(resource any, op PatchOperation) error {
 
 	// Parse path
 	path := parsePath(op.Path)
+	if field := extensionTarget(resource, path); field.IsValid() {
+		return patchExtension(field, "add", path, op.Value)
+	}
 	return pp.addToPath(resource, path, op.Value)
 }
 
@@ -58,6 +71,9 @@ func (pp *PatchProcessor) applyRemove(resource any, op PatchOperation) error {
 	}
 
 	path := parsePath(op.Path)
+	if field := extensionTarget(resource, path); field.IsValid() {
+		return patchExtension(field, "remove", path, nil)
+	}
 	return pp.removeFromPath(resource, path)
 }
 
@@ -69,6 +85,9 @@ func (pp *PatchProcessor) applyReplace(resource any, op PatchOperation) error {
 	}
 
 	path := parsePath(op.Path)
+	if field := extensionTarget(resource, path); field.IsValid() {
+		return patchExtension(field, "replace", path, op.Value)
+	}
 	return pp.replaceAtPath(resource, path, op.Value)
 }
 
@@ -96,6 +115,15 @@ func (pp *PatchProcessor) addToRoot(resource any, value any) error {
 
 	// Set each attribute
 	for key, val := range valueMap {
+		// Attributes of extensions without a struct field are kept in Extensions
+		path := &Path{Segments: []PathSegment{{Attribute: key}}}
+		if ext := extensionTarget(resource, path); ext.IsValid() {
+			if err := patchExtension(ext, "add", path, val); err != nil {
+				return err
+			}
+			continue
+		}
+
 		field := findField(v, key)
 		if !field.IsValid() || !field.CanSet() {
 			continue
@@ -436,24 +464,7 @@ func parsePath(pathStr string) *Path {
 
 	// Check if this is a schema URN path
 	if strings.HasPrefix(pathStr, "urn:") {
-		// Schema URN paths have format: <schema-urn>:<attribute-path>
-		// Example: urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber
-		// The schema URN ends at ":User" or ":Group", then comes the attribute path
-
-		var schemaURN string
-		var attrPath string
-
-		if idx := strings.Index(pathStr, ":User:"); idx != -1 {
-			schemaURN = pathStr[:idx+5] // Include ":User"
-			attrPath = pathStr[idx+6:]  // Skip ":User:"
-		} else if idx := strings.Index(pathStr, ":Group:"); idx != -1 {
-			schemaURN = pathStr[:idx+6] // Include ":Group"
-			attrPath = pathStr[idx+7:]  // Skip ":Group:"
-		} else {
-			// Fallback: just the URN without attribute
-			schemaURN = pathStr
-			attrPath = ""
-		}
+		schemaURN, attrPath := SplitSchemaURN(pathStr)
 
 		// First segment is the schema URN (maps to extension field)
 		parts = append(parts, schemaURN)
@@ -26,7 +26,7 @@ func TestPatchProcessor_Replace(t *testing.T) {
 					{Op: "replace", Path: "active", Value: false},
 				},
 			},
-			checkFunc: func(u *User) bool { return u.Active != nil && !*u.Active },
+			checkFunc: func(u *User) bool { return u.Active != nil && !bool(*u.Active) },
 			wantErr:   false,
 		},
 		{
@@ -48,7 +48,7 @@ func TestPatchProcessor_Replace(t *testing.T) {
 					{Op: "replace", Value: map[string]any{"active": false, "displayName": "Test"}},
 				},
 			},
-			checkFunc: func(u *User) bool { return u.Active != nil && !*u.Active && u.DisplayName == "Test" },
+			checkFunc: func(u *User) bool { return u.Active != nil && !bool(*u.Active) && u.DisplayName == "Test" },
 			wantErr:   false,
 		},
 	}
@@ -139,7 +139,7 @@ func TestPatchProcessor_ComplexPath(t *testing.T) {
 	user := &User{
 		UserName: "john.doe",
 		Emails: []Email{
-			{Value: "john@work.com", Type: "work", Primary: true},
+			{Value: "john@work.com", Type: "work", Primary: Bool(true)},
 			{Value: "john@home.com", Type: "home"},
 		},
 	}
@@ -171,16 +171,16 @@ func TestPatchProcessor_ReplaceFilteredArraySubAttribute(t *testing.T) {
 	user := &User{
 		UserName: "john.doe",
 		Emails: []Email{
-			{Value: "john@work.com", Type: "work", Primary: true},
+			{Value: "john@work.com", Type: "work", Primary: Bool(true)},
 			{Value: "john@home.com", Type: "home"},
 		},
 		PhoneNumbers: []PhoneNumber{
-			{Value: "555-1234", Type: "work", Primary: true},
+			{Value: "555-1234", Type: "work", Primary: Bool(true)},
 			{Value: "555-5678", Type: "mobile"},
 			{Value: "555-9999", Type: "fax"},
 		},
 		Addresses: []Address{
-			{Formatted: "123 Main St", Type: "work", Primary: true},
+			{Formatted: "123 Main St", Type: "work", Primary: Bool(true)},
 			{Formatted: "456 Home St", Type: "home"},
 		},
 	}
@@ -220,7 +220,7 @@ func TestPatchProcessor_ReplaceFilteredArraySubAttribute(t *testing.T) {
 			checkFunc: func(u *User) bool {
 				for _, email := range u.Emails {
 					if email.Type == "work" {
-						return email.Primary == false
+						return email.Primary != nil && !bool(*email.Primary)
 					}
 				}
 				return false
@@ -574,7 +574,7 @@ func TestPatchProcessor_AddWithFilteredPath(t *testing.T) {
 			checkFunc: func(u *User) bool {
 				// Should update the existing "work" email from previous test
 				for _, email := range u.Emails {
-					if email.Type == "work" && email.Value == "john@work.com" && bool(email.Primary) {
+					if email.Type == "work" && email.Value == "john@work.com" && email.Primary.IsTrue() {
 						return true
 					}
 				}
@@ -651,12 +651,12 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 	user := &User{
 		UserName: "john.doe",
 		Emails: []Email{
-			{Value: "john@work.com", Type: "work", Primary: Boolean(true)},
-			{Value: "john@home.com", Type: "home", Primary: Boolean(false)},
+			{Value: "john@work.com", Type: "work", Primary: Bool(true)},
+			{Value: "john@home.com", Type: "home", Primary: Bool(false)},
 		},
 		Roles: []Role{
-			{Value: "admin", Type: "work", Primary: Boolean(true)},
-			{Value: "user", Type: "app", Primary: Boolean(false)},
+			{Value: "admin", Type: "work", Primary: Bool(true)},
+			{Value: "user", Type: "app", Primary: Bool(false)},
 		},
 	}
 
@@ -676,7 +676,7 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 			},
 			checkFunc: func(u *User) bool {
 				for _, email := range u.Emails {
-					if bool(email.Primary) {
+					if email.Primary.IsTrue() {
 						return email.Value == "newemail@work.com"
 					}
 				}
@@ -694,7 +694,7 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 			},
 			checkFunc: func(u *User) bool {
 				for _, role := range u.Roles {
-					if bool(role.Primary) {
+					if role.Primary.IsTrue() {
 						return role.Value == "superadmin"
 					}
 				}
@@ -712,7 +712,7 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 			},
 			checkFunc: func(u *User) bool {
 				for _, email := range u.Emails {
-					if !bool(email.Primary) {
+					if !email.Primary.IsTrue() {
 						return email.Value == "newhome@example.com"
 					}
 				}
@@ -730,7 +730,7 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 			},
 			checkFunc: func(u *User) bool {
 				for _, role := range u.Roles {
-					if !bool(role.Primary) {
+					if !role.Primary.IsTrue() {
 						return role.Value == "guest"
 					}
 				}
@@ -751,12 +751,12 @@ func TestPatchProcessor_BooleanStringFilterComparison(t *testing.T) {
 				emailOk := false
 				roleOk := false
 				for _, email := range u.Emails {
-					if bool(email.Primary) && email.Type == "business" {
+					if email.Primary.IsTrue() && email.Type == "business" {
 						emailOk = true
 					}
 				}
 				for _, role := range u.Roles {
-					if bool(role.Primary) && role.Display == "Administrator" {
+					if role.Primary.IsTrue() && role.Display == "Administrator" {
 						roleOk = true
 					}
 				}
@@ -0,0 +1,121 @@
+package scim
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// projectionAssertions enables checking every attribute projection, see
+// SetProjectionAssertions
+var projectionAssertions atomic.Bool
+
+// SetProjectionAssertions enables or disables checking that attribute selection
+// keeps exactly the requested attributes. With assertions enabled,
+// AttributeSelector.FilterResource compares every projection with the full
+// resource and returns an error instead of a projection that drops a requested
+// attribute, keeps an excluded one or adds one. Every projection is checked,
+// so assertions are meant for tests and staging environments.
+func SetProjectionAssertions(enabled bool) {
+	projectionAssertions.Store(enabled)
+}
+
+// checkProjection verifies that projected is the projection of source the
+// selector's attributes and excluded attributes ask for
+func (as *AttributeSelector) checkProjection(source, projected map[string]any) error {
+	for key := range projected {
+		if _, ok := source[key]; !ok {
+			return fmt.Errorf("attribute projection added %q", key)
+		}
+	}
+
+	for key, value := range source {
+		lowerKey := strings.ToLower(key)
+		got, present := projected[key]
+
+		switch {
+		case lowerKey == "id" || lowerKey == "schemas" || lowerKey == "meta":
+			if !present {
+				return fmt.Errorf("attribute projection dropped %q, which is always returned", key)
+			}
+		case as.excluded[lowerKey]:
+			if present {
+				return fmt.Errorf("attribute projection kept excluded %q", key)
+			}
+		case !as.includeAll && as.attributes[lowerKey]:
+			if !present || !reflect.DeepEqual(got, value) {
+				return fmt.Errorf("attribute projection dropped or changed requested %q", key)
+			}
+		case !as.includeAll && as.subAttributes[lowerKey] != nil:
+			if present {
+				if err := checkSubProjection(key, got, as.subAttributes[lowerKey], true); err != nil {
+					return err
+				}
+			}
+		case !as.includeAll:
+			if present {
+				return fmt.Errorf("attribute projection kept %q, which was not requested", key)
+			}
+		case as.excludedSubAttributes[lowerKey] != nil:
+			if present {
+				if err := checkSubProjection(key, got, as.excludedSubAttributes[lowerKey], false); err != nil {
+					return err
+				}
+			}
+		default:
+			if !present || !reflect.DeepEqual(got, value) {
+				return fmt.Errorf("attribute projection dropped or changed %q", key)
+			}
+		}
+	}
+	return nil
+}
+
+// checkSubProjection verifies that the complex or multi-valued attribute value
+// holds only the sub-attributes paths list (include), or none of those the
+// paths name entirely (exclude)
+func checkSubProjection(key string, value any, paths []string, include bool) error {
+	// Group paths by their immediate child, as filterSubAttributes does
+	children := make(map[string][]string)
+	for _, path := range paths {
+		child, rest, _ := strings.Cut(strings.ToLower(path), ".")
+		if rest == "" {
+			children[child] = nil
+		} else if remainder, seen := children[child]; !seen || remainder != nil {
+			children[child] = append(children[child], rest)
+		}
+	}
+
+	var objects []map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		objects = append(objects, v)
+	case []any:
+		for _, item := range v {
+			if object, ok := item.(map[string]any); ok {
+				objects = append(objects, object)
+			}
+		}
+	default:
+		return nil
+	}
+
+	for _, object := range objects {
+		for subKey, subValue := range object {
+			remainder, named := children[strings.ToLower(subKey)]
+			path := key + "." + subKey
+			switch {
+			case include && !named:
+				return fmt.Errorf("attribute projection kept %q, which was not requested", path)
+			case !include && named && remainder == nil:
+				return fmt.Errorf("attribute projection kept excluded %q", path)
+			case named && remainder != nil:
+				if err := checkSubProjection(path, subValue, remainder, include); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,464 @@
+package scim
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The tests in this file check attribute selection against every JSON member
+// of User, Group and their extensions. Resources are populated by reflection,
+// so attributes added to the types later are covered without changing the
+// tests.
+
+// TestMain runs every test of the package with projection assertions, so any
+// test selecting attributes also checks the projection contract
+func TestMain(m *testing.M) {
+	SetProjectionAssertions(true)
+	os.Exit(m.Run())
+}
+
+const testExtensionUser = "urn:example:params:scim:schemas:extension:acme:2.0:User"
+const testExtensionGroup = "urn:example:params:scim:schemas:extension:acme:2.0:Group"
+
+// zeroValueOmitted lists the omitempty fields whose zero value is a meaningful
+// SCIM value but disappears from the JSON encoding, and why that is harmless.
+// A new field of a bool or numeric type with omitempty must be added here
+// after checking that clients cannot tell its zero value from its absence.
+// Optional booleans are *Boolean, so none are listed today.
+var zeroValueOmitted = map[string]string{}
+
+// populate sets every exported field of v with a JSON name to a non-zero
+// value derived from its path, so it appears in the JSON encoding
+func populate(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString("v-" + path)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(7)
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		populate(v.Elem(), path)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		populate(v.Index(0), path)
+	case reflect.Struct:
+		for _, field := range jsonFields(v.Type()) {
+			populate(v.FieldByIndex(field.Index), path+"."+jsonName(field))
+		}
+	}
+}
+
+// jsonFields returns the exported fields of t encoded as JSON members
+func jsonFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, field := range reflect.VisibleFields(t) {
+		if field.IsExported() && !field.Anonymous && field.Tag.Get("json") != "-" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// jsonName returns the JSON member name of field
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// populatedUser returns a user with every attribute and sub-attribute set
+func populatedUser() *User {
+	user := &User{}
+	populate(reflect.ValueOf(user).Elem(), "user")
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	user.ID = "u1"
+	user.Schemas = []string{SchemaUser, SchemaEnterpriseUser, testExtensionUser}
+	user.Meta = &Meta{ResourceType: "User", Created: &created, LastModified: &created, Version: `W/"1"`}
+	user.EnterpriseUser = map[string]any{
+		"department": "Sales",
+		"manager":    map[string]any{"value": "m1", "displayName": "Boss"},
+	}
+	user.Extensions = map[string]map[string]any{
+		testExtensionUser: {
+			"level": "3",
+			"badge": map[string]any{"id": "b1", "color": "red"},
+		},
+	}
+	return user
+}
+
+// populatedGroup returns a group with every attribute and sub-attribute set
+func populatedGroup() *Group {
+	group := &Group{}
+	populate(reflect.ValueOf(group).Elem(), "group")
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	group.ID = "g1"
+	group.Schemas = []string{SchemaGroup, testExtensionGroup}
+	group.Meta = &Meta{ResourceType: "Group", Created: &created, LastModified: &created, Version: `W/"1"`}
+	group.Extensions = map[string]map[string]any{
+		testExtensionGroup: {"costCenter": "cc-1", "owner": map[string]any{"value": "u1", "display": "Owner"}},
+	}
+	return group
+}
+
+// toMap returns the JSON object resource encodes to
+func toMap(t *testing.T, resource any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	return m
+}
+
+// projectionPath is an attribute path of a resource: the member holding it
+// and the path below that member
+type projectionPath struct {
+	attr string   // path as passed to attributes or excludedAttributes
+	key  string   // top-level JSON member
+	sub  []string // members below key
+}
+
+// projectionPaths returns every attribute and sub-attribute path of the
+// resource encoded as source, except the always returned id, schemas and meta
+func projectionPaths(source map[string]any) []projectionPath {
+	var paths []projectionPath
+	for key, value := range source {
+		if key == "id" || key == "schemas" || key == "meta" {
+			continue
+		}
+		paths = append(paths, projectionPath{attr: key, key: key})
+
+		// Sub-attributes of extensions are qualified by the URN
+		sep := "."
+		if strings.HasPrefix(key, "urn:") {
+			sep = ":"
+		}
+		var walk func(prefix string, sub []string, value any)
+		walk = func(prefix string, sub []string, value any) {
+			if items, ok := value.([]any); ok && len(items) > 0 {
+				value = items[0]
+			}
+			object, ok := value.(map[string]any)
+			if !ok {
+				return
+			}
+			for name, child := range object {
+				childSub := append(slices.Clone(sub), name)
+				attr := prefix + "." + name
+				if len(sub) == 0 {
+					attr = prefix + sep + name
+				}
+				paths = append(paths, projectionPath{attr: attr, key: key, sub: childSub})
+				walk(attr, childSub, child)
+			}
+		}
+		walk(key, nil, value)
+	}
+	slices.SortFunc(paths, func(a, b projectionPath) int { return strings.Compare(a.attr, b.attr) })
+	return paths
+}
+
+// expectedProjection returns source projected to path (include) or without
+// path (exclude), built independently of AttributeSelector
+func expectedProjection(source map[string]any, path projectionPath, include bool) map[string]any {
+	want := make(map[string]any)
+	for key, value := range source {
+		switch {
+		case key == "id" || key == "schemas" || key == "meta":
+			want[key] = value
+		case key != path.key:
+			if !include {
+				want[key] = value
+			}
+		case len(path.sub) == 0:
+			if include {
+				want[key] = value
+			}
+		default:
+			want[key] = projectValue(value, path.sub, include)
+		}
+	}
+	return want
+}
+
+// projectValue keeps (include) or removes the members sub of a complex or
+// multi-valued value
+func projectValue(value any, sub []string, include bool) any {
+	if items, ok := value.([]any); ok {
+		projected := make([]any, len(items))
+		for i, item := range items {
+			projected[i] = projectValue(item, sub, include)
+		}
+		return projected
+	}
+
+	object := value.(map[string]any)
+	projected := make(map[string]any)
+	for name, child := range object {
+		switch {
+		case name != sub[0]:
+			if !include {
+				projected[name] = child
+			}
+		case len(sub) > 1:
+			projected[name] = projectValue(child, sub[1:], include)
+		case include:
+			projected[name] = child
+		}
+	}
+	return projected
+}
+
+// TestProjectionContract selects and excludes every attribute and
+// sub-attribute of fully populated resources
+func TestProjectionContract(t *testing.T) {
+	resources := map[string]any{
+		"User":  populatedUser(),
+		"Group": populatedGroup(),
+	}
+
+	for name, resource := range resources {
+		source := toMap(t, resource)
+
+		// Every struct field must be populated, or its paths go untested
+		for _, field := range jsonFields(reflect.TypeOf(resource).Elem()) {
+			if _, ok := source[jsonName(field)]; !ok {
+				t.Errorf("%s.%s is missing from the populated %s; extend populate", name, field.Name, name)
+			}
+		}
+
+		for _, path := range projectionPaths(source) {
+			// Attribute names are case-insensitive; URNs are matched as given
+			attrs := []string{path.attr}
+			if !strings.HasPrefix(path.attr, "urn:") {
+				attrs = append(attrs, strings.ToUpper(path.attr))
+			}
+
+			for _, attr := range attrs {
+				for _, include := range []bool{true, false} {
+					selector := NewAttributeSelector([]string{attr}, nil)
+					param := "attributes"
+					if !include {
+						selector = NewAttributeSelector(nil, []string{attr})
+						param = "excludedAttributes"
+					}
+
+					got, err := selector.FilterResource(resource)
+					if err != nil {
+						t.Errorf("%s %s=%s: FilterResource() error = %v", name, param, attr, err)
+						continue
+					}
+					want := expectedProjection(source, path, include)
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("%s %s=%s:\ngot  %v\nwant %v", name, param, attr, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
+// TestProjectionZeroValues guards against omitempty fields whose zero value
+// silently disappears from responses
+func TestProjectionZeroValues(t *testing.T) {
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(typ reflect.Type) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] || typ == reflect.TypeFor[time.Time]() {
+			return
+		}
+		seen[typ] = true
+
+		typeName, _, _ := strings.Cut(typ.Name(), "[")
+		for _, field := range jsonFields(typ) {
+			walk(field.Type)
+
+			if !strings.Contains(field.Tag.Get("json"), ",omitempty") {
+				continue
+			}
+			switch field.Type.Kind() {
+			case reflect.Bool, reflect.Int, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+				key := typeName + "." + field.Name
+				if _, ok := zeroValueOmitted[key]; !ok {
+					t.Errorf("%s has omitempty, so its zero value disappears from responses; "+
+						"use a pointer or add it to zeroValueOmitted", key)
+				}
+			}
+		}
+	}
+	walk(reflect.TypeFor[User]())
+	walk(reflect.TypeFor[Group]())
+
+	// active is a pointer, so false is returned when selected
+	user := &User{ID: "u1", Schemas: []string{SchemaUser}, UserName: "john", Active: Bool(false)}
+	got, err := NewAttributeSelector([]string{"active"}, nil).FilterResource(user)
+	if err != nil {
+		t.Fatalf("FilterResource() error = %v", err)
+	}
+	if active, ok := got.(map[string]any)["active"]; !ok || active != false {
+		t.Errorf("attributes=active: active = %v, %v, want false", active, ok)
+	}
+
+	// An explicit primary=false is kept; an unset primary is omitted, which
+	// RFC 7643 treats as false
+	user.Emails = []Email{
+		{Value: "work@example.com", Type: "work", Primary: Bool(true)},
+		{Value: "home@example.com", Type: "home", Primary: Bool(false)},
+		{Value: "other@example.com", Type: "other"},
+	}
+	got, err = NewAttributeSelector([]string{"emails.primary"}, nil).FilterResource(user)
+	if err != nil {
+		t.Fatalf("FilterResource() error = %v", err)
+	}
+	want := []any{map[string]any{"primary": true}, map[string]any{"primary": false}}
+	if emails := got.(map[string]any)["emails"]; !reflect.DeepEqual(emails, want) {
+		t.Errorf("attributes=emails.primary: emails = %v, want %v", emails, want)
+	}
+
+	got, err = NewAttributeSelector(nil, []string{"emails.primary"}).FilterResource(user)
+	if err != nil {
+		t.Fatalf("FilterResource() error = %v", err)
+	}
+	want = []any{
+		map[string]any{"value": "work@example.com", "type": "work"},
+		map[string]any{"value": "home@example.com", "type": "home"},
+		map[string]any{"value": "other@example.com", "type": "other"},
+	}
+	if emails := got.(map[string]any)["emails"]; !reflect.DeepEqual(emails, want) {
+		t.Errorf("excludedAttributes=emails.primary: emails = %v, want %v", emails, want)
+	}
+}
+
+func TestBooleanJSON(t *testing.T) {
+	data := []byte(`{"emails":[{"value":"a","primary":false},{"value":"b","primary":"True"},{"value":"c"}]}`)
+	var user User
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if p := user.Emails[0].Primary; p == nil || bool(*p) {
+		t.Errorf("primary false decoded as %v, want explicit false", p)
+	}
+	if !user.Emails[1].Primary.IsTrue() {
+		t.Error(`primary "True" should decode as true`)
+	}
+	if user.Emails[2].Primary != nil {
+		t.Errorf("unset primary decoded as %v, want nil", *user.Emails[2].Primary)
+	}
+
+	out, err := json.Marshal(user.Emails)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	want := `[{"value":"a","primary":false},{"value":"b","primary":true},{"value":"c"}]`
+	if string(out) != want {
+		t.Errorf("Marshal() = %s, want %s", out, want)
+	}
+}
+
+func TestCheckProjection(t *testing.T) {
+	source := map[string]any{
+		"id":       "u1",
+		"schemas":  []any{SchemaUser},
+		"userName": "john",
+		"title":    "Engineer",
+		"emails":   []any{map[string]any{"value": "a@example.com", "type": "work"}},
+		"name":     map[string]any{"givenName": "John", "familyName": "Doe"},
+	}
+
+	tests := []struct {
+		name      string
+		attrs     []string
+		excluded  []string
+		projected map[string]any
+		wantErr   string
+	}{
+		{
+			name:      "valid selection",
+			attrs:     []string{"userName", "emails.type"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "userName": "john", "emails": []any{map[string]any{"type": "work"}}},
+		},
+		{
+			name:      "added attribute",
+			attrs:     []string{"userName"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "userName": "john", "password": "secret"},
+			wantErr:   `added "password"`,
+		},
+		{
+			name:      "dropped id",
+			attrs:     []string{"userName"},
+			projected: map[string]any{"schemas": []any{SchemaUser}, "userName": "john"},
+			wantErr:   `dropped "id"`,
+		},
+		{
+			name:      "dropped requested attribute",
+			attrs:     []string{"userName", "title"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "userName": "john"},
+			wantErr:   `requested "title"`,
+		},
+		{
+			name:      "unrequested attribute",
+			attrs:     []string{"userName"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "userName": "john", "title": "Engineer"},
+			wantErr:   `kept "title"`,
+		},
+		{
+			name:      "unrequested sub-attribute",
+			attrs:     []string{"emails.type"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "emails": []any{map[string]any{"value": "a@example.com", "type": "work"}}},
+			wantErr:   `kept "emails.value"`,
+		},
+		{
+			name:      "excluded attribute",
+			excluded:  []string{"title"},
+			projected: source,
+			wantErr:   `kept excluded "title"`,
+		},
+		{
+			name:      "excluded sub-attribute",
+			excluded:  []string{"name.givenName"},
+			projected: source,
+			wantErr:   `kept excluded "name.givenName"`,
+		},
+		{
+			name:     "changed attribute",
+			excluded: []string{"title"},
+			projected: map[string]any{"id": "u1", "schemas": []any{SchemaUser}, "userName": "jane",
+				"emails": source["emails"], "name": source["name"]},
+			wantErr: `changed "userName"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewAttributeSelector(tt.attrs, tt.excluded).checkProjection(source, tt.projected)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkProjection() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkProjection() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -7,12 +7,13 @@ import (
 // ApplyResourceFilter applies a SCIM filter expression to a slice of resources
 // Returns filtered resources or an error if the filter is invalid
 func ApplyResourceFilter[T any](resources []T, filter string) ([]T, error) {
-	if filter == "" {
-		return resources, nil
-	}
+	return applyQueryFilter(resources, QueryParams{Filter: filter})
+}
 
-	parser := NewFilterParser(filter)
-	expr, err := parser.Parse()
+// applyQueryFilter applies params.Filter like ApplyResourceFilter, ignoring
+// accents if params.AccentInsensitive is set
+func applyQueryFilter[T any](resources []T, params QueryParams) ([]T, error) {
+	expr, err := parseQueryFilter(params)
 	if err != nil {
 		return nil, ErrInvalidFilter(err.Error())
 	}
@@ -93,14 +94,14 @@ func ApplyAttributeSelection[T any](resources []T, attributes, excludedAttr []st
 // (filtering, pagination, attribute selection) to a list of resources
 func ProcessListQuery[T any](allResources []T, params QueryParams) (*ListResponse[T], error) {
 	// Apply filter if provided
-	filtered, err := ApplyResourceFilter(allResources, params.Filter)
+	filtered, err := applyQueryFilter(allResources, params)
 	if err != nil {
 		return nil, err
 	}
 
 	totalResults := len(filtered)
 
-	sorted := SortResources(filtered, params.SortBy, params.SortOrder)
+	sorted := SortResourcesCollated(filtered, params.SortBy, params.SortOrder, params.Collation)
 
 	// Apply pagination
 	paged, startIndex, itemsPerPage := ApplyResourcePagination(sorted, params.StartIndex, params.Count)
@@ -0,0 +1,366 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaExtension is an extension schema registered for the User or Group
+// resource type
+type SchemaExtension struct {
+	// ResourceType is ResourceTypeUser or ResourceTypeGroup
+	ResourceType string
+
+	// Schema defines the extension's attributes. Its ID is the extension URN,
+	// which must end with ":User" or ":Group" matching ResourceType so that
+	// attribute paths like "urn:example:scim:1.0:User:costCenter" can be split.
+	Schema *SchemaDefinition
+
+	// Required makes resources without extension data invalid
+	Required bool
+}
+
+// SchemaRegistry holds the extension schemas a server accepts in addition to
+// the built-in ones. Registered extensions are listed by the /Schemas and
+// /ResourceTypes endpoints, and their attributes are validated on create and
+// replace and patched by URN-qualified paths. Data under extension URNs that
+// are not registered is dropped, as it was before extensions could be registered.
+//
+// The enterprise User extension is always accepted; registering its schema
+// additionally lists and validates it.
+type SchemaRegistry struct {
+	mu         sync.RWMutex
+	extensions []SchemaExtension
+
+	// mutability overrides attribute mutability by resource type and
+	// lower-case attribute path
+	mutability map[string]map[string]string
+}
+
+// NewSchemaRegistry creates an empty schema registry
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{}
+}
+
+// Register adds an extension schema. It fails if the extension is invalid or its
+// URN is already registered.
+func (r *SchemaRegistry) Register(ext SchemaExtension) error {
+	if ext.ResourceType != ResourceTypeUser && ext.ResourceType != ResourceTypeGroup {
+		return fmt.Errorf("schema extension resource type must be %s or %s, got %q",
+			ResourceTypeUser, ResourceTypeGroup, ext.ResourceType)
+	}
+	if ext.Schema == nil || ext.Schema.ID == "" {
+		return errors.New("schema extension requires a schema with an ID")
+	}
+
+	urn := ext.Schema.ID
+	if !strings.HasPrefix(urn, "urn:") || !strings.HasSuffix(urn, ":"+ext.ResourceType) {
+		return fmt.Errorf("schema extension %s must be a URN ending in :%s", urn, ext.ResourceType)
+	}
+	if isCoreSchema(urn) {
+		return fmt.Errorf("schema %s is a core schema", urn)
+	}
+	if err := validateAttributeDefinitions(ext.Schema.Attributes); err != nil {
+		return fmt.Errorf("schema extension %s: %w", urn, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lookup(urn) != nil {
+		return fmt.Errorf("schema extension %s is already registered", urn)
+	}
+	r.extensions = append(r.extensions, ext)
+	return nil
+}
+
+// Lookup returns the registered extension with the given URN, compared
+// case-insensitively
+func (r *SchemaRegistry) Lookup(urn string) (SchemaExtension, bool) {
+	if r == nil {
+		return SchemaExtension{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if ext := r.lookup(urn); ext != nil {
+		return *ext, true
+	}
+	return SchemaExtension{}, false
+}
+
+// lookup returns the registered extension with the given URN. The caller must
+// hold r.mu.
+func (r *SchemaRegistry) lookup(urn string) *SchemaExtension {
+	for i := range r.extensions {
+		if strings.EqualFold(r.extensions[i].Schema.ID, urn) {
+			return &r.extensions[i]
+		}
+	}
+	return nil
+}
+
+// Extensions returns the extensions registered for a resource type, in
+// registration order
+func (r *SchemaRegistry) Extensions(resourceType string) []SchemaExtension {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var extensions []SchemaExtension
+	for _, ext := range r.extensions {
+		if ext.ResourceType == resourceType {
+			extensions = append(extensions, ext)
+		}
+	}
+	return extensions
+}
+
+// attributeTypes are the SCIM attribute data types (RFC 7643 Section 2.3)
+var attributeTypes = []string{"string", "boolean", "decimal", "integer", "dateTime", "binary", "reference", "complex"}
+
+// validateAttributeDefinitions checks that every attribute has a name, a known
+// type and, if set, a known mutability
+func validateAttributeDefinitions(attributes []AttributeDefinition) error {
+	for _, attr := range attributes {
+		if attr.Name == "" {
+			return errors.New("attribute name is required")
+		}
+		if !slices.Contains(attributeTypes, attr.Type) {
+			return fmt.Errorf("attribute %s has unknown type %q", attr.Name, attr.Type)
+		}
+		if attr.Mutability != "" && !slices.Contains(mutabilities, attr.Mutability) {
+			return fmt.Errorf("attribute %s has unknown mutability %q", attr.Name, attr.Mutability)
+		}
+		if err := validateAttributeDefinitions(attr.SubAttributes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateExtensions validates the extension data of a resource against the
+// extensions registered for its resource type and drops the data of
+// unregistered extensions. enterprise is the typed enterprise extension data of
+// a user, validated only if its schema is registered.
+func (r *SchemaRegistry) validateExtensions(resourceType string, extensions map[string]map[string]any, enterprise map[string]any) error {
+	var errs attributeErrors
+	r.checkExtensions(&errs, resourceType, extensions, enterprise)
+	return errs.err()
+}
+
+// checkExtensions is validateExtensions adding every problem to errs
+func (r *SchemaRegistry) checkExtensions(errs *attributeErrors, resourceType string, extensions map[string]map[string]any, enterprise map[string]any) {
+	for urn := range extensions {
+		if ext, ok := r.Lookup(urn); !ok || ext.ResourceType != resourceType {
+			delete(extensions, urn)
+		}
+	}
+
+	for _, ext := range r.Extensions(resourceType) {
+		var data map[string]any
+		if ext.Schema.ID == SchemaEnterpriseUser {
+			data = enterprise
+		} else {
+			data = extensionData(extensions, ext.Schema.ID)
+		}
+
+		if len(data) == 0 {
+			if ext.Required {
+				errs.addf("schema extension %s is required", ext.Schema.ID)
+			}
+			continue
+		}
+		checkAttributes(errs, ext.Schema.ID+":", ext.Schema.Attributes, data)
+	}
+}
+
+// extensionData returns the data of the extension with the given URN, compared
+// case-insensitively
+func extensionData(extensions map[string]map[string]any, urn string) map[string]any {
+	if data, ok := extensions[urn]; ok {
+		return data
+	}
+	for key, data := range extensions {
+		if strings.EqualFold(key, urn) {
+			return data
+		}
+	}
+	return nil
+}
+
+// attributeErrors collects the problems found validating the attributes of
+// a resource, each naming the attribute path, so a client learns of all of
+// them with one request
+type attributeErrors []string
+
+// addf adds a problem, unless it was already found
+func (e *attributeErrors) addf(format string, args ...any) {
+	problem := fmt.Sprintf(format, args...)
+	if !slices.Contains(*e, problem) {
+		*e = append(*e, problem)
+	}
+}
+
+// err returns the problems as an invalidValue error listing them, separated
+// by "; ", or nil if there are none
+func (e attributeErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return ErrInvalidValue(strings.Join(e, "; "))
+}
+
+// validateAttributes validates the attributes of a complex value: every
+// attribute must be defined and of its defined type, and required attributes
+// must be present. prefix qualifies attribute names in error messages, e.g.
+// "urn:example:scim:1.0:User:" or "urn:example:scim:1.0:User:manager.".
+func validateAttributes(prefix string, definitions []AttributeDefinition, data map[string]any) error {
+	var errs attributeErrors
+	checkAttributes(&errs, prefix, definitions, data)
+	return errs.err()
+}
+
+// checkAttributes is validateAttributes adding every problem to errs
+func checkAttributes(errs *attributeErrors, prefix string, definitions []AttributeDefinition, data map[string]any) {
+	for _, name := range slices.Sorted(maps.Keys(data)) {
+		def := findAttributeDefinition(definitions, name)
+		if def == nil {
+			errs.addf("attribute %s%s is not defined", prefix, name)
+			continue
+		}
+		checkAttributeValue(errs, prefix+def.Name, def, data[name])
+	}
+
+	for _, def := range definitions {
+		if !def.Required {
+			continue
+		}
+		if key, ok := findKey(data, def.Name); !ok || data[key] == nil || data[key] == "" {
+			errs.addf("%s%s is required", prefix, def.Name)
+		}
+	}
+}
+
+// validateAttributeValue checks that value matches the attribute's type and
+// multi-valuedness. Null values are always valid.
+func validateAttributeValue(path string, def *AttributeDefinition, value any) error {
+	var errs attributeErrors
+	checkAttributeValue(&errs, path, def, value)
+	return errs.err()
+}
+
+// checkAttributeValue is validateAttributeValue adding every problem to
+// errs. The values of a multi-valued attribute are named by their index,
+// e.g. emails[1].type, and at most one of them may be primary (RFC 7643
+// Section 2.4).
+func checkAttributeValue(errs *attributeErrors, path string, def *AttributeDefinition, value any) {
+	if value == nil {
+		return
+	}
+
+	if def.MultiValued {
+		values, ok := value.([]any)
+		if !ok {
+			errs.addf("%s must be an array", path)
+			return
+		}
+		primaries := 0
+		for i, v := range values {
+			checkSingleValue(errs, fmt.Sprintf("%s[%d]", path, i), def, v)
+			if m, ok := v.(map[string]any); ok {
+				if key, ok := findKey(m, "primary"); ok && m[key] == true {
+					primaries++
+				}
+			}
+		}
+		if primaries > 1 {
+			errs.addf("%s must have at most one primary value", path)
+		}
+		return
+	}
+
+	checkSingleValue(errs, path, def, value)
+}
+
+// validateSingleValue checks one value of an attribute against its type
+func validateSingleValue(path string, def *AttributeDefinition, value any) error {
+	var errs attributeErrors
+	checkSingleValue(&errs, path, def, value)
+	return errs.err()
+}
+
+// checkSingleValue is validateSingleValue adding every problem to errs.
+// Strings of attributes with canonical values must be one of them, compared
+// case-insensitively.
+func checkSingleValue(errs *attributeErrors, path string, def *AttributeDefinition, value any) {
+	valid := false
+	switch def.Type {
+	case "string", "binary", "reference":
+		var s string
+		s, valid = value.(string)
+		if valid && len(def.CanonicalValues) > 0 && !slices.ContainsFunc(def.CanonicalValues, func(c string) bool {
+			return strings.EqualFold(c, s)
+		}) {
+			errs.addf("%s must be one of %s, got %q", path, strings.Join(def.CanonicalValues, ", "), s)
+			return
+		}
+	case "boolean":
+		_, valid = value.(bool)
+	case "decimal":
+		valid = toFloat64(value) != nil
+	case "integer":
+		f := toFloat64(value)
+		valid = f != nil && *f == math.Trunc(*f)
+	case "dateTime":
+		if s, ok := value.(string); ok {
+			_, err := time.Parse(time.RFC3339, s)
+			valid = err == nil
+		}
+	case "complex":
+		data, ok := value.(map[string]any)
+		if !ok {
+			break
+		}
+		checkAttributes(errs, path+".", def.SubAttributes, data)
+		return
+	}
+
+	if !valid {
+		errs.addf("%s must be of type %s", path, def.Type)
+	}
+}
+
+// findAttributeDefinition returns the definition of the named attribute,
+// compared case-insensitively
+func findAttributeDefinition(definitions []AttributeDefinition, name string) *AttributeDefinition {
+	for i := range definitions {
+		if strings.EqualFold(definitions[i].Name, name) {
+			return &definitions[i]
+		}
+	}
+	return nil
+}
+
+// findKey returns the key of m matching name case-insensitively
+func findKey(m map[string]any, name string) (string, bool) {
+	if _, ok := m[name]; ok {
+		return name, true
+	}
+	for key := range m {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,381 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testExtensionURN = "urn:example:scim:schemas:extension:acme:1.0:User"
+
+// testExtension is a User extension with simple, multi-valued and complex attributes
+func testExtension(required bool) SchemaExtension {
+	return SchemaExtension{
+		ResourceType: ResourceTypeUser,
+		Required:     required,
+		Schema: &SchemaDefinition{
+			ID:   testExtensionURN,
+			Name: "AcmeUser",
+			Attributes: []AttributeDefinition{
+				{Name: "costCenter", Type: "string", Required: true},
+				{Name: "level", Type: "integer"},
+				{Name: "badges", Type: "string", MultiValued: true},
+				{Name: "hiredAt", Type: "dateTime"},
+				{
+					Name: "devices", Type: "complex", MultiValued: true,
+					SubAttributes: []AttributeDefinition{
+						{Name: "type", Type: "string"},
+						{Name: "serial", Type: "string"},
+					},
+				},
+				{
+					Name: "sponsor", Type: "complex",
+					SubAttributes: []AttributeDefinition{
+						{Name: "value", Type: "string", Required: true},
+						{Name: "displayName", Type: "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newTestRegistry(t *testing.T, required bool) *SchemaRegistry {
+	t.Helper()
+	registry := NewSchemaRegistry()
+	if err := registry.Register(testExtension(required)); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return registry
+}
+
+func TestSchemaRegistryRegister(t *testing.T) {
+	registry := newTestRegistry(t, false)
+
+	ext, ok := registry.Lookup(strings.ToUpper(testExtensionURN))
+	if !ok || ext.Schema.ID != testExtensionURN {
+		t.Errorf("Lookup() = %v, %v, want the registered extension", ext, ok)
+	}
+	if got := registry.Extensions(ResourceTypeUser); len(got) != 1 {
+		t.Errorf("Extensions(User) = %v, want 1 extension", got)
+	}
+	if got := registry.Extensions(ResourceTypeGroup); len(got) != 0 {
+		t.Errorf("Extensions(Group) = %v, want none", got)
+	}
+
+	invalid := map[string]SchemaExtension{
+		"duplicate":             testExtension(false),
+		"unknown resource type": {ResourceType: "Device", Schema: &SchemaDefinition{ID: "urn:example:Device"}},
+		"no schema":             {ResourceType: ResourceTypeUser},
+		"not a URN":             {ResourceType: ResourceTypeUser, Schema: &SchemaDefinition{ID: "acme:User"}},
+		"wrong suffix":          {ResourceType: ResourceTypeGroup, Schema: &SchemaDefinition{ID: "urn:example:acme:User"}},
+		"core schema":           {ResourceType: ResourceTypeUser, Schema: &SchemaDefinition{ID: SchemaUser}},
+		"unknown attribute type": {ResourceType: ResourceTypeUser, Schema: &SchemaDefinition{
+			ID:         "urn:example:other:User",
+			Attributes: []AttributeDefinition{{Name: "size", Type: "float"}},
+		}},
+	}
+	for name, ext := range invalid {
+		if err := registry.Register(ext); err == nil {
+			t.Errorf("Register() with %s should fail", name)
+		}
+	}
+}
+
+func TestValidatorExtensions(t *testing.T) {
+	tests := []struct {
+		name       string
+		required   bool
+		extensions map[string]map[string]any
+		wantErr    string
+	}{
+		{
+			name: "valid data",
+			extensions: map[string]map[string]any{testExtensionURN: {
+				"costCenter": "CC-1",
+				"level":      float64(3),
+				"badges":     []any{"a", "b"},
+				"hiredAt":    "2024-01-02T03:04:05Z",
+				"devices":    []any{map[string]any{"type": "laptop", "serial": "X1"}},
+				"sponsor":    map[string]any{"value": "u1"},
+			}},
+		},
+		{
+			name:       "attribute names are case-insensitive",
+			extensions: map[string]map[string]any{testExtensionURN: {"COSTCENTER": "CC-1"}},
+		},
+		{
+			name: "no data for optional extension",
+		},
+		{
+			name:     "missing required extension",
+			required: true,
+			wantErr:  "schema extension " + testExtensionURN + " is required",
+		},
+		{
+			name:       "missing required attribute",
+			extensions: map[string]map[string]any{testExtensionURN: {"level": float64(1)}},
+			wantErr:    "costCenter is required",
+		},
+		{
+			name:       "undefined attribute",
+			extensions: map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "shoeSize": "44"}},
+			wantErr:    "shoeSize is not defined",
+		},
+		{
+			name:       "wrong type",
+			extensions: map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "level": 1.5}},
+			wantErr:    testExtensionURN + ":level must be of type integer",
+		},
+		{
+			name:       "single value for multi-valued attribute",
+			extensions: map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "badges": "a"}},
+			wantErr:    "badges must be an array",
+		},
+		{
+			name:       "invalid dateTime",
+			extensions: map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "hiredAt": "yesterday"}},
+			wantErr:    "hiredAt must be of type dateTime",
+		},
+		{
+			name:       "missing required sub-attribute",
+			extensions: map[string]map[string]any{testExtensionURN: {"costCenter": "CC-1", "sponsor": map[string]any{"displayName": "Bob"}}},
+			wantErr:    testExtensionURN + ":sponsor.value is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewValidatorWithSchemas(newTestRegistry(t, tt.required))
+			user := &User{UserName: "alice", Extensions: tt.extensions}
+
+			err := validator.ValidateUser(user)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateUser() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateUser() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorDropsUnregisteredExtensions(t *testing.T) {
+	user := &User{
+		UserName: "alice",
+		Extensions: map[string]map[string]any{
+			testExtensionURN:               {"costCenter": "CC-1"},
+			"urn:example:unknown:1.0:User": {"foo": "bar"},
+		},
+	}
+
+	if err := NewValidatorWithSchemas(newTestRegistry(t, false)).ValidateUser(user); err != nil {
+		t.Fatalf("ValidateUser() error = %v", err)
+	}
+	if len(user.Extensions) != 1 || user.Extensions[testExtensionURN] == nil {
+		t.Errorf("Extensions = %v, want only the registered extension", user.Extensions)
+	}
+
+	// Without a registry every extension is dropped, as before registration existed
+	if err := NewValidator().ValidateUser(user); err != nil {
+		t.Fatalf("ValidateUser() error = %v", err)
+	}
+	if len(user.Extensions) != 0 {
+		t.Errorf("Extensions = %v, want none", user.Extensions)
+	}
+}
+
+func TestValidatePatchExtensions(t *testing.T) {
+	validator := NewValidatorWithSchemas(newTestRegistry(t, false))
+
+	tests := []struct {
+		name    string
+		op      PatchOperation
+		wantErr string
+	}{
+		{name: "attribute", op: PatchOperation{Op: "replace", Path: testExtensionURN + ":costCenter", Value: "CC-2"}},
+		{name: "sub-attribute", op: PatchOperation{Op: "add", Path: testExtensionURN + ":sponsor.displayName", Value: "Bob"}},
+		{name: "filtered element", op: PatchOperation{Op: "replace", Path: testExtensionURN + `:devices[type eq "laptop"].serial`, Value: "X2"}},
+		{name: "single value added to multi-valued", op: PatchOperation{Op: "add", Path: testExtensionURN + ":badges", Value: "c"}},
+		{name: "remove", op: PatchOperation{Op: "remove", Path: testExtensionURN + ":level"}},
+		{name: "extension object", op: PatchOperation{Op: "add", Path: testExtensionURN, Value: map[string]any{"level": float64(2)}}},
+		{name: "enterprise extension", op: PatchOperation{Op: "add", Path: SchemaEnterpriseUser + ":department", Value: "Sales"}},
+		{name: "core attribute", op: PatchOperation{Op: "replace", Path: "displayName", Value: "Alice"}},
+		{
+			name:    "unknown extension",
+			op:      PatchOperation{Op: "add", Path: "urn:example:unknown:1.0:User:foo", Value: "bar"},
+			wantErr: "unknown schema extension",
+		},
+		{
+			name:    "undefined attribute",
+			op:      PatchOperation{Op: "add", Path: testExtensionURN + ":shoeSize", Value: "44"},
+			wantErr: "shoeSize is not defined",
+		},
+		{
+			name:    "undefined sub-attribute",
+			op:      PatchOperation{Op: "add", Path: testExtensionURN + ":sponsor.email", Value: "x"},
+			wantErr: "sponsor.email is not defined",
+		},
+		{
+			name:    "wrong type",
+			op:      PatchOperation{Op: "replace", Path: testExtensionURN + ":level", Value: "high"},
+			wantErr: "level must be of type integer",
+		},
+		{
+			name:    "wrong type in root value",
+			op:      PatchOperation{Op: "replace", Value: map[string]any{testExtensionURN: map[string]any{"level": "high"}}},
+			wantErr: "level must be of type integer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidatePatchExtensions(ResourceTypeUser, &PatchOp{Operations: []PatchOperation{tt.op}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidatePatchExtensions() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidatePatchExtensions() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Unregistered extension data in a root value is dropped
+	value := map[string]any{
+		"displayName":                  "Alice",
+		"urn:example:unknown:1.0:User": map[string]any{"foo": "bar"},
+	}
+	patch := &PatchOp{Operations: []PatchOperation{{Op: "add", Value: value}}}
+	if err := validator.ValidatePatchExtensions(ResourceTypeUser, patch); err != nil {
+		t.Fatalf("ValidatePatchExtensions() error = %v", err)
+	}
+	if _, ok := value["urn:example:unknown:1.0:User"]; ok || value["displayName"] != "Alice" {
+		t.Errorf("value = %v, want the unknown extension dropped", value)
+	}
+}
+
+func TestServerSchemaExtensions(t *testing.T) {
+	plugin := newMockPlugin()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	srv.SetSchemaRegistry(newTestRegistry(t, false))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+
+	// Discovery lists the extension
+	w := do("GET", "/test/Schemas", "")
+	if !strings.Contains(w.Body.String(), testExtensionURN) {
+		t.Errorf("Schemas does not list the extension: %s", w.Body.String())
+	}
+	w = do("GET", "/test/ResourceTypes", "")
+	var resourceTypes struct{ Resources []ResourceTypeDefinition }
+	if err := json.Unmarshal(w.Body.Bytes(), &resourceTypes); err != nil {
+		t.Fatal(err)
+	}
+	userType := resourceTypes.Resources[0]
+	if len(userType.SchemaExtensions) != 2 || userType.SchemaExtensions[1].Schema != testExtensionURN {
+		t.Errorf("User schemaExtensions = %v, want enterprise and the registered extension", userType.SchemaExtensions)
+	}
+
+	// Create keeps registered extension data and drops unknown extensions
+	w = do("POST", "/test/Users", `{
+		"schemas": ["`+SchemaUser+`", "`+testExtensionURN+`"],
+		"userName": "alice",
+		"`+testExtensionURN+`": {"costCenter": "CC-1"},
+		"urn:example:unknown:1.0:User": {"foo": "bar"}
+	}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	var created User
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Extensions[testExtensionURN]["costCenter"] != "CC-1" {
+		t.Errorf("created extensions = %v", created.Extensions)
+	}
+	if _, ok := created.Extensions["urn:example:unknown:1.0:User"]; ok {
+		t.Error("unknown extension should be dropped")
+	}
+
+	w = do("POST", "/test/Users", `{"userName": "bob", "`+testExtensionURN+`": {"costCenter": 7}}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalidValue") {
+		t.Errorf("create with invalid extension data: status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Patch updates the extension through URN-qualified paths
+	w = do("PATCH", "/test/Users/"+created.ID, `{
+		"schemas": ["`+SchemaPatchOp+`"],
+		"Operations": [{"op": "replace", "path": "`+testExtensionURN+`:costCenter", "value": "CC-2"}]
+	}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"costCenter":"CC-2"`) {
+		t.Errorf("patch status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	w = do("PATCH", "/test/Users/"+created.ID, `{
+		"schemas": ["`+SchemaPatchOp+`"],
+		"Operations": [{"op": "add", "path": "urn:example:unknown:1.0:User:foo", "value": "bar"}]
+	}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalidPath") {
+		t.Errorf("patch of unknown extension: status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	// Attribute selection by URN-qualified name
+	w = do("GET", "/test/Users/"+created.ID+"?attributes="+testExtensionURN+":costCenter", "")
+	var selected map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &selected); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := selected["userName"]; ok {
+		t.Errorf("selected = %v, want only the extension attribute", selected)
+	}
+	if ext, _ := selected[testExtensionURN].(map[string]any); ext["costCenter"] != "CC-2" {
+		t.Errorf("selected = %v, want the extension attribute", selected)
+	}
+}
+
+func TestBulkSchemaExtensions(t *testing.T) {
+	plugin := newMockPlugin()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	srv.SetSchemaRegistry(newTestRegistry(t, true))
+
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice", "` + testExtensionURN + `": {"costCenter": "CC-1"}}},
+			{"method": "POST", "path": "/Users", "data": {"userName": "bob"}}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewReader([]byte(body)))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v, body: %s", err, w.Body.String())
+	}
+	if resp.Operations[0].Status != "201" || resp.Operations[1].Status != "400" {
+		t.Errorf("statuses = %s, %s, want 201 and 400 for the missing required extension",
+			resp.Operations[0].Status, resp.Operations[1].Status)
+	}
+
+	users, _ := plugin.GetUsers(context.Background(), QueryParams{})
+	if users.TotalResults != 1 || users.Resources[0].Extensions[testExtensionURN]["costCenter"] != "CC-1" {
+		t.Errorf("stored users = %v", users.Resources)
+	}
+}
@@ -0,0 +1,52 @@
+package scim
+
+import "context"
+
+// UserReplacer is an optional interface for plugins that can replace a user
+// in place. The server prefers it for PUT requests over deleting and
+// recreating the user, which changes the ID in backends that assign their
+// own IDs and loses meta.created. ReplaceUser returns the stored user; the
+// user it receives already carries the ID of the path.
+//
+// The server discovers the interface through wrappers such as the plugin
+// adapter, so plugin.Plugin implementations can implement it directly.
+type UserReplacer interface {
+	ReplaceUser(ctx context.Context, id string, user *User) (*User, error)
+}
+
+// GroupReplacer is the Group counterpart of UserReplacer
+type GroupReplacer interface {
+	ReplaceGroup(ctx context.Context, id string, group *Group) (*Group, error)
+}
+
+// Replacer is implemented by plugins that replace users and groups in place
+type Replacer interface {
+	UserReplacer
+	GroupReplacer
+}
+
+// ReplaceUser replaces the user id of plugin with user, through its
+// UserReplacer if it has one and by deleting and recreating the user
+// otherwise. Wrappers of PluginGetter use it to forward replacements.
+func ReplaceUser(ctx context.Context, plugin PluginGetter, id string, user *User) (*User, error) {
+	user.ID = id
+	if replacer, ok := lookupCapability[UserReplacer](plugin); ok {
+		return replacer.ReplaceUser(ctx, id, user)
+	}
+	if err := plugin.DeleteUser(ctx, id); err != nil {
+		return nil, err
+	}
+	return plugin.CreateUser(ctx, user)
+}
+
+// ReplaceGroup is the Group counterpart of ReplaceUser
+func ReplaceGroup(ctx context.Context, plugin PluginGetter, id string, group *Group) (*Group, error) {
+	group.ID = id
+	if replacer, ok := lookupCapability[GroupReplacer](plugin); ok {
+		return replacer.ReplaceGroup(ctx, id, group)
+	}
+	if err := plugin.DeleteGroup(ctx, id); err != nil {
+		return nil, err
+	}
+	return plugin.CreateGroup(ctx, group)
+}
@@ -0,0 +1,197 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replacingPlugin is a mockPlugin that replaces users and groups in place,
+// keeping meta.created like a database UPDATE would
+type replacingPlugin struct {
+	*mockPlugin
+	replaced int
+	deletes  int
+}
+
+func (p *replacingPlugin) ReplaceUser(ctx context.Context, id string, user *User) (*User, error) {
+	p.replaced++
+	existing, err := p.mockPlugin.GetUser(ctx, id, nil)
+	if err != nil {
+		return nil, ErrNotFound("User", id)
+	}
+	user.Meta = existing.Meta
+	p.mu.Lock()
+	p.users[id] = user
+	p.mu.Unlock()
+	return user, nil
+}
+
+func (p *replacingPlugin) ReplaceGroup(ctx context.Context, id string, group *Group) (*Group, error) {
+	p.replaced++
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.groups[id]; !ok {
+		return nil, ErrNotFound("Group", id)
+	}
+	p.groups[id] = group
+	return group, nil
+}
+
+func (p *replacingPlugin) DeleteUser(ctx context.Context, id string) error {
+	p.deletes++
+	return p.mockPlugin.DeleteUser(ctx, id)
+}
+
+func putResource(t *testing.T, plugin PluginGetter, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplaceUserInPlace(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := &replacingPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice", Meta: &Meta{Created: &created}}) // nolint:errcheck
+
+	// The replacer is found behind wrappers such as the plugin adapter
+	plugin := &unwrappingPlugin{PluginGetter: p.mockPlugin, inner: p}
+	w := putResource(t, plugin, "/test/Users/u1", `{"schemas":["`+SchemaUser+`"],"userName":"alice.smith"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if p.replaced != 1 || p.deletes != 0 {
+		t.Errorf("replaced = %d, deletes = %d, want an in-place replace", p.replaced, p.deletes)
+	}
+
+	var user User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if user.ID != "u1" || user.UserName != "alice.smith" {
+		t.Errorf("replaced user = %s/%s, want u1/alice.smith", user.ID, user.UserName)
+	}
+	if user.Meta == nil || user.Meta.Created == nil || !user.Meta.Created.Equal(created) {
+		t.Errorf("meta.created = %v, want %v", user.Meta, created)
+	}
+}
+
+func TestReplaceGroupInPlace(t *testing.T) {
+	p := &replacingPlugin{mockPlugin: newMockPlugin()}
+	p.CreateGroup(context.Background(), &Group{ID: "g1", DisplayName: "Admins"}) // nolint:errcheck
+
+	w := putResource(t, p, "/test/Groups/g1", `{"schemas":["`+SchemaGroup+`"],"displayName":"Operators"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+	if p.replaced != 1 {
+		t.Errorf("replaced = %d, want 1", p.replaced)
+	}
+	if got := p.groups["g1"].DisplayName; got != "Operators" {
+		t.Errorf("stored displayName = %q, want Operators", got)
+	}
+
+	w = putResource(t, p, "/test/Groups/missing", `{"schemas":["`+SchemaGroup+`"],"displayName":"Operators"}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d for a missing group, want 404", w.Code)
+	}
+}
+
+func TestReplaceUserFallback(t *testing.T) {
+	mock := newMockPlugin()
+	mock.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+
+	replaced, err := ReplaceUser(context.Background(), mock, "u1", &User{UserName: "alice.smith"})
+	if err != nil {
+		t.Fatalf("ReplaceUser() error = %v", err)
+	}
+	if replaced.ID != "u1" {
+		t.Errorf("ID = %q, want u1", replaced.ID)
+	}
+	if got := mock.users["u1"].UserName; got != "alice.smith" {
+		t.Errorf("stored userName = %q, want alice.smith", got)
+	}
+
+	if _, err := ReplaceUser(context.Background(), mock, "missing", &User{UserName: "bob"}); err == nil {
+		t.Error("ReplaceUser() of a missing user succeeded")
+	}
+	if _, ok := mock.users["missing"]; ok {
+		t.Error("fallback created a user whose delete failed")
+	}
+}
+
+func TestBulkReplaceInPlace(t *testing.T) {
+	p := &replacingPlugin{mockPlugin: newMockPlugin()}
+	p.mockPlugin.CreateUser(context.Background(), &User{ID: "u1", UserName: "alice"}) // nolint:errcheck
+	p.CreateGroup(context.Background(), &Group{ID: "g1", DisplayName: "Admins"})      // nolint:errcheck
+
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: p})
+	body := `{"schemas":["` + SchemaBulkRequest + `"],"Operations":[
+		{"method":"PUT","path":"/Users/u1","data":{"schemas":["` + SchemaUser + `"],"userName":"alice.smith"}},
+		{"method":"PUT","path":"/Groups/g1","data":{"schemas":["` + SchemaGroup + `"],"displayName":"Operators"}}
+	]}`
+	req := httptest.NewRequest(http.MethodPost, "/test/Bulk", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || strings.Count(w.Body.String(), `"status":"200"`) != 2 {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if p.replaced != 2 || p.deletes != 0 {
+		t.Errorf("replaced = %d, deletes = %d, want in-place replaces like PUT", p.replaced, p.deletes)
+	}
+	if p.users["u1"].UserName != "alice.smith" || p.groups["g1"].DisplayName != "Operators" {
+		t.Errorf("stored = %s, %s, want the replacements", p.users["u1"].UserName, p.groups["g1"].DisplayName)
+	}
+}
+
+// replacingDevicePlugin is a devicePlugin that replaces devices in place
+type replacingDevicePlugin struct {
+	*devicePlugin
+	replaced int
+}
+
+func (p *replacingDevicePlugin) Replace(ctx context.Context, id string, device *testDevice) (*testDevice, error) {
+	p.replaced++
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.devices[id]; !ok {
+		return nil, ErrNotFound("Device", id)
+	}
+	p.devices[id] = device
+	return device, nil
+}
+
+func TestReplaceResourceInPlace(t *testing.T) {
+	srv, devices := newDeviceServer(t)
+	replacer := &replacingDevicePlugin{devicePlugin: devices}
+	plugin, _ := srv.pluginManager.Get("test")
+	rtPlugin := plugin.(*unwrappingPlugin).inner.(*resourceTypePlugin)
+	rtPlugin.types = []ResourceType{NewResourceType(rtPlugin.types[0].Schema(), "/Devices", replacer)}
+	devices.devices["d1"] = &testDevice{Resource: Resource{ID: "d1"}, DisplayName: "Laptop"}
+
+	w, replaced := serveJSON(t, srv, http.MethodPut, "/test/Devices/d1", map[string]any{"displayName": "Desktop"})
+	if w.Code != http.StatusOK || replaced["id"] != "d1" || replaced["displayName"] != "Desktop" {
+		t.Fatalf("PUT status = %d, body = %v", w.Code, replaced)
+	}
+
+	w, _ = serveJSON(t, srv, http.MethodPost, "/test/Bulk", map[string]any{
+		"schemas":    []string{SchemaBulkRequest},
+		"Operations": []map[string]any{{"method": "PUT", "path": "/Devices/d1", "data": map[string]any{"displayName": "Tablet"}}},
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"200"`) {
+		t.Fatalf("bulk PUT status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if replacer.replaced != 2 || devices.devices["d1"].DisplayName != "Tablet" {
+		t.Errorf("replaced = %d, device = %+v, want PUT and bulk PUT replacing in place", replacer.replaced, devices.devices["d1"])
+	}
+}
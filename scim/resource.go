@@ -0,0 +1,197 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Base returns the resource's common attributes. Custom resource structs embed
+// Resource to satisfy CustomResource:
+//
+//	type Device struct {
+//	    scim.Resource
+//	    SerialNumber string `json:"serialNumber"`
+//	}
+func (r *Resource) Base() *Resource {
+	return r
+}
+
+// CustomResource is implemented by resources of custom resource types,
+// typically by embedding Resource
+type CustomResource interface {
+	Base() *Resource
+}
+
+// ResourcePlugin defines the backend operations for a custom resource type.
+// It mirrors the User/Group methods of plugin.Plugin: List returns raw resources and
+// the gateway applies filtering, sorting and pagination.
+//
+// T is usually a pointer to a struct embedding Resource (e.g. *Device).
+type ResourcePlugin[T CustomResource] interface {
+	List(ctx context.Context, params QueryParams) ([]T, error)
+	Create(ctx context.Context, resource T) (T, error)
+	Get(ctx context.Context, id string, attributes []string) (T, error)
+	Modify(ctx context.Context, id string, patch *PatchOp) error
+	Delete(ctx context.Context, id string) error
+}
+
+// ResourceReplacer is an optional interface for a ResourcePlugin that can
+// replace a resource in place, the custom resource counterpart of
+// UserReplacer. Without it, PUT requests delete and recreate the resource.
+type ResourceReplacer[T CustomResource] interface {
+	Replace(ctx context.Context, id string, resource T) (T, error)
+}
+
+// ResourceType is a custom resource type served by a plugin, created with NewResourceType.
+// The server routes /{plugin}/{endpoint}[/{id}] requests to it and lists it in the
+// ResourceTypes and Schemas discovery endpoints.
+type ResourceType interface {
+	// Definition returns the discovery representation of the resource type
+	Definition() ResourceTypeDefinition
+
+	// Schema returns the core schema of the resource type
+	Schema() *SchemaDefinition
+
+	decode(data []byte) (CustomResource, error)
+	list(ctx context.Context, params QueryParams) (*ListResponse[CustomResource], error)
+	create(ctx context.Context, resource CustomResource) (CustomResource, error)
+	get(ctx context.Context, id string, attributes []string) (CustomResource, error)
+	modify(ctx context.Context, id string, patch *PatchOp) error
+	replace(ctx context.Context, id string, resource CustomResource) (CustomResource, error)
+	delete(ctx context.Context, id string) error
+}
+
+// ResourceTypeProvider is an optional interface for plugins that serve resource types
+// beyond Users and Groups. The server discovers it through wrappers such as the plugin
+// adapter, so plugin.Plugin implementations can implement it directly.
+type ResourceTypeProvider interface {
+	ResourceTypes() []ResourceType
+}
+
+// NewResourceType creates a resource type from its schema and a typed plugin.
+// The schema ID is used as the core schema URN and the schema name as the resource
+// type name. Endpoint is the path relative to the plugin, e.g. "/Devices".
+func NewResourceType[T CustomResource](schema *SchemaDefinition, endpoint string, plugin ResourcePlugin[T]) ResourceType {
+	if !strings.HasPrefix(endpoint, "/") {
+		endpoint = "/" + endpoint
+	}
+	return &resourceType[T]{schema: schema, endpoint: endpoint, plugin: plugin}
+}
+
+// resourceType adapts a ResourcePlugin[T] to the type-erased ResourceType interface
+type resourceType[T CustomResource] struct {
+	schema   *SchemaDefinition
+	endpoint string
+	plugin   ResourcePlugin[T]
+}
+
+func (rt *resourceType[T]) Definition() ResourceTypeDefinition {
+	return ResourceTypeDefinition{
+		Schemas:     []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+		ID:          rt.schema.Name,
+		Name:        rt.schema.Name,
+		Endpoint:    rt.endpoint,
+		Description: rt.schema.Description,
+		Schema:      rt.schema.ID,
+	}
+}
+
+func (rt *resourceType[T]) Schema() *SchemaDefinition {
+	return rt.schema
+}
+
+// decode decodes a resource of the type from a JSON object. A null body
+// leaves the pointer T nil, which is rejected rather than returned as a
+// non-nil CustomResource.
+func (rt *resourceType[T]) decode(data []byte) (CustomResource, error) {
+	var resource T
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+	if v := reflect.ValueOf(resource); !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return nil, errors.New("resource must be a JSON object")
+	}
+	return resource, nil
+}
+
+func (rt *resourceType[T]) list(ctx context.Context, params QueryParams) (*ListResponse[CustomResource], error) {
+	resources, err := rt.plugin.List(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := ProcessListQuery(resources, params)
+	if err != nil {
+		return nil, err
+	}
+
+	erased := make([]CustomResource, len(response.Resources))
+	for i, resource := range response.Resources {
+		erased[i] = resource
+	}
+
+	return &ListResponse[CustomResource]{
+		Schemas:      response.Schemas,
+		TotalResults: response.TotalResults,
+		StartIndex:   response.StartIndex,
+		ItemsPerPage: response.ItemsPerPage,
+		Resources:    erased,
+	}, nil
+}
+
+func (rt *resourceType[T]) create(ctx context.Context, resource CustomResource) (CustomResource, error) {
+	typed, ok := resource.(T)
+	if !ok {
+		return nil, ErrInvalidValue("resource does not match resource type " + rt.schema.Name)
+	}
+	return rt.plugin.Create(ctx, typed)
+}
+
+func (rt *resourceType[T]) get(ctx context.Context, id string, attributes []string) (CustomResource, error) {
+	return rt.plugin.Get(ctx, id, attributes)
+}
+
+func (rt *resourceType[T]) modify(ctx context.Context, id string, patch *PatchOp) error {
+	return rt.plugin.Modify(ctx, id, patch)
+}
+
+// replace replaces the resource id in place if the plugin is a
+// ResourceReplacer, and deletes and recreates it otherwise
+func (rt *resourceType[T]) replace(ctx context.Context, id string, resource CustomResource) (CustomResource, error) {
+	typed, ok := resource.(T)
+	if !ok {
+		return nil, ErrInvalidValue("resource does not match resource type " + rt.schema.Name)
+	}
+	typed.Base().ID = id
+	if replacer, ok := rt.plugin.(ResourceReplacer[T]); ok {
+		return replacer.Replace(ctx, id, typed)
+	}
+	if err := rt.plugin.Delete(ctx, id); err != nil {
+		return nil, err
+	}
+	return rt.plugin.Create(ctx, typed)
+}
+
+func (rt *resourceType[T]) delete(ctx context.Context, id string) error {
+	return rt.plugin.Delete(ctx, id)
+}
+
+// lookupCapability finds an optional interface on a PluginGetter, following Unwrap
+// through wrappers such as the plugin adapter (similar to errors.As)
+func lookupCapability[T any](p any) (T, bool) {
+	for p != nil {
+		if capability, ok := p.(T); ok {
+			return capability, true
+		}
+		unwrapper, ok := p.(interface{ Unwrap() any })
+		if !ok {
+			break
+		}
+		p = unwrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,380 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// reservedEndpoints maps fixed plugin endpoints to the method they accept. Requests
+// with other methods fall through to the custom resource routes and must still get a 405.
+var reservedEndpoints = map[string]string{
+	".search":               http.MethodPost,
+	"Bulk":                  http.MethodPost,
+	"ServiceProviderConfig": http.MethodGet,
+	"ResourceTypes":         http.MethodGet,
+	"Schemas":               http.MethodGet,
+}
+
+// resourceTypes returns the custom resource types served by a plugin, if any
+func (s *Server) resourceTypes(plugin PluginGetter) []ResourceType {
+	provider, ok := lookupCapability[ResourceTypeProvider](plugin)
+	if !ok {
+		return nil
+	}
+	return provider.ResourceTypes()
+}
+
+// findResourceType looks up a plugin's custom resource type by endpoint name (e.g. "Devices")
+func (s *Server) findResourceType(plugin PluginGetter, endpoint string) (ResourceType, bool) {
+	for _, rt := range s.resourceTypes(plugin) {
+		if strings.TrimPrefix(rt.Definition().Endpoint, "/") == endpoint {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+// resolveResourceType resolves the plugin and custom resource type of a request,
+// writing a 404 response if either does not exist and a 403 response if the
+// plugin does not allow operation on the resource type
+func (s *Server) resolveResourceType(w http.ResponseWriter, r *http.Request, endpoint, operation string) (ResourceType, string, bool) {
+	pluginName := r.PathValue("plugin")
+	resourceName := r.PathValue("resourceType")
+
+	if allowed, ok := reservedEndpoints[resourceName]; ok {
+		w.Header().Set("Allow", allowed)
+		s.handler.WriteSCIMError(w, ErrMethodNotAllowed(r.Method))
+		return nil, "", false
+	}
+
+	plugin, ok := s.getPlugin(pluginName, endpoint, r)
+	if !ok {
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return nil, "", false
+	}
+
+	rt, ok := s.findResourceType(plugin, resourceName)
+	if !ok {
+		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Resource type '%s' not found", resourceName), "invalidPath")
+		return nil, "", false
+	}
+	if !s.allowOperation(w, plugin, pluginName, resourceName, operation) {
+		return nil, "", false
+	}
+
+	return rt, pluginName, true
+}
+
+// normalizeResource brings a plugin-provided custom resource into its canonical response shape
+func (s *Server) normalizeResource(rt ResourceType, resource CustomResource) {
+	if resource == nil {
+		return
+	}
+	common := resource.Base()
+	common.Schemas = buildSchemas(rt.Schema().ID, common.Schemas, nil)
+	common.Meta = EnsureResourceType(common.Meta, rt.Definition().Name)
+}
+
+// handleGetResources handles GET /{plugin}/{resourceType}
+func (s *Server) handleGetResources(w http.ResponseWriter, r *http.Request) {
+	rt, pluginName, ok := s.resolveResourceType(w, r, "GET /{resourceType}", OperationRead)
+	if !ok {
+		return
+	}
+	plugin, _ := s.pluginManager.Get(pluginName)
+
+	params, err := s.parseQueryParams(r, plugin)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
+		return
+	}
+
+	response, err := rt.list(r.Context(), params)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
+
+	for _, resource := range response.Resources {
+		s.normalizeResource(rt, resource)
+	}
+	listURL := s.resourceBaseURL(r.Context(), plugin, pluginName) + rt.Definition().Endpoint
+	s.setPaginationLinks(w, r, listURL, params, hasNextPage(params, response.TotalResults))
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+
+	// Apply attribute selection if specified
+	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
+		selector := NewAttributeSelector(params.Attributes, params.ExcludedAttr)
+		resources := make([]any, len(response.Resources))
+		for i, resource := range response.Resources {
+			resources[i] = resource
+		}
+
+		filteredResources, err := selector.FilterResources(resources)
+		if err != nil {
+			s.handler.WriteError(w, http.StatusInternalServerError, err.Error(), "internalError")
+			return
+		}
+
+		s.handler.WriteJSON(w, http.StatusOK, &ListResponse[any]{
+			Schemas:      response.Schemas,
+			TotalResults: response.TotalResults,
+			StartIndex:   response.StartIndex,
+			ItemsPerPage: response.ItemsPerPage,
+			Resources:    filteredResources,
+		})
+		return
+	}
+
+	s.handler.WriteJSON(w, http.StatusOK, response)
+}
+
+// handleCreateResource handles POST /{plugin}/{resourceType}
+func (s *Server) handleCreateResource(w http.ResponseWriter, r *http.Request) {
+	rt, pluginName, ok := s.resolveResourceType(w, r, "POST /{resourceType}", OperationCreate)
+	if !ok {
+		return
+	}
+
+	resource, ok := s.decodeResource(w, r, rt)
+	if !ok {
+		return
+	}
+
+	created, err := rt.create(r.Context(), resource)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
+
+	s.normalizeResource(rt, created)
+	common := created.Base()
+
+	// Set location header
+	plugin, _ := s.pluginManager.Get(pluginName)
+	location := s.resourceLocation(r.Context(), plugin, pluginName, r.PathValue("resourceType"), common.ID)
+	w.Header().Set("Location", location)
+	common.Meta.Location = location
+
+	s.writeVersioned(w, http.StatusCreated, created)
+}
+
+// handleGetResource handles GET /{plugin}/{resourceType}/{id}
+func (s *Server) handleGetResource(w http.ResponseWriter, r *http.Request) {
+	rt, pluginName, ok := s.resolveResourceType(w, r, "GET /{resourceType}/{id}", OperationRead)
+	if !ok {
+		return
+	}
+	plugin, _ := s.pluginManager.Get(pluginName)
+
+	params, err := s.parseQueryParams(r, plugin)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
+		return
+	}
+
+	resource, err := rt.get(r.Context(), r.PathValue("id"), params.Attributes)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return
+	}
+
+	s.normalizeResource(rt, resource)
+
+	etag, err := s.etagGen.Generate(resource)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+		return
+	}
+
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+
+	// Check If-None-Match for conditional GET (304 Not Modified)
+	status, err := s.etagGen.CheckPreconditions(r, etag)
+	if err != nil && status == http.StatusNotModified {
+		s.etagGen.SetETag(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	UpdateResourceVersion(resource.Base().Meta, etag)
+	s.etagGen.SetETag(w, etag)
+
+	// Apply attribute selection
+	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
+		selector := NewAttributeSelector(params.Attributes, params.ExcludedAttr)
+		filtered, err := selector.FilterResource(resource)
+		if err != nil {
+			s.handler.WriteError(w, http.StatusInternalServerError, err.Error(), "internalError")
+			return
+		}
+		s.handler.WriteJSON(w, http.StatusOK, filtered)
+		return
+	}
+
+	s.handler.WriteJSON(w, http.StatusOK, resource)
+}
+
+// handleReplaceResource handles PUT /{plugin}/{resourceType}/{id}
+func (s *Server) handleReplaceResource(w http.ResponseWriter, r *http.Request) {
+	rt, _, ok := s.resolveResourceType(w, r, "PUT /{resourceType}/{id}", OperationReplace)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	r, ok = s.checkResourcePreconditions(w, r, rt, id)
+	if !ok {
+		return
+	}
+
+	resource, ok := s.decodeResource(w, r, rt)
+	if !ok {
+		return
+	}
+
+	// Replace in place if the plugin can, else delete and recreate
+	replaced, err := rt.replace(r.Context(), id, resource)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
+
+	s.normalizeResource(rt, replaced)
+	s.writeVersioned(w, http.StatusOK, replaced)
+}
+
+// handlePatchResource handles PATCH /{plugin}/{resourceType}/{id}
+func (s *Server) handlePatchResource(w http.ResponseWriter, r *http.Request) {
+	rt, _, ok := s.resolveResourceType(w, r, "PATCH /{resourceType}/{id}", OperationPatch)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	r, ok = s.checkResourcePreconditions(w, r, rt, id)
+	if !ok {
+		return
+	}
+
+	var patch PatchOp
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
+		return
+	}
+
+	validator := NewValidator()
+	if err := validator.ValidatePatchOp(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
+		return
+	}
+
+	if err := rt.modify(r.Context(), id, &patch); err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return
+	}
+
+	// Return updated resource
+	resource, err := rt.get(r.Context(), id, nil)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return
+	}
+
+	s.normalizeResource(rt, resource)
+	s.writeVersioned(w, http.StatusOK, resource)
+}
+
+// handleDeleteResource handles DELETE /{plugin}/{resourceType}/{id}
+func (s *Server) handleDeleteResource(w http.ResponseWriter, r *http.Request) {
+	rt, _, ok := s.resolveResourceType(w, r, "DELETE /{resourceType}/{id}", OperationDelete)
+	if !ok {
+		return
+	}
+	id := r.PathValue("id")
+
+	r, ok = s.checkResourcePreconditions(w, r, rt, id)
+	if !ok {
+		return
+	}
+
+	if err := rt.delete(r.Context(), id); err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeResource reads and validates a custom resource from the request body
+func (s *Server) decodeResource(w http.ResponseWriter, r *http.Request, rt ResourceType) (CustomResource, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Failed to read request body", "invalidSyntax")
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	resource, err := rt.decode(body)
+	if err != nil || resource == nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
+		return nil, false
+	}
+
+	validator := NewValidator()
+	if err := validator.ValidateResource(rt, resource); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
+		return nil, false
+	}
+
+	return resource, true
+}
+
+// checkResourcePreconditions fetches the current resource and checks If-Match,
+// writing the error response and returning false if the request must not
+// proceed. The returned request carries the precondition for the plugin.
+// Requests without preconditions proceed without fetching the resource.
+func (s *Server) checkResourcePreconditions(w http.ResponseWriter, r *http.Request, rt ResourceType, id string) (*http.Request, bool) {
+	if !hasPreconditions(r) {
+		return r, true
+	}
+
+	current, err := rt.get(r.Context(), id, nil)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return r, false
+	}
+
+	s.normalizeResource(rt, current)
+
+	currentETag, err := s.etagGen.Generate(current)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+		return r, false
+	}
+
+	status, err := s.etagGen.CheckPreconditions(r, currentETag)
+	if err != nil && status == http.StatusPreconditionFailed {
+		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
+		return r, false
+	}
+
+	return withPrecondition(r, currentETag, current.Base().Meta), true
+}
+
+// writeVersioned sets the ETag and meta.version of a resource and writes it
+func (s *Server) writeVersioned(w http.ResponseWriter, status int, resource CustomResource) {
+	etag, err := s.etagGen.Generate(resource)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+		return
+	}
+
+	UpdateResourceVersion(resource.Base().Meta, etag)
+	s.etagGen.SetETag(w, etag)
+
+	s.handler.WriteJSON(w, status, resource)
+}
@@ -0,0 +1,398 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testDeviceSchema = "urn:example:params:scim:schemas:core:2.0:Device"
+
+// testDevice is a custom resource used to exercise pluggable resource types
+type testDevice struct {
+	Resource
+	DisplayName  string `json:"displayName"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+}
+
+// devicePlugin is an in-memory ResourcePlugin[*testDevice]
+type devicePlugin struct {
+	devices map[string]*testDevice
+	nextID  int
+	mu      sync.Mutex
+}
+
+func (p *devicePlugin) List(ctx context.Context, params QueryParams) ([]*testDevice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	devices := make([]*testDevice, 0, len(p.devices))
+	for _, d := range p.devices {
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+func (p *devicePlugin) Create(ctx context.Context, device *testDevice) (*testDevice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if device.ID == "" {
+		p.nextID++
+		device.ID = fmt.Sprintf("d%d", p.nextID)
+	}
+	p.devices[device.ID] = device
+	return device, nil
+}
+
+func (p *devicePlugin) Get(ctx context.Context, id string, attributes []string) (*testDevice, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	device, ok := p.devices[id]
+	if !ok {
+		return nil, ErrNotFound("Device", id)
+	}
+	return device, nil
+}
+
+func (p *devicePlugin) Modify(ctx context.Context, id string, patch *PatchOp) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	device, ok := p.devices[id]
+	if !ok {
+		return ErrNotFound("Device", id)
+	}
+	return NewPatchProcessor().ApplyPatch(device, patch)
+}
+
+func (p *devicePlugin) Delete(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.devices[id]; !ok {
+		return ErrNotFound("Device", id)
+	}
+	delete(p.devices, id)
+	return nil
+}
+
+// resourceTypePlugin is a mockPlugin that also serves custom resource types
+type resourceTypePlugin struct {
+	*mockPlugin
+	types    []ResourceType
+	excluded []string
+}
+
+func (p *resourceTypePlugin) ResourceTypes() []ResourceType {
+	return p.types
+}
+
+func (p *resourceTypePlugin) DefaultExcludedAttributes() []string {
+	return p.excluded
+}
+
+// unwrappingPlugin hides its capabilities behind Unwrap, like the plugin adapter
+type unwrappingPlugin struct {
+	PluginGetter
+	inner any
+}
+
+func (p *unwrappingPlugin) Unwrap() any {
+	return p.inner
+}
+
+func newDeviceServer(t *testing.T) (*Server, *devicePlugin) {
+	t.Helper()
+
+	devices := &devicePlugin{devices: make(map[string]*testDevice)}
+	schema := &SchemaDefinition{
+		ID:          testDeviceSchema,
+		Name:        "Device",
+		Description: "Managed device",
+		Attributes: []AttributeDefinition{
+			{Name: "displayName", Type: "string", Required: true},
+			{Name: "serialNumber", Type: "string"},
+		},
+	}
+
+	base := &resourceTypePlugin{
+		mockPlugin: newMockPlugin(),
+		types:      []ResourceType{NewResourceType(schema, "/Devices", devices)},
+	}
+	pm := &mockPluginManager{plugin: &unwrappingPlugin{PluginGetter: base, inner: base}}
+	return NewServer("http://localhost:8080", pm), devices
+}
+
+func serveJSON(t *testing.T, srv *Server, method, path string, body any) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var decoded map[string]any
+	if w.Body.Len() > 0 {
+		json.Unmarshal(w.Body.Bytes(), &decoded)
+	}
+	return w, decoded
+}
+
+func TestCustomResourceTypeCRUD(t *testing.T) {
+	srv, devices := newDeviceServer(t)
+
+	// Create
+	w, created := serveJSON(t, srv, http.MethodPost, "/test/Devices", map[string]any{
+		"displayName":  "Laptop",
+		"serialNumber": "SN-1",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body.String())
+	}
+	id, _ := created["id"].(string)
+	if w.Header().Get("Location") != "http://localhost:8080/test/Devices/"+id {
+		t.Errorf("Location = %q", w.Header().Get("Location"))
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("expected ETag header on create")
+	}
+	meta, _ := created["meta"].(map[string]any)
+	if meta["resourceType"] != "Device" {
+		t.Errorf("meta.resourceType = %v, want Device", meta["resourceType"])
+	}
+	schemas, _ := created["schemas"].([]any)
+	if len(schemas) != 1 || schemas[0] != testDeviceSchema {
+		t.Errorf("schemas = %v, want [%s]", schemas, testDeviceSchema)
+	}
+
+	// Get
+	w, got := serveJSON(t, srv, http.MethodGet, "/test/Devices/"+id, nil)
+	if w.Code != http.StatusOK || got["serialNumber"] != "SN-1" {
+		t.Fatalf("GET status = %d, body = %v", w.Code, got)
+	}
+
+	// Patch
+	w, patched := serveJSON(t, srv, http.MethodPatch, "/test/Devices/"+id, map[string]any{
+		"schemas":    []string{SchemaPatchOp},
+		"Operations": []map[string]any{{"op": "replace", "path": "serialNumber", "value": "SN-2"}},
+	})
+	if w.Code != http.StatusOK || patched["serialNumber"] != "SN-2" {
+		t.Fatalf("PATCH status = %d, body = %v", w.Code, patched)
+	}
+
+	// Replace
+	w, replaced := serveJSON(t, srv, http.MethodPut, "/test/Devices/"+id, map[string]any{
+		"displayName": "Desktop",
+	})
+	if w.Code != http.StatusOK || replaced["displayName"] != "Desktop" || replaced["id"] != id {
+		t.Fatalf("PUT status = %d, body = %v", w.Code, replaced)
+	}
+
+	// Delete
+	w, _ = serveJSON(t, srv, http.MethodDelete, "/test/Devices/"+id, nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if len(devices.devices) != 0 {
+		t.Errorf("expected device to be deleted, %d remain", len(devices.devices))
+	}
+
+	w, _ = serveJSON(t, srv, http.MethodGet, "/test/Devices/"+id, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET after delete status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestCustomResourceTypeList(t *testing.T) {
+	srv, _ := newDeviceServer(t)
+
+	for _, name := range []string{"Laptop", "Phone", "Tablet"} {
+		if w, _ := serveJSON(t, srv, http.MethodPost, "/test/Devices", map[string]any{"displayName": name}); w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d", name, w.Code)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		total int
+	}{
+		{"all", "", 3},
+		{"filter", `?filter=displayName%20eq%20%22Phone%22`, 1},
+		{"filter on embedded attribute", `?filter=id%20pr`, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, body := serveJSON(t, srv, http.MethodGet, "/test/Devices"+tt.query, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET status = %d: %s", w.Code, w.Body.String())
+			}
+			if total, _ := body["totalResults"].(float64); int(total) != tt.total {
+				t.Errorf("totalResults = %v, want %d", body["totalResults"], tt.total)
+			}
+		})
+	}
+
+	w, body := serveJSON(t, srv, http.MethodGet, "/test/Devices?attributes=displayName", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET with attributes status = %d", w.Code)
+	}
+	resources, _ := body["Resources"].([]any)
+	for _, r := range resources {
+		if _, ok := r.(map[string]any)["serialNumber"]; ok {
+			t.Error("attribute selection should drop serialNumber")
+		}
+	}
+}
+
+func TestCustomResourceTypeValidation(t *testing.T) {
+	srv, _ := newDeviceServer(t)
+
+	tests := []struct {
+		name string
+		body map[string]any
+	}{
+		{"missing required attribute", map[string]any{"serialNumber": "SN-1"}},
+		{"mismatched resourceType", map[string]any{"displayName": "Laptop", "meta": map[string]any{"resourceType": "User"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := serveJSON(t, srv, http.MethodPost, "/test/Devices", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("POST status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+
+	// A null body decodes to a nil resource
+	req := httptest.NewRequest(http.MethodPost, "/test/Devices", strings.NewReader("null"))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ScimTypeInvalidSyntax) {
+		t.Errorf("POST null status = %d, body: %s, want 400 invalidSyntax", w.Code, w.Body.String())
+	}
+
+	bulk := `{"schemas":["` + SchemaBulkRequest + `"],"Operations":[{"method":"POST","path":"/Devices","bulkId":"d1","data":null}]}`
+	req = httptest.NewRequest(http.MethodPost, "/test/Bulk", strings.NewReader(bulk))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"400"`) {
+		t.Errorf("bulk POST null status = %d, body: %s, want a 400 operation", w.Code, w.Body.String())
+	}
+}
+
+func TestCustomResourceTypeRouting(t *testing.T) {
+	srv, _ := newDeviceServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"unknown resource type", http.MethodGet, "/test/Printers", http.StatusNotFound},
+		{"unknown resource type by id", http.MethodGet, "/test/Printers/1", http.StatusNotFound},
+		{"users still routed to core handlers", http.MethodGet, "/test/Users", http.StatusOK},
+		{"search only accepts POST", http.MethodGet, "/test/.search", http.StatusMethodNotAllowed},
+		{"bulk only accepts POST", http.MethodGet, "/test/Bulk", http.StatusMethodNotAllowed},
+		{"discovery only accepts GET", http.MethodPost, "/test/Schemas", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, body := serveJSON(t, srv, tt.method, tt.path, nil)
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+			if w.Code != http.StatusOK && body["status"] != strconv.Itoa(tt.want) {
+				t.Errorf("%s %s body = %s, want a SCIM error", tt.method, tt.path, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCustomResourceTypeDefaultExcludedAttributes(t *testing.T) {
+	srv, _ := newDeviceServer(t)
+	plugin, _ := srv.pluginManager.Get("test")
+	plugin.(*unwrappingPlugin).inner.(*resourceTypePlugin).excluded = []string{"serialNumber"}
+
+	w, created := serveJSON(t, srv, http.MethodPost, "/test/Devices", map[string]any{"displayName": "Laptop", "serialNumber": "SN-1"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d: %s", w.Code, w.Body.String())
+	}
+
+	_, got := serveJSON(t, srv, http.MethodGet, "/test/Devices/"+created["id"].(string), nil)
+	if _, ok := got["serialNumber"]; ok || got["displayName"] != "Laptop" {
+		t.Errorf("GET = %v, want serialNumber excluded by default", got)
+	}
+	_, list := serveJSON(t, srv, http.MethodGet, "/test/Devices", nil)
+	resources, _ := list["Resources"].([]any)
+	if len(resources) != 1 {
+		t.Fatalf("list = %v, want one device", list)
+	}
+	if _, ok := resources[0].(map[string]any)["serialNumber"]; ok {
+		t.Errorf("listed device = %v, want serialNumber excluded by default", resources[0])
+	}
+	_, got = serveJSON(t, srv, http.MethodGet, "/test/Devices/"+created["id"].(string)+"?attributes=serialNumber", nil)
+	if got["serialNumber"] != "SN-1" {
+		t.Errorf("GET with attributes = %v, want serialNumber", got)
+	}
+}
+
+func TestCustomResourceTypeDiscovery(t *testing.T) {
+	srv, _ := newDeviceServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/test/ResourceTypes", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var resourceTypes struct {
+		Resources []ResourceTypeDefinition `json:"Resources"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resourceTypes); err != nil {
+		t.Fatalf("failed to decode ResourceTypes: %v", err)
+	}
+
+	var found bool
+	for _, rt := range resourceTypes.Resources {
+		if rt.Name == "Device" && rt.Endpoint == "/Devices" && rt.Schema == testDeviceSchema {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("ResourceTypes should include Device, got %+v", resourceTypes.Resources)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/test/Schemas", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	var schemas []SchemaDefinition
+	if err := json.NewDecoder(w.Body).Decode(&schemas); err != nil {
+		t.Fatalf("failed to decode Schemas: %v", err)
+	}
+	if len(schemas) != 3 || schemas[2].ID != testDeviceSchema {
+		t.Errorf("Schemas should include Device schema, got %d schemas", len(schemas))
+	}
+}
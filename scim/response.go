@@ -0,0 +1,91 @@
+package scim
+
+import (
+	"net/http"
+
+	"github.com/marcelom97/scimgateway/metrics"
+)
+
+// Reasons a response could not be written, used as the reason label of the
+// write error metric
+const (
+	writeFailureEncode = "encode" // the response value could not be encoded
+	writeFailureWrite  = "write"  // the body could not be sent, e.g. the client disconnected
+)
+
+// requestID returns the correlation ID the client sent with the request, or
+// "" if it sent none
+func requestID(r *http.Request) string {
+	return r.Header.Get("X-Request-Id")
+}
+
+// responseWriter wraps the http.ResponseWriter of a request served by the
+// Server, so that responses which could not be written completely are logged
+// and counted instead of being silently truncated
+type responseWriter struct {
+	http.ResponseWriter
+	server  *Server
+	request *http.Request
+	failed  bool // a failure was already reported for this response
+}
+
+// Write writes to the underlying response and reports the first failure
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	if err != nil {
+		rw.fail(writeFailureWrite, err)
+	}
+	return n, err
+}
+
+// WriteHeader writes the status of the response. Responses other than
+// successful ones and 304 Not Modified are never cached, even if the handler
+// allowed caching before it failed.
+func (rw *responseWriter) WriteHeader(status int) {
+	if status >= http.StatusMultipleChoices && status != http.StatusNotModified {
+		rw.Header().Set("Cache-Control", cacheControlNoStore)
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the underlying response writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// fail logs and counts a failure to write the response, once per response
+func (rw *responseWriter) fail(reason string, err error) {
+	if rw.failed {
+		return
+	}
+	rw.failed = true
+
+	r := rw.request
+	attrs := []any{
+		"request_id", requestID(r),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"reason", reason,
+		"error", err,
+	}
+	if reason == writeFailureEncode {
+		rw.server.logger.ErrorContext(r.Context(), "failed to encode response", attrs...)
+	} else {
+		rw.server.logger.WarnContext(r.Context(), "failed to write response", attrs...)
+	}
+
+	if registry := rw.server.metrics; registry != nil {
+		registry.Counter("scimgateway_response_write_errors_total",
+			"Total number of responses that could not be encoded or sent completely.",
+			metrics.Labels{"reason": reason},
+		).Inc()
+	}
+}
+
+// reportWriteFailure reports a failure to write the response of w, if w is
+// a response writer of the Server
+func reportWriteFailure(w http.ResponseWriter, reason string, err error) {
+	if rw, ok := w.(*responseWriter); ok {
+		rw.fail(reason, err)
+	}
+}
@@ -0,0 +1,89 @@
+package scim
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/metrics"
+)
+
+// disconnectedWriter fails every write, like a client that went away
+type disconnectedWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *disconnectedWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func newResponseTestServer(t *testing.T) (*Server, *metrics.Registry, *bytes.Buffer) {
+	t.Helper()
+	var logs bytes.Buffer
+	srv := NewServerWithLogger("http://localhost:8080",
+		&mockPluginManager{plugin: newStreamingPlugin(1)},
+		slog.New(slog.NewTextHandler(&logs, nil)))
+	registry := metrics.NewRegistry()
+	srv.SetMetrics(registry)
+	return srv, registry, &logs
+}
+
+func TestWriteJSONEncodeFailure(t *testing.T) {
+	srv, registry, logs := newResponseTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/test/Users", nil)
+	req.Header.Set("X-Request-Id", "req-123")
+	rec := httptest.NewRecorder()
+	w := &responseWriter{ResponseWriter: rec, server: srv, request: req}
+	srv.handler.WriteJSON(w, http.StatusOK, map[string]any{"value": make(chan int)})
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "failed to encode response") {
+		t.Errorf("body = %s, want a SCIM error", rec.Body.String())
+	}
+	if v, _ := registry.Value("scimgateway_response_write_errors_total", metrics.Labels{"reason": "encode"}); v != 1 {
+		t.Errorf("encode write errors = %v, want 1", v)
+	}
+	if !strings.Contains(logs.String(), "request_id=req-123") {
+		t.Errorf("log = %q, want the request ID", logs.String())
+	}
+}
+
+func TestServeHTTPWriteFailure(t *testing.T) {
+	srv, registry, logs := newResponseTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/test/Users", nil)
+	req.Header.Set("X-Request-Id", "req-456")
+	w := &disconnectedWriter{ResponseRecorder: httptest.NewRecorder()}
+	srv.ServeHTTP(w, req)
+
+	if w.writes == 0 {
+		t.Fatal("response was not written")
+	}
+	// A response is reported once, however many of its writes failed
+	if v, _ := registry.Value("scimgateway_response_write_errors_total", metrics.Labels{"reason": "write"}); v != 1 {
+		t.Errorf("write errors = %v, want 1", v)
+	}
+	if !strings.Contains(logs.String(), "failed to write response") || !strings.Contains(logs.String(), "request_id=req-456") {
+		t.Errorf("log = %q, want the failure with the request ID", logs.String())
+	}
+}
+
+func TestServeHTTPWithoutMetrics(t *testing.T) {
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: newStreamingPlugin(1)})
+
+	// Failures are only logged when no registry is set
+	w := &disconnectedWriter{ResponseRecorder: httptest.NewRecorder()}
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test/Users", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
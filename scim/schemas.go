@@ -0,0 +1,92 @@
+package scim
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// EnsureUserSchemas makes sure a user's schemas array lists the core User schema
+// and every extension schema for which the user actually carries data.
+//
+// Extension URNs for known extensions that have no data are removed, while any
+// unknown URNs supplied by the plugin are preserved as-is. Some IdP parsers
+// reject resources whose extension attributes are not announced in schemas.
+func EnsureUserSchemas(user *User) {
+	if user == nil {
+		return
+	}
+
+	extensions := extensionKeys(user.Extensions, map[string]bool{
+		SchemaEnterpriseUser: len(user.EnterpriseUser) > 0,
+	})
+
+	user.Schemas = buildSchemas(SchemaUser, user.Schemas, extensions)
+}
+
+// EnsureGroupSchemas makes sure a group's schemas array lists the core Group schema
+// and the extension schemas for which the group carries data
+func EnsureGroupSchemas(group *Group) {
+	if group == nil {
+		return
+	}
+
+	group.Schemas = buildSchemas(SchemaGroup, group.Schemas, extensionKeys(group.Extensions, nil))
+}
+
+// buildSchemas returns a schemas array starting with the core schema, followed by
+// the present extensions and any other URNs from the existing array
+func buildSchemas(core string, existing []string, extensions map[string]bool) []string {
+	schemas := make([]string, 0, len(existing)+len(extensions)+1)
+	schemas = append(schemas, core)
+
+	// Keep the order of existing URNs, dropping duplicates and absent extensions
+	for _, urn := range existing {
+		if urn == "" || slices.Contains(schemas, urn) {
+			continue
+		}
+		if present, known := extensions[urn]; known && !present {
+			continue
+		}
+		schemas = append(schemas, urn)
+	}
+
+	// Add present extensions that were not announced
+	for _, urn := range slices.Sorted(maps.Keys(extensions)) {
+		if extensions[urn] && !slices.Contains(schemas, urn) {
+			schemas = append(schemas, urn)
+		}
+	}
+
+	return schemas
+}
+
+// SplitSchemaURN splits a fully qualified attribute path into its schema URN and
+// the attribute path relative to that schema. The URN ends at the resource type
+// segment (":User" or ":Group"):
+//
+//	urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value
+//	-> "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User", "manager.value"
+//
+// Paths without a URN prefix are returned as attrPath with an empty URN. A bare
+// URN without an attribute is returned with an empty attrPath.
+func SplitSchemaURN(path string) (urn, attrPath string) {
+	if !strings.HasPrefix(path, "urn:") {
+		return "", path
+	}
+
+	if idx := strings.Index(path, ":User:"); idx != -1 {
+		return path[:idx+5], path[idx+6:] // Include ":User", skip ":User:"
+	}
+	if idx := strings.Index(path, ":Group:"); idx != -1 {
+		return path[:idx+6], path[idx+7:] // Include ":Group", skip ":Group:"
+	}
+
+	return path, ""
+}
+
+// isCoreSchema reports whether urn is the core User or Group schema, whose
+// attributes live at the top level of the resource
+func isCoreSchema(urn string) bool {
+	return urn == SchemaUser || urn == SchemaGroup
+}
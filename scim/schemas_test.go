@@ -0,0 +1,129 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEnsureUserSchemas(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     *User
+		expected []string
+	}{
+		{
+			name:     "empty schemas get core schema",
+			user:     &User{},
+			expected: []string{SchemaUser},
+		},
+		{
+			name:     "enterprise data adds extension URN",
+			user:     &User{EnterpriseUser: map[string]any{"department": "Sales"}},
+			expected: []string{SchemaUser, SchemaEnterpriseUser},
+		},
+		{
+			name:     "announced but empty enterprise extension is removed",
+			user:     &User{Schemas: []string{SchemaUser, SchemaEnterpriseUser}},
+			expected: []string{SchemaUser},
+		},
+		{
+			name:     "core schema is moved first and duplicates dropped",
+			user:     &User{Schemas: []string{SchemaEnterpriseUser, SchemaUser, SchemaUser}, EnterpriseUser: map[string]any{"employeeNumber": "1"}},
+			expected: []string{SchemaUser, SchemaEnterpriseUser},
+		},
+		{
+			name:     "unknown URNs are preserved",
+			user:     &User{Schemas: []string{"urn:example:custom"}},
+			expected: []string{SchemaUser, "urn:example:custom"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			EnsureUserSchemas(tt.user)
+			if !reflect.DeepEqual(tt.user.Schemas, tt.expected) {
+				t.Errorf("schemas = %v, want %v", tt.user.Schemas, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnsureGroupSchemas(t *testing.T) {
+	group := &Group{DisplayName: "Admins"}
+	EnsureGroupSchemas(group)
+
+	if !reflect.DeepEqual(group.Schemas, []string{SchemaGroup}) {
+		t.Errorf("schemas = %v, want [%s]", group.Schemas, SchemaGroup)
+	}
+
+	// Nil resources must be ignored
+	EnsureUserSchemas(nil)
+	EnsureGroupSchemas(nil)
+}
+
+func TestServerPopulatesExtensionSchemas(t *testing.T) {
+	plugin := newMockPlugin()
+	pm := &mockPluginManager{plugin: plugin}
+	srv := NewServer("http://localhost:8080", pm)
+
+	// Plugin stores the user without announcing the enterprise extension
+	plugin.users["u1"] = &User{
+		ID:             "u1",
+		Schemas:        []string{SchemaUser},
+		UserName:       "jdoe",
+		EnterpriseUser: map[string]any{"department": "Engineering"},
+	}
+
+	for _, path := range []string{"/test/Users/u1", "/test/Users"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want %d", path, w.Code, http.StatusOK)
+		}
+
+		var body map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		resource := body
+		if resources, ok := body["Resources"].([]any); ok {
+			resource = resources[0].(map[string]any)
+		}
+
+		schemas, _ := resource["schemas"].([]any)
+		if len(schemas) != 2 || schemas[1] != SchemaEnterpriseUser {
+			t.Errorf("GET %s schemas = %v, want enterprise extension URN included", path, schemas)
+		}
+	}
+}
+
+func TestSplitSchemaURN(t *testing.T) {
+	tests := []struct {
+		path     string
+		wantURN  string
+		wantAttr string
+	}{
+		{"userName", "", "userName"},
+		{"name.givenName", "", "name.givenName"},
+		{SchemaUser + ":userName", SchemaUser, "userName"},
+		{SchemaGroup + ":displayName", SchemaGroup, "displayName"},
+		{SchemaEnterpriseUser + ":department", SchemaEnterpriseUser, "department"},
+		{SchemaEnterpriseUser + ":manager.value", SchemaEnterpriseUser, "manager.value"},
+		{SchemaEnterpriseUser, SchemaEnterpriseUser, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			urn, attr := SplitSchemaURN(tt.path)
+			if urn != tt.wantURN || attr != tt.wantAttr {
+				t.Errorf("SplitSchemaURN(%q) = (%q, %q), want (%q, %q)", tt.path, urn, attr, tt.wantURN, tt.wantAttr)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"slices"
+	"strings"
 )
 
 const (
@@ -24,7 +25,7 @@ type SearchRequest struct {
 }
 
 // handleSearch handles POST /.search
-func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, plugin PluginGetter) {
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string) {
 	if r.Method != http.MethodPost {
 		s.handler.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed", "invalidMethod")
 		return
@@ -68,34 +69,65 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, plugin Plu
 		SortBy:       searchReq.SortBy,
 		SortOrder:    searchReq.SortOrder,
 	}
+	if err := checkPasswordQuery(params); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
+		return
+	}
+	applyDefaultExcludedAttributes(&params, plugin)
+	applySortCollation(&params, plugin)
+	applyAccentInsensitive(&params, plugin)
+
+	// POST /Users/.search and /Groups/.search search one resource type (RFC
+	// 7644 section 3.4.3) and are answered like GET, streamed when the plugin
+	// streams the resource type
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/Users/.search"):
+		if s.allowOperation(w, plugin, pluginName, "Users", OperationRead) {
+			s.listUsers(w, r, plugin, pluginName, params)
+		}
+		return
+	case strings.HasSuffix(r.URL.Path, "/Groups/.search"):
+		if s.allowOperation(w, plugin, pluginName, "Groups", OperationRead) {
+			s.listGroups(w, r, plugin, pluginName, params)
+		}
+		return
+	}
 
-	// Search across both Users and Groups
+	// Search across both Users and Groups, skipping those the plugin does not
+	// allow to read
+	base := s.resourceBaseURL(r.Context(), plugin, pluginName)
 	var allResources []any
 
 	// Get users
-	usersResp, err := plugin.GetUsers(r.Context(), params)
-	if err == nil {
-		for _, user := range usersResp.Resources {
-			allResources = append(allResources, user)
+	if allowsOperation(plugin, "Users", OperationRead) {
+		usersResp, err := plugin.GetUsers(r.Context(), params)
+		if err == nil {
+			for _, user := range usersResp.Resources {
+				s.normalizeUser(user, base)
+				allResources = append(allResources, user)
+			}
 		}
 	}
 
 	// Get groups
-	groupsResp, err := plugin.GetGroups(r.Context(), params)
-	if err == nil {
-		for _, group := range groupsResp.Resources {
-			allResources = append(allResources, group)
+	if allowsOperation(plugin, "Groups", OperationRead) {
+		groupsResp, err := plugin.GetGroups(r.Context(), params)
+		if err == nil {
+			for _, group := range groupsResp.Resources {
+				s.normalizeGroup(group, base)
+				allResources = append(allResources, group)
+			}
 		}
 	}
 
 	// Apply filtering, sorting, and pagination to combined results
-	filtered, err := FilterByFilter(allResources, params.Filter)
+	filtered, err := applyQueryFilter(allResources, params)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
 		return
 	}
 
-	sorted := SortResources(filtered, params.SortBy, params.SortOrder)
+	sorted := SortResourcesCollated(filtered, params.SortBy, params.SortOrder, params.Collation)
 	paged, startIndex, itemsPerPage := ApplyPagination(sorted, params.StartIndex, params.Count)
 
 	// Apply attribute selection
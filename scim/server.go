@@ -3,11 +3,15 @@ package scim
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/scimcontext"
 )
 
 // discardLogger returns a no-op logger that discards all output
@@ -48,6 +52,12 @@ type Server struct {
 	mux           *http.ServeMux
 	etagGen       *ETagGenerator
 	logger        *slog.Logger
+	metrics       *metrics.Registry // nil disables metrics
+	schemas       *SchemaRegistry
+	traceHeaders  []string       // canonical names of the headers passed to plugins
+	passwords     PasswordHasher // nil passes passwords to plugins as sent
+
+	paginationLinks bool // set Link headers on GET list responses
 }
 
 // NewServer creates a new SCIM server without logging
@@ -69,23 +79,106 @@ func NewServerWithLogger(baseURL string, pluginManager PluginManager, logger *sl
 		mux:           http.NewServeMux(),
 		etagGen:       NewETagGenerator(),
 		logger:        logger,
+		schemas:       NewSchemaRegistry(),
+		traceHeaders:  traceContextHeaders,
 	}
 
 	s.setupRoutes()
 	return s
 }
 
+// SetSchemaRegistry sets the extension schemas the server lists and validates.
+// It must be called before the server handles requests.
+func (s *Server) SetSchemaRegistry(schemas *SchemaRegistry) {
+	if schemas == nil {
+		schemas = NewSchemaRegistry()
+	}
+	s.schemas = schemas
+}
+
+// SetMetrics sets the registry the server reports its metrics to, such as
+// scimgateway_response_write_errors_total. It must be called before the
+// server handles requests.
+func (s *Server) SetMetrics(registry *metrics.Registry) {
+	s.metrics = registry
+}
+
+// SetMessageCatalog sets the catalog that translates the detail of error
+// responses into the language of the client's Accept-Language header. Nil
+// writes details in English (default). It must be called before the server
+// handles requests.
+func (s *Server) SetMessageCatalog(catalog *MessageCatalog) {
+	s.handler.messages = catalog
+}
+
 // handlePluginError writes the appropriate error response based on error type
-// If the error is a *SCIMError, it uses the status and scimType from the error
+// If the error is or wraps a *SCIMError, it uses the status and scimType from the error
 // Otherwise, it uses the provided fallback status and scimType
-func (s *Server) handlePluginError(w http.ResponseWriter, err error, fallbackStatus int, fallbackScimType string) {
-	if scimErr, ok := err.(*SCIMError); ok {
+// An *AcceptedError is answered with 202 Accepted instead, with its location
+// resolved against the base URL of the request's plugin.
+func (s *Server) handlePluginError(w http.ResponseWriter, r *http.Request, err error, fallbackStatus int, fallbackScimType string) {
+	var accepted *AcceptedError
+	if errors.As(err, &accepted) {
+		if accepted.Location != "" {
+			pluginName := r.PathValue("plugin")
+			if plugin, ok := s.pluginManager.Get(pluginName); ok {
+				w.Header().Set("Location", s.resourceBaseURL(r.Context(), plugin, pluginName)+"/"+accepted.Location)
+			}
+		}
+		s.handler.WriteJSON(w, http.StatusAccepted, map[string]string{"detail": accepted.Detail})
+		return
+	}
+
+	var scimErr *SCIMError
+	if errors.As(err, &scimErr) {
 		s.handler.WriteSCIMError(w, scimErr)
 	} else {
 		s.handler.WriteError(w, fallbackStatus, err.Error(), fallbackScimType)
 	}
 }
 
+// withPrecondition passes the If-Match precondition a request passed to the
+// plugin, so that it can reject the write if the resource changed since the
+// check. currentETag and meta are those of the resource the precondition was
+// checked against.
+func withPrecondition(r *http.Request, currentETag string, meta *Meta) *http.Request {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return r
+	}
+
+	precondition := Precondition{IfMatch: ifMatch, CurrentETag: currentETag}
+	if meta != nil {
+		precondition.Version = meta.Version
+	}
+	return r.WithContext(WithPrecondition(r.Context(), precondition))
+}
+
+// normalizeUser brings a plugin-provided user into its canonical response shape.
+// It must be applied before ETags are computed so that precondition checks and
+// responses hash the same representation.
+// References the plugin left empty are resolved against base, the URL the
+// plugin's resources are served under (see resourceBaseURL).
+func (s *Server) normalizeUser(user *User, base string) {
+	if user == nil {
+		return
+	}
+	EnsureUserSchemas(user)
+	user.Meta = EnsureResourceType(user.Meta, ResourceTypeUser)
+	user.Password = "" // returned never (RFC 7643 Section 4.1.1)
+	user.Groups = linkGroups(user.Groups, base)
+}
+
+// normalizeGroup brings a plugin-provided group into its canonical response shape
+func (s *Server) normalizeGroup(group *Group, base string) {
+	if group == nil {
+		return
+	}
+	EnsureGroupSchemas(group)
+	group.Meta = EnsureResourceType(group.Meta, ResourceTypeGroup)
+	group.Members = linkMembers(group.Members, base)
+}
+
 // setupRoutes sets up HTTP routes using Go 1.22+ enhanced routing patterns
 func (s *Server) setupRoutes() {
 	// Per-plugin discovery endpoints (public, no auth required - handled by middleware)
@@ -101,6 +194,9 @@ func (s *Server) setupRoutes() {
 	// Bulk endpoint
 	s.mux.HandleFunc("POST /{plugin}/Bulk", s.handleBulkEndpoint)
 
+	// Dry run of a write
+	s.mux.HandleFunc("POST /{plugin}/_simulate", s.handleSimulate)
+
 	// User endpoints
 	s.mux.HandleFunc("GET /{plugin}/Users", s.handleGetUsers)
 	s.mux.HandleFunc("POST /{plugin}/Users", s.handleCreateUser)
@@ -116,18 +212,38 @@ func (s *Server) setupRoutes() {
 	s.mux.HandleFunc("PUT /{plugin}/Groups/{id}", s.handleReplaceGroup)
 	s.mux.HandleFunc("PATCH /{plugin}/Groups/{id}", s.handlePatchGroup)
 	s.mux.HandleFunc("DELETE /{plugin}/Groups/{id}", s.handleDeleteGroup)
+
+	// Custom resource type endpoints (less specific than the routes above)
+	s.mux.HandleFunc("GET /{plugin}/{resourceType}", s.handleGetResources)
+	s.mux.HandleFunc("POST /{plugin}/{resourceType}", s.handleCreateResource)
+	s.mux.HandleFunc("GET /{plugin}/{resourceType}/{id}", s.handleGetResource)
+	s.mux.HandleFunc("PUT /{plugin}/{resourceType}/{id}", s.handleReplaceResource)
+	s.mux.HandleFunc("PATCH /{plugin}/{resourceType}/{id}", s.handlePatchResource)
+	s.mux.HandleFunc("DELETE /{plugin}/{resourceType}/{id}", s.handleDeleteResource)
 }
 
 // ServeHTTP implements http.Handler
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	// Pass the correlation ID of the client on to plugins, and return it
+	// with the response and its errors
+	if id := requestID(r); id != "" {
+		r = r.WithContext(scimcontext.WithRequestID(r.Context(), id))
+		w.Header().Set("X-Request-Id", id)
+	}
+	// and the trace of the request on to their backends
+	if headers := s.propagatedHeaders(r); headers != nil {
+		r = r.WithContext(scimcontext.WithTraceHeaders(r.Context(), headers))
+	}
+	// Responses are not cached unless the plugin allows it
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	s.mux.ServeHTTP(&responseWriter{ResponseWriter: w, server: s, request: r}, r)
 }
 
 // getPlugin retrieves a plugin by name and logs if not found
 func (s *Server) getPlugin(pluginName, endpoint string, r *http.Request) (PluginGetter, bool) {
 	plugin, ok := s.pluginManager.Get(pluginName)
 	if !ok {
-		s.logger.Warn("plugin not found",
+		s.logger.WarnContext(r.Context(), "plugin not found",
 			"plugin", pluginName,
 			"endpoint", endpoint,
 			"remote_addr", r.RemoteAddr,
@@ -141,15 +257,22 @@ func (s *Server) handleServiceProviderConfig(w http.ResponseWriter, r *http.Requ
 	pluginName := r.PathValue("plugin")
 
 	// Verify plugin exists
-	_, ok := s.getPlugin(pluginName, "ServiceProviderConfig", r)
+	plugin, ok := s.getPlugin(pluginName, "ServiceProviderConfig", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
 		return
 	}
 
-	// Return default service provider config
-	config := GetServiceProviderConfig(nil)
-	s.handler.WriteJSON(w, http.StatusOK, config)
+	setCacheControl(w, r, clientCache(plugin).DiscoveryMaxAge)
+	s.handler.WriteJSON(w, http.StatusOK, s.serviceProviderConfig(plugin))
+}
+
+// serviceProviderConfig returns the default service provider config,
+// restricted to the operations the plugin allows and with its bulk limits
+func (s *Server) serviceProviderConfig(plugin PluginGetter) *ServiceProviderConfig {
+	config := s.applyOperations(GetServiceProviderConfig(nil), plugin)
+	config.Bulk.MaxOperations, config.Bulk.MaxPayloadSize = bulkLimits(plugin)
+	return config
 }
 
 // handleResourceTypes handles GET /{plugin}/ResourceTypes
@@ -157,14 +280,22 @@ func (s *Server) handleResourceTypes(w http.ResponseWriter, r *http.Request) {
 	pluginName := r.PathValue("plugin")
 
 	// Verify plugin exists
-	_, ok := s.getPlugin(pluginName, "ResourceTypes", r)
+	plugin, ok := s.getPlugin(pluginName, "ResourceTypes", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
 		return
 	}
 
-	// Return default resource types
+	// Return default resource types with the registered extensions, plus any
+	// custom ones served by the plugin
 	resourceTypes := GetResourceTypes()
+	for i := range resourceTypes {
+		resourceTypes[i].SchemaExtensions = s.schemaExtensionRefs(resourceTypes[i].ID, resourceTypes[i].SchemaExtensions)
+	}
+	for _, rt := range s.resourceTypes(plugin) {
+		resourceTypes = append(resourceTypes, rt.Definition())
+	}
+	setCacheControl(w, r, clientCache(plugin).DiscoveryMaxAge)
 	s.handler.WriteJSON(w, http.StatusOK, map[string]any{"Resources": resourceTypes})
 }
 
@@ -173,31 +304,65 @@ func (s *Server) handleSchemas(w http.ResponseWriter, r *http.Request) {
 	pluginName := r.PathValue("plugin")
 
 	// Verify plugin exists
-	_, ok := s.getPlugin(pluginName, "Schemas", r)
+	plugin, ok := s.getPlugin(pluginName, "Schemas", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
 		return
 	}
 
 	// Return all schemas
 	schemas := []any{
-		GetUserSchema(),
-		GetGroupSchema(),
+		s.schemas.applyMutability(ResourceTypeUser, GetUserSchema()),
+		s.schemas.applyMutability(ResourceTypeGroup, GetGroupSchema()),
+	}
+	for _, resourceType := range []string{ResourceTypeUser, ResourceTypeGroup} {
+		for _, ext := range s.schemas.Extensions(resourceType) {
+			schemas = append(schemas, ext.Schema)
+		}
+	}
+	for _, rt := range s.resourceTypes(plugin) {
+		schemas = append(schemas, rt.Schema())
 	}
+	setCacheControl(w, r, clientCache(plugin).DiscoveryMaxAge)
 	s.handler.WriteJSON(w, http.StatusOK, schemas)
 }
 
+// writeValidationError writes a 400 response for err, keeping the scimType of a
+// wrapped *SCIMError
+func (s *Server) writeValidationError(w http.ResponseWriter, err error) {
+	scimType := ScimTypeInvalidValue
+	var scimErr *SCIMError
+	if errors.As(err, &scimErr) {
+		scimType = scimErr.ScimType
+	}
+	s.handler.WriteError(w, http.StatusBadRequest, err.Error(), scimType)
+}
+
+// schemaExtensionRefs adds the extensions registered for a resource type to its
+// built-in extension references
+func (s *Server) schemaExtensionRefs(resourceType string, refs []SchemaExtensionRef) []SchemaExtensionRef {
+	for _, ext := range s.schemas.Extensions(resourceType) {
+		ref := SchemaExtensionRef{Schema: ext.Schema.ID, Required: ext.Required}
+		if i := slices.IndexFunc(refs, func(r SchemaExtensionRef) bool { return r.Schema == ref.Schema }); i != -1 {
+			refs[i] = ref
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
 // handleSearchEndpoint handles POST /{plugin}/.search
 func (s *Server) handleSearchEndpoint(w http.ResponseWriter, r *http.Request) {
 	pluginName := r.PathValue("plugin")
 
 	plugin, ok := s.pluginManager.Get(pluginName)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
 		return
 	}
 
-	s.handleSearch(w, r, plugin)
+	s.handleSearch(w, r, plugin, pluginName)
 }
 
 // handleBulkEndpoint handles POST /{plugin}/Bulk
@@ -212,7 +377,10 @@ func (s *Server) handleGetUsers(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "GET /Users", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationRead) {
 		return
 	}
 
@@ -225,7 +393,10 @@ func (s *Server) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "POST /Users", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationCreate) {
 		return
 	}
 
@@ -239,7 +410,10 @@ func (s *Server) handleGetUser(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "GET /Users/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationRead) {
 		return
 	}
 
@@ -253,7 +427,10 @@ func (s *Server) handleReplaceUser(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "PUT /Users/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationReplace) {
 		return
 	}
 
@@ -267,7 +444,10 @@ func (s *Server) handlePatchUser(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "PATCH /Users/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationPatch) {
 		return
 	}
 
@@ -281,7 +461,10 @@ func (s *Server) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "DELETE /Users/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Users", OperationDelete) {
 		return
 	}
 
@@ -294,7 +477,10 @@ func (s *Server) handleGetGroups(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "GET /Groups", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationRead) {
 		return
 	}
 
@@ -307,7 +493,10 @@ func (s *Server) handleCreateGroup(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "POST /Groups", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationCreate) {
 		return
 	}
 
@@ -321,7 +510,10 @@ func (s *Server) handleGetGroup(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "GET /Groups/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationRead) {
 		return
 	}
 
@@ -335,7 +527,10 @@ func (s *Server) handleReplaceGroup(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "PUT /Groups/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationReplace) {
 		return
 	}
 
@@ -349,7 +544,10 @@ func (s *Server) handlePatchGroup(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "PATCH /Groups/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationPatch) {
 		return
 	}
 
@@ -363,7 +561,10 @@ func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
 
 	plugin, ok := s.getPlugin(pluginName, "DELETE /Groups/{id}", r)
 	if !ok {
-		s.handler.WriteError(w, http.StatusNotFound, fmt.Sprintf("Plugin '%s' not found", pluginName), "invalidPath")
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+	if !s.allowOperation(w, plugin, pluginName, "Groups", OperationDelete) {
 		return
 	}
 
@@ -372,22 +573,39 @@ func (s *Server) handleDeleteGroup(w http.ResponseWriter, r *http.Request) {
 
 // getUsers handles GET /plugin/Users
 func (s *Server) getUsers(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string) {
-	params, err := s.handler.ParseQueryParams(r)
+	params, err := s.parseQueryParams(r, plugin)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
 		return
 	}
 
+	s.listUsers(w, r, plugin, pluginName, params)
+}
+
+// listUsers writes the users of the plugin matching params, streaming them
+// when the plugin implements UserStreamer and the response is JSON, unless
+// the plugin answers the filter with a lookup
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, params QueryParams) {
+	base := s.resourceBaseURL(r.Context(), plugin, pluginName)
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+	if streamer, ok := lookupCapability[UserStreamer](plugin); ok && streamsJSON(w) && !answeredByLookup(plugin, ResourceTypeUser, params.Filter) {
+		streamList(s, w, r, params, base+"/Users", func(ctx context.Context, yield func(*User) error) error {
+			return streamer.StreamUsers(ctx, streamParams(params), yield)
+		}, func(resource *User) { s.normalizeUser(resource, base) })
+		return
+	}
+
 	response, err := plugin.GetUsers(r.Context(), params)
 	if err != nil {
-		if scimErr, ok := err.(*SCIMError); ok {
-			s.handler.WriteSCIMError(w, scimErr)
-		} else {
-			s.handler.WriteError(w, http.StatusInternalServerError, err.Error(), "internalError")
-		}
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	for _, user := range response.Resources {
+		s.normalizeUser(user, base)
+	}
+	s.setPaginationLinks(w, r, base+"/Users", params, hasNextPage(params, response.TotalResults))
+
 	// Apply attribute selection if specified
 	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
 		selector := NewAttributeSelector(params.Attributes, params.ExcludedAttr)
@@ -433,12 +651,24 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request, plugin Plugi
 		return
 	}
 
-	// Validate user
-	validator := NewValidator()
+	// Normalize and validate user
+	if err := normalizeUserInput(plugin, &user); err != nil {
+		s.writeValidationError(w, err)
+		return
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
 	if err := validator.ValidateUser(&user); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
+	if err := s.checkExternalID(r.Context(), plugin, ResourceTypeUser, "", user.ExternalID); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
+	if err := s.hashUserPassword(&user); err != nil {
+		s.writeValidationError(w, err)
+		return
+	}
 
 	// Set active to true by default if not provided
 	// Note: We check against the raw JSON to see if 'active' was explicitly set
@@ -450,12 +680,14 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request, plugin Plugi
 
 	created, err := plugin.CreateUser(r.Context(), &user)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusInternalServerError, "internalError")
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	s.normalizeUser(created, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Set location header
-	location := s.handler.GetResourceLocation(pluginName, "Users", created.ID)
+	location := s.resourceLocation(r.Context(), plugin, pluginName, "Users", created.ID)
 	w.Header().Set("Location", location)
 	if created.Meta != nil {
 		created.Meta.Location = location
@@ -479,7 +711,7 @@ func (s *Server) createUser(w http.ResponseWriter, r *http.Request, plugin Plugi
 
 // getUser handles GET /plugin/Users/{id}
 func (s *Server) getUser(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	params, err := s.handler.ParseQueryParams(r)
+	params, err := s.parseQueryParams(r, plugin)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
 		return
@@ -487,10 +719,12 @@ func (s *Server) getUser(w http.ResponseWriter, r *http.Request, plugin PluginGe
 
 	user, err := plugin.GetUser(r.Context(), id, params.Attributes)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
+	s.normalizeUser(user, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the resource
 	etag, err := s.etagGen.Generate(user)
 	if err != nil {
@@ -498,6 +732,8 @@ func (s *Server) getUser(w http.ResponseWriter, r *http.Request, plugin PluginGe
 		return
 	}
 
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+
 	// Check If-None-Match for conditional GET (304 Not Modified)
 	status, err := s.etagGen.CheckPreconditions(r, etag)
 	if err != nil && status == http.StatusNotModified {
@@ -531,119 +767,124 @@ func (s *Server) getUser(w http.ResponseWriter, r *http.Request, plugin PluginGe
 
 // replaceUser handles PUT /plugin/Users/{id}
 func (s *Server) replaceUser(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentUser, err := plugin.GetUser(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
-		return
-	}
-
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentUser)
-	if err != nil {
-		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
-		return
-	}
-
-	// Check If-Match precondition
-	status, err := s.etagGen.CheckPreconditions(r, currentETag)
-	if err != nil && status == http.StatusPreconditionFailed {
-		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
-		return
-	}
-
 	var user User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
 		return
 	}
 
-	// Validate user
-	validator := NewValidator()
+	// Normalize and validate user
+	if err := normalizeUserInput(plugin, &user); err != nil {
+		s.writeValidationError(w, err)
+		return
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
 	if err := validator.ValidateUser(&user); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
+	if err := s.checkExternalID(r.Context(), plugin, ResourceTypeUser, id, user.ExternalID); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
 
-	// Ensure ID matches
-	user.ID = id
+	// The current resource is only needed for preconditions and protected attributes
+	if hasPreconditions(r) || validator.replaceNeedsCurrent(ResourceTypeUser, &user) {
+		var current any
+		var ok bool
+		if r, current, ok = s.checkUserPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
+		if err := validator.ValidateReplaceMutability(ResourceTypeUser, &user, current); err != nil {
+			s.writeValidationError(w, err)
+			return
+		}
+	}
 
-	// Delete and recreate (simple replace strategy)
-	if err := plugin.DeleteUser(r.Context(), id); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	if err := s.hashUserPassword(&user); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
 
-	created, err := plugin.CreateUser(r.Context(), &user)
+	// Replace in place if the plugin can, else delete and recreate
+	replaced, err := ReplaceUser(r.Context(), plugin, id, &user)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusInternalServerError, "internalError")
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	s.normalizeUser(replaced, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the updated resource
-	etag, err := s.etagGen.Generate(created)
+	etag, err := s.etagGen.Generate(replaced)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
 		return
 	}
 
 	// Update meta.version with ETag value
-	UpdateResourceVersion(created.Meta, etag)
+	UpdateResourceVersion(replaced.Meta, etag)
 
 	// Set ETag header on response
 	s.etagGen.SetETag(w, etag)
 
-	s.handler.WriteJSON(w, http.StatusOK, created)
+	s.handler.WriteJSON(w, http.StatusOK, replaced)
 }
 
 // modifyUser handles PATCH /plugin/Users/{id}
 func (s *Server) modifyUser(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentUser, err := plugin.GetUser(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	var patch PatchOp
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
 		return
 	}
 
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentUser)
-	if err != nil {
-		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+	// Normalize and validate patch
+	if err := normalizePatchInput(plugin, ResourceTypeUser, &patch); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
-
-	// Check If-Match precondition
-	status, err := s.etagGen.CheckPreconditions(r, currentETag)
-	if err != nil && status == http.StatusPreconditionFailed {
-		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
+	validator := NewValidatorWithSchemas(s.schemas)
+	if err := validator.ValidatePatchOp(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
-
-	var patch PatchOp
-	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
-		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
+	if err := validator.ValidatePatchExtensions(ResourceTypeUser, &patch); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
-
-	// Validate patch
-	validator := NewValidator()
-	if err := validator.ValidatePatchOp(&patch); err != nil {
-		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
+	if err := s.checkPatchExternalID(r.Context(), plugin, ResourceTypeUser, id, &patch); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
-	if err := plugin.ModifyUser(r.Context(), id, &patch); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	// The current resource is only needed for preconditions and protected attributes
+	if hasPreconditions(r) || validator.patchNeedsCurrent(ResourceTypeUser, &patch) {
+		var current any
+		var ok bool
+		if r, current, ok = s.checkUserPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
+		if err := validator.ValidatePatchMutability(ResourceTypeUser, &patch, current); err != nil {
+			s.writeValidationError(w, err)
+			return
+		}
+	}
+
+	if err := s.hashPatchPasswords(&patch); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
 
-	// Return updated user
-	user, err := plugin.GetUser(r.Context(), id, nil)
+	// Modify and return the updated user, fetching it unless the plugin returns it
+	user, err := ModifyUser(r.Context(), plugin, id, &patch)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
+	s.normalizeUser(user, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the updated resource
 	etag, err := s.etagGen.Generate(user)
 	if err != nil {
@@ -662,29 +903,16 @@ func (s *Server) modifyUser(w http.ResponseWriter, r *http.Request, plugin Plugi
 
 // deleteUser handles DELETE /plugin/Users/{id}
 func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentUser, err := plugin.GetUser(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
-		return
-	}
-
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentUser)
-	if err != nil {
-		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
-		return
-	}
-
-	// Check If-Match precondition
-	status, err := s.etagGen.CheckPreconditions(r, currentETag)
-	if err != nil && status == http.StatusPreconditionFailed {
-		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
-		return
+	// Writes without preconditions need not fetch the current resource
+	if hasPreconditions(r) {
+		var ok bool
+		if r, _, ok = s.checkUserPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
 	}
 
 	if err := plugin.DeleteUser(r.Context(), id); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
@@ -693,22 +921,39 @@ func (s *Server) deleteUser(w http.ResponseWriter, r *http.Request, plugin Plugi
 
 // getGroups handles GET /plugin/Groups
 func (s *Server) getGroups(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string) {
-	params, err := s.handler.ParseQueryParams(r)
+	params, err := s.parseQueryParams(r, plugin)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
 		return
 	}
 
+	s.listGroups(w, r, plugin, pluginName, params)
+}
+
+// listGroups writes the groups of the plugin matching params, streaming them
+// when the plugin implements GroupStreamer and the response is JSON, unless
+// the plugin answers the filter with a lookup
+func (s *Server) listGroups(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, params QueryParams) {
+	base := s.resourceBaseURL(r.Context(), plugin, pluginName)
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+	if streamer, ok := lookupCapability[GroupStreamer](plugin); ok && streamsJSON(w) && !answeredByLookup(plugin, ResourceTypeGroup, params.Filter) {
+		streamList(s, w, r, params, base+"/Groups", func(ctx context.Context, yield func(*Group) error) error {
+			return streamer.StreamGroups(ctx, streamParams(params), yield)
+		}, func(resource *Group) { s.normalizeGroup(resource, base) })
+		return
+	}
+
 	response, err := plugin.GetGroups(r.Context(), params)
 	if err != nil {
-		if scimErr, ok := err.(*SCIMError); ok {
-			s.handler.WriteSCIMError(w, scimErr)
-		} else {
-			s.handler.WriteError(w, http.StatusInternalServerError, err.Error(), "internalError")
-		}
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	for _, group := range response.Resources {
+		s.normalizeGroup(group, base)
+	}
+	s.setPaginationLinks(w, r, base+"/Groups", params, hasNextPage(params, response.TotalResults))
+
 	// Apply attribute selection if specified
 	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
 		selector := NewAttributeSelector(params.Attributes, params.ExcludedAttr)
@@ -747,21 +992,31 @@ func (s *Server) createGroup(w http.ResponseWriter, r *http.Request, plugin Plug
 		return
 	}
 
-	// Validate group
-	validator := NewValidator()
+	// Normalize and validate group
+	if err := normalizeGroupInput(plugin, &group); err != nil {
+		s.writeValidationError(w, err)
+		return
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
 	if err := validator.ValidateGroup(&group); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
+	if err := s.checkExternalID(r.Context(), plugin, ResourceTypeGroup, "", group.ExternalID); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+		return
+	}
 
 	created, err := plugin.CreateGroup(r.Context(), &group)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusInternalServerError, "internalError")
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	s.normalizeGroup(created, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Set location header
-	location := s.handler.GetResourceLocation(pluginName, "Groups", created.ID)
+	location := s.resourceLocation(r.Context(), plugin, pluginName, "Groups", created.ID)
 	w.Header().Set("Location", location)
 	if created.Meta != nil {
 		created.Meta.Location = location
@@ -785,18 +1040,30 @@ func (s *Server) createGroup(w http.ResponseWriter, r *http.Request, plugin Plug
 
 // getGroup handles GET /plugin/Groups/{id}
 func (s *Server) getGroup(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	params, err := s.handler.ParseQueryParams(r)
+	params, err := s.parseQueryParams(r, plugin)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidFilter")
 		return
 	}
+	paging, paged, err := parseMemberPaging(r)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusBadRequest, "")
+		return
+	}
+	if paged && r.URL.Query().Get("excludedAttributes") == "" {
+		// Paging members requests them, even if the plugin excludes them by
+		// default, but not if the client excludes them
+		params.ExcludedAttr = withoutAttribute(params.ExcludedAttr, "members")
+	}
 
 	group, err := plugin.GetGroup(r.Context(), id, params.Attributes)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
+	s.normalizeGroup(group, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the resource
 	etag, err := s.etagGen.Generate(group)
 	if err != nil {
@@ -804,6 +1071,8 @@ func (s *Server) getGroup(w http.ResponseWriter, r *http.Request, plugin PluginG
 		return
 	}
 
+	setCacheControl(w, r, clientCache(plugin).ResourceMaxAge)
+
 	// Check If-None-Match for conditional GET (304 Not Modified)
 	status, err := s.etagGen.CheckPreconditions(r, etag)
 	if err != nil && status == http.StatusNotModified {
@@ -819,6 +1088,14 @@ func (s *Server) getGroup(w http.ResponseWriter, r *http.Request, plugin PluginG
 	// Set ETag header on response
 	s.etagGen.SetETag(w, etag)
 
+	// Return only the requested page of members. The ETag is that of the
+	// whole group, so preconditions work the same with and without paging.
+	// Responses without members describe no page.
+	paged = paged && returnsMembers(params)
+	if paged {
+		group = pageMembers(group, paging)
+	}
+
 	// Apply attribute selection
 	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
 		selector := NewAttributeSelector(params.Attributes, params.ExcludedAttr)
@@ -827,6 +1104,9 @@ func (s *Server) getGroup(w http.ResponseWriter, r *http.Request, plugin PluginG
 			s.handler.WriteError(w, http.StatusInternalServerError, err.Error(), "internalError")
 			return
 		}
+		if m, ok := filtered.(map[string]any); ok && paged {
+			m[SchemaMembersPage] = group.Extensions[SchemaMembersPage]
+		}
 		// Return the filtered map directly to preserve exact attribute selection
 		s.handler.WriteJSON(w, http.StatusOK, filtered)
 		return
@@ -837,119 +1117,114 @@ func (s *Server) getGroup(w http.ResponseWriter, r *http.Request, plugin PluginG
 
 // replaceGroup handles PUT /plugin/Groups/{id}
 func (s *Server) replaceGroup(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentGroup, err := plugin.GetGroup(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
-		return
-	}
-
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentGroup)
-	if err != nil {
-		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
-		return
-	}
-
-	// Check If-Match precondition
-	status, err := s.etagGen.CheckPreconditions(r, currentETag)
-	if err != nil && status == http.StatusPreconditionFailed {
-		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
-		return
-	}
-
 	var group Group
 	if err := json.NewDecoder(r.Body).Decode(&group); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
 		return
 	}
 
-	// Validate group
-	validator := NewValidator()
+	// Normalize and validate group
+	if err := normalizeGroupInput(plugin, &group); err != nil {
+		s.writeValidationError(w, err)
+		return
+	}
+	validator := NewValidatorWithSchemas(s.schemas)
 	if err := validator.ValidateGroup(&group); err != nil {
 		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
-
-	// Ensure ID matches
-	group.ID = id
-
-	// Delete and recreate (simple replace strategy)
-	if err := plugin.DeleteGroup(r.Context(), id); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	if err := s.checkExternalID(r.Context(), plugin, ResourceTypeGroup, id, group.ExternalID); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
-	created, err := plugin.CreateGroup(r.Context(), &group)
+	// The current resource is only needed for preconditions and protected attributes
+	if hasPreconditions(r) || validator.replaceNeedsCurrent(ResourceTypeGroup, &group) {
+		var current any
+		var ok bool
+		if r, current, ok = s.checkGroupPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
+		if err := validator.ValidateReplaceMutability(ResourceTypeGroup, &group, current); err != nil {
+			s.writeValidationError(w, err)
+			return
+		}
+	}
+
+	// Replace in place if the plugin can, else delete and recreate
+	replaced, err := ReplaceGroup(r.Context(), plugin, id, &group)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusInternalServerError, "internalError")
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
+	s.normalizeGroup(replaced, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the updated resource
-	etag, err := s.etagGen.Generate(created)
+	etag, err := s.etagGen.Generate(replaced)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
 		return
 	}
 
 	// Update meta.version with ETag value
-	UpdateResourceVersion(created.Meta, etag)
+	UpdateResourceVersion(replaced.Meta, etag)
 
 	// Set ETag header on response
 	s.etagGen.SetETag(w, etag)
 
-	s.handler.WriteJSON(w, http.StatusOK, created)
+	s.handler.WriteJSON(w, http.StatusOK, replaced)
 }
 
 // modifyGroup handles PATCH /plugin/Groups/{id}
 func (s *Server) modifyGroup(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentGroup, err := plugin.GetGroup(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	var patch PatchOp
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
 		return
 	}
 
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentGroup)
-	if err != nil {
-		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+	// Normalize and validate patch
+	if err := normalizePatchInput(plugin, ResourceTypeGroup, &patch); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
-
-	// Check If-Match precondition
-	status, err := s.etagGen.CheckPreconditions(r, currentETag)
-	if err != nil && status == http.StatusPreconditionFailed {
-		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
+	validator := NewValidatorWithSchemas(s.schemas)
+	if err := validator.ValidatePatchOp(&patch); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
 		return
 	}
-
-	var patch PatchOp
-	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
-		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", "invalidSyntax")
+	if err := validator.ValidatePatchExtensions(ResourceTypeGroup, &patch); err != nil {
+		s.writeValidationError(w, err)
 		return
 	}
-
-	// Validate patch
-	validator := NewValidator()
-	if err := validator.ValidatePatchOp(&patch); err != nil {
-		s.handler.WriteError(w, http.StatusBadRequest, err.Error(), "invalidValue")
+	if err := s.checkPatchExternalID(r.Context(), plugin, ResourceTypeGroup, id, &patch); err != nil {
+		s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
 		return
 	}
 
-	if err := plugin.ModifyGroup(r.Context(), id, &patch); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
-		return
+	// The current resource is only needed for preconditions and protected attributes
+	if hasPreconditions(r) || validator.patchNeedsCurrent(ResourceTypeGroup, &patch) {
+		var current any
+		var ok bool
+		if r, current, ok = s.checkGroupPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
+		if err := validator.ValidatePatchMutability(ResourceTypeGroup, &patch, current); err != nil {
+			s.writeValidationError(w, err)
+			return
+		}
 	}
 
-	// Return updated group
-	group, err := plugin.GetGroup(r.Context(), id, nil)
+	// Modify and return the updated group, fetching it unless the plugin returns it
+	group, err := ModifyGroup(r.Context(), plugin, id, &patch)
 	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
+	s.normalizeGroup(group, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
 	// Generate ETag for the updated resource
 	etag, err := s.etagGen.Generate(group)
 	if err != nil {
@@ -968,31 +1243,76 @@ func (s *Server) modifyGroup(w http.ResponseWriter, r *http.Request, plugin Plug
 
 // deleteGroup handles DELETE /plugin/Groups/{id}
 func (s *Server) deleteGroup(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName string, id string) {
-	// Get current resource to check ETag preconditions
-	currentGroup, err := plugin.GetGroup(r.Context(), id, nil)
-	if err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
+	// Writes without preconditions need not fetch the current resource
+	if hasPreconditions(r) {
+		var ok bool
+		if r, _, ok = s.checkGroupPreconditions(w, r, plugin, pluginName, id); !ok {
+			return
+		}
+	}
+
+	if err := plugin.DeleteGroup(r.Context(), id); err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
 		return
 	}
 
-	// Generate ETag for current resource
-	currentETag, err := s.etagGen.Generate(currentGroup)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkUserPreconditions fetches the current user and checks the request's
+// preconditions against it, writing the error response and returning false
+// if the request must not proceed. The returned request carries the
+// precondition for the plugin, and the returned user the version responses
+// show, for mutability checks.
+func (s *Server) checkUserPreconditions(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName, id string) (*http.Request, any, bool) {
+	currentUser, err := plugin.GetUser(r.Context(), id, nil)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return r, nil, false
+	}
+
+	s.normalizeUser(currentUser, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
+	currentETag, err := s.etagGen.Generate(currentUser)
 	if err != nil {
 		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
-		return
+		return r, nil, false
 	}
 
-	// Check If-Match precondition
 	status, err := s.etagGen.CheckPreconditions(r, currentETag)
 	if err != nil && status == http.StatusPreconditionFailed {
 		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
-		return
+		return r, nil, false
 	}
 
-	if err := plugin.DeleteGroup(r.Context(), id); err != nil {
-		s.handlePluginError(w, err, http.StatusNotFound, "")
-		return
+	return withPrecondition(r, currentETag, currentUser.Meta), withVersion(currentUser, currentETag), true
+}
+
+// checkGroupPreconditions fetches the current group and checks the request's
+// preconditions against it, writing the error response and returning false
+// if the request must not proceed. The returned request carries the
+// precondition for the plugin, and the returned group the version responses
+// show, for mutability checks.
+func (s *Server) checkGroupPreconditions(w http.ResponseWriter, r *http.Request, plugin PluginGetter, pluginName, id string) (*http.Request, any, bool) {
+	currentGroup, err := plugin.GetGroup(r.Context(), id, nil)
+	if err != nil {
+		s.handlePluginError(w, r, err, http.StatusNotFound, "")
+		return r, nil, false
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	s.normalizeGroup(currentGroup, s.resourceBaseURL(r.Context(), plugin, pluginName))
+
+	currentETag, err := s.etagGen.Generate(currentGroup)
+	if err != nil {
+		s.handler.WriteError(w, http.StatusInternalServerError, "Failed to generate ETag", "internalError")
+		return r, nil, false
+	}
+
+	status, err := s.etagGen.CheckPreconditions(r, currentETag)
+	if err != nil && status == http.StatusPreconditionFailed {
+		s.handler.WriteError(w, http.StatusPreconditionFailed, err.Error(), "invalidVers")
+		return r, nil, false
+	}
+
+	return withPrecondition(r, currentETag, currentGroup.Meta), withVersion(currentGroup, currentETag), true
 }
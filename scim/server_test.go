@@ -113,7 +113,7 @@ func TestHandlePluginErrorWithSCIMError(t *testing.T) {
 		ScimType: "invalidValue",
 	}
 
-	srv.handlePluginError(w, err, http.StatusInternalServerError, "serverError")
+	srv.handlePluginError(w, httptest.NewRequest("GET", "/test/Users", nil), err, http.StatusInternalServerError, "serverError")
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
@@ -137,7 +137,7 @@ func TestHandlePluginErrorWithRegularError(t *testing.T) {
 	w := httptest.NewRecorder()
 	err := fmt.Errorf("regular error")
 
-	srv.handlePluginError(w, err, http.StatusInternalServerError, "serverError")
+	srv.handlePluginError(w, httptest.NewRequest("GET", "/test/Users", nil), err, http.StatusInternalServerError, "serverError")
 
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
@@ -153,6 +153,29 @@ func TestHandlePluginErrorWithRegularError(t *testing.T) {
 	}
 }
 
+// TestHandlePluginErrorWrappedConflict tests that SCIM errors wrapped by
+// plugins keep their status
+func TestHandlePluginErrorWrappedConflict(t *testing.T) {
+	srv := NewServer("http://localhost:8080", &mockPluginManager{})
+
+	w := httptest.NewRecorder()
+	err := fmt.Errorf("insert user: %w", ErrConflict("User", "userName"))
+
+	srv.handlePluginError(w, httptest.NewRequest("GET", "/test/Users", nil), err, http.StatusInternalServerError, "serverError")
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	var resp Error
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ScimType != ScimTypeUniqueness || resp.Detail != "User with the same userName already exists" {
+		t.Errorf("response = %+v, want uniqueness error", resp)
+	}
+}
+
 // TestHandleServiceProviderConfig tests ServiceProviderConfig endpoint
 func TestHandleServiceProviderConfig(t *testing.T) {
 	plugin := newMockPlugin()
@@ -0,0 +1,90 @@
+package scim
+
+import "context"
+
+// SimplePlugin is a backend that leaves SCIM list processing to the server.
+// GetUsers and GetGroups may return every resource, ignoring params, and
+// NewSimpleAdapter applies the filter, sorting, pagination and attribute
+// selection of the request, except those declared native through
+// CapabilitiesProvider. Every plugin.Plugin is a SimplePlugin.
+type SimplePlugin interface {
+	GetUsers(ctx context.Context, params QueryParams) ([]*User, error)
+	CreateUser(ctx context.Context, user *User) (*User, error)
+	GetUser(ctx context.Context, id string, attributes []string) (*User, error)
+	ModifyUser(ctx context.Context, id string, patch *PatchOp) error
+	DeleteUser(ctx context.Context, id string) error
+	GetGroups(ctx context.Context, params QueryParams) ([]*Group, error)
+	CreateGroup(ctx context.Context, group *Group) (*Group, error)
+	GetGroup(ctx context.Context, id string, attributes []string) (*Group, error)
+	ModifyGroup(ctx context.Context, id string, patch *PatchOp) error
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+// NewSimpleAdapter returns a PluginGetter serving plugin, for servers whose
+// PluginManager is not the gateway's, which adapts plugins itself. Optional
+// interfaces of plugin, such as UserStreamer or Replacer, are still found.
+func NewSimpleAdapter(plugin SimplePlugin) PluginGetter {
+	return &simpleAdapter{plugin: plugin}
+}
+
+// simpleAdapter adapts a SimplePlugin to PluginGetter
+type simpleAdapter struct {
+	plugin SimplePlugin
+}
+
+// Unwrap returns the plugin so the server can discover its capabilities
+func (a *simpleAdapter) Unwrap() any {
+	return a.plugin
+}
+
+// GetUsers implements PluginGetter, applying the parts of the query the
+// plugin does not apply natively
+func (a *simpleAdapter) GetUsers(ctx context.Context, params QueryParams) (*ListResponse[*User], error) {
+	return ListUsersWithCapabilities(ctx, a.plugin, params)
+}
+
+// CreateUser implements PluginGetter
+func (a *simpleAdapter) CreateUser(ctx context.Context, user *User) (*User, error) {
+	return a.plugin.CreateUser(ctx, user)
+}
+
+// GetUser implements PluginGetter
+func (a *simpleAdapter) GetUser(ctx context.Context, id string, attributes []string) (*User, error) {
+	return a.plugin.GetUser(ctx, id, attributes)
+}
+
+// ModifyUser implements PluginGetter
+func (a *simpleAdapter) ModifyUser(ctx context.Context, id string, patch *PatchOp) error {
+	return a.plugin.ModifyUser(ctx, id, patch)
+}
+
+// DeleteUser implements PluginGetter
+func (a *simpleAdapter) DeleteUser(ctx context.Context, id string) error {
+	return a.plugin.DeleteUser(ctx, id)
+}
+
+// GetGroups implements PluginGetter, applying the parts of the query the
+// plugin does not apply natively
+func (a *simpleAdapter) GetGroups(ctx context.Context, params QueryParams) (*ListResponse[*Group], error) {
+	return ListGroupsWithCapabilities(ctx, a.plugin, params)
+}
+
+// CreateGroup implements PluginGetter
+func (a *simpleAdapter) CreateGroup(ctx context.Context, group *Group) (*Group, error) {
+	return a.plugin.CreateGroup(ctx, group)
+}
+
+// GetGroup implements PluginGetter
+func (a *simpleAdapter) GetGroup(ctx context.Context, id string, attributes []string) (*Group, error) {
+	return a.plugin.GetGroup(ctx, id, attributes)
+}
+
+// ModifyGroup implements PluginGetter
+func (a *simpleAdapter) ModifyGroup(ctx context.Context, id string, patch *PatchOp) error {
+	return a.plugin.ModifyGroup(ctx, id, patch)
+}
+
+// DeleteGroup implements PluginGetter
+func (a *simpleAdapter) DeleteGroup(ctx context.Context, id string) error {
+	return a.plugin.DeleteGroup(ctx, id)
+}
@@ -0,0 +1,84 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// slicePlugin is a SimplePlugin returning all of its users and groups
+type slicePlugin struct {
+	*mockPlugin
+}
+
+func (p *slicePlugin) GetUsers(ctx context.Context, params QueryParams) ([]*User, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	users := make([]*User, 0, len(p.users))
+	for _, user := range p.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (p *slicePlugin) GetGroups(ctx context.Context, params QueryParams) ([]*Group, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	groups := make([]*Group, 0, len(p.groups))
+	for _, group := range p.groups {
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+func TestSimpleAdapter(t *testing.T) {
+	p := &slicePlugin{newMockPlugin()}
+	for _, name := range []string{"carol", "alice", "bob", "dave"} {
+		p.CreateUser(context.Background(), &User{ID: name, UserName: name, Active: Bool(name != "dave")}) // nolint:errcheck
+	}
+	p.CreateGroup(context.Background(), &Group{ID: "g1", DisplayName: "Admins"}) // nolint:errcheck
+
+	w := getList(t, NewSimpleAdapter(p), `/test/Users?filter=active+eq+true&sortBy=userName&startIndex=2&count=1&attributes=userName`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ListResponse[map[string]any]
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp.TotalResults != 3 || resp.StartIndex != 2 || resp.ItemsPerPage != 1 {
+		t.Errorf("totalResults = %d, startIndex = %d, itemsPerPage = %d, want 3, 2, 1", resp.TotalResults, resp.StartIndex, resp.ItemsPerPage)
+	}
+	if len(resp.Resources) != 1 || resp.Resources[0]["userName"] != "bob" {
+		t.Fatalf("resources = %v, want bob", resp.Resources)
+	}
+	if _, ok := resp.Resources[0]["active"]; ok {
+		t.Error("attribute selection was not applied")
+	}
+
+	w = getList(t, NewSimpleAdapter(p), `/test/Groups?filter=displayName+eq+"Admins"`)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp.TotalResults != 1 {
+		t.Errorf("groups totalResults = %d, want 1", resp.TotalResults)
+	}
+}
+
+func TestSimpleAdapterCapabilities(t *testing.T) {
+	p := &replacingSlicePlugin{slicePlugin{newMockPlugin()}}
+	if _, ok := lookupCapability[UserReplacer](NewSimpleAdapter(p)); !ok {
+		t.Error("capability of the plugin not found behind the adapter")
+	}
+}
+
+// replacingSlicePlugin is a SimplePlugin that replaces users in place
+type replacingSlicePlugin struct {
+	slicePlugin
+}
+
+func (p *replacingSlicePlugin) ReplaceUser(ctx context.Context, id string, user *User) (*User, error) {
+	return user, nil
+}
@@ -0,0 +1,377 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	SchemaSimulationRequest  = "urn:scimgateway:params:scim:api:messages:2.0:SimulationRequest"
+	SchemaSimulationResponse = "urn:scimgateway:params:scim:api:messages:2.0:SimulationResponse"
+)
+
+// BackendMapper is an optional interface for plugins that map users and
+// groups to records of their backend. The simulation endpoint reports the
+// record a simulated write would send. Passwords are removed from the
+// resources before they are mapped.
+//
+// The server discovers the interface through wrappers such as the plugin
+// adapter, so plugin.Plugin implementations can implement it directly.
+type BackendMapper interface {
+	MapUser(ctx context.Context, user *User) (any, error)
+	MapGroup(ctx context.Context, group *Group) (any, error)
+}
+
+// SimulationRequest is the body of POST /{plugin}/_simulate: a write to
+// simulate, described like a bulk operation
+type SimulationRequest struct {
+	Schemas []string `json:"schemas"`
+
+	// Method is POST, PUT, PATCH or DELETE
+	Method string `json:"method"`
+
+	// Path is the endpoint of the write, such as /Users or /Groups/{id}
+	Path string `json:"path"`
+
+	// Data is the body of the write: a resource for POST and PUT, and a
+	// PatchOp for PATCH
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// SimulationResponse reports what the gateway would do for a SimulationRequest
+type SimulationResponse struct {
+	Schemas []string `json:"schemas"`
+	Method  string   `json:"method"`
+	Path    string   `json:"path"`
+
+	// Valid reports whether the write would be passed to the plugin
+	Valid bool `json:"valid"`
+
+	// Errors are the errors the write would be rejected with
+	Errors []SimulationError `json:"errors,omitempty"`
+
+	// Changes are the attributes the write would change, by name
+	Changes []AttributeChange `json:"changes,omitempty"`
+
+	// Resource is the resource as the write would leave it, without its
+	// password. It is omitted for deletes.
+	Resource any `json:"resource,omitempty"`
+
+	// Backend is the record the plugin would send to its backend, if it
+	// implements BackendMapper
+	Backend any `json:"backend,omitempty"`
+}
+
+// SimulationError is an error a simulated write would be rejected with
+type SimulationError struct {
+	Status   int    `json:"status"`
+	ScimType string `json:"scimType,omitempty"`
+	Detail   string `json:"detail"`
+}
+
+// AttributeChange is the change of one attribute of a resource by a write
+type AttributeChange struct {
+	Attribute string `json:"attribute"`
+
+	// Old and New are the values before and after the write, nil if the
+	// attribute had none. Passwords are reported without their values.
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// addError records err, reported with status and scimType unless it is a
+// *SCIMError
+func (r *SimulationResponse) addError(err error, status int, scimType string) {
+	var scimErr *SCIMError
+	if errors.As(err, &scimErr) {
+		status, scimType = scimErr.Status, scimErr.ScimType
+	}
+	r.Errors = append(r.Errors, SimulationError{Status: status, ScimType: scimType, Detail: err.Error()})
+}
+
+// handleSimulate handles POST /{plugin}/_simulate. It runs the validation of
+// the simulated write against the current resource and reports the changes
+// it would make, without passing it to the plugin.
+func (s *Server) handleSimulate(w http.ResponseWriter, r *http.Request) {
+	pluginName := r.PathValue("plugin")
+
+	plugin, ok := s.getPlugin(pluginName, "POST /_simulate", r)
+	if !ok {
+		s.handler.WriteSCIMError(w, ErrPluginNotFound(pluginName))
+		return
+	}
+
+	var req SimulationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.handler.WriteError(w, http.StatusBadRequest, "Invalid JSON", ScimTypeInvalidSyntax)
+		return
+	}
+	method := strings.ToUpper(req.Method)
+	operation := bulkOperation(method)
+	if operation == "" {
+		s.handler.WriteSCIMError(w, ErrInvalidValue("method must be POST, PUT, PATCH or DELETE"))
+		return
+	}
+	endpoint, id, _ := strings.Cut(strings.Trim(req.Path, "/"), "/")
+	if (endpoint != "Users" && endpoint != "Groups") || strings.Contains(id, "/") || (id == "") != (method == http.MethodPost) {
+		s.handler.WriteSCIMError(w, ErrInvalidPath("path must be /Users or /Groups for POST and /Users/{id} or /Groups/{id} otherwise"))
+		return
+	}
+
+	response := &SimulationResponse{
+		Schemas: []string{SchemaSimulationResponse},
+		Method:  method,
+		Path:    req.Path,
+	}
+	if !allowsOperation(plugin, endpoint, operation) {
+		response.addError(ErrOperationNotAllowed(operation, endpoint, pluginName), http.StatusForbidden, "")
+	}
+
+	base := s.resourceBaseURL(r.Context(), plugin, pluginName)
+	mapper, hasMapper := lookupCapability[BackendMapper](plugin)
+	if endpoint == "Users" {
+		simulateWrite(r.Context(), s, response, method, req.Data, simulatedType[User]{
+			resourceType: ResourceTypeUser,
+			get:          func(ctx context.Context) (*User, error) { return plugin.GetUser(ctx, id, nil) },
+			normalize:    func(user *User) { s.normalizeUser(user, base) },
+			validate: func(user *User) error {
+				if err := normalizeUserInput(plugin, user); err != nil {
+					return err
+				}
+				return NewValidatorWithSchemas(s.schemas).ValidateUser(user)
+			},
+			normalizePatch: func(patch *PatchOp) error { return normalizePatchInput(plugin, ResourceTypeUser, patch) },
+			externalID:     func(user *User) string { return user.ExternalID },
+			checkExternalID: func(ctx context.Context, externalID string) error {
+				return s.checkExternalID(ctx, plugin, ResourceTypeUser, id, externalID)
+			},
+			create: func(user *User, data map[string]any) {
+				if _, exists := data["active"]; !exists {
+					user.Active = Bool(true)
+				}
+			},
+			password: func(user *User) *string { return &user.Password },
+			mapBackend: func(ctx context.Context, user *User) (any, error) {
+				if !hasMapper {
+					return nil, nil
+				}
+				return mapper.MapUser(ctx, user)
+			},
+		})
+	} else {
+		simulateWrite(r.Context(), s, response, method, req.Data, simulatedType[Group]{
+			resourceType: ResourceTypeGroup,
+			get:          func(ctx context.Context) (*Group, error) { return plugin.GetGroup(ctx, id, nil) },
+			normalize:    func(group *Group) { s.normalizeGroup(group, base) },
+			validate: func(group *Group) error {
+				if err := normalizeGroupInput(plugin, group); err != nil {
+					return err
+				}
+				return NewValidatorWithSchemas(s.schemas).ValidateGroup(group)
+			},
+			normalizePatch: func(patch *PatchOp) error { return normalizePatchInput(plugin, ResourceTypeGroup, patch) },
+			externalID:     func(group *Group) string { return group.ExternalID },
+			checkExternalID: func(ctx context.Context, externalID string) error {
+				return s.checkExternalID(ctx, plugin, ResourceTypeGroup, id, externalID)
+			},
+			mapBackend: func(ctx context.Context, group *Group) (any, error) {
+				if !hasMapper {
+					return nil, nil
+				}
+				return mapper.MapGroup(ctx, group)
+			},
+		})
+	}
+	response.Valid = len(response.Errors) == 0
+
+	s.handler.WriteJSON(w, http.StatusOK, response)
+}
+
+// simulatedType holds the operations of a resource type a simulation needs
+type simulatedType[T any] struct {
+	resourceType string
+	get          func(ctx context.Context) (*T, error)
+	normalize    func(*T)
+
+	// validate normalizes and validates a resource sent by the client, and
+	// normalizePatch the values of a PATCH request
+	validate       func(*T) error
+	normalizePatch func(*PatchOp) error
+
+	// externalID returns the externalId of a resource, which
+	// checkExternalID checks for uniqueness
+	externalID      func(*T) string
+	checkExternalID func(ctx context.Context, externalID string) error
+
+	// create applies the defaults of created resources, given the members
+	// of the request body
+	create func(resource *T, data map[string]any)
+
+	// password returns the password field of resource types having one
+	password func(*T) *string
+
+	mapBackend func(ctx context.Context, resource *T) (any, error)
+}
+
+// simulateWrite validates a write with method and body data as the server
+// would, and records its errors, changes, resulting resource and backend
+// record in response
+func simulateWrite[T any](ctx context.Context, s *Server, response *SimulationResponse, method string, data json.RawMessage, t simulatedType[T]) {
+	validator := NewValidatorWithSchemas(s.schemas)
+
+	var before *T
+	if method != http.MethodPost {
+		current, err := t.get(ctx)
+		if err != nil {
+			response.addError(err, http.StatusNotFound, "")
+			return
+		}
+		t.normalize(current)
+		if before, err = cloneResource(current); err != nil {
+			response.addError(err, http.StatusInternalServerError, "")
+			return
+		}
+	}
+
+	var after *T
+	switch method {
+	case http.MethodPost, http.MethodPut:
+		after = new(T)
+		if err := json.Unmarshal(data, after); err != nil {
+			response.addError(ErrInvalidSyntax("Invalid JSON"), http.StatusBadRequest, "")
+			return
+		}
+		if err := t.validate(after); err != nil {
+			response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+		}
+		if method == http.MethodPost {
+			if t.create != nil {
+				var members map[string]any
+				json.Unmarshal(data, &members) // nolint:errcheck
+				t.create(after, members)
+			}
+			break
+		}
+		if err := validator.ValidateReplaceMutability(t.resourceType, after, before); err != nil {
+			response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+		}
+	case http.MethodPatch:
+		var patch PatchOp
+		if err := json.Unmarshal(data, &patch); err != nil {
+			response.addError(ErrInvalidSyntax("Invalid JSON"), http.StatusBadRequest, "")
+			return
+		}
+		for _, validate := range []func() error{
+			func() error { return t.normalizePatch(&patch) },
+			func() error { return validator.ValidatePatchOp(&patch) },
+			func() error { return validator.ValidatePatchExtensions(t.resourceType, &patch) },
+			func() error { return validator.ValidatePatchMutability(t.resourceType, &patch, before) },
+		} {
+			if err := validate(); err != nil {
+				response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+				return
+			}
+		}
+		var err error
+		if after, err = cloneResource(before); err != nil {
+			response.addError(err, http.StatusInternalServerError, "")
+			return
+		}
+		if err := NewPatchProcessor().ApplyPatch(after, &patch); err != nil {
+			response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+			return
+		}
+	}
+
+	response.Changes = resourceChanges(before, after)
+	if after == nil {
+		return
+	}
+	if externalID := t.externalID(after); before == nil || externalID != t.externalID(before) {
+		if err := t.checkExternalID(ctx, externalID); err != nil {
+			response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+		}
+	}
+
+	// Passwords are neither shown nor mapped
+	if t.password != nil {
+		*t.password(after) = ""
+	}
+	response.Resource = after
+	record, err := t.mapBackend(ctx, after)
+	if err != nil {
+		response.addError(err, http.StatusBadRequest, ScimTypeInvalidValue)
+		return
+	}
+	response.Backend = record
+}
+
+// cloneResource returns a deep copy of resource, so a simulation does not
+// modify a resource a plugin holds
+func cloneResource[T any](resource *T) (*T, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	clone := new(T)
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// unchangedAttributes are the attributes left out of resource changes: the
+// ID, which names the resource, and meta, which the plugin sets
+var unchangedAttributes = []string{"id", "meta"}
+
+// resourceChanges returns the attributes whose values differ between before
+// and after, either of which is nil if the resource does not exist, sorted
+// by name
+func resourceChanges[T any](before, after *T) []AttributeChange {
+	old, updated := changeAttributes(before), changeAttributes(after)
+
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range updated {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var changes []AttributeChange
+	for _, name := range names {
+		if reflect.DeepEqual(old[name], updated[name]) {
+			continue
+		}
+		if name == "password" {
+			changes = append(changes, AttributeChange{Attribute: name})
+			continue
+		}
+		changes = append(changes, AttributeChange{Attribute: name, Old: old[name], New: updated[name]})
+	}
+	return changes
+}
+
+// changeAttributes returns the JSON attributes of a resource, without the
+// unchanged ones
+func changeAttributes[T any](resource *T) map[string]any {
+	if resource == nil {
+		return nil
+	}
+	attributes, err := resourceData(resource)
+	if err != nil {
+		return nil
+	}
+	for _, name := range unchangedAttributes {
+		delete(attributes, name)
+	}
+	return attributes
+}
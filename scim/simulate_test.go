@@ -0,0 +1,111 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mappingPlugin is a mockPlugin mapping users to backend records
+type mappingPlugin struct {
+	*mockPlugin
+}
+
+func (p *mappingPlugin) MapUser(ctx context.Context, user *User) (any, error) {
+	return map[string]any{"login": user.UserName, "enabled": user.Active != nil && bool(*user.Active)}, nil
+}
+
+func (p *mappingPlugin) MapGroup(ctx context.Context, group *Group) (any, error) {
+	return map[string]any{"name": group.DisplayName}, nil
+}
+
+func simulate(t *testing.T, srv *Server, body string) SimulationResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/test/_simulate", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp SimulationResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestHandleSimulatePatch(t *testing.T) {
+	plugin := newMockPlugin()
+	plugin.CreateUser(context.Background(), &User{ID: "user1", UserName: "alice", Title: "Engineer", Active: Bool(true)})
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: &mappingPlugin{plugin}})
+
+	resp := simulate(t, srv, `{"method": "PATCH", "path": "/Users/user1", "data": {
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "replace", "path": "active", "value": false}, {"op": "remove", "path": "title"}]
+	}}`)
+
+	if !resp.Valid || len(resp.Errors) != 0 {
+		t.Errorf("valid = %v, errors = %v, want valid", resp.Valid, resp.Errors)
+	}
+	want := []AttributeChange{
+		{Attribute: "active", Old: true, New: false},
+		{Attribute: "title", Old: "Engineer"},
+	}
+	if len(resp.Changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", resp.Changes, want)
+	}
+	for i, change := range resp.Changes {
+		if change != want[i] {
+			t.Errorf("changes[%d] = %+v, want %+v", i, change, want[i])
+		}
+	}
+	if backend, _ := resp.Backend.(map[string]any); backend["login"] != "alice" || backend["enabled"] != false {
+		t.Errorf("backend = %v, want login alice, enabled false", resp.Backend)
+	}
+
+	// Nothing was written
+	user, _ := plugin.GetUser(context.Background(), "user1", nil)
+	if !bool(*user.Active) || user.Title != "Engineer" {
+		t.Errorf("stored user = %+v, want unchanged", user)
+	}
+}
+
+func TestHandleSimulateCreateInvalid(t *testing.T) {
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: newMockPlugin()})
+
+	resp := simulate(t, srv, `{"method": "POST", "path": "/Users", "data": {"schemas": ["`+SchemaUser+`"], "displayName": "No Name", "password": "secret"}}`)
+
+	if resp.Valid {
+		t.Error("valid = true, want false for a user without userName")
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].Status != http.StatusBadRequest || resp.Errors[0].ScimType != ScimTypeInvalidValue {
+		t.Errorf("errors = %+v, want one 400 invalidValue", resp.Errors)
+	}
+	for _, change := range resp.Changes {
+		if change.Attribute == "password" && (change.Old != nil || change.New != nil) {
+			t.Errorf("password change = %+v, want no values", change)
+		}
+	}
+	if resource, _ := resp.Resource.(map[string]any); resource["password"] != nil {
+		t.Errorf("resource password = %v, want none", resource["password"])
+	}
+}
+
+func TestHandleSimulateNotFoundAndInvalidPath(t *testing.T) {
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: newMockPlugin()})
+
+	resp := simulate(t, srv, `{"method": "DELETE", "path": "/Groups/missing"}`)
+	if resp.Valid || len(resp.Errors) != 1 || resp.Errors[0].Status != http.StatusNotFound {
+		t.Errorf("valid = %v, errors = %+v, want one 404", resp.Valid, resp.Errors)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/test/_simulate", strings.NewReader(`{"method": "POST", "path": "/Users/1"}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d for POST to a resource", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,225 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// UserStreamer is an optional interface for plugins that can stream users from a
+// cursor instead of returning them as a slice. The server writes each streamed
+// user to the list response as it arrives, so listing a very large directory
+// never holds the full result set in memory. Responses encoded other than by
+// JSONEncoder (see EncoderMiddleware) are listed with GetUsers instead.
+//
+// The server applies the filter, pagination and attribute selection to the
+// streamed resources and counts all matches for totalResults, so implementations
+// receive params with StartIndex 1 and Count 0 and must yield every matching
+// resource. Resources are paged in the order they are yielded, so implementations
+// must apply params.SortBy/SortOrder natively; params.Filter may be pushed down
+// as an optimization. StreamUsers must stop and return the error when yield
+// returns one (e.g. the client went away).
+//
+// The server discovers the interface through wrappers such as the plugin adapter,
+// so plugin.Plugin implementations can implement it directly.
+type UserStreamer interface {
+	StreamUsers(ctx context.Context, params QueryParams, yield func(*User) error) error
+}
+
+// GroupStreamer is the Group counterpart of UserStreamer
+type GroupStreamer interface {
+	StreamGroups(ctx context.Context, params QueryParams, yield func(*Group) error) error
+}
+
+// streamParams returns the parameters passed to a streaming plugin: the server
+// paginates the stream itself, so the plugin yields all matching resources
+func streamParams(params QueryParams) QueryParams {
+	params.StartIndex = 1
+	params.Count = 0
+	return params
+}
+
+// streamList writes a ListResponse for the resources produced by stream without
+// buffering them, applying the filter, pagination and attribute selection.
+// listURL is the URL of the list, for pagination links.
+// Errors before the first resource is written produce a regular SCIM error
+// response; later errors can only truncate the response, which clients detect
+// as invalid JSON.
+func streamList[T any](s *Server, w http.ResponseWriter, r *http.Request, params QueryParams, listURL string,
+	stream func(ctx context.Context, yield func(T) error) error, normalize func(T)) {
+	var expr Filter
+	if params.Filter != "" {
+		var err error
+		if expr, err = parseQueryFilter(params); err != nil {
+			s.handler.WriteSCIMError(w, ErrInvalidFilter(err.Error()))
+			return
+		}
+		scimcontext.TraceFilter(r.Context(), "evaluated in memory on streamed resources")
+	}
+
+	var selector *AttributeSelector
+	if len(params.Attributes) > 0 || len(params.ExcludedAttr) > 0 {
+		selector = NewAttributeSelector(params.Attributes, params.ExcludedAttr)
+	}
+
+	startIndex := max(params.StartIndex, 1)
+	_, canonical := ResponseEncoder(w).(CanonicalJSONEncoder)
+	lw := &listWriter{w: w, startIndex: startIndex, canonical: canonical}
+	if s.paginationLinks && r.Method == http.MethodGet {
+		lw.links = func(hasNext bool) { s.setPaginationLinks(w, r, listURL, params, hasNext) }
+	}
+	err := stream(r.Context(), func(resource T) error {
+		normalize(resource)
+		if expr != nil && !expr.Matches(resource) {
+			return nil
+		}
+
+		// Count every match for totalResults, but only write the requested
+		// page. A match after it tells the page held for its links has a
+		// next one.
+		lw.total++
+		if lw.total < startIndex {
+			return nil
+		}
+		if params.Count > 0 && lw.count >= params.Count {
+			return lw.flush(true)
+		}
+
+		if selector == nil {
+			return lw.write(resource)
+		}
+		selected, err := selector.FilterResource(resource)
+		if err != nil {
+			return err
+		}
+		return lw.write(selected)
+	})
+
+	if err != nil {
+		if !lw.started {
+			s.handlePluginError(w, r, err, http.StatusInternalServerError, "internalError")
+			return
+		}
+		s.logger.ErrorContext(r.Context(), "list response stream aborted",
+			"path", r.URL.Path,
+			"written", lw.count,
+			"error", err,
+		)
+		return
+	}
+
+	if err := lw.close(); err != nil {
+		s.logger.WarnContext(r.Context(), "failed to finish list response", "path", r.URL.Path, "error", err)
+	}
+}
+
+// listWriter writes a ListResponse incrementally. Resources are written before
+// totalResults, which is only known once the stream ends; JSON object member
+// order is not significant. Canonical lists, whose members are sorted by
+// name as by CanonicalJSONEncoder, start with Resources all the same.
+type listWriter struct {
+	w          http.ResponseWriter
+	started    bool
+	startIndex int
+	count      int // resources written or pending
+	total      int // resources matched
+	canonical  bool
+
+	// links sets the pagination links of the response, see
+	// Server.SetPaginationLinks. While it is set, the page is held in
+	// pending until it is known whether a next page follows.
+	links   func(hasNext bool)
+	pending [][]byte
+}
+
+// start writes the response header and the opening of the Resources array
+func (lw *listWriter) start() error {
+	lw.started = true
+	lw.w.Header().Set("Content-Type", "application/scim+json")
+	lw.w.WriteHeader(http.StatusOK)
+	if lw.canonical {
+		_, err := lw.w.Write([]byte(`{"Resources":[`))
+		return err
+	}
+	_, err := lw.w.Write([]byte(`{"schemas":["` + SchemaListResponse + `"],"Resources":[`))
+	return err
+}
+
+// write appends a resource to the Resources array
+func (lw *listWriter) write(resource any) error {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	if lw.canonical {
+		if data, err = canonicalJSON(data); err != nil {
+			return err
+		}
+	}
+	lw.count++
+
+	if lw.links != nil && !lw.started {
+		lw.pending = append(lw.pending, data)
+		return nil
+	}
+	return lw.writeData(data)
+}
+
+// writeData appends a marshaled resource to the Resources array
+func (lw *listWriter) writeData(data []byte) error {
+	if !lw.started {
+		if err := lw.start(); err != nil {
+			return err
+		}
+	} else if _, err := lw.w.Write([]byte{','}); err != nil {
+		return err
+	}
+
+	_, err := lw.w.Write(data)
+	return err
+}
+
+// flush starts the response with the pagination links and writes the
+// pending page. hasNext tells whether matches follow the page.
+func (lw *listWriter) flush(hasNext bool) error {
+	if lw.started {
+		return nil
+	}
+	if lw.links != nil {
+		lw.links(hasNext)
+	}
+	if err := lw.start(); err != nil {
+		return err
+	}
+	for i, data := range lw.pending {
+		if i > 0 {
+			if _, err := lw.w.Write([]byte{','}); err != nil {
+				return err
+			}
+		}
+		if _, err := lw.w.Write(data); err != nil {
+			return err
+		}
+	}
+	lw.pending = nil
+	return nil
+}
+
+// close ends the Resources array and writes the result counts
+func (lw *listWriter) close() error {
+	if err := lw.flush(false); err != nil {
+		return err
+	}
+
+	if lw.canonical {
+		_, err := fmt.Fprintf(lw.w, `],"itemsPerPage":%d,"schemas":["%s"],"startIndex":%d,"totalResults":%d}`+"\n",
+			lw.count, SchemaListResponse, lw.startIndex, lw.total)
+		return err
+	}
+	_, err := fmt.Fprintf(lw.w, `],"totalResults":%d,"startIndex":%d,"itemsPerPage":%d}`+"\n",
+		lw.total, lw.startIndex, lw.count)
+	return err
+}
@@ -0,0 +1,295 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// streamingPlugin is a mockPlugin that also streams users and groups in ID order
+type streamingPlugin struct {
+	*mockPlugin
+	streamCalls int
+	listCalls   int
+	failAfter   int // fail the stream after this many users; -1 never fails
+}
+
+func newStreamingPlugin(users int) *streamingPlugin {
+	p := &streamingPlugin{mockPlugin: newMockPlugin(), failAfter: -1}
+	for i := range users {
+		p.CreateUser(context.Background(), &User{ // nolint:errcheck
+			ID:       fmt.Sprintf("user%03d", i),
+			UserName: fmt.Sprintf("user%03d", i),
+			Active:   Bool(i%2 == 0),
+		})
+	}
+	p.CreateGroup(context.Background(), &Group{ID: "group1", DisplayName: "Admins"}) // nolint:errcheck
+	return p
+}
+
+func (p *streamingPlugin) GetUsers(ctx context.Context, params QueryParams) (*ListResponse[*User], error) {
+	p.listCalls++
+	return p.mockPlugin.GetUsers(ctx, params)
+}
+
+func (p *streamingPlugin) StreamUsers(ctx context.Context, params QueryParams, yield func(*User) error) error {
+	p.streamCalls++
+	if params.StartIndex != 1 || params.Count != 0 {
+		return fmt.Errorf("streamers should not paginate, got startIndex %d count %d", params.StartIndex, params.Count)
+	}
+
+	p.mu.RLock()
+	ids := make([]string, 0, len(p.users))
+	for id := range p.users {
+		ids = append(ids, id)
+	}
+	p.mu.RUnlock()
+	slices.Sort(ids)
+
+	for i, id := range ids {
+		if i == p.failAfter {
+			return ErrInternalServer("backend connection lost")
+		}
+		p.mu.RLock()
+		user := p.users[id]
+		p.mu.RUnlock()
+		if err := yield(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *streamingPlugin) StreamGroups(ctx context.Context, params QueryParams, yield func(*Group) error) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, group := range p.groups {
+		if err := yield(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func getList(t *testing.T, plugin PluginGetter, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestStreamUsers(t *testing.T) {
+	plugin := newStreamingPlugin(25)
+
+	w := getList(t, plugin, "/test/Users")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if plugin.streamCalls != 1 || plugin.listCalls != 0 {
+		t.Errorf("streamCalls = %d, listCalls = %d, want streaming only", plugin.streamCalls, plugin.listCalls)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/scim+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var resp ListResponse[*User]
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v\n%s", err, w.Body.String())
+	}
+	if resp.TotalResults != 25 || resp.ItemsPerPage != 25 || resp.StartIndex != 1 {
+		t.Errorf("totalResults = %d, itemsPerPage = %d, startIndex = %d", resp.TotalResults, resp.ItemsPerPage, resp.StartIndex)
+	}
+	if len(resp.Schemas) != 1 || resp.Schemas[0] != SchemaListResponse {
+		t.Errorf("schemas = %v", resp.Schemas)
+	}
+	if len(resp.Resources) != 25 || resp.Resources[0].ID != "user000" || resp.Resources[24].ID != "user024" {
+		t.Fatalf("resources not streamed in order: %d resources", len(resp.Resources))
+	}
+	if resp.Resources[0].Meta == nil || resp.Resources[0].Meta.ResourceType != ResourceTypeUser {
+		t.Error("streamed users should be normalized")
+	}
+}
+
+func TestStreamUsersFilterAndAttributes(t *testing.T) {
+	plugin := newStreamingPlugin(10)
+
+	w := getList(t, plugin, "/test/Users?filter=active+eq+true&attributes=userName")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		TotalResults int              `json:"totalResults"`
+		Resources    []map[string]any `json:"Resources"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp.TotalResults != 5 || len(resp.Resources) != 5 {
+		t.Fatalf("totalResults = %d, resources = %d, want 5 active users", resp.TotalResults, len(resp.Resources))
+	}
+	if _, ok := resp.Resources[0]["active"]; ok {
+		t.Error("attributes=userName should drop active")
+	}
+	if resp.Resources[0]["userName"] != "user000" {
+		t.Errorf("userName = %v, want user000", resp.Resources[0]["userName"])
+	}
+}
+
+func TestStreamUsersPagination(t *testing.T) {
+	plugin := newStreamingPlugin(5)
+
+	w := getList(t, plugin, "/test/Users?startIndex=3&count=2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if plugin.streamCalls != 1 || plugin.listCalls != 0 {
+		t.Errorf("streamCalls = %d, listCalls = %d, want streaming only", plugin.streamCalls, plugin.listCalls)
+	}
+
+	var resp ListResponse[*User]
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp.TotalResults != 5 || resp.StartIndex != 3 || resp.ItemsPerPage != 2 {
+		t.Errorf("totalResults = %d, startIndex = %d, itemsPerPage = %d, want 5, 3, 2", resp.TotalResults, resp.StartIndex, resp.ItemsPerPage)
+	}
+	if len(resp.Resources) != 2 || resp.Resources[0].ID != "user002" || resp.Resources[1].ID != "user003" {
+		t.Errorf("page = %v, want user002 and user003", resp.Resources)
+	}
+}
+
+func TestStreamUsersErrors(t *testing.T) {
+	t.Run("invalid filter", func(t *testing.T) {
+		plugin := newStreamingPlugin(1)
+		w := getList(t, plugin, "/test/Users?filter=userName+zz+%22x%22")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+		if plugin.streamCalls != 0 {
+			t.Error("plugin should not be called for an invalid filter")
+		}
+	})
+
+	t.Run("error before first resource", func(t *testing.T) {
+		plugin := newStreamingPlugin(3)
+		plugin.failAfter = 0
+		w := getList(t, plugin, "/test/Users")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+		}
+		if !strings.Contains(w.Body.String(), "backend connection lost") {
+			t.Errorf("body = %s, want SCIM error", w.Body.String())
+		}
+	})
+
+	t.Run("error mid-stream truncates response", func(t *testing.T) {
+		plugin := newStreamingPlugin(3)
+		plugin.failAfter = 2
+		w := getList(t, plugin, "/test/Users")
+		if w.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d (already committed)", w.Code, http.StatusOK)
+		}
+		var resp map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err == nil {
+			t.Error("truncated stream should not be valid JSON")
+		}
+	})
+}
+
+func TestStreamSearch(t *testing.T) {
+	plugin := newStreamingPlugin(10)
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	search := func(target, body string) ListResponse[map[string]any] {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/scim+json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST %s: status = %d: %s", target, w.Code, w.Body.String())
+		}
+		var resp ListResponse[map[string]any]
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("POST %s: invalid JSON response: %v", target, err)
+		}
+		return resp
+	}
+
+	resp := search("/test/Users/.search",
+		`{"schemas":["`+SchemaSearchRequest+`"],"filter":"active eq true","startIndex":2,"count":2}`)
+	if plugin.streamCalls != 1 || plugin.listCalls != 0 {
+		t.Errorf("streamCalls = %d, listCalls = %d, want streaming only", plugin.streamCalls, plugin.listCalls)
+	}
+	if resp.TotalResults != 5 || len(resp.Resources) != 2 || resp.Resources[0]["id"] != "user002" {
+		t.Errorf("totalResults = %d, resources = %v, want user002 and user004 of 5", resp.TotalResults, resp.Resources)
+	}
+
+	// A resource type scoped search returns only that type
+	resp = search("/test/Groups/.search", `{"schemas":["`+SchemaSearchRequest+`"]}`)
+	if resp.TotalResults != 1 || len(resp.Resources) != 1 || resp.Resources[0]["displayName"] != "Admins" {
+		t.Errorf("group search = %d total, %v, want Admins only", resp.TotalResults, resp.Resources)
+	}
+}
+
+func TestStreamEmptyAndGroups(t *testing.T) {
+	w := getList(t, newStreamingPlugin(0), "/test/Users")
+	var users ListResponse[*User]
+	if err := json.Unmarshal(w.Body.Bytes(), &users); err != nil {
+		t.Fatalf("invalid JSON response: %v\n%s", err, w.Body.String())
+	}
+	if users.TotalResults != 0 || users.Resources == nil {
+		t.Errorf("empty stream: totalResults = %d, Resources = %v, want empty array", users.TotalResults, users.Resources)
+	}
+
+	w = getList(t, newStreamingPlugin(0), "/test/Groups")
+	var groups ListResponse[*Group]
+	if err := json.Unmarshal(w.Body.Bytes(), &groups); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if groups.TotalResults != 1 || groups.Resources[0].DisplayName != "Admins" {
+		t.Errorf("groups = %+v", groups)
+	}
+}
+
+// failingWriter fails writes after the response has started, like a client disconnect
+type failingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 2 {
+		return 0, errors.New("connection reset")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestStreamStopsOnWriteError(t *testing.T) {
+	plugin := newStreamingPlugin(100)
+	srv := NewServer("http://localhost:8080", &mockPluginManager{plugin: plugin})
+
+	var yielded int
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest(http.MethodGet, "/test/Users", nil)
+	streamList(srv, w, req, QueryParams{StartIndex: 1}, "", func(ctx context.Context, yield func(*User) error) error {
+		return plugin.StreamUsers(ctx, QueryParams{}, func(u *User) error {
+			yielded++
+			return yield(u)
+		})
+	}, func(u *User) { srv.normalizeUser(u, "") })
+
+	if yielded >= 100 {
+		t.Errorf("stream yielded %d users after the client went away, want it to stop", yielded)
+	}
+}
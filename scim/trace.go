@@ -0,0 +1,43 @@
+package scim
+
+import (
+	"net/http"
+	"slices"
+)
+
+// traceContextHeaders are the W3C Trace Context headers, which the server
+// always passes on to plugins
+var traceContextHeaders = []string{"Traceparent", "Tracestate"}
+
+// SetPropagatedHeaders sets headers, in addition to traceparent and
+// tracestate, that the server passes on to plugins with
+// scimcontext.WithTraceHeaders, e.g. "X-B3-TraceId" for Zipkin tracing or
+// "X-Correlation-Id". HTTP-based plugins attach them to their backend
+// requests. It must be called before the server handles requests.
+func (s *Server) SetPropagatedHeaders(names []string) {
+	headers := slices.Clone(traceContextHeaders)
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		if !slices.Contains(headers, name) {
+			headers = append(headers, name)
+		}
+	}
+	s.traceHeaders = headers
+}
+
+// propagatedHeaders returns the headers of r that are passed on to plugins,
+// or nil if r has none of them
+func (s *Server) propagatedHeaders(r *http.Request) http.Header {
+	var headers http.Header
+	for _, name := range s.traceHeaders {
+		values := r.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		if headers == nil {
+			headers = make(http.Header, len(s.traceHeaders))
+		}
+		headers[name] = slices.Clone(values)
+	}
+	return headers
+}
@@ -0,0 +1,68 @@
+package scim
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// TransactionalPlugin is an optional interface for plugins whose backend can
+// apply several writes atomically, such as SQL databases.
+//
+// For bulk requests with failOnErrors, the server calls Begin before the first
+// operation and passes the returned context to every operation, so the plugin
+// can run them in one transaction. It calls Commit with that context when the
+// request completes, and Rollback when the request stops because failOnErrors
+// was reached, so the operations applied before the failure are undone instead
+// of leaving partial state. Their responses then report status 424.
+//
+// The server discovers the interface through wrappers such as the plugin adapter,
+// so plugin.Plugin implementations can implement it directly.
+type TransactionalPlugin interface {
+	Begin(ctx context.Context) (context.Context, error)
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// transactionKey marks the context of operations running in a transaction
+type transactionKey struct{}
+
+// WithTransaction returns ctx marked as that of operations running in a
+// transaction, as the bulk handler does between Begin and Commit
+func WithTransaction(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transactionKey{}, true)
+}
+
+// InTransaction reports whether ctx is that of a bulk operation running in
+// a transaction of a TransactionalPlugin, whose writes are not committed yet
+// and may be rolled back. Wrappers keeping the plugin's state elsewhere, such
+// as caches, use it to keep uncommitted state out.
+func InTransaction(ctx context.Context) bool {
+	in, _ := ctx.Value(transactionKey{}).(bool)
+	return in
+}
+
+// markRolledBack replaces the responses of successful bulk operations undone
+// by a rollback with a 424 (Failed Dependency) error
+func markRolledBack(results []*BulkOperationResponse, failOnErrors int) {
+	for _, resp := range results {
+		if resp == nil || !isBulkSuccess(resp.Status) {
+			continue
+		}
+		*resp = BulkOperationResponse{
+			Method: resp.Method,
+			BulkID: resp.BulkID,
+			Status: fmt.Sprint(http.StatusFailedDependency),
+			Response: Error{
+				Schemas: []string{SchemaError},
+				Status:  fmt.Sprint(http.StatusFailedDependency),
+				Detail:  fmt.Sprintf("rolled back: the bulk request reached failOnErrors (%d)", failOnErrors),
+			},
+		}
+	}
+}
+
+// isBulkSuccess reports whether a bulk operation status is a success
+func isBulkSuccess(status string) bool {
+	return status == "200" || status == "201" || status == "202" || status == "204"
+}
@@ -0,0 +1,161 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type txCtxKey struct{}
+
+// txPlugin records the transaction calls of a bulk request and checks that
+// operations run with the context returned by Begin
+type txPlugin struct {
+	*mockPlugin
+	calls     []string
+	outsideTx int
+}
+
+func (p *txPlugin) Begin(ctx context.Context) (context.Context, error) {
+	p.calls = append(p.calls, "begin")
+	return context.WithValue(ctx, txCtxKey{}, true), nil
+}
+
+func (p *txPlugin) Commit(ctx context.Context) error {
+	p.calls = append(p.calls, "commit")
+	return nil
+}
+
+func (p *txPlugin) Rollback(ctx context.Context) error {
+	p.calls = append(p.calls, "rollback")
+	return nil
+}
+
+func (p *txPlugin) CreateUser(ctx context.Context, user *User) (*User, error) {
+	if ctx.Value(txCtxKey{}) == nil {
+		p.outsideTx++
+	}
+	if user.UserName == "panic" {
+		panic("plugin failure")
+	}
+	return p.mockPlugin.CreateUser(ctx, user)
+}
+
+func doBulk(t *testing.T, plugin PluginGetter, body string) BulkResponse {
+	t.Helper()
+	server := NewServer("http://localhost:8880", &mockPluginManager{plugin: plugin})
+
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp BulkResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestServer_BulkTransactionRollback(t *testing.T) {
+	plugin := &txPlugin{mockPlugin: newMockPlugin()}
+
+	resp := doBulk(t, plugin, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{"method": "POST", "path": "/Users", "bulkId": "u1", "data": {"userName": "alice"}},
+			{"method": "DELETE", "path": "/Users/nonexistent"},
+			{"method": "POST", "path": "/Users", "data": {"userName": "bob"}}
+		]
+	}`)
+
+	if want := []string{"begin", "rollback"}; !reflect.DeepEqual(plugin.calls, want) {
+		t.Errorf("calls = %v, want %v", plugin.calls, want)
+	}
+	if plugin.outsideTx != 0 {
+		t.Errorf("%d operations ran outside the transaction", plugin.outsideTx)
+	}
+	if len(resp.Operations) != 2 {
+		t.Fatalf("Operations = %d, want 2", len(resp.Operations))
+	}
+
+	rolledBack := resp.Operations[0]
+	if rolledBack.Status != "424" || rolledBack.Location != "" || rolledBack.BulkID != "u1" {
+		t.Errorf("rolled back operation = %+v, want status 424 without a location", rolledBack)
+	}
+	if resp.Operations[1].Status != "404" {
+		t.Errorf("failed operation status = %s, want 404", resp.Operations[1].Status)
+	}
+}
+
+func TestServer_BulkTransactionCommit(t *testing.T) {
+	plugin := &txPlugin{mockPlugin: newMockPlugin()}
+
+	resp := doBulk(t, plugin, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 2,
+		"Operations": [
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice"}},
+			{"method": "DELETE", "path": "/Users/nonexistent"},
+			{"method": "POST", "path": "/Users", "data": {"userName": "bob"}}
+		]
+	}`)
+
+	// One error stays below failOnErrors, so the request commits
+	if want := []string{"begin", "commit"}; !reflect.DeepEqual(plugin.calls, want) {
+		t.Errorf("calls = %v, want %v", plugin.calls, want)
+	}
+	for i, want := range []string{"201", "404", "201"} {
+		if resp.Operations[i].Status != want {
+			t.Errorf("Operation %d status = %s, want %s", i, resp.Operations[i].Status, want)
+		}
+	}
+}
+
+func TestServer_BulkTransactionPanic(t *testing.T) {
+	plugin := &txPlugin{mockPlugin: newMockPlugin()}
+	server := NewServer("http://localhost:8880", &mockPluginManager{plugin: plugin})
+
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"failOnErrors": 1,
+		"Operations": [
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice"}},
+			{"method": "POST", "path": "/Users", "data": {"userName": "panic"}}
+		]
+	}`
+	req := httptest.NewRequest("POST", "/test/Bulk", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	func() {
+		defer func() { _ = recover() }()
+		server.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	// The transaction is not left open
+	if want := []string{"begin", "rollback"}; !reflect.DeepEqual(plugin.calls, want) {
+		t.Errorf("calls = %v, want %v", plugin.calls, want)
+	}
+}
+
+func TestServer_BulkWithoutFailOnErrorsSkipsTransaction(t *testing.T) {
+	plugin := &txPlugin{mockPlugin: newMockPlugin()}
+
+	doBulk(t, plugin, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+		"Operations": [
+			{"method": "POST", "path": "/Users", "data": {"userName": "alice"}}
+		]
+	}`)
+
+	if len(plugin.calls) != 0 {
+		t.Errorf("calls = %v, want none without failOnErrors", plugin.calls)
+	}
+}
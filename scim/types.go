@@ -40,7 +40,7 @@ type User struct {
 	PreferredLang    string            `json:"preferredLanguage,omitempty"`
 	Locale           string            `json:"locale,omitempty"`
 	Timezone         string            `json:"timezone,omitempty"`
-	Active           *bool             `json:"active,omitempty"`
+	Active           *Boolean          `json:"active,omitempty"`
 	Password         string            `json:"password,omitempty"`
 	Emails           []Email           `json:"emails,omitempty"`
 	PhoneNumbers     []PhoneNumber     `json:"phoneNumbers,omitempty"`
@@ -52,6 +52,10 @@ type User struct {
 	Roles            []Role            `json:"roles,omitempty"`
 	X509Certificates []X509Certificate `json:"x509Certificates,omitempty"`
 	EnterpriseUser   map[string]any    `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+
+	// Extensions holds the attributes of other schema extensions, keyed by
+	// schema URN. They are encoded as top-level members named by the URN.
+	Extensions map[string]map[string]any `json:"-"`
 }
 
 // Name represents a user's name components
@@ -66,14 +70,20 @@ type Name struct {
 
 // MultiValuedAttribute represents a generic multi-valued SCIM attribute
 type MultiValuedAttribute[T any] struct {
-	Value   T       `json:"value"`
-	Type    string  `json:"type,omitempty"`
-	Primary Boolean `json:"primary,omitempty"`
-	Display string  `json:"display,omitempty"`
+	Value   T        `json:"value"`
+	Type    string   `json:"type,omitempty"`
+	Primary *Boolean `json:"primary,omitempty"`
+	Display string   `json:"display,omitempty"`
 }
 
+// Boolean is the type of SCIM boolean attributes. Optional attributes are
+// *Boolean, so an explicit false is encoded while an unset attribute is
+// omitted; create them with Bool. Boolean also decodes the strings "true" and
+// "false" in any case, which some clients send instead of JSON booleans.
 type Boolean bool
 
+// UnmarshalJSON decodes a JSON boolean or a "true"/"false" string. Other
+// values leave b unchanged.
 func (b *Boolean) UnmarshalJSON(data []byte) error {
 	var val any
 	if err := json.Unmarshal(data, &val); err != nil {
@@ -97,6 +107,13 @@ func (b *Boolean) UnmarshalJSON(data []byte) error {
 	}
 }
 
+// IsTrue reports whether b is set and true. Unset boolean attributes are
+// false in SCIM.
+func (b *Boolean) IsTrue() bool {
+	return b != nil && bool(*b)
+}
+
+// MarshalJSON encodes b as a JSON boolean
 func (b Boolean) MarshalJSON() ([]byte, error) {
 	return json.Marshal(bool(b))
 }
@@ -115,14 +132,14 @@ type Photo = MultiValuedAttribute[string]
 
 // Address represents a physical mailing address
 type Address struct {
-	Formatted     string `json:"formatted,omitempty"`
-	StreetAddress string `json:"streetAddress,omitempty"`
-	Locality      string `json:"locality,omitempty"`
-	Region        string `json:"region,omitempty"`
-	PostalCode    string `json:"postalCode,omitempty"`
-	Country       string `json:"country,omitempty"`
-	Type          string `json:"type,omitempty"`
-	Primary       bool   `json:"primary,omitempty"`
+	Formatted     string   `json:"formatted,omitempty"`
+	StreetAddress string   `json:"streetAddress,omitempty"`
+	Locality      string   `json:"locality,omitempty"`
+	Region        string   `json:"region,omitempty"`
+	PostalCode    string   `json:"postalCode,omitempty"`
+	Country       string   `json:"country,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	Primary       *Boolean `json:"primary,omitempty"`
 }
 
 // GroupRef represents a reference to a group
@@ -150,6 +167,10 @@ type Group struct {
 	Schemas     []string    `json:"schemas"`
 	DisplayName string      `json:"displayName"`
 	Members     []MemberRef `json:"members,omitempty"`
+
+	// Extensions holds the attributes of schema extensions, keyed by schema URN.
+	// They are encoded as top-level members named by the URN.
+	Extensions map[string]map[string]any `json:"-"`
 }
 
 // MemberRef represents a reference to a group member
@@ -175,6 +196,10 @@ type Error struct {
 	Status   string   `json:"status"`
 	Detail   string   `json:"detail,omitempty"`
 	ScimType string   `json:"scimType,omitempty"`
+
+	// RequestID is the X-Request-Id of the request that failed, an
+	// extension attribute letting clients quote it when reporting errors
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // PatchOp represents a SCIM PATCH operation
@@ -199,9 +224,22 @@ type QueryParams struct {
 	Count        int
 	SortBy       string
 	SortOrder    string
+
+	// Collation is how strings are sorted: "" in code point order, or by
+	// the database's collation in plugins sorting natively, and
+	// CollationCaseInsensitive by their FoldCase form. It is set from the
+	// plugin's CollationProvider, not by clients.
+	Collation string
+
+	// AccentInsensitive makes filters compare strings by their FoldAccents
+	// form, so "Jose" matches "José". It is set from the plugin's
+	// AccentInsensitiveProvider, not by clients.
+	AccentInsensitive bool
 }
 
-// Bool returns a pointer to the given bool value
-func Bool(b bool) *bool {
-	return &b
+// Bool returns a pointer to a Boolean with the given value, for optional
+// boolean attributes such as User.Active and Email.Primary
+func Bool(b bool) *Boolean {
+	v := Boolean(b)
+	return &v
 }
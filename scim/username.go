@@ -0,0 +1,46 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// UserNameLookup is an optional interface for plugins that index the
+// userName of their users. The plugin adapter answers
+// GET /Users?filter=userName eq "...", the most frequent query of identity
+// providers such as Azure AD, with a single lookup instead of listing and
+// filtering the users, which helps most plugins without filter pushdown.
+//
+// userName is not case-exact (RFC 7643 Section 4.1.1), so lookups must
+// ignore case. They are scoped like other requests, e.g. by
+// BaseEntityFromContext, and return nil without error if no user has the
+// name. Plugins that cannot answer with a single user return an error
+// wrapping errors.ErrUnsupported, and the users are listed as usual.
+type UserNameLookup interface {
+	GetUserByUserName(ctx context.Context, userName string) (*User, error)
+}
+
+// UserNameFilter returns the userName a filter selects if it is exactly
+// userName eq "<value>", optionally with the core schema URN, which can be
+// answered with a UserNameLookup
+func UserNameFilter(filterStr string) (string, bool) {
+	return eqFilterValue(filterStr, "userName")
+}
+
+// LookupUsersByUserName answers params, whose filter selects userName, with
+// lookup, applying pagination and attribute selection to the user found.
+// Errors wrapping errors.ErrUnsupported are returned as is, for the caller
+// to list the users instead.
+func LookupUsersByUserName(ctx context.Context, lookup UserNameLookup, userName string, params QueryParams) (*ListResponse[*User], error) {
+	user, err := lookup.GetUserByUserName(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+	scimcontext.TraceFilter(ctx, "answered by userName lookup")
+	var users []*User
+	if user != nil {
+		users = append(users, user)
+	}
+	return ProcessListQuery(users, params)
+}
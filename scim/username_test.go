@@ -0,0 +1,26 @@
+package scim
+
+import "testing"
+
+func TestUserNameFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string
+		ok     bool
+	}{
+		{`userName eq "bjensen"`, "bjensen", true},
+		{`(UserName eq "bjensen")`, "bjensen", true},
+		{SchemaUser + `:userName eq "bjensen"`, "bjensen", true},
+		{SchemaEnterpriseUser + `:userName eq "bjensen"`, "", false},
+		{`userName ne "bjensen"`, "", false},
+		{`userName eq "bjensen" and active eq true`, "", false},
+		{`not (userName eq "bjensen")`, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := UserNameFilter(tt.filter)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("UserNameFilter(%q) = %q, %v, want %q, %v", tt.filter, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package scim
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"slices"
@@ -8,27 +9,39 @@ import (
 )
 
 // Validator validates SCIM resources
-type Validator struct{}
+type Validator struct {
+	schemas *SchemaRegistry
+}
 
-// NewValidator creates a new validator
+// NewValidator creates a new validator that accepts no extension schemas
+// beyond the enterprise User extension
 func NewValidator() *Validator {
 	return &Validator{}
 }
 
-// ValidateUser validates a User resource
+// NewValidatorWithSchemas creates a new validator that validates the data of the
+// extensions registered in schemas
+func NewValidatorWithSchemas(schemas *SchemaRegistry) *Validator {
+	return &Validator{schemas: schemas}
+}
+
+// ValidateUser validates a User resource against the User schema and the
+// registered extensions: attribute types, canonical values, required
+// attributes and sub-attributes, and at most one primary value of
+// multi-valued attributes. The error lists every invalid attribute path.
 func (v *Validator) ValidateUser(user *User) error {
 	if user == nil {
 		return ErrInvalidValue("user cannot be nil")
 	}
 
+	var errs attributeErrors
+
 	// userName is required
 	if strings.TrimSpace(user.UserName) == "" {
-		return ErrInvalidValue("userName is required")
-	}
-
-	// Validate userName format (alphanumeric, dots, underscores, hyphens, @ allowed)
-	if !isValidUserName(user.UserName) {
-		return ErrInvalidValue("userName contains invalid characters")
+		errs.addf("userName is required")
+	} else if !isValidUserName(user.UserName) {
+		// Validate userName format (alphanumeric, dots, underscores, hyphens, @ allowed)
+		errs.addf("userName contains invalid characters")
 	}
 
 	// Validate schemas
@@ -36,18 +49,27 @@ func (v *Validator) ValidateUser(user *User) error {
 		user.Schemas = []string{SchemaUser}
 	}
 
-	return nil
+	if err := validateResourceType(user.Meta, ResourceTypeUser); err != nil {
+		return err
+	}
+
+	checkCoreAttributes(&errs, GetUserSchema(), user)
+	v.schemas.checkExtensions(&errs, ResourceTypeUser, user.Extensions, user.EnterpriseUser)
+	return errs.err()
 }
 
-// ValidateGroup validates a Group resource
+// ValidateGroup validates a Group resource against the Group schema and the
+// registered extensions, like ValidateUser
 func (v *Validator) ValidateGroup(group *Group) error {
 	if group == nil {
 		return ErrInvalidValue("group cannot be nil")
 	}
 
+	var errs attributeErrors
+
 	// displayName is required
 	if strings.TrimSpace(group.DisplayName) == "" {
-		return ErrInvalidValue("displayName is required")
+		errs.addf("displayName is required")
 	}
 
 	// Validate schemas
@@ -55,7 +77,69 @@ func (v *Validator) ValidateGroup(group *Group) error {
 		group.Schemas = []string{SchemaGroup}
 	}
 
-	return nil
+	if err := validateResourceType(group.Meta, ResourceTypeGroup); err != nil {
+		return err
+	}
+
+	checkCoreAttributes(&errs, GetGroupSchema(), group)
+	v.schemas.checkExtensions(&errs, ResourceTypeGroup, group.Extensions, nil)
+	return errs.err()
+}
+
+// checkCoreAttributes adds the problems of the core attributes of resource,
+// a User or Group, to errs. The common attributes id, meta and schemas, and
+// the extension data, are validated apart.
+func checkCoreAttributes(errs *attributeErrors, schema *SchemaDefinition, resource any) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		errs.addf("%s", err)
+		return
+	}
+	var data map[string]any
+	if err := json.Unmarshal(body, &data); err != nil {
+		errs.addf("%s", err)
+		return
+	}
+	for key := range data {
+		if key == "id" || key == "meta" || key == "schemas" || strings.HasPrefix(strings.ToLower(key), "urn:") {
+			delete(data, key)
+		}
+	}
+	checkAttributes(errs, "", schema.Attributes, data)
+}
+
+// ValidateResource validates a custom resource against its resource type's schema:
+// required attributes must be present and meta.resourceType, if set, must match
+func (v *Validator) ValidateResource(rt ResourceType, resource CustomResource) error {
+	if resource == nil {
+		return ErrInvalidValue("resource cannot be nil")
+	}
+
+	for _, attr := range rt.Schema().Attributes {
+		if !attr.Required {
+			continue
+		}
+		value := getAttributeValue(resource, attr.Name)
+		if value == nil || isZeroValue(value) {
+			return ErrInvalidValue(fmt.Sprintf("%s is required", attr.Name))
+		}
+	}
+
+	common := resource.Base()
+	if len(common.Schemas) == 0 {
+		common.Schemas = []string{rt.Schema().ID}
+	}
+
+	return validateResourceType(common.Meta, rt.Definition().Name)
+}
+
+// validateResourceType rejects inbound payloads whose meta.resourceType, when
+// supplied, names a different resource type than the endpoint it was sent to
+func validateResourceType(meta *Meta, expected string) error {
+	if meta == nil || meta.ResourceType == "" || meta.ResourceType == expected {
+		return nil
+	}
+	return ErrInvalidValue(fmt.Sprintf("meta.resourceType must be %q, got %q", expected, meta.ResourceType))
 }
 
 // ValidatePatchOp validates a PATCH operation
@@ -84,6 +168,133 @@ func (v *Validator) ValidatePatchOp(patch *PatchOp) error {
 	return nil
 }
 
+// ValidatePatchExtensions validates the extension attributes a PATCH request to
+// a User or Group sets: paths into unregistered extensions are rejected, and
+// values must match the registered attribute definitions. Extension data of
+// unregistered extensions in operations without a path is dropped, as it is on
+// create.
+func (v *Validator) ValidatePatchExtensions(resourceType string, patch *PatchOp) error {
+	for i, op := range patch.Operations {
+		var err error
+		if op.Path == "" {
+			err = v.validatePatchRootExtensions(resourceType, op.Value)
+		} else {
+			err = v.validatePatchPath(resourceType, op)
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validatePatchRootExtensions validates the extension members of a patch value
+// without a path
+func (v *Validator) validatePatchRootExtensions(resourceType string, value any) error {
+	data, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	for key, attrs := range data {
+		if !strings.HasPrefix(strings.ToLower(key), "urn:") || isCoreSchema(key) {
+			continue
+		}
+		ext, ok := v.schemas.Lookup(key)
+		if !ok || ext.ResourceType != resourceType {
+			if key != SchemaEnterpriseUser {
+				delete(data, key)
+			}
+			continue
+		}
+		attrMap, ok := attrs.(map[string]any)
+		if !ok {
+			return ErrInvalidValue(fmt.Sprintf("extension %s must be a JSON object", key))
+		}
+		if err := validatePartialAttributes(ext, attrMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePatchPath validates an operation whose path is qualified by an
+// extension URN
+func (v *Validator) validatePatchPath(resourceType string, op PatchOperation) error {
+	urn, attrPath := SplitSchemaURN(op.Path)
+	if urn == "" || isCoreSchema(urn) {
+		return nil
+	}
+
+	ext, ok := v.schemas.Lookup(urn)
+	if !ok || ext.ResourceType != resourceType {
+		if urn == SchemaEnterpriseUser {
+			return nil
+		}
+		return ErrInvalidPath(fmt.Sprintf("unknown schema extension %s", urn))
+	}
+
+	remove := strings.EqualFold(op.Op, "remove")
+	if attrPath == "" {
+		attrMap, ok := op.Value.(map[string]any)
+		if remove {
+			return nil
+		}
+		if !ok {
+			return ErrInvalidValue(fmt.Sprintf("extension %s must be a JSON object", urn))
+		}
+		return validatePartialAttributes(ext, attrMap)
+	}
+
+	// Resolve the attribute and, for name.sub paths, the sub-attribute
+	name, filtered := attrPath, false
+	if idx := strings.Index(name, "["); idx != -1 {
+		name, filtered = name[:idx], true
+	}
+	name, sub, _ := strings.Cut(name, ".")
+	if filtered {
+		if _, after, ok := strings.Cut(attrPath, "]."); ok {
+			sub = after
+		}
+	}
+
+	def := findAttributeDefinition(ext.Schema.Attributes, name)
+	if def == nil {
+		return ErrInvalidPath(fmt.Sprintf("attribute %s:%s is not defined", urn, name))
+	}
+	path := urn + ":" + def.Name
+	if sub != "" {
+		if def = findAttributeDefinition(def.SubAttributes, sub); def == nil {
+			return ErrInvalidPath(fmt.Sprintf("attribute %s.%s is not defined", path, sub))
+		}
+		path += "." + def.Name
+	}
+
+	if remove || op.Value == nil {
+		return nil
+	}
+	if _, isArray := op.Value.([]any); def.MultiValued && (!isArray || filtered) {
+		// A single value added to or replacing an element of a multi-valued attribute
+		return validateSingleValue(path, def, op.Value)
+	}
+	return validateAttributeValue(path, def, op.Value)
+}
+
+// validatePartialAttributes checks the attributes a patch sets on an extension
+// without requiring the extension's required attributes
+func validatePartialAttributes(ext SchemaExtension, data map[string]any) error {
+	for name, value := range data {
+		def := findAttributeDefinition(ext.Schema.Attributes, name)
+		if def == nil {
+			return ErrInvalidValue(fmt.Sprintf("attribute %s:%s is not defined", ext.Schema.ID, name))
+		}
+		if err := validateAttributeValue(ext.Schema.ID+":"+def.Name, def, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // validatePatchOperation validates a single patch operation
 func (v *Validator) validatePatchOperation(op PatchOperation) error {
 	// Validate op
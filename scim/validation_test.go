@@ -1,6 +1,7 @@
 package scim
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -102,6 +103,75 @@ func TestValidator_ValidateGroup(t *testing.T) {
 	}
 }
 
+func TestValidator_ValidateUserSchema(t *testing.T) {
+	validator := NewValidator()
+
+	tests := []struct {
+		name       string
+		user       *User
+		wantDetail string
+	}{
+		{
+			name: "canonical values in any case",
+			user: &User{
+				UserName:     "john.doe",
+				Emails:       []Email{{Value: "john@example.com", Type: "Work", Primary: Bool(true)}},
+				PhoneNumbers: []PhoneNumber{{Value: "+1 555 0100", Type: "mobile"}},
+				Addresses:    []Address{{Locality: "Springfield", Type: "home"}},
+			},
+		},
+		{
+			name: "non-canonical type",
+			user: &User{
+				UserName:     "john.doe",
+				PhoneNumbers: []PhoneNumber{{Value: "+1 555 0100", Type: "main"}},
+			},
+			wantDetail: `phoneNumbers[0].type must be one of work, home, mobile, fax, pager, other, got "main"`,
+		},
+		{
+			name: "every invalid attribute is listed",
+			user: &User{
+				Emails: []Email{
+					{Value: "john@example.com", Primary: Bool(true)},
+					{Type: "home", Primary: Bool(true)},
+				},
+				Addresses: []Address{{Type: "office"}},
+			},
+			wantDetail: "userName is required; " +
+				`addresses[0].type must be one of work, home, other, got "office"; ` +
+				"emails[1].value is required; " +
+				"emails must have at most one primary value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidateUser(tt.user)
+			if tt.wantDetail == "" {
+				if err != nil {
+					t.Errorf("ValidateUser() error = %v", err)
+				}
+				return
+			}
+			var scimErr *SCIMError
+			if !errors.As(err, &scimErr) || scimErr.ScimType != ScimTypeInvalidValue || scimErr.Detail != tt.wantDetail {
+				t.Errorf("ValidateUser() error = %v, want invalidValue %q", err, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestValidator_ValidateGroupSchema(t *testing.T) {
+	err := NewValidator().ValidateGroup(&Group{
+		DisplayName: "Admins",
+		Members:     []MemberRef{{Value: "user1", Type: "user"}, {Type: "Person"}},
+	})
+	want := `members[1].type must be one of User, Group, got "Person"; members[1].value is required`
+	if err == nil || err.Error() != want {
+		t.Errorf("ValidateGroup() error = %v, want %q", err, want)
+	}
+}
+
 func TestValidator_ValidatePatchOp(t *testing.T) {
 	validator := NewValidator()
 
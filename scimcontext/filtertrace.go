@@ -0,0 +1,62 @@
+package scimcontext
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type filterTracerKey struct{}
+
+// FilterTracer receives the decisions taken on the filter of a list request:
+// whether the plugin applied it natively, how a query builder translated it
+// for the backend, which parts were left out and whether the gateway
+// evaluated it in memory. The gateway sets a FilterTrace when
+// gateway.filterTrace is configured; embedding applications can set their
+// own tracer, e.g. to add the decisions as span events.
+type FilterTracer interface {
+	TraceFilter(decision string)
+}
+
+// WithFilterTracer returns a context carrying a filter tracer
+func WithFilterTracer(ctx context.Context, tracer FilterTracer) context.Context {
+	return context.WithValue(ctx, filterTracerKey{}, tracer)
+}
+
+// TraceFilter reports a decision on the filter of the request to the tracer
+// of ctx, if any. The decision is only formatted when traced, so plugins and
+// query builders can call it unconditionally:
+//
+//	scimcontext.TraceFilter(ctx, "translated to SQL: %s", where)
+func TraceFilter(ctx context.Context, format string, args ...any) {
+	if tracer, ok := ctx.Value(filterTracerKey{}).(FilterTracer); ok {
+		tracer.TraceFilter(fmt.Sprintf(format, args...))
+	}
+}
+
+// FilterTrace is a FilterTracer recording the decisions of a request. It is
+// safe for concurrent use.
+type FilterTrace struct {
+	mu        sync.Mutex
+	decisions []string
+}
+
+// TraceFilter implements FilterTracer
+func (t *FilterTrace) TraceFilter(decision string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.decisions = append(t.decisions, decision)
+}
+
+// Decisions returns the decisions recorded so far, in order
+func (t *FilterTrace) Decisions() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.decisions...)
+}
+
+// String joins the decisions with "; "
+func (t *FilterTrace) String() string {
+	return strings.Join(t.Decisions(), "; ")
+}
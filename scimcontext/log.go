@@ -0,0 +1,55 @@
+package scimcontext
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler is a slog.Handler adding the request ID of the context to the
+// records of loggers called with one, e.g. logger.InfoContext(ctx, ...), so
+// that all log entries of a request can be correlated. The gateway wraps
+// the logger passed to Gateway.SetLogger with it; plugins wrap their own
+// loggers:
+//
+//	logger := slog.New(scimcontext.NewLogHandler(slog.NewJSONHandler(os.Stdout, nil)))
+type LogHandler struct {
+	next slog.Handler
+}
+
+// NewLogHandler wraps next, adding a request_id attribute to the records
+// logged with a context carrying a request ID
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{next: next}
+}
+
+// Enabled implements slog.Handler
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler. Records already carrying a request_id
+// attribute are passed on unchanged.
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		found := false
+		record.Attrs(func(attr slog.Attr) bool {
+			found = attr.Key == "request_id"
+			return !found
+		})
+		if !found {
+			record = record.Clone()
+			record.AddAttrs(slog.String("request_id", id))
+		}
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,38 @@
+package scimcontext
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type quotaWarningsKey struct{}
+
+// QuotaWarnings collects the warnings of a request nearing a quota, which
+// the gateway sets as X-Quota-Warning response headers. It is safe for
+// concurrent use.
+type QuotaWarnings struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+// WithQuotaWarnings returns a context collecting quota warnings in warnings
+func WithQuotaWarnings(ctx context.Context, warnings *QuotaWarnings) context.Context {
+	return context.WithValue(ctx, quotaWarningsKey{}, warnings)
+}
+
+// WarnQuota adds a warning to the quota warnings of ctx, if any
+func WarnQuota(ctx context.Context, format string, args ...any) {
+	if w, ok := ctx.Value(quotaWarningsKey{}).(*QuotaWarnings); ok {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.warnings = append(w.warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// Warnings returns the warnings collected so far, in order
+func (w *QuotaWarnings) Warnings() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.warnings...)
+}
@@ -0,0 +1,170 @@
+// Package scimcontext defines the request-scoped values the gateway passes to
+// plugins through the context, with typed accessors:
+//
+//	id := scimcontext.RequestID(ctx)          // X-Request-Id of the request
+//	tenant := scimcontext.Tenant(ctx)         // base entity the request is scoped to
+//	settings := scimcontext.TenantConfig(ctx) // config of that base entity
+//	who, ok := scimcontext.Identity(ctx)      // authenticated client, scopes and claims
+//
+// Plugins calling HTTP backends continue the trace of the request with
+//
+//	scimcontext.InjectTraceHeaders(ctx, req.Header)
+//
+// and plugins filtering natively report how they handled the filter with
+//
+//	scimcontext.TraceFilter(ctx, "translated to SQL: %s", where)
+//
+// Accessors return zero values when a value was not set, so plugins can be
+// called outside of a request, e.g. in tests, with context.Background().
+package scimcontext
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Context keys of the values below
+type (
+	requestIDKey     struct{}
+	tenantKey        struct{}
+	tenantConfigKey  struct{}
+	identityKey      struct{}
+	compatProfileKey struct{}
+	traceHeadersKey  struct{}
+)
+
+// WithRequestID returns a context carrying the correlation ID of a request.
+// The gateway sets it from the client's X-Request-Id header, or generates
+// one when the client sent none.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the correlation ID set with WithRequestID, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithTenant returns a context carrying the tenant, or base entity such as
+// an OU, a request is scoped to. Plugins that store several tenants in one
+// backend scope every query by it.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// Tenant returns the tenant set with WithTenant, or "" when the request is
+// not scoped to one
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// WithTenantConfig returns a context carrying the settings of the tenant a
+// request is scoped to. The gateway sets them from the config of the
+// plugin's baseEntities.
+func WithTenantConfig(ctx context.Context, config map[string]any) context.Context {
+	return context.WithValue(ctx, tenantConfigKey{}, config)
+}
+
+// TenantConfig returns the settings set with WithTenantConfig, or nil. The
+// result is shared by all requests to the tenant and must not be modified.
+func TenantConfig(ctx context.Context) map[string]any {
+	config, _ := ctx.Value(tenantConfigKey{}).(map[string]any)
+	return config
+}
+
+// AuthIdentity describes the client a request was authenticated as
+type AuthIdentity struct {
+	// Plugin is the plugin whose authentication accepted the request
+	Plugin string
+
+	// Method is the authentication type of the plugin, e.g. "bearer" or "mtls"
+	Method string
+
+	// Subject names the client when the authenticator can tell, e.g. the
+	// Basic auth username, the "sub" claim of an OAuth2 access token or the
+	// certificate identity of mTLS. Shared secrets such as bearer tokens
+	// leave it empty.
+	Subject string
+
+	// Scopes are the scopes granted to the client, e.g. by an OAuth2
+	// access token. Plugins authorize individual operations by them.
+	Scopes []string
+
+	// Claims are further attributes the authenticator knows of the client,
+	// such as the claims of an access token, for authorization and audit
+	// attribution. They are shared and must not be modified.
+	Claims map[string]any
+}
+
+// WithIdentity returns a context carrying the identity of the authenticated
+// client. The gateway sets it for plugins with authentication configured.
+func WithIdentity(ctx context.Context, identity AuthIdentity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// Identity returns the identity set with WithIdentity. Requests to plugins
+// without authentication have none.
+func Identity(ctx context.Context) (AuthIdentity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(AuthIdentity)
+	return identity, ok
+}
+
+// WithCompatProfile returns a context carrying the name of the client
+// compatibility profile, e.g. "entra" or "okta", that a request is served
+// with. Embedding applications set it to let plugins work around quirks of
+// a specific identity provider.
+func WithCompatProfile(ctx context.Context, profile string) context.Context {
+	return context.WithValue(ctx, compatProfileKey{}, profile)
+}
+
+// CompatProfile returns the profile set with WithCompatProfile, or "" for
+// standard SCIM behavior
+func CompatProfile(ctx context.Context) string {
+	profile, _ := ctx.Value(compatProfileKey{}).(string)
+	return profile
+}
+
+// WithTraceHeaders returns a context carrying the headers of the incoming
+// request that continue its trace, such as the W3C traceparent and
+// tracestate. The gateway sets them, together with the headers configured
+// under gateway.propagateHeaders.
+func WithTraceHeaders(ctx context.Context, headers http.Header) context.Context {
+	return context.WithValue(ctx, traceHeadersKey{}, headers)
+}
+
+// TraceHeaders returns the headers set with WithTraceHeaders, or nil. The
+// result is shared by all calls of a request and must not be modified.
+func TraceHeaders(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(traceHeadersKey{}).(http.Header)
+	return headers
+}
+
+// InjectTraceHeaders copies the trace headers and the X-Request-Id of ctx to
+// dst, the headers of a request to a backend, so that the backend's spans
+// join the trace of the SCIM request and its logs can be correlated with the
+// gateway's. Headers already set in dst are kept.
+func InjectTraceHeaders(ctx context.Context, dst http.Header) {
+	for name, values := range TraceHeaders(ctx) {
+		if _, ok := dst[name]; !ok {
+			dst[name] = append([]string(nil), values...)
+		}
+	}
+	if id := RequestID(ctx); id != "" && dst.Get("X-Request-Id") == "" {
+		dst.Set("X-Request-Id", id)
+	}
+}
+
+// Remaining returns the time left until the deadline of ctx, and false when
+// ctx has no deadline. Plugins use it to size backend timeouts or page
+// sizes; the result is negative once the deadline passed. Deadlines are
+// wall-clock times, so Remaining ignores the clock of the request.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
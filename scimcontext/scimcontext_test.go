@@ -0,0 +1,124 @@
+package scimcontext
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessors(t *testing.T) {
+	ctx := context.Background()
+	if RequestID(ctx) != "" || Tenant(ctx) != "" || CompatProfile(ctx) != "" {
+		t.Error("accessors of an empty context returned values")
+	}
+	if _, ok := Identity(ctx); ok {
+		t.Error("Identity() of an empty context = true")
+	}
+
+	identity := AuthIdentity{Plugin: "hr", Method: "oauth2", Subject: "okta", Scopes: []string{"scim.write"}, Claims: map[string]any{"sub": "okta"}}
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithTenant(ctx, "acme")
+	ctx = WithIdentity(ctx, identity)
+	ctx = WithCompatProfile(ctx, "entra")
+
+	if got := RequestID(ctx); got != "req-1" {
+		t.Errorf("RequestID() = %q", got)
+	}
+	if got := Tenant(ctx); got != "acme" {
+		t.Errorf("Tenant() = %q", got)
+	}
+	if got, ok := Identity(ctx); !ok || !reflect.DeepEqual(got, identity) {
+		t.Errorf("Identity() = %+v, %v", got, ok)
+	}
+	if got := CompatProfile(ctx); got != "entra" {
+		t.Errorf("CompatProfile() = %q", got)
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	if _, ok := Remaining(context.Background()); ok {
+		t.Error("Remaining() without deadline = true")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	remaining, ok := Remaining(ctx)
+	if !ok || remaining <= 0 || remaining > time.Minute {
+		t.Errorf("Remaining() = %v, %v; want up to a minute", remaining, ok)
+	}
+}
+
+func TestInjectTraceHeaders(t *testing.T) {
+	dst := http.Header{"Tracestate": {"backend=1"}}
+	InjectTraceHeaders(context.Background(), dst)
+	if len(dst) != 1 {
+		t.Errorf("InjectTraceHeaders() without trace headers changed dst: %v", dst)
+	}
+
+	ctx := WithTraceHeaders(context.Background(), http.Header{
+		"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":  {"gateway=1"},
+	})
+	InjectTraceHeaders(ctx, dst)
+	if got := dst.Get("Traceparent"); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Traceparent = %q", got)
+	}
+	if got := dst.Get("Tracestate"); got != "backend=1" {
+		t.Errorf("Tracestate = %q, want the header already set", got)
+	}
+
+	dst.Add("Traceparent", "changed")
+	if got := TraceHeaders(ctx)["Traceparent"]; len(got) != 1 {
+		t.Errorf("modifying dst changed the trace headers of ctx: %v", got)
+	}
+
+	InjectTraceHeaders(WithRequestID(ctx, "req-1"), dst)
+	if got := dst.Get("X-Request-Id"); got != "req-1" {
+		t.Errorf("X-Request-Id = %q, want req-1", got)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil))).With("component", "test")
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "with ID")
+	logger.InfoContext(WithRequestID(context.Background(), "req-2"), "explicit ID", "request_id", "req-3")
+	logger.Info("without context")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("logged %d lines, want 3: %s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"request_id":"req-1"`) || !strings.Contains(lines[0], `"component":"test"`) {
+		t.Errorf("entry with a request ID = %s", lines[0])
+	}
+	if strings.Count(lines[1], "request_id") != 1 || !strings.Contains(lines[1], `"request_id":"req-3"`) {
+		t.Errorf("entry with an explicit request ID = %s", lines[1])
+	}
+	if strings.Contains(lines[2], "request_id") {
+		t.Errorf("entry without a request ID = %s", lines[2])
+	}
+}
+
+func TestFilterTrace(t *testing.T) {
+	// Tracing without a tracer is a no-op
+	TraceFilter(context.Background(), "translated to SQL: %s", "x = ?")
+
+	trace := &FilterTrace{}
+	ctx := WithFilterTracer(context.Background(), trace)
+	TraceFilter(ctx, "dropped %s: cannot translate to SQL", "emails.value co \"x\"")
+	TraceFilter(ctx, "evaluated in memory")
+
+	if got := trace.Decisions(); len(got) != 2 || got[1] != "evaluated in memory" {
+		t.Errorf("Decisions() = %q", got)
+	}
+	if got, want := trace.String(), `dropped emails.value co "x": cannot translate to SQL; evaluated in memory`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
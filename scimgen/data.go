@@ -0,0 +1,68 @@
+package scimgen
+
+// The word lists generated values are drawn from
+
+var givenNames = []string{
+	"Aiden", "Amara", "Ana", "Arjun", "Astrid", "Ben", "Carlos", "Chen", "Chloe", "Daniel",
+	"Elena", "Emma", "Fatima", "Felix", "Grace", "Hana", "Hugo", "Ines", "Isaac", "Jack",
+	"Jamal", "Julia", "Kai", "Kenji", "Laura", "Leila", "Liam", "Lucas", "Maria", "Mateo",
+	"Maya", "Mei", "Mohammed", "Nadia", "Noah", "Olivia", "Omar", "Priya", "Rafael", "Sara",
+	"Sofia", "Tariq", "Theo", "Valentina", "Wei", "Yara", "Yuki", "Zoe",
+}
+
+var familyNames = []string{
+	"Almeida", "Andersson", "Bauer", "Brown", "Chen", "Costa", "Dubois", "Fernandez", "Fischer", "Garcia",
+	"Haddad", "Hansen", "Ivanova", "Jensen", "Johnson", "Kim", "Kowalski", "Kumar", "Lee", "Lopez",
+	"Martin", "Meyer", "Moreau", "Mueller", "Nakamura", "Nguyen", "Novak", "Okafor", "Olsen", "Patel",
+	"Rossi", "Santos", "Schmidt", "Silva", "Smith", "Suzuki", "Tanaka", "Taylor", "Wagner", "Wang",
+	"Williams", "Wilson", "Yilmaz", "Zhang",
+}
+
+var titles = []string{
+	"Software Engineer", "Senior Software Engineer", "Engineering Manager", "Product Manager",
+	"Designer", "Data Analyst", "Account Executive", "Recruiter", "Support Specialist",
+	"Financial Analyst", "Operations Manager", "Security Engineer", "Director",
+}
+
+var userTypes = []string{"Employee", "Employee", "Employee", "Contractor", "Intern"}
+
+var timezones = []string{
+	"America/New_York", "America/Chicago", "America/Los_Angeles", "Europe/London",
+	"Europe/Berlin", "Asia/Tokyo", "Asia/Singapore", "Australia/Sydney",
+}
+
+var streets = []string{
+	"Main Street", "Oak Avenue", "Market Street", "Park Road", "Harbor Way",
+	"Elm Street", "High Street", "River Road", "Station Square",
+}
+
+type city struct {
+	locality, region, country string
+}
+
+var cities = []city{
+	{"New York", "NY", "US"},
+	{"Austin", "TX", "US"},
+	{"San Francisco", "CA", "US"},
+	{"London", "England", "GB"},
+	{"Berlin", "Berlin", "DE"},
+	{"Tokyo", "Tokyo", "JP"},
+	{"Sydney", "NSW", "AU"},
+	{"Toronto", "ON", "CA"},
+}
+
+var organizations = []string{"Acme Corp", "Globex", "Initech", "Umbrella", "Stark Industries"}
+
+var divisions = []string{"Technology", "Commercial", "Corporate", "Operations"}
+
+var departments = []string{
+	"Engineering", "Sales", "Marketing", "Finance", "Legal", "Support",
+	"Human Resources", "Operations", "Research", "Security", "Design", "Product",
+}
+
+var groupKinds = []string{"Team", "Admins", "Leads", "Staff", "On-Call", "Guild", "Readers"}
+
+var words = []string{
+	"alpha", "bravo", "cedar", "delta", "ember", "falcon", "granite", "harbor",
+	"indigo", "juniper", "kestrel", "lumen", "meridian", "nova", "orbit", "pine",
+}
@@ -0,0 +1,328 @@
+// Package scimgen generates realistic random Users and Groups for load
+// tests, demos and plugin contract tests.
+//
+// Generated users have names, a unique userName and email addresses, phone
+// numbers, an address and, optionally, enterprise attributes. Groups get
+// members drawn from a set of users. Extension schemas of a registry are
+// filled in too, so generated resources pass the gateway's validation:
+//
+//	gen := scimgen.New(scimgen.Options{Seed: 42, Enterprise: true})
+//	users := gen.Users(1000)
+//	groups := gen.Groups(50, users)
+//
+// A generator with the same seed and options produces the same resources.
+// Generators are not safe for concurrent use.
+package scimgen
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Defaults of Options
+const (
+	DefaultDomain     = "example.com"
+	DefaultMinMembers = 1
+	DefaultMaxMembers = 10
+)
+
+// Options configures a Generator
+type Options struct {
+	// Seed seeds the random source, so a generator's output can be reproduced
+	Seed uint64
+
+	// Domain is the domain of userNames and email addresses,
+	// DefaultDomain if empty
+	Domain string
+
+	// Enterprise adds the enterprise User extension: employee number, cost
+	// center, organization, division, department and a manager among the
+	// users generated before
+	Enterprise bool
+
+	// Schemas holds the extension schemas to fill in. Attributes of the
+	// extensions registered for Users and Groups get random values of their
+	// type and canonical values: required ones always, optional ones half of
+	// the time. Read-only attributes are left out. A registered enterprise
+	// User schema takes the place of the default enterprise attributes.
+	Schemas *scim.SchemaRegistry
+
+	// MinMembers and MaxMembers bound the members of each group, the fan-out
+	// of memberships. They default to DefaultMinMembers and
+	// DefaultMaxMembers when both are zero.
+	MinMembers int
+	MaxMembers int
+}
+
+// Generator produces random Users and Groups
+type Generator struct {
+	opts Options
+	rand *rand.Rand
+
+	// userNames and groupNames are the names generated so far, which are
+	// unique like in the backends
+	userNames  map[string]bool
+	groupNames map[string]bool
+
+	// users are the users generated so far, the candidates for managers
+	users []*scim.User
+}
+
+// New creates a generator
+func New(opts Options) *Generator {
+	if opts.Domain == "" {
+		opts.Domain = DefaultDomain
+	}
+	if opts.MinMembers == 0 && opts.MaxMembers == 0 {
+		opts.MinMembers, opts.MaxMembers = DefaultMinMembers, DefaultMaxMembers
+	}
+	opts.MaxMembers = max(opts.MinMembers, opts.MaxMembers)
+
+	return &Generator{
+		opts:       opts,
+		rand:       rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x5c1a)),
+		userNames:  make(map[string]bool),
+		groupNames: make(map[string]bool),
+	}
+}
+
+// User generates a user. Its ID is a random UUID, which servers assign
+// themselves on create; the members of groups refer to it.
+func (g *Generator) User() *scim.User {
+	given := g.pick(givenNames)
+	family := g.pick(familyNames)
+	local := g.unique(g.userNames, strings.ToLower(given+"."+family), ".")
+	display := given + " " + family
+
+	user := &scim.User{
+		ID:          g.uuid(),
+		ExternalID:  fmt.Sprintf("E%06d", g.rand.IntN(1000000)),
+		UserName:    local + "@" + g.opts.Domain,
+		DisplayName: display,
+		Name: &scim.Name{
+			Formatted:  display,
+			GivenName:  given,
+			FamilyName: family,
+		},
+		Title:         g.pick(titles),
+		UserType:      g.pick(userTypes),
+		PreferredLang: "en-US",
+		Locale:        "en-US",
+		Timezone:      g.pick(timezones),
+		Active:        scim.Bool(g.rand.IntN(10) != 0),
+		Emails: []scim.Email{
+			{Value: local + "@" + g.opts.Domain, Type: "work", Primary: scim.Bool(true)},
+		},
+		PhoneNumbers: []scim.PhoneNumber{
+			{Value: fmt.Sprintf("+1-555-%03d-%04d", g.rand.IntN(1000), g.rand.IntN(10000)), Type: "work"},
+		},
+	}
+	if g.rand.IntN(2) == 0 {
+		user.Emails = append(user.Emails, scim.Email{Value: local + "@home." + g.opts.Domain, Type: "home"})
+	}
+
+	city := cities[g.rand.IntN(len(cities))]
+	street := fmt.Sprintf("%d %s", 1+g.rand.IntN(9999), g.pick(streets))
+	postal := fmt.Sprintf("%05d", g.rand.IntN(100000))
+	user.Addresses = []scim.Address{{
+		Formatted:     fmt.Sprintf("%s\n%s, %s %s\n%s", street, city.locality, city.region, postal, city.country),
+		StreetAddress: street,
+		Locality:      city.locality,
+		Region:        city.region,
+		PostalCode:    postal,
+		Country:       city.country,
+		Type:          "work",
+		Primary:       scim.Bool(true),
+	}}
+
+	for _, ext := range g.opts.Schemas.Extensions(scim.ResourceTypeUser) {
+		data := g.attributes(ext.Schema.Attributes, ext.Required)
+		if len(data) == 0 {
+			continue
+		}
+		if ext.Schema.ID == scim.SchemaEnterpriseUser {
+			user.EnterpriseUser = data
+			continue
+		}
+		if user.Extensions == nil {
+			user.Extensions = make(map[string]map[string]any)
+		}
+		user.Extensions[ext.Schema.ID] = data
+	}
+	if _, registered := g.opts.Schemas.Lookup(scim.SchemaEnterpriseUser); g.opts.Enterprise && !registered {
+		user.EnterpriseUser = g.enterprise()
+	}
+
+	scim.EnsureUserSchemas(user)
+	g.users = append(g.users, user)
+	return user
+}
+
+// Users generates n users
+func (g *Generator) Users(n int) []*scim.User {
+	users := make([]*scim.User, n)
+	for i := range users {
+		users[i] = g.User()
+	}
+	return users
+}
+
+// Group generates a group with the given members
+func (g *Generator) Group(members []*scim.User) *scim.Group {
+	group := &scim.Group{
+		ID:          g.uuid(),
+		ExternalID:  fmt.Sprintf("G%06d", g.rand.IntN(1000000)),
+		DisplayName: g.unique(g.groupNames, g.pick(departments)+" "+g.pick(groupKinds), " "),
+	}
+	for _, member := range members {
+		group.Members = append(group.Members, scim.MemberRef{
+			Value:   member.ID,
+			Type:    "User",
+			Display: member.DisplayName,
+		})
+	}
+
+	for _, ext := range g.opts.Schemas.Extensions(scim.ResourceTypeGroup) {
+		data := g.attributes(ext.Schema.Attributes, ext.Required)
+		if len(data) == 0 {
+			continue
+		}
+		if group.Extensions == nil {
+			group.Extensions = make(map[string]map[string]any)
+		}
+		group.Extensions[ext.Schema.ID] = data
+	}
+
+	scim.EnsureGroupSchemas(group)
+	return group
+}
+
+// Groups generates n groups, each with between MinMembers and MaxMembers
+// distinct members drawn from users. Pass the users a server returned on
+// create, so that members refer to their assigned IDs.
+func (g *Generator) Groups(n int, users []*scim.User) []*scim.Group {
+	groups := make([]*scim.Group, n)
+	for i := range groups {
+		count := min(g.opts.MinMembers+g.rand.IntN(g.opts.MaxMembers-g.opts.MinMembers+1), len(users))
+		members := make([]*scim.User, count)
+		for j, k := range g.rand.Perm(len(users))[:count] {
+			members[j] = users[k]
+		}
+		groups[i] = g.Group(members)
+	}
+	return groups
+}
+
+// enterprise generates the default enterprise User extension
+func (g *Generator) enterprise() map[string]any {
+	division := g.pick(divisions)
+	data := map[string]any{
+		"employeeNumber": fmt.Sprintf("%06d", g.rand.IntN(1000000)),
+		"costCenter":     fmt.Sprintf("CC-%04d", g.rand.IntN(10000)),
+		"organization":   g.pick(organizations),
+		"division":       division,
+		"department":     g.pick(departments),
+	}
+	if len(g.users) > 0 {
+		manager := g.users[g.rand.IntN(len(g.users))]
+		data["manager"] = map[string]any{"value": manager.ID, "displayName": manager.DisplayName}
+	}
+	return data
+}
+
+// attributes generates values for the attributes of an extension or
+// complex attribute. Optional attributes are left out half of the time
+// unless all is set.
+func (g *Generator) attributes(definitions []scim.AttributeDefinition, all bool) map[string]any {
+	data := make(map[string]any)
+	for i := range definitions {
+		def := &definitions[i]
+		if def.Mutability == scim.MutabilityReadOnly || (!def.Required && !all && g.rand.IntN(2) == 0) {
+			continue
+		}
+		if !def.MultiValued {
+			data[def.Name] = g.value(def)
+			continue
+		}
+		values := make([]any, 1+g.rand.IntN(3))
+		for j := range values {
+			values[j] = g.value(def)
+		}
+		data[def.Name] = values
+	}
+	return data
+}
+
+// value generates a single value of an attribute, as decoded from JSON
+func (g *Generator) value(def *scim.AttributeDefinition) any {
+	if len(def.CanonicalValues) > 0 {
+		return g.pick(def.CanonicalValues)
+	}
+
+	switch def.Type {
+	case "boolean":
+		return g.rand.IntN(2) == 0
+	case "integer":
+		return float64(g.rand.IntN(10000))
+	case "decimal":
+		return float64(g.rand.IntN(1000000)) / 100
+	case "dateTime":
+		base := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return base.Add(time.Duration(g.rand.Int64N(int64(5 * 365 * 24 * time.Hour)))).Truncate(time.Second).Format(time.RFC3339)
+	case "binary":
+		data := make([]byte, 16)
+		for i := range data {
+			data[i] = byte(g.rand.UintN(256))
+		}
+		return base64.StdEncoding.EncodeToString(data)
+	case "reference":
+		return fmt.Sprintf("https://%s/%s/%d", g.opts.Domain, strings.ToLower(def.Name), g.rand.IntN(10000))
+	case "complex":
+		return g.attributes(def.SubAttributes, false)
+	}
+
+	switch strings.ToLower(def.Name) {
+	case "employeenumber":
+		return fmt.Sprintf("%06d", g.rand.IntN(1000000))
+	case "costcenter":
+		return fmt.Sprintf("CC-%04d", g.rand.IntN(10000))
+	case "organization":
+		return g.pick(organizations)
+	case "division":
+		return g.pick(divisions)
+	case "department":
+		return g.pick(departments)
+	case "displayname":
+		return g.pick(givenNames) + " " + g.pick(familyNames)
+	}
+	return fmt.Sprintf("%s-%04d", g.pick(words), g.rand.IntN(10000))
+}
+
+// unique returns name, or name with the lowest free numeric suffix joined by
+// sep, and records it in used
+func (g *Generator) unique(used map[string]bool, name, sep string) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%s%d", name, sep, i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// uuid returns a random version 4 UUID drawn from the generator's source
+func (g *Generator) uuid() string {
+	hi, lo := g.rand.Uint64(), g.rand.Uint64()
+	hi = hi&^0xf000 | 0x4000
+	lo = lo&^(0xc<<60) | 0x8<<60
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+}
+
+// pick returns a random element of values
+func (g *Generator) pick(values []string) string {
+	return values[g.rand.IntN(len(values))]
+}
@@ -0,0 +1,174 @@
+package scimgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/marcelom97/scimgateway"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestGeneratorIsReproducible(t *testing.T) {
+	generate := func(seed uint64) ([]*scim.User, []*scim.Group) {
+		gen := New(Options{Seed: seed, Enterprise: true})
+		users := gen.Users(20)
+		return users, gen.Groups(5, users)
+	}
+
+	users, groups := generate(7)
+	again, againGroups := generate(7)
+	if !reflect.DeepEqual(users, again) || !reflect.DeepEqual(groups, againGroups) {
+		t.Error("generators with the same seed produced different resources")
+	}
+	if other, _ := generate(8); reflect.DeepEqual(users, other) {
+		t.Error("generators with different seeds produced the same users")
+	}
+}
+
+func TestUsers(t *testing.T) {
+	gen := New(Options{Seed: 1, Domain: "acme.test", Enterprise: true})
+	users := gen.Users(500)
+
+	userNames := make(map[string]bool)
+	ids := make(map[string]bool)
+	for i, user := range users {
+		if userNames[user.UserName] || ids[user.ID] {
+			t.Fatalf("user %d repeats userName %q or ID %q", i, user.UserName, user.ID)
+		}
+		userNames[user.UserName], ids[user.ID] = true, true
+
+		if user.Name == nil || user.Name.GivenName == "" || user.Name.FamilyName == "" {
+			t.Errorf("user %s has no name", user.UserName)
+		}
+		if len(user.Emails) == 0 || user.Emails[0].Value != user.UserName {
+			t.Errorf("user %s has emails %v, want the userName first", user.UserName, user.Emails)
+		}
+		if !slices.Contains(user.Schemas, scim.SchemaEnterpriseUser) || user.EnterpriseUser["department"] == nil {
+			t.Errorf("user %s lacks the enterprise extension: %v", user.UserName, user.EnterpriseUser)
+		}
+		if _, ok := user.EnterpriseUser["manager"]; ok != (i > 0) {
+			t.Errorf("user %d has manager = %v", i, ok)
+		}
+	}
+	if _, err := json.Marshal(users); err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	gen := New(Options{Seed: 1, MinMembers: 2, MaxMembers: 4})
+	users := gen.Users(10)
+	groups := gen.Groups(30, users)
+
+	names := make(map[string]bool)
+	for _, group := range groups {
+		if names[group.DisplayName] {
+			t.Errorf("displayName %q is repeated", group.DisplayName)
+		}
+		names[group.DisplayName] = true
+
+		if len(group.Members) < 2 || len(group.Members) > 4 {
+			t.Errorf("group %q has %d members, want 2 to 4", group.DisplayName, len(group.Members))
+		}
+		seen := make(map[string]bool)
+		for _, member := range group.Members {
+			if seen[member.Value] || !slices.ContainsFunc(users, func(u *scim.User) bool { return u.ID == member.Value }) {
+				t.Errorf("group %q has a repeated or unknown member %q", group.DisplayName, member.Value)
+			}
+			seen[member.Value] = true
+		}
+	}
+
+	if groups := New(Options{MinMembers: 5}).Groups(1, users[:3]); len(groups[0].Members) != 3 {
+		t.Errorf("members = %d, want all 3 users when fewer than MinMembers", len(groups[0].Members))
+	}
+}
+
+func TestGeneratedResourcesPassValidation(t *testing.T) {
+	userURN := "urn:example:scim:schemas:extension:acme:1.0:User"
+	groupURN := "urn:example:scim:schemas:extension:acme:1.0:Group"
+	extensions := []scim.SchemaExtension{
+		{
+			ResourceType: scim.ResourceTypeUser,
+			Required:     true,
+			Schema: &scim.SchemaDefinition{ID: userURN, Attributes: []scim.AttributeDefinition{
+				{Name: "badge", Type: "integer", Required: true},
+				{Name: "clearance", Type: "string", CanonicalValues: []string{"public", "secret"}},
+				{Name: "hired", Type: "dateTime"},
+				{Name: "rate", Type: "decimal"},
+				{Name: "remote", Type: "boolean"},
+				{Name: "avatar", Type: "binary"},
+				{Name: "homepage", Type: "reference"},
+				{Name: "skills", Type: "string", MultiValued: true},
+				{Name: "badgeId", Type: "string", Mutability: scim.MutabilityReadOnly},
+				{Name: "desk", Type: "complex", SubAttributes: []scim.AttributeDefinition{
+					{Name: "building", Type: "string", Required: true},
+					{Name: "floor", Type: "integer"},
+				}},
+			}},
+		},
+		{
+			ResourceType: scim.ResourceTypeGroup,
+			Schema: &scim.SchemaDefinition{ID: groupURN, Attributes: []scim.AttributeDefinition{
+				{Name: "owner", Type: "string", Required: true},
+			}},
+		},
+	}
+
+	schemas := scim.NewSchemaRegistry()
+	gw := scimgateway.New(&config.Config{
+		Gateway: config.GatewayConfig{BaseURL: "http://localhost:8080", Port: 8080},
+		Plugins: []config.PluginConfig{{Name: "test"}},
+	})
+	for _, ext := range extensions {
+		if err := schemas.Register(ext); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.RegisterSchemaExtension(ext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	create := func(path string, resource, created any) {
+		t.Helper()
+		body, _ := json.Marshal(resource)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d, body: %s\nresource: %s", path, w.Code, w.Body.String(), body)
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), created); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gen := New(Options{Seed: 3, Schemas: schemas, Enterprise: true})
+	var users []*scim.User
+	for _, user := range gen.Users(25) {
+		if _, ok := user.Extensions[userURN]["badgeId"]; ok {
+			t.Errorf("user %s has the read-only badgeId", user.UserName)
+		}
+		var created scim.User
+		create("/test/Users", user, &created)
+		users = append(users, &created)
+	}
+	for _, group := range gen.Groups(5, users) {
+		var created scim.Group
+		create("/test/Groups", group, &created)
+		if len(created.Members) != len(group.Members) {
+			t.Errorf("group %q has %d members, want %d", created.DisplayName, len(created.Members), len(group.Members))
+		}
+	}
+}
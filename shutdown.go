@@ -0,0 +1,161 @@
+package scimgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/marcelom97/scimgateway/plugin"
+)
+
+// ShutdownReport summarizes a shutdown, so operators can tell a clean
+// shutdown from one that aborted work, e.g. during a deploy
+type ShutdownReport struct {
+	// Duration is the time the shutdown took
+	Duration time.Duration
+
+	// InFlight is the number of requests being served when the shutdown
+	// began. Drained of them completed, and Aborted were still running when
+	// the context of the shutdown was done.
+	InFlight int
+	Drained  int
+	Aborted  int
+
+	// PendingAsyncWrites is the number of writes of plugins with
+	// asyncWrites not yet applied, by plugin. Durable operation stores
+	// apply them when the gateway starts again; memory stores lose them.
+	PendingAsyncWrites map[string]int
+
+	// QueuedWindowWrites is the number of writes held until the write
+	// window of a plugin opens, by plugin. They are kept in memory and lost.
+	QueuedWindowWrites map[string]int
+
+	// ClosedPlugins are the plugins implementing plugin.Closer that closed,
+	// and CloseErrors the errors of those that did not, by plugin
+	ClosedPlugins []string
+	CloseErrors   map[string]error
+}
+
+// Clean reports whether every request was drained, no write was left
+// behind and every plugin closed
+func (r *ShutdownReport) Clean() bool {
+	return r.Aborted == 0 && sum(r.PendingAsyncWrites) == 0 && sum(r.QueuedWindowWrites) == 0 && len(r.CloseErrors) == 0
+}
+
+// sum returns the sum of the counts
+func sum(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// ShutdownWithReport shuts the gateway down like Shutdown, and returns what
+// the shutdown left behind along with its error. The report is logged as
+// "gateway shut down", at warning level if work was aborted or left queued
+// and at error level if the shutdown failed.
+func (g *Gateway) ShutdownWithReport(ctx context.Context) (*ShutdownReport, error) {
+	start := g.clock.Now()
+	report := &ShutdownReport{
+		InFlight:           int(g.inFlight.Load()),
+		PendingAsyncWrites: make(map[string]int),
+		QueuedWindowWrites: make(map[string]int),
+		CloseErrors:        make(map[string]error),
+	}
+
+	g.mu.RLock()
+	server := g.httpServer
+	stopWorkers := g.stopWorkers
+	g.mu.RUnlock()
+
+	if stopWorkers != nil {
+		stopWorkers()
+	}
+
+	var errs []error
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop server: %w", err))
+		}
+	}
+	// Requests served by the server of an embedding application are not
+	// waited for, so those still running are reported as aborted
+	report.Aborted = min(int(g.inFlight.Load()), report.InFlight)
+	report.Drained = report.InFlight - report.Aborted
+
+	g.countQueuedWrites(ctx, report)
+
+	results := g.pluginManager.CloseEach()
+	for _, name := range slices.Sorted(maps.Keys(results)) {
+		if err := results[name]; err != nil {
+			report.CloseErrors[name] = err
+			errs = append(errs, fmt.Errorf("failed to close plugin %s: %w", name, err))
+		} else {
+			report.ClosedPlugins = append(report.ClosedPlugins, name)
+		}
+	}
+	report.Duration = g.clock.Now().Sub(start)
+
+	err := errors.Join(errs...)
+	g.logShutdown(report, err)
+	return report, err
+}
+
+// countQueuedWrites adds the writes the plugins have yet to apply to report
+func (g *Gateway) countQueuedWrites(ctx context.Context, report *ShutdownReport) {
+	for _, name := range g.pluginManager.List() {
+		if queue, ok := g.pluginManager.GetAsyncQueue(name); ok {
+			ops, err := queue.List(context.WithoutCancel(ctx))
+			if err != nil {
+				g.logger.Warn("failed to count pending asynchronous writes", "plugin", name, "error", err)
+			}
+			for _, op := range ops {
+				if op.Status == plugin.OperationPending {
+					report.PendingAsyncWrites[name]++
+				}
+			}
+		}
+		if window, ok := g.pluginManager.GetWriteWindow(name); ok {
+			if n := len(window.Queued()); n > 0 {
+				report.QueuedWindowWrites[name] = n
+			}
+		}
+	}
+}
+
+// logShutdown logs report, and err if the shutdown failed
+func (g *Gateway) logShutdown(report *ShutdownReport, err error) {
+	level := slog.LevelInfo
+	if !report.Clean() {
+		level = slog.LevelWarn
+	}
+	attrs := []any{
+		"duration_ms", report.Duration.Milliseconds(),
+		"in_flight", report.InFlight,
+		"drained", report.Drained,
+		"aborted", report.Aborted,
+		"pending_async_writes", sum(report.PendingAsyncWrites),
+		"queued_window_writes", sum(report.QueuedWindowWrites),
+		"plugins_closed", report.ClosedPlugins,
+		"clean", report.Clean(),
+	}
+	for _, name := range slices.Sorted(maps.Keys(report.PendingAsyncWrites)) {
+		attrs = append(attrs, slog.Int("pending_async_writes."+name, report.PendingAsyncWrites[name]))
+	}
+	for _, name := range slices.Sorted(maps.Keys(report.QueuedWindowWrites)) {
+		attrs = append(attrs, slog.Int("queued_window_writes."+name, report.QueuedWindowWrites[name]))
+	}
+	for _, name := range slices.Sorted(maps.Keys(report.CloseErrors)) {
+		attrs = append(attrs, slog.String("close_error."+name, report.CloseErrors[name].Error()))
+	}
+	if err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, "error", err)
+	}
+	g.logger.Log(context.Background(), level, "gateway shut down", attrs...)
+}
@@ -0,0 +1,128 @@
+package scimgateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// stallingPlugin holds list requests until release is closed, and fails to
+// close
+type stallingPlugin struct {
+	*testutil.MemoryPlugin
+	release chan struct{}
+}
+
+func (p *stallingPlugin) GetUsers(ctx context.Context, params scim.QueryParams) ([]*scim.User, error) {
+	<-p.release
+	return p.MemoryPlugin.GetUsers(ctx, params)
+}
+
+func (p *stallingPlugin) Close() error {
+	return errors.New("connection reset")
+}
+
+func TestShutdownReport(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].AsyncWrites = &config.AsyncWritesConfig{}
+	gw := New(cfg)
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	p := &stallingPlugin{MemoryPlugin: testutil.NewMemoryPlugin("test"), release: make(chan struct{})}
+	gw.RegisterPlugin(p)
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("POST", "/test/Users", `{"userName": "alice"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		do("GET", "/test/Users", "")
+	}()
+	for gw.inFlight.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	report, err := gw.ShutdownWithReport(context.Background())
+	close(p.release)
+	<-done
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("ShutdownWithReport() error = %v, want the close error", err)
+	}
+	if report.InFlight != 1 || report.Aborted != 1 || report.Drained != 0 {
+		t.Errorf("requests = %d in flight, %d drained, %d aborted, want 1 aborted", report.InFlight, report.Drained, report.Aborted)
+	}
+	if report.PendingAsyncWrites["test"] != 1 {
+		t.Errorf("PendingAsyncWrites = %v, want 1 for test", report.PendingAsyncWrites)
+	}
+	if report.CloseErrors["test"] == nil || len(report.ClosedPlugins) != 0 {
+		t.Errorf("ClosedPlugins = %v, CloseErrors = %v, want test failing", report.ClosedPlugins, report.CloseErrors)
+	}
+	if report.Clean() {
+		t.Error("Clean() = true, want false")
+	}
+	for _, want := range []string{`"level":"ERROR","msg":"gateway shut down"`, `"aborted":1`, `"pending_async_writes.test":1`, `"close_error.test":"connection reset"`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %s: %s", want, logs.String())
+		}
+	}
+}
+
+func TestShutdownReportClean(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+	gw.RegisterPlugin(&lifecyclePlugin{MemoryPlugin: testutil.NewMemoryPlugin("test")})
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	report, err := gw.ShutdownWithReport(context.Background())
+	if err != nil || !report.Clean() || len(report.ClosedPlugins) != 1 {
+		t.Errorf("ShutdownWithReport() = %+v, %v, want a clean shutdown closing test", report, err)
+	}
+	if !strings.Contains(logs.String(), `"level":"INFO","msg":"gateway shut down"`) || !strings.Contains(logs.String(), `"clean":true`) {
+		t.Errorf("logs do not report a clean shutdown: %s", logs.String())
+	}
+}
+
+func TestLogShutdownSortsPlugins(t *testing.T) {
+	gw := New(bearerConfig("token"))
+	var logs bytes.Buffer
+	gw.SetLogger(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	gw.logShutdown(&ShutdownReport{
+		PendingAsyncWrites: map[string]int{"c": 1, "a": 2, "b": 3},
+		CloseErrors:        map[string]error{"z": errors.New("reset"), "y": errors.New("timeout")},
+	}, nil)
+
+	got := logs.String()
+	order := []string{`"pending_async_writes.a"`, `"pending_async_writes.b"`, `"pending_async_writes.c"`, `"close_error.y"`, `"close_error.z"`}
+	for i := 1; i < len(order); i++ {
+		if strings.Index(got, order[i-1]) > strings.Index(got, order[i]) {
+			t.Errorf("%s logged after %s: %s", order[i-1], order[i], got)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package sqlutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL dialect a QueryBuilder writes
+type Dialect int
+
+const (
+	// Postgres reads JSONB attributes with the -> and ->> operators
+	Postgres Dialect = iota
+
+	// MySQL reads JSON attributes with JSON_EXTRACT
+	MySQL
+
+	// SQLite reads JSON attributes with json_extract. Booleans are compared
+	// as 1 and 0, as json_extract returns them.
+	SQLite
+)
+
+// String returns the name of the dialect
+func (d Dialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case MySQL:
+		return "mysql"
+	case SQLite:
+		return "sqlite"
+	}
+	return "Dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// Placeholders returns the placeholder style the database driver of the
+// dialect expects: Dollar for PostgreSQL, Question otherwise
+func (d Dialect) Placeholders() PlaceholderStyle {
+	if d == Postgres {
+		return Dollar
+	}
+	return Question
+}
+
+// PlaceholderStyle is how query parameters are written
+type PlaceholderStyle int
+
+const (
+	// Question writes every parameter as ?, as MySQL and SQLite expect, and
+	// as sqlx.Rebind converts for other drivers
+	Question PlaceholderStyle = iota
+
+	// Dollar numbers parameters as $1, $2, ..., as PostgreSQL expects
+	Dollar
+)
+
+// placeholder returns the placeholder of the n-th parameter, counting from 1
+func (s PlaceholderStyle) placeholder(n int) string {
+	if s == Dollar {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// jsonText returns the expression reading the attribute at path of a JSON
+// column as text
+func (d Dialect) jsonText(column string, path []string) string {
+	switch d {
+	case MySQL:
+		return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '%s'))", column, jsonPathLiteral(path))
+	case SQLite:
+		return fmt.Sprintf("json_extract(%s, '%s')", column, jsonPathLiteral(path))
+	}
+	var expr strings.Builder
+	expr.WriteString(column)
+	for i, part := range path {
+		if i == len(path)-1 {
+			fmt.Fprintf(&expr, "->>'%s'", part)
+		} else {
+			fmt.Fprintf(&expr, "->'%s'", part)
+		}
+	}
+	return expr.String()
+}
+
+// jsonPresent returns the condition of the attribute at path of a JSON
+// column having a value. As in the gateway's in-memory evaluator, missing
+// keys, null, "", [] and {} are absent.
+func (d Dialect) jsonPresent(column string, path []string) string {
+	switch d {
+	case MySQL:
+		value := fmt.Sprintf("JSON_EXTRACT(%s, '%s')", column, jsonPathLiteral(path))
+		return fmt.Sprintf("COALESCE(JSON_TYPE(%s) <> 'NULL' AND JSON_LENGTH(%s) > 0 AND %s <> '', FALSE)",
+			value, value, d.jsonText(column, path))
+	case SQLite:
+		// json_extract returns NULL for null and arrays and objects as JSON text
+		return fmt.Sprintf("COALESCE(%s NOT IN ('', '[]', '{}'), 0)", d.jsonText(column, path))
+	}
+	var value strings.Builder
+	value.WriteString(column)
+	for _, part := range path {
+		fmt.Fprintf(&value, "->'%s'", part)
+	}
+	return fmt.Sprintf(`COALESCE(%s NOT IN ('null', '""', '[]', '{}'), false)`, value.String())
+}
+
+// jsonPathLiteral returns the MySQL and SQLite JSON path of path segments,
+// quoting each so schema URNs can be used as keys
+func jsonPathLiteral(path []string) string {
+	var literal strings.Builder
+	literal.WriteString("$")
+	for _, part := range path {
+		fmt.Fprintf(&literal, `."%s"`, part)
+	}
+	return literal.String()
+}
+
+// numeric returns expr converted to a number for comparisons
+func (d Dialect) numeric(expr string) string {
+	switch d {
+	case MySQL:
+		return fmt.Sprintf("CAST(%s AS DECIMAL(65,10))", expr)
+	case SQLite:
+		return fmt.Sprintf("CAST(%s AS REAL)", expr)
+	}
+	return fmt.Sprintf("(%s)::numeric", expr)
+}
+
+// boolean returns the parameter a boolean attribute read as text is compared to
+func (d Dialect) boolean(b bool) any {
+	if d == SQLite {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	return strconv.FormatBool(b)
+}
+
+// like returns the condition of expr matching a LIKE pattern escaped with
+// escapeLikePattern. SQLite has no default escape character.
+func (d Dialect) like(expr, pattern string) string {
+	if d == SQLite {
+		return fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, expr, pattern)
+	}
+	return fmt.Sprintf("%s LIKE %s", expr, pattern)
+}
+
+// defaultOrder is the ORDER BY clause of unsorted queries. MySQL and SQLite
+// break ties by id, so pages neither overlap nor skip rows.
+func (d Dialect) defaultOrder() string {
+	if d == Postgres {
+		return "ORDER BY created_at ASC"
+	}
+	return "ORDER BY created_at ASC, id ASC"
+}
+
+// orderBy returns the ORDER BY clause sorting by the keys of expr in
+// direction, with rows missing expr last as in the gateway's in-memory sort
+func (d Dialect) orderBy(expr string, keys []string, direction string) string {
+	if d == Postgres {
+		terms := make([]string, len(keys))
+		for i, key := range keys {
+			terms[i] = key + " " + direction
+		}
+		terms[0] += " NULLS LAST"
+		return "ORDER BY " + strings.Join(terms, ", ")
+	}
+
+	// MySQL and SQLite sort NULL first
+	terms := []string{expr + " IS NULL"}
+	for _, key := range keys {
+		terms = append(terms, key+" "+direction)
+	}
+	terms = append(terms, "id ASC")
+	return "ORDER BY " + strings.Join(terms, ", ")
+}
+
+// pagination returns the LIMIT and OFFSET clause of a page, or "" for all
+// rows. A count of 0 has no limit.
+func (d Dialect) pagination(offset, count int) string {
+	switch {
+	case count > 0 && offset > 0:
+		return fmt.Sprintf("LIMIT %d OFFSET %d", count, offset)
+	case count > 0:
+		return fmt.Sprintf("LIMIT %d", count)
+	case offset > 0:
+		switch d {
+		case MySQL:
+			// MySQL has no OFFSET without LIMIT; use the largest row count
+			return fmt.Sprintf("LIMIT 18446744073709551615 OFFSET %d", offset)
+		case SQLite:
+			return fmt.Sprintf("LIMIT -1 OFFSET %d", offset)
+		}
+		return fmt.Sprintf("OFFSET %d", offset)
+	}
+	return ""
+}
@@ -0,0 +1,605 @@
+// Package sqlutil translates SCIM list queries to SQL for plugins that store
+// resources as JSON documents in a relational database, and keeps the claims
+// of unique values in one (see UniquenessStore).
+package sqlutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/marcelom97/scimgateway/filter"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimcontext"
+)
+
+// QueryBuilder constructs SQL queries from SCIM QueryParams for tables holding
+// one resource per row, with mapped attributes in columns and the rest in a
+// JSON data column. Filters, sorting and pagination run in the database.
+//
+// Filters and sort orders that cannot be expressed in SQL, such as value paths
+// (emails[type eq "work"]) or multi-valued attributes, are left out of the
+// query. Translated reports whether that happened, so callers can apply the
+// query in memory instead.
+type QueryBuilder struct {
+	dialect      Dialect
+	placeholders PlaceholderStyle
+	table        string
+	dataColumn   string
+	nameColumn   string
+	params       []any
+	attrMapping  map[string]string // Maps lowercase SCIM attributes to database columns
+	extLayout    ExtensionLayout   // How schema extension attributes are stored in the JSON column
+	scopes       []scopeCondition  // Column equality conditions applied to every query
+	conditions   []string          // Static SQL conditions applied to every query
+	columns      []string          // Additional columns selected by Build
+	untranslated bool              // Set when part of the filter or sort order was left out
+	traceCtx     context.Context   // Context receiving the filter translation decisions
+	foldAccents  bool              // Leave string comparisons to the in-memory filter (QueryParams.AccentInsensitive)
+	err          error             // First invalid filter met by Build or BuildCount
+}
+
+// scopeCondition restricts a query to rows where column equals value
+type scopeCondition struct {
+	column string
+	value  any
+}
+
+// ExtensionLayout describes where schema extension attributes live in the stored JSON
+type ExtensionLayout int
+
+const (
+	// ExtensionNested stores extension attributes under their schema URN key,
+	// matching the JSON representation of scim.User:
+	//   {"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "X"}}
+	ExtensionNested ExtensionLayout = iota
+
+	// ExtensionFlat stores extension attributes at the top level of the document:
+	//   {"department": "X"}
+	ExtensionFlat
+)
+
+// multiValuedAttributes are the core attributes holding JSON arrays. A path
+// into them matches any element in SCIM but nothing in a JSON path
+// expression, so filters and sort orders on them are not translated.
+var multiValuedAttributes = map[string]bool{
+	"emails":           true,
+	"phonenumbers":     true,
+	"ims":              true,
+	"photos":           true,
+	"addresses":        true,
+	"groups":           true,
+	"entitlements":     true,
+	"roles":            true,
+	"x509certificates": true,
+	"members":          true,
+	"schemas":          true,
+}
+
+// UserAttributeMapping maps the SCIM user attributes kept in their own
+// columns by the bundled SQL plugins
+var UserAttributeMapping = map[string]string{
+	"id":       "id",
+	"username": "username",
+}
+
+// GroupAttributeMapping maps the SCIM group attributes kept in their own
+// columns by the bundled SQL plugins
+var GroupAttributeMapping = map[string]string{
+	"id":          "id",
+	"displayname": "display_name",
+}
+
+// NewQueryBuilder creates a query builder for table in dialect. Attributes
+// found in attrMapping, by their lowercase SCIM path, are read from the mapped
+// column; all others from the JSON document in dataColumn. Parameters are
+// written in the placeholder style of the dialect.
+func NewQueryBuilder(dialect Dialect, table string, dataColumn string, attrMapping map[string]string) *QueryBuilder {
+	nameColumn := "display_name"
+	if table == "users" {
+		nameColumn = "username"
+	}
+	mapping := make(map[string]string, len(attrMapping))
+	for attr, column := range attrMapping {
+		mapping[strings.ToLower(attr)] = column
+	}
+	return &QueryBuilder{
+		dialect:      dialect,
+		placeholders: dialect.Placeholders(),
+		table:        table,
+		dataColumn:   dataColumn,
+		nameColumn:   nameColumn,
+		params:       make([]any, 0),
+		attrMapping:  mapping,
+	}
+}
+
+// WithPlaceholders sets how parameters are written, such as Question for
+// queries passed to sqlx.Rebind
+func (qb *QueryBuilder) WithPlaceholders(style PlaceholderStyle) *QueryBuilder {
+	qb.placeholders = style
+	return qb
+}
+
+// WithNameColumn sets the column selected by Build after id. It defaults to
+// username for the users table and display_name otherwise.
+func (qb *QueryBuilder) WithNameColumn(column string) *QueryBuilder {
+	qb.nameColumn = column
+	return qb
+}
+
+// WithExtensionLayout sets how schema extension attributes are stored in the JSON column.
+// The default is ExtensionNested.
+func (qb *QueryBuilder) WithExtensionLayout(layout ExtensionLayout) *QueryBuilder {
+	qb.extLayout = layout
+	return qb
+}
+
+// WithScope restricts every query built to rows where column equals value, in
+// addition to any SCIM filter. It is used to scope queries to a tenant.
+func (qb *QueryBuilder) WithScope(column string, value any) *QueryBuilder {
+	qb.scopes = append(qb.scopes, scopeCondition{column: column, value: value})
+	return qb
+}
+
+// WithCondition restricts every query built to rows matching a static SQL
+// condition without parameters, such as "deleted_at IS NULL"
+func (qb *QueryBuilder) WithCondition(condition string) *QueryBuilder {
+	qb.conditions = append(qb.conditions, condition)
+	return qb
+}
+
+// WithColumns adds columns to those selected by Build, after updated_at
+func (qb *QueryBuilder) WithColumns(columns ...string) *QueryBuilder {
+	qb.columns = append(qb.columns, columns...)
+	return qb
+}
+
+// WithFilterTrace reports how the SCIM filter is translated to SQL, and the
+// parts of it left out, to the filter tracer of ctx (see
+// scimcontext.TraceFilter)
+func (qb *QueryBuilder) WithFilterTrace(ctx context.Context) *QueryBuilder {
+	qb.traceCtx = ctx
+	return qb
+}
+
+// traceFilter reports a filter translation decision if WithFilterTrace was set
+func (qb *QueryBuilder) traceFilter(format string, args ...any) {
+	if qb.traceCtx != nil {
+		scimcontext.TraceFilter(qb.traceCtx, format, args...)
+	}
+}
+
+// Translated reports whether the queries built so far hold the whole filter
+// and sort order. If not, their results are a superset of the matches in an
+// arbitrary order and must be filtered and sorted in memory.
+func (qb *QueryBuilder) Translated() bool {
+	return !qb.untranslated
+}
+
+// Err returns an *InvalidFilterError if a query was built for a filter that
+// cannot be parsed. The filter is left out of the query, so running it
+// anyway returns every row of the scope.
+func (qb *QueryBuilder) Err() error {
+	return qb.err
+}
+
+// nextParam records value and returns its placeholder
+func (qb *QueryBuilder) nextParam(value any) string {
+	qb.params = append(qb.params, value)
+	return qb.placeholders.placeholder(len(qb.params))
+}
+
+// Build constructs the full SELECT query with WHERE, ORDER BY, LIMIT, and OFFSET clauses
+func (qb *QueryBuilder) Build(params scim.QueryParams) (string, []any) {
+	var query strings.Builder
+
+	// Base SELECT
+	fmt.Fprintf(&query, "SELECT id, %s, %s, created_at, updated_at", qb.nameColumn, qb.dataColumn)
+	for _, column := range qb.columns {
+		query.WriteString(", ")
+		query.WriteString(column)
+	}
+	fmt.Fprintf(&query, " FROM %s", qb.table)
+
+	// WHERE clause from scopes and filter
+	qb.foldAccents = params.AccentInsensitive
+	whereClause := qb.buildScopedWhereClause(params.Filter)
+	if whereClause != "" {
+		query.WriteString(" WHERE ")
+		query.WriteString(whereClause)
+	}
+
+	// ORDER BY clause
+	query.WriteString(" ")
+	query.WriteString(qb.buildOrderClause(params.SortBy, params.SortOrder, params.Collation))
+
+	// LIMIT and OFFSET for pagination; SCIM uses 1-based indexing
+	offset := 0
+	if params.StartIndex > 1 {
+		offset = params.StartIndex - 1
+	}
+	if pagination := qb.dialect.pagination(offset, params.Count); pagination != "" {
+		query.WriteString(" ")
+		query.WriteString(pagination)
+	}
+
+	return query.String(), qb.params
+}
+
+// BuildCount constructs a COUNT query for total results
+func (qb *QueryBuilder) BuildCount(params scim.QueryParams) (string, []any) {
+	var query strings.Builder
+
+	fmt.Fprintf(&query, "SELECT COUNT(*) FROM %s", qb.table)
+
+	// WHERE clause from scopes and filter
+	qb.foldAccents = params.AccentInsensitive
+	whereClause := qb.buildScopedWhereClause(params.Filter)
+	if whereClause != "" {
+		query.WriteString(" WHERE ")
+		query.WriteString(whereClause)
+	}
+
+	return query.String(), qb.params
+}
+
+// buildScopedWhereClause combines the scope and static conditions with the SCIM
+// filter. Scope parameters are added first so they precede the filter's parameters.
+func (qb *QueryBuilder) buildScopedWhereClause(filter string) string {
+	conditions := make([]string, 0, len(qb.scopes)+len(qb.conditions))
+	for _, scope := range qb.scopes {
+		conditions = append(conditions, fmt.Sprintf("%s = %s", scope.column, qb.nextParam(scope.value)))
+	}
+	conditions = append(conditions, qb.conditions...)
+
+	whereClause := qb.buildWhereClause(filter)
+	if whereClause == "" {
+		return strings.Join(conditions, " AND ")
+	}
+	if len(conditions) == 0 {
+		return whereClause
+	}
+	return strings.Join(conditions, " AND ") + " AND (" + whereClause + ")"
+}
+
+// buildWhereClause converts a SCIM filter string to a WHERE clause. It
+// returns "" and marks the builder untranslated if any part of the filter
+// cannot be expressed in SQL.
+func (qb *QueryBuilder) buildWhereClause(filterStr string) string {
+	if filterStr == "" {
+		return ""
+	}
+
+	parsedFilter, err := filter.Parse(filterStr)
+	if err != nil || parsedFilter == nil {
+		// Leave invalid filters out; callers report Err or filter in memory
+		qb.traceFilter("invalid filter not translated to SQL: %v", err)
+		qb.untranslated = true
+		if err != nil && qb.err == nil {
+			qb.err = &InvalidFilterError{Filter: filterStr, Err: err}
+		}
+		return ""
+	}
+
+	// Parameters of a partly translated filter must not leak into the query
+	mark := len(qb.params)
+	where := qb.filterToSQL(parsedFilter)
+	if where == "" {
+		qb.params = qb.params[:mark]
+		qb.untranslated = true
+		qb.traceFilter("filter not translated to SQL")
+	} else {
+		qb.traceFilter("translated to SQL: %s", where)
+	}
+	return where
+}
+
+// filterToSQL converts a parsed SCIM filter to SQL WHERE clause
+func (qb *QueryBuilder) filterToSQL(expr filter.Expr) string {
+	switch e := expr.(type) {
+	case *filter.AttributeExpr:
+		return qb.attributeExpressionToSQL(e)
+	case *filter.LogicalExpr:
+		return qb.logicalExpressionToSQL(e)
+	case *filter.NotExpr:
+		inner := qb.filterToSQL(e.Expr)
+		if inner == "" {
+			qb.traceFilter("dropped not: its operand cannot be translated to SQL")
+			return ""
+		}
+		return fmt.Sprintf("NOT (%s)", inner)
+	case *filter.GroupExpr:
+		inner := qb.filterToSQL(e.Expr)
+		if inner == "" {
+			return ""
+		}
+		return "(" + inner + ")"
+	}
+	return ""
+}
+
+// attributeExpressionToSQL converts a single attribute expression to SQL
+func (qb *QueryBuilder) attributeExpressionToSQL(expr *filter.AttributeExpr) string {
+	clause := qb.attributeClause(expr)
+	if clause == "" {
+		qb.traceFilter("dropped %s: cannot translate to SQL", describeExpression(expr))
+	}
+	return clause
+}
+
+// attributeClause builds the SQL condition of an attribute expression, or
+// returns "" if it cannot be translated
+func (qb *QueryBuilder) attributeClause(expr *filter.AttributeExpr) string {
+	attr, ok := qb.resolve(expr.Path.String())
+	if !ok {
+		return ""
+	}
+	if attr.multiValued {
+		if expr.Operator == filter.Pr {
+			return qb.buildPresentClause(attr)
+		}
+		return ""
+	}
+
+	// SQL cannot strip accents like scim.FoldAccents, so accent-insensitive
+	// string comparisons are only evaluated in memory
+	if _, isString := expr.Value.(string); isString && qb.foldAccents {
+		switch expr.Operator {
+		case filter.Eq, filter.Ne, filter.Co, filter.Sw, filter.Ew:
+			return ""
+		}
+	}
+
+	sqlPath := qb.text(attr)
+	switch expr.Operator {
+	case filter.Eq:
+		return qb.buildEqualityClause(sqlPath, expr.Value, true)
+	case filter.Ne:
+		return qb.buildEqualityClause(sqlPath, expr.Value, false)
+	case filter.Co:
+		return qb.buildLikeClause(sqlPath, expr.Value, "%", "%")
+	case filter.Sw:
+		return qb.buildLikeClause(sqlPath, expr.Value, "", "%")
+	case filter.Ew:
+		return qb.buildLikeClause(sqlPath, expr.Value, "%", "")
+	case filter.Pr:
+		return qb.buildPresentClause(attr)
+	case filter.Gt:
+		return qb.buildComparisonClause(sqlPath, expr.Value, ">")
+	case filter.Ge:
+		return qb.buildComparisonClause(sqlPath, expr.Value, ">=")
+	case filter.Lt:
+		return qb.buildComparisonClause(sqlPath, expr.Value, "<")
+	case filter.Le:
+		return qb.buildComparisonClause(sqlPath, expr.Value, "<=")
+	}
+	return ""
+}
+
+// logicalExpressionToSQL converts a logical expression (AND, OR) to SQL
+func (qb *QueryBuilder) logicalExpressionToSQL(expr *filter.LogicalExpr) string {
+	switch expr.Operator {
+	case filter.And:
+		left := qb.filterToSQL(expr.Left)
+		right := qb.filterToSQL(expr.Right)
+		if left == "" || right == "" {
+			qb.traceFilter("dropped and: an operand cannot be translated to SQL")
+			return ""
+		}
+		return fmt.Sprintf("(%s AND %s)", left, right)
+	case filter.Or:
+		left := qb.filterToSQL(expr.Left)
+		right := qb.filterToSQL(expr.Right)
+		if left == "" || right == "" {
+			qb.traceFilter("dropped or: an operand cannot be translated to SQL")
+			return ""
+		}
+		return fmt.Sprintf("(%s OR %s)", left, right)
+	}
+	return ""
+}
+
+// attribute is where an attribute is stored: a mapped column, or a path in
+// the JSON data column
+type attribute struct {
+	column      string
+	path        []string
+	multiValued bool // A multi-valued attribute as a whole, only checked by pr
+}
+
+// resolve returns where the SCIM attribute path is stored, and false for
+// paths that cannot be expressed in SQL
+func (qb *QueryBuilder) resolve(attrPath string) (attribute, bool) {
+	// Resolve schema URN prefixes (e.g. "urn:...:enterprise:2.0:User:department")
+	if urn, relPath := scim.SplitSchemaURN(attrPath); urn != "" && relPath != "" {
+		// Core schema attributes and flat extensions live at the top level
+		if urn == scim.SchemaUser || urn == scim.SchemaGroup || qb.extLayout == ExtensionFlat {
+			return qb.resolve(relPath)
+		}
+		return qb.jsonAttribute(append([]string{urn}, strings.Split(relPath, ".")...))
+	}
+
+	if column, ok := qb.attrMapping[strings.ToLower(attrPath)]; ok {
+		return attribute{column: column}, true
+	}
+
+	// Handle nested paths (e.g., "name.givenName")
+	return qb.jsonAttribute(strings.Split(attrPath, "."))
+}
+
+// jsonAttribute returns the attribute at path segments of the data column.
+// Paths into multi-valued attributes and segments that are not plain
+// attribute names, such as value filters, cannot be expressed in SQL. Every
+// segment is checked, since it is quoted into the SQL as a literal.
+func (qb *QueryBuilder) jsonAttribute(parts []string) (attribute, bool) {
+	attr := attribute{column: qb.dataColumn, path: parts}
+	for i, part := range parts {
+		// The first segment of an extension path is its URN, e.g.
+		// urn:ietf:params:scim:schemas:extension:enterprise:2.0:User
+		extra := ""
+		if i == 0 && strings.HasPrefix(part, "urn:") {
+			extra = ":."
+		}
+		if !isJSONKey(part, extra) {
+			return attribute{}, false
+		}
+		if i == 0 && multiValuedAttributes[strings.ToLower(part)] {
+			if len(parts) > 1 {
+				return attribute{}, false
+			}
+			attr.multiValued = true
+		}
+	}
+	return attr, true
+}
+
+// text returns the expression reading attr as text. Multi-valued attributes
+// have no text value; callers check pr on them with buildPresentClause.
+func (qb *QueryBuilder) text(attr attribute) string {
+	if attr.path == nil {
+		return attr.column
+	}
+	return qb.dialect.jsonText(attr.column, attr.path)
+}
+
+// getSQLPath returns the expression reading the SCIM attribute path as text,
+// or "" for paths that cannot be expressed in SQL
+func (qb *QueryBuilder) getSQLPath(attrPath string) string {
+	attr, ok := qb.resolve(attrPath)
+	if !ok || attr.multiValued {
+		return ""
+	}
+	return qb.text(attr)
+}
+
+// isJSONKey reports whether s is non-empty and holds only letters, digits,
+// '_', '-' and the extra characters, so it can be quoted in a JSON path literal
+func isJSONKey(s string, extra string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '_', ch == '-':
+		case strings.ContainsRune(extra, ch):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// buildEqualityClause builds an equality (eq) or inequality (ne) clause
+func (qb *QueryBuilder) buildEqualityClause(sqlPath string, value any, equal bool) string {
+	op := "="
+	if !equal {
+		op = "<>"
+	}
+
+	switch v := value.(type) {
+	case string:
+		// Case-insensitive string comparison, folded like scim.FoldCase
+		param := qb.nextParam(scim.FoldCase(v))
+		return fmt.Sprintf("LOWER(UPPER(%s)) %s %s", sqlPath, op, param)
+	case bool:
+		param := qb.nextParam(qb.dialect.boolean(v))
+		return fmt.Sprintf("%s %s %s", sqlPath, op, param)
+	case int64, float64:
+		param := qb.nextParam(fmt.Sprintf("%v", v))
+		return fmt.Sprintf("%s %s %s", qb.dialect.numeric(sqlPath), op, param)
+	case nil:
+		if equal {
+			return fmt.Sprintf("%s IS NULL", sqlPath)
+		}
+		return fmt.Sprintf("%s IS NOT NULL", sqlPath)
+	default:
+		param := qb.nextParam(fmt.Sprintf("%v", v))
+		return fmt.Sprintf("%s %s %s", sqlPath, op, param)
+	}
+}
+
+// buildLikeClause builds the case-insensitive LIKE clause of the co, sw and
+// ew operators, wrapping the escaped value in prefix and suffix
+func (qb *QueryBuilder) buildLikeClause(sqlPath string, value any, prefix, suffix string) string {
+	strVal, ok := value.(string)
+	if !ok {
+		return ""
+	}
+	param := qb.nextParam(prefix + scim.FoldCase(escapeLikePattern(strVal)) + suffix)
+	return qb.dialect.like(fmt.Sprintf("LOWER(UPPER(%s))", sqlPath), param)
+}
+
+// buildPresentClause builds the clause of the "pr" operator. As in the
+// gateway's in-memory evaluator, missing keys, null, "", [] and {} are absent.
+func (qb *QueryBuilder) buildPresentClause(attr attribute) string {
+	if attr.path == nil {
+		return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", attr.column, attr.column)
+	}
+	return qb.dialect.jsonPresent(attr.column, attr.path)
+}
+
+// buildComparisonClause builds a numeric comparison clause
+func (qb *QueryBuilder) buildComparisonClause(sqlPath string, value any, op string) string {
+	param := qb.nextParam(fmt.Sprintf("%v", value))
+	return fmt.Sprintf("%s %s %s", qb.dialect.numeric(sqlPath), op, param)
+}
+
+// buildOrderClause constructs the ORDER BY clause. Strings are folded first
+// with scim.CollationCaseInsensitive. Sort orders that cannot be expressed in
+// SQL mark the builder untranslated and fall back to the default order.
+func (qb *QueryBuilder) buildOrderClause(sortBy, sortOrder, collation string) string {
+	if sortBy == "" {
+		return qb.dialect.defaultOrder()
+	}
+
+	sqlPath := qb.getSQLPath(sortBy)
+	if sqlPath == "" {
+		qb.untranslated = true
+		return qb.dialect.defaultOrder()
+	}
+
+	direction := "ASC"
+	if strings.EqualFold(sortOrder, "descending") {
+		direction = "DESC"
+	}
+
+	keys := []string{sqlPath}
+	if collation == scim.CollationCaseInsensitive {
+		keys = []string{fmt.Sprintf("LOWER(UPPER(%s))", sqlPath), sqlPath}
+	}
+	return qb.dialect.orderBy(sqlPath, keys, direction)
+}
+
+// InvalidFilterError reports a SCIM filter that cannot be parsed
+type InvalidFilterError struct {
+	Filter string
+	Err    error
+}
+
+func (e *InvalidFilterError) Error() string {
+	return fmt.Sprintf("invalid filter %q: %v", e.Filter, e.Err)
+}
+
+func (e *InvalidFilterError) Unwrap() error {
+	return e.Err
+}
+
+// describeExpression formats an attribute expression for filter traces
+func describeExpression(expr *filter.AttributeExpr) string {
+	if expr.Operator == filter.Pr {
+		return expr.Path.String() + " pr"
+	}
+	if s, ok := expr.Value.(string); ok {
+		return fmt.Sprintf("%s %s %q", expr.Path, expr.Operator, s)
+	}
+	return fmt.Sprintf("%s %s %v", expr.Path, expr.Operator, expr.Value)
+}
+
+// escapeLikePattern escapes special characters in LIKE patterns
+func escapeLikePattern(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "%", "\\%")
+	s = strings.ReplaceAll(s, "_", "\\_")
+	return s
+}
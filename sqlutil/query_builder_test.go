@@ -0,0 +1,162 @@
+package sqlutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestQueryBuilder_Dialects(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  Dialect
+		params   scim.QueryParams
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "postgres numbers parameters",
+			dialect:  Postgres,
+			params:   scim.QueryParams{Filter: `userName eq "John" and active eq true`, Count: 10},
+			wantSQL:  "SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = $1 AND ((LOWER(UPPER(username)) = $2 AND data->>'active' = $3)) ORDER BY created_at ASC LIMIT 10",
+			wantArgs: []any{"tenant", "john", "true"},
+		},
+		{
+			name:     "mysql extracts JSON",
+			dialect:  MySQL,
+			params:   scim.QueryParams{Filter: `name.familyName sw "Do"`, SortBy: "name.givenName"},
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND (LOWER(UPPER(JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."familyName"')))) LIKE ?) ORDER BY JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."givenName"')) IS NULL, JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."givenName"')) ASC, id ASC`,
+			wantArgs: []any{"tenant", "do%"},
+		},
+		{
+			name:     "sqlite compares booleans as integers",
+			dialect:  SQLite,
+			params:   scim.QueryParams{Filter: `active eq false`, StartIndex: 3},
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND (json_extract(data, '$."active"') = ?) ORDER BY created_at ASC, id ASC LIMIT -1 OFFSET 2`,
+			wantArgs: []any{"tenant", 0},
+		},
+		{
+			name:     "sqlite escapes LIKE patterns",
+			dialect:  SQLite,
+			params:   scim.QueryParams{Filter: `userName co "50%"`},
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND (LOWER(UPPER(username)) LIKE ? ESCAPE '\') ORDER BY created_at ASC, id ASC`,
+			wantArgs: []any{"tenant", `%50\%%`},
+		},
+		{
+			name:     "sqlite pr on a multi-valued attribute",
+			dialect:  SQLite,
+			params:   scim.QueryParams{Filter: `emails pr`},
+			wantSQL:  `SELECT id, username, data, created_at, updated_at FROM users WHERE base_entity = ? AND (COALESCE(json_extract(data, '$."emails"') NOT IN ('', '[]', '{}'), 0)) ORDER BY created_at ASC, id ASC`,
+			wantArgs: []any{"tenant"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qb := NewQueryBuilder(tt.dialect, "users", "data", UserAttributeMapping).WithScope("base_entity", "tenant")
+			gotSQL, gotArgs := qb.Build(tt.params)
+
+			if gotSQL != tt.wantSQL {
+				t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("Build() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+			if !qb.Translated() {
+				t.Error("Translated() = false, want true")
+			}
+		})
+	}
+}
+
+func TestQueryBuilder_Mapping(t *testing.T) {
+	qb := NewQueryBuilder(Postgres, "accounts", "doc", map[string]string{"userName": "login", "externalId": "external_id"}).
+		WithNameColumn("login").
+		WithPlaceholders(Question)
+	gotSQL, gotArgs := qb.Build(scim.QueryParams{Filter: `externalId eq "e-1" or title pr`})
+
+	want := `SELECT id, login, doc, created_at, updated_at FROM accounts WHERE (LOWER(UPPER(external_id)) = ? OR COALESCE(doc->'title' NOT IN ('null', '""', '[]', '{}'), false)) ORDER BY created_at ASC`
+	if gotSQL != want {
+		t.Errorf("Build() SQL =\n%v\nwant:\n%v", gotSQL, want)
+	}
+	if want := []any{"e-1"}; !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("Build() args = %v, want %v", gotArgs, want)
+	}
+}
+
+func TestQueryBuilder_SortByInjection(t *testing.T) {
+	for _, dialect := range []Dialect{Postgres, MySQL, SQLite} {
+		qb := NewQueryBuilder(dialect, "users", "data", UserAttributeMapping)
+		gotSQL, _ := qb.Build(scim.QueryParams{SortBy: "urn:x') OR 1=1 --"})
+
+		if strings.Contains(gotSQL, "1=1") || qb.Translated() {
+			t.Errorf("%s: Build() SQL = %s, want the default order and the sort left untranslated", dialect, gotSQL)
+		}
+	}
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"simple", "simple"},
+		{"50%", `50\%`},
+		{"a_b", `a\_b`},
+		{`a\b`, `a\\b`},
+		{`%_\`, `\%\_\\`},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLikePattern(tt.input); got != tt.want {
+			t.Errorf("escapeLikePattern(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestGetSQLPath(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		attrPath string
+		want     string
+	}{
+		// Direct column mappings
+		{Postgres, "id", "id"},
+		{Postgres, "userName", "username"},
+		{MySQL, "USERNAME", "username"},
+
+		// JSON paths
+		{Postgres, "active", "data->>'active'"},
+		{Postgres, "name.givenName", "data->'name'->>'givenName'"},
+		{MySQL, "name.givenName", `JSON_UNQUOTE(JSON_EXTRACT(data, '$."name"."givenName"'))`},
+		{SQLite, "meta.lastModified", `json_extract(data, '$."meta"."lastModified"')`},
+
+		// Schema URN prefixes
+		{Postgres, scim.SchemaUser + ":userName", "username"},
+		{Postgres, scim.SchemaUser + ":name.givenName", "data->'name'->>'givenName'"},
+		{Postgres, scim.SchemaEnterpriseUser + ":department", "data->'" + scim.SchemaEnterpriseUser + "'->>'department'"},
+		{MySQL, scim.SchemaEnterpriseUser + ":manager.value", `JSON_UNQUOTE(JSON_EXTRACT(data, '$."` + scim.SchemaEnterpriseUser + `"."manager"."value"'))`},
+
+		// Paths that cannot be expressed in SQL
+		{Postgres, "emails", ""},
+		{MySQL, "Emails.value", ""},
+		{SQLite, `emails[type eq "work"].value`, ""},
+		{Postgres, "name.given'Name", ""},
+		{MySQL, `name."givenName"`, ""},
+		{Postgres, "urn:x') OR 1=1 --", ""},
+		{MySQL, `urn:x"')) OR 1=1 --`, ""},
+		{SQLite, "name.urn:x') OR 1=1 --", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.String()+"/"+tt.attrPath, func(t *testing.T) {
+			qb := NewQueryBuilder(tt.dialect, "users", "data", UserAttributeMapping)
+			if got := qb.getSQLPath(tt.attrPath); got != tt.want {
+				t.Errorf("getSQLPath(%q) = %q, want %q", tt.attrPath, got, tt.want)
+			}
+		})
+	}
+}
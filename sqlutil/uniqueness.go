@@ -0,0 +1,77 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// UniquenessStore is a uniqueness.Store keeping claims in a table, so
+// gateway instances sharing the database share them. The table needs a
+// unique_key column with a primary key or unique constraint and an owner_id
+// column, e.g.:
+//
+//	CREATE TABLE scim_uniqueness (
+//	    unique_key VARCHAR(512) PRIMARY KEY,
+//	    owner_id   VARCHAR(255) NOT NULL
+//	)
+type UniquenessStore struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+// NewUniquenessStore creates a store of the claims in table of db, written
+// in dialect
+func NewUniquenessStore(db *sql.DB, dialect Dialect, table string) *UniquenessStore {
+	return &UniquenessStore{db: db, dialect: dialect, table: table}
+}
+
+// Claim implements uniqueness.Store. The constraint on unique_key decides
+// which of concurrent claims assigns a key.
+func (s *UniquenessStore) Claim(ctx context.Context, key, owner string) (string, bool, error) {
+	p := s.dialect.Placeholders()
+	var insert string
+	switch s.dialect {
+	case MySQL:
+		insert = "INSERT IGNORE INTO %s (unique_key, owner_id) VALUES (%s, %s)"
+	case SQLite:
+		insert = "INSERT OR IGNORE INTO %s (unique_key, owner_id) VALUES (%s, %s)"
+	default:
+		insert = "INSERT INTO %s (unique_key, owner_id) VALUES (%s, %s) ON CONFLICT (unique_key) DO NOTHING"
+	}
+	insert = fmt.Sprintf(insert, s.table, p.placeholder(1), p.placeholder(2))
+	query := fmt.Sprintf("SELECT owner_id FROM %s WHERE unique_key = %s", s.table, p.placeholder(1))
+
+	for attempt := 1; ; attempt++ {
+		result, err := s.db.ExecContext(ctx, insert, key, owner)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to claim unique value: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n > 0 {
+			return owner, true, nil
+		}
+
+		var holder string
+		err = s.db.QueryRowContext(ctx, query, key).Scan(&holder)
+		// The holder may have released the key since, so it is claimed again
+		if errors.Is(err, sql.ErrNoRows) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read unique value owner: %w", err)
+		}
+		return holder, false, nil
+	}
+}
+
+// Release implements uniqueness.Store
+func (s *UniquenessStore) Release(ctx context.Context, key, owner string) error {
+	p := s.dialect.Placeholders()
+	query := fmt.Sprintf("DELETE FROM %s WHERE unique_key = %s AND owner_id = %s", s.table, p.placeholder(1), p.placeholder(2))
+	if _, err := s.db.ExecContext(ctx, query, key, owner); err != nil {
+		return fmt.Errorf("failed to release unique value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// claimsDB is a database/sql driver holding the unique_key table of a
+// UniquenessStore in a map, recording the statements it runs
+type claimsDB struct {
+	mu         sync.Mutex
+	owners     map[string]string
+	statements []string
+}
+
+func (db *claimsDB) Connect(context.Context) (driver.Conn, error) { return claimsConn{db}, nil }
+func (db *claimsDB) Driver() driver.Driver                        { return nil }
+
+type claimsConn struct{ db *claimsDB }
+
+func (c claimsConn) Prepare(query string) (driver.Stmt, error) { return claimsStmt{c.db, query}, nil }
+func (c claimsConn) Close() error                              { return nil }
+func (c claimsConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type claimsStmt struct {
+	db    *claimsDB
+	query string
+}
+
+func (s claimsStmt) Close() error  { return nil }
+func (s claimsStmt) NumInput() int { return -1 }
+
+func (s claimsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.statements = append(s.db.statements, s.query)
+	key, owner := args[0].(string), args[1].(string)
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		if _, ok := s.db.owners[key]; ok {
+			return driver.RowsAffected(0), nil
+		}
+		s.db.owners[key] = owner
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE"):
+		if s.db.owners[key] == owner {
+			delete(s.db.owners, key)
+			return driver.RowsAffected(1), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s claimsStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.statements = append(s.db.statements, s.query)
+	owner, ok := s.db.owners[args[0].(string)]
+	return &claimsRows{owner: owner, done: !ok}, nil
+}
+
+type claimsRows struct {
+	owner string
+	done  bool
+}
+
+func (r *claimsRows) Columns() []string { return []string{"owner_id"} }
+func (r *claimsRows) Close() error      { return nil }
+
+func (r *claimsRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.owner, true
+	return nil
+}
+
+func TestUniquenessStore(t *testing.T) {
+	ctx := context.Background()
+	claims := &claimsDB{owners: make(map[string]string)}
+	store := NewUniquenessStore(sql.OpenDB(claims), Postgres, "scim_uniqueness")
+
+	if holder, claimed, err := store.Claim(ctx, "hr//User/userName/alice", "1"); err != nil || holder != "1" || !claimed {
+		t.Errorf("Claim() = %q, %v, %v, want 1, true", holder, claimed, err)
+	}
+	if holder, claimed, err := store.Claim(ctx, "hr//User/userName/alice", "2"); err != nil || holder != "1" || claimed {
+		t.Errorf("Claim() of held key = %q, %v, %v, want 1, false", holder, claimed, err)
+	}
+	if err := store.Release(ctx, "hr//User/userName/alice", "2"); err != nil || claims.owners["hr//User/userName/alice"] != "1" {
+		t.Errorf("Release() by another owner = %v, owners = %v, want the key kept", err, claims.owners)
+	}
+	if err := store.Release(ctx, "hr//User/userName/alice", "1"); err != nil || len(claims.owners) != 0 {
+		t.Errorf("Release() = %v, owners = %v, want the key released", err, claims.owners)
+	}
+
+	want := []string{
+		"INSERT INTO scim_uniqueness (unique_key, owner_id) VALUES ($1, $2) ON CONFLICT (unique_key) DO NOTHING",
+		"INSERT INTO scim_uniqueness (unique_key, owner_id) VALUES ($1, $2) ON CONFLICT (unique_key) DO NOTHING",
+		"SELECT owner_id FROM scim_uniqueness WHERE unique_key = $1",
+		"DELETE FROM scim_uniqueness WHERE unique_key = $1 AND owner_id = $2",
+		"DELETE FROM scim_uniqueness WHERE unique_key = $1 AND owner_id = $2",
+	}
+	if strings.Join(claims.statements, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements = %q, want %q", claims.statements, want)
+	}
+
+	claims.statements = nil
+	mysql := NewUniquenessStore(sql.OpenDB(claims), MySQL, "scim_uniqueness")
+	if _, _, err := mysql.Claim(ctx, "hr//Group/externalId/g-1", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claims.statements[0]; got != "INSERT IGNORE INTO scim_uniqueness (unique_key, owner_id) VALUES (?, ?)" {
+		t.Errorf("MySQL statement = %q", got)
+	}
+}
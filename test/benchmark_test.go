@@ -0,0 +1,85 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+	"github.com/marcelom97/scimgateway/scimgen"
+)
+
+// newBenchHandler serves the in-memory plugin seeded with n generated users
+// and a group with 5 to 50 of them per 20 users
+func newBenchHandler(b *testing.B, n int) (http.Handler, *scimgen.Generator) {
+	b.Helper()
+
+	handler := NewComplianceHandler(b, testutil.NewMemoryPlugin("test"))
+	gen := scimgen.New(scimgen.Options{Seed: 1, Enterprise: true, MinMembers: 5, MaxMembers: 50})
+
+	var users []*scim.User
+	for _, user := range gen.Users(n) {
+		var created scim.User
+		if w := serveJSON(handler, http.MethodPost, "/test/Users", user); w.Code != http.StatusCreated {
+			b.Fatalf("create user status = %d, body: %s", w.Code, w.Body.String())
+		} else if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			b.Fatal(err)
+		}
+		users = append(users, &created)
+	}
+	for _, group := range gen.Groups(n/20, users) {
+		if w := serveJSON(handler, http.MethodPost, "/test/Groups", group); w.Code != http.StatusCreated {
+			b.Fatalf("create group status = %d, body: %s", w.Code, w.Body.String())
+		}
+	}
+	return handler, gen
+}
+
+// serveJSON serves a request with the JSON encoding of resource as its body
+func serveJSON(handler http.Handler, method, path string, resource any) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	if resource != nil {
+		json.NewEncoder(&body).Encode(resource) // nolint:errcheck
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func BenchmarkGateway_CreateUser(b *testing.B) {
+	handler, gen := newBenchHandler(b, 0)
+	users := gen.Users(b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if w := serveJSON(handler, http.MethodPost, "/test/Users", users[i]); w.Code != http.StatusCreated {
+			b.Fatalf("status = %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkGateway_FilterUsers_1000(b *testing.B) {
+	handler, _ := newBenchHandler(b, 1000)
+	path := "/test/Users?filter=" + url.QueryEscape(`title eq "Director" and emails[type eq "work"].value ew "example.com"`)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if w := serveJSON(handler, http.MethodGet, path, nil); w.Code != http.StatusOK {
+			b.Fatalf("status = %d", w.Code)
+		}
+	}
+}
+
+func BenchmarkGateway_ListGroups_1000(b *testing.B) {
+	handler, _ := newBenchHandler(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if w := serveJSON(handler, http.MethodGet, "/test/Groups?count=50", nil); w.Code != http.StatusOK {
+			b.Fatalf("status = %d", w.Code)
+		}
+	}
+}
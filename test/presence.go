@@ -0,0 +1,95 @@
+package test
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// PresenceURN is the schema extension holding the attribute of PresenceCases
+const PresenceURN = "urn:example:scim:schemas:extension:presence:1.0:User"
+
+// PresenceFilter is the filter PresenceCases are evaluated with
+const PresenceFilter = PresenceURN + ":value pr"
+
+// PresenceCase is a stored attribute value and whether "pr" matches it
+type PresenceCase struct {
+	Name string
+
+	// Value is the JSON of the attribute; empty means the attribute is missing
+	Value string
+
+	Present bool
+}
+
+// PresenceCases define the "pr" operator for every shape of stored value. An
+// attribute is present unless it is missing, null, an empty string, an empty
+// array or an object without members; its members and elements are not
+// inspected. The in-memory evaluator and the SQL query builders must agree on
+// all of them, so that a filter gives the same result whether a plugin
+// applies it natively or the gateway falls back to applying it in memory.
+var PresenceCases = []PresenceCase{
+	{Name: "missing", Value: "", Present: false},
+	{Name: "null", Value: `null`, Present: false},
+	{Name: "empty string", Value: `""`, Present: false},
+	{Name: "empty array", Value: `[]`, Present: false},
+	{Name: "empty object", Value: `{}`, Present: false},
+	{Name: "string", Value: `"a"`, Present: true},
+	{Name: "blank string", Value: `" "`, Present: true},
+	{Name: "string null", Value: `"null"`, Present: true},
+	{Name: "false", Value: `false`, Present: true},
+	{Name: "zero", Value: `0`, Present: true},
+	{Name: "array", Value: `["a"]`, Present: true},
+	{Name: "array of empty strings", Value: `[""]`, Present: true},
+	{Name: "array of objects", Value: `[{"value": "a"}]`, Present: true},
+	{Name: "object", Value: `{"value": "a"}`, Present: true},
+	{Name: "object with an empty member", Value: `{"value": ""}`, Present: true},
+}
+
+// PresenceUser returns a user named "presence-<case>" storing the value of c
+// under PresenceURN
+func PresenceUser(c PresenceCase) *scim.User {
+	attrs := map[string]any{}
+	if c.Value != "" {
+		var value any
+		if err := json.Unmarshal([]byte(c.Value), &value); err != nil {
+			panic("invalid presence case " + c.Name + ": " + err.Error())
+		}
+		attrs["value"] = value
+	}
+	return &scim.User{
+		Schemas:    []string{scim.SchemaUser, PresenceURN},
+		UserName:   "presence-" + c.Name,
+		Extensions: map[string]map[string]any{PresenceURN: attrs},
+	}
+}
+
+// RunPresenceConformance creates the PresenceUser of every case with create
+// and checks that list returns exactly those of present values for
+// PresenceFilter. It is exported so that plugin modules can check the SQL
+// their query builders generate against real backends.
+func RunPresenceConformance(t *testing.T, create func(*scim.User) error, list func(filter string) ([]*scim.User, error)) {
+	t.Helper()
+
+	for _, c := range PresenceCases {
+		if err := create(PresenceUser(c)); err != nil {
+			t.Fatalf("create user for %q: %v", c.Name, err)
+		}
+	}
+
+	users, err := list(PresenceFilter)
+	if err != nil {
+		t.Fatalf("list %s: %v", PresenceFilter, err)
+	}
+	var listed []string
+	for _, user := range users {
+		listed = append(listed, user.UserName)
+	}
+	for _, c := range PresenceCases {
+		if got := slices.Contains(listed, "presence-"+c.Name); got != c.Present {
+			t.Errorf("%s: %s matched = %v, want %v", c.Name, PresenceFilter, got, c.Present)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// TestPresenceConformance checks the in-memory evaluator, which the gateway
+// falls back to for plugins not filtering natively, against PresenceCases
+func TestPresenceConformance(t *testing.T) {
+	ctx := context.Background()
+	p := testutil.NewMemoryPlugin("test")
+	RunPresenceConformance(t,
+		func(user *scim.User) error {
+			_, err := p.CreateUser(ctx, user)
+			return err
+		},
+		func(filter string) ([]*scim.User, error) {
+			users, err := p.GetUsers(ctx, scim.QueryParams{})
+			if err != nil {
+				return nil, err
+			}
+			resp, err := scim.ProcessListQuery(users, scim.QueryParams{Filter: filter})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Resources, nil
+		})
+
+	// Stored documents decoded without a resource type evaluate the same
+	expr, err := scim.NewFilterParser(PresenceFilter).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range PresenceCases {
+		data, _ := json.Marshal(PresenceUser(c))
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if got := expr.Matches(doc); got != c.Present {
+			t.Errorf("%s: document matched = %v, want %v", c.Name, got, c.Present)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scenario"
+)
+
+// RunScenarios runs the YAML scenarios of the files matching pattern against a
+// gateway handler, each as a subtest named after the scenario. Like
+// RunComplianceSuite it is exported so that plugin modules can run the
+// scenarios of this directory, or their own, against real backends:
+//
+//	test.RunScenarios(t, handler, "testdata/scenarios/*.yaml")
+func RunScenarios(t *testing.T, handler http.Handler, pattern string) {
+	t.Helper()
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("invalid scenario pattern %q: %v", pattern, err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no scenarios match %q", pattern)
+	}
+
+	for _, file := range files {
+		s, err := scenario.Load(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := s.Name
+		if name == "" {
+			name = filepath.Base(file)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			for _, step := range s.Run(handler).Steps {
+				for _, failure := range step.Failures {
+					t.Errorf("%s: step %q: %s", file, step.Name, failure)
+				}
+			}
+		})
+	}
+}
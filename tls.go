@@ -0,0 +1,26 @@
+package scimgateway
+
+import "crypto/tls"
+
+// serverTLSConfig returns the TLS configuration of the gateway server. The
+// certificate is served from certs so Reload can rotate it.
+//
+// While a plugin uses mtls auth, client certificates are requested but not
+// verified during the handshake: each plugin verifies them against its own CA
+// bundle, so one listener can serve plugins trusting different CAs as well as
+// plugins using other auth types. The check runs per connection, so plugins
+// switched to mtls by Reload take effect for new connections.
+func (g *Gateway) serverTLSConfig(certs *certificateStore) *tls.Config {
+	return &tls.Config{
+		GetCertificate: certs.GetCertificate,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			if !g.Config().UsesMTLS() {
+				return nil, nil // use the base configuration
+			}
+			return &tls.Config{
+				GetCertificate: certs.GetCertificate,
+				ClientAuth:     tls.RequestClientCert,
+			}, nil
+		},
+	}
+}
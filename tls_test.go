@@ -0,0 +1,74 @@
+package scimgateway
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/config"
+)
+
+func TestMTLSAuthentication(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeTestCertificate(t, dir, "gateway")
+	clientCert, clientKey := writeTestCertificate(t, dir, "okta")
+	otherCert, otherKey := writeTestCertificate(t, dir, "other")
+
+	cfg := bearerConfig("token")
+	cfg.Gateway.TLS = &config.TLS{Enabled: true, CertFile: serverCert, KeyFile: serverKey}
+	cfg.Plugins[0].Auth = &config.AuthConfig{
+		Type: "mtls",
+		// The self-signed client certificate is its own CA
+		MTLS: &config.MTLSAuth{CAFile: clientCert, AllowedIdentities: []string{"okta"}},
+	}
+	gw, handler := newReloadGateway(t, cfg)
+
+	certs, err := loadCertificateStore(cfg.Gateway.TLS)
+	if err != nil {
+		t.Fatalf("loadCertificateStore() error = %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = gw.serverTLSConfig(certs)
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	get := func(t *testing.T, certFile, keyFile string) int {
+		t.Helper()
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if certFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL + "/test/Users")
+		if err != nil {
+			t.Fatalf("GET error = %v", err)
+		}
+		resp.Body.Close() // nolint:errcheck
+		return resp.StatusCode
+	}
+
+	if code := get(t, clientCert, clientKey); code != http.StatusOK {
+		t.Errorf("trusted client certificate: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(t, "", ""); code != http.StatusUnauthorized {
+		t.Errorf("no client certificate: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := get(t, otherCert, otherKey); code != http.StatusUnauthorized {
+		t.Errorf("untrusted client certificate: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// Without mtls plugins the server does not ask for client certificates
+	bearer := bearerConfig("token")
+	bearer.Gateway.TLS = cfg.Gateway.TLS
+	if err := gw.Reload(bearer); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if tlsConfig, _ := server.TLS.GetConfigForClient(nil); tlsConfig != nil {
+		t.Errorf("GetConfigForClient() = %+v without mtls plugins, want base configuration", tlsConfig)
+	}
+}
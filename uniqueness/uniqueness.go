@@ -0,0 +1,209 @@
+// Package uniqueness keeps the userName and externalId of users and groups
+// unique for plugins whose backends have no unique constraints, such as
+// key-value stores or remote APIs.
+//
+// A plugin claims the values of a resource in an Index before writing it,
+// and releases them once they are no longer used:
+//
+//	if err := p.index.ClaimUser(ctx, user); err != nil {
+//		return nil, err // scim.ErrConflict, answered with 409 uniqueness
+//	}
+//	if err := p.backend.Put(ctx, user); err != nil {
+//		p.index.ReleaseUser(ctx, user, nil) // nolint:errcheck
+//		return nil, err
+//	}
+//
+// The Index keeps its claims in a Store: a MemoryStore for a single gateway
+// instance, or a store shared by several instances, such as
+// sqlutil.UniquenessStore or one on Redis.
+package uniqueness
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// Store keeps which owner claimed each key. Claims must be atomic, so two
+// owners claiming a key at once cannot both get it, e.g. with a unique
+// constraint or Redis SET NX. Implementations must be safe for concurrent
+// use.
+type Store interface {
+	// Claim assigns key to owner unless an owner holds it already. It
+	// returns the owner holding key afterwards, and whether this call
+	// assigned it.
+	Claim(ctx context.Context, key, owner string) (holder string, claimed bool, err error)
+
+	// Release removes key if owner holds it
+	Release(ctx context.Context, key, owner string) error
+}
+
+// MemoryStore is a Store keeping claims in memory
+type MemoryStore struct {
+	mu     sync.Mutex
+	owners map[string]string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{owners: make(map[string]string)}
+}
+
+// Claim implements Store
+func (s *MemoryStore) Claim(ctx context.Context, key, owner string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holder, ok := s.owners[key]; ok {
+		return holder, false, nil
+	}
+	s.owners[key] = owner
+	return owner, true, nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(ctx context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owners[key] == owner {
+		delete(s.owners, key)
+	}
+	return nil
+}
+
+// Len returns the number of claimed keys
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.owners)
+}
+
+// Index claims the unique values of the users and groups of a plugin in a
+// Store: the userName and externalId of users and the externalId of groups.
+// userName is unique ignoring case and externalId case-exact, as RFC 7643
+// defines them. Values are unique per base entity (see
+// scim.BaseEntityFromContext), and per plugin, so plugins can share a store.
+//
+// Resources claim their values by ID, so claiming the values a resource
+// already holds succeeds. Plugins with existing resources claim their values
+// when they start, e.g. in Initialize.
+type Index struct {
+	name  string
+	store Store
+}
+
+// NewIndex creates the index of the plugin name, claiming values in store
+func NewIndex(name string, store Store) *Index {
+	return &Index{name: name, store: store}
+}
+
+// ErrNoID is returned for resources claiming values without an ID, which
+// plugins assign before claiming
+var ErrNoID = errors.New("uniqueness: resource has no id")
+
+// claim is a unique value of a resource
+type claim struct {
+	attribute string
+	value     string
+}
+
+// userClaims returns the unique values of user
+func userClaims(user *scim.User) []claim {
+	var claims []claim
+	if user.UserName != "" {
+		claims = append(claims, claim{"userName", strings.ToLower(user.UserName)})
+	}
+	if user.ExternalID != "" {
+		claims = append(claims, claim{"externalId", user.ExternalID})
+	}
+	return claims
+}
+
+// groupClaims returns the unique values of group
+func groupClaims(group *scim.Group) []claim {
+	if group.ExternalID == "" {
+		return nil
+	}
+	return []claim{{"externalId", group.ExternalID}}
+}
+
+// ClaimUser claims the userName and externalId of user for its ID, before
+// it is created or replaced. If another user holds one of them, none are
+// claimed and ClaimUser returns scim.ErrConflict.
+func (x *Index) ClaimUser(ctx context.Context, user *scim.User) error {
+	return x.claim(ctx, scim.ResourceTypeUser, user.ID, userClaims(user))
+}
+
+// ReleaseUser releases the values of user that kept does not have: those
+// of a deleted user or a failed create with a nil kept, those a replace
+// changed with kept the new user, or those a failed replace claimed with
+// kept the user before it
+func (x *Index) ReleaseUser(ctx context.Context, user, kept *scim.User) error {
+	var keep []claim
+	if kept != nil {
+		keep = userClaims(kept)
+	}
+	return x.release(ctx, scim.ResourceTypeUser, user.ID, userClaims(user), keep)
+}
+
+// ClaimGroup claims the externalId of group for its ID, like ClaimUser
+func (x *Index) ClaimGroup(ctx context.Context, group *scim.Group) error {
+	return x.claim(ctx, scim.ResourceTypeGroup, group.ID, groupClaims(group))
+}
+
+// ReleaseGroup releases the values of group that kept does not have, like
+// ReleaseUser
+func (x *Index) ReleaseGroup(ctx context.Context, group, kept *scim.Group) error {
+	var keep []claim
+	if kept != nil {
+		keep = groupClaims(kept)
+	}
+	return x.release(ctx, scim.ResourceTypeGroup, group.ID, groupClaims(group), keep)
+}
+
+// claim claims the values of the resource of resourceType with id, and
+// releases those it claimed if one is held by another resource
+func (x *Index) claim(ctx context.Context, resourceType, id string, claims []claim) error {
+	if id == "" {
+		return ErrNoID
+	}
+	var claimed []claim
+	for _, c := range claims {
+		holder, ok, err := x.store.Claim(ctx, x.key(ctx, resourceType, c), id)
+		if ok {
+			claimed = append(claimed, c)
+		}
+		if err == nil && holder == id {
+			continue
+		}
+		// The values the resource held before stay claimed, e.g. its
+		// userName when a replace changing its externalId conflicts
+		x.release(ctx, resourceType, id, claimed, nil) // nolint:errcheck
+		if err != nil {
+			return err
+		}
+		return scim.ErrConflict(resourceType, c.attribute)
+	}
+	return nil
+}
+
+// release releases the values of the resource of resourceType with id not
+// in keep
+func (x *Index) release(ctx context.Context, resourceType, id string, claims, keep []claim) error {
+	var errs []error
+	for _, c := range claims {
+		if !slices.Contains(keep, c) {
+			errs = append(errs, x.store.Release(ctx, x.key(ctx, resourceType, c), id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// key returns the store key of a value, scoped to the plugin and the base
+// entity of ctx
+func (x *Index) key(ctx context.Context, resourceType string, c claim) string {
+	return strings.Join([]string{x.name, scim.BaseEntityFromContext(ctx), resourceType, c.attribute, c.value}, "/")
+}
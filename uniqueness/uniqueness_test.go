@@ -0,0 +1,89 @@
+package uniqueness
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+// wantConflict fails the test unless err is a 409 uniqueness error
+func wantConflict(t *testing.T, err error, what string) {
+	t.Helper()
+	var scimErr *scim.SCIMError
+	if !errors.As(err, &scimErr) || scimErr.Status != http.StatusConflict || scimErr.ScimType != scim.ScimTypeUniqueness {
+		t.Errorf("%s error = %v, want 409 uniqueness", what, err)
+	}
+}
+
+func TestIndex_Users(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	index := NewIndex("hr", store)
+
+	alice := &scim.User{ID: "1", UserName: "alice", ExternalID: "a-1"}
+	if err := index.ClaimUser(ctx, alice); err != nil {
+		t.Fatalf("ClaimUser() error = %v", err)
+	}
+	if err := index.ClaimUser(ctx, alice); err != nil {
+		t.Errorf("ClaimUser() of held values error = %v", err)
+	}
+	wantConflict(t, index.ClaimUser(ctx, &scim.User{ID: "2", UserName: "ALICE"}), "userName in other case")
+	wantConflict(t, index.ClaimUser(ctx, &scim.User{ID: "2", UserName: "bob", ExternalID: "a-1"}), "externalId")
+	if store.Len() != 2 {
+		t.Errorf("store has %d keys after conflicts, want the 2 of alice", store.Len())
+	}
+	if err := index.ClaimUser(ctx, &scim.User{ID: "2", UserName: "bob", ExternalID: "A-1"}); err != nil {
+		t.Errorf("ClaimUser() of case-exact externalId in other case error = %v", err)
+	}
+
+	// A replace conflicting on its new externalId keeps the userName held
+	wantConflict(t, index.ClaimUser(ctx, &scim.User{ID: "1", UserName: "alice", ExternalID: "A-1"}), "replace")
+	wantConflict(t, index.ClaimUser(ctx, &scim.User{ID: "3", UserName: "alice"}), "userName after failed replace")
+
+	// A replace renaming alice releases her former userName
+	renamed := &scim.User{ID: "1", UserName: "alice.smith", ExternalID: "a-1"}
+	if err := index.ClaimUser(ctx, renamed); err != nil {
+		t.Fatalf("ClaimUser() of renamed user error = %v", err)
+	}
+	if err := index.ReleaseUser(ctx, alice, renamed); err != nil {
+		t.Fatalf("ReleaseUser() error = %v", err)
+	}
+	if err := index.ClaimUser(ctx, &scim.User{ID: "3", UserName: "alice"}); err != nil {
+		t.Errorf("ClaimUser() of released userName error = %v", err)
+	}
+	wantConflict(t, index.ClaimUser(ctx, &scim.User{ID: "4", UserName: "alice.smith"}), "renamed userName")
+
+	if err := index.ClaimUser(ctx, &scim.User{UserName: "carol"}); !errors.Is(err, ErrNoID) {
+		t.Errorf("ClaimUser() without id error = %v, want ErrNoID", err)
+	}
+}
+
+func TestIndex_Scopes(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	hr, crm := NewIndex("hr", store), NewIndex("crm", store)
+
+	if err := hr.ClaimUser(ctx, &scim.User{ID: "1", UserName: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := crm.ClaimUser(ctx, &scim.User{ID: "2", UserName: "alice"}); err != nil {
+		t.Errorf("ClaimUser() of another plugin error = %v", err)
+	}
+	if err := hr.ClaimUser(scim.WithBaseEntity(ctx, "acme"), &scim.User{ID: "3", UserName: "alice"}); err != nil {
+		t.Errorf("ClaimUser() of another base entity error = %v", err)
+	}
+	if err := hr.ClaimGroup(ctx, &scim.Group{ID: "4", ExternalID: "alice"}); err != nil {
+		t.Errorf("ClaimGroup() error = %v", err)
+	}
+	wantConflict(t, hr.ClaimGroup(ctx, &scim.Group{ID: "5", ExternalID: "alice"}), "group externalId")
+
+	if err := hr.ReleaseGroup(ctx, &scim.Group{ID: "4", ExternalID: "alice"}, nil); err != nil {
+		t.Fatalf("ReleaseGroup() error = %v", err)
+	}
+	if err := hr.ClaimGroup(ctx, &scim.Group{ID: "5", ExternalID: "alice"}); err != nil {
+		t.Errorf("ClaimGroup() of released externalId error = %v", err)
+	}
+}
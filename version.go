@@ -0,0 +1,26 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/marcelom97/scimgateway/version"
+)
+
+// VersionMiddleware serves GET /version, reporting the build of the running
+// gateway (see version.Get) so operators can tell which build answers. Like
+// the health endpoints it is served before authentication. Other requests
+// are passed to next.
+func VersionMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.URL.Path != "/version" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(version.Get()) // nolint:errcheck
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package version reports the build of the gateway. Release builds embed
+// the version and commit with linker flags:
+//
+//	go build -ldflags "-X github.com/marcelom97/scimgateway/version.Version=v1.4.0 \
+//	    -X github.com/marcelom97/scimgateway/version.Commit=$(git rev-parse --short HEAD)"
+//
+// Without them the module version and VCS revision recorded by the Go
+// toolchain are reported, e.g. for binaries built with go install.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// modulePath is the path of the gateway module in build information
+const modulePath = "github.com/marcelom97/scimgateway"
+
+// Version and Commit are set at build time with -ldflags -X. Empty values
+// are read from the build information of the binary.
+var (
+	Version string
+	Commit  string
+)
+
+// Info describes the build of the running gateway
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+var (
+	buildOnce sync.Once
+	buildInfo Info
+)
+
+// Get returns the build of the running gateway. Version is "dev" when it was
+// neither set at build time nor recorded by the toolchain.
+func Get() Info {
+	buildOnce.Do(func() {
+		buildInfo = Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			if buildInfo.Version == "" {
+				buildInfo.Version = moduleVersion(bi)
+			}
+			if buildInfo.Commit == "" {
+				buildInfo.Commit = revision(bi)
+			}
+		}
+		if buildInfo.Version == "" {
+			buildInfo.Version = "dev"
+		}
+	})
+	return buildInfo
+}
+
+// UserAgent returns the User-Agent header of outbound requests of the
+// gateway, e.g. "scimgateway/v1.4.0"
+func UserAgent() string {
+	return "scimgateway/" + Get().Version
+}
+
+// moduleVersion returns the version of the gateway module in bi: the main
+// module for the gateway's own binaries, a dependency for embedding
+// applications
+func moduleVersion(bi *debug.BuildInfo) string {
+	if bi.Main.Path == modulePath && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	for _, dep := range bi.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// revision returns the abbreviated VCS revision recorded in bi
+func revision(bi *debug.BuildInfo) string {
+	for _, setting := range bi.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value[:min(len(setting.Value), 12)]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package version
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestModuleVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		bi   debug.BuildInfo
+		want string
+	}{
+		{"gateway binary", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.4.0"}}, "v1.4.0"},
+		{"local build", debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}}, ""},
+		{"embedding application", debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/idp-bridge", Version: "(devel)"},
+			Deps: []*debug.Module{{Path: "golang.org/x/text", Version: "v0.3.0"}, {Path: modulePath, Version: "v1.3.2"}},
+		}, "v1.3.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := moduleVersion(&tt.bi); got != tt.want {
+				t.Errorf("moduleVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevision(t *testing.T) {
+	bi := &debug.BuildInfo{Settings: []debug.BuildSetting{
+		{Key: "vcs", Value: "git"},
+		{Key: "vcs.revision", Value: "10bcd01c2f9e4b7a8d3e6f5a4b3c2d1e0f9a8b7c"},
+	}}
+	if got := revision(bi); got != "10bcd01c2f9e" {
+		t.Errorf("revision() = %q, want 10bcd01c2f9e", got)
+	}
+}
+
+func TestGet(t *testing.T) {
+	info := Get()
+	if info.Version == "" || info.GoVersion == "" {
+		t.Errorf("Get() = %+v, want a version and Go version", info)
+	}
+	if ua := UserAgent(); !strings.HasPrefix(ua, "scimgateway/") || !strings.HasSuffix(ua, info.Version) {
+		t.Errorf("UserAgent() = %q", ua)
+	}
+}
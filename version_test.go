@@ -0,0 +1,42 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marcelom97/scimgateway/version"
+)
+
+func TestVersionMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := VersionMiddleware()(next)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var info version.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if info != version.Get() {
+		t.Errorf("body = %+v, want %+v", info, version.Get())
+	}
+
+	// Plugin routes and other methods are passed on
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/version/Users", nil),
+		httptest.NewRequest(http.MethodPost, "/version", nil),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusTeapot {
+			t.Errorf("%s %s: status = %d, want the next handler", req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
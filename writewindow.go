@@ -0,0 +1,100 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/plugin"
+)
+
+// writeQueueStatus is the body of GET /{plugin}/WriteQueue
+type writeQueueStatus struct {
+	Open     bool                 `json:"open"`
+	NextOpen *time.Time           `json:"nextOpen,omitempty"`
+	Queued   []plugin.QueuedWrite `json:"queued"`
+}
+
+// WriteWindowMiddleware serves the admin endpoints of plugins configured with
+// writeWindow: GET /{plugin}/WriteQueue reports whether the window is open,
+// when it opens next and the writes queued, and POST
+// /{plugin}/WriteQueue/flush applies the queued writes right away, outside
+// the window or not. It is placed behind the plugin's authentication, and
+// authorization grants its endpoints with the admin operation. Other
+// requests, and requests to plugins without a write window, are passed to
+// next.
+func WriteWindowMiddleware(manager *plugin.Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name, endpoint, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+			if !ok || (endpoint != "WriteQueue" && endpoint != "WriteQueue/flush") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			window, ok := manager.GetWriteWindow(name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			switch {
+			case endpoint == "WriteQueue" && r.Method == http.MethodGet:
+				status := writeQueueStatus{Open: window.Open(), Queued: window.Queued()}
+				if next := window.NextOpen(); !status.Open && !next.IsZero() {
+					status.NextOpen = &next
+				}
+				writeAdminJSON(w, status)
+			case endpoint == "WriteQueue/flush" && r.Method == http.MethodPost:
+				writeAdminJSON(w, window.Flush(r.Context()))
+			default:
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// writeAdminJSON writes the body of a successful admin request
+func writeAdminJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(body) // nolint:errcheck
+}
+
+// registerWriteWindows logs the queued writes applied by the write windows
+// of plugins and registers a scimgateway_write_queue_length gauge per plugin
+// with a write window. The windows are looked up at scrape time, so gauges
+// follow windows recreated on reload.
+func (g *Gateway) registerWriteWindows() {
+	for _, name := range g.pluginManager.List() {
+		window, ok := g.pluginManager.GetWriteWindow(name)
+		if !ok {
+			continue
+		}
+		window.OnFlush(g.logWriteFlush)
+		g.metrics.GaugeFunc("scimgateway_write_queue_length",
+			"Number of writes queued until the write window of a plugin opens.",
+			metrics.Labels{"plugin": name},
+			func() float64 {
+				window, ok := g.pluginManager.GetWriteWindow(name)
+				if !ok {
+					return 0
+				}
+				return float64(len(window.Queued()))
+			},
+		)
+	}
+}
+
+// logWriteFlush logs the result of applying queued writes
+func (g *Gateway) logWriteFlush(result plugin.FlushResult) {
+	g.logger.Info("applied queued writes",
+		"plugin", result.Plugin,
+		"applied", result.Applied,
+		"failed", len(result.Failed),
+	)
+	for _, failed := range result.Failed {
+		g.logger.Error("queued write failed", "plugin", result.Plugin, "operation", failed.Operation, "error", failed.Error)
+	}
+}
@@ -0,0 +1,76 @@
+package scimgateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcelom97/scimgateway/clock"
+	"github.com/marcelom97/scimgateway/config"
+	"github.com/marcelom97/scimgateway/internal/testutil"
+	"github.com/marcelom97/scimgateway/metrics"
+	"github.com/marcelom97/scimgateway/scim"
+)
+
+func TestWriteWindowQueue(t *testing.T) {
+	cfg := bearerConfig("token")
+	cfg.Plugins[0].WriteWindow = &config.WriteWindowConfig{
+		Schedule: []string{"* 22-23,0-5 * * *"},
+		TimeZone: "UTC",
+		Mode:     config.WriteWindowQueue,
+	}
+	gw := New(cfg)
+	gw.SetClock(clock.NewFake(time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)))
+	gw.RegisterPlugin(testutil.NewMemoryPlugin("test"))
+	if err := gw.Initialize(); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+	handler, _ := gw.Handler()
+
+	do := func(method, path, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/test/Users", `{"userName": "alice"}`, "token")
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), "opens at 2025-01-15T22:00:00Z") {
+		t.Fatalf("create status = %d, body: %s", w.Code, w.Body.String())
+	}
+	if length, _ := gw.Metrics().Value("scimgateway_write_queue_length", metrics.Labels{"plugin": "test"}); length != 1 {
+		t.Errorf("write_queue_length = %v, want 1", length)
+	}
+
+	// The admin endpoints require the plugin's credentials
+	if w := do("GET", "/test/WriteQueue", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated status = %d, want 401", w.Code)
+	}
+
+	var status writeQueueStatus
+	if err := json.Unmarshal(do("GET", "/test/WriteQueue", "", "token").Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Open || status.NextOpen == nil || len(status.Queued) != 1 || status.Queued[0].Operation != "POST Users" {
+		t.Errorf("write queue status = %+v", status)
+	}
+
+	w = do("POST", "/test/WriteQueue/flush", "", "token")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"applied":1`) {
+		t.Fatalf("flush status = %d, body: %s", w.Code, w.Body.String())
+	}
+
+	var list scim.ListResponse[*scim.User]
+	if err := json.Unmarshal(do("GET", "/test/Users", "", "token").Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if list.TotalResults != 1 || list.Resources[0].UserName != "alice" {
+		t.Errorf("users after flush = %+v, want alice", list.Resources)
+	}
+}